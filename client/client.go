@@ -0,0 +1,1281 @@
+// Package client talks to a bzzz server: creating and running games as a
+// host, joining and buzzing as a player, and listening to either stream as
+// typed events.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls a single bzzz server.
+type Client struct {
+	// BaseURL is the server's address, e.g. https://bzzz.example.com
+	BaseURL string
+	// APIKey identifies the tenant creating games on multi tenant servers
+	APIKey string
+	// AccountKey identifies the host account creating games, see Register
+	AccountKey string
+	// HTTPClient makes the requests, http.DefaultClient when nil. streams
+	// stay open, so it shouldn't have a timeout
+	HTTPClient *http.Client
+	// ClientID names this client on host streams. keep it the same across
+	// reconnects and each new stream replaces the last rather than the
+	// server writing to both until it notices the old one is gone
+	ClientID string
+	// Events, if set, are the only events streams are sent, e.g.
+	// ActionBuzz and ActionScore for a scoreboard display. events clients
+	// can't do without, such as ActionShutdown, are sent regardless
+	Events []string
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Error is a request the server turned down.
+type Error struct {
+	StatusCode int
+	// Code says what went wrong, e.g. CodeGameNotFound, for branching on
+	// instead of Message, which can be localized
+	Code    string
+	Message string
+	// Field is the request field at fault, when the server said
+	Field string
+	// RetryAfter is how long until the request would be accepted, for
+	// buzzes made during a cooldown
+	RetryAfter time.Duration
+	// Suggestion is a value the server would take instead, such as a free
+	// name when joining with one that's taken
+	Suggestion string
+	// Details is everything the server said about the error, still
+	// encoded, e.g. the "result" of a buzz that wasn't counted
+	Details map[string]json.RawMessage
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("bzzz: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("bzzz: %d %s", e.StatusCode, e.Message)
+}
+
+// some of the codes an Error can have. the server's OpenAPI document
+// doesn't list them, new ones can turn up.
+const (
+	CodeGameNotFound     = "GAME_NOT_FOUND"
+	CodePlayerNotFound   = "PLAYER_NOT_FOUND"
+	CodeGameFull         = "GAME_FULL"
+	CodeGamePaused       = "GAME_PAUSED"
+	CodeGameEnded        = "GAME_ENDED"
+	CodeHostAway         = "HOST_AWAY"
+	CodeBanned           = "BANNED"
+	CodeNameTaken        = "NAME_TAKEN"
+	CodeBuzzersLocked    = "BUZZERS_LOCKED"
+	CodeAlreadyBuzzed    = "ALREADY_BUZZED"
+	CodeTeamLockedOut    = "TEAM_LOCKED_OUT"
+	CodeNotYourTurn      = "NOT_YOUR_TURN"
+	CodeLateBuzz         = "LATE_BUZZ"
+	CodeStaleBuzz        = "STALE_BUZZ"
+	CodeBuzzCooldown     = "BUZZ_COOLDOWN"
+	CodeBadHostToken     = "BAD_HOST_TOKEN"
+	CodeBadPlayerToken   = "BAD_PLAYER_TOKEN"
+	CodeSessionExpired   = "SESSION_EXPIRED"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeInternalError    = "INTERNAL_ERROR"
+	CodeUnavailable      = "UNAVAILABLE"
+	CodeQuotaExceeded    = "QUOTA_EXCEEDED"
+	CodeGameCodeTaken    = "GAME_CODE_TAKEN"
+	CodeTemplateNotFound = "TEMPLATE_NOT_FOUND"
+	CodeNotYourPick      = "NOT_YOUR_PICK"
+	CodeCellUsed         = "CELL_USED"
+	CodeDeviceTaken      = "DEVICE_TAKEN"
+	CodeBuzzerLocked     = "BUZZER_LOCKED"
+	CodeStreamNotFound   = "STREAM_NOT_FOUND"
+	CodeEarlyBuzz        = "EARLY_BUZZ"
+	CodeNothingToUndo    = "NOTHING_TO_UNDO"
+	CodeUndoStale        = "UNDO_STALE"
+	CodeBodyTooLarge     = "BODY_TOO_LARGE"
+	CodeUnknownField     = "UNKNOWN_FIELD"
+	CodeNoCaptains       = "NO_CAPTAINS"
+	CodeNotCaptain       = "NOT_CAPTAIN"
+	CodeNotOnTeam        = "NOT_ON_TEAM"
+	CodeNoTeamRequest    = "NO_TEAM_REQUEST"
+)
+
+// eventVersion is the event schema version the client reads. it's asked
+// for on every stream and poll, so the client keeps working when the
+// server's default moves on.
+const eventVersion = "1"
+
+// GameOptions are what a host can ask for when creating a game. zero
+// values leave the server's defaults.
+type GameOptions struct {
+	// Template is a saved template to start from, see SaveTemplate. the
+	// other options override its settings
+	Template      string
+	Locale        string
+	OrphanPolicy  string
+	MaxDuration   string
+	TeamLockout   bool
+	Recognition   string
+	TurnTimeout   string
+	FirstBuzzWins bool
+	// ArmedBuzzing only takes buzzes while the host has the buzzers armed,
+	// see Arm. buzzes before then fail with CodeEarlyBuzz
+	ArmedBuzzing bool
+	// BuzzCooldown is a duration such as "3s", "0s" turns off the
+	// server's default
+	BuzzCooldown string
+	// AlmostWindow is a duration such as "500ms", how long after the
+	// winner of a first buzz wins question late buzzes are reported as
+	// close calls
+	AlmostWindow string
+	// LatencyCompensation orders buzzes by when they were pressed, taking
+	// off half of each player's round trip time as measured by pings
+	LatencyCompensation bool
+	// Code asks for a game code of the host's own, 4 to 16 letters and
+	// digits
+	Code string
+	// MaxPlayers caps the players who can join. with WaitingRoom, players
+	// joining a full game wait for a slot instead of being turned away
+	MaxPlayers  int
+	WaitingRoom bool
+	// RecoveryPIN, 4 to 8 digits, lets TransferWithPIN take the game over
+	// if the host token is lost
+	RecoveryPIN string
+	// Webhooks are URLs the game's events are POSTed to, signed with
+	// Game.WebhookSecret. WebhookEvents picks the actions sent, the server
+	// picks the key ones if it's empty
+	Webhooks      []string
+	WebhookEvents []string
+	// Scoring is how the host's verdicts turn into points, the server's
+	// plain rules when nil
+	Scoring *ScoringRules
+	// Public lists the game in PublicGames under Name, for players to find
+	// without being given the code
+	Public bool
+	Name   string
+	// Captains gives each team a captain, who answers for the team, asks
+	// for timeouts and accepts or removes members. players joining a team
+	// that has one wait for them to accept them, see Player.RequestedTeam
+	Captains bool
+	// Tiebreak is how buzzes too close together to tell apart are ordered,
+	// "arrival" (the default), "random", "alphabetical" or "lowest_score".
+	// the host hears about every tie as ActionBuzzTie
+	Tiebreak string
+}
+
+// ScoringRules are how Judge turns a verdict into points when it isn't
+// given them.
+type ScoringRules struct {
+	// Correct is what a right answer wins on a question without points of
+	// its own, 1 when 0
+	Correct int `json:"correct,omitempty"`
+	// Wrong is what a wrong answer costs, unless WrongCostsValue makes it
+	// cost what a right answer would have won
+	Wrong           int  `json:"wrong,omitempty"`
+	WrongCostsValue bool `json:"wrongCostsValue,omitempty"`
+	// RoundMultipliers scale points round by round, the last one for
+	// every round after the list runs out
+	RoundMultipliers []float64 `json:"roundMultipliers,omitempty"`
+	// Floor is as low as a wrong answer can take a score, none when nil
+	Floor *int `json:"floor,omitempty"`
+	// EarlyBuzz is what buzzing before the buzzers are armed costs in an
+	// ArmedBuzzing game
+	EarlyBuzz int `json:"earlyBuzz,omitempty"`
+}
+
+func (o GameOptions) query() url.Values {
+	q := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	set("template", o.Template)
+	set("locale", o.Locale)
+	set("orphanPolicy", o.OrphanPolicy)
+	set("maxDuration", o.MaxDuration)
+	set("recognition", o.Recognition)
+	set("turnTimeout", o.TurnTimeout)
+	set("buzzCooldown", o.BuzzCooldown)
+	set("almostWindow", o.AlmostWindow)
+	set("code", o.Code)
+	set("recoveryPin", o.RecoveryPIN)
+	if o.TeamLockout {
+		q.Set("teamLockout", "true")
+	}
+	if o.FirstBuzzWins {
+		q.Set("firstBuzzWins", "true")
+	}
+	if o.ArmedBuzzing {
+		q.Set("armedBuzzing", "true")
+	}
+	if o.LatencyCompensation {
+		q.Set("latencyCompensation", "true")
+	}
+	if o.MaxPlayers > 0 {
+		q.Set("maxPlayers", strconv.Itoa(o.MaxPlayers))
+	}
+	if o.WaitingRoom {
+		q.Set("waitingRoom", "true")
+	}
+	for _, u := range o.Webhooks {
+		q.Add("webhook", u)
+	}
+	set("webhookEvents", strings.Join(o.WebhookEvents, ","))
+	if o.Scoring != nil {
+		b, _ := json.Marshal(o.Scoring)
+		q.Set("scoring", string(b))
+	}
+	if o.Public {
+		q.Set("public", "true")
+	}
+	set("name", o.Name)
+	if o.Captains {
+		q.Set("captains", "true")
+	}
+	set("tiebreak", o.Tiebreak)
+	return q
+}
+
+// Game is a newly created game.
+type Game struct {
+	Code      string `json:"gameCode"`
+	HostToken string `json:"hostToken"`
+	EndsAt    string `json:"endsAt,omitempty"`
+	// WebhookSecret signs the game's webhooks, if it was given any
+	WebhookSecret string `json:"webhookSecret,omitempty"`
+}
+
+// JoinLink is how players get to a game's join page. ShortURL is a short
+// link redirecting there, if the server has a join page, and URL the link
+// the game's QR code holds.
+type JoinLink struct {
+	GameCode string `json:"gameCode"`
+	JoinURL  string `json:"joinURL"`
+	ShortURL string `json:"shortURL,omitempty"`
+	URL      string `json:"url"`
+}
+
+// Rematch is a game started as a rematch of another, with the players
+// brought along to it.
+type Rematch struct {
+	Game
+	SameCode bool            `json:"sameCode"`
+	Players  []RematchPlayer `json:"players"`
+}
+
+// Player is a player who has joined a game. Token reconnects as them, as
+// does Session until it expires.
+type Player struct {
+	GameID   string `json:"gameID"`
+	PlayerID string `json:"playerID"`
+	Name     string `json:"playerName"`
+	Team     string `json:"team,omitempty"`
+	// Avatar is what the player picked to go with their name, Color the
+	// one the server gave them so displays can tell players apart
+	Avatar  string `json:"avatar,omitempty"`
+	Color   string `json:"color,omitempty"`
+	Token   string `json:"token"`
+	Session string `json:"session"`
+	// Waiting is true for a player put in a full game's waiting room, at
+	// Position in line. their stream is held open until they get a slot,
+	// and they have no Session until then
+	Waiting  bool `json:"waiting,omitempty"`
+	Position int  `json:"position,omitempty"`
+	// RequestedTeam is the team a player joining a game with captains
+	// asked for, when its captain has yet to accept them. they're on no
+	// team until then
+	RequestedTeam string `json:"requestedTeam,omitempty"`
+}
+
+// BuzzResult is how a counted buzz went.
+type BuzzResult struct {
+	// Result is "accepted", "duplicate" for a retry of a buzz already
+	// counted, or "practice" during a soundcheck or practice mode
+	Result string `json:"result"`
+	// Position is the buzz's place in the buzz order, counting from 1
+	Position int   `json:"position,omitempty"`
+	Buzz     *Buzz `json:"buzz,omitempty"`
+}
+
+// State is a snapshot of a game, as of the event numbered Seq. the rest is
+// left encoded.
+type State struct {
+	GameID     string          `json:"gameID"`
+	Seq        int             `json:"seq"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+	Roster     json.RawMessage `json:"roster"`
+	Scoreboard json.RawMessage `json:"scoreboard"`
+	Question   json.RawMessage `json:"question"`
+	// Timer is set while a countdown is running
+	Timer json.RawMessage `json:"timer,omitempty"`
+	// PausedAt is set while the game is paused
+	PausedAt *time.Time `json:"pausedAt,omitempty"`
+	// Phase is the game's lifecycle state, one of the State constants
+	Phase string `json:"state"`
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// do sends a request and decodes a JSON response into out, if given.
+// token, a host token or a player's, is sent as a bearer token when set.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, token string, body, out interface{}) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.AccountKey != "" {
+		req.Header.Set("X-Account-Key", c.AccountKey)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func responseError(resp *http.Response) error {
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	msg := strings.TrimSpace(string(b))
+
+	var body struct {
+		Code    string                     `json:"code"`
+		Message string                     `json:"message"`
+		Details map[string]json.RawMessage `json:"details"`
+	}
+	if json.Unmarshal(b, &body) != nil || body.Code == "" {
+		// e.g. a proxy in front of the server turning it down
+		return &Error{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	e := &Error{StatusCode: resp.StatusCode, Code: body.Code, Message: body.Message, Details: body.Details}
+	var retryAfterMs int64
+	json.Unmarshal(body.Details["field"], &e.Field)
+	json.Unmarshal(body.Details["suggestion"], &e.Suggestion)
+	json.Unmarshal(body.Details["retryAfterMs"], &retryAfterMs)
+	e.RetryAfter = time.Duration(retryAfterMs) * time.Millisecond
+	return e
+}
+
+// CreateGame starts a new game, returning its code and host token.
+func (c *Client) CreateGame(ctx context.Context, opts GameOptions) (*Game, error) {
+	var g Game
+	if err := c.do(ctx, http.MethodPost, "/api/host", opts.query(), "", nil, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// PublicGame is a public game that can be joined.
+type PublicGame struct {
+	GameID     string `json:"gameID"`
+	Name       string `json:"name,omitempty"`
+	Locale     string `json:"locale,omitempty"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"maxPlayers,omitempty"`
+	// Status is "lobby" before the game gets going, then "playing", or
+	// "paused" while the host has paused it or is away
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// PublicGames lists the public games that can be joined, newest first. a
+// limit of 0 takes the server's default.
+func (c *Client) PublicGames(ctx context.Context, limit int) ([]PublicGame, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out struct {
+		Games []PublicGame `json:"games"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/games/public", q, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Games, nil
+}
+
+// Account is a registered host account.
+type Account struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Email     string       `json:"email,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Keys      []AccountKey `json:"keys"`
+	// Games are the games the account created, oldest first
+	Games []AccountGame `json:"games"`
+}
+
+// AccountKey is one of an account's API keys. the key itself is only
+// returned when it's issued, Hint is its last few characters.
+type AccountKey struct {
+	ID        string    `json:"id"`
+	Hint      string    `json:"hint"`
+	CreatedAt time.Time `json:"createdAt"`
+	APIKey    string    `json:"apiKey,omitempty"`
+}
+
+// AccountGame is a game an account created.
+type AccountGame struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Register creates a host account and sets AccountKey to its first API
+// key, which is returned too as it isn't shown again.
+func (c *Client) Register(ctx context.Context, name, email string) (*Account, string, error) {
+	var out struct {
+		Account Account `json:"account"`
+		APIKey  string  `json:"apiKey"`
+	}
+	body := map[string]string{"name": name, "email": email}
+	if err := c.do(ctx, http.MethodPost, "/api/accounts", nil, "", body, &out); err != nil {
+		return nil, "", err
+	}
+	c.AccountKey = out.APIKey
+	return &out.Account, out.APIKey, nil
+}
+
+// Account returns the host account of AccountKey.
+func (c *Client) Account(ctx context.Context) (*Account, error) {
+	var a Account
+	if err := c.do(ctx, http.MethodGet, "/api/account", nil, "", nil, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateAccountKey issues the account another API key, with APIKey set.
+func (c *Client) CreateAccountKey(ctx context.Context) (*AccountKey, error) {
+	var k AccountKey
+	if err := c.do(ctx, http.MethodPost, "/api/account/keys", nil, "", nil, &k); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// RevokeAccountKey revokes one of the account's API keys by ID.
+func (c *Client) RevokeAccountKey(ctx context.Context, keyID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/account/keys/"+url.PathEscape(keyID), nil, "", nil, nil)
+}
+
+// ReplayEvent is an event of a game as it was originally sent, to To,
+// "all", "players" or "host", at At.
+type ReplayEvent struct {
+	Seq        int             `json:"seq"`
+	Txn        int             `json:"txn,omitempty"`
+	At         time.Time       `json:"at"`
+	Action     string          `json:"action"`
+	PlayerID   string          `json:"playerID,omitempty"`
+	PlayerName string          `json:"playerName,omitempty"`
+	Team       string          `json:"team,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	To         string          `json:"to"`
+	Private    bool            `json:"private,omitempty"`
+}
+
+// Replay returns every event of a game with seqs from from to to, either 0
+// for no limit, also after the game has ended.
+func (c *Client) Replay(ctx context.Context, gameID, hostToken string, from, to int) ([]ReplayEvent, error) {
+	q := url.Values{"mode": {"all"}}
+	if from > 0 {
+		q.Set("from", strconv.Itoa(from))
+	}
+	if to > 0 {
+		q.Set("to", strconv.Itoa(to))
+	}
+	var out struct {
+		Events []ReplayEvent `json:"events"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/game/"+gameID+"/replay", q, hostToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Events, nil
+}
+
+// Template is a saved game setup for CreateGame to start from, with the
+// settings of a game plus teams and a question bank. the settings are named
+// as in the create game API, e.g. {"maxPlayers": 40}.
+type Template struct {
+	Name      string                 `json:"name"`
+	Settings  map[string]interface{} `json:"-"`
+	Teams     []string               `json:"teams,omitempty"`
+	Questions []TemplateQuestion     `json:"questions,omitempty"`
+	UpdatedAt time.Time              `json:"updatedAt"`
+}
+
+// TemplateQuestion is a question in a template's question bank.
+type TemplateQuestion struct {
+	Text   string `json:"text"`
+	Answer string `json:"answer,omitempty"`
+	Points int    `json:"points,omitempty"`
+	// Category puts the question on a board, see StartBoard
+	Category string `json:"category,omitempty"`
+}
+
+// MarshalJSON puts the settings alongside the rest, as the server has them.
+func (t Template) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{}
+	for k, v := range t.Settings {
+		m[k] = v
+	}
+	m["name"] = t.Name
+	if len(t.Teams) > 0 {
+		m["teams"] = t.Teams
+	}
+	if len(t.Questions) > 0 {
+		m["questions"] = t.Questions
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON picks the settings out from the rest.
+func (t *Template) UnmarshalJSON(b []byte) error {
+	type plain Template
+	if err := json.Unmarshal(b, (*plain)(t)); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &t.Settings); err != nil {
+		return err
+	}
+	for _, k := range []string{"name", "teams", "questions", "updatedAt"} {
+		delete(t.Settings, k)
+	}
+	return nil
+}
+
+// SaveTemplate saves a template under t.Name, replacing one of the same
+// name.
+func (c *Client) SaveTemplate(ctx context.Context, t Template) (*Template, error) {
+	var saved Template
+	if err := c.do(ctx, http.MethodPut, "/api/templates/"+url.PathEscape(t.Name), nil, "", t, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Templates lists the saved templates, by name.
+func (c *Client) Templates(ctx context.Context) ([]Template, error) {
+	var out struct {
+		Templates []Template `json:"templates"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/templates", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Templates, nil
+}
+
+// DeleteTemplate deletes a saved template.
+func (c *Client) DeleteTemplate(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/templates/"+url.PathEscape(name), nil, "", nil, nil)
+}
+
+// Join adds a player to a game. the server picks a name when name is
+// empty.
+func (c *Client) Join(ctx context.Context, gameID, name, team string) (*Player, error) {
+	return c.JoinWithAvatar(ctx, gameID, name, team, "")
+}
+
+// JoinWithAvatar is Join, with an emoji or a few characters shown next to
+// the player's name.
+func (c *Client) JoinWithAvatar(ctx context.Context, gameID, name, team, avatar string) (*Player, error) {
+	var p Player
+	body := map[string]string{"name": name, "team": team, "avatar": avatar}
+	if err := c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/join", nil, "", body, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Buzz buzzes in for a player. like every call made as a player it takes
+// their token, or their session. nonce, when set, makes retrying the same
+// buzz safe.
+func (c *Client) Buzz(ctx context.Context, gameID, playerID, token, nonce string) error {
+	_, err := c.BuzzIn(ctx, gameID, playerID, token, nonce)
+	return err
+}
+
+// BuzzIn is Buzz, also returning where the buzz landed in the buzz order.
+// a buzz that isn't counted, because the buzzers are locked or it was too
+// late, fails with an *Error.
+func (c *Client) BuzzIn(ctx context.Context, gameID, playerID, token, nonce string) (*BuzzResult, error) {
+	return c.BuzzInFor(ctx, gameID, playerID, token, nonce, 0)
+}
+
+// BuzzInFor is BuzzIn for a given question. if the host has reset it by
+// the time the buzz arrives, it fails with CodeStaleBuzz rather than
+// counting for the next question.
+func (c *Client) BuzzInFor(ctx context.Context, gameID, playerID, token, nonce string, questionID int) (*BuzzResult, error) {
+	var res BuzzResult
+	body := map[string]interface{}{"gameID": gameID, "playerID": playerID, "nonce": nonce}
+	if questionID != 0 {
+		body["questionID"] = questionID
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/buzz", nil, token, body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Pong answers a ping in a latency compensated game, returning the
+// player's smoothed round trip time.
+func (c *Client) Pong(ctx context.Context, gameID, playerID, token string, pingID int64) (time.Duration, error) {
+	var resp struct {
+		RttMs float64 `json:"rttMs"`
+	}
+	body := map[string]interface{}{"playerID": playerID, "pingID": pingID}
+	if err := c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/pong", nil, token, body, &resp); err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.RttMs * float64(time.Millisecond)), nil
+}
+
+// Latency is what a ping to the server measured.
+type Latency struct {
+	RTT time.Duration
+	// Offset is how far the server's clock is ahead of this one, taking
+	// the answer to have been sent halfway through the round trip
+	Offset time.Duration
+}
+
+// Ping measures the round trip to the server and the offset of its clock.
+func (c *Client) Ping(ctx context.Context, gameID string) (*Latency, error) {
+	var resp struct {
+		ServerTime time.Time `json:"serverTime"`
+	}
+	sent := time.Now()
+	if err := c.do(ctx, http.MethodGet, "/api/play/"+url.PathEscape(gameID)+"/ping", nil, "", nil, &resp); err != nil {
+		return nil, err
+	}
+	rtt := time.Since(sent)
+	return &Latency{RTT: rtt, Offset: resp.ServerTime.Sub(sent.Add(rtt / 2))}, nil
+}
+
+// SyncClock works out the offset of the server's clock from this one, out
+// of samples round trips, keeping the one with the shortest round trip
+// since it says the most about when the server answered. it needs no game.
+func (c *Client) SyncClock(ctx context.Context, samples int) (*Latency, error) {
+	var best *Latency
+	for i := 0; i < samples || best == nil; i++ {
+		var resp struct {
+			ReceivedMs int64 `json:"receivedMs"`
+			SentMs     int64 `json:"sentMs"`
+		}
+		t0 := time.Now()
+		if err := c.do(ctx, http.MethodGet, "/api/time", nil, "", nil, &resp); err != nil {
+			return nil, err
+		}
+		t3 := time.Now()
+
+		received := time.Duration(resp.ReceivedMs) * time.Millisecond
+		sent := time.Duration(resp.SentMs) * time.Millisecond
+		l := &Latency{
+			RTT:    t3.Sub(t0) - (sent - received),
+			Offset: (received - time.Duration(t0.UnixNano()) + sent - time.Duration(t3.UnixNano())) / 2,
+		}
+		if best == nil || l.RTT < best.RTT {
+			best = l
+		}
+	}
+	return best, nil
+}
+
+// Transfer swaps a game's host token for a new one, for handing the game
+// to another device. streams opened with the old token are closed.
+func (c *Client) Transfer(ctx context.Context, gameID, hostToken string) (*Game, error) {
+	var g Game
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/transfer", nil, hostToken, nil, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// TransferWithPIN takes a game over with its recovery PIN, when the host
+// token has been lost.
+func (c *Client) TransferWithPIN(ctx context.Context, gameID, pin string) (*Game, error) {
+	var g Game
+	body := map[string]string{"recoveryPin": pin}
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/transfer", nil, "", body, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// State fetches a snapshot of a game, for resyncing a stream that has
+// missed events.
+func (c *Client) State(ctx context.Context, gameID string) (*State, error) {
+	var st State
+	if err := c.do(ctx, http.MethodGet, "/api/game/"+url.PathEscape(gameID)+"/state", nil, "", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// JoinLink fetches the links to a game's join page, in the locale of the
+// client's Accept-Language. the QR code of URL, for showing on a projector,
+// is served as an image at /api/game/{id}/qr.
+func (c *Client) JoinLink(ctx context.Context, gameID string) (*JoinLink, error) {
+	var link JoinLink
+	query := url.Values{"format": {"json"}}
+	if err := c.do(ctx, http.MethodGet, "/api/game/"+url.PathEscape(gameID)+"/qr", query, "", nil, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// PlayerStats returns a player's buzzes, wins, average reaction time,
+// judged answers and points so far in a game.
+func (c *Client) PlayerStats(ctx context.Context, gameID, playerID string) (*PlayerStats, error) {
+	var s PlayerStats
+	path := "/api/game/" + url.PathEscape(gameID) + "/players/" + url.PathEscape(playerID) + "/stats"
+	if err := c.do(ctx, http.MethodGet, path, nil, "", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Answer submits the answer of the player who buzzed in.
+func (c *Client) Answer(ctx context.Context, gameID, playerID, token, text string) error {
+	body := map[string]string{"playerID": playerID, "text": text}
+	return c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/answer", nil, token, body, nil)
+}
+
+// what a player can react with, see React
+const (
+	ReactionHandRaise = "hand_raise"
+	ReactionThumbsUp  = "thumbs_up"
+	ReactionLaugh     = "laugh"
+)
+
+// React sends the host a player's reaction, one of the Reaction constants.
+func (c *Client) React(ctx context.Context, gameID, playerID, token, reaction string) error {
+	body := map[string]interface{}{"playerID": playerID, "reaction": reaction}
+	return c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/react", nil, token, body, nil)
+}
+
+// AcceptTeamMember lets memberID onto the team captained by playerID,
+// after they asked for it when joining. it fails with CodeNotCaptain
+// unless playerID is the team's captain.
+func (c *Client) AcceptTeamMember(ctx context.Context, gameID, playerID, token, memberID string) error {
+	body := map[string]string{"playerID": playerID, "memberID": memberID}
+	return c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/team/accept", nil, token, body, nil)
+}
+
+// RemoveTeamMember takes memberID off the team captained by playerID. they
+// stay in the game without a team.
+func (c *Client) RemoveTeamMember(ctx context.Context, gameID, playerID, token, memberID string) error {
+	body := map[string]string{"playerID": playerID, "memberID": memberID}
+	return c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/team/remove", nil, token, body, nil)
+}
+
+// RequestTimeout asks the host for a timeout on behalf of the team
+// captained by playerID. whether to pause is up to the host.
+func (c *Client) RequestTimeout(ctx context.Context, gameID, playerID, token string) (*TimeoutData, error) {
+	var t TimeoutData
+	body := map[string]string{"playerID": playerID}
+	if err := c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/team/timeout", nil, token, body, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Wager stakes points and answers in a wager round. either can be sent on
+// its own and changed until the host closes wagers, a nil wager keeps the
+// one already made.
+func (c *Client) Wager(ctx context.Context, gameID, playerID, token string, wager *int, answer string) (*Wager, error) {
+	var w Wager
+	body := map[string]interface{}{"playerID": playerID, "answer": answer}
+	if wager != nil {
+		body["wager"] = *wager
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/wager", nil, token, body, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// AllPlay answers in an all-play round. each player answers once.
+func (c *Client) AllPlay(ctx context.Context, gameID, playerID, token, answer string) (*AllPlayAnswer, error) {
+	var a AllPlayAnswer
+	body := map[string]string{"playerID": playerID, "answer": answer}
+	if err := c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/allplay", nil, token, body, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Board returns a game's category board.
+func (c *Client) Board(ctx context.Context, gameID string) (*BoardData, error) {
+	var b BoardData
+	if err := c.do(ctx, http.MethodGet, "/api/game/"+url.PathEscape(gameID)+"/board", nil, "", nil, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Pick picks the next question off the board by its ID, for the player
+// with the pick. it fails with CodeNotYourPick for anyone else.
+func (c *Client) Pick(ctx context.Context, gameID, playerID, token string, questionID int) (*BoardData, error) {
+	var b BoardData
+	body := map[string]interface{}{"playerID": playerID, "questionID": questionID}
+	if err := c.do(ctx, http.MethodPost, "/api/play/"+url.PathEscape(gameID)+"/pick", nil, token, body, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// hostAction posts to one of a game's host endpoints.
+func (c *Client) hostAction(ctx context.Context, gameID, hostToken, action string, body interface{}) error {
+	return c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/"+action, nil, hostToken, body, nil)
+}
+
+// Lock locks a game's buzzers.
+func (c *Client) Lock(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "lock", nil)
+}
+
+// Unlock unlocks a game's buzzers.
+func (c *Client) Unlock(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "unlock", nil)
+}
+
+// Arm arms a game's buzzers, timing reactions from now. in ArmedBuzzing
+// games buzzes are only taken from now until the buzzers are locked or
+// the question moves on.
+func (c *Client) Arm(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "arm", nil)
+}
+
+// LockPlayer locks one player's buzzer, leaving everyone else's as it is.
+func (c *Client) LockPlayer(ctx context.Context, gameID, hostToken, playerID string) error {
+	return c.hostAction(ctx, gameID, hostToken, "lock", map[string]string{"playerID": playerID})
+}
+
+// UnlockPlayer unlocks a buzzer locked by LockPlayer.
+func (c *Client) UnlockPlayer(ctx context.Context, gameID, hostToken, playerID string) error {
+	return c.hostAction(ctx, gameID, hostToken, "unlock", map[string]string{"playerID": playerID})
+}
+
+// LockTeam locks the buzzers of everyone on team, such as the team that
+// just answered wrong.
+func (c *Client) LockTeam(ctx context.Context, gameID, hostToken, team string) error {
+	return c.hostAction(ctx, gameID, hostToken, "lock", map[string]string{"team": team})
+}
+
+// UnlockTeam unlocks buzzers locked by LockTeam.
+func (c *Client) UnlockTeam(ctx context.Context, gameID, hostToken, team string) error {
+	return c.hostAction(ctx, gameID, hostToken, "unlock", map[string]string{"team": team})
+}
+
+// AppointCaptain makes a player on team its captain, in a game with
+// captains.
+func (c *Client) AppointCaptain(ctx context.Context, gameID, hostToken, team, playerID string) error {
+	return c.hostAction(ctx, gameID, hostToken, "teams/captain", map[string]string{"team": team, "playerID": playerID})
+}
+
+// PauseGame pauses a game for an intermission. buzzes and score changes
+// are turned away and any countdown stops until it's resumed.
+func (c *Client) PauseGame(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "pause", nil)
+}
+
+// ResumeGame resumes a paused game, restarting its countdown where it
+// stopped.
+func (c *Client) ResumeGame(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "resume", nil)
+}
+
+// EndGame ends a game, sending everyone the final results and players a
+// farewell. the game is torn down once the results have been up a while.
+func (c *Client) EndGame(ctx context.Context, gameID, hostToken string) (*ResultsData, error) {
+	var results ResultsData
+	err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/end", nil, hostToken, nil, &results)
+	if err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// Rematch starts a fresh game with the same players, teams and settings on
+// zero points, and sends everyone an ActionRematch event with how to rejoin.
+// with sameCode it keeps the game's code, and everyone their tokens.
+func (c *Client) Rematch(ctx context.Context, gameID, hostToken string, sameCode bool) (*Rematch, error) {
+	var out Rematch
+	body := map[string]bool{"sameCode": sameCode}
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/rematch", nil, hostToken, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SendMessage sends text, data or both from the host to every player, or
+// only to playerID when it isn't empty.
+func (c *Client) SendMessage(ctx context.Context, gameID, hostToken, playerID, text string, data json.RawMessage) error {
+	body := map[string]interface{}{"text": text}
+	if playerID != "" {
+		body["playerID"] = playerID
+	}
+	if len(data) > 0 {
+		body["data"] = data
+	}
+	return c.hostAction(ctx, gameID, hostToken, "message", body)
+}
+
+// Reset clears the buzzes, unlocks the buzzers and moves on to the next
+// question.
+func (c *Client) Reset(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "reset", nil)
+}
+
+// Judge marks a player's answer right or wrong. points is what the game's
+// scoring rules make it worth when nil.
+func (c *Client) Judge(ctx context.Context, gameID, hostToken, playerID string, correct bool, points *int) error {
+	body := map[string]interface{}{"playerID": playerID, "correct": correct}
+	if points != nil {
+		body["points"] = *points
+	}
+	return c.hostAction(ctx, gameID, hostToken, "judge", body)
+}
+
+// Undo reverses the host's most recent score change, judgment, kick or
+// reset. it fails with CodeNothingToUndo when there's nothing left, and
+// CodeUndoStale when the game has moved on since the action.
+func (c *Client) Undo(ctx context.Context, gameID, hostToken string) (*UndoData, error) {
+	var out UndoData
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/undo", nil, hostToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Mark drops a named marker, e.g. "Q5 start", into the game's event log
+// with the server's time, for syncing video of the game against its
+// history and buzz log exports.
+func (c *Client) Mark(ctx context.Context, gameID, hostToken, name string) (*MarkerData, error) {
+	var out MarkerData
+	body := map[string]string{"name": name}
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/markers", nil, hostToken, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetScoring replaces a game's scoring rules, from the next verdict on.
+func (c *Client) SetScoring(ctx context.Context, gameID, hostToken string, rules ScoringRules) error {
+	return c.hostAction(ctx, gameID, hostToken, "settings", map[string]interface{}{"scoring": rules})
+}
+
+// Cue sends players a named cue, e.g. "correct", with optional parameters
+// for their devices.
+func (c *Client) Cue(ctx context.Context, gameID, hostToken, name string, payload interface{}) error {
+	body := map[string]interface{}{"name": name}
+	if payload != nil {
+		body["payload"] = payload
+	}
+	return c.hostAction(ctx, gameID, hostToken, "cue", body)
+}
+
+// OpenWagers starts a wager round. maxWager is what anyone can stake,
+// players ahead can stake up to their score.
+func (c *Client) OpenWagers(ctx context.Context, gameID, hostToken, category string, maxWager int) error {
+	body := map[string]interface{}{"category": category, "maxWager": maxWager}
+	return c.hostAction(ctx, gameID, hostToken, "wager/open", body)
+}
+
+// CloseWagers stops players wagering.
+func (c *Client) CloseWagers(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "wager/close", nil)
+}
+
+// RevealWager reveals a player's wager to everyone, judging their answer.
+// they win or lose what they staked.
+func (c *Client) RevealWager(ctx context.Context, gameID, hostToken, playerID string, correct bool) error {
+	body := map[string]interface{}{"playerID": playerID, "correct": correct}
+	return c.hostAction(ctx, gameID, hostToken, "wager/reveal", body)
+}
+
+// OpenAllPlay starts an all-play round on the current question, one every
+// player answers. points is what a correct answer wins, what the question
+// is worth when nil.
+func (c *Client) OpenAllPlay(ctx context.Context, gameID, hostToken string, points *int) error {
+	body := map[string]interface{}{}
+	if points != nil {
+		body["points"] = *points
+	}
+	return c.hostAction(ctx, gameID, hostToken, "allplay/open", body)
+}
+
+// CloseAllPlay stops players answering.
+func (c *Client) CloseAllPlay(ctx context.Context, gameID, hostToken string) error {
+	return c.hostAction(ctx, gameID, hostToken, "allplay/close", nil)
+}
+
+// RevealAllPlay reveals every answer to everyone at once, the players in
+// correct judged right and the rest wrong.
+func (c *Client) RevealAllPlay(ctx context.Context, gameID, hostToken string, correct []string) error {
+	body := map[string]interface{}{"correct": correct}
+	return c.hostAction(ctx, gameID, hostToken, "allplay/reveal", body)
+}
+
+// StartBoard sets up a category board from the game's questions. picker
+// gets the first pick, the host has it when empty.
+func (c *Client) StartBoard(ctx context.Context, gameID, hostToken, picker string) (*BoardData, error) {
+	var b BoardData
+	body := map[string]string{"picker": picker}
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/board", nil, hostToken, body, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// HostPick picks the next question off the board in place of whoever has
+// the pick.
+func (c *Client) HostPick(ctx context.Context, gameID, hostToken string, questionID int) error {
+	return c.hostAction(ctx, gameID, hostToken, "pick", map[string]int{"questionID": questionID})
+}
+
+// Device is a hardware buzzer paired with a player. Connected is true
+// while it's connected to the server.
+type Device struct {
+	DeviceID   string `json:"deviceID"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Connected  bool   `json:"connected"`
+}
+
+// Devices lists the hardware buzzers paired in a game.
+func (c *Client) Devices(ctx context.Context, gameID, hostToken string) ([]Device, error) {
+	var out struct {
+		Devices []Device `json:"devices"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/host/"+url.PathEscape(gameID)+"/devices", nil, hostToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Devices, nil
+}
+
+// PairDevice pairs a hardware buzzer with a player, so its buzzes count as
+// theirs.
+func (c *Client) PairDevice(ctx context.Context, gameID, hostToken, deviceID, playerID string) (*Device, error) {
+	var d Device
+	body := map[string]string{"deviceID": deviceID, "playerID": playerID}
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/devices", nil, hostToken, body, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// UnpairDevice forgets a hardware buzzer's pairing.
+func (c *Client) UnpairDevice(ctx context.Context, gameID, hostToken, deviceID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/host/"+url.PathEscape(gameID)+"/devices/"+url.PathEscape(deviceID), nil, hostToken, nil, nil)
+}
+
+// StartPractice turns on practice mode before a game starts. players'
+// buzzes are echoed back to them and the host without counting, until
+// EndPractice or the first round starts.
+func (c *Client) StartPractice(ctx context.Context, gameID, hostToken string) (*Practice, error) {
+	var p Practice
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/practice", nil, hostToken, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Practice returns the practice checklist.
+func (c *Client) Practice(ctx context.Context, gameID, hostToken string) (*Practice, error) {
+	var p Practice
+	if err := c.do(ctx, http.MethodGet, "/api/host/"+url.PathEscape(gameID)+"/practice", nil, hostToken, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// EndPractice turns off practice mode, returning the final checklist.
+func (c *Client) EndPractice(ctx context.Context, gameID, hostToken string) (*Practice, error) {
+	var p Practice
+	if err := c.do(ctx, http.MethodPost, "/api/host/"+url.PathEscape(gameID)+"/practice/end", nil, hostToken, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Listen opens a player's stream, connecting as the player token belongs
+// to. events arrive on the returned channel, which is closed once the
+// stream ends or ctx is done.
+func (c *Client) Listen(ctx context.Context, gameID, token string) (<-chan Event, error) {
+	q := url.Values{"token": {token}}
+	c.filter(q)
+	return c.stream(ctx, "/api/play/"+url.PathEscape(gameID), q, "")
+}
+
+// Resume opens a player's stream with a session token, from Join or the
+// latest greeting.
+func (c *Client) Resume(ctx context.Context, gameID, session string) (<-chan Event, error) {
+	q := url.Values{"session": {session}}
+	c.filter(q)
+	return c.stream(ctx, "/api/play/"+url.PathEscape(gameID), q, "")
+}
+
+// ListenHost opens a game's host stream, under c.ClientID if it's set.
+func (c *Client) ListenHost(ctx context.Context, gameID, hostToken string) (<-chan Event, error) {
+	q := url.Values{}
+	if c.ClientID != "" {
+		q.Set("clientID", c.ClientID)
+	}
+	c.filter(q)
+	return c.stream(ctx, "/api/host/"+url.PathEscape(gameID), q, hostToken)
+}
+
+// Dashboard opens one stream of the events of every game AccountKey's
+// account is running, each tagged with its GameID. it opens with
+// ActionDashboardGames, and games created after it opens are announced
+// with ActionDashboardGame.
+func (c *Client) Dashboard(ctx context.Context) (<-chan Event, error) {
+	q := url.Values{}
+	c.filter(q)
+	return c.stream(ctx, "/api/host/dashboard", q, "")
+}
+
+// filter asks for only c.Events, if it's set.
+func (c *Client) filter(q url.Values) {
+	if len(c.Events) > 0 {
+		q.Set("events", strings.Join(c.Events, ","))
+	}
+}
+
+// SetPlayerEvents changes which events a player's open stream is sent,
+// every event when events is empty.
+func (c *Client) SetPlayerEvents(ctx context.Context, gameID, playerID, token string, events []string) error {
+	body := map[string]interface{}{"playerID": playerID, "include": events}
+	return c.do(ctx, http.MethodPut, "/api/play/"+url.PathEscape(gameID)+"/events", nil, token, body, nil)
+}
+
+// SetHostEvents changes which events the host stream opened under clientID
+// is sent, every event when events is empty.
+func (c *Client) SetHostEvents(ctx context.Context, gameID, hostToken, clientID string, events []string) error {
+	body := map[string]interface{}{"clientID": clientID, "include": events}
+	return c.do(ctx, http.MethodPut, "/api/host/"+url.PathEscape(gameID)+"/events", nil, hostToken, body, nil)
+}
+
+// Poll long polls a player's events after since, waiting up to wait for
+// some to arrive, for networks that block streams. pass the returned seq as
+// since on the next poll.
+func (c *Client) Poll(ctx context.Context, gameID, token string, since int, wait time.Duration) (*PollResult, error) {
+	q := url.Values{"token": {token}, "since": {strconv.Itoa(since)}, "wait": {wait.String()}}
+	return c.poll(ctx, "/api/play/"+url.PathEscape(gameID)+"/poll", q, "")
+}
+
+// PollHost long polls a game's host events after since, like Poll.
+func (c *Client) PollHost(ctx context.Context, gameID, hostToken string, since int, wait time.Duration) (*PollResult, error) {
+	q := url.Values{"since": {strconv.Itoa(since)}, "wait": {wait.String()}}
+	return c.poll(ctx, "/api/host/"+url.PathEscape(gameID)+"/poll", q, hostToken)
+}
+
+func (c *Client) poll(ctx context.Context, path string, query url.Values, hostToken string) (*PollResult, error) {
+	query.Set("v", eventVersion)
+	var res PollResult
+	if err := c.do(ctx, http.MethodGet, path, query, hostToken, nil, &res); err != nil {
+		return nil, err
+	}
+	for i := range res.Events {
+		res.Events[i].ID = res.Events[i].Seq
+	}
+	return &res, nil
+}
+
+func (c *Client) stream(ctx context.Context, path string, query url.Values, hostToken string) (<-chan Event, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("v", eventVersion)
+	u := c.BaseURL + path + "?" + query.Encode()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	if hostToken != "" {
+		req.Header.Set("Authorization", "Bearer "+hostToken)
+	}
+	if c.AccountKey != "" {
+		req.Header.Set("X-Account-Key", c.AccountKey)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		readEvents(resp.Body, func(e Event) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return events, nil
+}
@@ -0,0 +1,672 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// event actions a client is likely to switch on. the greeting that opens a
+// player stream has no action.
+const (
+	ActionJoined         = "joined"
+	ActionPlayerLeft     = "player_left"
+	ActionPlayerRejoined = "player_rejoined"
+	ActionPlayerStalled  = "player_stalled"
+	ActionDisconnect     = "disconnect"
+	ActionBuzz           = "buzz"
+	ActionLock           = "lock"
+	ActionUnlock         = "unlock"
+	ActionReset          = "reset"
+	ActionQuestionReset  = "question_reset"
+	ActionScore          = "score"
+	ActionQuestion       = "question"
+	ActionAnswer         = "answer"
+	ActionVerdict        = "verdict"
+	ActionTimerStart     = "timer_start"
+	ActionTimerTick      = "timer_tick"
+	ActionTimerStop      = "timer_stop"
+	ActionTimerEnd       = "timer_end"
+	ActionTimeUp         = "time_up"
+	ActionSummary        = "summary"
+	ActionCue            = "cue"
+	ActionKicked         = "kicked"
+	ActionResync         = "resync"
+	ActionShutdown       = "server_shutdown"
+	ActionPing           = "ping"
+	ActionTransferred    = "host_transferred"
+	ActionPlayerWaiting  = "player_waiting"
+	ActionPlayerAdmitted = "player_admitted"
+	ActionWagerOpen      = "wager_open"
+	ActionWagerSubmitted = "wager_submitted"
+	ActionWagerReceived  = "wager_received"
+	ActionWagerClosed    = "wager_closed"
+	ActionWagerReveal    = "wager_reveal"
+	ActionBuzzLate       = "buzz_late"
+	ActionBuzzAlmost     = "buzz_almost"
+	ActionGamePaused     = "game_paused"
+	ActionGameResumed    = "game_resumed"
+	ActionGameStats      = "game_stats"
+	ActionResults        = "results"
+	ActionFarewell       = "farewell"
+	ActionRematch        = "rematch"
+	ActionHostMessage    = "host_message"
+	ActionBuzzerLocked   = "buzzer_locked"
+	ActionBuzzerUnlocked = "buzzer_unlocked"
+	ActionEarlyBuzz      = "early_buzz"
+	ActionDashboardGames = "dashboard_games"
+	ActionDashboardGame  = "dashboard_game"
+	ActionUndo           = "undo"
+	ActionReacted        = "reacted"
+	ActionClock          = "clock"
+	ActionStateChanged   = "state_changed"
+	// ActionMarker only reaches the host
+	ActionMarker = "marker"
+	// ActionBuzzTie only reaches the host
+	ActionBuzzTie = "buzz_tie"
+)
+
+// team captain actions, in games with captains. ActionTeamJoinRequested
+// only reaches the host and the team's captain, ActionTimeoutRequested only
+// the host.
+const (
+	ActionCaptainChanged     = "captain_changed"
+	ActionTeamJoinRequested  = "team_join_requested"
+	ActionTeamMemberAccepted = "team_member_accepted"
+	ActionTeamMemberRemoved  = "team_member_removed"
+	ActionTimeoutRequested   = "timeout_requested"
+)
+
+// all-play round actions. ActionAllPlayAnswer only reaches the host,
+// ActionAllPlayReceived only the player who answered.
+const (
+	ActionAllPlayOpen     = "allplay_open"
+	ActionAllPlayAnswer   = "allplay_answer"
+	ActionAllPlayReceived = "allplay_received"
+	ActionAllPlayClosed   = "allplay_closed"
+	ActionAllPlayReveal   = "allplay_reveal"
+)
+
+// practice mode actions. ActionPracticeBuzz reaches the player who buzzed
+// and the host.
+const (
+	ActionPracticeStart = "practice"
+	ActionPracticeBuzz  = "practice_buzz"
+	ActionPracticeEnd   = "practice_end"
+)
+
+// ActionBoard carries the whole category board whenever it changes, see
+// BoardData.
+const ActionBoard = "board"
+
+// SchemaVersion is the version of the event schema this package knows.
+// events from a newer server may have fields it can't decode.
+const SchemaVersion = 1
+
+// TypeHello is the Type of the greeting that opens a player stream.
+const TypeHello = "hello"
+
+// Event is one event off a stream. Data is left encoded, Decode it into
+// the type that goes with its Type.
+type Event struct {
+	// ID is the SSE event id, the same as Seq for sequenced events
+	ID int `json:"-"`
+	// V is the event's schema version
+	V int `json:"v"`
+	// Type names the event, the same as Action apart from the greeting
+	Type       string          `json:"type"`
+	GameID     string          `json:"gameID"`
+	PlayerID   string          `json:"playerID,omitempty"`
+	PlayerName string          `json:"playerName,omitempty"`
+	Action     string          `json:"action,omitempty"`
+	Seq        int             `json:"seq,omitempty"`
+	Txn        int             `json:"txn,omitempty"`
+	Critical   bool            `json:"critical,omitempty"`
+	Seat       int             `json:"seat,omitempty"`
+	Team       string          `json:"team,omitempty"`
+	Avatar     string          `json:"avatar,omitempty"`
+	Color      string          `json:"color,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	// Token and Session are only set on the greeting
+	Token   string `json:"token,omitempty"`
+	Session string `json:"session,omitempty"`
+	// State is the lifecycle state the event left the game in, one of
+	// the State constants
+	State string `json:"state,omitempty"`
+}
+
+// Decode unmarshals the event's data into v.
+func (e Event) Decode(v interface{}) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+// Buzz is a single buzz in a question's buzz order.
+type Buzz struct {
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Seat       int       `json:"seat,omitempty"`
+	Team       string    `json:"team,omitempty"`
+	Color      string    `json:"color,omitempty"`
+	QuestionID int       `json:"questionID,omitempty"`
+	Round      int       `json:"round,omitempty"`
+	Position   int       `json:"position"`
+	At         time.Time `json:"at"`
+	DeltaMs    float64   `json:"deltaMs"`
+	ReactionMs float64   `json:"reactionMs,omitempty"`
+	Late       bool      `json:"late,omitempty"`
+	// Tie numbers the tie the buzz was part of within its question, zero
+	// for none, see BuzzTieData
+	Tie int `json:"tie,omitempty"`
+}
+
+// BuzzData goes with ActionBuzz.
+type BuzzData struct {
+	Buzz  Buzz   `json:"buzz"`
+	Order []Buzz `json:"order"`
+}
+
+// BuzzAlmostData goes with ActionBuzzAlmost, the players who buzzed within
+// WindowMs of the winner of a first buzz wins question, closest first.
+type BuzzAlmostData struct {
+	QuestionID int     `json:"questionID"`
+	Winner     Buzz    `json:"winner"`
+	WindowMs   float64 `json:"windowMs"`
+	Buzzes     []Buzz  `json:"buzzes"`
+}
+
+// BuzzTieData goes with ActionBuzzTie, buzzes pressed too close together
+// to tell apart, in the order Tiebreak put them. the host can go with it or
+// re-run the buzz with a reset.
+type BuzzTieData struct {
+	QuestionID int    `json:"questionID"`
+	Tie        int    `json:"tie"`
+	Tiebreak   string `json:"tiebreak"`
+	Buzzes     []Buzz `json:"buzzes"`
+}
+
+// Standing is a player's place on the leaderboard.
+type Standing struct {
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Points     int    `json:"points"`
+}
+
+// ScoreEntry is one entry in a game's score ledger.
+type ScoreEntry struct {
+	ID         int       `json:"id"`
+	Kind       string    `json:"kind"`
+	PlayerID   string    `json:"playerID"`
+	Points     int       `json:"points"`
+	Reason     string    `json:"reason,omitempty"`
+	Corrects   int       `json:"corrects,omitempty"`
+	At         time.Time `json:"at"`
+	QuestionID int       `json:"questionID,omitempty"`
+	Round      int       `json:"round,omitempty"`
+}
+
+// ScoreData goes with ActionScore.
+type ScoreData struct {
+	Entries     []ScoreEntry `json:"entries"`
+	Leaderboard []Standing   `json:"leaderboard"`
+}
+
+// UndoData goes with ActionUndo, the host taking back an action. Undone.Action is
+// one of "score", "judgment", "kick" or "reset". Corrections are the
+// ledger entries putting scores back, and Buzzes the ones an undone reset
+// restored.
+type UndoData struct {
+	Undone struct {
+		Action     string    `json:"action"`
+		At         time.Time `json:"at"`
+		PlayerID   string    `json:"playerID"`
+		QuestionID int       `json:"questionID"`
+	} `json:"undone"`
+	PlayerName  string       `json:"playerName"`
+	Corrections []ScoreEntry `json:"corrections"`
+	Leaderboard []Standing   `json:"leaderboard"`
+	Buzzes      []Buzz       `json:"buzzes"`
+	Remaining   int          `json:"remaining"`
+}
+
+// ReactedData goes with ActionReacted, sent to the host when a player
+// reacts.
+type ReactedData struct {
+	PlayerName string `json:"playerName"`
+	Reaction   string `json:"reaction"`
+}
+
+// ClockData goes with ActionClock, the server's time sent every so often
+// on player and host streams. see SyncClock for a precise offset.
+type ClockData struct {
+	ServerTime time.Time `json:"serverTime"`
+	ServerMs   int64     `json:"serverMs"`
+}
+
+// a game's lifecycle states, see Event.State
+const (
+	StateLobby        = "lobby"
+	StateArmed        = "armed"
+	StateAnswering    = "answering"
+	StateJudging      = "judging"
+	StateIntermission = "intermission"
+	StateEnded        = "ended"
+)
+
+// StateChangedData goes with ActionStateChanged, the game moving from one
+// lifecycle state to another.
+type StateChangedData struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CaptainData goes with ActionCaptainChanged.
+type CaptainData struct {
+	Team       string `json:"team"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+}
+
+// TeamMemberData goes with ActionTeamJoinRequested, ActionTeamMemberAccepted
+// and ActionTeamMemberRemoved. PlayerID is the member, Captain the captain
+// who accepted or removed them.
+type TeamMemberData struct {
+	Team       string `json:"team"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Captain    string `json:"captain,omitempty"`
+}
+
+// TimeoutData goes with ActionTimeoutRequested. Count is how many timeouts
+// the team has asked for in the game.
+type TimeoutData struct {
+	Team       string `json:"team"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Count      int    `json:"count"`
+}
+
+// MarkerData goes with ActionMarker, a point in the game the host marked.
+// MonotonicMs is how long after the game started it was on the server's
+// monotonic clock, which doesn't jump when the wall clock is adjusted.
+type MarkerData struct {
+	Name        string    `json:"name"`
+	At          time.Time `json:"at"`
+	MonotonicMs float64   `json:"monotonicMs"`
+}
+
+// SummaryData goes with ActionSummary.
+type SummaryData struct {
+	StartedAt string   `json:"startedAt"`
+	EndedAt   string   `json:"endedAt"`
+	Players   []string `json:"players"`
+}
+
+// QuestionData goes with ActionQuestion.
+type QuestionData struct {
+	ID     int    `json:"id"`
+	Text   string `json:"text"`
+	Points int    `json:"points,omitempty"`
+	Round  int    `json:"round,omitempty"`
+	// Category is set for questions picked off a board
+	Category string `json:"category,omitempty"`
+}
+
+// CueData goes with ActionCue. At is when the host sent it, a cue that
+// arrives late can be skipped.
+type CueData struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+// TimerData goes with the timer actions. EndsAt is zero on stops and
+// ends.
+type TimerData struct {
+	TimerID     int       `json:"timerID"`
+	DurationMs  int64     `json:"durationMs,omitempty"`
+	RemainingMs int64     `json:"remainingMs,omitempty"`
+	EndsAt      time.Time `json:"endsAt,omitempty"`
+	AutoLock    bool      `json:"autoLock,omitempty"`
+}
+
+// PauseData goes with ActionGamePaused and ActionGameResumed. TimerMs is
+// what was left of the countdown the pause stopped, which starts again
+// with a fresh ActionTimerStart on resume.
+type PauseData struct {
+	PausedAt time.Time `json:"pausedAt"`
+	TimerMs  int64     `json:"timerMs,omitempty"`
+}
+
+// PollResult answers a long poll. Seq is the since to send with the next
+// one.
+type PollResult struct {
+	Events []Event `json:"events"`
+	Seq    int     `json:"seq"`
+}
+
+// PlayerStats sums up how a player did over a game. AverageReactionMs is
+// over the buzzes made while the buzzers were armed.
+type PlayerStats struct {
+	PlayerID          string  `json:"playerID"`
+	PlayerName        string  `json:"playerName"`
+	Buzzes            int     `json:"buzzes"`
+	Wins              int     `json:"wins"`
+	AverageReactionMs float64 `json:"averageReactionMs"`
+	Correct           int     `json:"correct"`
+	Incorrect         int     `json:"incorrect"`
+	Points            int     `json:"points"`
+}
+
+// GameStatsData goes with ActionGameStats, sent as the game ends with
+// every player's stats, top scorer first.
+type GameStatsData struct {
+	Players []PlayerStats `json:"players"`
+}
+
+// QuestionResetData goes with ActionQuestionReset, sent when the host
+// resets the question: the buzzes are cleared and the buzzers unlocked.
+type QuestionResetData struct {
+	QuestionID int `json:"questionID"`
+	Round      int `json:"round,omitempty"`
+}
+
+// ResultsData goes with ActionResults, sent to everyone when a game ends
+// with its final standings. the game is torn down at CleanupAt.
+type ResultsData struct {
+	Reason    string        `json:"reason"`
+	StartedAt time.Time     `json:"startedAt"`
+	EndedAt   time.Time     `json:"endedAt"`
+	Standings []Standing    `json:"standings"`
+	Stats     []PlayerStats `json:"stats"`
+	Winners   []string      `json:"winners"`
+	CleanupAt time.Time     `json:"cleanupAt"`
+}
+
+// FarewellData goes with ActionFarewell, sent to players when a game ends.
+type FarewellData struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// RematchData goes with ActionRematch, sent when the host starts a rematch
+// under GameCode. players are each sent the PlayerID and Token to rejoin
+// with, the host the Players brought along.
+type RematchData struct {
+	GameCode string          `json:"gameCode"`
+	SameCode bool            `json:"sameCode"`
+	PlayerID string          `json:"playerID,omitempty"`
+	Token    string          `json:"token,omitempty"`
+	Players  []RematchPlayer `json:"players,omitempty"`
+}
+
+// RematchPlayer is a player brought along to a rematch.
+type RematchPlayer struct {
+	PlayerID string `json:"playerID"`
+	Name     string `json:"name"`
+	Team     string `json:"team,omitempty"`
+}
+
+// HostMessageData goes with ActionHostMessage, a message from the host.
+// Direct is true when it was sent to just this player.
+type HostMessageData struct {
+	Text   string          `json:"text"`
+	Data   json.RawMessage `json:"data"`
+	Direct bool            `json:"direct"`
+}
+
+// EarlyBuzzData goes with ActionEarlyBuzz, sent to the host when a player
+// buzzes in an ArmedBuzzing game before the buzzers are armed. Count is
+// their early buzzes this game, Penalty what the last one cost them, if
+// anything.
+type EarlyBuzzData struct {
+	QuestionID int         `json:"questionID"`
+	Count      int         `json:"count"`
+	Penalty    *ScoreEntry `json:"penalty"`
+}
+
+// DashboardGamesData goes with ActionDashboardGames, the games a dashboard
+// stream is watching when it opens.
+type DashboardGamesData struct {
+	Games []string `json:"games"`
+}
+
+// BuzzerLockData goes with ActionBuzzerLocked and ActionBuzzerUnlocked,
+// naming the one player or team whose buzzers were locked or unlocked.
+type BuzzerLockData struct {
+	PlayerID string `json:"playerID"`
+	Team     string `json:"team"`
+}
+
+// StalledData goes with ActionPlayerStalled, sent to the host when a
+// player's stream is cut off for not reading what it's sent.
+type StalledData struct {
+	Timeouts  int   `json:"timeouts"`
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+// PracticePlayer is a player's line on the practice checklist.
+type PracticePlayer struct {
+	PlayerID   string     `json:"playerID"`
+	PlayerName string     `json:"playerName"`
+	Team       string     `json:"team,omitempty"`
+	Connected  bool       `json:"connected"`
+	Tested     bool       `json:"tested"`
+	Buzzes     int        `json:"buzzes"`
+	LastBuzzAt *time.Time `json:"lastBuzzAt,omitempty"`
+}
+
+// Practice is the checklist of who has tested their device in practice
+// mode. it goes with ActionPracticeStart and ActionPracticeEnd.
+type Practice struct {
+	Players []PracticePlayer `json:"players"`
+	Tested  int              `json:"tested"`
+	// Complete is true once every connected player has buzzed
+	Complete bool `json:"complete"`
+}
+
+// PracticeBuzzData goes with ActionPracticeBuzz. only the host's copy has
+// the Checklist.
+type PracticeBuzzData struct {
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Buzzes     int       `json:"buzzes"`
+	At         time.Time `json:"at"`
+	Checklist  *Practice `json:"checklist,omitempty"`
+}
+
+// BoardCell is a question's place on a board.
+type BoardCell struct {
+	QuestionID int  `json:"questionID"`
+	Points     int  `json:"points"`
+	Used       bool `json:"used"`
+}
+
+// BoardCategory is a column of a board, its cells by points.
+type BoardCategory struct {
+	Name  string      `json:"name"`
+	Cells []BoardCell `json:"cells"`
+}
+
+// BoardData goes with ActionBoard. Picker is the player who picks the next
+// question with Client.Pick, empty while it's up to the host, and Cell the
+// question picked last.
+type BoardData struct {
+	Categories []BoardCategory `json:"categories"`
+	Picker     string          `json:"picker,omitempty"`
+	PickerName string          `json:"pickerName,omitempty"`
+	Cell       int             `json:"cell,omitempty"`
+	Remaining  int             `json:"remaining"`
+}
+
+// PresenceData goes with ActionPlayerLeft and ActionPlayerRejoined.
+type PresenceData struct {
+	PlayerID    string     `json:"playerID"`
+	Name        string     `json:"name"`
+	Seat        int        `json:"seat,omitempty"`
+	Team        string     `json:"team,omitempty"`
+	Avatar      string     `json:"avatar,omitempty"`
+	Color       string     `json:"color,omitempty"`
+	Connected   bool       `json:"connected"`
+	ConnectedAt *time.Time `json:"connectedAt,omitempty"`
+	LeftAt      *time.Time `json:"leftAt,omitempty"`
+}
+
+// WaitingData goes with ActionPlayerWaiting and ActionPlayerAdmitted.
+type WaitingData struct {
+	PlayerID string `json:"playerID"`
+	Name     string `json:"name"`
+	Team     string `json:"team,omitempty"`
+	Position int    `json:"position,omitempty"`
+}
+
+// WagerOpenData goes with ActionWagerOpen. anyone can stake up to
+// MaxWager, players ahead up to their score.
+type WagerOpenData struct {
+	Category string `json:"category,omitempty"`
+	MaxWager int    `json:"maxWager,omitempty"`
+}
+
+// Wager is a player's wager and answer in a wager round. it goes with
+// ActionWagerSubmitted, to the host, and ActionWagerReceived, only to the
+// player who made it.
+type Wager struct {
+	PlayerID   string     `json:"playerID"`
+	PlayerName string     `json:"playerName"`
+	Wager      int        `json:"wager"`
+	Answer     string     `json:"answer,omitempty"`
+	At         time.Time  `json:"at"`
+	Correct    *bool      `json:"correct,omitempty"`
+	RevealedAt *time.Time `json:"revealedAt,omitempty"`
+}
+
+// WagerClosedData goes with ActionWagerClosed, listing who wagered.
+type WagerClosedData struct {
+	Wagered []string `json:"wagered"`
+}
+
+// WagerRevealData goes with ActionWagerReveal. Score settles the wager,
+// it's nil for a wager of nothing.
+type WagerRevealData struct {
+	Wager Wager       `json:"wager"`
+	Score *ScoreEntry `json:"score,omitempty"`
+}
+
+// AllPlayOpenData goes with ActionAllPlayOpen. every player can answer the
+// question once, a correct answer wins Points.
+type AllPlayOpenData struct {
+	QuestionID int `json:"questionID"`
+	Points     int `json:"points"`
+}
+
+// AllPlayAnswer is a player's answer in an all-play round. it goes with
+// ActionAllPlayAnswer, to the host, and ActionAllPlayReceived, only to the
+// player who gave it.
+type AllPlayAnswer struct {
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Answer     string    `json:"answer"`
+	At         time.Time `json:"at"`
+	Correct    *bool     `json:"correct,omitempty"`
+}
+
+// AllPlayClosedData goes with ActionAllPlayClosed, listing who answered.
+type AllPlayClosedData struct {
+	Answered []string `json:"answered"`
+}
+
+// AllPlayRevealData goes with ActionAllPlayReveal, every answer with the
+// host's verdict and the scores of those who got it right.
+type AllPlayRevealData struct {
+	Entries []AllPlayAnswer `json:"entries"`
+	Scores  []ScoreEntry    `json:"scores"`
+}
+
+// ResyncData goes with ActionResync, sent when a stream has fallen too far
+// behind to be caught up. fetch the snapshot with Client.State, apply only
+// the events after its Seq and Reset any SeqTracker to it.
+type ResyncData struct {
+	State string `json:"state"`
+}
+
+// SeqTracker drops events a stream delivers twice or out of order, as can
+// happen around a reconnect, going by the seq every sequenced event
+// carries. seqs count every event in a game, host and player streams alike,
+// so a stream skipping some is normal. the server sends ActionResync when
+// a stream has really missed events.
+type SeqTracker struct {
+	last int
+}
+
+// Reset starts tracking from seq, e.g. a State's after a resync.
+func (t *SeqTracker) Reset(seq int) {
+	t.last = seq
+}
+
+// Observe checks an event off, reporting whether it's new. events without
+// a seq, such as pings, always are.
+func (t *SeqTracker) Observe(e Event) bool {
+	if e.Seq == 0 {
+		return true
+	}
+	if e.Seq <= t.last {
+		return false
+	}
+	t.last = e.Seq
+	return true
+}
+
+// PingData goes with ActionPing. answer it with Client.Pong.
+type PingData struct {
+	PingID int64     `json:"pingID"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+// readEvents parses an SSE stream, calling emit for every event until the
+// stream ends or emit returns false. comments such as keepalives are
+// skipped.
+func readEvents(r io.Reader, emit func(Event) bool) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var id int
+	var eventType string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(data.String()), &e); err == nil {
+				e.ID = id
+				if eventType != "" {
+					e.Type = eventType
+				}
+				if !emit(e) {
+					return
+				}
+			}
+			id = 0
+			eventType = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "id:"):
+			id, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "id:")))
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
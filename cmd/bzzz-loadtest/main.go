@@ -0,0 +1,323 @@
+// bzzz-loadtest drives a bzzz server with simulated games: it creates
+// -games games with -players players each, has every player listen on
+// their own stream and buzz at -rate buzzes a second per game, then reports
+// how long buzzes took to reach every stream.
+//
+// with -check-leaks and the server's -admin-token it also ends every game
+// once it's done and checks the server's goroutines, stream queues and
+// hubs go back to what they were before, exiting 1 if they don't, so a
+// soak run can fail a CI job. ended games hold on to their hubs until
+// they're torn down, so give the server a short END_LINGER for it.
+//
+// the server holds each client IP to a buzz and create rate, so run it with
+// BUZZ_RATE=0 and CREATE_GAME_RATE=0 when testing from a single machine.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bzzz/client"
+)
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "server to test")
+	apiKey := flag.String("api-key", "", "API key to create games with")
+	games := flag.Int("games", 10, "number of concurrent games")
+	players := flag.Int("players", 8, "players in each game")
+	rate := flag.Float64("rate", 2, "buzzes a second in each game")
+	duration := flag.Duration("duration", 30*time.Second, "how long to buzz for")
+	insecure := flag.Bool("insecure", false, "skip TLS certificate checks")
+	checkLeaks := flag.Bool("check-leaks", false, "end the games afterwards and check the server let go of their streams")
+	adminToken := flag.String("admin-token", "", "the server's admin token, for -check-leaks")
+	flag.Parse()
+
+	if *games <= 0 || *players <= 0 || *rate <= 0 {
+		log.Fatal("-games, -players and -rate must be positive")
+	}
+	if *checkLeaks && *adminToken == "" {
+		log.Fatal("-check-leaks needs -admin-token")
+	}
+
+	// every player holds a stream open, and buzzes go out alongside them
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = *games * (*players + 2)
+	if *insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	c := client.New(*baseURL)
+	c.APIKey = *apiKey
+	c.HTTPClient = &http.Client{Transport: transport}
+
+	var before serverStats
+	if *checkLeaks {
+		var err error
+		if before, err = fetchStats(c, *adminToken); err != nil {
+			log.Fatalf("failed to get server stats: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		log.Println("interrupted, reporting so far")
+		cancel()
+	}()
+
+	st := &stats{}
+	var sims []*simulation
+	for i := 0; i < *games; i++ {
+		sim, err := startSimulation(ctx, c, *players, st)
+		if err != nil {
+			log.Fatalf("failed to set up game %d: %v", i+1, err)
+		}
+		sims = append(sims, sim)
+	}
+	log.Printf("%d games with %d players each are listening, buzzing for %s", *games, *players, *duration)
+
+	runCtx, stop := context.WithTimeout(ctx, *duration)
+	defer stop()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, sim := range sims {
+		wg.Add(1)
+		go func(sim *simulation) {
+			defer wg.Done()
+			sim.buzz(runCtx, *rate)
+		}(sim)
+	}
+	wg.Wait()
+
+	// give the last buzzes a moment to arrive before hanging up
+	time.Sleep(time.Second)
+	if *checkLeaks {
+		for _, sim := range sims {
+			if _, err := c.EndGame(context.Background(), sim.game.Code, sim.game.HostToken); err != nil {
+				log.Printf("failed to end game %s: %v", sim.game.Code, err)
+			}
+		}
+	}
+	cancel()
+
+	st.report(os.Stdout, time.Since(start))
+
+	if *checkLeaks {
+		transport.CloseIdleConnections()
+		if !leaksSettled(c, *adminToken, before, os.Stdout) {
+			os.Exit(1)
+		}
+	}
+}
+
+// serverStats are the parts of the server's admin stats a leak shows up
+// in.
+type serverStats struct {
+	Goroutines int   `json:"goroutines"`
+	Queues     int64 `json:"queues"`
+	Hubs       int   `json:"hubs"`
+}
+
+// fetchStats gets the server's admin stats.
+func fetchStats(c *client.Client, adminToken string) (serverStats, error) {
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/api/admin/stats", nil)
+	if err != nil {
+		return serverStats{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return serverStats{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return serverStats{}, fmt.Errorf("server answered %s", resp.Status)
+	}
+
+	var st serverStats
+	err = json.NewDecoder(resp.Body).Decode(&st)
+	return st, err
+}
+
+// leakSettle is how long the server gets to let go of the games' streams
+// before what's left counts as leaked, and goroutineSlack how many more
+// goroutines than before it may still have, for connections net/http is
+// still winding down.
+const (
+	leakSettle     = 10 * time.Second
+	goroutineSlack = 5
+)
+
+// leaksSettled waits for the server's stats to go back to before,
+// reporting whether they did.
+func leaksSettled(c *client.Client, adminToken string, before serverStats, w io.Writer) bool {
+	deadline := time.Now().Add(leakSettle)
+	for {
+		after, err := fetchStats(c, adminToken)
+		if err != nil {
+			log.Printf("failed to get server stats: %v", err)
+			return false
+		}
+		settled := after.Queues <= before.Queues && after.Hubs <= before.Hubs &&
+			after.Goroutines <= before.Goroutines+goroutineSlack
+		if settled || time.Now().After(deadline) {
+			fmt.Fprintf(w, "goroutines:   %d before, %d after\n", before.Goroutines, after.Goroutines)
+			fmt.Fprintf(w, "queues:       %d before, %d after\n", before.Queues, after.Queues)
+			fmt.Fprintf(w, "hubs:         %d before, %d after\n", before.Hubs, after.Hubs)
+			if !settled {
+				fmt.Fprintln(w, "leaked: the server held on to more than it had before")
+			}
+			return settled
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// simulation is one game and its players.
+type simulation struct {
+	c       *client.Client
+	game    *client.Game
+	players []*client.Player
+	stats   *stats
+
+	mu sync.Mutex
+	// sentAt is when each player's latest buzz was sent, by playerID
+	sentAt map[string]time.Time
+}
+
+// startSimulation creates a game, joins its players and opens every
+// player's stream.
+func startSimulation(ctx context.Context, c *client.Client, players int, st *stats) (*simulation, error) {
+	g, err := c.CreateGame(ctx, client.GameOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create game: %v", err)
+	}
+	sim := &simulation{c: c, game: g, stats: st, sentAt: map[string]time.Time{}}
+
+	for i := 0; i < players; i++ {
+		p, err := c.Join(ctx, g.Code, fmt.Sprintf("bot %d", i+1), "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to join game %s: %v", g.Code, err)
+		}
+		events, err := c.Listen(ctx, g.Code, p.Token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open a stream on game %s: %v", g.Code, err)
+		}
+		sim.players = append(sim.players, p)
+		go sim.receive(events)
+	}
+	return sim, nil
+}
+
+// receive times every buzz that arrives on a stream against when it was
+// sent.
+func (sim *simulation) receive(events <-chan client.Event) {
+	for e := range events {
+		if e.Type != client.ActionBuzz {
+			continue
+		}
+		var data client.BuzzData
+		if err := e.Decode(&data); err != nil {
+			atomic.AddInt64(&sim.stats.errors, 1)
+			continue
+		}
+
+		sim.mu.Lock()
+		sent, ok := sim.sentAt[data.Buzz.PlayerID]
+		sim.mu.Unlock()
+		if ok {
+			sim.stats.delivered(time.Since(sent))
+		}
+	}
+}
+
+// buzz has the game's players buzz in a random order at rate buzzes a
+// second, resetting once they all have, until ctx is done.
+func (sim *simulation) buzz(ctx context.Context, rate float64) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+
+	var order []int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if len(order) == 0 {
+			if err := sim.c.Reset(ctx, sim.game.Code, sim.game.HostToken); err != nil && ctx.Err() == nil {
+				atomic.AddInt64(&sim.stats.errors, 1)
+				log.Printf("failed to reset game %s: %v", sim.game.Code, err)
+			}
+			order = rand.Perm(len(sim.players))
+			continue
+		}
+
+		p := sim.players[order[0]]
+		order = order[1:]
+
+		sim.mu.Lock()
+		sim.sentAt[p.PlayerID] = time.Now()
+		sim.mu.Unlock()
+
+		atomic.AddInt64(&sim.stats.sent, 1)
+		if err := sim.c.Buzz(ctx, sim.game.Code, p.PlayerID, p.Token, ""); err != nil && ctx.Err() == nil {
+			atomic.AddInt64(&sim.stats.errors, 1)
+			log.Printf("failed to buzz in game %s: %v", sim.game.Code, err)
+		}
+	}
+}
+
+// stats collects the latency of every buzz delivered to a stream.
+type stats struct {
+	sent   int64
+	errors int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (st *stats) delivered(d time.Duration) {
+	st.mu.Lock()
+	st.latencies = append(st.latencies, d)
+	st.mu.Unlock()
+}
+
+func (st *stats) report(w io.Writer, elapsed time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sent := atomic.LoadInt64(&st.sent)
+	fmt.Fprintf(w, "buzzes sent:  %d (%.1f/s)\n", sent, float64(sent)/elapsed.Seconds())
+	fmt.Fprintf(w, "deliveries:   %d\n", len(st.latencies))
+	fmt.Fprintf(w, "errors:       %d\n", atomic.LoadInt64(&st.errors))
+	if len(st.latencies) == 0 {
+		return
+	}
+
+	sort.Slice(st.latencies, func(a, b int) bool { return st.latencies[a] < st.latencies[b] })
+	percentile := func(p float64) time.Duration {
+		return st.latencies[int(p*float64(len(st.latencies)-1))]
+	}
+	fmt.Fprintf(w, "latency p50:  %s\n", percentile(0.50))
+	fmt.Fprintf(w, "latency p90:  %s\n", percentile(0.90))
+	fmt.Fprintf(w, "latency p99:  %s\n", percentile(0.99))
+	fmt.Fprintf(w, "latency max:  %s\n", st.latencies[len(st.latencies)-1])
+}
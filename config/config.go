@@ -0,0 +1,469 @@
+// Package config loads the server's settings. every setting has a default
+// and can be overridden, in increasing order of precedence, by a YAML or
+// JSON config file, an environment variable and a command line flag.
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is every setting the server takes. each field is tagged with its
+// key in the config file, its environment variable and its flag.
+type Config struct {
+	// Mode "dev" serves the self-signed local.crt when no cert is given
+	Mode string `yaml:"mode" env:"MODE" flag:"mode" usage:"run mode, \"dev\" uses the self-signed local cert"`
+
+	// Addr, CertFile and KeyFile make up the default listener, used when
+	// there's no ListenersConfig and no systemd sockets
+	Addr     string `yaml:"addr" env:"ADDR" flag:"addr" usage:"address to listen on"`
+	CertFile string `yaml:"cert_file" env:"CERT_FILE" flag:"cert-file" usage:"TLS certificate, defaults to fullchain.pem (local.crt in dev mode)"`
+	KeyFile  string `yaml:"key_file" env:"KEY_FILE" flag:"key-file" usage:"TLS key, defaults to privkey.pem (local.key in dev mode)"`
+
+	// TLS picks how the default listener gets its certificate, "file" from
+	// CertFile and KeyFile, "autocert" from Let's Encrypt for
+	// AutocertDomains, or "off" to serve plain HTTP behind a proxy.
+	// HTTPAddr adds a plain HTTP listener next to it, which also answers
+	// ACME challenges in autocert mode. PrivateAddr adds a plain HTTP one
+	// that's the only one serving /metrics and the admin API, for keeping
+	// them on an internal network
+	TLS              string   `yaml:"tls" env:"TLS" flag:"tls" usage:"TLS for the default listener, file, autocert or off"`
+	HTTPAddr         string   `yaml:"http_addr" env:"HTTP_ADDR" flag:"http-addr" usage:"address of an extra plain HTTP listener, none when empty"`
+	PrivateAddr      string   `yaml:"private_addr" env:"PRIVATE_ADDR" flag:"private-addr" usage:"address of a plain HTTP listener for /metrics and the admin API, which then leave the others"`
+	AutocertDomains  []string `yaml:"autocert_domains" env:"AUTOCERT_DOMAINS" flag:"autocert-domains" usage:"comma separated domains to get Let's Encrypt certificates for"`
+	AutocertEmail    string   `yaml:"autocert_email" env:"AUTOCERT_EMAIL" flag:"autocert-email" usage:"contact email for the Let's Encrypt account"`
+	AutocertCacheDir string   `yaml:"autocert_cache_dir" env:"AUTOCERT_CACHE_DIR" flag:"autocert-cache-dir" usage:"directory Let's Encrypt certificates are kept in"`
+
+	// TLSMinVersion, TLSCipherSuites and TLSClientCA apply to every TLS
+	// listener. with a TLSClientCA connections need a client certificate
+	// it signed, so only a trusted frontend can reach the API directly.
+	// certificate files and the client CA are read again on SIGHUP
+	TLSMinVersion   string   `yaml:"tls_min_version" env:"TLS_MIN_VERSION" flag:"tls-min-version" usage:"oldest TLS version accepted, 1.0, 1.1, 1.2 or 1.3"`
+	TLSCipherSuites []string `yaml:"tls_cipher_suites" env:"TLS_CIPHER_SUITES" flag:"tls-cipher-suites" usage:"comma separated cipher suites allowed up to TLS 1.2, Go's defaults when empty"`
+	TLSClientCA     string   `yaml:"tls_client_ca" env:"TLS_CLIENT_CA" flag:"tls-client-ca" usage:"PEM file of the CAs client certificates have to be signed by, turning on mutual TLS"`
+
+	ListenersConfig string `yaml:"listeners_config" env:"LISTENERS_CONFIG" flag:"listeners-config" usage:"JSON file describing every listener"`
+	TenantsConfig   string `yaml:"tenants_config" env:"TENANTS_CONFIG" flag:"tenants-config" usage:"JSON file of tenants, API keys and quotas"`
+	LTIConfig       string `yaml:"lti_config" env:"LTI_CONFIG" flag:"lti-config" usage:"JSON file of LTI platforms"`
+
+	AdminToken string `yaml:"admin_token" env:"ADMIN_TOKEN" flag:"admin-token" usage:"bearer token for the admin API, which is off without one"`
+
+	// SessionSecret signs player session tokens, every replica needs the
+	// same one
+	SessionSecret string        `yaml:"session_secret" env:"SESSION_SECRET" flag:"session-secret" usage:"key player session tokens are signed with, random per process when empty"`
+	SessionTTL    time.Duration `yaml:"session_ttl" env:"SESSION_TTL" flag:"session-ttl" usage:"how long a player session token can resume a player"`
+
+	// Auth picks how tenant API callers authenticate, the rest of the auth
+	// settings only apply to their own kind
+	Auth            string   `yaml:"auth" env:"AUTH" flag:"auth" usage:"how tenant API callers authenticate, apikey, token or jwt"`
+	AuthTokens      []string `yaml:"auth_tokens" env:"AUTH_TOKENS" flag:"auth-tokens" usage:"comma separated bearer tokens for token auth, each optionally prefixed with \"tenant:\""`
+	OIDCIssuer      string   `yaml:"oidc_issuer" env:"OIDC_ISSUER" flag:"oidc-issuer" usage:"issuer JWTs must come from for jwt auth"`
+	OIDCAudience    string   `yaml:"oidc_audience" env:"OIDC_AUDIENCE" flag:"oidc-audience" usage:"audience JWTs must be for, required for jwt auth"`
+	OIDCJWKSURL     string   `yaml:"oidc_jwks_url" env:"OIDC_JWKS_URL" flag:"oidc-jwks-url" usage:"JWKS for verifying JWTs, discovered from the issuer when empty"`
+	OIDCTenantClaim string   `yaml:"oidc_tenant_claim" env:"OIDC_TENANT_CLAIM" flag:"oidc-tenant-claim" usage:"JWT claim naming the caller's tenant"`
+
+	// CreateGameRate and BuzzRate are also each burst, e.g. 10 games at
+	// once then one every 6s
+	CreateGameRate    int  `yaml:"create_game_rate" env:"CREATE_GAME_RATE" flag:"create-game-rate" usage:"games a minute a single IP can create, 0 for no limit"`
+	BuzzRate          int  `yaml:"buzz_rate" env:"BUZZ_RATE" flag:"buzz-rate" usage:"buzzes a second a single player can send, 0 for no limit"`
+	TrustForwardedFor bool `yaml:"trust_forwarded_for" env:"TRUST_FORWARDED_FOR" flag:"trust-forwarded-for" usage:"take client IPs from the last X-Forwarded-For entry, only safe behind a single proxy that appends to it"`
+
+	Moderation string `yaml:"moderation" env:"MODERATION" flag:"moderation" usage:"how names are moderated in games without a tenant, wordlist or none"`
+
+	// AnonymousGames lets games be created without a host account key,
+	// for quick play. turned off, hosts have to register first
+	AnonymousGames bool `yaml:"anonymous_games" env:"ANONYMOUS_GAMES" flag:"anonymous-games" usage:"let games be created without a host account key"`
+
+	GameWebhooks bool `yaml:"game_webhooks" env:"GAME_WEBHOOKS" flag:"game-webhooks" usage:"let hosts give webhook URLs for their games, only where they can be trusted not to point them at internal services"`
+
+	CORSOrigins []string      `yaml:"cors_origins" env:"CORS_ORIGINS" flag:"cors-origins" usage:"comma separated origins allowed to call the API, * for any"`
+	CORSMethods []string      `yaml:"cors_methods" env:"CORS_METHODS" flag:"cors-methods" usage:"comma separated methods pages on those origins can use"`
+	CORSHeaders []string      `yaml:"cors_headers" env:"CORS_HEADERS" flag:"cors-headers" usage:"comma separated request headers pages on those origins can send"`
+	CORSMaxAge  time.Duration `yaml:"cors_max_age" env:"CORS_MAX_AGE" flag:"cors-max-age" usage:"how long browsers can cache a preflight, 0 to leave it to them"`
+	JoinURL     string        `yaml:"join_url" env:"JOIN_URL" flag:"join-url" usage:"player join page, {code} is replaced with the game code and {locale} with the player's language"`
+	// ShortJoinURL is for deployments with a short domain redirecting to
+	// /j/{code}, which QR codes of the join page hold instead
+	ShortJoinURL string `yaml:"short_join_url" env:"SHORT_JOIN_URL" flag:"short-join-url" usage:"short link to the join page, {code} is replaced with the game code, /j/{code} on this server when empty"`
+
+	Store            string `yaml:"store" env:"STORE" flag:"store" usage:"where games are kept, memory, redis, sqlite or postgres"`
+	Broker           string `yaml:"broker" env:"BROKER" flag:"broker" usage:"how events reach other replicas, memory or redis"`
+	RedisURL         string `yaml:"redis_url" env:"REDIS_URL" flag:"redis-url" usage:"redis server for the redis store and broker"`
+	SQLitePath       string `yaml:"sqlite_path" env:"SQLITE_PATH" flag:"sqlite-path" usage:"database file for the sqlite store, created if it doesn't exist"`
+	PostgresDSN      string `yaml:"postgres_dsn" env:"POSTGRES_DSN" flag:"postgres-dsn" usage:"database for the postgres store, e.g. postgres://bzzz@localhost/bzzz?sslmode=disable"`
+	PostgresMaxConns int    `yaml:"postgres_max_conns" env:"POSTGRES_MAX_CONNS" flag:"postgres-max-conns" usage:"most connections the postgres store keeps open"`
+
+	GameIDStrategy   string `yaml:"game_id_strategy" env:"GAME_ID_STRATEGY" flag:"game-id-strategy" usage:"game ID strategy, numeric, alphanumeric, words, ulid or uuid"`
+	PlayerIDStrategy string `yaml:"player_id_strategy" env:"PLAYER_ID_STRATEGY" flag:"player-id-strategy" usage:"player ID strategy, numeric, alphanumeric, words, ulid or uuid"`
+	GameCodeMin      int    `yaml:"game_code_min" env:"GAME_CODE_MIN" flag:"game-code-min" usage:"smallest numeric game code"`
+	GameCodeMax      int    `yaml:"game_code_max" env:"GAME_CODE_MAX" flag:"game-code-max" usage:"largest numeric game code"`
+	GameCodeLength   int    `yaml:"game_code_length" env:"GAME_CODE_LENGTH" flag:"game-code-length" usage:"length of alphanumeric game codes"`
+
+	SSEProxyHints bool `yaml:"sse_proxy_hints" env:"SSE_PROXY_HINTS" flag:"sse-proxy-hints" usage:"tell proxies like nginx not to buffer event streams"`
+	SSEPadding    int  `yaml:"sse_padding" env:"SSE_PADDING" flag:"sse-padding" usage:"bytes of padding at the start of event streams"`
+	EventVersion  int  `yaml:"event_version" env:"EVENT_VERSION" flag:"event-version" usage:"event schema version streams get when the client doesn't ask for one, 1 or 2"`
+	WSCompression bool `yaml:"ws_compression" env:"WS_COMPRESSION" flag:"ws-compression" usage:"offer permessage-deflate on WebSocket streams"`
+	WebApp        bool `yaml:"web_app" env:"WEB_APP" flag:"web-app" usage:"serve the built-in host console and buzzer page at /app"`
+	Chaos         bool `yaml:"chaos" env:"CHAOS" flag:"chaos" usage:"dev only, let the admin API inject latency, dropped events and reconnects into streams"`
+
+	Compression     bool `yaml:"compression" env:"COMPRESSION" flag:"compression" usage:"gzip JSON responses and event streams for clients that accept it"`
+	CompressMinSize int  `yaml:"compress_min_size" env:"COMPRESS_MIN_SIZE" flag:"compress-min-size" usage:"smallest response in bytes worth gzipping, event streams are gzipped whatever their size"`
+	MaxRequestBody  int  `yaml:"max_request_body" env:"MAX_REQUEST_BODY" flag:"max-request-body" usage:"largest request body in bytes, question uploads and templates excepted"`
+
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env:"HEARTBEAT_INTERVAL" flag:"heartbeat-interval" usage:"how often idle streams get a keepalive comment, 0 for never"`
+	StreamBatchWindow time.Duration `yaml:"stream_batch_window" env:"STREAM_BATCH_WINDOW" flag:"stream-batch-window" usage:"how long event streams hold events back to send them together, 0 to send each straight away"`
+	RecentEventsTTL   time.Duration `yaml:"recent_events_ttl" env:"RECENT_EVENTS_TTL" flag:"recent-events-ttl" usage:"how far back events are replayed to players joining a game, 0 for none"`
+	QualityInterval   time.Duration `yaml:"quality_interval" env:"QUALITY_INTERVAL" flag:"quality-interval" usage:"how often players and hosts get connection quality reports, 0 for never"`
+	ClockInterval     time.Duration `yaml:"clock_interval" env:"CLOCK_INTERVAL" flag:"clock-interval" usage:"how often player and host streams are sent the server's time, 0 for never"`
+	HostLostAfter     time.Duration `yaml:"host_lost_after" env:"HOST_LOST_AFTER" flag:"host-lost-after" usage:"how long a host can be gone before the game is orphaned"`
+	OrphanGrace       time.Duration `yaml:"orphan_grace" env:"ORPHAN_GRACE" flag:"orphan-grace" usage:"how long a paused orphaned game waits for its host"`
+	EndLinger         time.Duration `yaml:"end_linger" env:"END_LINGER" flag:"end-linger" usage:"how long an ended game's results stay up before it's torn down"`
+	MaxGameDuration   time.Duration `yaml:"max_game_duration" env:"MAX_GAME_DURATION" flag:"max-game-duration" usage:"default limit on how long a game runs, 0 for none"`
+	BuzzCooldown      time.Duration `yaml:"buzz_cooldown" env:"BUZZ_COOLDOWN" flag:"buzz-cooldown" usage:"default time a player has to wait between buzzes, hosts can change it per game"`
+	AlmostWindow      time.Duration `yaml:"almost_window" env:"ALMOST_WINDOW" flag:"almost-window" usage:"default time after the winner of a first buzz wins question that late buzzes are reported to the host as close calls, 0 for never"`
+	TieWindow         time.Duration `yaml:"tie_window" env:"TIE_WINDOW" flag:"tie-window" usage:"buzzes pressed closer together than this are a tie, broken by the game's tiebreak and flagged to the host, 0 for never"`
+	ChallengeWindow   time.Duration `yaml:"challenge_window" env:"CHALLENGE_WINDOW" flag:"challenge-window" usage:"how long players have to challenge a ruling"`
+	HistoryRetention  time.Duration `yaml:"history_retention" env:"HISTORY_RETENTION" flag:"history-retention" usage:"how long a game's history is kept after its last event, 0 to keep none"`
+	GameIdleTimeout   time.Duration `yaml:"game_idle_timeout" env:"GAME_IDLE_TIMEOUT" flag:"game-idle-timeout" usage:"how long a game can sit idle before it expires, 0 for never"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long streams get to close on shutdown"`
+
+	// PingInterval and MaxLatencyCompensation only apply to games with
+	// latency compensation on
+	PingInterval           time.Duration `yaml:"ping_interval" env:"PING_INTERVAL" flag:"ping-interval" usage:"how often players in latency compensated games are pinged, 0 for never"`
+	MaxLatencyCompensation time.Duration `yaml:"max_latency_compensation" env:"MAX_LATENCY_COMPENSATION" flag:"max-latency-compensation" usage:"most a buzz can be moved earlier by latency compensation"`
+
+	// MaxPlayers and WaitingRoom are defaults, hosts can pick their own
+	// for each game
+	MaxPlayers  int  `yaml:"max_players" env:"MAX_PLAYERS" flag:"max-players" usage:"most players a game takes, 0 for no limit"`
+	WaitingRoom bool `yaml:"waiting_room" env:"WAITING_ROOM" flag:"waiting-room" usage:"put players joining a full game in line for a slot instead of turning them away"`
+
+	// BuzzLogChain applies to the buzz log kept with each game's history
+	BuzzLogChain bool `yaml:"buzz_log_chain" env:"BUZZ_LOG_CHAIN" flag:"buzz-log-chain" usage:"hash chain each game's buzz log so later changes to it show"`
+
+	// HostBacklog is kept for each game on each replica
+	HostBacklog int `yaml:"host_backlog" env:"HOST_BACKLOG" flag:"host-backlog" usage:"events kept for a game's host while it's away or falling behind, replayed once it's back"`
+
+	// MaxGames and MaxStreams cap what each replica takes on, so a surge is
+	// turned away with a 503 instead of running it out of memory. streams
+	// are SSE streams, WebSockets and long polls
+	MaxGames   int `yaml:"max_games" env:"MAX_GAMES" flag:"max-games" usage:"most games a replica runs at once, 0 for no limit"`
+	MaxStreams int `yaml:"max_streams" env:"MAX_STREAMS" flag:"max-streams" usage:"most streams a replica holds open at once, 0 for no limit"`
+
+	// TraceEndpoint is an OTLP/HTTP collector, such as Jaeger's on port
+	// 4318. requests and the events they publish are traced through to
+	// each stream they're written to
+	TraceEndpoint    string  `yaml:"trace_endpoint" env:"TRACE_ENDPOINT" flag:"trace-endpoint" usage:"OTLP/HTTP endpoint traces are exported to, e.g. http://localhost:4318, tracing is off when empty"`
+	TraceSampleRatio float64 `yaml:"trace_sample_ratio" env:"TRACE_SAMPLE_RATIO" flag:"trace-sample-ratio" usage:"share of traces kept, from 0 to 1"`
+
+	// DeviceAddr is where hardware buzzers connect, speaking the line
+	// protocol described in device.go. DeviceKey, if set, is a secret
+	// every device has to send first
+	DeviceAddr string `yaml:"device_addr" env:"DEVICE_ADDR" flag:"device-addr" usage:"TCP address hardware buzzers connect to, e.g. :7000, off when empty"`
+	DeviceKey  string `yaml:"device_key" env:"DEVICE_KEY" flag:"device-key" usage:"secret hardware buzzers send with HELLO, not needed when empty"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() *Config {
+	return &Config{
+		Addr:              ":8080",
+		TLS:               "file",
+		AutocertCacheDir:  "certs",
+		TLSMinVersion:     "1.2",
+		CORSOrigins:       []string{"*"},
+		CORSMethods:       []string{"GET", "POST", "PUT", "DELETE"},
+		CORSHeaders:       []string{"Content-Type", "Authorization", "X-Host-Token", "X-API-Key", "X-Account-Key", "X-Player-Token", "Accept-Language", "Last-Event-ID"},
+		CORSMaxAge:        10 * time.Minute,
+		Moderation:        "wordlist",
+		Auth:              "apikey",
+		AnonymousGames:    true,
+		OIDCTenantClaim:   "tenant",
+		SessionTTL:        12 * time.Hour,
+		CreateGameRate:    10,
+		BuzzRate:          5,
+		Store:             "memory",
+		Broker:            "memory",
+		RedisURL:          "redis://localhost:6379",
+		SQLitePath:        "bzzz.db",
+		PostgresMaxConns:  10,
+		GameIDStrategy:    "numeric",
+		PlayerIDStrategy:  "ulid",
+		GameCodeMin:       100000,
+		GameCodeMax:       999999,
+		GameCodeLength:    6,
+		SSEProxyHints:     true,
+		WSCompression:     true,
+		EventVersion:      1,
+		WebApp:            true,
+		Compression:       true,
+		CompressMinSize:   1024,
+		MaxRequestBody:    64 << 10,
+		HeartbeatInterval: 15 * time.Second,
+		RecentEventsTTL:   time.Minute,
+		QualityInterval:   5 * time.Second,
+		ClockInterval:     30 * time.Second,
+		HostLostAfter:     15 * time.Second,
+		OrphanGrace:       5 * time.Minute,
+		EndLinger:         2 * time.Minute,
+		AlmostWindow:      500 * time.Millisecond,
+		TieWindow:         time.Millisecond,
+		ChallengeWindow:   time.Minute,
+		HistoryRetention:  30 * 24 * time.Hour,
+		GameIdleTimeout:   2 * time.Hour,
+		ShutdownTimeout:   10 * time.Second,
+
+		PingInterval:           2 * time.Second,
+		MaxLatencyCompensation: 250 * time.Millisecond,
+
+		BuzzLogChain: true,
+		HostBacklog:  1024,
+
+		TraceSampleRatio: 1,
+	}
+}
+
+// Load builds the config from the defaults, the file named by -config or
+// CONFIG_FILE, the environment and the command line args, in that order.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	fs := flag.NewFlagSet("bzzz", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON config file")
+	flags := map[string]*string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		flags[f.Name] = fs.String(f.Tag.Get("flag"), "", f.Tag.Get("usage"))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if s, ok := os.LookupEnv(f.Tag.Get("env")); ok && s != "" {
+			if err := setField(v.Field(i), s); err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Tag.Get("env"), err)
+			}
+		}
+	}
+
+	given := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { given[fl.Name] = true })
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := f.Tag.Get("flag"); given[name] {
+			if err := setField(v.Field(i), *flags[f.Name]); err != nil {
+				return nil, fmt.Errorf("-%s: %v", name, err)
+			}
+		}
+	}
+
+	return cfg, cfg.validate()
+}
+
+// loadFile applies a config file. YAML is a superset of JSON so both parse
+// the same way.
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("yaml")
+		known[key] = true
+
+		val, ok := raw[key]
+		if !ok {
+			continue
+		}
+		s := fmt.Sprint(val)
+		if list, ok := val.([]interface{}); ok {
+			items := make([]string, len(list))
+			for j, item := range list {
+				items[j] = fmt.Sprint(item)
+			}
+			s = strings.Join(items, ",")
+		}
+		if err := setField(v.Field(i), s); err != nil {
+			return fmt.Errorf("%s: %s: %v", path, key, err)
+		}
+	}
+
+	for key := range raw {
+		if !known[key] {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+	}
+	return nil
+}
+
+// setField parses s into a config field.
+func setField(f reflect.Value, s string) error {
+	switch f.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case bool:
+		switch strings.ToLower(s) {
+		case "on":
+			f.SetBool(true)
+		case "off":
+			f.SetBool(false)
+		default:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			f.SetBool(b)
+		}
+	case []string:
+		list := []string{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.Set(reflect.ValueOf(list))
+	case string:
+		f.SetString(s)
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.Type())
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.GameCodeMin < 0 || c.GameCodeMin >= c.GameCodeMax {
+		return errors.New("game_code_min must be below game_code_max")
+	}
+	if c.GameCodeLength < 4 || c.GameCodeLength > 16 {
+		return errors.New("game_code_length must be between 4 and 16")
+	}
+	if c.MaxPlayers < 0 {
+		return errors.New("max_players can't be negative")
+	}
+	if c.MaxGames < 0 {
+		return errors.New("max_games can't be negative")
+	}
+	if c.MaxStreams < 0 {
+		return errors.New("max_streams can't be negative")
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		return errors.New("trace_sample_ratio must be between 0 and 1")
+	}
+	if c.HostBacklog < 1 {
+		return errors.New("host_backlog must be at least 1")
+	}
+	if c.TieWindow < 0 {
+		return errors.New("tie_window can't be negative")
+	}
+	if c.PrivateAddr != "" && c.ListenersConfig != "" {
+		return errors.New("private_addr is for the default listeners, give listeners_config a private listener instead")
+	}
+	if c.Store == "postgres" && c.PostgresDSN == "" {
+		return errors.New("the postgres store needs postgres_dsn")
+	}
+	if c.PostgresMaxConns < 1 {
+		return errors.New("postgres_max_conns must be at least 1")
+	}
+	if c.EventVersion < 1 || c.EventVersion > 2 {
+		return errors.New("event_version must be 1 or 2")
+	}
+	if c.CompressMinSize < 0 {
+		return errors.New("compress_min_size can't be negative")
+	}
+	if c.MaxRequestBody < 1 {
+		return errors.New("max_request_body must be at least 1")
+	}
+	if c.StreamBatchWindow < 0 || c.StreamBatchWindow > time.Second {
+		return errors.New("stream_batch_window must be between 0 and 1s")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("cert_file and key_file have to be set together")
+	}
+	switch c.TLS {
+	case "file", "off":
+	case "autocert":
+		if len(c.AutocertDomains) == 0 {
+			return errors.New("autocert needs at least one of autocert_domains")
+		}
+	default:
+		return fmt.Errorf("unknown tls %q, file, autocert or off", c.TLS)
+	}
+	switch c.TLSMinVersion {
+	case "1.0", "1.1", "1.2", "1.3":
+	default:
+		return fmt.Errorf("unknown tls_min_version %q, 1.0, 1.1, 1.2 or 1.3", c.TLSMinVersion)
+	}
+	for _, name := range c.TLSCipherSuites {
+		if !cipherSuite(name) {
+			return fmt.Errorf("unknown tls_cipher_suites %q, see crypto/tls for the names", name)
+		}
+	}
+	if len(c.CORSOrigins) == 0 {
+		return errors.New("at least one CORS origin is required")
+	}
+	if len(c.CORSMethods) == 0 {
+		return errors.New("at least one CORS method is required")
+	}
+	if c.CORSMaxAge < 0 {
+		return errors.New("cors_max_age can't be negative")
+	}
+	return nil
+}
+
+// cipherSuite reports whether name is a secure cipher suite that can be
+// picked, one used up to TLS 1.2. TLS 1.3 suites aren't configurable.
+func cipherSuite(name string) bool {
+	for _, s := range tls.CipherSuites() {
+		if s.Name != name {
+			continue
+		}
+		for _, v := range s.SupportedVersions {
+			if v != tls.VersionTLS13 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,9 +1,18 @@
 module bzzz
 
-go 1.13
+go 1.16
 
 require (
-	github.com/gorilla/handlers v1.4.2
+	github.com/gomodule/redigo v1.8.9
 	github.com/gorilla/mux v1.7.4
-	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e // indirect
+	github.com/gorilla/websocket v1.5.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.16
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	google.golang.org/protobuf v1.28.1
+	gopkg.in/yaml.v2 v2.4.0
 )
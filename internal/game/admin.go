@@ -0,0 +1,69 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// GameSummary is an operator's view of a running game. the stream counts
+// are only for this replica.
+type GameSummary struct {
+	GameID     string    `json:"gameID"`
+	Tenant     string    `json:"tenant,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	LastActive time.Time `json:"lastActive"`
+	Seq        int       `json:"seq"`
+	Locked     bool      `json:"locked"`
+	Orphaned   bool      `json:"orphaned"`
+	Players    int       `json:"players"`
+	Connected  int       `json:"connected"`
+	Hosts      int       `json:"hosts"`
+	Spectators int       `json:"spectators"`
+}
+
+// summarize sums up g, it must be called with m.mu held.
+func (g *game) summarize() GameSummary {
+	s := GameSummary{
+		GameID:     g.id,
+		Tenant:     g.options.Tenant,
+		StartedAt:  g.startedAt,
+		LastActive: g.lastActive,
+		Seq:        g.seq,
+		Locked:     g.locked,
+		Players:    len(g.players),
+		Hosts:      len(g.hosts),
+		Spectators: len(g.spectators),
+	}
+	for _, p := range g.players {
+		if p.Connected {
+			s.Connected++
+		}
+	}
+	return s
+}
+
+// Summaries sums up every running game, oldest first. whether a game is
+// orphaned is left for the caller, see summarizeGames.
+func (m *Manager) Summaries() []GameSummary {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	summaries := make([]GameSummary, 0, len(m.games))
+	for _, g := range m.games {
+		summaries = append(summaries, g.summarize())
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].StartedAt.Before(summaries[j].StartedAt) })
+	return summaries
+}
+
+// Summary sums up a single game.
+func (m *Manager) Summary(gameID string) (GameSummary, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return GameSummary{}, false
+	}
+	return g.summarize(), true
+}
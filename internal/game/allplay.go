@@ -0,0 +1,186 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoAllPlay       = errors.New("no all-play round has been opened")
+	ErrAllPlayClosed   = errors.New("all-play answers are closed")
+	ErrAllPlayOpen     = errors.New("all-play answers are still open")
+	ErrAllPlayRevealed = errors.New("all-play answers already revealed")
+	ErrNotAnswered     = errors.New("player hasn't answered")
+)
+
+// AllPlayRound is a question every player answers rather than the first to
+// buzz. the host opens it, each player answers once until the host closes
+// it, then the host reveals every answer at the same moment, judging them
+// all at once.
+type AllPlayRound struct {
+	QuestionID int `json:"questionID"`
+	// Points is what a correct answer wins
+	Points     int            `json:"points"`
+	Open       bool           `json:"open"`
+	OpenedAt   time.Time      `json:"openedAt"`
+	ClosedAt   *time.Time     `json:"closedAt,omitempty"`
+	RevealedAt *time.Time     `json:"revealedAt,omitempty"`
+	Entries    []AllPlayEntry `json:"entries"`
+}
+
+// AllPlayEntry is a player's answer in an all-play round.
+type AllPlayEntry struct {
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Answer     string    `json:"answer"`
+	At         time.Time `json:"at"`
+	// Correct is nil until the host reveals the answers
+	Correct *bool `json:"correct,omitempty"`
+}
+
+// copy returns a copy of the round that shares nothing with it.
+func (a *AllPlayRound) copy() AllPlayRound {
+	c := *a
+	c.Entries = append([]AllPlayEntry{}, a.Entries...)
+	return c
+}
+
+// entry returns the index of a player's answer, or -1.
+func (a *AllPlayRound) entry(playerID string) int {
+	for i, e := range a.Entries {
+		if e.PlayerID == playerID {
+			return i
+		}
+	}
+	return -1
+}
+
+// OpenAllPlay starts an all-play round on the current question, replacing
+// the last one. a correct answer wins points.
+func (m *Manager) OpenAllPlay(gameID string, points int) (AllPlayRound, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return AllPlayRound{}, ErrGameNotFound
+	}
+	g.allPlay = &AllPlayRound{
+		QuestionID: g.question,
+		Points:     points,
+		Open:       true,
+		OpenedAt:   time.Now(),
+		Entries:    []AllPlayEntry{},
+	}
+	m.save(g)
+	return g.allPlay.copy(), nil
+}
+
+// SubmitAllPlay records a player's answer while the round is open. each
+// player answers once, a second answer fails with ErrAlreadyAnswered.
+func (m *Manager) SubmitAllPlay(gameID, playerID, text string) (AllPlayEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return AllPlayEntry{}, ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return AllPlayEntry{}, ErrPlayerNotFound
+	}
+	if g.allPlay == nil {
+		return AllPlayEntry{}, ErrNoAllPlay
+	}
+	if !g.allPlay.Open {
+		return AllPlayEntry{}, ErrAllPlayClosed
+	}
+	if g.allPlay.entry(playerID) >= 0 {
+		return AllPlayEntry{}, ErrAlreadyAnswered
+	}
+
+	e := AllPlayEntry{
+		PlayerID:   playerID,
+		PlayerName: p.Name,
+		Answer:     text,
+		At:         time.Now(),
+	}
+	g.allPlay.Entries = append(g.allPlay.Entries, e)
+	m.save(g)
+	return e, nil
+}
+
+// CloseAllPlay stops players answering.
+func (m *Manager) CloseAllPlay(gameID string) (AllPlayRound, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return AllPlayRound{}, ErrGameNotFound
+	}
+	if g.allPlay == nil {
+		return AllPlayRound{}, ErrNoAllPlay
+	}
+	if !g.allPlay.Open {
+		return AllPlayRound{}, ErrAllPlayClosed
+	}
+
+	now := time.Now()
+	g.allPlay.Open = false
+	g.allPlay.ClosedAt = &now
+	m.save(g)
+	return g.allPlay.copy(), nil
+}
+
+// RevealAllPlay judges every answer once the round has closed, the players
+// in correct right and everyone else wrong. a player in correct who didn't
+// answer fails with ErrNotAnswered, naming them.
+func (m *Manager) RevealAllPlay(gameID string, correct []string) (revealed AllPlayRound, missing string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return AllPlayRound{}, "", ErrGameNotFound
+	}
+	if g.allPlay == nil {
+		return AllPlayRound{}, "", ErrNoAllPlay
+	}
+	if g.allPlay.Open {
+		return AllPlayRound{}, "", ErrAllPlayOpen
+	}
+	if g.allPlay.RevealedAt != nil {
+		return AllPlayRound{}, "", ErrAllPlayRevealed
+	}
+	right := map[string]bool{}
+	for _, playerID := range correct {
+		if g.allPlay.entry(playerID) < 0 {
+			return AllPlayRound{}, playerID, ErrNotAnswered
+		}
+		right[playerID] = true
+	}
+
+	now := time.Now()
+	for i := range g.allPlay.Entries {
+		verdict := right[g.allPlay.Entries[i].PlayerID]
+		g.allPlay.Entries[i].Correct = &verdict
+		g.tallyVerdict(g.allPlay.Entries[i].PlayerID, verdict)
+	}
+	g.allPlay.RevealedAt = &now
+	m.save(g)
+	return g.allPlay.copy(), "", nil
+}
+
+// AllPlay returns a game's current or last all-play round.
+func (m *Manager) AllPlay(gameID string) (AllPlayRound, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok || g.allPlay == nil {
+		return AllPlayRound{}, false
+	}
+	return g.allPlay.copy(), true
+}
@@ -0,0 +1,33 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// noteAlmost keeps a late buzz for the question's almost report if it
+// landed within the game's almost window of the winner. it must be called
+// with m.mu held.
+func (g *game) noteAlmost(b Buzz) {
+	window := g.options.AlmostWindow
+	if window <= 0 || b.DeltaMs > float64(window)/float64(time.Millisecond) {
+		return
+	}
+	g.almost = append(g.almost, b)
+}
+
+// TakeAlmost hands over the close calls gathered for winner's question and
+// forgets them. ok is false if the buzzers have been reset since winner
+// won, leaving nothing to report.
+func (m *Manager) TakeAlmost(gameID string, winner Buzz) (buzzes []Buzz, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists || len(g.buzzes) == 0 || !g.buzzes[0].At.Equal(winner.At) || g.buzzes[0].PlayerID != winner.PlayerID {
+		return nil, false
+	}
+	buzzes, g.almost = g.almost, nil
+	sort.SliceStable(buzzes, func(i, j int) bool { return buzzes[i].DeltaMs < buzzes[j].DeltaMs })
+	return buzzes, true
+}
@@ -0,0 +1,182 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotOnFloor      = errors.New("only the buzzed in player can answer")
+	ErrAlreadyAnswered = errors.New("answer already submitted")
+)
+
+type Answer struct {
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Text       string    `json:"text,omitempty"`
+	QuestionID int       `json:"questionID"`
+	Round      int       `json:"round,omitempty"`
+	At         time.Time `json:"at"`
+	// Correct is nil until the host has judged the answer
+	Correct  *bool      `json:"correct,omitempty"`
+	Points   int        `json:"points,omitempty"`
+	JudgedAt *time.Time `json:"judgedAt,omitempty"`
+	// AnsweredBy is the captain who answered for their teammate with the
+	// floor, in a game with captains
+	AnsweredBy string `json:"answeredBy,omitempty"`
+}
+
+// floor returns who gets to answer the current question: the earliest
+// buzz that hasn't already been judged wrong. it must be called with m.mu
+// held.
+func (g *game) floor() (playerID string, ok bool) {
+	wrong := map[string]bool{}
+	for _, a := range g.answers {
+		if a.QuestionID == g.question && a.Correct != nil && !*a.Correct {
+			wrong[a.PlayerID] = true
+		}
+	}
+	for _, b := range g.buzzes {
+		if !b.Late && !wrong[b.PlayerID] {
+			return b.PlayerID, true
+		}
+	}
+	return "", false
+}
+
+// pendingAnswer returns the index of a player's unjudged answer to the
+// current question, or -1. it must be called with m.mu held.
+func (g *game) pendingAnswer(playerID string) int {
+	for i, a := range g.answers {
+		if a.PlayerID == playerID && a.QuestionID == g.question && a.Correct == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// SubmitAnswer records the answer of the player with the floor. anyone
+// else fails with ErrNotOnFloor, a second answer before the first is judged
+// with ErrAlreadyAnswered. in a game with captains a team's answers come
+// from its captain, on behalf of whichever of them has the floor, and
+// anyone else on the team fails with ErrNotCaptain.
+func (m *Manager) SubmitAnswer(gameID, playerID, text string) (Answer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Answer{}, ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return Answer{}, ErrPlayerNotFound
+	}
+	if err := g.checkTransition(stateJudging); err != nil {
+		return Answer{}, err
+	}
+	floor, ok := g.floor()
+	if !ok {
+		return Answer{}, ErrNotOnFloor
+	}
+	by := ""
+	if g.options.Captains && p.Team != "" {
+		if g.captain(p.Team) != playerID {
+			return Answer{}, ErrNotCaptain
+		}
+		if fp, ok := g.players[floor]; ok && fp.Team == p.Team && floor != playerID {
+			by, p = playerID, fp
+		}
+	}
+	if floor != p.PlayerID {
+		return Answer{}, ErrNotOnFloor
+	}
+	if g.pendingAnswer(p.PlayerID) >= 0 {
+		return Answer{}, ErrAlreadyAnswered
+	}
+
+	a := Answer{
+		PlayerID:   p.PlayerID,
+		PlayerName: p.Name,
+		Text:       text,
+		QuestionID: g.question,
+		Round:      g.currentRound(),
+		At:         time.Now(),
+		AnsweredBy: by,
+	}
+	g.answers = append(g.answers, a)
+	m.save(g)
+	return a, nil
+}
+
+// Judge records the host's verdict on a player's answer to the current
+// question. a player who answered out loud rather than submitting is judged
+// all the same. next is who has the floor afterwards, if anyone.
+func (m *Manager) Judge(gameID, playerID string, correct bool, points int) (a Answer, next string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Answer{}, "", ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return Answer{}, "", ErrPlayerNotFound
+	}
+	to := stateArmed
+	if correct {
+		to = stateIntermission
+	}
+	if err := g.checkTransition(to); err != nil {
+		return Answer{}, "", err
+	}
+
+	i := g.pendingAnswer(playerID)
+	if i < 0 {
+		g.answers = append(g.answers, Answer{
+			PlayerID:   playerID,
+			PlayerName: p.Name,
+			QuestionID: g.question,
+			Round:      g.currentRound(),
+			At:         time.Now(),
+		})
+		i = len(g.answers) - 1
+	}
+
+	now := time.Now()
+	g.answers[i].Correct = &correct
+	g.answers[i].Points = points
+	g.answers[i].JudgedAt = &now
+	m.save(g)
+
+	if !correct {
+		next, _ = g.floor()
+	}
+	return g.answers[i], next, nil
+}
+
+// QuestionPoints is what the current question is worth, its loaded points
+// or the game's points for a right answer, times the round's multiplier.
+func (m *Manager) QuestionPoints(gameID string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0
+	}
+	return g.questionValue()
+}
+
+// Floor returns who gets to answer a game's current question.
+func (m *Manager) Floor(gameID string) (playerID string, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, exists := m.games[gameID]
+	if !exists {
+		return "", false
+	}
+	return g.floor()
+}
@@ -0,0 +1,40 @@
+package game
+
+// playerColors are handed out to players as they join, so host displays
+// can tell buzzers apart without keeping their own mapping. they're picked
+// to stay distinct from each other on light and dark backgrounds.
+var playerColors = []string{
+	"#e6194b", // red
+	"#3cb44b", // green
+	"#4363d8", // blue
+	"#f58231", // orange
+	"#911eb4", // purple
+	"#42d4f4", // cyan
+	"#f032e6", // magenta
+	"#bfef45", // lime
+	"#fabed4", // pink
+	"#469990", // teal
+	"#9a6324", // brown
+	"#ffe119", // yellow
+}
+
+// pickColor returns the color the fewest players in the game have, going
+// through playerColors in order, so nobody shares a color until they've
+// all been used.
+func (g *game) pickColor() string {
+	used := map[string]int{}
+	for _, p := range g.players {
+		used[p.Color]++
+	}
+	for _, w := range g.waiting {
+		used[w.Color]++
+	}
+
+	color := playerColors[0]
+	for _, c := range playerColors[1:] {
+		if used[c] < used[color] {
+			color = c
+		}
+	}
+	return color
+}
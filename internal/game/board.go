@@ -0,0 +1,217 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCategories = errors.New("no loaded question has a category")
+	ErrNoBoard      = errors.New("no board has been set up")
+	ErrNotYourPick  = errors.New("it's not your pick")
+	ErrNoSuchCell   = errors.New("no such question on the board")
+	ErrCellUsed     = errors.New("that question has already been picked")
+)
+
+// boardState is a Jeopardy style board made from the loaded questions that
+// have a category. the player who last answered a board question right
+// picks the next one, which is then asked like any other question.
+type boardState struct {
+	// Picker is who picks next, empty for the host
+	Picker string `json:"picker,omitempty"`
+	// Used are the questions already picked, in the order they were
+	Used []int `json:"used,omitempty"`
+	// Cell is the question picked last, 0 before the first pick
+	Cell      int       `json:"cell,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+func (b *boardState) copy() boardState {
+	c := *b
+	c.Used = append([]int{}, b.Used...)
+	return c
+}
+
+func (b *boardState) used(questionID int) bool {
+	for _, id := range b.Used {
+		if id == questionID {
+			return true
+		}
+	}
+	return false
+}
+
+// boardCell is a question's place on the board. the text only comes out
+// once it's picked, the answer never.
+type boardCell struct {
+	QuestionID int  `json:"questionID"`
+	Points     int  `json:"points"`
+	Used       bool `json:"used"`
+}
+
+type boardCategory struct {
+	Name  string      `json:"name"`
+	Cells []boardCell `json:"cells"`
+}
+
+// BoardView is the board as everyone sees it, the data of a board event.
+type BoardView struct {
+	Categories []boardCategory `json:"categories"`
+	Picker     string          `json:"picker,omitempty"`
+	PickerName string          `json:"pickerName,omitempty"`
+	Cell       int             `json:"cell,omitempty"`
+	// Remaining counts the cells not yet picked
+	Remaining int `json:"remaining"`
+}
+
+// BoardView lays the board out, categories in the order they first appear
+// in the questions and each one's cells by points. it must be called with
+// m.mu held and g.board set.
+func (g *game) boardView() BoardView {
+	v := BoardView{Categories: []boardCategory{}, Picker: g.board.Picker, Cell: g.board.Cell}
+	if p, ok := g.players[g.board.Picker]; ok {
+		v.PickerName = p.Name
+	}
+
+	index := map[string]int{}
+	for _, q := range g.questions {
+		if q.Category == "" {
+			continue
+		}
+		i, ok := index[q.Category]
+		if !ok {
+			i = len(v.Categories)
+			index[q.Category] = i
+			v.Categories = append(v.Categories, boardCategory{Name: q.Category})
+		}
+		used := g.board.used(q.ID)
+		if !used {
+			v.Remaining++
+		}
+		v.Categories[i].Cells = append(v.Categories[i].Cells, boardCell{QuestionID: q.ID, Points: q.Points, Used: used})
+	}
+	for _, c := range v.Categories {
+		cells := c.Cells
+		sort.SliceStable(cells, func(i, j int) bool { return cells[i].Points < cells[j].Points })
+	}
+	return v
+}
+
+// boardQuestion finds the board question picked by its ID, or by its
+// category and points when questionID is 0. it must be called with m.mu
+// held.
+func (g *game) boardQuestion(questionID int, category string, points int) (Question, bool) {
+	for _, q := range g.questions {
+		if q.Category == "" {
+			continue
+		}
+		if questionID != 0 && q.ID == questionID {
+			return q, true
+		}
+		if questionID == 0 && strings.EqualFold(q.Category, category) && q.Points == points && !g.board.used(q.ID) {
+			return q, true
+		}
+	}
+	return Question{}, false
+}
+
+// StartBoard sets up a fresh board from the loaded questions. picker is
+// who gets the first pick, empty to leave it to the host.
+func (m *Manager) StartBoard(gameID, picker string) (BoardView, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return BoardView{}, ErrGameNotFound
+	}
+	if len(g.questions) == 0 {
+		return BoardView{}, ErrNoQuestions
+	}
+	categorized := false
+	for _, q := range g.questions {
+		categorized = categorized || q.Category != ""
+	}
+	if !categorized {
+		return BoardView{}, ErrNoCategories
+	}
+	if _, ok := g.players[picker]; picker != "" && !ok {
+		return BoardView{}, ErrPlayerNotFound
+	}
+
+	g.board = &boardState{Picker: picker, StartedAt: time.Now()}
+	m.save(g)
+	return g.boardView(), nil
+}
+
+// Board returns a game's board.
+func (m *Manager) Board(gameID string) (BoardView, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return BoardView{}, ErrGameNotFound
+	}
+	if g.board == nil {
+		return BoardView{}, ErrNoBoard
+	}
+	return g.boardView(), nil
+}
+
+// PickCell moves a game on to a question picked from the board, clearing
+// the buzzes like NextQuestion. playerID is the player picking, who must
+// be the board's picker, or empty for the host, who can always pick. the
+// question being asked is the picked one, so unlike with NextQuestion
+// the questions can go out of order.
+func (m *Manager) PickCell(gameID, playerID string, questionID int, category string, points int) (Question, BoardView, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Question{}, BoardView{}, ErrGameNotFound
+	}
+	if g.board == nil {
+		return Question{}, BoardView{}, ErrNoBoard
+	}
+	if playerID != "" && playerID != g.board.Picker {
+		return Question{}, BoardView{}, ErrNotYourPick
+	}
+	q, ok := g.boardQuestion(questionID, category, points)
+	if !ok {
+		if questionID == 0 && category != "" {
+			// every question at those points may have gone already
+			return Question{}, BoardView{}, ErrCellUsed
+		}
+		return Question{}, BoardView{}, ErrNoSuchCell
+	}
+	if g.board.used(q.ID) {
+		return Question{}, BoardView{}, ErrCellUsed
+	}
+
+	g.clearBuzzes()
+	g.question = q.ID
+	g.shown = q.ID
+	g.board.Used = append(g.board.Used, q.ID)
+	g.board.Cell = q.ID
+	m.save(g)
+	return q, g.boardView(), nil
+}
+
+// BoardWinner hands the pick to a player who answered the picked board
+// question right. ok is false if that changed nothing.
+func (m *Manager) BoardWinner(gameID, playerID string) (v BoardView, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.board == nil || g.board.Cell == 0 || g.board.Cell != g.question || g.board.Picker == playerID {
+		return BoardView{}, false
+	}
+	g.board.Picker = playerID
+	m.save(g)
+	return g.boardView(), true
+}
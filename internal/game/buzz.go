@@ -0,0 +1,352 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrTeamLockedOut = errors.New("your team already buzzed for this question")
+	ErrDuplicateBuzz = errors.New("buzz already received")
+	ErrBuzzersLocked = errors.New("buzzers are locked")
+	ErrStaleBuzz     = errors.New("that question has been reset")
+)
+
+// buzzNonceTTL is how long a buzz nonce is remembered. clients that send the
+// same buzz over more than one transport use the same nonce for every copy,
+// and copies arriving within this window count once. so do retries from
+// clients on flaky networks, even if the question has moved on since.
+const buzzNonceTTL = time.Minute
+
+// seenBuzz is a counted buzz remembered by its nonce. it's kept with the
+// game record, so a retry landing after a restart, or on the replica that
+// picked the game up, still counts once.
+type seenBuzz struct {
+	Buzz Buzz      `json:"buzz"`
+	At   time.Time `json:"at"`
+}
+
+// Buzz is a single accepted buzz in a game's buzz order.
+type Buzz struct {
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Seat       int       `json:"seat,omitempty"`
+	Team       string    `json:"team,omitempty"`
+	Color      string    `json:"color,omitempty"`
+	QuestionID int       `json:"questionID,omitempty"`
+	Round      int       `json:"round,omitempty"`
+	Position   int       `json:"position"`
+	At         time.Time `json:"at"`
+	// DeltaMs is how far behind the first buzz this one landed, measured on
+	// the server's monotonic clock
+	DeltaMs float64 `json:"deltaMs"`
+	// ReactionMs is how long after the buzzers were armed this buzz landed
+	ReactionMs float64 `json:"reactionMs,omitempty"`
+	// Late is set on buzzes kept after losing to a first buzz wins winner
+	Late bool `json:"late,omitempty"`
+	// CompensationMs is how much earlier than At the buzz was taken to be
+	// pressed in a latency compensated game, see latencyCompensation
+	CompensationMs float64 `json:"compensationMs,omitempty"`
+	// Tie numbers the tie the buzz was part of within its question, buzzes
+	// pressed within cfg.TieWindow of each other sharing it. zero for none
+	Tie int `json:"tie,omitempty"`
+}
+
+// pressedAt is when a buzz counts as pressed for the buzz order, when it
+// arrived less any latency compensation.
+func (b Buzz) pressedAt() time.Time {
+	return b.At.Add(-time.Duration(b.CompensationMs * float64(time.Millisecond)))
+}
+
+// RecordBuzz timestamps a buzz on receipt and adds it to the game's buzz
+// order for the current question, by when it was pressed in latency
+// compensated games. ok is false if the player already buzzed for it. a
+// buzz the game won't take fails with an error saying why, such as
+// ErrBuzzersLocked, and a duplicate or late one also returns its buzz. comp
+// is how much earlier than it arrived the buzz was pressed.
+func (m *Manager) RecordBuzz(gameID, playerID, nonce string, questionID int, comp time.Duration) (b Buzz, ok bool, err error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists {
+		return Buzz{}, false, ErrGameNotFound
+	}
+	p, exists := g.players[playerID]
+	if !exists {
+		return Buzz{}, false, ErrPlayerNotFound
+	}
+	if seen, dup := g.seenBuzz(playerID, nonce, now); dup {
+		return seen, false, ErrDuplicateBuzz
+	}
+	if err := g.checkTransition(stateAnswering); err != nil {
+		return Buzz{}, false, err
+	}
+	if questionID != 0 && questionID != g.question {
+		return Buzz{}, false, ErrStaleBuzz
+	}
+	if g.buzzed[playerID] {
+		return Buzz{}, false, nil
+	}
+	if g.cooldownLeft(playerID, now) > 0 {
+		return Buzz{}, false, ErrBuzzCooldown
+	}
+	if g.options.Recognition == RecognitionRoundRobin && g.turnPlayer != playerID {
+		return Buzz{}, false, ErrNotYourTurn
+	}
+	if late, _, ok := g.lateBuzz(p, now, m.cfg.TieWindow); ok {
+		m.save(g)
+		return late, false, ErrLateBuzz
+	}
+	if g.locked {
+		return Buzz{}, false, ErrBuzzersLocked
+	}
+	if g.targetLocked(p) {
+		return Buzz{}, false, ErrBuzzerLocked
+	}
+	if g.options.ArmedBuzzing && !g.armed {
+		return Buzz{}, false, ErrEarlyBuzz
+	}
+	if g.options.TeamLockout && !g.options.SoloMode && p.Team != "" {
+		for _, other := range g.buzzes {
+			if other.Team == p.Team {
+				return Buzz{}, false, ErrTeamLockedOut
+			}
+		}
+	}
+
+	b = Buzz{
+		PlayerID:   playerID,
+		PlayerName: p.Name,
+		Seat:       p.Seat,
+		Team:       p.Team,
+		Color:      p.Color,
+		QuestionID: g.question,
+		Round:      g.currentRound(),
+		Position:   len(g.buzzes) + 1,
+		At:         now,
+	}
+	if g.options.LatencyCompensation {
+		b.CompensationMs = float64(comp) / float64(time.Millisecond)
+	}
+	if len(g.buzzes) > 0 {
+		b.DeltaMs = float64(b.pressedAt().Sub(g.buzzes[0].pressedAt())) / float64(time.Millisecond)
+	}
+	if !g.armedAt.IsZero() {
+		b.ReactionMs = float64(b.pressedAt().Sub(g.armedAt)) / float64(time.Millisecond)
+		g.reactions = append(g.reactions, b)
+	}
+
+	g.buzzed[playerID] = true
+	if g.options.LatencyCompensation {
+		b = g.insertBuzz(b)
+	} else {
+		g.buzzes = append(g.buzzes, b)
+	}
+	b = g.breakTie(b.Position-1, m.cfg.TieWindow)
+	g.lastBuzzAt[playerID] = now
+	if g.options.FirstBuzzWins {
+		g.locked, g.armed = true, false
+	}
+	if nonce != "" {
+		g.rememberBuzz(playerID, nonce, seenBuzz{Buzz: b, At: now})
+	}
+	m.save(g)
+	return b, true, nil
+}
+
+// insertBuzz puts b in the buzz order by when it was pressed, renumbering
+// the buzzes it overtakes, and returns it with its position. it must be
+// called with m.mu held.
+func (g *game) insertBuzz(b Buzz) Buzz {
+	i := len(g.buzzes)
+	for i > 0 && !g.buzzes[i-1].Late && b.pressedAt().Before(g.buzzes[i-1].pressedAt()) {
+		i--
+	}
+	g.buzzes = append(g.buzzes, Buzz{})
+	copy(g.buzzes[i+1:], g.buzzes[i:])
+	g.buzzes[i] = b
+
+	first := g.buzzes[0].pressedAt()
+	for j := range g.buzzes {
+		g.buzzes[j].Position = j + 1
+		if !g.buzzes[j].Late {
+			g.buzzes[j].DeltaMs = float64(g.buzzes[j].pressedAt().Sub(first)) / float64(time.Millisecond)
+		}
+	}
+	return g.buzzes[i]
+}
+
+// DuplicateBuzz returns the buzz a player already made with nonce, if any.
+func (m *Manager) DuplicateBuzz(gameID, playerID, nonce string) (Buzz, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Buzz{}, false
+	}
+	return g.seenBuzz(playerID, nonce, time.Now())
+}
+
+// rememberBuzz keeps a counted buzz under the player's nonce for it.
+func (g *game) rememberBuzz(playerID, nonce string, seen seenBuzz) {
+	if g.nonces[playerID] == nil {
+		g.nonces[playerID] = map[string]seenBuzz{}
+	}
+	g.nonces[playerID][nonce] = seen
+}
+
+// seenBuzz looks up a player's buzz nonce, forgetting the player's nonces
+// older than buzzNonceTTL as it goes. while the question is still open the
+// buzz comes back as it stands in the buzz order now, later buzzes may have
+// overtaken it in a latency compensated game.
+func (g *game) seenBuzz(playerID, nonce string, now time.Time) (Buzz, bool) {
+	nonces := g.nonces[playerID]
+	for n, seen := range nonces {
+		if now.Sub(seen.At) > buzzNonceTTL {
+			delete(nonces, n)
+		}
+	}
+	if len(nonces) == 0 {
+		delete(g.nonces, playerID)
+	}
+	seen, ok := nonces[nonce]
+	if nonce == "" || !ok {
+		return Buzz{}, false
+	}
+	if b, ok := g.playerBuzz(playerID); ok && b.QuestionID == seen.Buzz.QuestionID {
+		return b, true
+	}
+	return seen.Buzz, true
+}
+
+// playerBuzz finds a player's buzz in the buzz order. it must be called
+// with m.mu held.
+func (g *game) playerBuzz(playerID string) (Buzz, bool) {
+	for _, b := range g.buzzes {
+		if b.PlayerID == playerID {
+			return b, true
+		}
+	}
+	return Buzz{}, false
+}
+
+// BuzzOrder returns a copy of the game's current buzz order.
+func (m *Manager) BuzzOrder(gameID string) []Buzz {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return []Buzz{}
+	}
+	return append([]Buzz{}, g.buzzes...)
+}
+
+// PlayerBuzz returns a player's buzz in the buzz order for the current
+// question, if they've buzzed.
+func (m *Manager) PlayerBuzz(gameID, playerID string) (Buzz, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Buzz{}, false
+	}
+	return g.playerBuzz(playerID)
+}
+
+// clearBuzzes moves a game on to its next question. it must be called with
+// m.mu held.
+func (g *game) clearBuzzes() {
+	// keep the cleared buzzes in the round's history
+	if g.roundActive {
+		r := &g.rounds[len(g.rounds)-1]
+		r.Buzzes = append(r.Buzzes, g.buzzes...)
+	}
+	tallyBuzzes(g.stats, g.buzzes)
+	g.buzzes = nil
+	g.buzzed = map[string]bool{}
+	g.late = map[string]bool{}
+	g.almost = nil
+	g.armedAt = time.Time{}
+	g.armed = false
+	g.clearTargets()
+	g.question++
+}
+
+// ResetQuestion moves a game on to its next question with the buzzers
+// unlocked, so a buzz can't land between the two. it returns the question
+// now up and its round.
+func (m *Manager) ResetQuestion(gameID string) (questionID, round int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0, 0, ErrGameNotFound
+	}
+	if err := g.checkTransition(stateArmed); err != nil {
+		return 0, 0, err
+	}
+	g.pushUndo(g.undoReset())
+	g.clearBuzzes()
+	g.locked = false
+	m.save(g)
+	return g.question, g.currentRound(), nil
+}
+
+// SetLocked locks or unlocks a game's buzzers. unlocking them unlocks
+// players and teams locked on their own too.
+func (m *Manager) SetLocked(gameID string, lock bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ErrGameNotFound
+	}
+	to := stateArmed
+	if lock {
+		to = stateIntermission
+	}
+	if err := g.checkTransition(to); err != nil {
+		return err
+	}
+	g.locked = lock
+	if lock {
+		g.armed = false
+	} else {
+		g.clearTargets()
+	}
+	m.save(g)
+	return nil
+}
+
+// Locked reports whether a game's buzzers are locked.
+func (m *Manager) Locked(gameID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	return ok && g.locked
+}
+
+// ApplyHostAction updates server side game state for a host action before
+// it is broadcast. an action the game can't take in the state it's in
+// fails with a TransitionError.
+func (m *Manager) ApplyHostAction(gameID string, action string) error {
+	switch action {
+	case "lock":
+		return m.SetLocked(gameID, true)
+	case "unlock":
+		return m.SetLocked(gameID, false)
+	case "reset":
+		_, _, err := m.ResetQuestion(gameID)
+		return err
+	}
+	return nil
+}
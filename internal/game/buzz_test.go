@@ -0,0 +1,38 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// nonces are each player's own, a retry counts once but another player
+// picking the same nonce still buzzes
+func TestBuzzNonces(t *testing.T) {
+	m := newTestManager(t, newMemStore(), ULIDs{}, ULIDs{})
+	gameID, players := newResetGame(t, m, GameOptions{}, 2)
+
+	first, ok, err := m.RecordBuzz(gameID, players[0], "n1", 0, 0)
+	if err != nil || !ok {
+		t.Fatalf("first buzz got %v, %v", ok, err)
+	}
+	if b, _, err := m.RecordBuzz(gameID, players[0], "n1", 0, 0); err != ErrDuplicateBuzz || b.PlayerID != first.PlayerID {
+		t.Fatalf("retry got %+v, %v, want the first buzz and ErrDuplicateBuzz", b, err)
+	}
+	if _, ok, err := m.RecordBuzz(gameID, players[1], "n1", 0, 0); err != nil || !ok {
+		t.Fatalf("another player's buzz with the same nonce got %v, %v", ok, err)
+	}
+
+	// a nonce is forgotten once it's older than buzzNonceTTL
+	m.mu.Lock()
+	g := m.games[gameID]
+	seen := g.nonces[players[0]]["n1"]
+	seen.At = seen.At.Add(-buzzNonceTTL - time.Second)
+	g.nonces[players[0]]["n1"] = seen
+	m.mu.Unlock()
+	if _, dup := m.DuplicateBuzz(gameID, players[0], "n1"); dup {
+		t.Fatal("expired nonce still counted as a duplicate")
+	}
+	if _, dup := m.DuplicateBuzz(gameID, players[1], "n1"); !dup {
+		t.Fatal("another player's nonce forgotten along with it")
+	}
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+// BuzzLogEntry is every buzz a game received, counted or not, in the order
+// the server received them. it's what settles "I buzzed first" disputes.
+//
+// with cfg.BuzzLogChain on each entry carries the hash of the one before it
+// and its own hash, the hex SHA-256 of
+//
+//	n|at|playerID|questionID|round|result|position|prev
+//
+// with at in RFC 3339 with nanoseconds in UTC, so an entry edited, dropped
+// or slipped in afterwards breaks the chain from there on.
+type BuzzLogEntry struct {
+	// N counts the game's buzzes from 1
+	N int `json:"n"`
+	// At is when the server received the buzz
+	At         time.Time `json:"at"`
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Team       string    `json:"team,omitempty"`
+	QuestionID int       `json:"questionID"`
+	Round      int       `json:"round,omitempty"`
+	// Result is how the buzz was answered, see buzzResponse
+	Result string `json:"result"`
+	// Position is the buzz's place in the buzz order when it was counted
+	Position       int     `json:"position,omitempty"`
+	DeltaMs        float64 `json:"deltaMs,omitempty"`
+	CompensationMs float64 `json:"compensationMs,omitempty"`
+	Nonce          string  `json:"nonce,omitempty"`
+	Prev           string  `json:"prev,omitempty"`
+	Hash           string  `json:"hash,omitempty"`
+	// MonotonicMs is how long after the game started At was, see
+	// SinceStart. it isn't part of the hash, a restart changes its clock
+	MonotonicMs float64 `json:"monotonicMs,omitempty"`
+}
+
+// Digest returns the entry's hash, chained to the one before it.
+func (e BuzzLogEntry) Digest() string {
+	line := fmt.Sprintf("%d|%s|%s|%d|%d|%s|%d|%s",
+		e.N, e.At.UTC().Format(time.RFC3339Nano), e.PlayerID, e.QuestionID, e.Round, e.Result, e.Position, e.Prev)
+	sum := sha256.Sum256([]byte(line))
+	return hex.EncodeToString(sum[:])
+}
+
+// LogBuzz appends a player's buzz to a game's buzz log. b is the buzz as
+// counted, if it was, and received is when it arrived otherwise. entries
+// are numbered and chained under m.mu, so the log is written in order.
+func (m *Manager) LogBuzz(gameID, playerID, nonce, result string, b Buzz, received time.Time) {
+	if m.cfg.HistoryRetention <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return
+	}
+	e := BuzzLogEntry{
+		At:         received,
+		PlayerID:   playerID,
+		QuestionID: g.question,
+		Round:      g.currentRound(),
+		Result:     result,
+		Nonce:      nonce,
+	}
+	if p, ok := g.players[playerID]; ok {
+		e.PlayerName, e.Team = p.Name, p.Team
+	}
+	if !b.At.IsZero() {
+		e.At = b.At
+		e.QuestionID, e.Round = b.QuestionID, b.Round
+		e.Position, e.DeltaMs, e.CompensationMs = b.Position, b.DeltaMs, b.CompensationMs
+	}
+
+	e.MonotonicMs = SinceStart(g.startedAt, e.At)
+
+	g.buzzLogged++
+	e.N = g.buzzLogged
+	if m.cfg.BuzzLogChain {
+		e.Prev = g.buzzLogHead
+		e.Hash = e.Digest()
+		g.buzzLogHead = e.Hash
+	}
+	m.save(g)
+
+	if err := m.store.AppendBuzzLog(gameID, []BuzzLogEntry{e}); err != nil {
+		log.Printf("failed to log buzz for game %s: %v", gameID, err)
+	}
+}
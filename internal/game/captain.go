@@ -0,0 +1,190 @@
+package game
+
+import (
+	"errors"
+)
+
+var (
+	ErrNoCaptains    = errors.New("this game doesn't have team captains")
+	ErrNotCaptain    = errors.New("only your team's captain can do that")
+	ErrNotOnTeam     = errors.New("that player isn't on the team")
+	ErrNoTeamRequest = errors.New("that player hasn't asked to join your team")
+)
+
+// captain returns who captains a team: the player the host made captain,
+// while they're still on it, otherwise whoever has been on it longest. it
+// must be called with m.mu held.
+func (g *game) captain(team string) string {
+	if team == "" {
+		return ""
+	}
+	if id := g.captains[team]; id != "" {
+		if p, ok := g.players[id]; ok && p.Team == team {
+			return id
+		}
+	}
+	var first *Player
+	for _, p := range g.players {
+		if p.Team != team {
+			continue
+		}
+		if first == nil || p.JoinedAt.Before(first.JoinedAt) ||
+			(p.JoinedAt.Equal(first.JoinedAt) && p.PlayerID < first.PlayerID) {
+			first = p
+		}
+	}
+	if first == nil {
+		return ""
+	}
+	return first.PlayerID
+}
+
+// joinTeam puts a player coming into a game on the team they picked,
+// unless the game has captains and the team already has one. then they
+// come in without a team, and their request waits for the captain to
+// accept it. it returns the team requested, if any. it must be called with
+// m.mu held, before p is one of g.players.
+func (g *game) joinTeam(p *Player) (requested string) {
+	if !g.options.Captains || g.captain(p.Team) == "" {
+		return ""
+	}
+	requested, p.Team = p.Team, ""
+	if g.teamRequests == nil {
+		g.teamRequests = map[string]string{}
+	}
+	g.teamRequests[p.PlayerID] = requested
+	return requested
+}
+
+// asCaptain returns the player acting as captain, failing unless the game
+// has captains and they're their team's. it must be called with m.mu held.
+func (g *game) asCaptain(playerID string) (*Player, error) {
+	if !g.options.Captains {
+		return nil, ErrNoCaptains
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return nil, ErrPlayerNotFound
+	}
+	if p.Team == "" || g.captain(p.Team) != playerID {
+		return nil, ErrNotCaptain
+	}
+	return p, nil
+}
+
+// TeamRequest returns the team a player asked to join and who captains it,
+// if they're still waiting to be accepted.
+func (m *Manager) TeamRequest(gameID, playerID string) (team, captain string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return "", ""
+	}
+	team = g.teamRequests[playerID]
+	return team, g.captain(team)
+}
+
+// AppointCaptain makes a player their team's captain, in place of whoever
+// was.
+func (m *Manager) AppointCaptain(gameID, team, playerID string) (Player, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Player{}, ErrGameNotFound
+	}
+	if !g.options.Captains {
+		return Player{}, ErrNoCaptains
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return Player{}, ErrPlayerNotFound
+	}
+	if p.Team != team {
+		return Player{}, ErrNotOnTeam
+	}
+	if g.captains == nil {
+		g.captains = map[string]string{}
+	}
+	g.captains[team] = playerID
+	m.save(g)
+	return *p, nil
+}
+
+// AcceptMember puts a player who asked to join the captain's team on it.
+func (m *Manager) AcceptMember(gameID, captainID, playerID string) (Player, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Player{}, ErrGameNotFound
+	}
+	c, err := g.asCaptain(captainID)
+	if err != nil {
+		return Player{}, err
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return Player{}, ErrPlayerNotFound
+	}
+	if g.teamRequests[playerID] != c.Team {
+		return Player{}, ErrNoTeamRequest
+	}
+	delete(g.teamRequests, playerID)
+	p.Team = c.Team
+	m.save(g)
+	return *p, nil
+}
+
+// RemoveMember takes a player off the captain's team. they stay in the
+// game, without a team. captains can't remove themselves.
+func (m *Manager) RemoveMember(gameID, captainID, playerID string) (Player, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Player{}, ErrGameNotFound
+	}
+	c, err := g.asCaptain(captainID)
+	if err != nil {
+		return Player{}, err
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return Player{}, ErrPlayerNotFound
+	}
+	if p.Team != c.Team || playerID == captainID {
+		return Player{}, ErrNotOnTeam
+	}
+	removed := *p
+	p.Team = ""
+	m.save(g)
+	return removed, nil
+}
+
+// RequestTimeout counts a timeout asked for by a captain, returning them
+// and how many their team has asked for.
+func (m *Manager) RequestTimeout(gameID, captainID string) (Player, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Player{}, 0, ErrGameNotFound
+	}
+	c, err := g.asCaptain(captainID)
+	if err != nil {
+		return Player{}, 0, err
+	}
+	if g.timeouts == nil {
+		g.timeouts = map[string]int{}
+	}
+	g.timeouts[c.Team]++
+	m.save(g)
+	return *c, g.timeouts[c.Team], nil
+}
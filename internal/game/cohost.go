@@ -0,0 +1,124 @@
+package game
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// HostConn is one host stream open on a game. a game can be hosted from
+// several at once, co-hosts or the host on both a phone and a laptop, and
+// each gets every host event.
+type HostConn struct {
+	ID string `json:"id"`
+	// ClientID is what the host's app calls itself, the same every time it
+	// reconnects. it's missing for apps that don't say
+	ClientID string `json:"clientID,omitempty"`
+	// Label is what the host called the stream, e.g. "phone"
+	Label       string    `json:"label,omitempty"`
+	ConnectedAt time.Time `json:"connectedAt"`
+}
+
+// AddHost opens a host stream on a game. catchUp is the seq the stream
+// should be caught up from, or -1 if another host is already connected and
+// it starts live. a stream opened under the clientID of one that's still
+// open replaces it, closing the old one. without a clientID the stream
+// never replaces another.
+func (m *Manager) AddHost(gameID, clientID, label string) (q *transport.Queue, conn HostConn, catchUp int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, HostConn{}, 0, ErrGameNotFound
+	}
+
+	catchUp = -1
+	if len(g.hosts) == 0 {
+		catchUp = g.hostsLeftSeq
+	}
+
+	g.hostCount++
+	conn = HostConn{ID: "h" + strconv.Itoa(g.hostCount), ClientID: clientID, Label: label, ConnectedAt: time.Now()}
+	if clientID == "" {
+		// conn.IDs are never reused, so nothing else subscribes under it
+		clientID = conn.ID
+	}
+	q, old := g.hostSubs.Subscribe(m.queues, clientID)
+	delete(g.hosts, old)
+	g.hosts[q] = &conn
+	return q, conn, catchUp, nil
+}
+
+// RemoveHost closes a host stream. written is the last seq the stream got
+// out to its host, the next host to connect is caught up from there if
+// this was the game's only one, or from now when it's 0. removed is false
+// if the stream was already gone, replaced by its client reconnecting.
+// last is true if it was the game's only one.
+func (m *Manager) RemoveHost(gameID string, q *transport.Queue, written int) (removed, last bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return false, false
+	}
+	conn, ok := g.hosts[q]
+	if !ok {
+		return false, false
+	}
+	delete(g.hosts, q)
+	clientID := conn.ClientID
+	if clientID == "" {
+		clientID = conn.ID
+	}
+	g.hostSubs.Unsubscribe(clientID, q)
+	if len(g.hosts) > 0 {
+		return true, false
+	}
+	g.hostsLeftSeq = g.seq
+	if written > 0 {
+		g.hostsLeftSeq = written
+	}
+	return true, true
+}
+
+// Hosts returns the host streams open on a game, oldest first.
+func (m *Manager) Hosts(gameID string) ([]HostConn, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, false
+	}
+	hosts := make([]HostConn, 0, len(g.hosts))
+	for _, conn := range g.hosts {
+		hosts = append(hosts, *conn)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].ConnectedAt.Before(hosts[j].ConnectedAt) })
+	return hosts, true
+}
+
+// HostQueues returns the queues feeding a game's host streams.
+func (m *Manager) HostQueues(gameID string) []*transport.Queue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+	return g.hostQueues()
+}
+
+// hostQueues lists g's host queues, it must be called with m.mu held.
+func (g *game) hostQueues() []*transport.Queue {
+	queues := make([]*transport.Queue, 0, len(g.hosts))
+	for q := range g.hosts {
+		queues = append(queues, q)
+	}
+	return queues
+}
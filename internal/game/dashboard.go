@@ -0,0 +1,47 @@
+package game
+
+import (
+	"crypto/subtle"
+
+	"bzzz/internal/transport"
+)
+
+// WatchGame feeds a game's events to a dashboard queue, as long as the game
+// is still the one created with hostToken and its code hasn't been reused.
+func (m *Manager) WatchGame(gameID, hostToken string, q *transport.Queue) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok || subtle.ConstantTimeCompare([]byte(g.hostToken), []byte(hostToken)) != 1 {
+		return false
+	}
+	g.dashboards[q] = true
+	return true
+}
+
+// UnwatchGames stops feeding every game's events to a dashboard queue.
+func (m *Manager) UnwatchGames(q *transport.Queue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, g := range m.games {
+		delete(g.dashboards, q)
+	}
+}
+
+// Dashboards returns the dashboard queues watching a game.
+func (m *Manager) Dashboards(gameID string) []*transport.Queue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+	qs := make([]*transport.Queue, 0, len(g.dashboards))
+	for q := range g.dashboards {
+		qs = append(qs, q)
+	}
+	return qs
+}
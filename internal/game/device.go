@@ -0,0 +1,115 @@
+package game
+
+import (
+	"errors"
+	"sort"
+)
+
+// hardware buzzers, e.g. an ESP32 with a big button, connect over plain TCP
+// to cfg.DeviceAddr and talk a line protocol, one command per line and one
+// reply line for each:
+//
+//	HELLO <deviceID> [key]       OK <deviceID>
+//	BUZZ [deviceID [nonce]]      OK <result> [position] or ERR <code> <message>
+//	PING                         PONG
+//
+// HELLO names the device the connection is for, BUZZ without a device ID
+// buzzes as that one. a box with several buttons can send each button's
+// own ID on one connection instead. when cfg.DeviceKey is set HELLO has to
+// come first and carry it. a device buzzes as whichever player the host
+// paired its ID with, see HostPairDeviceHandler, and everything after that
+// goes the way it would for the player's phone.
+
+var (
+	ErrDeviceTaken    = errors.New("device is paired with another game")
+	ErrDeviceNotFound = errors.New("device isn't paired")
+)
+
+// Device is a hardware buzzer paired with a player.
+type Device struct {
+	DeviceID   string `json:"deviceID"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	// Connected is true while the device has a connection open to this
+	// replica. the manager doesn't know, it's filled in by whoever asked
+	Connected bool `json:"connected"`
+}
+
+// PairDevice pairs a device with a player, replacing whoever it was paired
+// with in the game. a device can only be paired in one game at a time.
+func (m *Manager) PairDevice(gameID, deviceID, playerID string) (Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Device{}, ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return Device{}, ErrPlayerNotFound
+	}
+	for id, other := range m.games {
+		if _, paired := other.devices[deviceID]; paired && id != gameID {
+			return Device{}, ErrDeviceTaken
+		}
+	}
+
+	if g.devices == nil {
+		g.devices = map[string]string{}
+	}
+	g.devices[deviceID] = playerID
+	m.save(g)
+	return Device{DeviceID: deviceID, PlayerID: playerID, PlayerName: p.Name}, nil
+}
+
+// UnpairDevice forgets a device's pairing.
+func (m *Manager) UnpairDevice(gameID, deviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ErrGameNotFound
+	}
+	if _, ok := g.devices[deviceID]; !ok {
+		return ErrDeviceNotFound
+	}
+	delete(g.devices, deviceID)
+	m.save(g)
+	return nil
+}
+
+// Devices lists the devices paired in a game, by ID.
+func (m *Manager) Devices(gameID string) ([]Device, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	list := make([]Device, 0, len(g.devices))
+	for deviceID, playerID := range g.devices {
+		d := Device{DeviceID: deviceID, PlayerID: playerID}
+		if p, ok := g.players[playerID]; ok {
+			d.PlayerName = p.Name
+		}
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DeviceID < list[j].DeviceID })
+	return list, nil
+}
+
+// DevicePlayer returns the game and player a device is paired with.
+func (m *Manager) DevicePlayer(deviceID string) (string, string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for gameID, g := range m.games {
+		if playerID, ok := g.devices[deviceID]; ok {
+			return gameID, playerID, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,70 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrEarlyBuzz = errors.New("the buzzers aren't armed yet, wait for the host")
+
+// EarlyBuzz tells the host a player buzzed in an ArmedBuzzing game before
+// the buzzers were armed
+const EarlyBuzz = "early_buzz"
+
+// EarlyBuzzEvent is the data of an early_buzz event.
+type EarlyBuzzEvent struct {
+	QuestionID int `json:"questionID"`
+	// Count is how many early buzzes the player has made this game
+	Count int `json:"count"`
+	// Penalty is the score entry charging the player for it, if the
+	// game's scoring rules cost early buzzes anything
+	Penalty *ScoreEntry `json:"penalty,omitempty"`
+}
+
+// EarlyBuzz counts an early buzz against a player, charging them what the
+// game's scoring rules say it costs.
+func (m *Manager) EarlyBuzz(gameID, playerID string) (EarlyBuzzEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return EarlyBuzzEvent{}, ErrGameNotFound
+	}
+	if _, ok := g.players[playerID]; !ok {
+		return EarlyBuzzEvent{}, ErrPlayerNotFound
+	}
+
+	if g.earlyBuzzes == nil {
+		g.earlyBuzzes = map[string]int{}
+	}
+	g.earlyBuzzes[playerID]++
+	e := EarlyBuzzEvent{QuestionID: g.question, Count: g.earlyBuzzes[playerID]}
+
+	rules := g.options.Scoring
+	if points := rules.floored(g.playerScore(playerID), -rules.EarlyBuzz); points != 0 {
+		penalty := ScoreEntry{
+			ID:         len(g.scores) + 1,
+			Kind:       ScoreKindAdjustment,
+			PlayerID:   playerID,
+			Points:     points,
+			Reason:     EarlyBuzz,
+			At:         time.Now(),
+			QuestionID: g.question,
+			Round:      g.currentRound(),
+		}
+		g.scores = append(g.scores, penalty)
+		e.Penalty = &penalty
+	}
+	m.save(g)
+	return e, nil
+}
+
+// Armed reports whether an ArmedBuzzing game is taking buzzes.
+func (m *Manager) Armed(gameID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	return ok && g.armed
+}
@@ -0,0 +1,45 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrGameEnded = errors.New("game has ended")
+
+// End marks a game as ended, after which it turns away joins, buzzes and
+// score changes until it's torn down.
+func (m *Manager) End(gameID string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return time.Time{}, ErrGameNotFound
+	}
+	if !g.endedAt.IsZero() {
+		return time.Time{}, ErrGameEnded
+	}
+
+	g.endedAt = time.Now()
+	m.save(g)
+	return g.endedAt, nil
+}
+
+// Ended reports whether a game has ended.
+func (m *Manager) Ended(gameID string) bool {
+	_, ok := m.EndedAt(gameID)
+	return ok
+}
+
+// EndedAt returns when a game ended. ok is false unless it has.
+func (m *Manager) EndedAt(gameID string) (endedAt time.Time, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.endedAt.IsZero() {
+		return time.Time{}, false
+	}
+	return g.endedAt, true
+}
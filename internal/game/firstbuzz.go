@@ -0,0 +1,76 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrLateBuzz = errors.New("another player already won this question")
+
+// lateBuzz checks a buzz against the winner of a first buzz wins question.
+// ok is false unless the game is in that mode and the question has been
+// won, in which case b carries how far behind the winner the buzz landed.
+// first is true the first time a player buzzes late for the question so
+// mashing the button only reaches the host once, and only then is the buzz
+// kept for the question's almost report. a late buzz pressed within window
+// of the winner is tied with it. it must be called with m.mu held.
+func (g *game) lateBuzz(p *Player, now time.Time, window time.Duration) (b Buzz, first, ok bool) {
+	if !g.options.FirstBuzzWins || len(g.buzzes) == 0 {
+		return Buzz{}, false, false
+	}
+
+	b = Buzz{
+		PlayerID:   p.PlayerID,
+		PlayerName: p.Name,
+		Seat:       p.Seat,
+		Team:       p.Team,
+		Color:      p.Color,
+		QuestionID: g.question,
+		Round:      g.currentRound(),
+		At:         now,
+		DeltaMs:    float64(now.Sub(g.buzzes[0].At)) / float64(time.Millisecond),
+	}
+	// a late buzz tied with the winner has lost all the same, the
+	// winner already has the floor, but the host gets to know
+	if tied(b, g.buzzes[0], window) {
+		if g.buzzes[0].Tie == 0 {
+			g.buzzes[0].Tie = 1
+		}
+		b.Tie = g.buzzes[0].Tie
+	}
+	first = !g.late[p.PlayerID]
+	g.late[p.PlayerID] = true
+	if first {
+		g.noteAlmost(b)
+	}
+	if first && g.options.RecordLateBuzzes {
+		b.Position = len(g.buzzes) + 1
+		b.Late = true
+		g.buzzes = append(g.buzzes, b)
+		g.buzzed[p.PlayerID] = true
+	}
+	return b, first, true
+}
+
+// LateBuzz reports a buzz arriving after a first buzz wins question was
+// won, see game.lateBuzz.
+func (m *Manager) LateBuzz(gameID, playerID string) (b Buzz, first, ok bool) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists {
+		return Buzz{}, false, false
+	}
+	p, exists := g.players[playerID]
+	if !exists {
+		return Buzz{}, false, false
+	}
+	b, first, ok = g.lateBuzz(p, now, m.cfg.TieWindow)
+	if ok && first {
+		m.save(g)
+	}
+	return b, first, ok
+}
@@ -0,0 +1,1015 @@
+// Package game is the game engine: games, their players and buzzes, and
+// the Manager every change to them goes through. it knows nothing about
+// HTTP, events reach clients through the transport queues it's given and
+// games are kept in whatever Store it's handed.
+package game
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bzzz/config"
+	"bzzz/internal/transport"
+)
+
+var (
+	ErrGameNotFound   = errors.New("game not found")
+	ErrPlayerNotFound = errors.New("player not found")
+	ErrCodeTaken      = errors.New("game code is taken")
+)
+
+type Player struct {
+	GameID      string
+	PlayerID    string
+	Name        string
+	ConnectedAt time.Time
+	Connected   bool
+	// LeftAt is when the player's last stream closed
+	LeftAt time.Time
+	Seat   int
+	Team   string
+	// Avatar is the emoji or short text the player picked to go with their
+	// name, Color the one they were given so displays can tell them apart
+	Avatar string
+	Color  string
+	// Token is the secret the player reconnects with
+	Token    string
+	JoinedAt time.Time
+}
+
+// GameOptions are chosen by the host when a game is created.
+type GameOptions struct {
+	Locale       string
+	OrphanPolicy string
+	// MaxDuration ends the game automatically once it has run this long,
+	// zero means no limit
+	MaxDuration time.Duration
+	// TeamLockout stops the rest of a team buzzing once one member has
+	// buzzed for the current question
+	TeamLockout bool
+	// Tenant is who the game's usage is metered against
+	Tenant string
+	// Recognition is how players get the floor, first to buzz by default or
+	// RecognitionRoundRobin
+	Recognition string
+	// TurnTimeout passes a round robin turn on if its player hasn't buzzed
+	// in time, zero means turns never time out
+	TurnTimeout time.Duration
+	// FirstBuzzWins locks the buzzers as soon as anyone buzzes, turning
+	// away everyone after them
+	FirstBuzzWins bool
+	// ArmedBuzzing only takes buzzes while the host has the buzzers armed,
+	// turning away anyone buzzing ahead while the question is being read
+	ArmedBuzzing bool
+	// RecordLateBuzzes keeps the buzzes FirstBuzzWins turns away in the
+	// question's buzz order, flagged late
+	RecordLateBuzzes bool
+	// MaxPlayers caps how many players can join, zero means no limit
+	MaxPlayers int
+	// BuzzCooldown is how long a player has to wait between buzzes
+	BuzzCooldown time.Duration
+	// AlmostWindow is how long after the winner of a first buzz wins
+	// question late buzzes count as close calls, reported to the host as
+	// buzz_almost. zero turns the report off
+	AlmostWindow time.Duration
+	// Teams were set up before anyone joined, by a template, and are
+	// listed even while nobody is on them
+	Teams []string
+	// SoloMode turns teams off, team names given on join are ignored and
+	// team lockout doesn't apply
+	SoloMode bool
+	// LatencyCompensation pings players to measure their round trip time
+	// and orders buzzes as if each was pressed half of it earlier
+	LatencyCompensation bool
+	// WaitingRoom puts players joining a full game in line for a slot
+	// instead of turning them away
+	WaitingRoom bool
+	// Webhooks are the game's own webhook endpoints, given by the host
+	// when it was created, on top of its tenant's
+	Webhooks []WebhookEndpoint
+	// Scoring is how the host's verdicts turn into points
+	Scoring ScoringRules
+	// Public games are listed for anyone looking for a game to join, under
+	// Name
+	Public bool
+	Name   string
+	// Captains gives each team a captain, who answers for the team, asks
+	// for timeouts and decides who else is on it, see captain.go
+	Captains bool
+	// Tiebreak is how buzzes pressed too close together to tell apart are
+	// ordered, arrival order when empty, see tiebreak.go. in first buzz
+	// wins games the first to arrive already has the floor, so ties are
+	// only flagged
+	Tiebreak string
+}
+
+// game is the server side state of a single game. it is only ever touched
+// with the Manager's lock held.
+type game struct {
+	id        string
+	options   GameOptions
+	startedAt time.Time
+	seq       int
+	backup    string
+
+	// hosts are the host streams open on the game, see HostConn
+	hosts     map[*transport.Queue]*HostConn
+	hostCount int
+	// hostSubs are the host streams by client ID, so a host coming back
+	// replaces its old stream
+	hostSubs transport.Subscriptions
+	// hostsLeftSeq is the last seq the last host stream to go away got
+	// out, a host connecting to a game nobody is hosting is caught up from
+	// there
+	hostsLeftSeq int
+	// clients are the player streams, by player ID
+	clients transport.Subscriptions
+	// polled is when each player long polling for events without a
+	// stream is due back with their next poll
+	polled map[string]time.Time
+	// previews get a copy of everything sent to players
+	previews map[*transport.Queue]bool
+	// spectators are told whenever the game's state changes
+	spectators map[*transport.Queue]bool
+	// dashboards are the dashboards of the host account that created the
+	// game, see HostDashboardHandler
+	dashboards map[*transport.Queue]bool
+	players    map[string]*Player
+
+	buzzes []Buzz
+	buzzed map[string]bool
+	// lastBuzzAt is when each player last buzzed, for the buzz cooldown
+	lastBuzzAt map[string]time.Time
+	locked     bool
+	// lockedPlayers and lockedTeams can't buzz while everyone else can,
+	// until the host unlocks them or the question moves on
+	lockedPlayers map[string]bool
+	lockedTeams   map[string]bool
+	// late is who has buzzed after the winner of a first buzz wins question
+	late map[string]bool
+	// almost is the late buzzes within the almost window of the winner,
+	// waiting to be reported to the host
+	almost []Buzz
+	// armedAt is when the host armed the buzzers for the current question,
+	// reactions holds every buzz made while armed
+	armedAt   time.Time
+	reactions []Buzz
+
+	// armed is whether an ArmedBuzzing game is taking buzzes, from the host
+	// arming the buzzers until they're locked or the question moves on.
+	// earlyBuzzes counts each player's buzzes outside of that
+	armed       bool
+	earlyBuzzes map[string]int
+
+	// undo is the host's actions that can still be undone, the most recent
+	// last
+	undo []UndoEntry
+
+	// state is the lifecycle state last announced, see TransitionState
+	state string
+
+	// captains are the captains the host picked, by team. teamRequests are
+	// the teams players asked to join, by player, waiting on the captain,
+	// and timeouts how many each team has asked for
+	captains     map[string]string
+	teamRequests map[string]string
+	timeouts     map[string]int
+
+	// soundcheck is who has buzzed during a practice round, nil when there
+	// isn't one
+	soundcheck map[string]bool
+	// practice is how each player's buzzes have gone in practice mode, nil
+	// when it's off
+	practice map[string]*practiceDevice
+
+	// scores is the game's append only score ledger
+	scores []ScoreEntry
+	// answers submitted or judged, for every question
+	answers []Answer
+
+	// rounds played so far, the last one is in progress if roundActive
+	rounds      []Round
+	roundActive bool
+
+	// lastActive is when anything last happened in the game
+	lastActive time.Time
+
+	// hostToken is the secret that controls the game
+	hostToken string
+	// promoted is the player asked to take over from a lost host, their
+	// player token works as a host token
+	promoted string
+	// recoveryPIN is the hash of the PIN that can take the game over with a
+	// new host token, empty if the host didn't pick one.
+	// recoveryAttempts counts the wrong PINs since the last transfer
+	recoveryPIN      string
+	recoveryAttempts int
+	// waiting is the game's waiting room, in the order players joined it
+	waiting []*waitingPlayer
+	// wager is the wager round in play or last played, nil before the
+	// first
+	wager *WagerRound
+	// allPlay is the all-play round in play or last played, nil before
+	// the first
+	allPlay *AllPlayRound
+	// board is the category board, nil unless the host has set one up
+	board *boardState
+	// devices maps the ID of each hardware buzzer paired in the game to
+	// its player
+	devices map[string]string
+	// buzzLogged counts the buzzes in the game's buzz log, buzzLogHead is
+	// the hash of the latest
+	buzzLogged  int
+	buzzLogHead string
+	// questions loaded for the game, in the order they'll be asked
+	questions []Question
+	// shown is the ID of the loaded question last pushed to players
+	shown int
+
+	// timer counts the countdowns started, timerEndsAt is when the running
+	// one ends, zero when none is
+	timer         int
+	timerEndsAt   time.Time
+	timerAutoLock bool
+	// pausedAt is when the host paused the game, zero unless it's paused.
+	// pausedTimer is what was left of the countdown the pause stopped,
+	// which locks the buzzers when it runs out if pausedAutoLock
+	pausedAt       time.Time
+	pausedTimer    time.Duration
+	pausedAutoLock bool
+	// endedAt is when the game was ended, zero while it's still going. an
+	// ended game lingers showing its results until it's torn down
+	endedAt time.Time
+	// question is the ID of the question being asked, counting from 1
+	question int
+	// stats are each player's tallies from the questions already cleared
+	stats map[string]*statTally
+
+	// nonces maps playerID -> nonce -> recent buzz, for spotting the same
+	// buzz arriving more than once
+	nonces map[string]map[string]seenBuzz
+
+	// banned holds the names (see banKey) and tokens of kicked players who
+	// can't rejoin
+	banned map[string]bool
+
+	// turnPlayer holds the turn in round robin games, turn counts the turns
+	// handed out so far
+	turnPlayer string
+	turn       int
+}
+
+// Manager owns every game and player. all game and player lifecycle
+// operations go through it so handlers and the broadcaster never share
+// unsynchronized state.
+//
+// each game is played on one replica, and requests have to be routed to it
+// by game code. another replica only reads a game from the store, see Load,
+// when it doesn't hold it, such as after the one playing it went away. two
+// replicas holding the same game don't see each other's changes.
+type Manager struct {
+	mu    sync.RWMutex
+	games map[string]*game
+	store Store
+	// saver writes games to store, see save
+	saver *gameSaver
+
+	// players maps playerID -> gameID
+	players map[string]string
+
+	gameIDs   IDGenerator
+	playerIDs IDGenerator
+
+	// cfg is the settings games run under
+	cfg *config.Config
+	// queues makes the queues of the games' streams
+	queues *transport.Queues
+}
+
+// NewManager returns an empty Manager running games under c, that
+// persists them to store, draws new game and player IDs from the given
+// generators and makes its streams' queues with queues.
+func NewManager(c *config.Config, store Store, gameIDs, playerIDs IDGenerator, queues *transport.Queues) *Manager {
+	return &Manager{
+		games:     map[string]*game{},
+		players:   map[string]string{},
+		store:     store,
+		saver:     newGameSaver(store),
+		gameIDs:   gameIDs,
+		playerIDs: playerIDs,
+		cfg:       c,
+		queues:    queues,
+	}
+}
+
+// newID draws IDs from gen until the store accepts one as unused in space.
+// it must be called with m.mu held.
+func (m *Manager) newID(gen IDGenerator, space string) (string, error) {
+	attempts := int64(idAttempts)
+	if b, ok := gen.(boundedIDs); ok {
+		// this only counts what this replica holds, the store has the
+		// final say
+		used := len(m.games)
+		if space == playerIDSpace {
+			used = len(m.players)
+		}
+		free := b.size() - int64(used)
+		if free <= 0 {
+			log.Printf("every one of the %d ids is in use", b.size())
+			return "", ErrIDSpaceFull
+		}
+		// draw more as the space fills up, so finding a free ID stays about
+		// as likely as in an empty one
+		if n := idAttempts * b.size() / free; n > attempts {
+			attempts = n
+		}
+		if attempts > maxIDAttempts {
+			attempts = maxIDAttempts
+		}
+	}
+
+	for attempt := int64(0); attempt < attempts; attempt++ {
+		id, err := gen.NewID()
+		if err != nil {
+			return "", err
+		}
+		ok, err := m.store.ReserveID(space, id)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return id, nil
+		}
+	}
+	return "", ErrIDExhausted
+}
+
+// record snapshots a game for the store.
+func (g *game) record() GameRecord {
+	rec := GameRecord{
+		ID:         g.id,
+		Options:    g.options,
+		StartedAt:  g.startedAt,
+		Seq:        g.seq,
+		Backup:     g.backup,
+		Locked:     g.locked,
+		Players:    make([]Player, 0, len(g.players)),
+		Buzzes:     append([]Buzz{}, g.buzzes...),
+		Scores:     append([]ScoreEntry{}, g.scores...),
+		Answers:    append([]Answer{}, g.answers...),
+		Rounds:     append([]Round{}, g.rounds...),
+		InRound:    g.roundActive,
+		LastActive: g.lastActive,
+		HostToken:  g.hostToken,
+		Promoted:   g.promoted,
+		Questions:  append([]Question{}, g.questions...),
+		Shown:      g.shown,
+		Question:   g.question,
+		Reactions:  append([]Buzz{}, g.reactions...),
+		TurnPlayer: g.turnPlayer,
+		Turn:       g.turn,
+	}
+	for _, p := range g.players {
+		rec.Players = append(rec.Players, *p)
+	}
+	// in the order they joined, so a store keeping a row per player only
+	// has to write the ones that changed
+	sort.Slice(rec.Players, func(i, j int) bool {
+		a, b := rec.Players[i], rec.Players[j]
+		if !a.JoinedAt.Equal(b.JoinedAt) {
+			return a.JoinedAt.Before(b.JoinedAt)
+		}
+		return a.PlayerID < b.PlayerID
+	})
+	for key := range g.banned {
+		rec.Banned = append(rec.Banned, key)
+	}
+	rec.RecoveryPIN, rec.RecoveryAttempts = g.recoveryPIN, g.recoveryAttempts
+	for _, w := range g.waiting {
+		rec.Waiting = append(rec.Waiting, w.Player)
+	}
+	rec.BuzzLogged, rec.BuzzLogHead = g.buzzLogged, g.buzzLogHead
+	if !g.pausedAt.IsZero() {
+		pausedAt := g.pausedAt
+		rec.PausedAt = &pausedAt
+		rec.PausedTimerMs, rec.PausedAutoLock = g.pausedTimer.Milliseconds(), g.pausedAutoLock
+	}
+	if !g.endedAt.IsZero() {
+		endedAt := g.endedAt
+		rec.EndedAt = &endedAt
+	}
+	rec.LockedPlayers, rec.LockedTeams = sortedKeys(g.lockedPlayers), sortedKeys(g.lockedTeams)
+	// the record is written after m.mu is let go, so it mustn't share
+	// anything the game changes in place
+	rec.Options.Teams = append([]string(nil), g.options.Teams...)
+	rec.Options.Webhooks = append([]WebhookEndpoint(nil), g.options.Webhooks...)
+	rec.Armed, rec.EarlyBuzzes = g.armed, copyCounts(g.earlyBuzzes)
+	rec.Undo = append([]UndoEntry(nil), g.undo...)
+	rec.Captains, rec.TeamRequests, rec.Timeouts = copyStrings(g.captains), copyStrings(g.teamRequests), copyCounts(g.timeouts)
+	rec.State = g.state
+	if g.wager != nil {
+		w := g.wager.copy()
+		rec.Wager = &w
+	}
+	if g.allPlay != nil {
+		a := g.allPlay.copy()
+		rec.AllPlay = &a
+	}
+	if g.board != nil {
+		b := g.board.copy()
+		rec.Board = &b
+	}
+	if len(g.devices) > 0 {
+		rec.Devices = make(map[string]string, len(g.devices))
+		for deviceID, playerID := range g.devices {
+			rec.Devices[deviceID] = playerID
+		}
+	}
+	if len(g.stats) > 0 {
+		rec.Stats = make(map[string]statTally, len(g.stats))
+		for playerID, t := range g.stats {
+			rec.Stats[playerID] = *t
+		}
+	}
+	for playerID, seen := range g.nonces {
+		for nonce, s := range seen {
+			if time.Since(s.At) > buzzNonceTTL {
+				continue
+			}
+			if rec.Nonces == nil {
+				rec.Nonces = map[string]seenBuzz{}
+			}
+			rec.Nonces[playerID+"|"+nonce] = s
+		}
+	}
+	return rec
+}
+
+// copyStrings returns a copy of m, nil if it's empty.
+func copyStrings(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// copyCounts returns a copy of m, nil if it's empty.
+func copyCounts(m map[string]int) map[string]int {
+	if len(m) == 0 {
+		return nil
+	}
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// save queues a game to be written to the store, see gameSaver. it must be
+// called with m.mu held so saves for a game are queued in the order its
+// state changed.
+func (m *Manager) save(g *game) {
+	m.saver.save(g.record())
+}
+
+// Close writes everything still to be written to the store.
+func (m *Manager) Close() {
+	m.saver.Close()
+}
+
+// Restore loads every stored game back into memory and returns their IDs.
+// players come back disconnected and pick their streams up again with
+// ?token= when they reconnect.
+func (m *Manager) Restore() ([]string, error) {
+	recs, err := m.store.LoadGames()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		m.restore(rec)
+		ids = append(ids, rec.ID)
+	}
+
+	log.Printf("restored %d games", len(ids))
+	return ids, nil
+}
+
+// Load reads a game this replica doesn't hold in from the store, such as
+// one the replica that was playing it left behind. it returns true only if
+// it was this call that read the game in.
+func (m *Manager) Load(gameID string) (bool, error) {
+	if gameID == "" || m.Exists(gameID) {
+		return false, nil
+	}
+	// read off the lock, a slow store mustn't hold up every other game
+	rec, ok, err := m.store.LoadGame(gameID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.games[gameID]; ok {
+		return false, nil
+	}
+	// a game that just ended here is still on its way out of the store
+	if m.saver.busy(gameID) {
+		return false, nil
+	}
+	m.restore(rec)
+	log.Printf("loaded game %s from the store", gameID)
+	return true, nil
+}
+
+// restore puts a stored game back in memory. players come back
+// disconnected. m.mu must be held.
+func (m *Manager) restore(rec GameRecord) {
+	g := &game{
+		id:           rec.ID,
+		options:      rec.Options,
+		startedAt:    rec.StartedAt,
+		seq:          rec.Seq,
+		backup:       rec.Backup,
+		locked:       rec.Locked,
+		hosts:        map[*transport.Queue]*HostConn{},
+		hostSubs:     transport.Subscriptions{},
+		hostsLeftSeq: rec.Seq,
+		clients:      transport.Subscriptions{},
+		polled:       map[string]time.Time{},
+		previews:     map[*transport.Queue]bool{},
+		spectators:   map[*transport.Queue]bool{},
+		dashboards:   map[*transport.Queue]bool{},
+		players:      map[string]*Player{},
+		buzzes:       rec.Buzzes,
+		buzzed:       map[string]bool{},
+		lastBuzzAt:   map[string]time.Time{},
+		late:         map[string]bool{},
+		nonces:       map[string]map[string]seenBuzz{},
+		scores:       rec.Scores,
+		answers:      rec.Answers,
+		rounds:       rec.Rounds,
+		roundActive:  rec.InRound,
+		lastActive:   rec.LastActive,
+		hostToken:    rec.HostToken,
+		promoted:     rec.Promoted,
+		questions:    rec.Questions,
+		shown:        rec.Shown,
+		banned:       map[string]bool{},
+		question:     rec.Question,
+		reactions:    rec.Reactions,
+		turnPlayer:   rec.TurnPlayer,
+		turn:         rec.Turn,
+		stats:        map[string]*statTally{},
+	}
+	for _, key := range rec.Banned {
+		g.banned[key] = true
+	}
+	g.recoveryPIN, g.recoveryAttempts = rec.RecoveryPIN, rec.RecoveryAttempts
+	for _, p := range rec.Waiting {
+		g.wait(p)
+	}
+	for key, seen := range rec.Nonces {
+		if i := strings.Index(key, "|"); i > 0 {
+			g.rememberBuzz(key[:i], key[i+1:], seen)
+		}
+	}
+	g.wager = rec.Wager
+	g.allPlay = rec.AllPlay
+	g.board = rec.Board
+	g.devices = rec.Devices
+	for playerID, t := range rec.Stats {
+		t := t
+		g.stats[playerID] = &t
+	}
+	g.buzzLogged, g.buzzLogHead = rec.BuzzLogged, rec.BuzzLogHead
+	if rec.PausedAt != nil {
+		g.pausedAt = *rec.PausedAt
+		g.pausedTimer, g.pausedAutoLock = time.Duration(rec.PausedTimerMs)*time.Millisecond, rec.PausedAutoLock
+	}
+	if rec.EndedAt != nil {
+		g.endedAt = *rec.EndedAt
+	}
+	for _, playerID := range rec.LockedPlayers {
+		g.lockTarget(playerID, "", true)
+	}
+	for _, team := range rec.LockedTeams {
+		g.lockTarget("", team, true)
+	}
+	g.armed, g.earlyBuzzes = rec.Armed, rec.EarlyBuzzes
+	g.undo = rec.Undo
+	g.captains, g.teamRequests, g.timeouts = rec.Captains, rec.TeamRequests, rec.Timeouts
+	g.state = rec.State
+	if g.question == 0 {
+		g.question = 1
+	}
+	if g.lastActive.IsZero() {
+		g.touch()
+	}
+	for i := range rec.Players {
+		p := rec.Players[i]
+		p.Connected = false
+		g.players[p.PlayerID] = &p
+		m.players[p.PlayerID] = rec.ID
+	}
+	for _, b := range rec.Buzzes {
+		g.buzzed[b.PlayerID] = true
+	}
+
+	m.games[rec.ID] = g
+	// the saver doesn't know what the store has yet, so the first
+	// save writes the whole game
+	m.save(g)
+}
+
+// CreateGame sets up a new game instance and returns its game code. the
+// code is code if one is asked for, otherwise a fresh one from the game ID
+// strategy.
+func (m *Manager) CreateGame(opts GameOptions, code string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cfg.MaxGames > 0 && len(m.games) >= m.cfg.MaxGames {
+		return "", ErrAtGameLimit
+	}
+
+	gameCode := code
+	if code == "" {
+		var err error
+		if gameCode, err = m.newID(m.gameIDs, GameIDSpace); err != nil {
+			return "", err
+		}
+	} else if ok, err := m.store.ReserveID(GameIDSpace, code); err != nil {
+		return "", err
+	} else if !ok {
+		return "", ErrCodeTaken
+	}
+	hostToken, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	g := &game{
+		id:         gameCode,
+		options:    opts,
+		hostToken:  hostToken,
+		question:   1,
+		startedAt:  time.Now(),
+		hosts:      map[*transport.Queue]*HostConn{},
+		hostSubs:   transport.Subscriptions{},
+		clients:    transport.Subscriptions{},
+		polled:     map[string]time.Time{},
+		previews:   map[*transport.Queue]bool{},
+		spectators: map[*transport.Queue]bool{},
+		dashboards: map[*transport.Queue]bool{},
+		players:    map[string]*Player{},
+		buzzed:     map[string]bool{},
+		lastBuzzAt: map[string]time.Time{},
+		late:       map[string]bool{},
+		nonces:     map[string]map[string]seenBuzz{},
+		banned:     map[string]bool{},
+		stats:      map[string]*statTally{},
+	}
+	g.touch()
+	m.games[gameCode] = g
+	m.save(g)
+
+	log.Printf("creating game: %s", gameCode)
+	return gameCode, nil
+}
+
+// HostToken returns the secret that controls a game.
+func (m *Manager) HostToken(gameID string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return "", false
+	}
+	return g.hostToken, true
+}
+
+// Exists reports whether a game is running.
+func (m *Manager) Exists(gameID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.games[gameID]
+	return ok
+}
+
+// Options returns the options a game was created with.
+func (m *Manager) Options(gameID string) (GameOptions, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return GameOptions{}, false
+	}
+	return g.options, true
+}
+
+// StartedAt returns when a game was created.
+func (m *Manager) StartedAt(gameID string) time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return time.Time{}
+	}
+	return g.startedAt
+}
+
+// Remove tears a game down and forgets its players, returning their IDs.
+func (m *Manager) Remove(gameID string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+
+	ids := make([]string, 0, len(g.players))
+	for playerID := range g.players {
+		delete(m.players, playerID)
+		ids = append(ids, playerID)
+	}
+	// nobody is getting a slot now, let the waiting room go
+	for _, w := range g.waiting {
+		close(w.admitted)
+		ids = append(ids, w.PlayerID)
+	}
+	g.closeQueues()
+	delete(m.games, gameID)
+
+	// the IDs are only free once the game is gone from the store, or a
+	// new game under the same code could be deleted along with it
+	released := append([]string(nil), ids...)
+	m.saver.delete(gameID, func() {
+		if err := m.store.ReleaseIDs(playerIDSpace, released...); err != nil {
+			log.Printf("failed to release player ids for game %s: %v", gameID, err)
+		}
+		if err := m.store.ReleaseIDs(GameIDSpace, gameID); err != nil {
+			log.Printf("failed to release id for game %s: %v", gameID, err)
+		}
+	})
+	return ids
+}
+
+// Saving reports whether a game still has changes on their way to the
+// store, or is still being deleted from it.
+func (m *Manager) Saving(gameID string) bool {
+	return m.saver.busy(gameID)
+}
+
+// closeQueues closes every stream queue the game still owns, so streams
+// still open on it finish. it must be called with m.mu held.
+func (g *game) closeQueues() {
+	for _, subs := range []transport.Subscriptions{g.hostSubs, g.clients} {
+		for clientID, q := range subs {
+			subs.Unsubscribe(clientID, q)
+		}
+	}
+	for _, set := range []map[*transport.Queue]bool{g.spectators, g.previews} {
+		for q := range set {
+			delete(set, q)
+			q.Close()
+		}
+	}
+}
+
+// Join adds a new player to a game, on the given team if team isn't empty,
+// and gives them a color. if the game is full and has a waiting room, the
+// player is put in line for a slot and returned along with ErrWaiting.
+func (m *Manager) Join(gameID, name, team, avatar string) (Player, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Player{}, ErrGameNotFound
+	}
+	if !g.endedAt.IsZero() {
+		return Player{}, ErrGameEnded
+	}
+	if g.banned[banKey(name)] {
+		return Player{}, ErrBanned
+	}
+	if g.nameTaken(name) {
+		return Player{}, ErrNameTaken
+	}
+	full := g.full()
+	if full && !g.options.WaitingRoom {
+		return Player{}, ErrGameFull
+	}
+	if g.options.SoloMode {
+		team = ""
+	}
+
+	playerID, err := m.newID(m.playerIDs, playerIDSpace)
+	if err != nil {
+		return Player{}, err
+	}
+	token, err := NewToken()
+	if err != nil {
+		return Player{}, err
+	}
+
+	p := &Player{
+		GameID:   gameID,
+		PlayerID: playerID,
+		Name:     name,
+		Team:     team,
+		Avatar:   avatar,
+		Color:    g.pickColor(),
+		Token:    token,
+		JoinedAt: time.Now(),
+	}
+	if full {
+		g.wait(*p)
+		m.save(g)
+		return *p, ErrWaiting
+	}
+	g.joinTeam(p)
+	g.players[playerID] = p
+	m.players[playerID] = gameID
+	m.save(g)
+	return *p, nil
+}
+
+// Connect marks a player as connected and gives them a fresh queue for
+// their stream, replacing any previous one. arrival is how the host should
+// hear about it, "joined" for the player's first stream, PlayerRejoined
+// when they come back after leaving, or empty when the stream replaces one
+// that's still open.
+func (m *Manager) Connect(gameID, playerID string) (q *transport.Queue, arrival string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, "", ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return nil, "", ErrPlayerNotFound
+	}
+
+	switch {
+	case p.ConnectedAt.IsZero():
+		arrival = "joined"
+	case !p.Connected:
+		arrival = PlayerRejoined
+	}
+
+	p.ConnectedAt = time.Now()
+	g.touch()
+	p.Connected = true
+
+	// a stream left over from before is replaced and stopped
+	q, _ = g.clients.Subscribe(m.queues, playerID)
+	return q, arrival, nil
+}
+
+// Disconnect marks a player as gone and closes their queue, unless they have
+// already reconnected with a newer stream than q. it reports whether the
+// player went offline.
+func (m *Manager) Disconnect(playerID string, q *transport.Queue) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[m.players[playerID]]
+	if !ok || !g.clients.Unsubscribe(playerID, q) {
+		return false
+	}
+	p := g.players[playerID]
+	p.Connected = false
+	p.LeftAt = time.Now()
+	return true
+}
+
+// Player looks up a player by ID.
+func (m *Manager) Player(playerID string) (Player, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[m.players[playerID]]
+	if !ok {
+		return Player{}, false
+	}
+	return *g.players[playerID], true
+}
+
+// PlayerInGame reports whether a player belongs to a game.
+func (m *Manager) PlayerInGame(gameID, playerID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	gid, ok := m.players[playerID]
+	return ok && gid == gameID
+}
+
+// Players returns every player in a game.
+func (m *Manager) Players(gameID string) []Player {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+
+	list := make([]Player, 0, len(g.players))
+	for _, p := range g.players {
+		list = append(list, *p)
+	}
+	return list
+}
+
+// PlayerCount returns how many players have joined a game.
+func (m *Manager) PlayerCount(gameID string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0
+	}
+	return len(g.players)
+}
+
+// SetBackup designates the player promoted to host if the host is lost.
+func (m *Manager) SetBackup(gameID, playerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ErrGameNotFound
+	}
+	if _, ok := g.players[playerID]; !ok {
+		return ErrPlayerNotFound
+	}
+	g.backup = playerID
+	m.save(g)
+	return nil
+}
+
+// NextSeq assigns the next sequence number for an event in a game. it
+// returns 0 for games that no longer exist.
+func (m *Manager) NextSeq(gameID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0
+	}
+	g.seq++
+	g.touch()
+	// nothing else changes with a sequence number, so that's all that's
+	// written
+	m.saver.saveSeq(g.id, g.seq, g.lastActive)
+	return g.seq
+}
+
+// Recipients returns every player in a game, the queues of the players
+// currently connected keyed by playerID, and the host streams' queues.
+func (m *Manager) Recipients(gameID string) ([]string, map[string]*transport.Queue, []*transport.Queue) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	ids := make([]string, 0, len(g.players))
+	clients := map[string]*transport.Queue{}
+	for playerID, p := range g.players {
+		ids = append(ids, playerID)
+		// players connected by long polling have no queue, they pick
+		// events up from their outbox
+		if q, ok := g.clients[playerID]; ok && p.Connected {
+			clients[playerID] = q
+		}
+	}
+	return ids, clients, g.hostQueues()
+}
@@ -0,0 +1,36 @@
+package game
+
+import (
+	"crypto/subtle"
+)
+
+// IsHost reports whether token controls a game. that's the game's host
+// token, or the player token of whoever was last promoted to host.
+func (m *Manager) IsHost(gameID, token string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok || token == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(g.hostToken), []byte(token)) == 1 {
+		return true
+	}
+	if p, ok := g.players[g.promoted]; ok {
+		return subtle.ConstantTimeCompare([]byte(p.Token), []byte(token)) == 1
+	}
+	return false
+}
+
+// SetPromoted records the player who has been asked to take over hosting a
+// game.
+func (m *Manager) SetPromoted(gameID, playerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if g, ok := m.games[gameID]; ok {
+		g.promoted = playerID
+		m.save(g)
+	}
+}
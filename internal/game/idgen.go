@@ -0,0 +1,241 @@
+package game
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"bzzz/config"
+)
+
+// idAttempts is how many fresh IDs are tried before giving up on finding one
+// nobody has. more are tried when a bounded ID space is filling up, up to
+// maxIDAttempts.
+const (
+	idAttempts    = 10
+	maxIDAttempts = 1000
+)
+
+var (
+	ErrIDExhausted = errors.New("couldn't find an unused id, try again")
+	ErrIDSpaceFull = errors.New("every id is in use, try again once a game has ended")
+)
+
+// ID namespaces. game and player IDs are reserved separately, so players
+// joining don't use up game codes and a game code can't clash with a
+// player ID.
+const (
+	GameIDSpace   = "game"
+	playerIDSpace = "player"
+)
+
+// IDGenerator makes new game or player IDs. IDs don't have to be unique on
+// their own, the Manager reserves each one with the store and asks for
+// another on a collision.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// boundedIDs is an IDGenerator with few enough IDs that they can all be
+// taken. size is how many there are.
+type boundedIDs interface {
+	size() int64
+}
+
+// ID generation strategies, picked separately for games and players
+var idStrategies = map[string]func(c *config.Config) IDGenerator{
+	// numeric is a code from the configured game code range, 6 digits by
+	// default, that's easy to read out and type
+	"numeric": func(c *config.Config) IDGenerator { return numericIDs{min: c.GameCodeMin, max: c.GameCodeMax} },
+	// alphanumeric is a code like "K7QM2X", 6 characters by default, without
+	// the letters and digits that are easy to mix up
+	"alphanumeric": func(c *config.Config) IDGenerator { return alphanumericIDs{length: c.GameCodeLength} },
+	// words is a code like "brave-otter-42" that's easy to say out loud
+	"words": func(*config.Config) IDGenerator { return wordIDs{} },
+	// ulid is a sortable 26 character ID, meant for IDs nobody has to type
+	"ulid": func(*config.Config) IDGenerator { return ULIDs{} },
+	// uuid is a random 36 character UUID, for integrations that expect one
+	"uuid": func(*config.Config) IDGenerator { return uuidIDs{} },
+}
+
+// NewIDGenerator picks the strategy called name for IDs of kind, configured
+// from c.
+func NewIDGenerator(c *config.Config, name, kind string) (IDGenerator, error) {
+	strategy, ok := idStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown id strategy %q for %s ids", name, kind)
+	}
+	return strategy(c), nil
+}
+
+// randInt returns a uniformly random int in [0, n) from crypto/rand.
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// NewToken returns a random secret, e.g. the token a player reconnects with.
+func NewToken() (string, error) {
+	return RandomHex(24)
+}
+
+// RandomHex returns n random bytes from crypto/rand, hex encoded.
+func RandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type numericIDs struct {
+	min, max int
+}
+
+func (g numericIDs) size() int64 {
+	return int64(g.max - g.min + 1)
+}
+
+func (g numericIDs) NewID() (string, error) {
+	n, err := randInt(g.max - g.min + 1)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(g.min + n), nil
+}
+
+// codeAlphabet is crockford without 0, 1 and the letters that look like
+// them, so codes read out in a noisy room survive being typed in
+const codeAlphabet = "23456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type alphanumericIDs struct {
+	length int
+}
+
+func (g alphanumericIDs) size() int64 {
+	n := int64(1)
+	for i := 0; i < g.length; i++ {
+		if n > math.MaxInt64/int64(len(codeAlphabet)) {
+			return math.MaxInt64
+		}
+		n *= int64(len(codeAlphabet))
+	}
+	return n
+}
+
+func (g alphanumericIDs) NewID() (string, error) {
+	b := make([]byte, g.length)
+	for i := range b {
+		n, err := randInt(len(codeAlphabet))
+		if err != nil {
+			return "", err
+		}
+		b[i] = codeAlphabet[n]
+	}
+	return string(b), nil
+}
+
+var (
+	idAdjectives = []string{
+		"able", "bold", "brave", "brisk", "calm", "clever", "cool", "crisp",
+		"daring", "eager", "fair", "fancy", "fast", "fierce", "fine", "fresh",
+		"gentle", "glad", "golden", "grand", "happy", "hardy", "jolly", "keen",
+		"kind", "lively", "lucky", "merry", "mighty", "neat", "noble", "plucky",
+		"polite", "proud", "quick", "quiet", "rapid", "ready", "rosy", "royal",
+		"shiny", "silly", "sly", "smart", "snappy", "spry", "steady", "sunny",
+		"super", "swift", "tidy", "tough", "vivid", "warm", "wild", "wise",
+		"witty", "zany", "zesty", "zippy", "jazzy", "peppy", "perky", "sleek",
+	}
+	idNouns = []string{
+		"badger", "beaver", "bison", "camel", "cobra", "coyote", "crane", "crow",
+		"dingo", "eagle", "falcon", "ferret", "finch", "fox", "gecko", "goose",
+		"heron", "hippo", "hornet", "husky", "ibis", "iguana", "jackal", "koala",
+		"lemur", "llama", "lynx", "magpie", "marmot", "moose", "newt", "otter",
+		"owl", "panda", "parrot", "pelican", "puffin", "python", "quail", "rabbit",
+		"raven", "robin", "salmon", "seal", "shark", "sloth", "spider", "squid",
+		"stork", "swan", "tapir", "tiger", "toucan", "trout", "turtle", "viper",
+		"walrus", "weasel", "whale", "wolf", "wombat", "yak", "zebra", "moth",
+	}
+)
+
+type wordIDs struct{}
+
+func (wordIDs) size() int64 {
+	return int64(len(idAdjectives) * len(idNouns) * 100)
+}
+
+func (wordIDs) NewID() (string, error) {
+	a, err := randInt(len(idAdjectives))
+	if err != nil {
+		return "", err
+	}
+	n, err := randInt(len(idNouns))
+	if err != nil {
+		return "", err
+	}
+	d, err := randInt(100)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s-%02d", idAdjectives[a], idNouns[n], d), nil
+}
+
+// crockford is the base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type ULIDs struct{}
+
+// NewID returns a ULID: 48 bits of millisecond timestamp followed by 80
+// random bits, encoded as 26 characters of Crockford base32.
+func (ULIDs) NewID() (string, error) {
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(b[:6], ts[2:])
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	// 128 bits don't divide into 5 bit groups, the leading group gets the
+	// 3 bits left over
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var sb strings.Builder
+	for i := 25; i >= 0; i-- {
+		shift := uint(i * 5)
+		var v uint64
+		switch {
+		case shift >= 64:
+			v = hi >> (shift - 64)
+		case shift > 59:
+			v = lo>>shift | hi<<(64-shift)
+		default:
+			v = lo >> shift
+		}
+		sb.WriteByte(crockford[v&0x1f])
+	}
+	return sb.String(), nil
+}
+
+type uuidIDs struct{}
+
+// NewID returns a random, version 4, UUID.
+func (uuidIDs) NewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,176 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// scriptedIDs hands out ids in order, repeating the last one.
+type scriptedIDs struct {
+	mu   sync.Mutex
+	ids  []string
+	used int
+}
+
+func (g *scriptedIDs) NewID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.used++
+	if len(g.ids) > 1 {
+		id := g.ids[0]
+		g.ids = g.ids[1:]
+		return id, nil
+	}
+	return g.ids[0], nil
+}
+
+func TestNewIDRetriesCollisions(t *testing.T) {
+	store := newMemStore()
+	store.ReserveID(GameIDSpace, "AAAA")
+	gen := &scriptedIDs{ids: []string{"AAAA", "AAAA", "AAAA", "BBBB"}}
+	m := newTestManager(t, store, gen, ULIDs{})
+
+	code, err := m.CreateGame(GameOptions{}, "")
+	if err != nil {
+		t.Fatalf("failed to create game: %v", err)
+	}
+	if code != "BBBB" {
+		t.Fatalf("got code %s, want BBBB", code)
+	}
+	if gen.used != 4 {
+		t.Fatalf("drew %d ids, want 4", gen.used)
+	}
+}
+
+func TestNewIDExhausted(t *testing.T) {
+	store := newMemStore()
+	store.ReserveID(GameIDSpace, "AAAA")
+	gen := &scriptedIDs{ids: []string{"AAAA"}}
+	m := newTestManager(t, store, gen, ULIDs{})
+
+	if _, err := m.CreateGame(GameOptions{}, ""); err != ErrIDExhausted {
+		t.Fatalf("got %v, want ErrIDExhausted", err)
+	}
+	if gen.used != idAttempts {
+		t.Fatalf("drew %d ids, want %d", gen.used, idAttempts)
+	}
+}
+
+// another replica holding every code is only found out from the store
+func TestNewIDExhaustedByOtherReplica(t *testing.T) {
+	store := newMemStore()
+	for n := 1; n <= 3; n++ {
+		store.ReserveID(GameIDSpace, strconv.Itoa(n))
+	}
+	m := newTestManager(t, store, numericIDs{min: 1, max: 3}, ULIDs{})
+
+	if _, err := m.CreateGame(GameOptions{}, ""); err != ErrIDExhausted {
+		t.Fatalf("got %v, want ErrIDExhausted", err)
+	}
+}
+
+func TestTinyGameIDSpace(t *testing.T) {
+	const size = 5
+	m := newTestManager(t, newMemStore(), numericIDs{min: 1, max: size}, ULIDs{})
+
+	codes := map[string]bool{}
+	for i := 0; i < size; i++ {
+		code, err := m.CreateGame(GameOptions{}, "")
+		if err != nil {
+			t.Fatalf("game %d: %v", i+1, err)
+		}
+		if codes[code] {
+			t.Fatalf("code %s handed out twice", code)
+		}
+		codes[code] = true
+	}
+	for n := 1; n <= size; n++ {
+		if !codes[strconv.Itoa(n)] {
+			t.Fatalf("code %d never handed out, got %v", n, codes)
+		}
+	}
+
+	if _, err := m.CreateGame(GameOptions{}, ""); err != ErrIDSpaceFull {
+		t.Fatalf("got %v, want ErrIDSpaceFull", err)
+	}
+
+	// a code is free again once its game is gone from the store
+	m.Remove("3")
+	waitSaved(t, m, "3")
+	code, err := m.CreateGame(GameOptions{}, "")
+	if err != nil {
+		t.Fatalf("failed to create game after one ended: %v", err)
+	}
+	if code != "3" {
+		t.Fatalf("got code %s, want the freed 3", code)
+	}
+}
+
+func TestTinyPlayerIDSpace(t *testing.T) {
+	const size = 3
+	m := newTestManager(t, newMemStore(), ULIDs{}, numericIDs{min: 1, max: size})
+	a, err := m.CreateGame(GameOptions{}, "")
+	if err != nil {
+		t.Fatalf("failed to create game: %v", err)
+	}
+	b, err := m.CreateGame(GameOptions{}, "")
+	if err != nil {
+		t.Fatalf("failed to create game: %v", err)
+	}
+
+	// player IDs are unique across games
+	ids := map[string]bool{}
+	for i, gameID := range []string{a, b, a} {
+		p, err := m.Join(gameID, fmt.Sprintf("player%d", i), "", "")
+		if err != nil {
+			t.Fatalf("player %d: %v", i, err)
+		}
+		if ids[p.PlayerID] {
+			t.Fatalf("player id %s handed out twice", p.PlayerID)
+		}
+		ids[p.PlayerID] = true
+	}
+	if _, err := m.Join(b, "late", "", ""); err != ErrIDSpaceFull {
+		t.Fatalf("got %v, want ErrIDSpaceFull", err)
+	}
+}
+
+// concurrent creates never share a code, however crowded the space
+func TestTinyGameIDSpaceConcurrent(t *testing.T) {
+	const size = 20
+	m := newTestManager(t, newMemStore(), numericIDs{min: 1, max: size}, ULIDs{})
+
+	var mu sync.Mutex
+	codes := map[string]bool{}
+	var full int
+	var wg sync.WaitGroup
+	for i := 0; i < size+10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, err := m.CreateGame(GameOptions{}, "")
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				if codes[code] {
+					t.Errorf("code %s handed out twice", code)
+				}
+				codes[code] = true
+			case ErrIDSpaceFull:
+				full++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(codes) != size || full != 10 {
+		t.Fatalf("got %d games and %d refused, want %d and 10", len(codes), full, size)
+	}
+}
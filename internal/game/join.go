@@ -0,0 +1,22 @@
+package game
+
+import (
+	"crypto/subtle"
+)
+
+// PlayerByToken finds the player in a game holding token.
+func (m *Manager) PlayerByToken(gameID, token string) (Player, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok || token == "" {
+		return Player{}, false
+	}
+	for _, p := range g.players {
+		if subtle.ConstantTimeCompare([]byte(p.Token), []byte(token)) == 1 {
+			return *p, true
+		}
+	}
+	return Player{}, false
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"bzzz/internal/transport"
+)
+
+var ErrBanned = errors.New("banned from this game")
+
+// Kick removes a player from a game, returning them and the queue feeding
+// their stream if they had one. with ban set their name and token can't be
+// used to join the game again.
+func (m *Manager) Kick(gameID, playerID string, ban bool) (Player, *transport.Queue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Player{}, nil, ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return Player{}, nil, ErrPlayerNotFound
+	}
+	q := g.clients[playerID]
+
+	delete(g.players, playerID)
+	delete(g.clients, playerID)
+	delete(g.buzzed, playerID)
+	delete(g.lastBuzzAt, playerID)
+	delete(g.teamRequests, playerID)
+	delete(m.players, playerID)
+	for deviceID, paired := range g.devices {
+		if paired == playerID {
+			delete(g.devices, deviceID)
+		}
+	}
+	if g.promoted == playerID {
+		g.promoted = ""
+	}
+	if ban {
+		g.banned[banKey(p.Name)] = true
+		g.banned[p.Token] = true
+	}
+	kicked := *p
+	g.pushUndo(UndoEntry{Action: undoKick, PlayerID: playerID, Kicked: &kicked, Banned: ban})
+	m.save(g)
+
+	if err := m.store.ReleaseIDs(playerIDSpace, playerID); err != nil {
+		log.Printf("failed to release id for player %s: %v", playerID, err)
+	}
+	return *p, q, nil
+}
+
+// Banned reports whether a name or token has been banned from a game.
+func (m *Manager) Banned(gameID, nameOrToken string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	return ok && nameOrToken != "" && (g.banned[banKey(nameOrToken)] || g.banned[nameOrToken])
+}
+
+// banKey is how a banned name is remembered, so changing its case doesn't
+// get around a ban.
+func banKey(name string) string {
+	return "name:" + strings.ToLower(strings.TrimSpace(name))
+}
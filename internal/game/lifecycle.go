@@ -0,0 +1,120 @@
+package game
+
+import (
+	"fmt"
+)
+
+// a game's lifecycle states. they're worked out from the game itself, see
+// lifecycle, so they can't drift from what the game is actually doing.
+const (
+	// stateLobby is before anything has been shown, armed, buzzed or
+	// scored
+	stateLobby = "lobby"
+	// stateArmed is the buzzers open with nobody buzzed in yet
+	stateArmed = "armed"
+	// stateAnswering is a player with the floor who hasn't answered yet
+	stateAnswering = "answering"
+	// stateJudging is an answer waiting on the host's verdict
+	stateJudging = "judging"
+	// stateIntermission is play stopped between questions or rounds, the
+	// buzzers locked or the question answered
+	stateIntermission = "intermission"
+	stateEnded        = "ended"
+)
+
+// stateTransitions are the moves a game can make between states. actions
+// that would make any other move are turned away with a TransitionError,
+// see checkTransition. one that gets through all the same is a bug
+// somewhere, it's logged to the game's timeline.
+var stateTransitions = map[string]map[string]bool{
+	stateLobby:        {stateArmed: true, stateAnswering: true, stateIntermission: true, stateEnded: true},
+	stateArmed:        {stateAnswering: true, stateIntermission: true, stateEnded: true},
+	stateAnswering:    {stateJudging: true, stateArmed: true, stateIntermission: true, stateEnded: true},
+	stateJudging:      {stateAnswering: true, stateArmed: true, stateIntermission: true, stateEnded: true},
+	stateIntermission: {stateArmed: true, stateAnswering: true, stateJudging: true, stateEnded: true},
+	stateEnded:        {},
+}
+
+// TransitionError is an action turned away because it would move the game
+// between states it can't move between, say arming an ended game.
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("the game can't go from %s to %s", e.From, e.To)
+}
+
+// checkTransition fails with a TransitionError unless the game can move
+// from the state it's in to to. it must be called with m.mu held.
+func (g *game) checkTransition(to string) error {
+	from := g.lifecycle()
+	if from == to && from != stateEnded || stateTransitions[from][to] {
+		return nil
+	}
+	return &TransitionError{From: from, To: to}
+}
+
+// lifecycle works out which state a game is in. it must be called with
+// m.mu held.
+func (g *game) lifecycle() string {
+	if !g.endedAt.IsZero() {
+		return stateEnded
+	}
+	if !g.started() && g.shown == 0 && g.armedAt.IsZero() {
+		return stateLobby
+	}
+	for _, a := range g.answers {
+		if a.QuestionID == g.question && a.Correct != nil && *a.Correct {
+			return stateIntermission
+		}
+	}
+	if floor, ok := g.floor(); ok {
+		if g.pendingAnswer(floor) >= 0 {
+			return stateJudging
+		}
+		return stateAnswering
+	}
+	if g.locked || (len(g.rounds) > 0 && !g.roundActive) || (g.options.ArmedBuzzing && !g.armed) {
+		return stateIntermission
+	}
+	return stateArmed
+}
+
+// State returns the lifecycle state a game is in.
+func (m *Manager) State(gameID string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ""
+	}
+	return g.lifecycle()
+}
+
+// TransitionState moves a game on to the state it's now in, returning the
+// state it was in before if that's changed. a move the game shouldn't have
+// been able to make is still made, it's what the game is doing, but comes
+// back as a TransitionError too. the state is saved with the game's next
+// sequence number.
+func (m *Manager) TransitionState(gameID string) (from, to string, changed bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return "", "", false, nil
+	}
+	from, to = g.state, g.lifecycle()
+	g.state = to
+
+	if from == to {
+		return from, to, false, nil
+	}
+	if from != "" && !stateTransitions[from][to] {
+		err = &TransitionError{From: from, To: to}
+	}
+	return from, to, from != "", err
+}
@@ -0,0 +1,9 @@
+package game
+
+import (
+	"errors"
+)
+
+// ErrAtGameLimit is creating a game on a replica already running
+// cfg.MaxGames.
+var ErrAtGameLimit = errors.New("this server is running as many games as it can")
@@ -0,0 +1,82 @@
+package game
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrBuzzerLocked = errors.New("the host has locked your buzzer")
+
+// lockTarget locks or unlocks a player's or a team's buzzers. it must be
+// called with m.mu held.
+func (g *game) lockTarget(playerID, team string, lock bool) {
+	if g.lockedPlayers == nil {
+		g.lockedPlayers, g.lockedTeams = map[string]bool{}, map[string]bool{}
+	}
+	switch {
+	case playerID != "" && lock:
+		g.lockedPlayers[playerID] = true
+	case playerID != "":
+		delete(g.lockedPlayers, playerID)
+	case lock:
+		g.lockedTeams[team] = true
+	default:
+		delete(g.lockedTeams, team)
+	}
+}
+
+// clearTargets unlocks every player and team locked on their own. it must
+// be called with m.mu held.
+func (g *game) clearTargets() {
+	g.lockedPlayers, g.lockedTeams = nil, nil
+}
+
+// targetLocked reports whether p, or their team, has been locked on their
+// own. it must be called with m.mu held.
+func (g *game) targetLocked(p *Player) bool {
+	return g.lockedPlayers[p.PlayerID] || (p.Team != "" && g.lockedTeams[p.Team])
+}
+
+// LockTarget locks or unlocks the buzzers of one player, or one team,
+// leaving everyone else's as they are. the lock lasts until the host
+// unlocks them, unlocks everyone or the question moves on.
+func (m *Manager) LockTarget(gameID, playerID, team string, lock bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ErrGameNotFound
+	}
+	if _, ok := g.players[playerID]; playerID != "" && !ok {
+		return ErrPlayerNotFound
+	}
+	g.lockTarget(playerID, team, lock)
+	m.save(g)
+	return nil
+}
+
+// LockedTargets returns the players and teams locked out on their own.
+func (m *Manager) LockedTargets(gameID string) (players, teams []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, nil
+	}
+	return sortedKeys(g.lockedPlayers), sortedKeys(g.lockedTeams)
+}
+
+// sortedKeys lists a set's members in order, nil when it's empty.
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,73 @@
+package game
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"bzzz/config"
+	"bzzz/internal/transport"
+)
+
+// memStore is a Store keeping only the IDs reserved in it.
+type memStore struct {
+	mu       sync.Mutex
+	reserved map[string]map[string]bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{reserved: map[string]map[string]bool{}}
+}
+
+func (s *memStore) SaveGame(GameRecord, *GameRecord) error     { return nil }
+func (s *memStore) DeleteGame(string) error                    { return nil }
+func (s *memStore) LoadGames() ([]GameRecord, error)           { return nil, nil }
+func (s *memStore) LoadGame(string) (GameRecord, bool, error)  { return GameRecord{}, false, nil }
+func (s *memStore) AppendBuzzLog(string, []BuzzLogEntry) error { return nil }
+
+func (s *memStore) ReserveID(space, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reserved[space] == nil {
+		s.reserved[space] = map[string]bool{}
+	}
+	if s.reserved[space][id] {
+		return false, nil
+	}
+	s.reserved[space][id] = true
+	return true, nil
+}
+
+func (s *memStore) ReleaseIDs(space string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.reserved[space], id)
+	}
+	return nil
+}
+
+// newTestManager returns a Manager on a memStore, closed when the test
+// ends.
+func newTestManager(t *testing.T, store Store, gameIDs, playerIDs IDGenerator) *Manager {
+	t.Helper()
+
+	m := NewManager(config.Default(), store, gameIDs, playerIDs, &transport.Queues{})
+	t.Cleanup(m.Close)
+	return m
+}
+
+// waitSaved waits for everything queued for gameID to reach the store.
+func waitSaved(t *testing.T, m *Manager, gameID string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for m.Saving(gameID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("game %s never finished saving", gameID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
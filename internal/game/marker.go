@@ -0,0 +1,17 @@
+package game
+
+import (
+	"time"
+)
+
+// SinceStart returns how many milliseconds after a game started t was. it
+// goes by the server's monotonic clock, which unlike the wall clock never
+// jumps when it's adjusted, so footage synced against it lines up across
+// the whole game. a game picked up from the store after a restart has lost
+// its monotonic start, and those fall back to the wall clock.
+func SinceStart(startedAt, t time.Time) float64 {
+	if startedAt.IsZero() || t.IsZero() {
+		return 0
+	}
+	return float64(t.Sub(startedAt)) / float64(time.Millisecond)
+}
@@ -0,0 +1,62 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxPlayerNameLength caps player names, in characters, so they fit on the
+// host screen.
+const MaxPlayerNameLength = 24
+
+// ErrNameTaken is returned by Join when someone in the game, or waiting
+// for a slot in it, already goes by the name.
+var ErrNameTaken = errors.New("name taken")
+
+// nameKey is what two names have to share to count as the same, so
+// "alex" can't join alongside "Alex".
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// nameTaken reports whether a player in the game or its waiting room goes
+// by name. it must be called with m.mu held.
+func (g *game) nameTaken(name string) bool {
+	key := nameKey(name)
+	for _, p := range g.players {
+		if nameKey(p.Name) == key {
+			return true
+		}
+	}
+	for _, w := range g.waiting {
+		if nameKey(w.Name) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// AvailableName returns name if nobody in the game goes by it, otherwise
+// the first of "name (2)", "name (3)" and so on that's free, shortening
+// name to keep within MaxPlayerNameLength.
+func (m *Manager) AvailableName(gameID, name string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok || !g.nameTaken(name) {
+		return name
+	}
+	for n := 2; ; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		base := []rune(name)
+		if max := MaxPlayerNameLength - len(suffix); len(base) > max {
+			base = base[:max]
+		}
+		candidate := strings.TrimSpace(string(base)) + suffix
+		if !g.nameTaken(candidate) {
+			return candidate
+		}
+	}
+}
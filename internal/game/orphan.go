@@ -0,0 +1,35 @@
+package game
+
+import (
+	"time"
+)
+
+// PromotionCandidate picks who should take over a game whose host is lost:
+// the designated backup if they are connected, otherwise the longest
+// connected player. players in skip are never picked. it returns "" if
+// nobody is eligible.
+func (m *Manager) PromotionCandidate(gameID string, skip map[string]bool) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ""
+	}
+
+	if backup, ok := g.players[g.backup]; ok && backup.Connected && !skip[g.backup] {
+		return g.backup
+	}
+
+	candidate := ""
+	var longest time.Time
+	for playerID, p := range g.players {
+		if !p.Connected || skip[playerID] {
+			continue
+		}
+		if candidate == "" || p.ConnectedAt.Before(longest) {
+			candidate, longest = playerID, p.ConnectedAt
+		}
+	}
+	return candidate
+}
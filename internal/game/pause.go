@@ -0,0 +1,83 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrGamePaused    = errors.New("game is paused")
+	ErrNotPaused     = errors.New("game isn't paused")
+	ErrAlreadyPaused = errors.New("game is already paused")
+)
+
+// Pause freezes a game for an intermission: buzzes and score changes are
+// turned away until it's resumed. a running countdown is stopped, timerID
+// and left say which it was and how much of it there was to go.
+func (m *Manager) Pause(gameID string) (pausedAt time.Time, timerID int, left time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return time.Time{}, 0, 0, ErrGameNotFound
+	}
+	if !g.pausedAt.IsZero() {
+		return time.Time{}, 0, 0, ErrAlreadyPaused
+	}
+
+	g.pausedAt = time.Now()
+	g.pausedTimer, g.pausedAutoLock = 0, false
+	if !g.timerEndsAt.IsZero() {
+		timerID = g.timer
+		if left = g.timerEndsAt.Sub(g.pausedAt); left > 0 {
+			g.pausedTimer, g.pausedAutoLock = left, g.timerAutoLock
+		}
+		g.timerEndsAt = time.Time{}
+	}
+	m.save(g)
+	return g.pausedAt, timerID, g.pausedTimer, nil
+}
+
+// Resume picks a paused game back up. left is what was left of the
+// countdown stopped by the pause, if any, to be started again with
+// autoLock as it was.
+func (m *Manager) Resume(gameID string) (pausedAt time.Time, left time.Duration, autoLock bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return time.Time{}, 0, false, ErrGameNotFound
+	}
+	if g.pausedAt.IsZero() {
+		return time.Time{}, 0, false, ErrNotPaused
+	}
+
+	pausedAt, left, autoLock = g.pausedAt, g.pausedTimer, g.pausedAutoLock
+	g.pausedAt = time.Time{}
+	g.pausedTimer, g.pausedAutoLock = 0, false
+	m.save(g)
+	return pausedAt, left, autoLock, nil
+}
+
+// Paused reports whether a game is paused.
+func (m *Manager) Paused(gameID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	return ok && !g.pausedAt.IsZero()
+}
+
+// PausedAt returns when a game was paused. ok is false unless it is.
+func (m *Manager) PausedAt(gameID string) (pausedAt time.Time, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.pausedAt.IsZero() {
+		return time.Time{}, false
+	}
+	return g.pausedAt, true
+}
@@ -0,0 +1,197 @@
+package game
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// saveWorkers is how many games are written to the store at once.
+const saveWorkers = 4
+
+// gameSaver writes games to the store in the background, so nothing waits
+// on the store while holding the Manager's lock. a game is only ever
+// written by one worker at a time, and saves made while it's being written
+// are coalesced: the store gets the latest state, and is told what it had
+// before so it can write just what changed.
+type gameSaver struct {
+	store Store
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// games are the games the saver knows of, by ID
+	games map[string]*savedGame
+	// order is the games with something to write, oldest first
+	order  []string
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// savedGame is where a game's saves are up to.
+type savedGame struct {
+	// latest is the newest state of the game, written is what the store
+	// has, nil if it has nothing or it isn't known
+	latest  *GameRecord
+	written *GameRecord
+	// dirty is set while latest is still to be written, deleted when the
+	// game is to be deleted instead, after which after is run
+	dirty   bool
+	deleted bool
+	after   func()
+	writing bool
+}
+
+func newGameSaver(store Store) *gameSaver {
+	s := &gameSaver{store: store, games: map[string]*savedGame{}}
+	s.cond = sync.NewCond(&s.mu)
+	for i := 0; i < saveWorkers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// game returns where gameID's saves are up to. it must be called with s.mu
+// held.
+func (s *gameSaver) game(gameID string) *savedGame {
+	sg, ok := s.games[gameID]
+	if !ok {
+		sg = &savedGame{}
+		s.games[gameID] = sg
+	}
+	return sg
+}
+
+// queue marks a game as having something to write. it must be called with
+// s.mu held.
+func (s *gameSaver) queue(gameID string, sg *savedGame) {
+	if !sg.dirty && !sg.deleted {
+		s.order = append(s.order, gameID)
+	}
+	s.cond.Signal()
+}
+
+// save queues rec, the latest state of its game, to be written.
+func (s *gameSaver) save(rec GameRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg := s.game(rec.ID)
+	s.queue(rec.ID, sg)
+	sg.latest, sg.dirty = &rec, true
+	sg.deleted, sg.after = false, nil
+}
+
+// saveSeq queues a game's new sequence number, which is all that changes
+// with most events. it's a no-op for games the saver hasn't been handed.
+func (s *gameSaver) saveSeq(gameID string, seq int, lastActive time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg, ok := s.games[gameID]
+	if !ok || sg.latest == nil || sg.deleted {
+		return
+	}
+	rec := *sg.latest
+	rec.Seq, rec.LastActive = seq, lastActive
+	s.queue(gameID, sg)
+	sg.latest, sg.dirty = &rec, true
+}
+
+// delete queues a game to be deleted, dropping anything still to be
+// written of it. after, if given, is run once it's gone from the store.
+func (s *gameSaver) delete(gameID string, after func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg := s.game(gameID)
+	s.queue(gameID, sg)
+	sg.latest, sg.dirty = nil, false
+	sg.deleted, sg.after = true, after
+}
+
+// busy reports whether a game has anything still to be written, or is
+// being written.
+func (s *gameSaver) busy(gameID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg, ok := s.games[gameID]
+	return ok && (sg.dirty || sg.deleted || sg.writing)
+}
+
+// next takes the oldest game with something to write that isn't already
+// being written. it must be called with s.mu held.
+func (s *gameSaver) next() (string, *savedGame, bool) {
+	for i, gameID := range s.order {
+		sg := s.games[gameID]
+		if sg.writing {
+			continue
+		}
+		s.order = append(s.order[:i:i], s.order[i+1:]...)
+		return gameID, sg, true
+	}
+	return "", nil, false
+}
+
+// work writes games until the saver is closed and everything is written.
+func (s *gameSaver) work() {
+	defer s.wg.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		gameID, sg, ok := s.next()
+		if !ok {
+			if s.closed && len(s.order) == 0 {
+				return
+			}
+			s.cond.Wait()
+			continue
+		}
+
+		rec, since, deleted, after := sg.latest, sg.written, sg.deleted, sg.after
+		sg.dirty, sg.deleted, sg.after = false, false, nil
+		sg.writing = true
+		s.mu.Unlock()
+
+		var err error
+		if deleted {
+			err = s.store.DeleteGame(gameID)
+			if err != nil {
+				log.Printf("failed to delete game %s: %v", gameID, err)
+			}
+			if after != nil {
+				after()
+			}
+		} else if err = s.store.SaveGame(*rec, since); err != nil {
+			log.Printf("failed to save game %s: %v", gameID, err)
+		}
+
+		s.mu.Lock()
+		sg.writing = false
+		switch {
+		case deleted && !sg.dirty:
+			delete(s.games, gameID)
+		case deleted:
+			sg.written = nil
+		case err != nil:
+			// what the store has isn't known, the next save writes
+			// everything
+			sg.written = nil
+		default:
+			sg.written = rec
+		}
+		// another worker may be waiting for this game
+		s.cond.Broadcast()
+	}
+}
+
+// Close writes everything still to be written and stops the workers.
+func (s *gameSaver) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.wg.Wait()
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"time"
+)
+
+// pollGrace is how long after a poll ends a player has to poll again
+// before they count as gone
+const pollGrace = 15 * time.Second
+
+// Polled marks a player as connected over long polling until a little
+// after until, when their poll ends. arrival is what to tell the host, as
+// for Connect, if they weren't already connected.
+func (m *Manager) Polled(gameID, playerID string, until time.Time) (arrival string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return "", ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return "", ErrPlayerNotFound
+	}
+	g.touch()
+
+	// a player with a stream open is connected through that
+	if _, ok := g.clients[playerID]; ok {
+		return "", nil
+	}
+	switch {
+	case p.ConnectedAt.IsZero():
+		arrival = "joined"
+	case !p.Connected:
+		arrival = PlayerRejoined
+	}
+	if arrival != "" {
+		p.ConnectedAt = time.Now()
+		p.Connected = true
+	}
+	g.polled[playerID] = until
+	return arrival, nil
+}
+
+// StalePollers marks the players who stopped long polling as gone and
+// returns them.
+func (m *Manager) StalePollers() []Player {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var gone []Player
+	for _, g := range m.games {
+		for playerID, until := range g.polled {
+			if now.Before(until.Add(pollGrace)) {
+				continue
+			}
+			delete(g.polled, playerID)
+
+			p, ok := g.players[playerID]
+			if _, streaming := g.clients[playerID]; !ok || streaming {
+				continue
+			}
+			p.Connected = false
+			p.LeftAt = now
+			gone = append(gone, *p)
+		}
+	}
+	return gone
+}
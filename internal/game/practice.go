@@ -0,0 +1,150 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNoPractice  = errors.New("practice mode is off")
+	ErrGameStarted = errors.New("the game has already started")
+)
+
+// practiceDevice is how a player's practice buzzes have gone.
+type practiceDevice struct {
+	Buzzes     int
+	LastBuzzAt time.Time
+}
+
+// practicePlayer is a player's line on the practice checklist.
+type practicePlayer struct {
+	PlayerID   string     `json:"playerID"`
+	PlayerName string     `json:"playerName"`
+	Team       string     `json:"team,omitempty"`
+	Connected  bool       `json:"connected"`
+	Tested     bool       `json:"tested"`
+	Buzzes     int        `json:"buzzes"`
+	LastBuzzAt *time.Time `json:"lastBuzzAt,omitempty"`
+}
+
+// PracticeStatus is the host's checklist of which players have tested
+// their device during practice mode.
+type PracticeStatus struct {
+	Players []practicePlayer `json:"players"`
+	Tested  int              `json:"tested"`
+	// Complete is true once every connected player has buzzed
+	Complete bool `json:"complete"`
+}
+
+// PracticeBuzzEvent is the data of a practice_buzz event. only the host's
+// copy has the Checklist.
+type PracticeBuzzEvent struct {
+	PlayerID   string          `json:"playerID"`
+	PlayerName string          `json:"playerName"`
+	Buzzes     int             `json:"buzzes"`
+	At         time.Time       `json:"at"`
+	Checklist  *PracticeStatus `json:"checklist,omitempty"`
+}
+
+// started reports whether a game is under way, anything having been buzzed,
+// scored or played. it must be called with m.mu held.
+func (g *game) started() bool {
+	return g.question > 1 || len(g.buzzes) > 0 || len(g.scores) > 0 || len(g.rounds) > 0
+}
+
+// PracticeStatus must be called with m.mu held.
+func (g *game) practiceStatus() PracticeStatus {
+	s := PracticeStatus{Players: []practicePlayer{}, Complete: true}
+	for _, p := range g.players {
+		pp := practicePlayer{PlayerID: p.PlayerID, PlayerName: p.Name, Team: p.Team, Connected: p.Connected}
+		if d, ok := g.practice[p.PlayerID]; ok {
+			at := d.LastBuzzAt
+			pp.Tested, pp.Buzzes, pp.LastBuzzAt = true, d.Buzzes, &at
+			s.Tested++
+		} else if p.Connected {
+			s.Complete = false
+		}
+		s.Players = append(s.Players, pp)
+	}
+	sort.Slice(s.Players, func(i, j int) bool { return s.Players[i].PlayerName < s.Players[j].PlayerName })
+	return s
+}
+
+// StartPractice turns on practice mode for a game that hasn't started yet.
+// turning it on again keeps the checklist so far.
+func (m *Manager) StartPractice(gameID string) (PracticeStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return PracticeStatus{}, ErrGameNotFound
+	}
+	if g.practice == nil {
+		if g.started() {
+			return PracticeStatus{}, ErrGameStarted
+		}
+		g.practice = map[string]*practiceDevice{}
+	}
+	return g.practiceStatus(), nil
+}
+
+// PracticeBuzz ticks a player off the practice checklist. ok is false if
+// practice mode is off, in which case it's a real buzz.
+func (m *Manager) PracticeBuzz(gameID, playerID string, at time.Time) (e PracticeBuzzEvent, s PracticeStatus, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.practice == nil {
+		return PracticeBuzzEvent{}, PracticeStatus{}, false
+	}
+	p, exists := g.players[playerID]
+	if !exists {
+		return PracticeBuzzEvent{}, PracticeStatus{}, false
+	}
+	d, exists := g.practice[playerID]
+	if !exists {
+		d = &practiceDevice{}
+		g.practice[playerID] = d
+	}
+	d.Buzzes++
+	d.LastBuzzAt = at
+	g.touch()
+
+	e = PracticeBuzzEvent{PlayerID: playerID, PlayerName: p.Name, Buzzes: d.Buzzes, At: at}
+	return e, g.practiceStatus(), true
+}
+
+// Practice returns a game's practice checklist.
+func (m *Manager) Practice(gameID string) (PracticeStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return PracticeStatus{}, ErrGameNotFound
+	}
+	if g.practice == nil {
+		return PracticeStatus{}, ErrNoPractice
+	}
+	return g.practiceStatus(), nil
+}
+
+// EndPractice turns off practice mode, returning the final checklist.
+func (m *Manager) EndPractice(gameID string) (PracticeStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return PracticeStatus{}, ErrGameNotFound
+	}
+	if g.practice == nil {
+		return PracticeStatus{}, ErrNoPractice
+	}
+	s := g.practiceStatus()
+	g.practice = nil
+	return s, nil
+}
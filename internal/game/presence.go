@@ -0,0 +1,63 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// presence actions, sent to hosts as players' streams come and go. a
+// player's first stream is announced as "joined".
+const (
+	PlayerLeft     = "player_left"
+	PlayerRejoined = "player_rejoined"
+	// PlayerStalled is sent just before a player's stream is cut off for
+	// not reading what it's sent, see writeGuard
+	PlayerStalled = "player_stalled"
+)
+
+// Presence is whether a player has a stream open right now.
+type Presence struct {
+	PlayerID  string `json:"playerID"`
+	Name      string `json:"name"`
+	Seat      int    `json:"seat,omitempty"`
+	Team      string `json:"team,omitempty"`
+	Avatar    string `json:"avatar,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Connected bool   `json:"connected"`
+	// ConnectedAt is when the player's latest stream opened, LeftAt when
+	// it closed. both are missing for a player who has never had a stream
+	ConnectedAt *time.Time `json:"connectedAt,omitempty"`
+	LeftAt      *time.Time `json:"leftAt,omitempty"`
+}
+
+func PresenceOf(p Player) Presence {
+	pr := Presence{PlayerID: p.PlayerID, Name: p.Name, Seat: p.Seat, Team: p.Team, Avatar: p.Avatar, Color: p.Color, Connected: p.Connected}
+	if !p.ConnectedAt.IsZero() {
+		at := p.ConnectedAt
+		pr.ConnectedAt = &at
+	}
+	if !p.Connected && !p.LeftAt.IsZero() {
+		at := p.LeftAt
+		pr.LeftAt = &at
+	}
+	return pr
+}
+
+// Presence returns who is in a game and whether they're connected, sorted
+// by name.
+func (m *Manager) Presence(gameID string) ([]Presence, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, false
+	}
+
+	list := make([]Presence, 0, len(g.players))
+	for _, p := range g.players {
+		list = append(list, PresenceOf(*p))
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+	return list, true
+}
@@ -0,0 +1,47 @@
+package game
+
+import (
+	"bzzz/internal/transport"
+)
+
+// AddPreview registers a new queue that receives a copy of everything sent
+// to a game's players.
+func (m *Manager) AddPreview(gameID string) (*transport.Queue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	q := m.queues.NewQueue()
+	g.previews[q] = true
+	return q, nil
+}
+
+// RemovePreview stops feeding a preview queue and closes it.
+func (m *Manager) RemovePreview(gameID string, q *transport.Queue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if g, ok := m.games[gameID]; ok {
+		delete(g.previews, q)
+	}
+	q.Close()
+}
+
+// Previews returns the preview queues open on a game.
+func (m *Manager) Previews(gameID string) []*transport.Queue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+	qs := make([]*transport.Queue, 0, len(g.previews))
+	for q := range g.previews {
+		qs = append(qs, q)
+	}
+	return qs
+}
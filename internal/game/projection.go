@@ -0,0 +1,11 @@
+package game
+
+type RosterEntry struct {
+	PlayerID  string `json:"playerID"`
+	Name      string `json:"name"`
+	Seat      int    `json:"seat,omitempty"`
+	Team      string `json:"team,omitempty"`
+	Avatar    string `json:"avatar,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Connected bool   `json:"connected"`
+}
@@ -0,0 +1,66 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// public game statuses
+const (
+	// publicLobby games haven't got going yet
+	publicLobby = "lobby"
+	// publicPlaying games are under way, but still take players
+	publicPlaying = "playing"
+	// PublicPaused games are paused by their host or waiting for them to
+	// come back
+	PublicPaused = "paused"
+)
+
+// publicGame is how a public game is listed for players looking for one
+// to join.
+type publicGame struct {
+	GameID  string `json:"gameID"`
+	Name    string `json:"name,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+	Players int    `json:"players"`
+	// MaxPlayers is the game's player cap, missing when there isn't one
+	MaxPlayers int       `json:"maxPlayers,omitempty"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+// PublicGames lists the public games that can still be joined, newest
+// first: ones that haven't ended and have room, or a waiting room.
+func (m *Manager) PublicGames() []publicGame {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	games := []publicGame{}
+	for id, g := range m.games {
+		if !g.options.Public || !g.endedAt.IsZero() {
+			continue
+		}
+		if g.full() && !g.options.WaitingRoom {
+			continue
+		}
+
+		status := publicPlaying
+		switch {
+		case !g.pausedAt.IsZero():
+			status = PublicPaused
+		case g.question <= 1 && len(g.buzzes) == 0 && len(g.rounds) == 0 && len(g.scores) == 0:
+			status = publicLobby
+		}
+		games = append(games, publicGame{
+			GameID:     id,
+			Name:       g.options.Name,
+			Locale:     g.options.Locale,
+			Players:    len(g.players),
+			MaxPlayers: g.options.MaxPlayers,
+			Status:     status,
+			StartedAt:  g.startedAt,
+		})
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].StartedAt.After(games[j].StartedAt) })
+	return games
+}
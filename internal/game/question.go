@@ -0,0 +1,132 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoQuestions     = errors.New("no questions loaded")
+	ErrOutOfQuestions  = errors.New("every question has been asked")
+	ErrBadQuestionsCSV = errors.New("questions CSV needs text, answer, points and category columns")
+)
+
+// maxQuestions caps how many questions a game can have loaded.
+const maxQuestions = 500
+
+type Question struct {
+	ID     int    `json:"id"`
+	Text   string `json:"text"`
+	Answer string `json:"answer,omitempty"`
+	Points int    `json:"points,omitempty"`
+	// Category groups the question on a board, see boardState
+	Category string `json:"category,omitempty"`
+}
+
+func ValidateQuestions(qs []Question) error {
+	if len(qs) > maxQuestions {
+		return fmt.Errorf("a game can have at most %d questions", maxQuestions)
+	}
+	for i, q := range qs {
+		if q.Text == "" {
+			return fmt.Errorf("question %d has no text", i+1)
+		}
+	}
+	return nil
+}
+
+// LoadQuestions replaces a game's questions, numbering them in order.
+func (m *Manager) LoadQuestions(gameID string, qs []Question) error {
+	if err := ValidateQuestions(qs); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ErrGameNotFound
+	}
+
+	g.questions = make([]Question, len(qs))
+	for i, q := range qs {
+		q.ID = i + 1
+		g.questions[i] = q
+	}
+	// a new set starts out unseen, with no board
+	g.shown = 0
+	g.board = nil
+	m.save(g)
+	return nil
+}
+
+// QuestionTag returns the ID of the question being asked in a game and the
+// round it's in. questions are numbered from 1 whether or not any were
+// loaded, so with questions loaded the ID is the loaded question's.
+func (m *Manager) QuestionTag(gameID string) (questionID, round int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0, 0
+	}
+	return g.question, g.currentRound()
+}
+
+// Questions returns the questions loaded for a game.
+func (m *Manager) Questions(gameID string) []Question {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return []Question{}
+	}
+	return append([]Question{}, g.questions...)
+}
+
+// NextQuestion moves a game on to its next loaded question and returns it.
+// the first call shows the current question rather than skipping past it.
+// advanced is true if the buzzes were cleared for a new question.
+func (m *Manager) NextQuestion(gameID string) (q Question, advanced bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Question{}, false, ErrGameNotFound
+	}
+	if len(g.questions) == 0 {
+		return Question{}, false, ErrNoQuestions
+	}
+
+	next := g.question
+	if g.shown >= g.question {
+		next++
+	}
+	if next > len(g.questions) {
+		return Question{}, false, ErrOutOfQuestions
+	}
+	if next > g.question {
+		g.clearBuzzes()
+		advanced = true
+	}
+	g.shown = g.question
+	m.save(g)
+	return g.questions[g.question-1], advanced, nil
+}
+
+// ShownQuestion returns the loaded question players are looking at, if
+// any.
+func (m *Manager) ShownQuestion(gameID string) Question {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok || g.shown == 0 || g.shown != g.question || g.shown > len(g.questions) {
+		return Question{}
+	}
+	return g.questions[g.shown-1]
+}
@@ -0,0 +1,55 @@
+package game
+
+import (
+	"bzzz/internal/transport"
+)
+
+// PlayerQueue returns the queue of a player's open stream.
+func (m *Manager) PlayerQueue(gameID, playerID string) (*transport.Queue, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, false
+	}
+	q, ok := g.clients[playerID]
+	return q, ok
+}
+
+// HostQueue returns the queue of a game's host stream with the given
+// client ID.
+func (m *Manager) HostQueue(gameID, clientID string) (*transport.Queue, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, false
+	}
+	q, ok := g.hostSubs[clientID]
+	return q, ok
+}
+
+// StreamQueues returns the queues feeding a game's host streams and its
+// players' streams, or just playerID's stream when it isn't empty.
+func (m *Manager) StreamQueues(gameID, playerID string) []*transport.Queue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+	if playerID != "" {
+		if q, ok := g.clients[playerID]; ok {
+			return []*transport.Queue{q}
+		}
+		return nil
+	}
+	queues := g.hostQueues()
+	for _, q := range g.clients {
+		queues = append(queues, q)
+	}
+	return queues
+}
@@ -0,0 +1,74 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// reactionStats sums up one player's reaction times over a game.
+type reactionStats struct {
+	PlayerID   string  `json:"playerID"`
+	PlayerName string  `json:"playerName"`
+	Buzzes     int     `json:"buzzes"`
+	AverageMs  float64 `json:"averageMs"`
+	BestMs     float64 `json:"bestMs"`
+}
+
+// Arm unlocks a game's buzzers and starts timing reactions for the current
+// question, returning when it was armed. in ArmedBuzzing games it opens the
+// buzzers until they're locked again or the question moves on.
+func (m *Manager) Arm(gameID string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return time.Time{}, ErrGameNotFound
+	}
+	if err := g.checkTransition(stateArmed); err != nil {
+		return time.Time{}, err
+	}
+	g.armedAt = time.Now()
+	g.armed = true
+	g.locked = false
+	m.save(g)
+	return g.armedAt, nil
+}
+
+// Reactions returns every buzz made while a game was armed, in the order
+// they happened.
+func (m *Manager) Reactions(gameID string) []Buzz {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return []Buzz{}
+	}
+	return append([]Buzz{}, g.reactions...)
+}
+
+// SummarizeReactions works out each player's reaction time stats, fastest
+// average first.
+func SummarizeReactions(buzzes []Buzz) []reactionStats {
+	byPlayer := map[string]*reactionStats{}
+	for _, b := range buzzes {
+		s, ok := byPlayer[b.PlayerID]
+		if !ok {
+			s = &reactionStats{PlayerID: b.PlayerID, PlayerName: b.PlayerName, BestMs: b.ReactionMs}
+			byPlayer[b.PlayerID] = s
+		}
+		s.AverageMs = (s.AverageMs*float64(s.Buzzes) + b.ReactionMs) / float64(s.Buzzes+1)
+		s.Buzzes++
+		if b.ReactionMs < s.BestMs {
+			s.BestMs = b.ReactionMs
+		}
+	}
+
+	stats := make([]reactionStats, 0, len(byPlayer))
+	for _, s := range byPlayer {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].AverageMs < stats[j].AverageMs })
+	return stats
+}
@@ -0,0 +1,24 @@
+package game
+
+import (
+	"time"
+)
+
+// touch records activity on a game.
+func (g *game) touch() {
+	g.lastActive = time.Now()
+}
+
+// IdleGames returns the games with no activity for longer than idle.
+func (m *Manager) IdleGames(idle time.Duration) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := []string{}
+	for id, g := range m.games {
+		if time.Since(g.lastActive) > idle {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// rematchSource is what a rematch takes from the game it's a rematch of.
+type rematchSource struct {
+	Options   GameOptions
+	hostToken string
+	Players   []Player
+	captains  map[string]string
+}
+
+// RematchSource returns what a rematch of a game starts from: its options,
+// host token, players in the order they joined and team captains.
+func (m *Manager) RematchSource(gameID string) (rematchSource, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return rematchSource{}, ErrGameNotFound
+	}
+	src := rematchSource{Options: g.options, hostToken: g.hostToken, captains: map[string]string{}}
+	src.Options.Webhooks = append([]WebhookEndpoint(nil), g.options.Webhooks...)
+	src.Options.Teams = append([]string(nil), g.options.Teams...)
+	for _, p := range g.players {
+		src.Players = append(src.Players, *p)
+	}
+	sort.Slice(src.Players, func(i, j int) bool { return src.Players[i].JoinedAt.Before(src.Players[j].JoinedAt) })
+	for team, playerID := range g.captains {
+		src.captains[team] = playerID
+	}
+	return src, nil
+}
+
+// AddRematchPlayers puts a rematch's players into its new game with their
+// names, teams, avatars, colors and seats, and no points. with sameCode they
+// keep their IDs and tokens, and the host theirs, so apps rejoin with what
+// they already have. a player whose ID was taken in between is left out and
+// has to join again. it returns the players as they are in the new game.
+func (m *Manager) AddRematchPlayers(gameID string, src rematchSource, sameCode bool) ([]Player, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	defer m.save(g)
+
+	if sameCode {
+		g.hostToken = src.hostToken
+	}
+	ids := map[string]string{}
+	players := []Player{}
+	for _, old := range src.Players {
+		p := old
+		p.GameID = gameID
+		p.Connected, p.ConnectedAt, p.LeftAt = false, time.Time{}, time.Time{}
+		p.JoinedAt = time.Now()
+
+		if sameCode {
+			ok, err := m.store.ReserveID(playerIDSpace, p.PlayerID)
+			if err != nil {
+				return players, err
+			}
+			if !ok {
+				log.Printf("player id %s was taken before the rematch of %s, leaving them out", p.PlayerID, gameID)
+				continue
+			}
+		} else {
+			var err error
+			if p.PlayerID, err = m.newID(m.playerIDs, playerIDSpace); err != nil {
+				return players, err
+			}
+			if p.Token, err = NewToken(); err != nil {
+				return players, err
+			}
+		}
+
+		ids[old.PlayerID] = p.PlayerID
+		g.players[p.PlayerID] = &p
+		m.players[p.PlayerID] = gameID
+		players = append(players, p)
+	}
+
+	for team, playerID := range src.captains {
+		if id, ok := ids[playerID]; ok {
+			if g.captains == nil {
+				g.captains = map[string]string{}
+			}
+			g.captains[team] = id
+		}
+	}
+	return players, nil
+}
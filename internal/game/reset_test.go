@@ -0,0 +1,130 @@
+package game
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// newResetGame creates a game with players joined, returning its ID and
+// theirs.
+func newResetGame(t *testing.T, m *Manager, opts GameOptions, players int) (string, []string) {
+	t.Helper()
+
+	gameID, err := m.CreateGame(opts, "")
+	if err != nil {
+		t.Fatalf("failed to create game: %v", err)
+	}
+	var ids []string
+	for i := 0; i < players; i++ {
+		p, err := m.Join(gameID, fmt.Sprintf("player%d", i), "", "")
+		if err != nil {
+			t.Fatalf("failed to join: %v", err)
+		}
+		ids = append(ids, p.PlayerID)
+	}
+	return gameID, ids
+}
+
+func TestBuzzForResetQuestionIsStale(t *testing.T) {
+	m := newTestManager(t, newMemStore(), ULIDs{}, ULIDs{})
+	gameID, players := newResetGame(t, m, GameOptions{}, 1)
+
+	question, _, err := m.ResetQuestion(gameID)
+	if err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if _, _, err := m.RecordBuzz(gameID, players[0], "", question-1, 0); err != ErrStaleBuzz {
+		t.Fatalf("buzz for the old question got %v, want ErrStaleBuzz", err)
+	}
+	b, ok, err := m.RecordBuzz(gameID, players[0], "", question, 0)
+	if err != nil || !ok {
+		t.Fatalf("buzz for the new question got %v, %v", ok, err)
+	}
+	if b.QuestionID != question {
+		t.Fatalf("buzz recorded for question %d, want %d", b.QuestionID, question)
+	}
+}
+
+func TestResetUnlocksFirstBuzzWins(t *testing.T) {
+	m := newTestManager(t, newMemStore(), ULIDs{}, ULIDs{})
+	gameID, players := newResetGame(t, m, GameOptions{FirstBuzzWins: true}, 2)
+
+	if _, ok, err := m.RecordBuzz(gameID, players[0], "", 0, 0); err != nil || !ok {
+		t.Fatalf("first buzz got %v, %v", ok, err)
+	}
+	if !m.Locked(gameID) {
+		t.Fatal("buzzers not locked after the first buzz")
+	}
+	if _, _, err := m.RecordBuzz(gameID, players[1], "", 0, 0); err != ErrLateBuzz {
+		t.Fatalf("second buzz got %v, want ErrLateBuzz", err)
+	}
+
+	if _, _, err := m.ResetQuestion(gameID); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if m.Locked(gameID) {
+		t.Fatal("buzzers still locked after reset")
+	}
+	if order := m.BuzzOrder(gameID); len(order) != 0 {
+		t.Fatalf("buzzes left after reset: %v", order)
+	}
+	if _, ok, err := m.RecordBuzz(gameID, players[1], "", 0, 0); err != nil || !ok {
+		t.Fatalf("buzz after reset got %v, %v", ok, err)
+	}
+}
+
+// buzzes for a question racing its reset either land before it, and are
+// cleared by it, or after it and are turned away as stale. none is left
+// behind on the next question.
+func TestResetRacingBuzzes(t *testing.T) {
+	for run := 0; run < 50; run++ {
+		m := newTestManager(t, newMemStore(), ULIDs{}, ULIDs{})
+		gameID, players := newResetGame(t, m, GameOptions{FirstBuzzWins: run%2 == 1}, 8)
+		const question = 1
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		errs := make(chan error, len(players))
+		for _, playerID := range players {
+			wg.Add(1)
+			go func(playerID string) {
+				defer wg.Done()
+				<-start
+				b, ok, err := m.RecordBuzz(gameID, playerID, "", question, 0)
+				switch {
+				case err == nil && ok && b.QuestionID != question:
+					errs <- fmt.Errorf("buzz recorded for question %d, want %d", b.QuestionID, question)
+				case err != nil && err != ErrStaleBuzz && err != ErrLateBuzz:
+					errs <- err
+				}
+			}(playerID)
+		}
+		var next int
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			var err error
+			if next, _, err = m.ResetQuestion(gameID); err != nil {
+				errs <- err
+			}
+		}()
+		close(start)
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if next != question+1 {
+			t.Fatalf("run %d: reset moved to question %d, want %d", run, next, question+1)
+		}
+		if order := m.BuzzOrder(gameID); len(order) != 0 {
+			t.Fatalf("run %d: buzzes left on the next question: %v", run, order)
+		}
+		if m.Locked(gameID) {
+			t.Fatalf("run %d: buzzers locked on the next question", run)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNoActiveRound = errors.New("no round is in progress")
+
+// Round is one round of a game along with every buzz made during it.
+type Round struct {
+	Number int `json:"number"`
+	// Type is a label the host gives the round, e.g. "lightning", that
+	// league stats are broken down by
+	Type      string     `json:"type,omitempty"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	Buzzes    []Buzz     `json:"buzzes"`
+}
+
+// StartRound begins the next round of the given type, clearing the buzz
+// queue. a round still in progress is ended first.
+func (m *Manager) StartRound(gameID, roundType string) (Round, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Round{}, ErrGameNotFound
+	}
+	if g.roundActive {
+		g.endRound()
+	}
+
+	g.rounds = append(g.rounds, Round{
+		Number:    len(g.rounds) + 1,
+		Type:      roundType,
+		StartedAt: time.Now(),
+		Buzzes:    []Buzz{},
+	})
+	g.roundActive = true
+	tallyBuzzes(g.stats, g.buzzes)
+	g.buzzes = nil
+	g.buzzed = map[string]bool{}
+	g.late = map[string]bool{}
+	g.armedAt = time.Time{}
+	g.armed = false
+	m.save(g)
+	return g.rounds[len(g.rounds)-1], nil
+}
+
+// EndRound ends the round in progress, archiving its buzzes and clearing the
+// buzz queue.
+func (m *Manager) EndRound(gameID string) (Round, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return Round{}, ErrGameNotFound
+	}
+	if !g.roundActive {
+		return Round{}, ErrNoActiveRound
+	}
+
+	ended := g.endRound()
+	m.save(g)
+	return ended, nil
+}
+
+// Rounds returns every round played so far, the current one last.
+func (m *Manager) Rounds(gameID string) []Round {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return []Round{}
+	}
+
+	rounds := make([]Round, 0, len(g.rounds))
+	for _, r := range g.rounds {
+		r.Buzzes = append([]Buzz{}, r.Buzzes...)
+		rounds = append(rounds, r)
+	}
+	if g.roundActive {
+		rounds[len(rounds)-1].Buzzes = append([]Buzz{}, g.buzzes...)
+	}
+	return rounds
+}
+
+// CurrentRound returns the number of the round in progress, or 0.
+func (m *Manager) CurrentRound(gameID string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0
+	}
+	return g.currentRound()
+}
+
+// currentRound returns the number of the round in progress, or 0.
+func (g *game) currentRound() int {
+	if !g.roundActive {
+		return 0
+	}
+	return len(g.rounds)
+}
+
+func (g *game) endRound() Round {
+	now := time.Now()
+	r := &g.rounds[len(g.rounds)-1]
+	r.EndedAt = &now
+	r.Buzzes = append(r.Buzzes, g.buzzes...)
+	tallyBuzzes(g.stats, g.buzzes)
+
+	g.roundActive = false
+	g.buzzes = nil
+	g.buzzed = map[string]bool{}
+	g.late = map[string]bool{}
+	g.armedAt = time.Time{}
+	g.armed = false
+	return *r
+}
@@ -0,0 +1,167 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrScoreNotFound    = errors.New("score entry not found")
+	ErrNotCorrectable   = errors.New("only awards and adjustments can be corrected")
+	ErrUnknownScoreKind = errors.New("unknown score entry kind")
+)
+
+// score ledger entry kinds. the ledger is append only, totals are always
+// recomputed from it so every change to a score can be traced.
+const (
+	// ScoreKindAward is points given during play.
+	ScoreKindAward = "award"
+	// ScoreKindAdjustment is points given or taken outside of play, e.g. by an
+	// upheld challenge.
+	ScoreKindAdjustment = "adjustment"
+	// ScoreKindCorrection amends an earlier award or adjustment, replacing who
+	// it credited and for how much. the latest correction wins.
+	ScoreKindCorrection = "correction"
+)
+
+type ScoreEntry struct {
+	ID       int       `json:"id"`
+	Kind     string    `json:"kind"`
+	PlayerID string    `json:"playerID"`
+	Points   int       `json:"points"`
+	Reason   string    `json:"reason,omitempty"`
+	Corrects int       `json:"corrects,omitempty"`
+	At       time.Time `json:"at"`
+	// QuestionID and Round are where the points were won, corrections
+	// share them with the entry they correct
+	QuestionID int `json:"questionID,omitempty"`
+	Round      int `json:"round,omitempty"`
+}
+
+type Standing struct {
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Points     int    `json:"points"`
+}
+
+// RecordScore appends an entry to a game's score ledger, filling in its ID
+// and time.
+func (m *Manager) RecordScore(gameID string, e ScoreEntry) (ScoreEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ScoreEntry{}, ErrGameNotFound
+	}
+	if _, ok := g.players[e.PlayerID]; !ok {
+		return ScoreEntry{}, ErrPlayerNotFound
+	}
+	if !g.pausedAt.IsZero() {
+		return ScoreEntry{}, ErrGamePaused
+	}
+
+	switch e.Kind {
+	case ScoreKindAward, ScoreKindAdjustment:
+		e.Corrects = 0
+		e.QuestionID, e.Round = g.question, g.currentRound()
+	case ScoreKindCorrection:
+		orig, ok := g.scoreEntry(e.Corrects)
+		if !ok {
+			return ScoreEntry{}, ErrScoreNotFound
+		}
+		if orig.Kind == ScoreKindCorrection {
+			return ScoreEntry{}, ErrNotCorrectable
+		}
+		e.QuestionID, e.Round = orig.QuestionID, orig.Round
+	default:
+		return ScoreEntry{}, ErrUnknownScoreKind
+	}
+
+	e.ID = len(g.scores) + 1
+	e.At = time.Now()
+	g.scores = append(g.scores, e)
+	m.save(g)
+	return e, nil
+}
+
+// ScoreEntry looks up a single ledger entry.
+func (m *Manager) ScoreEntry(gameID string, entryID int) (ScoreEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ScoreEntry{}, false
+	}
+	return g.scoreEntry(entryID)
+}
+
+// ScoreLedger returns a copy of a game's full score ledger.
+func (m *Manager) ScoreLedger(gameID string) []ScoreEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return []ScoreEntry{}
+	}
+	return append([]ScoreEntry{}, g.scores...)
+}
+
+// Leaderboard recomputes every player's total from the ledger, highest
+// first.
+func (m *Manager) Leaderboard(gameID string) []Standing {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return []Standing{}
+	}
+	return g.leaderboard()
+}
+
+func (g *game) scoreEntry(entryID int) (ScoreEntry, bool) {
+	if entryID < 1 || entryID > len(g.scores) {
+		return ScoreEntry{}, false
+	}
+	return g.scores[entryID-1], true
+}
+
+func (g *game) leaderboard() []Standing {
+	// apply corrections over the entries they amend, then total
+	effective := map[int]ScoreEntry{}
+	for _, e := range g.scores {
+		if e.Kind == ScoreKindCorrection {
+			effective[e.Corrects] = e
+		} else {
+			effective[e.ID] = e
+		}
+	}
+
+	totals := map[string]int{}
+	for playerID := range g.players {
+		totals[playerID] = 0
+	}
+	for _, e := range effective {
+		totals[e.PlayerID] += e.Points
+	}
+
+	board := make([]Standing, 0, len(totals))
+	for playerID, points := range totals {
+		s := Standing{PlayerID: playerID, Points: points}
+		if p, ok := g.players[playerID]; ok {
+			s.PlayerName = p.Name
+		}
+		board = append(board, s)
+	}
+	sort.Slice(board, func(a, b int) bool {
+		if board[a].Points != board[b].Points {
+			return board[a].Points > board[b].Points
+		}
+		return board[a].PlayerName < board[b].PlayerName
+	})
+	return board
+}
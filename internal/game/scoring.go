@@ -0,0 +1,108 @@
+package game
+
+import (
+	"math"
+)
+
+// ScoringRules are how a verdict turns into points when the host doesn't
+// give them, so the host only says right or wrong. the zero value is a
+// question's points, or 1, for a right answer and nothing for a wrong one.
+type ScoringRules struct {
+	// Correct is what a right answer wins on a question without points of
+	// its own, 1 when 0
+	Correct int `json:"correct,omitempty"`
+	// Wrong is what a wrong answer costs
+	Wrong int `json:"wrong,omitempty"`
+	// WrongCostsValue makes a wrong answer cost what a right one would
+	// have won, as in Jeopardy, instead of Wrong
+	WrongCostsValue bool `json:"wrongCostsValue,omitempty"`
+	// RoundMultipliers scale the points round by round, the first for
+	// round 1 and the last for every round after the list runs out. 2 for
+	// the second round is double jeopardy
+	RoundMultipliers []float64 `json:"roundMultipliers,omitempty"`
+	// Floor is as low as a wrong answer can take a score, no floor when
+	// nil. a score already under it loses nothing more
+	Floor *int `json:"floor,omitempty"`
+	// EarlyBuzz is what buzzing before the buzzers are armed costs in an
+	// ArmedBuzzing game, nothing when 0. the floor applies to it too
+	EarlyBuzz int `json:"earlyBuzz,omitempty"`
+}
+
+// multiplier is what points in a round are multiplied by. a verdict
+// between rounds counts in the round just ended.
+func (s ScoringRules) multiplier(round int) float64 {
+	if len(s.RoundMultipliers) == 0 {
+		return 1
+	}
+	if round < 1 {
+		round = 1
+	}
+	if round > len(s.RoundMultipliers) {
+		round = len(s.RoundMultipliers)
+	}
+	return s.RoundMultipliers[round-1]
+}
+
+// questionValue is what a right answer to the current question wins under
+// the game's rules. it must be called with m.mu held.
+func (g *game) questionValue() int {
+	points := g.options.Scoring.Correct
+	if points == 0 {
+		points = 1
+	}
+	if i := g.question - 1; i >= 0 && i < len(g.questions) && g.questions[i].Points != 0 {
+		points = g.questions[i].Points
+	}
+	return int(math.Round(float64(points) * g.options.Scoring.multiplier(len(g.rounds))))
+}
+
+// playerScore is a player's total. it must be called with m.mu held.
+func (g *game) playerScore(playerID string) int {
+	for _, s := range g.leaderboard() {
+		if s.PlayerID == playerID {
+			return s.Points
+		}
+	}
+	return 0
+}
+
+// JudgedPoints works out what a verdict on a player's answer is worth
+// under the game's scoring rules. points is what the host gave instead,
+// nil to leave it to the rules. either way a loss stops at the floor.
+func (m *Manager) JudgedPoints(gameID, playerID string, correct bool, points *int) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0
+	}
+	rules := g.options.Scoring
+
+	var delta int
+	switch {
+	case points != nil:
+		delta = *points
+	case correct:
+		delta = g.questionValue()
+	case rules.WrongCostsValue:
+		delta = -g.questionValue()
+	default:
+		delta = -int(math.Round(float64(rules.Wrong) * rules.multiplier(len(g.rounds))))
+	}
+
+	return rules.floored(g.playerScore(playerID), delta)
+}
+
+// floored stops a loss of delta points from taking score below the floor.
+func (s ScoringRules) floored(score, delta int) int {
+	if s.Floor != nil && delta < 0 {
+		if score+delta < *s.Floor {
+			delta = *s.Floor - score
+		}
+		if delta > 0 {
+			delta = 0
+		}
+	}
+	return delta
+}
@@ -0,0 +1,85 @@
+package game
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrSeatTaken = errors.New("seat is taken")
+
+// Seats returns playerID -> seat for every seated player in a game.
+func (m *Manager) Seats(gameID string) map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seats := map[string]int{}
+	if g, ok := m.games[gameID]; ok {
+		for playerID, p := range g.players {
+			if p.Seat > 0 {
+				seats[playerID] = p.Seat
+			}
+		}
+	}
+	return seats
+}
+
+// SetSeat puts a player in a seat, seat 0 unseats them.
+func (m *Manager) SetSeat(gameID, playerID string, seat int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	if seat > 0 {
+		for id, other := range g.players {
+			if id != playerID && other.Seat == seat {
+				return ErrSeatTaken
+			}
+		}
+	}
+	p.Seat = seat
+	m.save(g)
+	return nil
+}
+
+// AutoSeat gives every unseated player in a game the lowest free seat, in
+// the order they joined.
+func (m *Manager) AutoSeat(gameID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return
+	}
+
+	taken := map[int]bool{}
+	unseated := []*Player{}
+	for _, p := range g.players {
+		if p.Seat > 0 {
+			taken[p.Seat] = true
+		} else {
+			unseated = append(unseated, p)
+		}
+	}
+
+	sort.Slice(unseated, func(i, j int) bool {
+		return unseated[i].ConnectedAt.Before(unseated[j].ConnectedAt)
+	})
+
+	seat := 1
+	for _, p := range unseated {
+		for taken[seat] {
+			seat++
+		}
+		p.Seat = seat
+		taken[seat] = true
+	}
+	m.save(g)
+}
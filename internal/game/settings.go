@@ -0,0 +1,50 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrGameFull     = errors.New("game is full")
+	ErrBuzzCooldown = errors.New("buzzing again too soon")
+)
+
+// cooldownLeft is how long a player has to wait before buzzing again in
+// games with a buzz cooldown. it must be called with m.mu held.
+func (g *game) cooldownLeft(playerID string, now time.Time) time.Duration {
+	last, ok := g.lastBuzzAt[playerID]
+	if !ok || g.options.BuzzCooldown <= 0 {
+		return 0
+	}
+	if left := g.options.BuzzCooldown - now.Sub(last); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// CooldownLeft is how long a player has to wait before buzzing again.
+func (m *Manager) CooldownLeft(gameID, playerID string) time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0
+	}
+	return g.cooldownLeft(playerID, time.Now())
+}
+
+// UpdateOptions changes a running game's options.
+func (m *Manager) UpdateOptions(gameID string, update func(*GameOptions)) (GameOptions, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return GameOptions{}, ErrGameNotFound
+	}
+	update(&g.options)
+	m.save(g)
+	return g.options, nil
+}
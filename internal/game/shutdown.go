@@ -0,0 +1,23 @@
+package game
+
+// GameIDs returns the ID of every running game.
+func (m *Manager) GameIDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.games))
+	for id := range m.games {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// SaveAll writes every game to the store.
+func (m *Manager) SaveAll() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, g := range m.games {
+		m.save(g)
+	}
+}
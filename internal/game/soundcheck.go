@@ -0,0 +1,90 @@
+package game
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrNoSoundcheck = errors.New("no soundcheck is running")
+
+type soundcheckPlayer struct {
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Connected  bool   `json:"connected"`
+}
+
+// SoundcheckStatus is who has and hasn't got a buzz through during a
+// soundcheck.
+type SoundcheckStatus struct {
+	Ready   []soundcheckPlayer `json:"ready"`
+	Waiting []soundcheckPlayer `json:"waiting"`
+	// Complete is true once every connected player is ready
+	Complete bool `json:"complete"`
+}
+
+// SoundcheckStatus must be called with m.mu held.
+func (g *game) soundcheckStatus() SoundcheckStatus {
+	s := SoundcheckStatus{Ready: []soundcheckPlayer{}, Waiting: []soundcheckPlayer{}, Complete: true}
+	for _, p := range g.players {
+		sp := soundcheckPlayer{PlayerID: p.PlayerID, PlayerName: p.Name, Connected: p.Connected}
+		if g.soundcheck[p.PlayerID] {
+			s.Ready = append(s.Ready, sp)
+			continue
+		}
+		s.Waiting = append(s.Waiting, sp)
+		if p.Connected {
+			s.Complete = false
+		}
+	}
+	sort.Slice(s.Ready, func(i, j int) bool { return s.Ready[i].PlayerName < s.Ready[j].PlayerName })
+	sort.Slice(s.Waiting, func(i, j int) bool { return s.Waiting[i].PlayerName < s.Waiting[j].PlayerName })
+	return s
+}
+
+// StartSoundcheck asks every player in a game to buzz once, forgetting any
+// soundcheck already running.
+func (m *Manager) StartSoundcheck(gameID string) (SoundcheckStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return SoundcheckStatus{}, ErrGameNotFound
+	}
+	g.soundcheck = map[string]bool{}
+	return g.soundcheckStatus(), nil
+}
+
+// SoundcheckBuzz counts a player's buzz towards a running soundcheck. ok is
+// false if there is no soundcheck, in which case it's a real buzz.
+func (m *Manager) SoundcheckBuzz(gameID, playerID string) (s SoundcheckStatus, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.soundcheck == nil {
+		return SoundcheckStatus{}, false
+	}
+	if _, exists := g.players[playerID]; !exists {
+		return SoundcheckStatus{}, false
+	}
+	g.soundcheck[playerID] = true
+	return g.soundcheckStatus(), true
+}
+
+// EndSoundcheck stops a running soundcheck, returning how it went.
+func (m *Manager) EndSoundcheck(gameID string) (SoundcheckStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return SoundcheckStatus{}, ErrGameNotFound
+	}
+	if g.soundcheck == nil {
+		return SoundcheckStatus{}, ErrNoSoundcheck
+	}
+	s := g.soundcheckStatus()
+	g.soundcheck = nil
+	return s, nil
+}
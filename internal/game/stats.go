@@ -0,0 +1,151 @@
+package game
+
+// statTally is what a game keeps counting for each player as it goes. the
+// buzzes of the question in play are only tallied once it's cleared, see
+// tallyBuzzes.
+type statTally struct {
+	Buzzes int `json:"buzzes"`
+	// Wins are the questions the player buzzed first in
+	Wins int `json:"wins"`
+	// Correct and Incorrect are the player's judged wager and all-play
+	// answers, buzzed answers are counted from the game's answers
+	Correct   int `json:"correct,omitempty"`
+	Incorrect int `json:"incorrect,omitempty"`
+}
+
+// PlayerStats sums up how a player did over a game.
+type PlayerStats struct {
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Buzzes     int    `json:"buzzes"`
+	Wins       int    `json:"wins"`
+	// AverageReactionMs is over the buzzes made while the buzzers were
+	// armed, zero when there weren't any
+	AverageReactionMs float64 `json:"averageReactionMs"`
+	Correct           int     `json:"correct"`
+	Incorrect         int     `json:"incorrect"`
+	Points            int     `json:"points"`
+}
+
+// statFor returns a player's tally in stats, starting one if needed.
+func statFor(stats map[string]*statTally, playerID string) *statTally {
+	t, ok := stats[playerID]
+	if !ok {
+		t = &statTally{}
+		stats[playerID] = t
+	}
+	return t
+}
+
+// tallyBuzzes counts a question's buzzes towards each player's stats, the
+// first one that wasn't late winning it.
+func tallyBuzzes(stats map[string]*statTally, buzzes []Buzz) {
+	won := false
+	for _, b := range buzzes {
+		t := statFor(stats, b.PlayerID)
+		t.Buzzes++
+		if !won && !b.Late {
+			t.Wins++
+			won = true
+		}
+	}
+}
+
+// untallyBuzzes takes a question's buzzes back out of the stats, when a
+// reset is undone. it must be called with m.mu held.
+func untallyBuzzes(stats map[string]*statTally, buzzes []Buzz) {
+	won := false
+	for _, b := range buzzes {
+		t := statFor(stats, b.PlayerID)
+		t.Buzzes--
+		if !won && !b.Late {
+			t.Wins--
+			won = true
+		}
+	}
+}
+
+// tallyVerdict counts a judged wager or all-play answer towards a player's
+// stats. it must be called with m.mu held.
+func (g *game) tallyVerdict(playerID string, correct bool) {
+	if correct {
+		statFor(g.stats, playerID).Correct++
+	} else {
+		statFor(g.stats, playerID).Incorrect++
+	}
+}
+
+// PlayerStats works out every player's stats, in leaderboard order. it
+// must be called with m.mu held.
+func (g *game) playerStats() []PlayerStats {
+	// count the question in play as if it were cleared now, on a copy
+	tallies := make(map[string]*statTally, len(g.stats))
+	for playerID, t := range g.stats {
+		c := *t
+		tallies[playerID] = &c
+	}
+	tallyBuzzes(tallies, g.buzzes)
+	for _, a := range g.answers {
+		if a.Correct == nil {
+			continue
+		}
+		if *a.Correct {
+			statFor(tallies, a.PlayerID).Correct++
+		} else {
+			statFor(tallies, a.PlayerID).Incorrect++
+		}
+	}
+	reactions := map[string]reactionStats{}
+	for _, s := range SummarizeReactions(g.reactions) {
+		reactions[s.PlayerID] = s
+	}
+
+	board := g.leaderboard()
+	stats := make([]PlayerStats, 0, len(board))
+	for _, s := range board {
+		t := statFor(tallies, s.PlayerID)
+		stats = append(stats, PlayerStats{
+			PlayerID:          s.PlayerID,
+			PlayerName:        s.PlayerName,
+			Buzzes:            t.Buzzes,
+			Wins:              t.Wins,
+			AverageReactionMs: reactions[s.PlayerID].AverageMs,
+			Correct:           t.Correct,
+			Incorrect:         t.Incorrect,
+			Points:            s.Points,
+		})
+	}
+	return stats
+}
+
+// Stats returns every player's stats in a game, top scorer first.
+func (m *Manager) Stats(gameID string) ([]PlayerStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	return g.playerStats(), nil
+}
+
+// PlayerStats returns one player's stats in a game.
+func (m *Manager) PlayerStats(gameID, playerID string) (PlayerStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return PlayerStats{}, ErrGameNotFound
+	}
+	if _, ok := g.players[playerID]; !ok {
+		return PlayerStats{}, ErrPlayerNotFound
+	}
+	for _, s := range g.playerStats() {
+		if s.PlayerID == playerID {
+			return s, nil
+		}
+	}
+	return PlayerStats{}, ErrPlayerNotFound
+}
@@ -0,0 +1,101 @@
+package game
+
+import (
+	"time"
+)
+
+// GameRecord is everything about a game that has to survive a restart.
+// streams, queues and outboxes are rebuilt when clients reconnect.
+type GameRecord struct {
+	ID         string       `json:"id"`
+	Options    GameOptions  `json:"options"`
+	StartedAt  time.Time    `json:"startedAt"`
+	Seq        int          `json:"seq"`
+	Backup     string       `json:"backup,omitempty"`
+	Locked     bool         `json:"locked,omitempty"`
+	Players    []Player     `json:"players"`
+	Buzzes     []Buzz       `json:"buzzes,omitempty"`
+	Scores     []ScoreEntry `json:"scores,omitempty"`
+	Answers    []Answer     `json:"answers,omitempty"`
+	Rounds     []Round      `json:"rounds,omitempty"`
+	InRound    bool         `json:"inRound,omitempty"`
+	LastActive time.Time    `json:"lastActive"`
+	HostToken  string       `json:"hostToken"`
+	Promoted   string       `json:"promoted,omitempty"`
+	Questions  []Question   `json:"questions,omitempty"`
+	Shown      int          `json:"shown,omitempty"`
+	Question   int          `json:"question,omitempty"`
+	Reactions  []Buzz       `json:"reactions,omitempty"`
+	Banned     []string     `json:"banned,omitempty"`
+	TurnPlayer string       `json:"turnPlayer,omitempty"`
+	Turn       int          `json:"turn,omitempty"`
+
+	// RecoveryPIN is hashed, see hashRecoveryPIN
+	RecoveryPIN      string `json:"recoveryPin,omitempty"`
+	RecoveryAttempts int    `json:"recoveryAttempts,omitempty"`
+	// Waiting is the waiting room, in line order
+	Waiting []Player `json:"waiting,omitempty"`
+	// Nonces are the buzz nonces seen lately, by playerID|nonce
+	Nonces map[string]seenBuzz `json:"nonces,omitempty"`
+	// Wager is the wager round in play or last played
+	Wager *WagerRound `json:"wager,omitempty"`
+	// AllPlay is the all-play round in play or last played
+	AllPlay *AllPlayRound `json:"allPlay,omitempty"`
+	// Board is the category board, if the host set one up
+	Board *boardState `json:"board,omitempty"`
+	// Devices are the hardware buzzers paired with players, by device ID
+	Devices map[string]string `json:"devices,omitempty"`
+	// BuzzLogged and BuzzLogHead carry the buzz log on, see BuzzLogEntry
+	BuzzLogged  int    `json:"buzzLogged,omitempty"`
+	BuzzLogHead string `json:"buzzLogHead,omitempty"`
+	// PausedAt is set while the game is paused, PausedTimerMs and
+	// PausedAutoLock are the countdown the pause stopped
+	PausedAt       *time.Time `json:"pausedAt,omitempty"`
+	PausedTimerMs  int64      `json:"pausedTimerMs,omitempty"`
+	PausedAutoLock bool       `json:"pausedAutoLock,omitempty"`
+	// EndedAt is set once the game has ended and is waiting to be torn down
+	EndedAt *time.Time `json:"endedAt,omitempty"`
+	// LockedPlayers and LockedTeams are locked out while everyone else can
+	// buzz
+	LockedPlayers []string `json:"lockedPlayers,omitempty"`
+	LockedTeams   []string `json:"lockedTeams,omitempty"`
+	// Armed and EarlyBuzzes are an ArmedBuzzing game's state, see game
+	Armed       bool           `json:"armed,omitempty"`
+	EarlyBuzzes map[string]int `json:"earlyBuzzes,omitempty"`
+	// Undo is the host's undo stack, see UndoEntry
+	Undo []UndoEntry `json:"undo,omitempty"`
+	// Captains, TeamRequests and Timeouts are the game's team captain
+	// state, see game
+	Captains     map[string]string `json:"captains,omitempty"`
+	TeamRequests map[string]string `json:"teamRequests,omitempty"`
+	Timeouts     map[string]int    `json:"timeouts,omitempty"`
+	// Stats are each player's tallies from the questions already cleared
+	Stats map[string]statTally `json:"stats,omitempty"`
+	// State is the lifecycle state last announced
+	State string `json:"state,omitempty"`
+}
+
+// Store is the part of a Store the games themselves need.
+type Store interface {
+	// SaveGame writes the latest state of a game, replacing what was there.
+	// since is what was last written of it, nil if nothing was or that's
+	// not known, so a store can write just what changed.
+	SaveGame(rec GameRecord, since *GameRecord) error
+	// DeleteGame forgets a game that has ended.
+	DeleteGame(gameID string) error
+	// LoadGames returns every game that hasn't ended.
+	LoadGames() ([]GameRecord, error)
+	// LoadGame returns one game that hasn't ended, false if the store has
+	// no such game.
+	LoadGame(gameID string) (GameRecord, bool, error)
+
+	// ReserveID claims a new ID in a namespace, GameIDSpace or
+	// playerIDSpace, returning false if it's already taken there.
+	ReserveID(space, id string) (bool, error)
+	// ReleaseIDs frees IDs in a namespace once their game has ended.
+	ReleaseIDs(space string, ids ...string) error
+
+	// AppendBuzzLog adds entries to a game's buzz log, kept as long as its
+	// history.
+	AppendBuzzLog(gameID string, entries []BuzzLogEntry) error
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	"sort"
+)
+
+type team struct {
+	Name    string        `json:"name"`
+	Players []RosterEntry `json:"players"`
+	// Captain is the team's captain, in a game with captains. Requests
+	// are the players waiting for them to accept them onto the team
+	Captain  string        `json:"captain,omitempty"`
+	Requests []RosterEntry `json:"requests,omitempty"`
+}
+
+// Teams returns every team in a game with its players, by team name,
+// including teams set up in advance that nobody is on. players without a
+// team aren't included.
+func (m *Manager) Teams(gameID string) []team {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return []team{}
+	}
+
+	byName := map[string]*team{}
+	for _, name := range g.options.Teams {
+		byName[name] = &team{Name: name, Players: []RosterEntry{}}
+	}
+	for _, p := range g.players {
+		if p.Team == "" {
+			continue
+		}
+		t, ok := byName[p.Team]
+		if !ok {
+			t = &team{Name: p.Team}
+			byName[p.Team] = t
+		}
+		t.Players = append(t.Players, RosterEntry{
+			PlayerID:  p.PlayerID,
+			Name:      p.Name,
+			Seat:      p.Seat,
+			Team:      p.Team,
+			Avatar:    p.Avatar,
+			Color:     p.Color,
+			Connected: p.Connected,
+		})
+	}
+
+	for playerID, name := range g.teamRequests {
+		p, ok := g.players[playerID]
+		t, known := byName[name]
+		if !ok || !known {
+			continue
+		}
+		t.Requests = append(t.Requests, RosterEntry{PlayerID: p.PlayerID, Name: p.Name, Avatar: p.Avatar, Color: p.Color, Connected: p.Connected})
+	}
+
+	teams := make([]team, 0, len(byName))
+	for _, t := range byName {
+		if g.options.Captains {
+			t.Captain = g.captain(t.Name)
+		}
+		sort.Slice(t.Requests, func(a, b int) bool { return t.Requests[a].Name < t.Requests[b].Name })
+		sort.Slice(t.Players, func(a, b int) bool { return t.Players[a].Name < t.Players[b].Name })
+		teams = append(teams, *t)
+	}
+	sort.Slice(teams, func(a, b int) bool { return teams[a].Name < teams[b].Name })
+	return teams
+}
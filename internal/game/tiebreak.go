@@ -0,0 +1,133 @@
+package game
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how a game breaks ties between buzzes pressed within cfg.TieWindow of
+// each other, see GameOptions.Tiebreak. every one of them is deterministic,
+// so replaying the game's buzzes gives the same order.
+const (
+	// TiebreakArrival keeps the order the buzzes arrived in
+	TiebreakArrival = "arrival"
+	// TiebreakRandom orders them by a hash of the game, question and
+	// player, which no player can aim for but always comes out the same
+	TiebreakRandom = "random"
+	// TiebreakAlphabetical orders them by player name
+	TiebreakAlphabetical = "alphabetical"
+	// TiebreakLowestScore puts whoever has the fewest points first, by
+	// name when they have as many
+	TiebreakLowestScore = "lowest_score"
+)
+
+// tied reports whether two buzzes were pressed within window of each
+// other.
+func tied(a, b Buzz, window time.Duration) bool {
+	d := a.pressedAt().Sub(b.pressedAt())
+	if d < 0 {
+		d = -d
+	}
+	return d < window
+}
+
+// breakTie checks the buzz just put in the order at position i against its
+// neighbours. buzzes pressed within window of the one next to them,
+// or already tied with it, are one tie, ordered by the game's tiebreak and
+// numbered in Tie. a buzz that loses a tiebreak to one pressed after it
+// ends up with a negative DeltaMs. it returns the buzz as it now stands. it
+// must be called with m.mu held.
+func (g *game) breakTie(i int, window time.Duration) Buzz {
+	if window <= 0 {
+		return g.buzzes[i]
+	}
+	next := func(a, b Buzz) bool {
+		return !a.Late && !b.Late && (tied(a, b, window) || a.Tie != 0 && a.Tie == b.Tie)
+	}
+	lo, hi := i, i
+	for lo > 0 && next(g.buzzes[lo-1], g.buzzes[lo]) {
+		lo--
+	}
+	for hi < len(g.buzzes)-1 && next(g.buzzes[hi], g.buzzes[hi+1]) {
+		hi++
+	}
+	if lo == hi {
+		return g.buzzes[i]
+	}
+
+	number := 0
+	for _, b := range g.buzzes {
+		if b.Tie > number {
+			number = b.Tie
+		}
+	}
+	number++
+	for _, b := range g.buzzes[lo : hi+1] {
+		if b.Tie != 0 {
+			number = b.Tie
+			break
+		}
+	}
+
+	playerID := g.buzzes[i].PlayerID
+	group := g.buzzes[lo : hi+1]
+	sort.SliceStable(group, g.tiebreakLess(group))
+
+	first := g.buzzes[0].pressedAt()
+	for j := range g.buzzes {
+		g.buzzes[j].Position = j + 1
+		if !g.buzzes[j].Late {
+			g.buzzes[j].DeltaMs = float64(g.buzzes[j].pressedAt().Sub(first)) / float64(time.Millisecond)
+		}
+		if j >= lo && j <= hi {
+			g.buzzes[j].Tie = number
+		}
+	}
+	b, _ := g.playerBuzz(playerID)
+	return b
+}
+
+// tiebreakLess orders tied buzzes by the game's tiebreak. it must be called
+// with m.mu held.
+func (g *game) tiebreakLess(group []Buzz) func(a, b int) bool {
+	byName := func(a, b int) bool {
+		an, bn := strings.ToLower(group[a].PlayerName), strings.ToLower(group[b].PlayerName)
+		if an != bn {
+			return an < bn
+		}
+		return group[a].PlayerID < group[b].PlayerID
+	}
+
+	switch g.options.Tiebreak {
+	case TiebreakRandom:
+		key := func(b Buzz) uint64 {
+			h := fnv.New64a()
+			h.Write([]byte(g.id + "|" + strconv.Itoa(b.QuestionID) + "|" + b.PlayerID))
+			return h.Sum64()
+		}
+		return func(a, b int) bool { return key(group[a]) < key(group[b]) }
+	case TiebreakAlphabetical:
+		return byName
+	case TiebreakLowestScore:
+		points := map[string]int{}
+		for _, s := range g.leaderboard() {
+			points[s.PlayerID] = s.Points
+		}
+		return func(a, b int) bool {
+			if pa, pb := points[group[a].PlayerID], points[group[b].PlayerID]; pa != pb {
+				return pa < pb
+			}
+			return byName(a, b)
+		}
+	default:
+		return func(a, b int) bool {
+			if !group[a].pressedAt().Equal(group[b].pressedAt()) {
+				return group[a].pressedAt().Before(group[b].pressedAt())
+			}
+			return group[a].At.Before(group[b].At)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"time"
+)
+
+// StartTimer starts a countdown on a game, replacing any running one, and
+// returns its ID and when it ends. autoLock is kept for a pause to carry
+// over.
+func (m *Manager) StartTimer(gameID string, d time.Duration, autoLock bool) (timerID int, endsAt time.Time, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0, time.Time{}, ErrGameNotFound
+	}
+	g.timer++
+	g.timerEndsAt = time.Now().Add(d)
+	g.timerAutoLock = autoLock
+	return g.timer, g.timerEndsAt, nil
+}
+
+// StopTimer cancels a game's running countdown. ok is false if there
+// wasn't one.
+func (m *Manager) StopTimer(gameID string) (timerID int, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.timerEndsAt.IsZero() {
+		return 0, false
+	}
+	g.timerEndsAt = time.Time{}
+	return g.timer, true
+}
+
+// Timer returns a game's running countdown. ok is false if there isn't
+// one.
+func (m *Manager) Timer(gameID string) (timerID int, endsAt time.Time, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.timerEndsAt.IsZero() {
+		return 0, time.Time{}, false
+	}
+	return g.timer, g.timerEndsAt, true
+}
+
+// ExpireTimer ends a countdown that's run out. ok is false if it was
+// stopped or replaced first.
+func (m *Manager) ExpireTimer(gameID string, timerID int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists || g.timer != timerID || g.timerEndsAt.IsZero() {
+		return false
+	}
+	g.timerEndsAt = time.Time{}
+	return true
+}
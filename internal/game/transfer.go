@@ -0,0 +1,86 @@
+package game
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"bzzz/internal/transport"
+)
+
+// maxRecoveryAttempts is how many wrong recovery PINs a game takes before
+// it stops accepting its PIN, so a short PIN can't be guessed.
+const maxRecoveryAttempts = 5
+
+var (
+	ErrBadHostToken       = errors.New("host token doesn't match this game")
+	ErrBadRecoveryPIN     = errors.New("recovery PIN doesn't match this game")
+	ErrRecoveryLockedOut  = errors.New("too many wrong recovery PINs")
+	ErrNoRecoveryPIN      = errors.New("game has no recovery PIN")
+	ErrTransferNotAllowed = errors.New("host token or recovery PIN required")
+)
+
+// hashRecoveryPIN keeps recovery PINs out of the store, salted with the
+// game's code so the same PIN hashes differently in every game.
+func hashRecoveryPIN(gameID, pin string) string {
+	sum := sha256.Sum256([]byte(gameID + ":" + pin))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetRecoveryPIN lets pin take over a game with TransferHost.
+func (m *Manager) SetRecoveryPIN(gameID, pin string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if g, ok := m.games[gameID]; ok {
+		g.recoveryPIN = hashRecoveryPIN(gameID, pin)
+		m.save(g)
+	}
+}
+
+// TransferHost replaces a game's host token, when token is its current
+// host token or, without one, pin is its recovery PIN. it returns the new
+// token along with the host streams opened with the old one, which the
+// caller closes. the player promoted to stand in for a lost host stands
+// down.
+func (m *Manager) TransferHost(gameID, token, pin string) (hostToken string, old []*transport.Queue, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return "", nil, ErrGameNotFound
+	}
+
+	switch {
+	case token != "":
+		if subtle.ConstantTimeCompare([]byte(g.hostToken), []byte(token)) != 1 {
+			return "", nil, ErrBadHostToken
+		}
+	case pin != "":
+		if g.recoveryPIN == "" {
+			return "", nil, ErrNoRecoveryPIN
+		}
+		if g.recoveryAttempts >= maxRecoveryAttempts {
+			return "", nil, ErrRecoveryLockedOut
+		}
+		if subtle.ConstantTimeCompare([]byte(g.recoveryPIN), []byte(hashRecoveryPIN(gameID, pin))) != 1 {
+			g.recoveryAttempts++
+			m.save(g)
+			return "", nil, ErrBadRecoveryPIN
+		}
+	default:
+		return "", nil, ErrTransferNotAllowed
+	}
+
+	if hostToken, err = NewToken(); err != nil {
+		return "", nil, err
+	}
+	g.hostToken = hostToken
+	g.promoted = ""
+	g.recoveryAttempts = 0
+	old = g.hostQueues()
+	m.save(g)
+	return hostToken, old, nil
+}
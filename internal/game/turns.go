@@ -0,0 +1,84 @@
+package game
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrNotYourTurn = errors.New("it's not your turn")
+
+// RecognitionRoundRobin is the recognition mode where players take turns
+// and only the player whose turn it is can buzz. the default is first to
+// buzz.
+const RecognitionRoundRobin = "roundrobin"
+
+// turnOrder returns the game's players in the order they take turns: seated
+// players by seat, then everyone else in the order they joined. it must be
+// called with m.mu held.
+func (g *game) turnOrder() []*Player {
+	order := make([]*Player, 0, len(g.players))
+	for _, p := range g.players {
+		order = append(order, p)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if (a.Seat > 0) != (b.Seat > 0) {
+			return a.Seat > 0
+		}
+		if a.Seat != b.Seat {
+			return a.Seat < b.Seat
+		}
+		if !a.JoinedAt.Equal(b.JoinedAt) {
+			return a.JoinedAt.Before(b.JoinedAt)
+		}
+		return a.PlayerID < b.PlayerID
+	})
+	return order
+}
+
+// NextTurn passes the turn to the next connected player after the one
+// holding it, returning them and the turn's number. ok is false if nobody
+// is connected to take it.
+func (m *Manager) NextTurn(gameID string) (p Player, turn int, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.games[gameID]
+	if !exists {
+		return Player{}, 0, false
+	}
+
+	order := g.turnOrder()
+	start := 0
+	for i, candidate := range order {
+		if candidate.PlayerID == g.turnPlayer {
+			start = i + 1
+			break
+		}
+	}
+
+	g.turnPlayer = ""
+	for i := 0; i < len(order); i++ {
+		candidate := order[(start+i)%len(order)]
+		if candidate.Connected {
+			g.turnPlayer = candidate.PlayerID
+			p, ok = *candidate, true
+			break
+		}
+	}
+	g.turn++
+	m.save(g)
+	return p, g.turn, ok
+}
+
+// Turn returns the player whose turn it is and the turn's number.
+func (m *Manager) Turn(gameID string) (playerID string, turn int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return "", 0
+	}
+	return g.turnPlayer, g.turn
+}
@@ -0,0 +1,269 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrUndoStale     = errors.New("the game has moved on since, it can't be undone")
+)
+
+// maxUndo caps how many host actions a game remembers for undoing, the
+// oldest are forgotten first.
+const maxUndo = 50
+
+// the host actions that can be undone
+const (
+	// UndoScore is points awarded or corrected by hand
+	UndoScore = "score"
+	// UndoJudgment is a verdict on an answer, with any points it gave
+	UndoJudgment = "judgment"
+	undoKick     = "kick"
+	undoReset    = "reset"
+)
+
+// UndoEntry is a host action on a game's undo stack, with what it takes to
+// reverse it.
+type UndoEntry struct {
+	Action string    `json:"action"`
+	At     time.Time `json:"at"`
+	// PlayerID is who was scored, judged or kicked
+	PlayerID string `json:"playerID,omitempty"`
+	// QuestionID is the question a judgment was on, or the one a reset
+	// moved on from
+	QuestionID int `json:"questionID,omitempty"`
+	// Scores are the ledger entries the action wrote
+	Scores []int `json:"scores,omitempty"`
+	// Kicked is the player a kick removed, Banned whether it banned them
+	Kicked *Player `json:"kicked,omitempty"`
+	Banned bool    `json:"banned,omitempty"`
+	// Buzzes and Locked are what a reset cleared
+	Buzzes []Buzz `json:"buzzes,omitempty"`
+	Locked bool   `json:"locked,omitempty"`
+}
+
+// undoSummary is how an UndoEntry is shown to the host, without the state
+// kept for reversing it.
+type undoSummary struct {
+	Action     string    `json:"action"`
+	At         time.Time `json:"at"`
+	PlayerID   string    `json:"playerID,omitempty"`
+	QuestionID int       `json:"questionID,omitempty"`
+}
+
+func (e UndoEntry) summary() undoSummary {
+	return undoSummary{Action: e.Action, At: e.At, PlayerID: e.PlayerID, QuestionID: e.QuestionID}
+}
+
+// UndoEvent is the data of an undo event.
+type UndoEvent struct {
+	Undone     undoSummary `json:"undone"`
+	PlayerName string      `json:"playerName,omitempty"`
+	// Corrections are the ledger entries putting the scores back
+	Corrections []ScoreEntry `json:"corrections,omitempty"`
+	Leaderboard []Standing   `json:"leaderboard"`
+	// Buzzes are the buzzes an undone reset put back
+	Buzzes []Buzz `json:"buzzes,omitempty"`
+	// Remaining is how many more actions can be undone
+	Remaining int `json:"remaining"`
+}
+
+// pushUndo puts a host action on top of the game's undo stack. it must be
+// called with m.mu held.
+func (g *game) pushUndo(e UndoEntry) {
+	e.At = time.Now()
+	g.undo = append(g.undo, e)
+	if len(g.undo) > maxUndo {
+		g.undo = g.undo[len(g.undo)-maxUndo:]
+	}
+}
+
+// undoReset is what undoing a reset of the current question takes. it must
+// be called with m.mu held, before the reset.
+func (g *game) undoReset() UndoEntry {
+	return UndoEntry{
+		Action:     undoReset,
+		QuestionID: g.question,
+		Buzzes:     append([]Buzz(nil), g.buzzes...),
+		Locked:     g.locked,
+	}
+}
+
+// PushUndo records a host action that can be undone.
+func (m *Manager) PushUndo(gameID string, e UndoEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if g, ok := m.games[gameID]; ok {
+		g.pushUndo(e)
+		m.save(g)
+	}
+}
+
+// UndoLog returns a game's undo stack, the next action to be undone last.
+func (m *Manager) UndoLog(gameID string) []undoSummary {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []undoSummary{}
+	if g, ok := m.games[gameID]; ok {
+		for _, e := range g.undo {
+			entries = append(entries, e.summary())
+		}
+	}
+	return entries
+}
+
+// Undo reverses the host's most recent action still on the stack. scores
+// are put back with corrections so the ledger still shows what happened.
+// an action the game has moved on from, say a reset when someone has since
+// buzzed on the next question, fails with ErrUndoStale but is taken off the
+// stack all the same, so the ones before it can still be undone.
+func (m *Manager) Undo(gameID string) (UndoEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return UndoEvent{}, ErrGameNotFound
+	}
+	if len(g.undo) == 0 {
+		return UndoEvent{}, ErrNothingToUndo
+	}
+	e := g.undo[len(g.undo)-1]
+	g.undo = g.undo[:len(g.undo)-1]
+	defer m.save(g)
+
+	ev := UndoEvent{Undone: e.summary(), Remaining: len(g.undo)}
+	switch e.Action {
+	case UndoJudgment:
+		g.unjudge(e.PlayerID, e.QuestionID)
+	case undoKick:
+		if err := m.unkick(g, e); err != nil {
+			return UndoEvent{}, err
+		}
+	case undoReset:
+		if g.question != e.QuestionID+1 || len(g.buzzes) > 0 {
+			return UndoEvent{}, ErrUndoStale
+		}
+		g.unreset(e)
+		ev.Buzzes = e.Buzzes
+	}
+	for _, entryID := range e.Scores {
+		if c, ok := g.restoreScore(entryID); ok {
+			ev.Corrections = append(ev.Corrections, c)
+		}
+	}
+	if p, ok := g.players[e.PlayerID]; ok {
+		ev.PlayerName = p.Name
+	}
+	ev.Leaderboard = g.leaderboard()
+	return ev, nil
+}
+
+// restoreScore appends a correction putting a ledger entry's points back
+// to what they were before it was written. it must be called with m.mu
+// held.
+func (g *game) restoreScore(entryID int) (ScoreEntry, bool) {
+	e, ok := g.scoreEntry(entryID)
+	if !ok {
+		return ScoreEntry{}, false
+	}
+
+	// an award or adjustment wasn't worth anything before, a correction
+	// goes back to whatever the entry it corrected was worth before it
+	c := ScoreEntry{Kind: ScoreKindCorrection, PlayerID: e.PlayerID, Reason: "undo", Corrects: e.ID}
+	if e.Kind == ScoreKindCorrection {
+		prev, _ := g.scoreEntry(e.Corrects)
+		for _, s := range g.scores[:e.ID-1] {
+			if s.Kind == ScoreKindCorrection && s.Corrects == e.Corrects {
+				prev = s
+			}
+		}
+		c.PlayerID, c.Points, c.Corrects = prev.PlayerID, prev.Points, e.Corrects
+	}
+	c.QuestionID, c.Round = e.QuestionID, e.Round
+
+	c.ID = len(g.scores) + 1
+	c.At = time.Now()
+	g.scores = append(g.scores, c)
+	return c, true
+}
+
+// unjudge takes back the verdict on a player's latest judged answer to a
+// question, forgetting the answer if the host judged it without one being
+// submitted. it must be called with m.mu held.
+func (g *game) unjudge(playerID string, questionID int) {
+	for i := len(g.answers) - 1; i >= 0; i-- {
+		a := g.answers[i]
+		if a.PlayerID != playerID || a.QuestionID != questionID || a.Correct == nil {
+			continue
+		}
+		if a.Text == "" {
+			g.answers = append(g.answers[:i], g.answers[i+1:]...)
+		} else {
+			g.answers[i].Correct, g.answers[i].Points, g.answers[i].JudgedAt = nil, 0, nil
+		}
+		return
+	}
+}
+
+// unkick puts a kicked player back in the game, lifting their ban. they
+// come back disconnected and reconnect with their token. it must be called
+// with m.mu held.
+func (m *Manager) unkick(g *game, e UndoEntry) error {
+	if e.Kicked == nil {
+		return ErrUndoStale
+	}
+	p := *e.Kicked
+	if _, taken := g.players[p.PlayerID]; taken {
+		return ErrUndoStale
+	}
+	ok, err := m.store.ReserveID(playerIDSpace, p.PlayerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUndoStale
+	}
+
+	for _, other := range g.players {
+		if p.Seat > 0 && other.Seat == p.Seat {
+			p.Seat = 0
+		}
+	}
+	p.Connected = false
+	g.players[p.PlayerID] = &p
+	m.players[p.PlayerID] = g.id
+	if e.Banned {
+		delete(g.banned, banKey(p.Name))
+		delete(g.banned, p.Token)
+	}
+	return nil
+}
+
+// unreset puts back the question a reset moved on from, buzzes and all. it
+// must be called with m.mu held.
+func (g *game) unreset(e UndoEntry) {
+	// the buzzes were moved into the round's history and stats
+	if g.roundActive && len(g.rounds) > 0 {
+		r := &g.rounds[len(g.rounds)-1]
+		if n := len(r.Buzzes) - len(e.Buzzes); n >= 0 {
+			r.Buzzes = r.Buzzes[:n]
+		}
+	}
+	untallyBuzzes(g.stats, e.Buzzes)
+
+	g.question = e.QuestionID
+	g.buzzes = e.Buzzes
+	g.buzzed, g.late = map[string]bool{}, map[string]bool{}
+	for _, b := range e.Buzzes {
+		g.buzzed[b.PlayerID] = true
+		if b.Late {
+			g.late[b.PlayerID] = true
+		}
+	}
+	g.locked = e.Locked
+}
@@ -0,0 +1,214 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoWagerRound  = errors.New("no wager round has been opened")
+	ErrWagersClosed  = errors.New("wagers are closed")
+	ErrWagersOpen    = errors.New("wagers are still open")
+	ErrWagerTooHigh  = errors.New("wager is more than the player can stake")
+	ErrNoWager       = errors.New("player hasn't wagered")
+	ErrWagerRevealed = errors.New("wager already revealed")
+)
+
+// WagerRound is a round where every player privately stakes points on a
+// single question, Final Jeopardy style. the host opens it, players wager
+// and answer until the host closes it, then the host reveals and judges
+// each wager in turn, the player winning or losing what they staked.
+type WagerRound struct {
+	// Category is what the host tells players before they wager
+	Category string `json:"category,omitempty"`
+	// MaxWager is what anyone can stake, even with a lower score. players
+	// ahead can stake up to their score
+	MaxWager int          `json:"maxWager,omitempty"`
+	Open     bool         `json:"open"`
+	OpenedAt time.Time    `json:"openedAt"`
+	ClosedAt *time.Time   `json:"closedAt,omitempty"`
+	Entries  []WagerEntry `json:"entries"`
+}
+
+// WagerEntry is a player's wager and answer.
+type WagerEntry struct {
+	PlayerID   string    `json:"playerID"`
+	PlayerName string    `json:"playerName"`
+	Wager      int       `json:"wager"`
+	Answer     string    `json:"answer,omitempty"`
+	At         time.Time `json:"at"`
+	// Correct is nil until the host reveals the wager
+	Correct    *bool      `json:"correct,omitempty"`
+	RevealedAt *time.Time `json:"revealedAt,omitempty"`
+}
+
+// copy returns a copy of the round that shares nothing with it.
+func (w *WagerRound) copy() WagerRound {
+	c := *w
+	c.Entries = append([]WagerEntry{}, w.Entries...)
+	return c
+}
+
+// entry returns the index of a player's wager, or -1.
+func (w *WagerRound) entry(playerID string) int {
+	for i, e := range w.Entries {
+		if e.PlayerID == playerID {
+			return i
+		}
+	}
+	return -1
+}
+
+// maxStake is the most a player can wager, their score or the round's
+// MaxWager, whichever is higher. it must be called with m.mu held.
+func (g *game) maxStake(playerID string) int {
+	stake := g.wager.MaxWager
+	for _, s := range g.leaderboard() {
+		if s.PlayerID == playerID && s.Points > stake {
+			stake = s.Points
+		}
+	}
+	return stake
+}
+
+// OpenWagers starts a wager round, replacing the last one.
+func (m *Manager) OpenWagers(gameID, category string, maxWager int) (WagerRound, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return WagerRound{}, ErrGameNotFound
+	}
+	g.wager = &WagerRound{
+		Category: category,
+		MaxWager: maxWager,
+		Open:     true,
+		OpenedAt: time.Now(),
+		Entries:  []WagerEntry{},
+	}
+	m.save(g)
+	return g.wager.copy(), nil
+}
+
+// SubmitWager records a player's wager, their answer or both while wagers
+// are open. either can be changed until they close, a nil wager keeps the
+// one already made. a wager over what the player can stake fails with
+// ErrWagerTooHigh, returning the most they can.
+func (m *Manager) SubmitWager(gameID, playerID string, wager *int, text string) (e WagerEntry, maxStake int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return WagerEntry{}, 0, ErrGameNotFound
+	}
+	p, ok := g.players[playerID]
+	if !ok {
+		return WagerEntry{}, 0, ErrPlayerNotFound
+	}
+	if g.wager == nil {
+		return WagerEntry{}, 0, ErrNoWagerRound
+	}
+	if !g.wager.Open {
+		return WagerEntry{}, 0, ErrWagersClosed
+	}
+
+	i := g.wager.entry(playerID)
+	e = WagerEntry{PlayerID: playerID}
+	if i >= 0 {
+		e = g.wager.Entries[i]
+	}
+	if wager != nil {
+		if max := g.maxStake(playerID); *wager > max {
+			return WagerEntry{}, max, ErrWagerTooHigh
+		}
+		e.Wager = *wager
+	}
+	if text != "" {
+		e.Answer = text
+	}
+	e.PlayerName = p.Name
+	e.At = time.Now()
+	if i >= 0 {
+		g.wager.Entries[i] = e
+	} else {
+		g.wager.Entries = append(g.wager.Entries, e)
+	}
+	m.save(g)
+	return e, 0, nil
+}
+
+// CloseWagers stops players wagering or changing their answers.
+func (m *Manager) CloseWagers(gameID string) (WagerRound, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return WagerRound{}, ErrGameNotFound
+	}
+	if g.wager == nil {
+		return WagerRound{}, ErrNoWagerRound
+	}
+	if !g.wager.Open {
+		return WagerRound{}, ErrWagersClosed
+	}
+
+	now := time.Now()
+	g.wager.Open = false
+	g.wager.ClosedAt = &now
+	m.save(g)
+	return g.wager.copy(), nil
+}
+
+// RevealWager judges a player's answer once wagers have closed, returning
+// the wager along with the points it wins or loses.
+func (m *Manager) RevealWager(gameID, playerID string, correct bool) (e WagerEntry, points int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return WagerEntry{}, 0, ErrGameNotFound
+	}
+	if g.wager == nil {
+		return WagerEntry{}, 0, ErrNoWagerRound
+	}
+	if g.wager.Open {
+		return WagerEntry{}, 0, ErrWagersOpen
+	}
+	i := g.wager.entry(playerID)
+	if i < 0 {
+		return WagerEntry{}, 0, ErrNoWager
+	}
+	if g.wager.Entries[i].Correct != nil {
+		return WagerEntry{}, 0, ErrWagerRevealed
+	}
+
+	now := time.Now()
+	e = g.wager.Entries[i]
+	e.Correct = &correct
+	e.RevealedAt = &now
+	g.wager.Entries[i] = e
+	g.tallyVerdict(playerID, correct)
+	m.save(g)
+
+	points = e.Wager
+	if !correct {
+		points = -points
+	}
+	return e, points, nil
+}
+
+// Wagers returns a game's current or last wager round.
+func (m *Manager) Wagers(gameID string) (WagerRound, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok || g.wager == nil {
+		return WagerRound{}, false
+	}
+	return g.wager.copy(), true
+}
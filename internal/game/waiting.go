@@ -0,0 +1,94 @@
+package game
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// ErrWaiting is returned by Join along with the new player when the game is
+// full and they've been put in its waiting room instead.
+var ErrWaiting = errors.New("game is full, waiting for a slot")
+
+// waitingPlayer is a player in a game's waiting room. admitted is closed
+// once they've been given a slot, or the game has ended.
+type waitingPlayer struct {
+	Player
+	admitted chan struct{}
+}
+
+// full reports whether a game's player cap leaves no room for one more. it
+// must be called with m.mu held.
+func (g *game) full() bool {
+	return g.options.MaxPlayers > 0 && len(g.players) >= g.options.MaxPlayers
+}
+
+// wait puts a player at the back of a game's waiting room. it must be
+// called with m.mu held.
+func (g *game) wait(p Player) {
+	g.waiting = append(g.waiting, &waitingPlayer{Player: p, admitted: make(chan struct{})})
+}
+
+// Waiting returns a waiting player's place in line, counting from 1, and
+// a channel closed once they're admitted. ok is false if the player isn't
+// waiting.
+func (m *Manager) Waiting(gameID, playerID string) (position int, admitted <-chan struct{}, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return 0, nil, false
+	}
+	for i, w := range g.waiting {
+		if w.PlayerID == playerID {
+			return i + 1, w.admitted, true
+		}
+	}
+	return 0, nil, false
+}
+
+// WaitingByToken finds the player in a game's waiting room holding token.
+func (m *Manager) WaitingByToken(gameID, token string) (Player, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok || token == "" {
+		return Player{}, false
+	}
+	for _, w := range g.waiting {
+		if subtle.ConstantTimeCompare([]byte(w.Token), []byte(token)) == 1 {
+			return w.Player, true
+		}
+	}
+	return Player{}, false
+}
+
+// AdmitWaiting moves players from the front of a game's waiting room into
+// the game while it has free slots, and returns them.
+func (m *Manager) AdmitWaiting(gameID string) []Player {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+
+	var admitted []Player
+	for len(g.waiting) > 0 && !g.full() {
+		w := g.waiting[0]
+		g.waiting = g.waiting[1:]
+
+		p := w.Player
+		g.joinTeam(&p)
+		g.players[p.PlayerID] = &p
+		m.players[p.PlayerID] = gameID
+		close(w.admitted)
+		admitted = append(admitted, p)
+	}
+	if len(admitted) > 0 {
+		m.save(g)
+	}
+	return admitted
+}
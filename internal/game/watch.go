@@ -0,0 +1,47 @@
+package game
+
+import (
+	"bzzz/internal/transport"
+)
+
+// AddSpectator registers a queue that's told whenever a game's state
+// changes. spectators aren't players, they don't show up in the roster.
+func (m *Manager) AddSpectator(gameID string) (*transport.Queue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	q := m.queues.NewQueue()
+	g.spectators[q] = true
+	return q, nil
+}
+
+// RemoveSpectator stops feeding a spectator queue and closes it.
+func (m *Manager) RemoveSpectator(gameID string, q *transport.Queue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if g, ok := m.games[gameID]; ok {
+		delete(g.spectators, q)
+	}
+	q.Close()
+}
+
+// Spectators returns the spectator queues open on a game.
+func (m *Manager) Spectators(gameID string) []*transport.Queue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.games[gameID]
+	if !ok {
+		return nil
+	}
+	qs := make([]*transport.Queue, 0, len(g.spectators))
+	for q := range g.spectators {
+		qs = append(qs, q)
+	}
+	return qs
+}
@@ -0,0 +1,42 @@
+package game
+
+import (
+	"fmt"
+)
+
+// WebhookEndpoint is where a tenant wants game events POSTed. payloads are
+// signed with Secret so the receiver can tell they came from us.
+type WebhookEndpoint struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	// Events limits which actions are sent, empty means all of them
+	Events []string `json:"events,omitempty"`
+}
+
+func (e WebhookEndpoint) Wants(action string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, ev := range e.Events {
+		if ev == action {
+			return true
+		}
+	}
+	return false
+}
+
+// SetWebhooks gives a new game its own webhook endpoints, named after the
+// game so their dead letters can be told apart from the tenant's.
+func (m *Manager) SetWebhooks(gameID string, endpoints []WebhookEndpoint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if g, ok := m.games[gameID]; ok {
+		for i := range endpoints {
+			endpoints[i].ID = fmt.Sprintf("game-%s-%d", gameID, i+1)
+		}
+		g.options.Webhooks = endpoints
+		m.save(g)
+	}
+}
@@ -0,0 +1,542 @@
+// Package qr encodes short text, such as a join link, as a QR code and
+// draws it as a PNG or SVG.
+//
+// it only does what the server needs: byte mode, error correction level M,
+// which survives a smudged projector screen, and versions 1 to 10, which
+// hold up to 213 bytes. links longer than that fail with ErrTooLong.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// ErrTooLong is returned for text too long to fit the largest version.
+var ErrTooLong = errors.New("qr: text too long")
+
+// Quiet is the light border around a code, in modules, that scanners need
+// to find it.
+const Quiet = 4
+
+// version is the layout of one QR version at level M: how its codewords
+// are split into blocks, and where its alignment patterns go.
+type version struct {
+	// blocks are the data codewords in each block, ec the error correction
+	// codewords added to each
+	blocks []int
+	ec     int
+	align  []int
+}
+
+// versions are versions 1 to 10 at level M, from the tables in ISO/IEC
+// 18004.
+var versions = []version{
+	{blocks: []int{16}, ec: 10},
+	{blocks: []int{28}, ec: 16, align: []int{6, 18}},
+	{blocks: []int{44}, ec: 26, align: []int{6, 22}},
+	{blocks: []int{32, 32}, ec: 18, align: []int{6, 26}},
+	{blocks: []int{43, 43}, ec: 24, align: []int{6, 30}},
+	{blocks: []int{27, 27, 27, 27}, ec: 16, align: []int{6, 34}},
+	{blocks: []int{31, 31, 31, 31}, ec: 18, align: []int{6, 22, 38}},
+	{blocks: []int{38, 38, 39, 39}, ec: 22, align: []int{6, 24, 42}},
+	{blocks: []int{36, 36, 36, 37, 37}, ec: 22, align: []int{6, 26, 46}},
+	{blocks: []int{43, 43, 43, 43, 44}, ec: 26, align: []int{6, 28, 50}},
+}
+
+func (v version) dataCodewords() int {
+	n := 0
+	for _, b := range v.blocks {
+		n += b
+	}
+	return n
+}
+
+// Code is an encoded QR code, Size modules square.
+type Code struct {
+	Size    int
+	Version int
+	modules [][]bool
+	// function marks the modules of the finder, timing, alignment, format
+	// and version patterns, which carry no data and aren't masked
+	function [][]bool
+}
+
+// Encode encodes text as the smallest QR code that holds it.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for i, v := range versions {
+		n := i + 1
+		countBits := 8
+		if n >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) > 8*v.dataCodewords() {
+			continue
+		}
+
+		c := &Code{Size: 17 + 4*n, Version: n}
+		c.modules = grid(c.Size)
+		c.function = grid(c.Size)
+		c.drawFunctionPatterns(v)
+		c.drawCodewords(v.interleave(v.codewords(data, countBits)))
+		c.applyBestMask()
+		return c, nil
+	}
+	return nil, ErrTooLong
+}
+
+func grid(size int) [][]bool {
+	g := make([][]bool, size)
+	for y := range g {
+		g[y] = make([]bool, size)
+	}
+	return g
+}
+
+// Dark reports whether the module at column x, row y is dark. modules
+// outside the code, in its quiet zone, are light.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// bitBuffer collects bits, most significant first.
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, value>>uint(i)&1 == 1)
+	}
+}
+
+func (b *bitBuffer) bytes() []byte {
+	out := make([]byte, (len(b.bits)+7)/8)
+	for i, bit := range b.bits {
+		if bit {
+			out[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return out
+}
+
+// codewords are data in byte mode, terminated and padded out to the
+// version's data capacity.
+func (v version) codewords(data []byte, countBits int) []byte {
+	capacity := 8 * v.dataCodewords()
+	var b bitBuffer
+	b.append(0x4, 4)
+	b.append(len(data), countBits)
+	for _, d := range data {
+		b.append(int(d), 8)
+	}
+	terminator := capacity - len(b.bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	b.append(0, terminator)
+	b.append(0, (8-len(b.bits)%8)%8)
+
+	out := b.bytes()
+	for pad := 0; len(out) < v.dataCodewords(); pad++ {
+		out = append(out, []byte{0xec, 0x11}[pad%2])
+	}
+	return out
+}
+
+// interleave splits data into the version's blocks, adds each one's error
+// correction, and interleaves them in the order they're drawn in.
+func (v version) interleave(data []byte) []byte {
+	divisor := rsDivisor(v.ec)
+	blocks := make([][]byte, len(v.blocks))
+	ecs := make([][]byte, len(v.blocks))
+	longest := 0
+	for i, n := range v.blocks {
+		blocks[i], data = data[:n], data[n:]
+		ecs[i] = rsRemainder(blocks[i], divisor)
+		if n > longest {
+			longest = n
+		}
+	}
+
+	out := []byte{}
+	for i := 0; i < longest; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ec; i++ {
+		for _, ec := range ecs {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial for degree error
+// correction codewords, highest power first without its leading 1.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords for data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFunctionPatterns draws everything but the data, with format bits for
+// mask 0 to reserve their modules until the mask is picked.
+func (c *Code) drawFunctionPatterns(v version) {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// finders, with their light separators
+	for _, at := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := at[0]+dx, at[1]+dy
+				if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				c.setFunction(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// alignment patterns go everywhere on the grid but over the finders
+	last := len(v.align) - 1
+	for i, ax := range v.align {
+		for j, ay := range v.align {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// drawFormatBits draws both copies of the format information for level M
+// and mask, and the dark module beside the bottom one.
+func (c *Code) drawFormatBits(mask int) {
+	// level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// drawVersion draws both copies of the version information, which only
+// versions 7 and up have.
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	bits := c.Version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 == 1
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords lays the codewords into the data modules, in two module
+// wide columns zigzagging up and down from the bottom right. modules left
+// over are the remainder bits and stay light.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// the vertical timing pattern is skipped
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y][x] = data[i/8]>>uint(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// masked reports whether mask flips the module at column x, row y.
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask flips the data modules picked by mask. applying it twice
+// undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask applies whichever mask leaves the code with the fewest
+// features that confuse scanners.
+func (c *Code) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+// penalty scores the code by the four rules in ISO/IEC 18004: long runs of
+// one color, 2x2 blocks of one color, patterns that look like finders, and
+// an uneven balance of dark and light.
+func (c *Code) penalty() int {
+	p := 0
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, line := range c.lines() {
+		run := 1
+		for i := 1; i <= len(line); i++ {
+			if i < len(line) && line[i] == line[i-1] {
+				run++
+				continue
+			}
+			if run >= 5 {
+				p += 3 + run - 5
+			}
+			run = 1
+		}
+
+		for i := 0; i+len(finder) <= len(line); i++ {
+			if !matches(line[i:i+len(finder)], finder) {
+				continue
+			}
+			if light(line, i-4, i) || light(line, i+len(finder), i+len(finder)+4) {
+				p += 40
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				m := c.modules[y][x]
+				if c.modules[y][x-1] == m && c.modules[y-1][x] == m && c.modules[y-1][x-1] == m {
+					p += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+// lines returns every row and column of the code.
+func (c *Code) lines() [][]bool {
+	lines := make([][]bool, 0, 2*c.Size)
+	for y := 0; y < c.Size; y++ {
+		lines = append(lines, c.modules[y])
+	}
+	for x := 0; x < c.Size; x++ {
+		col := make([]bool, c.Size)
+		for y := range col {
+			col[y] = c.modules[y][x]
+		}
+		lines = append(lines, col)
+	}
+	return lines
+}
+
+func matches(a, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// light reports whether line[from:to] is all light, counting modules past
+// its ends, in the quiet zone, as light.
+func light(line []bool, from, to int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Image draws the code with scale pixels to a module, quiet zone included.
+func (c *Code) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	side := (c.Size + 2*Quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for py := 0; py < side; py++ {
+		for px := 0; px < side; px++ {
+			if c.Dark(px/scale-Quiet, py/scale-Quiet) {
+				img.SetColorIndex(px, py, 1)
+			}
+		}
+	}
+	return img
+}
+
+// PNG draws the code as a PNG with scale pixels to a module.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.Image(scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG draws the code as an SVG, one unit to a module, as a single path so
+// it stays small and scales to any size without blurring.
+func (c *Code) SVG() string {
+	side := c.Size + 2*Quiet
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, side, side)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, side, side)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+Quiet, y+Quiet)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.String()
+}
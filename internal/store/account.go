@@ -0,0 +1,74 @@
+package store
+
+import (
+	"time"
+)
+
+// AccountKey is one of an account's API keys. only its hash is kept, the key
+// itself is shown once when it's issued.
+type AccountKey struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash,omitempty"`
+	// Hint is the end of the key, to tell keys apart
+	Hint      string    `json:"hint"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HostAccount is a registered host.
+type HostAccount struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	// TenantID is the tenant the account was registered with, the only
+	// one it can create games for
+	TenantID  string       `json:"tenantID,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Keys      []AccountKey `json:"keys"`
+	// Games are the games the account created, oldest first, whose
+	// history it can read without their host tokens
+	Games []AccountGame `json:"games"`
+}
+
+// AccountGame is a game a host account created.
+type AccountGame struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	// HostToken is the token the game was created with, which its history
+	// is checked against in case the code has since been reused
+	HostToken string `json:"hostToken,omitempty"`
+}
+
+// Public returns the account without its key hashes and host tokens, to
+// send back.
+func (a HostAccount) Public() HostAccount {
+	keys := make([]AccountKey, len(a.Keys))
+	for i, k := range a.Keys {
+		k.Hash = ""
+		keys[i] = k
+	}
+	a.Keys = keys
+	games := make([]AccountGame, len(a.Games))
+	for i, g := range a.Games {
+		g.HostToken = ""
+		games[i] = g
+	}
+	a.Games = games
+	return a
+}
+
+// HostToken returns the host token the account created a game with, false
+// if it didn't create it.
+func (a HostAccount) HostToken(gameID string) (string, bool) {
+	for i := len(a.Games) - 1; i >= 0; i-- {
+		if a.Games[i].ID == gameID {
+			return a.Games[i].HostToken, true
+		}
+	}
+	return "", false
+}
+
+// Owner is what the account's templates are saved under, kept apart from
+// the tenant IDs shared templates use.
+func (a HostAccount) Owner() string {
+	return "account:" + a.ID
+}
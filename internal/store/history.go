@@ -0,0 +1,29 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HistoryEntry is one thing that happened in a game, as kept after the game
+// has ended.
+type HistoryEntry struct {
+	Seq        int             `json:"seq"`
+	Txn        int             `json:"txn,omitempty"`
+	At         time.Time       `json:"at"`
+	Action     string          `json:"action"`
+	PlayerID   string          `json:"playerID,omitempty"`
+	PlayerName string          `json:"playerName,omitempty"`
+	Team       string          `json:"team,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	// MonotonicMs is how long after the game started the entry happened,
+	// see game.SinceStart
+	MonotonicMs float64 `json:"monotonicMs"`
+}
+
+// GameHistory is a game's history along with the host token that can read
+// it, which has to outlive the game itself.
+type GameHistory struct {
+	HostToken string         `json:"-"`
+	Entries   []HistoryEntry `json:"entries"`
+}
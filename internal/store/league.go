@@ -0,0 +1,82 @@
+package store
+
+import (
+	"sort"
+)
+
+// TeamTally is a team's record across a tenant's games.
+type TeamTally struct {
+	Games int64 `json:"games"`
+	Wins  int64 `json:"wins"`
+}
+
+// reactionTally sums reaction times for one type of round.
+type reactionTally struct {
+	Buzzes  int64   `json:"buzzes"`
+	TotalMs float64 `json:"totalMs"`
+}
+
+// LeagueStats are the aggregates a tenant's league software cares about,
+// built up as games end.
+type LeagueStats struct {
+	// Teams is keyed by team name
+	Teams map[string]TeamTally `json:"teams"`
+	// Reactions is keyed by round type
+	Reactions map[string]reactionTally `json:"reactions"`
+}
+
+func NewLeagueStats() LeagueStats {
+	return LeagueStats{Teams: map[string]TeamTally{}, Reactions: map[string]reactionTally{}}
+}
+
+func (s *LeagueStats) add(o LeagueStats) {
+	for name, t := range o.Teams {
+		total := s.Teams[name]
+		total.Games += t.Games
+		total.Wins += t.Wins
+		s.Teams[name] = total
+	}
+	for kind, r := range o.Reactions {
+		total := s.Reactions[kind]
+		total.Buzzes += r.Buzzes
+		total.TotalMs += r.TotalMs
+		s.Reactions[kind] = total
+	}
+}
+
+type TeamReport struct {
+	Team    string  `json:"team"`
+	Games   int64   `json:"games"`
+	Wins    int64   `json:"wins"`
+	WinRate float64 `json:"winRate"`
+}
+
+type ReactionReport struct {
+	RoundType string  `json:"roundType"`
+	Buzzes    int64   `json:"buzzes"`
+	AverageMs float64 `json:"averageMs"`
+}
+
+// Report turns league stats into sorted per-team and per-round-type rows.
+func (s LeagueStats) Report() ([]TeamReport, []ReactionReport) {
+	teams := make([]TeamReport, 0, len(s.Teams))
+	for name, t := range s.Teams {
+		tr := TeamReport{Team: name, Games: t.Games, Wins: t.Wins}
+		if t.Games > 0 {
+			tr.WinRate = float64(t.Wins) / float64(t.Games)
+		}
+		teams = append(teams, tr)
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Team < teams[j].Team })
+
+	reactions := make([]ReactionReport, 0, len(s.Reactions))
+	for kind, r := range s.Reactions {
+		rr := ReactionReport{RoundType: kind, Buzzes: r.Buzzes}
+		if r.Buzzes > 0 {
+			rr.AverageMs = r.TotalMs / float64(r.Buzzes)
+		}
+		reactions = append(reactions, rr)
+	}
+	sort.Slice(reactions, func(i, j int) bool { return reactions[i].RoundType < reactions[j].RoundType })
+	return teams, reactions
+}
@@ -0,0 +1,742 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"bzzz/internal/game"
+)
+
+// postgresConnLifetime is how long a pooled connection is reused before
+// it's reopened, so the pool follows a database that fails over
+const postgresConnLifetime = 30 * time.Minute
+
+// postgresMigrationLock is the advisory lock replicas take while they
+// migrate, so two starting together don't both run the same migration
+const postgresMigrationLock = 0x627a7a7a
+
+// postgresMigrations bring a database up to the schema the store expects,
+// in order. each runs once, in a transaction, and is recorded in
+// schema_migrations. they're only ever added to, never changed once
+// released.
+var postgresMigrations = []string{
+	// 1: the same tables as the sqlite store, with the columns reporting
+	// queries want on games
+	`
+CREATE TABLE games (
+	id TEXT PRIMARY KEY,
+	tenant_id TEXT NOT NULL DEFAULT '',
+	started_at TIMESTAMPTZ NOT NULL,
+	ended_at TIMESTAMPTZ,
+	record JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX games_tenant ON games (tenant_id, started_at);
+CREATE TABLE players (
+	game_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	player_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	team TEXT NOT NULL,
+	waiting BOOLEAN NOT NULL,
+	record JSONB NOT NULL,
+	PRIMARY KEY (game_id, waiting, position)
+);
+CREATE TABLE scores (
+	game_id TEXT NOT NULL,
+	id INTEGER NOT NULL,
+	player_id TEXT NOT NULL,
+	points INTEGER NOT NULL,
+	record JSONB NOT NULL,
+	PRIMARY KEY (game_id, id)
+);
+CREATE INDEX scores_player ON scores (player_id);
+CREATE TABLE ids (
+	key TEXT PRIMARY KEY
+);
+CREATE TABLE usage (
+	tenant_id TEXT NOT NULL,
+	period TEXT NOT NULL,
+	games_created BIGINT NOT NULL DEFAULT 0,
+	connection_seconds BIGINT NOT NULL DEFAULT 0,
+	events_delivered BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (tenant_id, period)
+);
+CREATE TABLE league_teams (
+	tenant_id TEXT NOT NULL,
+	team TEXT NOT NULL,
+	games INTEGER NOT NULL DEFAULT 0,
+	wins INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (tenant_id, team)
+);
+CREATE TABLE league_reactions (
+	tenant_id TEXT NOT NULL,
+	round_type TEXT NOT NULL,
+	buzzes INTEGER NOT NULL DEFAULT 0,
+	total_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+	PRIMARY KEY (tenant_id, round_type)
+);
+CREATE TABLE logs (
+	game_id TEXT PRIMARY KEY,
+	host_token TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX logs_expires_at ON logs (expires_at);
+CREATE TABLE events (
+	seq BIGSERIAL PRIMARY KEY,
+	game_id TEXT NOT NULL,
+	log TEXT NOT NULL,
+	entry JSONB NOT NULL
+);
+CREATE INDEX events_game ON events (game_id, log, seq);
+CREATE TABLE templates (
+	owner TEXT NOT NULL,
+	name TEXT NOT NULL,
+	record JSONB NOT NULL,
+	PRIMARY KEY (owner, name)
+);
+CREATE TABLE accounts (
+	id TEXT PRIMARY KEY,
+	record JSONB NOT NULL
+);
+CREATE TABLE account_keys (
+	hash TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL
+);
+`,
+}
+
+// postgresStore keeps everything in a Postgres database, for organizations
+// that want their games' history somewhere durable they can run reports
+// against, and for several replicas sharing one database. it's laid out
+// like the sqlite store, with records as JSONB, and games also carry their
+// tenant and when they started and ended as columns of their own.
+//
+// the schema is migrated on startup, see postgresMigrations. the pool holds
+// up to cfg.PostgresMaxConns connections.
+type postgresStore struct {
+	db *sql.DB
+	// retention is how long a game's history is kept after its last entry
+	retention time.Duration
+}
+
+func newPostgresStore(dsn string, maxConns int, retention time.Duration) (*postgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+	db.SetConnMaxLifetime(postgresConnLifetime)
+
+	s := &postgresStore{db: db, retention: retention}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("postgres: %v", err)
+	}
+	return s, nil
+}
+
+// migrate runs the migrations the database hasn't had yet.
+func (s *postgresStore) migrate() error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+			return err
+		}
+
+		var version int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+			return err
+		}
+		if version > len(postgresMigrations) {
+			return fmt.Errorf("database is at schema version %d, newer than this server's %d", version, len(postgresMigrations))
+		}
+		for i := version; i < len(postgresMigrations); i++ {
+			if _, err := tx.Exec(postgresMigrations[i]); err != nil {
+				return fmt.Errorf("migration %d: %v", i+1, err)
+			}
+			if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, i+1); err != nil {
+				return err
+			}
+			log.Printf("postgres: migrated to schema version %d", i+1)
+		}
+		return nil
+	})
+}
+
+// Close closes the connection pool.
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *postgresStore) Ping() error {
+	return s.db.Ping()
+}
+
+// inTx runs f in a transaction, committing it if f succeeds.
+func (s *postgresStore) inTx(f func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) SaveGame(rec game.GameRecord, since *game.GameRecord) error {
+	players, waiting, scores := rec.Players, rec.Waiting, rec.Scores
+	rec.Players, rec.Waiting, rec.Scores = nil, nil, nil
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var was game.GameRecord
+	if since != nil {
+		was = *since
+	}
+
+	// players and scores have rows of their own, only the ones that
+	// changed since the last save are written
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`INSERT INTO games (id, tenant_id, started_at, ended_at, record, updated_at) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO UPDATE SET ended_at = excluded.ended_at, record = excluded.record, updated_at = excluded.updated_at`,
+			rec.ID, rec.Options.Tenant, rec.StartedAt, rec.EndedAt, string(b), time.Now()); err != nil {
+			return err
+		}
+		for i, lists := range [][2][]game.Player{{players, was.Players}, {waiting, was.Waiting}} {
+			changed, whole := changedPlayers(lists[0], lists[1], since != nil)
+			if whole {
+				if _, err := tx.Exec(`DELETE FROM players WHERE game_id = $1 AND waiting = $2`, rec.ID, i == 1); err != nil {
+					return err
+				}
+				changed = changed[:0]
+				for pos := range lists[0] {
+					changed = append(changed, pos)
+				}
+			}
+			for _, pos := range changed {
+				p := lists[0][pos]
+				b, err := json.Marshal(p)
+				if err != nil {
+					return err
+				}
+				if _, err := tx.Exec(`INSERT INTO players (game_id, position, player_id, name, team, waiting, record) VALUES ($1, $2, $3, $4, $5, $6, $7)
+					ON CONFLICT (game_id, waiting, position) DO UPDATE SET player_id = excluded.player_id, name = excluded.name, team = excluded.team, record = excluded.record`,
+					rec.ID, pos, p.PlayerID, p.Name, p.Team, i == 1, string(b)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if since == nil {
+			if _, err := tx.Exec(`DELETE FROM scores WHERE game_id = $1`, rec.ID); err != nil {
+				return err
+			}
+		}
+		changed, gone := changedScores(scores, was.Scores)
+		for _, id := range gone {
+			if _, err := tx.Exec(`DELETE FROM scores WHERE game_id = $1 AND id = $2`, rec.ID, id); err != nil {
+				return err
+			}
+		}
+		for _, e := range changed {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO scores (game_id, id, player_id, points, record) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (game_id, id) DO UPDATE SET player_id = excluded.player_id, points = excluded.points, record = excluded.record`,
+				rec.ID, e.ID, e.PlayerID, e.Points, string(b)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *postgresStore) DeleteGame(gameID string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for _, q := range []string{
+			`DELETE FROM games WHERE id = $1`,
+			`DELETE FROM players WHERE game_id = $1`,
+			`DELETE FROM scores WHERE game_id = $1`,
+		} {
+			if _, err := tx.Exec(q, gameID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *postgresStore) LoadGames() ([]game.GameRecord, error) {
+	return s.loadGames("")
+}
+
+func (s *postgresStore) LoadGame(gameID string) (game.GameRecord, bool, error) {
+	recs, err := s.loadGames(gameID)
+	if err != nil || len(recs) == 0 {
+		return game.GameRecord{}, false, err
+	}
+	return recs[0], true, nil
+}
+
+// loadGames reads games back with their players and scores, every one of
+// them if gameID is empty.
+func (s *postgresStore) loadGames(gameID string) ([]game.GameRecord, error) {
+	var whereGame, whereChild string
+	var args []interface{}
+	if gameID != "" {
+		whereGame, whereChild = ` WHERE id = $1`, ` WHERE game_id = $1`
+		args = append(args, gameID)
+	}
+
+	var recs []game.GameRecord
+	err := s.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT id, record FROM games`+whereGame, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		byID := map[string]int{}
+		for rows.Next() {
+			var id string
+			var b []byte
+			if err := rows.Scan(&id, &b); err != nil {
+				return err
+			}
+			var rec game.GameRecord
+			if err := json.Unmarshal(b, &rec); err != nil {
+				return fmt.Errorf("game %s: %v", id, err)
+			}
+			byID[id] = len(recs)
+			recs = append(recs, rec)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		players, err := tx.Query(`SELECT game_id, waiting, record FROM players`+whereChild+` ORDER BY game_id, waiting, position`, args...)
+		if err != nil {
+			return err
+		}
+		defer players.Close()
+		for players.Next() {
+			var id string
+			var b []byte
+			var waiting bool
+			if err := players.Scan(&id, &waiting, &b); err != nil {
+				return err
+			}
+			i, ok := byID[id]
+			if !ok {
+				continue
+			}
+			var p game.Player
+			if err := json.Unmarshal(b, &p); err != nil {
+				return fmt.Errorf("player in game %s: %v", id, err)
+			}
+			if waiting {
+				recs[i].Waiting = append(recs[i].Waiting, p)
+			} else {
+				recs[i].Players = append(recs[i].Players, p)
+			}
+		}
+		if err := players.Err(); err != nil {
+			return err
+		}
+
+		scores, err := tx.Query(`SELECT game_id, record FROM scores`+whereChild+` ORDER BY game_id, id`, args...)
+		if err != nil {
+			return err
+		}
+		defer scores.Close()
+		for scores.Next() {
+			var id string
+			var b []byte
+			if err := scores.Scan(&id, &b); err != nil {
+				return err
+			}
+			i, ok := byID[id]
+			if !ok {
+				continue
+			}
+			var e game.ScoreEntry
+			if err := json.Unmarshal(b, &e); err != nil {
+				return fmt.Errorf("score in game %s: %v", id, err)
+			}
+			recs[i].Scores = append(recs[i].Scores, e)
+		}
+		return scores.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range recs {
+		if recs[i].Players == nil {
+			recs[i].Players = []game.Player{}
+		}
+	}
+	return recs, nil
+}
+
+func (s *postgresStore) ReserveID(space, id string) (bool, error) {
+	res, err := s.db.Exec(`INSERT INTO ids (key) VALUES ($1) ON CONFLICT DO NOTHING`, idKey(space, id))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *postgresStore) ReleaseIDs(space string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.inTx(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.Exec(`DELETE FROM ids WHERE key = $1`, idKey(space, id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *postgresStore) AddUsage(tenantID, period string, u Usage) error {
+	_, err := s.db.Exec(`INSERT INTO usage (tenant_id, period, games_created, connection_seconds, events_delivered) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id, period) DO UPDATE SET
+			games_created = usage.games_created + excluded.games_created,
+			connection_seconds = usage.connection_seconds + excluded.connection_seconds,
+			events_delivered = usage.events_delivered + excluded.events_delivered`,
+		tenantID, period, u.GamesCreated, u.ConnectionSeconds, u.EventsDelivered)
+	return err
+}
+
+func (s *postgresStore) LoadUsage(tenantID, period string) (Usage, error) {
+	var u Usage
+	err := s.db.QueryRow(`SELECT games_created, connection_seconds, events_delivered FROM usage WHERE tenant_id = $1 AND period = $2`,
+		tenantID, period).Scan(&u.GamesCreated, &u.ConnectionSeconds, &u.EventsDelivered)
+	if err == sql.ErrNoRows {
+		return Usage{}, nil
+	}
+	return u, err
+}
+
+func (s *postgresStore) AddLeagueStats(tenantID string, add LeagueStats) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for name, t := range add.Teams {
+			if _, err := tx.Exec(`INSERT INTO league_teams (tenant_id, team, games, wins) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (tenant_id, team) DO UPDATE SET games = league_teams.games + excluded.games, wins = league_teams.wins + excluded.wins`,
+				tenantID, name, t.Games, t.Wins); err != nil {
+				return err
+			}
+		}
+		for kind, r := range add.Reactions {
+			if _, err := tx.Exec(`INSERT INTO league_reactions (tenant_id, round_type, buzzes, total_ms) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (tenant_id, round_type) DO UPDATE SET buzzes = league_reactions.buzzes + excluded.buzzes, total_ms = league_reactions.total_ms + excluded.total_ms`,
+				tenantID, kind, r.Buzzes, r.TotalMs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *postgresStore) LoadLeagueStats(tenantID string) (LeagueStats, error) {
+	stats := NewLeagueStats()
+
+	teams, err := s.db.Query(`SELECT team, games, wins FROM league_teams WHERE tenant_id = $1`, tenantID)
+	if err != nil {
+		return LeagueStats{}, err
+	}
+	defer teams.Close()
+	for teams.Next() {
+		var name string
+		var t TeamTally
+		if err := teams.Scan(&name, &t.Games, &t.Wins); err != nil {
+			return LeagueStats{}, err
+		}
+		stats.Teams[name] = t
+	}
+	if err := teams.Err(); err != nil {
+		return LeagueStats{}, err
+	}
+
+	reactions, err := s.db.Query(`SELECT round_type, buzzes, total_ms FROM league_reactions WHERE tenant_id = $1`, tenantID)
+	if err != nil {
+		return LeagueStats{}, err
+	}
+	defer reactions.Close()
+	for reactions.Next() {
+		var kind string
+		var r reactionTally
+		if err := reactions.Scan(&kind, &r.Buzzes, &r.TotalMs); err != nil {
+			return LeagueStats{}, err
+		}
+		stats.Reactions[kind] = r
+	}
+	return stats, reactions.Err()
+}
+
+// appendLog adds entries to one of a game's logs and pushes back when its
+// logs expire. hostToken is kept if it's given. logs that have expired are
+// forgotten along the way.
+func (s *postgresStore) appendLog(gameID, hostToken, log string, entries []interface{}) error {
+	now := time.Now()
+	return s.inTx(func(tx *sql.Tx) error {
+		expired := `SELECT game_id FROM logs WHERE expires_at < $1`
+		if _, err := tx.Exec(`DELETE FROM events WHERE game_id IN (`+expired+`)`, now); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM logs WHERE expires_at < $1`, now); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`INSERT INTO logs (game_id, host_token, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (game_id) DO UPDATE SET
+				host_token = CASE WHEN excluded.host_token = '' THEN logs.host_token ELSE excluded.host_token END,
+				expires_at = excluded.expires_at`,
+			gameID, hostToken, now.Add(s.retention)); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO events (game_id, log, entry) VALUES ($1, $2, $3)`, gameID, log, string(b)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadLog calls f with each entry of one of a game's logs, oldest first.
+// it returns the host token of the game's history, and false if its logs
+// have expired or it never had any.
+func (s *postgresStore) loadLog(gameID, log string, f func(b []byte) error) (string, bool, error) {
+	var hostToken string
+	err := s.db.QueryRow(`SELECT host_token FROM logs WHERE game_id = $1 AND expires_at >= $2`, gameID, time.Now()).Scan(&hostToken)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	rows, err := s.db.Query(`SELECT entry FROM events WHERE game_id = $1 AND log = $2 ORDER BY seq`, gameID, log)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return "", false, err
+		}
+		if err := f(b); err != nil {
+			return "", false, err
+		}
+	}
+	return hostToken, true, rows.Err()
+}
+
+func (s *postgresStore) AppendHistory(gameID, hostToken string, entries []HistoryEntry) error {
+	list := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	return s.appendLog(gameID, hostToken, sqliteHistoryLog, list)
+}
+
+func (s *postgresStore) LoadHistory(gameID string) (GameHistory, bool, error) {
+	var h GameHistory
+	hostToken, ok, err := s.loadLog(gameID, sqliteHistoryLog, func(b []byte) error {
+		var e HistoryEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("history entry for game %s: %v", gameID, err)
+		}
+		h.Entries = append(h.Entries, e)
+		return nil
+	})
+	if err != nil || !ok || len(h.Entries) == 0 {
+		return GameHistory{}, false, err
+	}
+	h.HostToken = hostToken
+	return h, true, nil
+}
+
+func (s *postgresStore) AppendBuzzLog(gameID string, entries []game.BuzzLogEntry) error {
+	list := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	return s.appendLog(gameID, "", sqliteBuzzLog, list)
+}
+
+func (s *postgresStore) LoadBuzzLog(gameID string) ([]game.BuzzLogEntry, error) {
+	entries := []game.BuzzLogEntry{}
+	_, _, err := s.loadLog(gameID, sqliteBuzzLog, func(b []byte) error {
+		var e game.BuzzLogEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("buzz log entry for game %s: %v", gameID, err)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *postgresStore) AppendReplay(gameID string, entries []ReplayEntry) error {
+	list := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	return s.appendLog(gameID, "", sqliteReplayLog, list)
+}
+
+func (s *postgresStore) LoadReplay(gameID string) ([]ReplayEntry, error) {
+	entries := []ReplayEntry{}
+	_, _, err := s.loadLog(gameID, sqliteReplayLog, func(b []byte) error {
+		var e ReplayEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("replay entry for game %s: %v", gameID, err)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *postgresStore) SaveTemplate(owner string, t GameTemplate) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO templates (owner, name, record) VALUES ($1, $2, $3)
+		ON CONFLICT (owner, name) DO UPDATE SET record = excluded.record`, owner, t.Name, string(b))
+	return err
+}
+
+func (s *postgresStore) LoadTemplate(owner, name string) (GameTemplate, bool, error) {
+	var b []byte
+	err := s.db.QueryRow(`SELECT record FROM templates WHERE owner = $1 AND name = $2`, owner, name).Scan(&b)
+	if err == sql.ErrNoRows {
+		return GameTemplate{}, false, nil
+	}
+	if err != nil {
+		return GameTemplate{}, false, err
+	}
+
+	var t GameTemplate
+	if err := json.Unmarshal(b, &t); err != nil {
+		return GameTemplate{}, false, fmt.Errorf("template %s: %v", name, err)
+	}
+	return t, true, nil
+}
+
+func (s *postgresStore) ListTemplates(owner string) ([]GameTemplate, error) {
+	rows, err := s.db.Query(`SELECT record FROM templates WHERE owner = $1 ORDER BY name`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []GameTemplate{}
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		var t GameTemplate
+		if err := json.Unmarshal(b, &t); err != nil {
+			return nil, fmt.Errorf("template for %s: %v", owner, err)
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func (s *postgresStore) DeleteTemplate(owner, name string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM templates WHERE owner = $1 AND name = $2`, owner, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *postgresStore) SaveAccount(a HostAccount) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(func(tx *sql.Tx) error {
+		// drop the index entries of keys that have been revoked
+		if _, err := tx.Exec(`DELETE FROM account_keys WHERE account_id = $1`, a.ID); err != nil {
+			return err
+		}
+		for _, k := range a.Keys {
+			if _, err := tx.Exec(`INSERT INTO account_keys (hash, account_id) VALUES ($1, $2)
+				ON CONFLICT (hash) DO UPDATE SET account_id = excluded.account_id`, k.Hash, a.ID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO accounts (id, record) VALUES ($1, $2)
+			ON CONFLICT (id) DO UPDATE SET record = excluded.record`, a.ID, string(b))
+		return err
+	})
+}
+
+func (s *postgresStore) LoadAccount(id string) (HostAccount, bool, error) {
+	var b []byte
+	err := s.db.QueryRow(`SELECT record FROM accounts WHERE id = $1`, id).Scan(&b)
+	if err == sql.ErrNoRows {
+		return HostAccount{}, false, nil
+	}
+	if err != nil {
+		return HostAccount{}, false, err
+	}
+
+	var a HostAccount
+	if err := json.Unmarshal(b, &a); err != nil {
+		return HostAccount{}, false, fmt.Errorf("account %s: %v", id, err)
+	}
+	return a, true, nil
+}
+
+func (s *postgresStore) AccountForKey(hash string) (HostAccount, bool, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT account_id FROM account_keys WHERE hash = $1`, hash).Scan(&id)
+	if err == sql.ErrNoRows {
+		return HostAccount{}, false, nil
+	}
+	if err != nil {
+		return HostAccount{}, false, err
+	}
+	return s.LoadAccount(id)
+}
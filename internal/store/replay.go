@@ -0,0 +1,11 @@
+package store
+
+// ReplayEntry is one event of a game as it was sent, kept so the game can be
+// replayed afterwards. unlike the history every event is kept, along with
+// who it went to.
+type ReplayEntry struct {
+	HistoryEntry
+	// To is "all", "players" or "host"
+	To      string `json:"to"`
+	Private bool   `json:"private,omitempty"`
+}
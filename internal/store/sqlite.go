@@ -0,0 +1,703 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"bzzz/internal/game"
+)
+
+// sqliteSchema sets up a fresh database and is safe to run on one that's
+// already set up.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS games (
+	id TEXT PRIMARY KEY,
+	record TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS players (
+	game_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	player_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	team TEXT NOT NULL,
+	waiting INTEGER NOT NULL,
+	record TEXT NOT NULL,
+	PRIMARY KEY (game_id, waiting, position)
+);
+CREATE TABLE IF NOT EXISTS scores (
+	game_id TEXT NOT NULL,
+	id INTEGER NOT NULL,
+	player_id TEXT NOT NULL,
+	points INTEGER NOT NULL,
+	record TEXT NOT NULL,
+	PRIMARY KEY (game_id, id)
+);
+CREATE TABLE IF NOT EXISTS ids (
+	key TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS usage (
+	tenant_id TEXT NOT NULL,
+	period TEXT NOT NULL,
+	games_created INTEGER NOT NULL DEFAULT 0,
+	connection_seconds INTEGER NOT NULL DEFAULT 0,
+	events_delivered INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (tenant_id, period)
+);
+CREATE TABLE IF NOT EXISTS league_teams (
+	tenant_id TEXT NOT NULL,
+	team TEXT NOT NULL,
+	games INTEGER NOT NULL DEFAULT 0,
+	wins INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (tenant_id, team)
+);
+CREATE TABLE IF NOT EXISTS league_reactions (
+	tenant_id TEXT NOT NULL,
+	round_type TEXT NOT NULL,
+	buzzes INTEGER NOT NULL DEFAULT 0,
+	total_ms REAL NOT NULL DEFAULT 0,
+	PRIMARY KEY (tenant_id, round_type)
+);
+CREATE TABLE IF NOT EXISTS logs (
+	game_id TEXT PRIMARY KEY,
+	host_token TEXT NOT NULL DEFAULT '',
+	expires_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS logs_expires_at ON logs (expires_at);
+CREATE TABLE IF NOT EXISTS events (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	game_id TEXT NOT NULL,
+	log TEXT NOT NULL,
+	entry TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_game ON events (game_id, log, seq);
+CREATE TABLE IF NOT EXISTS templates (
+	owner TEXT NOT NULL,
+	name TEXT NOT NULL,
+	record TEXT NOT NULL,
+	PRIMARY KEY (owner, name)
+);
+CREATE TABLE IF NOT EXISTS accounts (
+	id TEXT PRIMARY KEY,
+	record TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS account_keys (
+	hash TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL
+);
+`
+
+// the logs kept in the events table
+const (
+	sqliteHistoryLog = "history"
+	sqliteBuzzLog    = "buzzlog"
+	sqliteReplayLog  = "replay"
+)
+
+// sqliteStore keeps everything in a single SQLite file, for running one
+// replica on a laptop or a Raspberry Pi that still picks its games back up
+// after a crash, without redis. a game's record is JSON in games, except
+// for its players and its score ledger, which have tables of their own
+// with a column for each thing worth querying. tenant usage and league
+// stats are counters in usage, league_teams and league_reactions. a game's
+// history, buzz log and replay are rows of JSON in events, oldest first,
+// with the history's host token and when they all expire in logs. the
+// rest are JSON keyed like the redis store's.
+//
+// there's only one connection, so writes never find the file locked, and
+// the database is in WAL mode so a crash loses at most the write in flight.
+type sqliteStore struct {
+	db *sql.DB
+	// retention is how long a game's history is kept after its last entry
+	retention time.Duration
+}
+
+func newSQLiteStore(path string, retention time.Duration) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+		"PRAGMA busy_timeout = 5000",
+	} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &sqliteStore{db: db, retention: retention}, nil
+}
+
+// Close closes the database file.
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStore) Ping() error {
+	return s.db.Ping()
+}
+
+// inTx runs f in a transaction, committing it if f succeeds.
+func (s *sqliteStore) inTx(f func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) SaveGame(rec game.GameRecord, since *game.GameRecord) error {
+	players, waiting, scores := rec.Players, rec.Waiting, rec.Scores
+	rec.Players, rec.Waiting, rec.Scores = nil, nil, nil
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var was game.GameRecord
+	if since != nil {
+		was = *since
+	}
+
+	// players and scores have rows of their own, only the ones that
+	// changed since the last save are written
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`INSERT INTO games (id, record, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET record = excluded.record, updated_at = excluded.updated_at`,
+			rec.ID, string(b), time.Now().Unix()); err != nil {
+			return err
+		}
+		for i, lists := range [][2][]game.Player{{players, was.Players}, {waiting, was.Waiting}} {
+			changed, whole := changedPlayers(lists[0], lists[1], since != nil)
+			if whole {
+				if _, err := tx.Exec(`DELETE FROM players WHERE game_id = ? AND waiting = ?`, rec.ID, i); err != nil {
+					return err
+				}
+				changed = changed[:0]
+				for pos := range lists[0] {
+					changed = append(changed, pos)
+				}
+			}
+			for _, pos := range changed {
+				p := lists[0][pos]
+				b, err := json.Marshal(p)
+				if err != nil {
+					return err
+				}
+				if _, err := tx.Exec(`INSERT INTO players (game_id, position, player_id, name, team, waiting, record) VALUES (?, ?, ?, ?, ?, ?, ?)
+					ON CONFLICT (game_id, waiting, position) DO UPDATE SET player_id = excluded.player_id, name = excluded.name, team = excluded.team, record = excluded.record`,
+					rec.ID, pos, p.PlayerID, p.Name, p.Team, i, string(b)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if since == nil {
+			if _, err := tx.Exec(`DELETE FROM scores WHERE game_id = ?`, rec.ID); err != nil {
+				return err
+			}
+		}
+		changed, gone := changedScores(scores, was.Scores)
+		for _, id := range gone {
+			if _, err := tx.Exec(`DELETE FROM scores WHERE game_id = ? AND id = ?`, rec.ID, id); err != nil {
+				return err
+			}
+		}
+		for _, e := range changed {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO scores (game_id, id, player_id, points, record) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT (game_id, id) DO UPDATE SET player_id = excluded.player_id, points = excluded.points, record = excluded.record`,
+				rec.ID, e.ID, e.PlayerID, e.Points, string(b)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) DeleteGame(gameID string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for _, q := range []string{
+			`DELETE FROM games WHERE id = ?`,
+			`DELETE FROM players WHERE game_id = ?`,
+			`DELETE FROM scores WHERE game_id = ?`,
+		} {
+			if _, err := tx.Exec(q, gameID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) LoadGames() ([]game.GameRecord, error) {
+	return s.loadGames("")
+}
+
+func (s *sqliteStore) LoadGame(gameID string) (game.GameRecord, bool, error) {
+	recs, err := s.loadGames(gameID)
+	if err != nil || len(recs) == 0 {
+		return game.GameRecord{}, false, err
+	}
+	return recs[0], true, nil
+}
+
+// loadGames reads games back with their players and scores, every one of
+// them if gameID is empty.
+func (s *sqliteStore) loadGames(gameID string) ([]game.GameRecord, error) {
+	var whereGame, whereChild string
+	var args []interface{}
+	if gameID != "" {
+		whereGame, whereChild = ` WHERE id = ?`, ` WHERE game_id = ?`
+		args = append(args, gameID)
+	}
+
+	var recs []game.GameRecord
+	err := s.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT id, record FROM games`+whereGame, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		byID := map[string]int{}
+		for rows.Next() {
+			var id, b string
+			if err := rows.Scan(&id, &b); err != nil {
+				return err
+			}
+			var rec game.GameRecord
+			if err := json.Unmarshal([]byte(b), &rec); err != nil {
+				return fmt.Errorf("game %s: %v", id, err)
+			}
+			byID[id] = len(recs)
+			recs = append(recs, rec)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		players, err := tx.Query(`SELECT game_id, waiting, record FROM players`+whereChild+` ORDER BY game_id, waiting, position`, args...)
+		if err != nil {
+			return err
+		}
+		defer players.Close()
+		for players.Next() {
+			var id, b string
+			var waiting bool
+			if err := players.Scan(&id, &waiting, &b); err != nil {
+				return err
+			}
+			i, ok := byID[id]
+			if !ok {
+				continue
+			}
+			var p game.Player
+			if err := json.Unmarshal([]byte(b), &p); err != nil {
+				return fmt.Errorf("player in game %s: %v", id, err)
+			}
+			if waiting {
+				recs[i].Waiting = append(recs[i].Waiting, p)
+			} else {
+				recs[i].Players = append(recs[i].Players, p)
+			}
+		}
+		if err := players.Err(); err != nil {
+			return err
+		}
+
+		scores, err := tx.Query(`SELECT game_id, record FROM scores`+whereChild+` ORDER BY game_id, id`, args...)
+		if err != nil {
+			return err
+		}
+		defer scores.Close()
+		for scores.Next() {
+			var id, b string
+			if err := scores.Scan(&id, &b); err != nil {
+				return err
+			}
+			i, ok := byID[id]
+			if !ok {
+				continue
+			}
+			var e game.ScoreEntry
+			if err := json.Unmarshal([]byte(b), &e); err != nil {
+				return fmt.Errorf("score in game %s: %v", id, err)
+			}
+			recs[i].Scores = append(recs[i].Scores, e)
+		}
+		return scores.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range recs {
+		if recs[i].Players == nil {
+			recs[i].Players = []game.Player{}
+		}
+	}
+	return recs, nil
+}
+
+func (s *sqliteStore) ReserveID(space, id string) (bool, error) {
+	res, err := s.db.Exec(`INSERT INTO ids (key) VALUES (?) ON CONFLICT DO NOTHING`, idKey(space, id))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqliteStore) ReleaseIDs(space string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.inTx(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.Exec(`DELETE FROM ids WHERE key = ?`, idKey(space, id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) AddUsage(tenantID, period string, u Usage) error {
+	_, err := s.db.Exec(`INSERT INTO usage (tenant_id, period, games_created, connection_seconds, events_delivered) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (tenant_id, period) DO UPDATE SET
+			games_created = games_created + excluded.games_created,
+			connection_seconds = connection_seconds + excluded.connection_seconds,
+			events_delivered = events_delivered + excluded.events_delivered`,
+		tenantID, period, u.GamesCreated, u.ConnectionSeconds, u.EventsDelivered)
+	return err
+}
+
+func (s *sqliteStore) LoadUsage(tenantID, period string) (Usage, error) {
+	var u Usage
+	err := s.db.QueryRow(`SELECT games_created, connection_seconds, events_delivered FROM usage WHERE tenant_id = ? AND period = ?`,
+		tenantID, period).Scan(&u.GamesCreated, &u.ConnectionSeconds, &u.EventsDelivered)
+	if err == sql.ErrNoRows {
+		return Usage{}, nil
+	}
+	return u, err
+}
+
+func (s *sqliteStore) AddLeagueStats(tenantID string, add LeagueStats) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for name, t := range add.Teams {
+			if _, err := tx.Exec(`INSERT INTO league_teams (tenant_id, team, games, wins) VALUES (?, ?, ?, ?)
+				ON CONFLICT (tenant_id, team) DO UPDATE SET games = games + excluded.games, wins = wins + excluded.wins`,
+				tenantID, name, t.Games, t.Wins); err != nil {
+				return err
+			}
+		}
+		for kind, r := range add.Reactions {
+			if _, err := tx.Exec(`INSERT INTO league_reactions (tenant_id, round_type, buzzes, total_ms) VALUES (?, ?, ?, ?)
+				ON CONFLICT (tenant_id, round_type) DO UPDATE SET buzzes = buzzes + excluded.buzzes, total_ms = total_ms + excluded.total_ms`,
+				tenantID, kind, r.Buzzes, r.TotalMs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) LoadLeagueStats(tenantID string) (LeagueStats, error) {
+	stats := NewLeagueStats()
+
+	teams, err := s.db.Query(`SELECT team, games, wins FROM league_teams WHERE tenant_id = ?`, tenantID)
+	if err != nil {
+		return LeagueStats{}, err
+	}
+	defer teams.Close()
+	for teams.Next() {
+		var name string
+		var t TeamTally
+		if err := teams.Scan(&name, &t.Games, &t.Wins); err != nil {
+			return LeagueStats{}, err
+		}
+		stats.Teams[name] = t
+	}
+	if err := teams.Err(); err != nil {
+		return LeagueStats{}, err
+	}
+
+	reactions, err := s.db.Query(`SELECT round_type, buzzes, total_ms FROM league_reactions WHERE tenant_id = ?`, tenantID)
+	if err != nil {
+		return LeagueStats{}, err
+	}
+	defer reactions.Close()
+	for reactions.Next() {
+		var kind string
+		var r reactionTally
+		if err := reactions.Scan(&kind, &r.Buzzes, &r.TotalMs); err != nil {
+			return LeagueStats{}, err
+		}
+		stats.Reactions[kind] = r
+	}
+	return stats, reactions.Err()
+}
+
+// appendLog adds entries to one of a game's logs and pushes back when its
+// logs expire. hostToken is kept if it's given. logs that have expired are
+// forgotten along the way.
+func (s *sqliteStore) appendLog(gameID, hostToken, log string, entries []interface{}) error {
+	now := time.Now()
+	return s.inTx(func(tx *sql.Tx) error {
+		expired := `SELECT game_id FROM logs WHERE expires_at < ?`
+		if _, err := tx.Exec(`DELETE FROM events WHERE game_id IN (`+expired+`)`, now.Unix()); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM logs WHERE expires_at < ?`, now.Unix()); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`INSERT INTO logs (game_id, host_token, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (game_id) DO UPDATE SET
+				host_token = CASE WHEN excluded.host_token = '' THEN host_token ELSE excluded.host_token END,
+				expires_at = excluded.expires_at`,
+			gameID, hostToken, now.Add(s.retention).Unix()); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO events (game_id, log, entry) VALUES (?, ?, ?)`, gameID, log, string(b)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadLog calls f with each entry of one of a game's logs, oldest first.
+// it returns the host token of the game's history, and false if its logs
+// have expired or it never had any.
+func (s *sqliteStore) loadLog(gameID, log string, f func(b []byte) error) (string, bool, error) {
+	var hostToken string
+	err := s.db.QueryRow(`SELECT host_token FROM logs WHERE game_id = ? AND expires_at >= ?`, gameID, time.Now().Unix()).Scan(&hostToken)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	rows, err := s.db.Query(`SELECT entry FROM events WHERE game_id = ? AND log = ? ORDER BY seq`, gameID, log)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err != nil {
+			return "", false, err
+		}
+		if err := f([]byte(b)); err != nil {
+			return "", false, err
+		}
+	}
+	return hostToken, true, rows.Err()
+}
+
+func (s *sqliteStore) AppendHistory(gameID, hostToken string, entries []HistoryEntry) error {
+	list := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	return s.appendLog(gameID, hostToken, sqliteHistoryLog, list)
+}
+
+func (s *sqliteStore) LoadHistory(gameID string) (GameHistory, bool, error) {
+	var h GameHistory
+	hostToken, ok, err := s.loadLog(gameID, sqliteHistoryLog, func(b []byte) error {
+		var e HistoryEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("history entry for game %s: %v", gameID, err)
+		}
+		h.Entries = append(h.Entries, e)
+		return nil
+	})
+	if err != nil || !ok || len(h.Entries) == 0 {
+		return GameHistory{}, false, err
+	}
+	h.HostToken = hostToken
+	return h, true, nil
+}
+
+func (s *sqliteStore) AppendBuzzLog(gameID string, entries []game.BuzzLogEntry) error {
+	list := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	return s.appendLog(gameID, "", sqliteBuzzLog, list)
+}
+
+func (s *sqliteStore) LoadBuzzLog(gameID string) ([]game.BuzzLogEntry, error) {
+	entries := []game.BuzzLogEntry{}
+	_, _, err := s.loadLog(gameID, sqliteBuzzLog, func(b []byte) error {
+		var e game.BuzzLogEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("buzz log entry for game %s: %v", gameID, err)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *sqliteStore) AppendReplay(gameID string, entries []ReplayEntry) error {
+	list := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	return s.appendLog(gameID, "", sqliteReplayLog, list)
+}
+
+func (s *sqliteStore) LoadReplay(gameID string) ([]ReplayEntry, error) {
+	entries := []ReplayEntry{}
+	_, _, err := s.loadLog(gameID, sqliteReplayLog, func(b []byte) error {
+		var e ReplayEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("replay entry for game %s: %v", gameID, err)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *sqliteStore) SaveTemplate(owner string, t GameTemplate) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO templates (owner, name, record) VALUES (?, ?, ?)
+		ON CONFLICT (owner, name) DO UPDATE SET record = excluded.record`, owner, t.Name, string(b))
+	return err
+}
+
+func (s *sqliteStore) LoadTemplate(owner, name string) (GameTemplate, bool, error) {
+	var b string
+	err := s.db.QueryRow(`SELECT record FROM templates WHERE owner = ? AND name = ?`, owner, name).Scan(&b)
+	if err == sql.ErrNoRows {
+		return GameTemplate{}, false, nil
+	}
+	if err != nil {
+		return GameTemplate{}, false, err
+	}
+
+	var t GameTemplate
+	if err := json.Unmarshal([]byte(b), &t); err != nil {
+		return GameTemplate{}, false, fmt.Errorf("template %s: %v", name, err)
+	}
+	return t, true, nil
+}
+
+func (s *sqliteStore) ListTemplates(owner string) ([]GameTemplate, error) {
+	rows, err := s.db.Query(`SELECT record FROM templates WHERE owner = ? ORDER BY name`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []GameTemplate{}
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		var t GameTemplate
+		if err := json.Unmarshal([]byte(b), &t); err != nil {
+			return nil, fmt.Errorf("template for %s: %v", owner, err)
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) DeleteTemplate(owner, name string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM templates WHERE owner = ? AND name = ?`, owner, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqliteStore) SaveAccount(a HostAccount) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(func(tx *sql.Tx) error {
+		// drop the index entries of keys that have been revoked
+		if _, err := tx.Exec(`DELETE FROM account_keys WHERE account_id = ?`, a.ID); err != nil {
+			return err
+		}
+		for _, k := range a.Keys {
+			if _, err := tx.Exec(`INSERT INTO account_keys (hash, account_id) VALUES (?, ?)
+				ON CONFLICT (hash) DO UPDATE SET account_id = excluded.account_id`, k.Hash, a.ID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO accounts (id, record) VALUES (?, ?)
+			ON CONFLICT (id) DO UPDATE SET record = excluded.record`, a.ID, string(b))
+		return err
+	})
+}
+
+func (s *sqliteStore) LoadAccount(id string) (HostAccount, bool, error) {
+	var b string
+	err := s.db.QueryRow(`SELECT record FROM accounts WHERE id = ?`, id).Scan(&b)
+	if err == sql.ErrNoRows {
+		return HostAccount{}, false, nil
+	}
+	if err != nil {
+		return HostAccount{}, false, err
+	}
+
+	var a HostAccount
+	if err := json.Unmarshal([]byte(b), &a); err != nil {
+		return HostAccount{}, false, fmt.Errorf("account %s: %v", id, err)
+	}
+	return a, true, nil
+}
+
+func (s *sqliteStore) AccountForKey(hash string) (HostAccount, bool, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT account_id FROM account_keys WHERE hash = ?`, hash).Scan(&id)
+	if err == sql.ErrNoRows {
+		return HostAccount{}, false, nil
+	}
+	if err != nil {
+		return HostAccount{}, false, err
+	}
+	return s.LoadAccount(id)
+}
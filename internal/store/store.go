@@ -0,0 +1,970 @@
+// Package store keeps games and everything kept about them, history,
+// templates, accounts and usage, in memory, redis, sqlite or postgres.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"bzzz/config"
+	"bzzz/internal/game"
+)
+
+// changedPlayers returns the positions in list, a game's players or its
+// waiting room, whose player isn't as they were in since. whole is set when
+// the list has to be written from scratch instead, because someone left it
+// or it was never written.
+func changedPlayers(list, since []game.Player, written bool) (changed []int, whole bool) {
+	if !written || len(list) < len(since) {
+		return nil, true
+	}
+	for i := range since {
+		if list[i].PlayerID != since[i].PlayerID {
+			return nil, true
+		}
+		if list[i] != since[i] {
+			changed = append(changed, i)
+		}
+	}
+	for i := len(since); i < len(list); i++ {
+		changed = append(changed, i)
+	}
+	return changed, false
+}
+
+// changedScores returns the entries of a game's score ledger that aren't
+// as they were in since, and the IDs of since's entries that are gone, say
+// undone.
+func changedScores(scores, since []game.ScoreEntry) (changed []game.ScoreEntry, gone []int) {
+	old := make(map[int]game.ScoreEntry, len(since))
+	for _, e := range since {
+		old[e.ID] = e
+	}
+	for _, e := range scores {
+		if o, ok := old[e.ID]; !ok || o != e {
+			changed = append(changed, e)
+		}
+		delete(old, e.ID)
+	}
+	for id := range old {
+		gone = append(gone, id)
+	}
+	return changed, gone
+}
+
+// Store persists game state so in-progress games survive a restart and can
+// be picked up by another instance.
+type Store interface {
+	game.Store
+
+	// AddUsage adds to a tenant's metered usage for a billing period.
+	AddUsage(tenantID, period string, u Usage) error
+	// LoadUsage returns a tenant's metered usage for a billing period.
+	LoadUsage(tenantID, period string) (Usage, error)
+
+	// AddLeagueStats adds a finished game to a tenant's league stats.
+	AddLeagueStats(tenantID string, s LeagueStats) error
+	// LoadLeagueStats returns a tenant's league stats.
+	LoadLeagueStats(tenantID string) (LeagueStats, error)
+
+	// AppendHistory adds entries to a game's history, kept with the host
+	// token that can read it for the configured retention after the last
+	// one.
+	AppendHistory(gameID, hostToken string, entries []HistoryEntry) error
+	// LoadHistory returns a game's history, false if there's none.
+	LoadHistory(gameID string) (GameHistory, bool, error)
+	// LoadBuzzLog returns a game's buzz log, oldest first.
+	LoadBuzzLog(gameID string) ([]game.BuzzLogEntry, error)
+	// AppendReplay adds every event sent in a game to its replay, kept as
+	// long as its history.
+	AppendReplay(gameID string, entries []ReplayEntry) error
+	// LoadReplay returns a game's replay, oldest first.
+	LoadReplay(gameID string) ([]ReplayEntry, error)
+
+	// SaveTemplate writes a game template for its owner, replacing any
+	// of the same name.
+	SaveTemplate(owner string, t GameTemplate) error
+	// LoadTemplate returns one of an owner's templates, false if there's
+	// none by that name.
+	LoadTemplate(owner, name string) (GameTemplate, bool, error)
+	// ListTemplates returns every template an owner has saved.
+	ListTemplates(owner string) ([]GameTemplate, error)
+	// DeleteTemplate forgets a template, false if there was none.
+	DeleteTemplate(owner, name string) (bool, error)
+
+	// SaveAccount writes a host account, replacing what was there, and
+	// indexes it by the hashes of its API keys. keys no longer on the
+	// account stop finding it.
+	SaveAccount(a HostAccount) error
+	// LoadAccount returns a host account, false if there's none.
+	LoadAccount(id string) (HostAccount, bool, error)
+	// AccountForKey returns the host account an API key hash belongs to,
+	// false if none does.
+	AccountForKey(hash string) (HostAccount, bool, error)
+
+	// Ping checks the store can be reached.
+	Ping() error
+}
+
+// New picks the storage backend, "memory" (the default), "redis",
+// "sqlite" or "postgres". the redis backend connects to the configured redis
+// URL, the sqlite one opens the configured database file, creating it if
+// need be, and the postgres one connects to the configured DSN and
+// migrates its schema.
+func New(c *config.Config) (Store, error) {
+	switch backend := c.Store; backend {
+	case "", "memory":
+		return newMemoryStore(c.HistoryRetention), nil
+	case "redis":
+		return newRedisStore(c.RedisURL, c.HistoryRetention), nil
+	case "sqlite":
+		return newSQLiteStore(c.SQLitePath, c.HistoryRetention)
+	case "postgres":
+		return newPostgresStore(c.PostgresDSN, c.PostgresMaxConns, c.HistoryRetention)
+	default:
+		return nil, fmt.Errorf("unknown store %q", backend)
+	}
+}
+
+// memoryStore keeps records in process. it's the default and survives
+// nothing, but keeps the game.Manager code the same for every backend.
+type memoryStore struct {
+	mu      sync.Mutex
+	games   map[string]game.GameRecord
+	ids     map[string]bool
+	usage   map[string]Usage
+	league  map[string]LeagueStats
+	history map[string]*storedHistory
+	// templates maps owner -> name -> template
+	templates map[string]map[string]GameTemplate
+	accounts  map[string]HostAccount
+	// accountKeys maps API key hash -> account id
+	accountKeys map[string]string
+	// retention is how long a game's history is kept after its last entry
+	retention time.Duration
+}
+
+// storedHistory is a game's history in the memory store, dropped once it
+// expires.
+type storedHistory struct {
+	GameHistory
+	buzzLog []game.BuzzLogEntry
+	replay  []ReplayEntry
+	expires time.Time
+}
+
+func newMemoryStore(retention time.Duration) *memoryStore {
+	return &memoryStore{
+		games:   map[string]game.GameRecord{},
+		ids:     map[string]bool{},
+		usage:   map[string]Usage{},
+		league:  map[string]LeagueStats{},
+		history: map[string]*storedHistory{},
+
+		templates:   map[string]map[string]GameTemplate{},
+		accounts:    map[string]HostAccount{},
+		accountKeys: map[string]string{},
+
+		retention: retention,
+	}
+}
+
+func (s *memoryStore) Ping() error {
+	return nil
+}
+
+func (s *memoryStore) SaveGame(rec game.GameRecord, since *game.GameRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.games[rec.ID] = rec
+	return nil
+}
+
+func (s *memoryStore) DeleteGame(gameID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.games, gameID)
+	return nil
+}
+
+func (s *memoryStore) LoadGame(gameID string) (game.GameRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.games[gameID]
+	return rec, ok, nil
+}
+
+func (s *memoryStore) LoadGames() ([]game.GameRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recs := make([]game.GameRecord, 0, len(s.games))
+	for _, rec := range s.games {
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// idKey is what an ID is reserved under in its namespace. game IDs are
+// kept bare, the way every ID was reserved before players got a namespace
+// of their own, so games already running keep their codes.
+func idKey(space, id string) string {
+	if space == game.GameIDSpace {
+		return id
+	}
+	return space + ":" + id
+}
+
+func (s *memoryStore) ReserveID(space, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := idKey(space, id)
+	if s.ids[key] {
+		return false, nil
+	}
+	s.ids[key] = true
+	return true, nil
+}
+
+func (s *memoryStore) ReleaseIDs(space string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.ids, idKey(space, id))
+	}
+	return nil
+}
+
+func (s *memoryStore) AddUsage(tenantID, period string, u Usage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := tenantID + "|" + period
+	total := s.usage[key]
+	total.Add(u)
+	s.usage[key] = total
+	return nil
+}
+
+func (s *memoryStore) LoadUsage(tenantID, period string) (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.usage[tenantID+"|"+period], nil
+}
+
+func (s *memoryStore) AddLeagueStats(tenantID string, add LeagueStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total, ok := s.league[tenantID]
+	if !ok {
+		total = NewLeagueStats()
+	}
+	total.add(add)
+	s.league[tenantID] = total
+	return nil
+}
+
+func (s *memoryStore) LoadLeagueStats(tenantID string) (LeagueStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := NewLeagueStats()
+	if total, ok := s.league[tenantID]; ok {
+		stats.add(total)
+	}
+	return stats, nil
+}
+
+func (s *memoryStore) AppendHistory(gameID, hostToken string, entries []HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	h, ok := s.history[gameID]
+	if !ok {
+		// a new game is as good a time as any to forget expired ones
+		for id, old := range s.history {
+			if now.After(old.expires) {
+				delete(s.history, id)
+			}
+		}
+		h = &storedHistory{}
+		s.history[gameID] = h
+	}
+	if hostToken != "" {
+		h.HostToken = hostToken
+	}
+	h.Entries = append(h.Entries, entries...)
+	h.expires = now.Add(s.retention)
+	return nil
+}
+
+func (s *memoryStore) LoadHistory(gameID string) (GameHistory, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.history[gameID]
+	if !ok || time.Now().After(h.expires) {
+		return GameHistory{}, false, nil
+	}
+	return GameHistory{HostToken: h.HostToken, Entries: append([]HistoryEntry{}, h.Entries...)}, true, nil
+}
+
+func (s *memoryStore) AppendBuzzLog(gameID string, entries []game.BuzzLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.history[gameID]
+	if !ok {
+		h = &storedHistory{}
+		s.history[gameID] = h
+	}
+	h.buzzLog = append(h.buzzLog, entries...)
+	h.expires = time.Now().Add(s.retention)
+	return nil
+}
+
+func (s *memoryStore) LoadBuzzLog(gameID string) ([]game.BuzzLogEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.history[gameID]
+	if !ok || time.Now().After(h.expires) {
+		return []game.BuzzLogEntry{}, nil
+	}
+	return append([]game.BuzzLogEntry{}, h.buzzLog...), nil
+}
+
+func (s *memoryStore) AppendReplay(gameID string, entries []ReplayEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.history[gameID]
+	if !ok {
+		h = &storedHistory{}
+		s.history[gameID] = h
+	}
+	h.replay = append(h.replay, entries...)
+	h.expires = time.Now().Add(s.retention)
+	return nil
+}
+
+func (s *memoryStore) LoadReplay(gameID string) ([]ReplayEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.history[gameID]
+	if !ok || time.Now().After(h.expires) {
+		return []ReplayEntry{}, nil
+	}
+	return append([]ReplayEntry{}, h.replay...), nil
+}
+
+func (s *memoryStore) SaveTemplate(owner string, t GameTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.templates[owner]; !ok {
+		s.templates[owner] = map[string]GameTemplate{}
+	}
+	s.templates[owner][t.Name] = t
+	return nil
+}
+
+func (s *memoryStore) LoadTemplate(owner, name string) (GameTemplate, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.templates[owner][name]
+	return t, ok, nil
+}
+
+func (s *memoryStore) ListTemplates(owner string) ([]GameTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]GameTemplate, 0, len(s.templates[owner]))
+	for _, t := range s.templates[owner] {
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+func (s *memoryStore) DeleteTemplate(owner, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.templates[owner][name]
+	delete(s.templates[owner], name)
+	return ok, nil
+}
+
+func (s *memoryStore) SaveAccount(a HostAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.accounts[a.ID].Keys {
+		delete(s.accountKeys, k.Hash)
+	}
+	for _, k := range a.Keys {
+		s.accountKeys[k.Hash] = a.ID
+	}
+	s.accounts[a.ID] = a
+	return nil
+}
+
+func (s *memoryStore) LoadAccount(id string) (HostAccount, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.accounts[id]
+	return a, ok, nil
+}
+
+func (s *memoryStore) AccountForKey(hash string) (HostAccount, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.accountKeys[hash]
+	if !ok {
+		return HostAccount{}, false, nil
+	}
+	a, ok := s.accounts[id]
+	return a, ok, nil
+}
+
+// redis keys used by redisStore
+const (
+	redisGamesKey        = "bzzz:games"
+	redisGameKeyPrefix   = "bzzz:game:"
+	redisIDKeyPrefix     = "bzzz:id:"
+	redisUsageKeyPrefix  = "bzzz:usage:"
+	redisLeagueKeyPrefix = "bzzz:league:"
+	redisHistoryPrefix   = "bzzz:history:"
+	redisBuzzLogPrefix   = "bzzz:buzzlog:"
+	redisReplayPrefix    = "bzzz:replay:"
+	redisTemplatesPrefix = "bzzz:templates:"
+	redisAccountsKey     = "bzzz:accounts"
+	redisAccountKeysKey  = "bzzz:account_keys"
+)
+
+// fields of a tenant's league stats hash, each followed by a team name or
+// round type
+const (
+	leagueTeamGames      = "team:games:"
+	leagueTeamWins       = "team:wins:"
+	leagueReactionBuzzes = "reaction:buzzes:"
+	leagueReactionMs     = "reaction:ms:"
+)
+
+// redisStore keeps each game as a JSON blob under bzzz:game:<id>, with the
+// set of live game IDs in bzzz:games. every game and player ID in use is
+// reserved under bzzz:id:<id>. tenant usage is a hash per billing period
+// under bzzz:usage:<tenant>:<period>, and league stats are a hash per tenant
+// under bzzz:league:<tenant>. a game's history is a list of JSON entries
+// under bzzz:history:<id>, with its host token in bzzz:history:<id>:host,
+// and its buzz log and replay are lists under bzzz:buzzlog:<id> and
+// bzzz:replay:<id>. game templates are JSON in a hash per owner under
+// bzzz:templates:<owner>, keyed by name. host accounts are JSON in the bzzz:accounts hash keyed by
+// id, with bzzz:account_keys mapping the hash of each API key to its
+// account.
+type redisStore struct {
+	pool *redis.Pool
+	// retention is how long a game's history is kept after its last entry
+	retention time.Duration
+}
+
+func newRedisStore(url string, retention time.Duration) *redisStore {
+	return &redisStore{pool: &redis.Pool{
+		MaxIdle:     8,
+		IdleTimeout: 4 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url)
+		},
+	}, retention: retention}
+}
+
+func redisGameKey(gameID string) string {
+	return redisGameKeyPrefix + gameID
+}
+
+func (s *redisStore) Ping() error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PING")
+	return err
+}
+
+func (s *redisStore) SaveGame(rec game.GameRecord, since *game.GameRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("SET", redisGameKey(rec.ID), b)
+	conn.Send("SADD", redisGamesKey, rec.ID)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) DeleteGame(gameID string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("DEL", redisGameKey(gameID))
+	conn.Send("SREM", redisGamesKey, gameID)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) LoadGame(gameID string) (game.GameRecord, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("GET", redisGameKey(gameID)))
+	if err == redis.ErrNil {
+		return game.GameRecord{}, false, nil
+	}
+	if err != nil {
+		return game.GameRecord{}, false, err
+	}
+	var rec game.GameRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return game.GameRecord{}, false, fmt.Errorf("game %s: %v", gameID, err)
+	}
+	return rec, true, nil
+}
+
+func (s *redisStore) LoadGames() ([]game.GameRecord, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	ids, err := redis.Strings(conn.Do("SMEMBERS", redisGamesKey))
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]game.GameRecord, 0, len(ids))
+	for _, id := range ids {
+		b, err := redis.Bytes(conn.Do("GET", redisGameKey(id)))
+		if err == redis.ErrNil {
+			// the game was deleted between SMEMBERS and GET
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var rec game.GameRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return nil, fmt.Errorf("game %s: %v", id, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+func (s *redisStore) ReserveID(space, id string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", redisIDKeyPrefix+idKey(space, id), 1, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *redisStore) ReleaseIDs(space string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, redisIDKeyPrefix+idKey(space, id))
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", keys...)
+	return err
+}
+
+func redisUsageKey(tenantID, period string) string {
+	return redisUsageKeyPrefix + tenantID + ":" + period
+}
+
+func (s *redisStore) AddUsage(tenantID, period string, u Usage) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	key := redisUsageKey(tenantID, period)
+	conn.Send("MULTI")
+	conn.Send("HINCRBY", key, "gamesCreated", u.GamesCreated)
+	conn.Send("HINCRBY", key, "connectionSeconds", u.ConnectionSeconds)
+	conn.Send("HINCRBY", key, "eventsDelivered", u.EventsDelivered)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) LoadUsage(tenantID, period string) (Usage, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.Int64Map(conn.Do("HGETALL", redisUsageKey(tenantID, period)))
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{
+		GamesCreated:      fields["gamesCreated"],
+		ConnectionSeconds: fields["connectionSeconds"],
+		EventsDelivered:   fields["eventsDelivered"],
+	}, nil
+}
+
+func (s *redisStore) AddLeagueStats(tenantID string, add LeagueStats) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	key := redisLeagueKeyPrefix + tenantID
+	conn.Send("MULTI")
+	for name, t := range add.Teams {
+		conn.Send("HINCRBY", key, leagueTeamGames+name, t.Games)
+		conn.Send("HINCRBY", key, leagueTeamWins+name, t.Wins)
+	}
+	for kind, r := range add.Reactions {
+		conn.Send("HINCRBY", key, leagueReactionBuzzes+kind, r.Buzzes)
+		conn.Send("HINCRBYFLOAT", key, leagueReactionMs+kind, r.TotalMs)
+	}
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) LoadLeagueStats(tenantID string) (LeagueStats, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.StringMap(conn.Do("HGETALL", redisLeagueKeyPrefix+tenantID))
+	if err != nil {
+		return LeagueStats{}, err
+	}
+
+	stats := NewLeagueStats()
+	for field, value := range fields {
+		switch {
+		case strings.HasPrefix(field, leagueTeamGames):
+			name := strings.TrimPrefix(field, leagueTeamGames)
+			t := stats.Teams[name]
+			t.Games, _ = strconv.ParseInt(value, 10, 64)
+			stats.Teams[name] = t
+		case strings.HasPrefix(field, leagueTeamWins):
+			name := strings.TrimPrefix(field, leagueTeamWins)
+			t := stats.Teams[name]
+			t.Wins, _ = strconv.ParseInt(value, 10, 64)
+			stats.Teams[name] = t
+		case strings.HasPrefix(field, leagueReactionBuzzes):
+			kind := strings.TrimPrefix(field, leagueReactionBuzzes)
+			r := stats.Reactions[kind]
+			r.Buzzes, _ = strconv.ParseInt(value, 10, 64)
+			stats.Reactions[kind] = r
+		case strings.HasPrefix(field, leagueReactionMs):
+			kind := strings.TrimPrefix(field, leagueReactionMs)
+			r := stats.Reactions[kind]
+			r.TotalMs, _ = strconv.ParseFloat(value, 64)
+			stats.Reactions[kind] = r
+		}
+	}
+	return stats, nil
+}
+
+func (s *redisStore) AppendHistory(gameID, hostToken string, entries []HistoryEntry) error {
+	key := redisHistoryPrefix + gameID
+	ttl := int64(s.retention / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	args := redis.Args{}.Add(key)
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		args = args.Add(b)
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("RPUSH", args...)
+	conn.Send("EXPIRE", key, ttl)
+	if hostToken != "" {
+		conn.Send("SET", key+":host", hostToken, "EX", ttl)
+	} else {
+		conn.Send("EXPIRE", key+":host", ttl)
+	}
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) LoadHistory(gameID string) (GameHistory, bool, error) {
+	key := redisHistoryPrefix + gameID
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	blobs, err := redis.ByteSlices(conn.Do("LRANGE", key, 0, -1))
+	if err != nil {
+		return GameHistory{}, false, err
+	}
+	if len(blobs) == 0 {
+		return GameHistory{}, false, nil
+	}
+	hostToken, err := redis.String(conn.Do("GET", key+":host"))
+	if err != nil && err != redis.ErrNil {
+		return GameHistory{}, false, err
+	}
+
+	h := GameHistory{HostToken: hostToken, Entries: make([]HistoryEntry, 0, len(blobs))}
+	for _, b := range blobs {
+		var e HistoryEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return GameHistory{}, false, fmt.Errorf("history entry for game %s: %v", gameID, err)
+		}
+		h.Entries = append(h.Entries, e)
+	}
+	return h, true, nil
+}
+
+func (s *redisStore) AppendBuzzLog(gameID string, entries []game.BuzzLogEntry) error {
+	key := redisBuzzLogPrefix + gameID
+	ttl := int64(s.retention / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	args := redis.Args{}.Add(key)
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		args = args.Add(b)
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("RPUSH", args...)
+	conn.Send("EXPIRE", key, ttl)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) LoadBuzzLog(gameID string) ([]game.BuzzLogEntry, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	blobs, err := redis.ByteSlices(conn.Do("LRANGE", redisBuzzLogPrefix+gameID, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]game.BuzzLogEntry, 0, len(blobs))
+	for _, b := range blobs {
+		var e game.BuzzLogEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, fmt.Errorf("buzz log entry for game %s: %v", gameID, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *redisStore) AppendReplay(gameID string, entries []ReplayEntry) error {
+	key := redisReplayPrefix + gameID
+	ttl := int64(s.retention / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	args := redis.Args{}.Add(key)
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		args = args.Add(b)
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("RPUSH", args...)
+	conn.Send("EXPIRE", key, ttl)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) LoadReplay(gameID string) ([]ReplayEntry, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	blobs, err := redis.ByteSlices(conn.Do("LRANGE", redisReplayPrefix+gameID, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ReplayEntry, 0, len(blobs))
+	for _, b := range blobs {
+		var e ReplayEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, fmt.Errorf("replay entry for game %s: %v", gameID, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *redisStore) SaveTemplate(owner string, t GameTemplate) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("HSET", redisTemplatesPrefix+owner, t.Name, b)
+	return err
+}
+
+func (s *redisStore) LoadTemplate(owner, name string) (GameTemplate, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("HGET", redisTemplatesPrefix+owner, name))
+	if err == redis.ErrNil {
+		return GameTemplate{}, false, nil
+	}
+	if err != nil {
+		return GameTemplate{}, false, err
+	}
+
+	var t GameTemplate
+	if err := json.Unmarshal(b, &t); err != nil {
+		return GameTemplate{}, false, fmt.Errorf("template %s: %v", name, err)
+	}
+	return t, true, nil
+}
+
+func (s *redisStore) ListTemplates(owner string) ([]GameTemplate, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	blobs, err := redis.ByteSlices(conn.Do("HVALS", redisTemplatesPrefix+owner))
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]GameTemplate, 0, len(blobs))
+	for _, b := range blobs {
+		var t GameTemplate
+		if err := json.Unmarshal(b, &t); err != nil {
+			return nil, fmt.Errorf("template for %s: %v", owner, err)
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+func (s *redisStore) DeleteTemplate(owner, name string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	n, err := redis.Int(conn.Do("HDEL", redisTemplatesPrefix+owner, name))
+	return n > 0, err
+}
+
+func (s *redisStore) SaveAccount(a HostAccount) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	// drop the index entries of keys that have been revoked
+	old, found, err := s.LoadAccount(a.ID)
+	if err != nil {
+		return err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	if found {
+		for _, k := range old.Keys {
+			conn.Send("HDEL", redisAccountKeysKey, k.Hash)
+		}
+	}
+	for _, k := range a.Keys {
+		conn.Send("HSET", redisAccountKeysKey, k.Hash, a.ID)
+	}
+	conn.Send("HSET", redisAccountsKey, a.ID, b)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) LoadAccount(id string) (HostAccount, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("HGET", redisAccountsKey, id))
+	if err == redis.ErrNil {
+		return HostAccount{}, false, nil
+	}
+	if err != nil {
+		return HostAccount{}, false, err
+	}
+
+	var a HostAccount
+	if err := json.Unmarshal(b, &a); err != nil {
+		return HostAccount{}, false, fmt.Errorf("account %s: %v", id, err)
+	}
+	return a, true, nil
+}
+
+func (s *redisStore) AccountForKey(hash string) (HostAccount, bool, error) {
+	conn := s.pool.Get()
+	id, err := redis.String(conn.Do("HGET", redisAccountKeysKey, hash))
+	conn.Close()
+	if err == redis.ErrNil {
+		return HostAccount{}, false, nil
+	}
+	if err != nil {
+		return HostAccount{}, false, err
+	}
+	return s.LoadAccount(id)
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"time"
+
+	"bzzz/internal/game"
+)
+
+// GameRequest is what a host can ask for when creating a game.
+type GameRequest struct {
+	Locale        string `json:"locale"`
+	OrphanPolicy  string `json:"orphanPolicy"`
+	MaxDuration   string `json:"maxDuration"`
+	TeamLockout   bool   `json:"teamLockout"`
+	Recognition   string `json:"recognition"`
+	TurnTimeout   string `json:"turnTimeout"`
+	FirstBuzzWins bool   `json:"firstBuzzWins"`
+	ArmedBuzzing  bool   `json:"armedBuzzing"`
+	BuzzCooldown  string `json:"buzzCooldown"`
+	AlmostWindow  string `json:"almostWindow"`
+	// LatencyCompensation orders buzzes by when they were pressed, see
+	// game.GameOptions
+	LatencyCompensation bool `json:"latencyCompensation"`
+	// MaxPlayers caps the game's players, over the configured default.
+	// with WaitingRoom, players joining a full game wait for a slot
+	MaxPlayers  int  `json:"maxPlayers"`
+	WaitingRoom bool `json:"waitingRoom"`
+	// Code is the game code the host would like, e.g. "PUBQUIZ", instead
+	// of a generated one
+	Code string `json:"code"`
+	// RecoveryPIN lets the game be taken over from another device if the
+	// host token is lost, see HostTransferHandler
+	RecoveryPIN string `json:"recoveryPin"`
+	// Webhooks are URLs the game's events are POSTed to, signed like the
+	// tenant's webhooks. WebhookEvents picks which, see gameWebhookEvents
+	Webhooks      []string `json:"webhooks"`
+	WebhookEvents []string `json:"webhookEvents"`
+	// Scoring is how verdicts turn into points, see game.ScoringRules
+	Scoring *game.ScoringRules `json:"scoring"`
+	// Public lists the game at /api/games/public under Name, so players
+	// can find it without being given the code
+	Public bool   `json:"public"`
+	Name   string `json:"name"`
+	// Captains gives each team a captain, see game.GameOptions
+	Captains bool `json:"captains"`
+	// Tiebreak orders buzzes too close together to tell apart, see
+	// tiebreak.go
+	Tiebreak string `json:"tiebreak"`
+}
+
+// GameTemplate is a named game setup saved by a host account or tenant, so
+// a recurring trivia night can be started again with
+// POST /api/host?template=<name> instead of configuring everything each
+// week. the settings are the same as creating a game takes, query
+// parameters given at creation override them.
+type GameTemplate struct {
+	Name string `json:"name"`
+	GameRequest
+	// Teams are set up in every game made from the template, listed from
+	// the start for players to pick from
+	Teams     []string        `json:"teams,omitempty"`
+	Questions []game.Question `json:"questions,omitempty"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
@@ -0,0 +1,14 @@
+package store
+
+// Usage is what a tenant has used in one billing period.
+type Usage struct {
+	GamesCreated      int64 `json:"gamesCreated"`
+	ConnectionSeconds int64 `json:"connectionSeconds"`
+	EventsDelivered   int64 `json:"eventsDelivered"`
+}
+
+func (u *Usage) Add(o Usage) {
+	u.GamesCreated += o.GamesCreated
+	u.ConnectionSeconds += o.ConnectionSeconds
+	u.EventsDelivered += o.EventsDelivered
+}
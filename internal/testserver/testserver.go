@@ -0,0 +1,322 @@
+// Package testserver runs a bzzz server in process on httptest, with
+// helpers to create games, join and buzz, attach fake stream clients and
+// assert on the events they receive.
+//
+//	ts := testserver.New(t, nil)
+//	game := ts.CreateGame(client.GameOptions{})
+//	host := ts.ListenHost(game)
+//	alice := ts.Join(game, "alice")
+//	ts.MustBuzz(game, alice)
+//	host.WaitFor("buzz")
+package testserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"bzzz/client"
+	"bzzz/config"
+	"bzzz/server"
+)
+
+// Timeout is how long WaitFor waits for an event before failing the test.
+var Timeout = 2 * time.Second
+
+// AdminToken is the admin token Config sets, for Stats.
+const AdminToken = "testserver-admin"
+
+// Config returns settings suited to an in process server: in memory store
+// and broker, no rate limits and no heartbeats, quality reports or pings
+// mixed in with the events a test is waiting for.
+func Config() *config.Config {
+	c := config.Default()
+	c.Store = "memory"
+	c.Broker = "memory"
+	c.CreateGameRate = 0
+	c.BuzzRate = 0
+	c.HeartbeatInterval = 0
+	c.QualityInterval = 0
+	c.PingInterval = 0
+	c.WebApp = false
+	c.AdminToken = AdminToken
+	return c
+}
+
+// Server is a bzzz server running on an httptest server, with a client
+// pointed at it. failed requests fail the test.
+type Server struct {
+	*httptest.Server
+	// Bzzz is the server under test
+	Bzzz   *server.Server
+	Client *client.Client
+
+	t testing.TB
+}
+
+// New starts a bzzz server set up from c, or Config if c is nil, and
+// serves it on an httptest server. both are shut down when the test ends.
+func New(t testing.TB, c *config.Config) *Server {
+	t.Helper()
+
+	if c == nil {
+		c = Config()
+	}
+	srv, err := server.New(c)
+	if err != nil {
+		t.Fatalf("failed to set up server: %v", err)
+	}
+	if err := srv.StartBackground(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+
+	ts := httptest.NewServer(srv.Handler())
+	s := &Server{Server: ts, Bzzz: srv, Client: client.New(ts.URL), t: t}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+		ts.Close()
+	})
+	return s
+}
+
+// Stats is what the admin API reports about the server's load.
+type Stats struct {
+	Games      int `json:"games"`
+	Players    int `json:"players"`
+	Connected  int `json:"connected"`
+	Hosts      int `json:"hosts"`
+	Hubs       int `json:"hubs"`
+	Goroutines int `json:"goroutines"`
+	// Queues is how many stream queues are open
+	Queues int64 `json:"queues"`
+}
+
+// Stats fetches the server's stats from the admin API, which needs the
+// server set up with AdminToken, as Config does.
+func (s *Server) Stats() Stats {
+	s.t.Helper()
+
+	req, err := http.NewRequest("GET", s.URL+"/api/admin/stats", nil)
+	if err != nil {
+		s.t.Fatalf("failed to fetch stats: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+AdminToken)
+	resp, err := s.Server.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("failed to fetch stats: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.t.Fatalf("failed to fetch stats: %s", resp.Status)
+	}
+
+	var stats Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		s.t.Fatalf("failed to decode stats: %v", err)
+	}
+	return stats
+}
+
+// CreateGame creates a game as a host.
+func (s *Server) CreateGame(opts client.GameOptions) *client.Game {
+	s.t.Helper()
+
+	g, err := s.Client.CreateGame(context.Background(), opts)
+	if err != nil {
+		s.t.Fatalf("failed to create game: %v", err)
+	}
+	return g
+}
+
+// Join adds a player called name to a game.
+func (s *Server) Join(g *client.Game, name string) *client.Player {
+	s.t.Helper()
+
+	p, err := s.Client.Join(context.Background(), g.Code, name, "")
+	if err != nil {
+		s.t.Fatalf("failed to join game %s as %s: %v", g.Code, name, err)
+	}
+	return p
+}
+
+// Buzz buzzes in as a player. unlike the other helpers it returns the
+// error, a *client.Error, so tests can check turned down buzzes.
+func (s *Server) Buzz(g *client.Game, p *client.Player) error {
+	return s.Client.Buzz(context.Background(), g.Code, p.PlayerID, p.Token, "")
+}
+
+// MustBuzz buzzes in as a player, failing the test if it's turned down.
+func (s *Server) MustBuzz(g *client.Game, p *client.Player) {
+	s.t.Helper()
+
+	if err := s.Buzz(g, p); err != nil {
+		s.t.Fatalf("%s failed to buzz: %v", p.Name, err)
+	}
+}
+
+// Listen attaches a stream client as a player.
+func (s *Server) Listen(p *client.Player) *Stream {
+	s.t.Helper()
+
+	return s.attach(p.Name, func(ctx context.Context) (<-chan client.Event, error) {
+		return s.Client.Listen(ctx, p.GameID, p.Token)
+	})
+}
+
+// ListenHost attaches a stream client as a game's host.
+func (s *Server) ListenHost(g *client.Game) *Stream {
+	s.t.Helper()
+
+	return s.attach("host", func(ctx context.Context) (<-chan client.Event, error) {
+		return s.Client.ListenHost(ctx, g.Code, g.HostToken)
+	})
+}
+
+func (s *Server) attach(name string, open func(context.Context) (<-chan client.Event, error)) *Stream {
+	s.t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := open(ctx)
+	if err != nil {
+		cancel()
+		s.t.Fatalf("failed to open %s's stream: %v", name, err)
+	}
+
+	st := &Stream{name: name, t: s.t, cancel: cancel, arrived: make(chan struct{}, 1)}
+	go st.read(events)
+	s.t.Cleanup(st.Close)
+	return st
+}
+
+// Stream is a fake client reading a player or host stream, keeping every
+// event it receives.
+type Stream struct {
+	name   string
+	t      testing.TB
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	events []client.Event
+	// next is the index of the first event WaitFor hasn't looked at
+	next   int
+	closed bool
+	// arrived is poked whenever an event arrives or the stream closes
+	arrived chan struct{}
+}
+
+func (st *Stream) read(events <-chan client.Event) {
+	for e := range events {
+		st.mu.Lock()
+		st.events = append(st.events, e)
+		st.mu.Unlock()
+		st.poke()
+	}
+
+	st.mu.Lock()
+	st.closed = true
+	st.mu.Unlock()
+	st.poke()
+}
+
+func (st *Stream) poke() {
+	select {
+	case st.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// Close hangs the stream up.
+func (st *Stream) Close() {
+	st.cancel()
+}
+
+// Events returns every event received so far.
+func (st *Stream) Events() []client.Event {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return append([]client.Event(nil), st.events...)
+}
+
+// errStreamClosed is returned by Next once the stream has ended and every
+// event has been looked at.
+var errStreamClosed = errors.New("stream closed")
+
+// Next returns the first event WaitFor or Next hasn't looked at yet,
+// waiting up to Timeout for one to arrive.
+func (st *Stream) Next() (client.Event, error) {
+	deadline := time.NewTimer(Timeout)
+	defer deadline.Stop()
+
+	for {
+		st.mu.Lock()
+		if st.next < len(st.events) {
+			e := st.events[st.next]
+			st.next++
+			st.mu.Unlock()
+			return e, nil
+		}
+		closed := st.closed
+		st.mu.Unlock()
+		if closed {
+			return client.Event{}, errStreamClosed
+		}
+
+		select {
+		case <-st.arrived:
+		case <-deadline.C:
+			return client.Event{}, context.DeadlineExceeded
+		}
+	}
+}
+
+// WaitFor skips ahead to the next event of type action, failing the test
+// if none arrives within Timeout.
+func (st *Stream) WaitFor(action string) client.Event {
+	st.t.Helper()
+
+	for {
+		e, err := st.Next()
+		if err != nil {
+			st.t.Fatalf("%s's stream got no %s event: %v", st.name, action, err)
+		}
+		if e.Type == action {
+			return e
+		}
+	}
+}
+
+// WaitForData waits for the next event of type action and decodes its
+// data into v.
+func (st *Stream) WaitForData(action string, v interface{}) client.Event {
+	st.t.Helper()
+
+	e := st.WaitFor(action)
+	if err := e.Decode(v); err != nil {
+		st.t.Fatalf("failed to decode %s's %s event: %v", st.name, action, err)
+	}
+	return e
+}
+
+// AssertNone fails the test if an event of type action arrives within d,
+// looking only at events WaitFor and Next haven't.
+func (st *Stream) AssertNone(action string, d time.Duration) {
+	st.t.Helper()
+
+	time.Sleep(d)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, e := range st.events[st.next:] {
+		if e.Type == action {
+			st.t.Fatalf("%s's stream got an unexpected %s event", st.name, action)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package transport
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Audience selects which streams of a game receive a message.
+type Audience int
+
+const (
+	ToPlayers Audience = 1 << iota
+	ToHost
+
+	ToAll = ToPlayers | ToHost
+)
+
+// Envelope is a batch of messages published together, sequenced back to
+// back and sent to the same audience.
+type Envelope struct {
+	Msgs []Message
+	To   Audience
+	// SequencedAt is when the replica that published the envelope
+	// sequenced it
+	SequencedAt time.Time
+	// Trace is the span it was sequenced under
+	Trace trace.SpanContext
+}
@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Broker carries sequenced envelopes between API replicas. every replica,
+// including the one that published, receives each envelope and delivers it
+// to whichever streams it holds. a game's state is only held by the replica
+// playing it though, so its requests have to be routed there by game code,
+// see game.Manager.
+type Broker interface {
+	Publish(env Envelope) error
+	// Subscribe returns the stream of envelopes published by any replica,
+	// in the order the broker saw them.
+	Subscribe() (<-chan Envelope, error)
+	// Ping checks the broker can be reached and that its subscription, if
+	// it has one to keep up, is up.
+	Ping() error
+}
+
+// NewBroker picks the broker, "memory" (the default, a single replica) or
+// "redis". the redis broker connects to redisURL, the same redis as the
+// redis store.
+func NewBroker(backend, redisURL string) (Broker, error) {
+	switch backend {
+	case "", "memory":
+		return &memoryBroker{ch: make(chan Envelope)}, nil
+	case "redis":
+		return newRedisBroker(redisURL), nil
+	default:
+		return nil, fmt.Errorf("unknown broker %q", backend)
+	}
+}
+
+// memoryBroker hands envelopes straight back to this process.
+type memoryBroker struct {
+	ch chan Envelope
+}
+
+func (b *memoryBroker) Publish(env Envelope) error {
+	b.ch <- env
+	return nil
+}
+
+func (b *memoryBroker) Subscribe() (<-chan Envelope, error) {
+	return b.ch, nil
+}
+
+func (b *memoryBroker) Ping() error {
+	return nil
+}
+
+// redisEventsChannel is the pub/sub channel every replica publishes to and
+// subscribes on.
+const redisEventsChannel = "bzzz:events"
+
+// wireEnvelope is how an envelope travels through redis.
+type wireEnvelope struct {
+	Msgs        []Message `json:"msgs"`
+	To          Audience  `json:"to"`
+	SequencedAt time.Time `json:"sequencedAt"`
+	// Trace is the traceparent of the span the envelope was sequenced
+	// under, if it's traced
+	Trace string `json:"trace,omitempty"`
+}
+
+// redisBroker fans envelopes out over redis pub/sub.
+type redisBroker struct {
+	pool *redis.Pool
+
+	mu sync.Mutex
+	// subscribed is set while a subscription is receiving
+	subscribed bool
+}
+
+func newRedisBroker(url string) *redisBroker {
+	return &redisBroker{pool: &redis.Pool{
+		MaxIdle:     4,
+		IdleTimeout: 4 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url)
+		},
+	}}
+}
+
+func (b *redisBroker) Publish(env Envelope) error {
+	payload, err := json.Marshal(wireEnvelope{Msgs: env.Msgs, To: env.To, SequencedAt: env.SequencedAt, Trace: encodeTrace(env.Trace)})
+	if err != nil {
+		return err
+	}
+
+	conn := b.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", redisEventsChannel, payload)
+	return err
+}
+
+func (b *redisBroker) Subscribe() (<-chan Envelope, error) {
+	// fail fast if redis isn't reachable at startup, after that keep
+	// resubscribing
+	conn := b.pool.Get()
+	if err := conn.Err(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	out := make(chan Envelope)
+	go func() {
+		for {
+			b.receive(conn, out)
+			time.Sleep(time.Second)
+			conn = b.pool.Get()
+		}
+	}()
+	return out, nil
+}
+
+// receive reads from one subscription until the connection fails.
+func (b *redisBroker) receive(conn redis.Conn, out chan<- Envelope) {
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	if err := psc.Subscribe(redisEventsChannel); err != nil {
+		log.Printf("failed to subscribe to %s: %v", redisEventsChannel, err)
+		return
+	}
+	b.setSubscribed(true)
+	defer b.setSubscribed(false)
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var wire wireEnvelope
+			if err := json.Unmarshal(v.Data, &wire); err != nil {
+				log.Printf("dropping malformed envelope: %v", err)
+				continue
+			}
+			out <- Envelope{Msgs: wire.Msgs, To: wire.To, SequencedAt: wire.SequencedAt, Trace: decodeTrace(wire.Trace)}
+		case error:
+			log.Printf("lost subscription to %s: %v", redisEventsChannel, v)
+			return
+		}
+	}
+}
+
+func (b *redisBroker) setSubscribed(subscribed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribed = subscribed
+}
+
+func (b *redisBroker) Ping() error {
+	b.mu.Lock()
+	subscribed := b.subscribed
+	b.mu.Unlock()
+	if !subscribed {
+		return fmt.Errorf("not subscribed to %s", redisEventsChannel)
+	}
+
+	conn := b.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PING")
+	return err
+}
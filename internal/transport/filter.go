@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// EventFilter picks which events a stream wants by action. ?include=buzz,lock
+// sends only those actions, ?exclude=chat,reaction sends everything else.
+// ?events= is the same as ?include=. they accept a comma separated list or a
+// repeated parameter, include wins if both are given.
+type EventFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// ParseEventFilter reads a filter from a stream's query parameters.
+func ParseEventFilter(q url.Values) EventFilter {
+	return EventFilter{
+		include: actionSet(append(q["include"], q["events"]...)),
+		exclude: actionSet(q["exclude"]),
+	}
+}
+
+func actionSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range values {
+		for _, action := range strings.Split(v, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				set[action] = true
+			}
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return set
+}
+
+// Allows reports whether an event with the given action passes the filter,
+// leaving aside the actions no filter holds back, see Queue.Wants.
+func (f EventFilter) Allows(action string) bool {
+	if f.include != nil {
+		return f.include[action]
+	}
+	return !f.exclude[action]
+}
+
+// EventFilterSpec is an EventFilter as JSON.
+type EventFilterSpec struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Filter returns the filter s describes.
+func (s EventFilterSpec) Filter() EventFilter {
+	return EventFilter{include: actionSet(s.Include), exclude: actionSet(s.Exclude)}
+}
+
+// Spec returns f as JSON, to show a client the filter it has.
+func (f EventFilter) Spec() EventFilterSpec {
+	return EventFilterSpec{Include: sortedActions(f.include), Exclude: sortedActions(f.exclude)}
+}
+
+// sortedActions lists a set of actions in order, nil for none.
+func sortedActions(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	list := make([]string, 0, len(set))
+	for action := range set {
+		list = append(list, action)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// SetFilter changes the events the stream reading q is sent, taking effect
+// with the next one.
+func (q *Queue) SetFilter(f EventFilter) {
+	q.filterMu.Lock()
+	defer q.filterMu.Unlock()
+	q.filter = f
+}
+
+// Wants reports whether the stream reading q wants an event with the given
+// action.
+func (q *Queue) Wants(action string) bool {
+	if q.queues.Unfiltered != nil && q.queues.Unfiltered(action) {
+		return true
+	}
+	q.filterMu.Lock()
+	defer q.filterMu.Unlock()
+	return q.filter.Allows(action)
+}
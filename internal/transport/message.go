@@ -0,0 +1,37 @@
+// Package transport carries a game's events to the streams reading them:
+// the messages themselves, the queues each stream reads from, the filters
+// clients set on them, and the broker that spreads events between
+// replicas.
+package transport
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Message is an event sent to clients, or a request a client sent.
+type Message struct {
+	GameID   string `json:"gameID,omitempty"`
+	PlayerID string `json:"playerID,omitempty"`
+	Action   string `json:"action,omitempty"`
+	Seq      int    `json:"seq,omitempty"`
+	Txn      int    `json:"txn,omitempty"`
+	// Nonce is picked by the client so the same request sent more than
+	// once can be recognised
+	Nonce string `json:"nonce,omitempty"`
+	// Private keeps a message sent to players off every stream but
+	// PlayerID's, for things like wagers nobody else may see yet
+	Private bool `json:"private,omitempty"`
+	// State is the lifecycle state the message left its game in, see
+	// lifecycle
+	State string `json:"state,omitempty"`
+
+	// Data carries action specific details
+	Data interface{} `json:"data,omitempty"`
+
+	// Trace is the span the message was last handled under, QueuedAt when
+	// it was queued for streams. neither is sent to clients
+	Trace    trace.SpanContext `json:"-"`
+	QueuedAt time.Time         `json:"-"`
+}
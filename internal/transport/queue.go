@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	highQueueSize = 64
+	LowQueueSize  = 16
+)
+
+// Priority classes decide how a message is queued for a client. High
+// priority gameplay events are never stuck behind low priority traffic.
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityLow
+)
+
+// Queue buffers messages for a single stream with a separate buffer
+// per priority class. every queue has one owner that closes it: a game's
+// subscriptions for player and host streams, the game itself for its
+// spectators and previews, and the dashboard stream for its own. a game
+// closes whatever it still owns when it's torn down, so a stream can't
+// outlive its game waiting on a queue nothing writes to anymore.
+type Queue struct {
+	high chan Message
+	low  chan Message
+
+	// done is closed once the stream reading the queue has gone
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// filter is the events the stream reading the queue wants, see wants
+	filterMu sync.Mutex
+	filter   EventFilter
+
+	// queues is what made the queue, and counts it until it's closed
+	queues *Queues
+}
+
+// Queues makes the queues of a server's streams and counts the ones
+// not yet closed. the count should go back down with the streams reading
+// them, one that only grows is a leak.
+type Queues struct {
+	// LowPriority are the actions that may be dropped for slow clients,
+	// anything else is gameplay critical
+	LowPriority map[string]bool
+	// Unfiltered reports whether an action reaches a stream whatever its
+	// filter
+	Unfiltered func(action string) bool
+
+	open int64
+}
+
+// PriorityOf returns the class a message is queued in.
+func (qs *Queues) PriorityOf(msg Message) Priority {
+	if qs.LowPriority[msg.Action] {
+		return PriorityLow
+	}
+	return PriorityHigh
+}
+
+// NewQueue makes a queue for a stream, counted until it's closed.
+func (qs *Queues) NewQueue() *Queue {
+	atomic.AddInt64(&qs.open, 1)
+	return &Queue{
+		high:   make(chan Message, highQueueSize),
+		low:    make(chan Message, LowQueueSize),
+		done:   make(chan struct{}),
+		queues: qs,
+	}
+}
+
+// Count returns how many queues are open.
+func (qs *Queues) Count() int64 {
+	return atomic.LoadInt64(&qs.open)
+}
+
+// Close marks the queue as dead so nothing waits on it anymore. the message
+// channels themselves stay open so a send racing with close can't panic.
+func (q *Queue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.done)
+		atomic.AddInt64(&q.queues.open, -1)
+	})
+}
+
+// Depth returns how many messages are waiting to be written.
+func (q *Queue) Depth() int {
+	return len(q.high) + len(q.low)
+}
+
+// Send queues a message using its class drop policy. it never blocks:
+// a low priority message is dropped when its buffer is full, while a full
+// high priority buffer means the client can't keep up and should be
+// evicted. evict is only ever true when ok is false.
+func (q *Queue) Send(msg Message) (ok, evict bool) {
+	if q.Offer(msg) {
+		return true, false
+	}
+	return false, q.queues.PriorityOf(msg) == PriorityHigh
+}
+
+// Offer queues a message without ever blocking. It returns false if the
+// message was dropped.
+func (q *Queue) Offer(msg Message) bool {
+	ch := q.low
+	if q.queues.PriorityOf(msg) == PriorityHigh {
+		ch = q.high
+	}
+
+	select {
+	case ch <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// next blocks until a message is available, always draining high priority
+// messages first.
+func (q *Queue) next() Message {
+	select {
+	case msg := <-q.high:
+		return msg
+	default:
+	}
+
+	select {
+	case msg := <-q.high:
+		return msg
+	case msg := <-q.low:
+		return msg
+	}
+}
+
+// NextOr is like next but gives up once done fires or the queue is closed.
+// ok is false if that happened before a message was available.
+func (q *Queue) NextOr(done <-chan struct{}) (msg Message, ok bool) {
+	select {
+	case msg := <-q.high:
+		return msg, true
+	default:
+	}
+
+	select {
+	case msg := <-q.high:
+		return msg, true
+	case msg := <-q.low:
+		return msg, true
+	case <-done:
+		return Message{}, false
+	case <-q.done:
+		return Message{}, false
+	}
+}
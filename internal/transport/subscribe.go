@@ -0,0 +1,31 @@
+package transport
+
+// Subscriptions are the streams subscribed to a game's events, keyed by a
+// client ID that stays the same when the client reconnects. a client
+// coming back replaces its old subscription, whose queue is closed, so a
+// stream that died without anyone noticing isn't written to alongside its
+// replacement until its heartbeats run out.
+type Subscriptions map[string]*Queue
+
+// Subscribe gives clientID a fresh queue from qs, closing and returning
+// the one it had before, if any.
+func (s Subscriptions) Subscribe(qs *Queues, clientID string) (q, old *Queue) {
+	if old = s[clientID]; old != nil {
+		old.Close()
+	}
+	q = qs.NewQueue()
+	s[clientID] = q
+	return q, old
+}
+
+// Unsubscribe closes clientID's queue and forgets it, as long as it's
+// still q and the client hasn't already subscribed again with a newer one.
+// it reports whether it was.
+func (s Subscriptions) Unsubscribe(clientID string, q *Queue) bool {
+	if s[clientID] != q {
+		return false
+	}
+	delete(s, clientID)
+	q.Close()
+	return true
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+)
+
+// host accounts let a host register and create games with an API key of
+// their own, sent as X-Account-Key. with cfg.AnonymousGames off creating a
+// game needs one, and templates and game history belong to the account
+// instead of being shared by everyone on the tenant.
+
+const (
+	maxAccountNameLength = 64
+	maxAccountKeys       = 10
+	// maxAccountGames caps the games an account remembers creating, the
+	// oldest are forgotten first
+	maxAccountGames = 200
+	// accountKeyPrefix starts every account API key, so one pasted in the
+	// wrong place is easy to spot
+	accountKeyPrefix = "bzzz_"
+)
+
+var (
+	errUnknownAccountKey = errors.New("unknown account key")
+	errLastAccountKey    = errors.New("an account's last API key can't be revoked")
+)
+
+// hashAccountKey keeps account API keys out of the store.
+func hashAccountKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAccountKey issues an API key, returning it along with what's kept of
+// it.
+func newAccountKey() (string, store.AccountKey, error) {
+	token, err := game.NewToken()
+	if err != nil {
+		return "", store.AccountKey{}, err
+	}
+	id, err := game.RandomHex(4)
+	if err != nil {
+		return "", store.AccountKey{}, err
+	}
+	key := accountKeyPrefix + token
+	return key, store.AccountKey{
+		ID:        id,
+		Hash:      hashAccountKey(key),
+		Hint:      key[len(key)-4:],
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// accountFor returns the host account whose key a request carries,
+// errNoCredentials if it has none.
+func (srv *Server) accountFor(r *http.Request) (store.HostAccount, error) {
+	key := r.Header.Get("X-Account-Key")
+	if key == "" {
+		return store.HostAccount{}, errNoCredentials
+	}
+	a, ok, err := srv.store.AccountForKey(hashAccountKey(key))
+	if err != nil {
+		return store.HostAccount{}, err
+	}
+	if !ok {
+		return store.HostAccount{}, errUnknownAccountKey
+	}
+	return a, nil
+}
+
+// hostAccountFor returns the host account behind a request to the tenant
+// API, which has to belong to tenant t. found is false for an anonymous
+// request. it writes the error and returns false if the key is bad or
+// anonymous requests aren't allowed and there isn't one.
+func (srv *Server) hostAccountFor(w http.ResponseWriter, r *http.Request, t *tenant, anonymous bool) (a store.HostAccount, found, ok bool) {
+	a, err := srv.accountFor(r)
+	switch err {
+	case nil:
+	case errNoCredentials:
+		if !anonymous {
+			writeError(w, http.StatusUnauthorized, codeAccountKeyRequired, "a host account key is required")
+			return store.HostAccount{}, false, false
+		}
+		return store.HostAccount{}, false, true
+	case errUnknownAccountKey:
+		writeErr(w, http.StatusUnauthorized, err)
+		return store.HostAccount{}, false, false
+	default:
+		log.Printf("failed to look up account key: %v", err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to look up account")
+		return store.HostAccount{}, false, false
+	}
+	if a.TenantID != t.ID {
+		writeError(w, http.StatusForbidden, codeWrongTenant, "account belongs to another tenant")
+		return store.HostAccount{}, false, false
+	}
+	return a, true, true
+}
+
+// addAccountGame remembers that an account created a game.
+func (srv *Server) addAccountGame(accountID, gameID, hostToken string) error {
+	srv.accountsMu.Lock()
+	defer srv.accountsMu.Unlock()
+
+	a, ok, err := srv.store.LoadAccount(accountID)
+	if err != nil || !ok {
+		return err
+	}
+	a.Games = append(a.Games, store.AccountGame{ID: gameID, CreatedAt: time.Now(), HostToken: hostToken})
+	if len(a.Games) > maxAccountGames {
+		a.Games = a.Games[len(a.Games)-maxAccountGames:]
+	}
+	if err := srv.store.SaveAccount(a); err != nil {
+		return err
+	}
+	srv.addDashboardGame(accountID, gameID, hostToken)
+	return nil
+}
+
+type registerRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (req *registerRequest) validate() error {
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Name == "" {
+		return badRequest("name", "name is required")
+	}
+	if len([]rune(req.Name)) > maxAccountNameLength {
+		return badRequest("name", "names can be at most %d characters", maxAccountNameLength)
+	}
+	if req.Email != "" && (len(req.Email) > 254 || !strings.Contains(req.Email, "@")) {
+		return badRequest("email", "bad email [%s]", req.Email)
+	}
+	return nil
+}
+
+// keyResponse carries a newly issued API key, the only time it's shown.
+type keyResponse struct {
+	store.AccountKey
+	APIKey string `json:"apiKey"`
+}
+
+// registerResponse answers a registration with the new account and its
+// first API key.
+type registerResponse struct {
+	Account store.HostAccount `json:"account"`
+	APIKey  string            `json:"apiKey"`
+}
+
+// RegisterAccountHandler registers a host account with the calling tenant,
+// returning it with its first API key.
+func (srv *Server) RegisterAccountHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	t, ok := srv.tenantFor(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeBadCredentials, "unknown tenant credentials")
+		return
+	}
+
+	var req registerRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	id, err := game.ULIDs{}.NewID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to create account")
+		return
+	}
+	key, k, err := newAccountKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to create account")
+		return
+	}
+	a := store.HostAccount{
+		ID:        id,
+		Name:      req.Name,
+		Email:     req.Email,
+		TenantID:  t.ID,
+		CreatedAt: time.Now(),
+		Keys:      []store.AccountKey{k},
+	}
+	if err := srv.store.SaveAccount(a); err != nil {
+		log.Printf("failed to save account %s: %v", id, err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to save account")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(registerResponse{Account: a.Public(), APIKey: key}); err != nil {
+		log.Printf("failed to encode account: %v", err)
+	}
+}
+
+// currentAccount returns the account whose key a request carries, writing
+// an error and returning false if there isn't one.
+func (srv *Server) currentAccount(w http.ResponseWriter, r *http.Request) (store.HostAccount, bool) {
+	a, err := srv.accountFor(r)
+	switch err {
+	case nil:
+		return a, true
+	case errNoCredentials:
+		writeError(w, http.StatusUnauthorized, codeAccountKeyRequired, "account key required")
+	case errUnknownAccountKey:
+		writeErr(w, http.StatusUnauthorized, err)
+	default:
+		log.Printf("failed to look up account key: %v", err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to look up account")
+	}
+	return store.HostAccount{}, false
+}
+
+// AccountHandler returns the calling host account, with its keys and the
+// games it created.
+func (srv *Server) AccountHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	a, ok := srv.currentAccount(w, r)
+	if !ok {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(a.Public()); err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// AccountKeyCreateHandler issues the calling account another API key, e.g.
+// to rotate the one it has.
+func (srv *Server) AccountKeyCreateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	srv.accountsMu.Lock()
+	defer srv.accountsMu.Unlock()
+
+	a, ok := srv.currentAccount(w, r)
+	if !ok {
+		return
+	}
+	if len(a.Keys) >= maxAccountKeys {
+		writeError(w, http.StatusConflict, codeTooManyAccountKeys, fmt.Sprintf("an account can have at most %d API keys", maxAccountKeys))
+		return
+	}
+
+	key, k, err := newAccountKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to create API key")
+		return
+	}
+	a.Keys = append(a.Keys, k)
+	if err := srv.store.SaveAccount(a); err != nil {
+		log.Printf("failed to save account %s: %v", a.ID, err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to save account")
+		return
+	}
+
+	k.Hash = ""
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(keyResponse{AccountKey: k, APIKey: key}); err != nil {
+		log.Printf("failed to encode API key: %v", err)
+	}
+}
+
+// AccountKeyRevokeHandler revokes one of the calling account's API keys,
+// which can be the one it's called with as long as there's another.
+func (srv *Server) AccountKeyRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	srv.accountsMu.Lock()
+	defer srv.accountsMu.Unlock()
+
+	a, ok := srv.currentAccount(w, r)
+	if !ok {
+		return
+	}
+	keyID := mux.Vars(r)["keyID"]
+
+	keys := make([]store.AccountKey, 0, len(a.Keys))
+	for _, k := range a.Keys {
+		if k.ID != keyID {
+			keys = append(keys, k)
+		}
+	}
+	switch {
+	case len(keys) == len(a.Keys):
+		writeError(w, http.StatusNotFound, codeAccountKeyNotFound, "API key not found")
+		return
+	case len(keys) == 0:
+		writeErr(w, http.StatusConflict, errLastAccountKey)
+		return
+	}
+
+	a.Keys = keys
+	if err := srv.store.SaveAccount(a); err != nil {
+		log.Printf("failed to save account %s: %v", a.ID, err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to save account")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+const (
+	ackRetryInterval = 2 * time.Second
+	ackTimeout       = 30 * time.Second
+)
+
+// criticalActions are the actions a player must acknowledge. They are
+// redelivered on the player's stream until acked or ackTimeout passes.
+var criticalActions = map[string]bool{
+	"disconnect":    true,
+	"host_lost":     true,
+	"host_promoted": true,
+}
+
+type pendingAck struct {
+	msg     transport.Message
+	client  *transport.Queue
+	firstAt time.Time
+	lastAt  time.Time
+}
+
+// trackAck records a critical message sent to a player so it can be
+// redelivered until acknowledged.
+func (srv *Server) trackAck(playerID string, client *transport.Queue, msg transport.Message) {
+	if client == nil {
+		return
+	}
+
+	srv.acksMu.Lock()
+	defer srv.acksMu.Unlock()
+
+	if _, ok := srv.pendingAcks[playerID]; !ok {
+		srv.pendingAcks[playerID] = map[int]*pendingAck{}
+	}
+
+	now := time.Now()
+	srv.pendingAcks[playerID][msg.Seq] = &pendingAck{
+		msg:     msg,
+		client:  client,
+		firstAt: now,
+		lastAt:  now,
+	}
+}
+
+// ackEvent clears a pending critical message. It returns false if nothing was
+// pending for the given player and seq.
+func (srv *Server) ackEvent(playerID string, seq int) bool {
+	srv.acksMu.Lock()
+	defer srv.acksMu.Unlock()
+
+	pending, ok := srv.pendingAcks[playerID]
+	if !ok {
+		return false
+	}
+	if _, ok := pending[seq]; !ok {
+		return false
+	}
+
+	delete(pending, seq)
+	if len(pending) == 0 {
+		delete(srv.pendingAcks, playerID)
+	}
+	return true
+}
+
+// redeliverAcks resends unacknowledged critical messages every
+// ackRetryInterval and gives up on them after ackTimeout.
+func (srv *Server) redeliverAcks() {
+	ticker := time.NewTicker(ackRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		srv.acksMu.Lock()
+		now := time.Now()
+		for playerID, pending := range srv.pendingAcks {
+			for seq, p := range pending {
+				if now.Sub(p.firstAt) > ackTimeout {
+					log.Printf("player %s never acked seq %d, giving up", playerID, seq)
+					delete(pending, seq)
+					continue
+				}
+				if now.Sub(p.lastAt) < ackRetryInterval {
+					continue
+				}
+
+				// never block the redelivery loop on a stalled client
+				if p.client.Offer(p.msg) {
+					p.lastAt = now
+				}
+			}
+			if len(pending) == 0 {
+				delete(srv.pendingAcks, playerID)
+			}
+		}
+		srv.acksMu.Unlock()
+	}
+}
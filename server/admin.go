@@ -0,0 +1,186 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+)
+
+// requireAdmin only lets requests bearing the configured admin token through
+// to h. without an admin token the admin API is off.
+func (srv *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if srv.cfg.AdminToken == "" {
+			writeError(w, http.StatusNotFound, "", "the admin API is disabled")
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(srv.cfg.AdminToken)) != 1 {
+			log.Printf("rejected admin request for %s", r.URL.Path)
+			writeError(w, http.StatusUnauthorized, codeAdminTokenRequired, "admin token required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// requireAdminInPublic wraps a handler so it needs the admin token unless
+// the request came in on a private listener, see servedBy. a server
+// embedded through Handler has no listeners of its own, so it always does.
+func (srv *Server) requireAdminInPublic(h http.HandlerFunc) http.HandlerFunc {
+	admin := srv.requireAdmin(h)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if private, _ := r.Context().Value(privateKey{}).(bool); private {
+			h(w, r)
+			return
+		}
+		admin(w, r)
+	}
+}
+
+// summarizeGames sums up every running game, oldest first, with whether
+// its host is gone.
+func (srv *Server) summarizeGames() []game.GameSummary {
+	summaries := srv.manager.Summaries()
+	for i := range summaries {
+		summaries[i].Orphaned = srv.isOrphaned(summaries[i].GameID)
+	}
+	return summaries
+}
+
+// summarizeGame sums up a single game, with whether its host is gone.
+func (srv *Server) summarizeGame(gameID string) (game.GameSummary, bool) {
+	s, ok := srv.manager.Summary(gameID)
+	s.Orphaned = ok && srv.isOrphaned(gameID)
+	return s, ok
+}
+
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// AdminGamesHandler lists every running game.
+func (srv *Server) AdminGamesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	writeAdminJSON(w, map[string]interface{}{"games": srv.summarizeGames()})
+}
+
+// AdminGameHandler returns everything about a single game: its summary,
+// state and host streams.
+func (srv *Server) AdminGameHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	summary, ok := srv.summarizeGame(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+	hosts, _ := srv.manager.Hosts(id)
+
+	writeAdminJSON(w, map[string]interface{}{
+		"summary": summary,
+		"state":   json.RawMessage(srv.gameSnapshot(id)),
+		"hosts":   hosts,
+	})
+}
+
+// AdminEndGameHandler force ends a game, disconnecting everyone in it.
+func (srv *Server) AdminEndGameHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	log.Printf("admin ended game %s", id)
+	srv.recordTimeline(id, timelineEntry{Kind: timelineEvent, Detail: "ended by an admin"})
+	srv.endGame(id)
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serverStats are server wide stats for this replica, for the admin API
+// and /metrics.
+type serverStats struct {
+	StartedAt  time.Time `json:"startedAt"`
+	Uptime     string    `json:"uptime"`
+	Games      int       `json:"games"`
+	Orphaned   int       `json:"orphaned"`
+	Players    int       `json:"players"`
+	Connected  int       `json:"connected"`
+	Hosts      int       `json:"hosts"`
+	Spectators int       `json:"spectators"`
+	Hubs       int       `json:"hubs"`
+	Goroutines int       `json:"goroutines"`
+	HeapBytes  uint64    `json:"heapBytes"`
+	// Queues is how many stream queues are open, see transport.Queues
+	Queues int64 `json:"queues"`
+	// Limits is how the replica stands against cfg.MaxGames and
+	// cfg.MaxStreams
+	Limits limitStats `json:"limits"`
+}
+
+func (srv *Server) currentServerStats() serverStats {
+	stats := serverStats{
+		StartedAt:  srv.startedAt,
+		Uptime:     time.Since(srv.startedAt).Round(time.Second).String(),
+		Hubs:       srv.hubCount(),
+		Goroutines: runtime.NumGoroutine(),
+		Queues:     srv.queues.Count(),
+		Limits:     srv.currentLimitStats(),
+	}
+	for _, s := range srv.summarizeGames() {
+		stats.Games++
+		if s.Orphaned {
+			stats.Orphaned++
+		}
+		stats.Players += s.Players
+		stats.Connected += s.Connected
+		stats.Hosts += s.Hosts
+		stats.Spectators += s.Spectators
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats.HeapBytes = mem.HeapAlloc
+	return stats
+}
+
+// AdminStatsHandler returns server wide stats for this replica.
+func (srv *Server) AdminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	writeAdminJSON(w, srv.currentServerStats())
+}
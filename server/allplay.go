@@ -0,0 +1,314 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// all-play round events. allPlayOpen, allPlayClosed and allPlayReveal go
+// to everyone. allPlayAnswer goes to the host with the answer,
+// allPlayReceived privately to the player who gave it, so nobody else sees
+// an answer before they're all revealed together.
+const (
+	allPlayOpen     = "allplay_open"
+	allPlayAnswer   = "allplay_answer"
+	allPlayReceived = "allplay_received"
+	allPlayClosed   = "allplay_closed"
+	allPlayReveal   = "allplay_reveal"
+)
+
+// allPlayOpenEvent is the data of an allplay_open event.
+type allPlayOpenEvent struct {
+	QuestionID int `json:"questionID"`
+	Points     int `json:"points"`
+}
+
+// allPlayClosedEvent is the data of an allplay_closed event. it says who
+// answered, never what.
+type allPlayClosedEvent struct {
+	Answered []string `json:"answered"`
+}
+
+// allPlayRevealEvent is the data of an allplay_reveal event, every answer
+// with the host's verdict along with the score entries of the players who
+// got it right.
+type allPlayRevealEvent struct {
+	Entries []game.AllPlayEntry `json:"entries"`
+	Scores  []game.ScoreEntry   `json:"scores"`
+}
+
+// allPlayOpenRequest opens an all-play round. Points defaults to what the
+// current question is worth.
+type allPlayOpenRequest struct {
+	Points *int `json:"points"`
+}
+
+func (req allPlayOpenRequest) validate() error {
+	if req.Points != nil && *req.Points < 0 {
+		return badRequest("points", "points can't be negative")
+	}
+	return nil
+}
+
+// allPlayRequest is a player's answer in an all-play round.
+type allPlayRequest struct {
+	PlayerID string `json:"playerID"`
+	Answer   string `json:"answer"`
+}
+
+func (req allPlayRequest) validate() error {
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if strings.TrimSpace(req.Answer) == "" {
+		return badRequest("answer", "answer has no text")
+	}
+	return nil
+}
+
+// allPlayRevealRequest is the host's verdict on every answer, listing the
+// players who got it right.
+type allPlayRevealRequest struct {
+	Correct []string `json:"correct"`
+}
+
+// HostAllPlayOpenHandler opens an all-play round on the current question.
+func (srv *Server) HostAllPlayOpenHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req allPlayOpenRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+	points := srv.manager.QuestionPoints(id)
+	if req.Points != nil {
+		points = *req.Points
+	}
+
+	opened, err := srv.manager.OpenAllPlay(id, points)
+	if err != nil {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, Action: allPlayOpen, Data: allPlayOpenEvent{QuestionID: opened.QuestionID, Points: opened.Points}}, transport.ToAll)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(opened)
+	if err != nil {
+		log.Printf("failed to encode all-play round: %v", err)
+	}
+}
+
+// AllPlayHandler takes a player's answer in an all-play round. the host
+// sees it as it comes in, the player gets it back on their own stream and
+// nobody else hears anything until the answers are revealed.
+func (srv *Server) AllPlayHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req allPlayRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	text := strings.TrimSpace(req.Answer)
+	if r := []rune(text); len(r) > maxAnswerLength {
+		text = string(r[:maxAnswerLength])
+	}
+
+	if !srv.allowFlood(w, r, id, req.PlayerID, "allplay") {
+		return
+	}
+
+	e, err := srv.manager.SubmitAllPlay(id, req.PlayerID, text)
+	switch err {
+	case nil:
+	case game.ErrNoAllPlay, game.ErrAllPlayClosed, game.ErrAlreadyAnswered:
+		writeError(w, http.StatusConflict, errorCode(err), srv.trErr(r, id, err))
+		return
+	default:
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, PlayerID: req.PlayerID, Action: allPlayAnswer, Data: e}, transport.ToHost)
+	srv.publish(transport.Message{GameID: id, PlayerID: req.PlayerID, Action: allPlayReceived, Data: e, Private: true}, transport.ToPlayers)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(e)
+	if err != nil {
+		log.Printf("failed to encode all-play answer: %v", err)
+	}
+}
+
+// HostAllPlayCloseHandler stops the answering. everyone hears who answered
+// but not what.
+func (srv *Server) HostAllPlayCloseHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	closed, err := srv.manager.CloseAllPlay(id)
+	switch err {
+	case nil:
+	case game.ErrNoAllPlay, game.ErrAllPlayClosed:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	answered := allPlayClosedEvent{Answered: []string{}}
+	for _, e := range closed.Entries {
+		answered.Answered = append(answered.Answered, e.PlayerID)
+	}
+	srv.publish(transport.Message{GameID: id, Action: allPlayClosed, Data: answered}, transport.ToAll)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(closed)
+	if err != nil {
+		log.Printf("failed to encode all-play round: %v", err)
+	}
+}
+
+// HostAllPlayRevealHandler reveals every answer to everyone at once with
+// the host's verdicts, awarding the round's points to each player who got
+// it right.
+func (srv *Server) HostAllPlayRevealHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	var req allPlayRevealRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	revealed, missing, err := srv.manager.RevealAllPlay(id, req.Correct)
+	switch err {
+	case nil:
+	case game.ErrNoAllPlay, game.ErrAllPlayOpen, game.ErrAllPlayRevealed:
+		writeErr(w, http.StatusConflict, err)
+		return
+	case game.ErrNotAnswered:
+		writeRequestError(w, badRequest("correct", "player %s hasn't answered", missing))
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	reveal := allPlayRevealEvent{Entries: revealed.Entries, Scores: []game.ScoreEntry{}}
+	for _, e := range revealed.Entries {
+		if !*e.Correct || revealed.Points == 0 {
+			continue
+		}
+		s, err := srv.manager.RecordScore(id, game.ScoreEntry{
+			Kind:     game.ScoreKindAward,
+			PlayerID: e.PlayerID,
+			Points:   revealed.Points,
+			Reason:   "all-play",
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "", fmt.Sprintf("failed to record score: %v", err))
+			return
+		}
+		reveal.Scores = append(reveal.Scores, s)
+	}
+	msgs := []transport.Message{{GameID: id, Action: allPlayReveal, Data: reveal}}
+	if len(reveal.Scores) > 0 {
+		msgs = append(msgs, srv.scoreMessage(id, reveal.Scores))
+	}
+	srv.publishTxn(msgs, transport.ToAll)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(reveal)
+	if err != nil {
+		log.Printf("failed to encode all-play reveal: %v", err)
+	}
+}
+
+// HostAllPlayHandler returns the current or last all-play round with every
+// answer in it.
+func (srv *Server) HostAllPlayHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	round, ok := srv.manager.AllPlay(id)
+	if !ok {
+		if !srv.manager.Exists(id) {
+			writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+			return
+		}
+		writeErr(w, http.StatusNotFound, game.ErrNoAllPlay)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(round)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"time"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// buzzAlmost is sent to the host once a first buzz wins question's almost
+// window has closed, with everyone who buzzed within it of the winner.
+const buzzAlmost = "buzz_almost"
+
+// almostEvent is the data of buzz_almost events.
+type almostEvent struct {
+	QuestionID int       `json:"questionID"`
+	Winner     game.Buzz `json:"winner"`
+	// WindowMs is how long after the winner the window stayed open
+	WindowMs float64 `json:"windowMs"`
+	// Buzzes are the close calls, closest first, each with its DeltaMs
+	// behind the winner
+	Buzzes []game.Buzz `json:"buzzes"`
+}
+
+// scheduleAlmost tells the host who came close to winner once the game's
+// almost window has closed. nothing is sent if nobody did.
+func (srv *Server) scheduleAlmost(gameID string, winner game.Buzz, window time.Duration) {
+	if window <= 0 {
+		return
+	}
+	time.AfterFunc(window, func() {
+		buzzes, ok := srv.manager.TakeAlmost(gameID, winner)
+		if !ok || len(buzzes) == 0 {
+			return
+		}
+		srv.publish(transport.Message{GameID: gameID, Action: buzzAlmost, Data: almostEvent{
+			QuestionID: winner.QuestionID,
+			Winner:     winner,
+			WindowMs:   float64(window) / float64(time.Millisecond),
+			Buzzes:     buzzes,
+		}}, transport.ToHost)
+	})
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// maxAnswerLength caps the text of a submitted answer.
+const maxAnswerLength = 200
+
+// answer events. answers only go to the host, verdicts to everyone.
+const (
+	answerSubmitted = "answer"
+	answerVerdict   = "verdict"
+)
+
+// answerRequest is what a player submits as their answer.
+type answerRequest struct {
+	PlayerID string `json:"playerID"`
+	Text     string `json:"text"`
+}
+
+func (a answerRequest) validate() error {
+	if a.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if strings.TrimSpace(a.Text) == "" {
+		return badRequest("text", "answer has no text")
+	}
+	return nil
+}
+
+// AnswerHandler lets the player who buzzed in submit a text answer, which
+// only the host sees. in a game with captains it's their team's captain who
+// answers for them.
+func (srv *Server) AnswerHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req answerRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	text := strings.TrimSpace(req.Text)
+	if r := []rune(text); len(r) > maxAnswerLength {
+		text = string(r[:maxAnswerLength])
+	}
+
+	if !srv.allowFlood(w, r, id, req.PlayerID, "answer") {
+		return
+	}
+
+	a, err := srv.manager.SubmitAnswer(id, req.PlayerID, text)
+	switch err {
+	case nil:
+	case game.ErrNotOnFloor, game.ErrAlreadyAnswered, game.ErrNotCaptain:
+		writeError(w, http.StatusConflict, errorCode(err), srv.trErr(r, id, err))
+		return
+	default:
+		writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, PlayerID: a.PlayerID, Action: answerSubmitted, Data: a}, transport.ToHost)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(a)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// judgeRequest is the host's verdict on an answer. PlayerID defaults to
+// whoever has the floor and Points to what the game's scoring rules give.
+type judgeRequest struct {
+	PlayerID string `json:"playerID"`
+	Correct  bool   `json:"correct"`
+	Points   *int   `json:"points"`
+}
+
+// HostJudgeHandler marks a player's answer right or wrong. the body is
+// {"playerID": "...", "correct": true, "points": 10}, points defaulting to
+// what the game's scoring rules make the verdict worth and the player
+// defaulting to whoever has the floor. no wrong answer takes a score below
+// the rules' floor. any points go on the score
+// ledger, and everyone gets the verdict along with the scores together,
+// and the board too if a right answer won the pick.
+func (srv *Server) HostJudgeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	var req judgeRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if req.PlayerID == "" {
+		req.PlayerID, _ = srv.manager.Floor(id)
+	}
+	if req.PlayerID == "" {
+		writeError(w, http.StatusConflict, codeNoBuzzes, "nobody has buzzed in")
+		return
+	}
+
+	points := srv.manager.JudgedPoints(id, req.PlayerID, req.Correct, req.Points)
+
+	a, next, err := srv.manager.Judge(id, req.PlayerID, req.Correct, points)
+	if err != nil {
+		writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+		return
+	}
+
+	verdict := map[string]interface{}{"answer": a}
+	if next != "" {
+		verdict["next"] = next
+	}
+	msgs := []transport.Message{{GameID: id, PlayerID: a.PlayerID, Action: answerVerdict, Data: verdict}}
+	undo := game.UndoEntry{Action: game.UndoJudgment, PlayerID: a.PlayerID, QuestionID: a.QuestionID}
+
+	if points != 0 {
+		reason := "correct answer"
+		if !req.Correct {
+			reason = "wrong answer"
+		}
+		e, err := srv.manager.RecordScore(id, game.ScoreEntry{
+			Kind:     game.ScoreKindAward,
+			PlayerID: a.PlayerID,
+			Points:   points,
+			Reason:   reason,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "", fmt.Sprintf("failed to record score: %v", err))
+			return
+		}
+		msgs = append(msgs, srv.scoreMessage(id, []game.ScoreEntry{e}))
+		undo.Scores = []int{e.ID}
+	}
+	srv.manager.PushUndo(id, undo)
+	if req.Correct {
+		// on a board, answering right wins the next pick
+		if v, ok := srv.manager.BoardWinner(id, a.PlayerID); ok {
+			msgs = append(msgs, transport.Message{GameID: id, Action: boardUpdate, Data: v})
+		}
+	}
+	srv.publishTxn(msgs, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(verdict)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
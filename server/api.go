@@ -0,0 +1,1206 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+	"bzzz/internal/transport"
+)
+
+// BuzzHandler records a player's buzz in the game's authoritative buzz order
+// and broadcasts the updated order.
+func (srv *Server) BuzzHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+	log.Println("buzz detected")
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var clientMsg buzzRequest
+	if err := decodeRequest(r, &clientMsg); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	// the game in the body is optional, but can't disagree with the path
+	if clientMsg.GameID == "" {
+		clientMsg.GameID = id
+	}
+	if clientMsg.GameID != id {
+		writeRequestError(w, badRequest("gameID", "gameID [%s] doesn't match the game in the URL", clientMsg.GameID))
+		return
+	}
+	if err := srv.checkPlayer(r, id, clientMsg.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	log.Printf("%v", clientMsg)
+
+	srv.buzzIn(w, r, clientMsg)
+}
+
+// buzzIn counts a checked buzz and answers it on w, whichever transport it
+// came in on.
+func (srv *Server) buzzIn(w http.ResponseWriter, r *http.Request, clientMsg buzzRequest) {
+	received := time.Now()
+	ctx, span := tracer.Start(r.Context(), "buzz", trace.WithAttributes(
+		attribute.String("game.id", clientMsg.GameID),
+		attribute.String("player.id", clientMsg.PlayerID),
+	))
+	defer span.End()
+
+	// a copy of a buzz already counted, e.g. sent over another transport,
+	// gets the same answer as the original without counting again
+	if b, dup := srv.manager.DuplicateBuzz(clientMsg.GameID, clientMsg.PlayerID, clientMsg.Nonce); dup {
+		srv.logBuzz(clientMsg, buzzDuplicate, game.Buzz{}, received)
+		writeDuplicateBuzz(w, b)
+		return
+	}
+
+	if !allowRate(w, srv.buzzLimiter, clientMsg.PlayerID) {
+		return
+	}
+
+	if srv.isOrphaned(clientMsg.GameID) {
+		writeError(w, http.StatusConflict, codeHostAway, srv.tr(r, clientMsg.GameID, "host_away"))
+		return
+	}
+	if srv.paused(w, r, clientMsg.GameID) {
+		return
+	}
+	// every buzz counts against the flood budget, including ones turned
+	// away below for being locked out or late
+	if !srv.allowFlood(w, r, clientMsg.GameID, clientMsg.PlayerID, "buzz") {
+		return
+	}
+
+	if srv.practiceBuzzIn(w, r, clientMsg, received) {
+		return
+	}
+	// a soundcheck buzz only shows the host the player's button works
+	if status, ok := srv.manager.SoundcheckBuzz(clientMsg.GameID, clientMsg.PlayerID); ok {
+		srv.publish(transport.Message{GameID: clientMsg.GameID, PlayerID: clientMsg.PlayerID, Action: soundcheckBuzz, Data: status}, transport.ToHost)
+		srv.logBuzz(clientMsg, buzzPractice, game.Buzz{}, received)
+		writeBuzzResponse(w, http.StatusCreated, buzzResponse{Result: buzzPractice})
+		return
+	}
+
+	if srv.manager.Locked(clientMsg.GameID) {
+		if b, first, ok := srv.manager.LateBuzz(clientMsg.GameID, clientMsg.PlayerID); ok {
+			srv.logBuzz(clientMsg, buzzLate, b, received)
+			srv.rejectLateBuzz(w, r, clientMsg.GameID, b, first)
+			return
+		}
+		srv.logBuzz(clientMsg, buzzLocked, game.Buzz{}, received)
+		writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzLocked, Error: srv.tr(r, clientMsg.GameID, "buzzers_locked")})
+		return
+	}
+
+	b, ok, err := srv.manager.RecordBuzz(clientMsg.GameID, clientMsg.PlayerID, clientMsg.Nonce, clientMsg.QuestionID, srv.latencyCompensation(clientMsg.PlayerID))
+	switch err {
+	case nil:
+	case game.ErrDuplicateBuzz:
+		srv.logBuzz(clientMsg, buzzDuplicate, game.Buzz{}, received)
+		writeDuplicateBuzz(w, b)
+		return
+	case game.ErrTeamLockedOut:
+		srv.logBuzz(clientMsg, buzzLockedOut, game.Buzz{}, received)
+		writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzLockedOut, Error: srv.trErr(r, clientMsg.GameID, err)})
+		return
+	case game.ErrNotYourTurn:
+		srv.logBuzz(clientMsg, buzzNotYourTurn, game.Buzz{}, received)
+		writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzNotYourTurn, Error: srv.trErr(r, clientMsg.GameID, err)})
+		return
+	case game.ErrBuzzCooldown:
+		srv.logBuzz(clientMsg, buzzCooldown, game.Buzz{}, received)
+		srv.writeBuzzCooldown(w, r, clientMsg.GameID, clientMsg.PlayerID)
+		return
+	case game.ErrLateBuzz:
+		srv.logBuzz(clientMsg, buzzLate, b, received)
+		srv.rejectLateBuzz(w, r, clientMsg.GameID, b, true)
+		return
+	case game.ErrBuzzersLocked:
+		srv.logBuzz(clientMsg, buzzLocked, game.Buzz{}, received)
+		writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzLocked, Error: srv.tr(r, clientMsg.GameID, "buzzers_locked")})
+		return
+	case game.ErrStaleBuzz:
+		srv.logBuzz(clientMsg, buzzStale, game.Buzz{}, received)
+		writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzStale, Error: srv.trErr(r, clientMsg.GameID, err)})
+		return
+	case game.ErrBuzzerLocked:
+		srv.logBuzz(clientMsg, buzzTargetLocked, game.Buzz{}, received)
+		writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzTargetLocked, Error: srv.trErr(r, clientMsg.GameID, err)})
+		return
+	case game.ErrEarlyBuzz:
+		srv.logBuzz(clientMsg, buzzEarly, game.Buzz{}, received)
+		srv.penalizeEarlyBuzz(clientMsg.GameID, clientMsg.PlayerID)
+		writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzEarly, Error: srv.trErr(r, clientMsg.GameID, err)})
+		return
+	default:
+		writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+		return
+	}
+	if !ok {
+		srv.logBuzz(clientMsg, buzzAlreadyBuzzed, game.Buzz{}, received)
+		resp := buzzResponse{Result: buzzAlreadyBuzzed, Error: srv.tr(r, clientMsg.GameID, "already_buzzed")}
+		if first, ok := srv.manager.PlayerBuzz(clientMsg.GameID, clientMsg.PlayerID); ok {
+			resp.Position, resp.Buzz = first.Position, &first
+		}
+		writeBuzzResponse(w, http.StatusConflict, resp)
+		return
+	}
+
+	srv.logBuzz(clientMsg, buzzAccepted, b, received)
+
+	span.SetAttributes(attribute.Int("buzz.position", b.Position))
+	order := srv.manager.BuzzOrder(clientMsg.GameID)
+	buzzMsg := traced(ctx, transport.Message{
+		GameID:   clientMsg.GameID,
+		PlayerID: clientMsg.PlayerID,
+		Action:   "buzz",
+		Data: buzzEvent{
+			Buzz:  b,
+			Order: order,
+		},
+	})
+	// in first buzz wins games the winning buzz locked the buzzers, and
+	// everyone hears about both together
+	if opts, _ := srv.manager.Options(clientMsg.GameID); opts.FirstBuzzWins && b.Position == 1 {
+		srv.publishTxn([]transport.Message{buzzMsg, {GameID: clientMsg.GameID, Action: "lock"}}, transport.ToAll)
+		srv.scheduleAlmost(clientMsg.GameID, b, opts.AlmostWindow)
+	} else {
+		srv.publish(buzzMsg, transport.ToAll)
+	}
+	srv.announceTie(clientMsg.GameID, b, order)
+
+	writeBuzzResponse(w, http.StatusCreated, buzzResponse{Result: buzzAccepted, Position: b.Position, Buzz: &b})
+}
+
+// writeDuplicateBuzz answers a buzz that was already counted the same way
+// as the original.
+func writeDuplicateBuzz(w http.ResponseWriter, b game.Buzz) {
+	writeBuzzResponse(w, http.StatusOK, buzzResponse{Result: buzzDuplicate, Position: b.Position, Buzz: &b, Duplicate: true})
+}
+
+// AckHandler acknowledges receipt of a critical event so the server stops
+// redelivering it to the player.
+func (srv *Server) AckHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var ack ackRequest
+	if err := decodeRequest(r, &ack); err != nil {
+		log.Println(err.Error())
+		if re, ok := err.(*requestError); ok && re.Field == "" {
+			re.Message = srv.tr(r, id, "bad_ack")
+		}
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, ack.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if !srv.ackEvent(ack.PlayerID, ack.Seq) {
+		writeError(w, http.StatusNotFound, codeNoPendingEvent, srv.tr(r, id, "no_pending_event", ack.Seq))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HostLockHandler locks a game's buzzers. a body of {"playerID": "..."} or
+// {"team": "..."} locks just that player's or team's, such as the team
+// that just answered wrong, while everyone else can still buzz.
+func (srv *Server) HostLockHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if handled := srv.lockTargetHandled(w, r, id, true); handled {
+		return
+	}
+
+	if err := srv.manager.ApplyHostAction(id, "lock"); err != nil {
+		writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+		return
+	}
+
+	lockMsg := traced(r.Context(), transport.Message{
+		GameID: id,
+		Action: "lock",
+	})
+
+	srv.publish(lockMsg, transport.ToPlayers)
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// HostUnlockHandler unlocks a game's buzzers, everyone's including players
+// and teams locked on their own, or just one player's or team's given a
+// body like HostLockHandler's.
+func (srv *Server) HostUnlockHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if handled := srv.lockTargetHandled(w, r, id, false); handled {
+		return
+	}
+
+	if err := srv.manager.ApplyHostAction(id, "unlock"); err != nil {
+		writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+		return
+	}
+
+	unlockMsg := traced(r.Context(), transport.Message{
+		GameID: id,
+		Action: "unlock",
+	})
+
+	srv.publish(unlockMsg, transport.ToPlayers)
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// LockStatusHandler reports whether a game's buzzers are locked.
+func (srv *Server) LockStatusHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	players, teams := srv.manager.LockedTargets(id)
+	err := json.NewEncoder(w).Encode(lockStatus{Locked: srv.manager.Locked(id), Armed: srv.manager.Armed(id), LockedPlayers: players, LockedTeams: teams})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+func (srv *Server) HostResetHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	questionID, round, err := srv.manager.ResetQuestion(id)
+	if err != nil {
+		writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+		return
+	}
+
+	resetMsg := traced(r.Context(), transport.Message{
+		GameID: id,
+		Action: questionReset,
+		Data:   questionResetEvent{QuestionID: questionID, Round: round},
+	})
+
+	srv.publish(resetMsg, transport.ToAll)
+	srv.advanceTurn(id)
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// hostActions are the actions a host may batch via HostActionsHandler.
+var hostActions = map[string]bool{
+	"lock":   true,
+	"unlock": true,
+	"reset":  true,
+}
+
+// hostActionsRequest is the ordered list of actions to apply together.
+type hostActionsRequest struct {
+	Actions []hostActionRequest `json:"actions"`
+}
+
+// hostActionRequest is one of hostActions.
+type hostActionRequest struct {
+	Action string `json:"action"`
+}
+
+// HostActionsHandler applies an ordered list of host actions atomically. The
+// actions are validated up front and either all of them are emitted back to
+// back as one transaction or none are.
+func (srv *Server) HostActionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusBadRequest, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	var req hostActionsRequest
+	err := decodeRequest(r, &req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if len(req.Actions) == 0 {
+		writeError(w, http.StatusBadRequest, "", "no actions given")
+		return
+	}
+
+	for _, a := range req.Actions {
+		if !hostActions[a.Action] {
+			writeError(w, http.StatusBadRequest, "", fmt.Sprintf("unsupported action [%s]", a.Action))
+			return
+		}
+	}
+
+	// lock, unlock and reset only fail in a game that has ended, so if any
+	// of them does it's the first. a reset is the same as HostResetHandler's,
+	// the next question with the buzzers unlocked and a question_reset for
+	// everyone
+	msgs := make([]transport.Message, 0, len(req.Actions))
+	reset := false
+	for _, a := range req.Actions {
+		msg := traced(r.Context(), transport.Message{
+			GameID: id,
+			Action: a.Action,
+		})
+		if a.Action == "reset" {
+			questionID, round, err := srv.manager.ResetQuestion(id)
+			if err != nil {
+				writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+				return
+			}
+			msg.Action, msg.Data = questionReset, questionResetEvent{QuestionID: questionID, Round: round}
+			reset = true
+		} else if err := srv.manager.ApplyHostAction(id, a.Action); err != nil {
+			writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+			return
+		}
+		msgs = append(msgs, msg)
+	}
+
+	srv.publishTxn(msgs, transport.ToAll)
+	// a new question is the next player's turn
+	if reset {
+		srv.advanceTurn(id)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// HostBackupHandler designates the player who takes over as host if the host
+// is lost in a game using the promote orphan policy.
+func (srv *Server) HostBackupHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req backupRequest
+	err := decodeRequest(r, &req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := srv.manager.SetBackup(id, req.PlayerID); err != nil {
+		writeError(w, http.StatusNotFound, codePlayerNotFound, fmt.Sprintf("player [%s] not found in game [%s]", req.PlayerID, id))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// HostCreateHandler handles a simple POST request to create a game instance
+// and returns a game code.
+func (srv *Server) HostCreateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	t, ok := srv.tenantFor(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeBadCredentials, "unknown tenant credentials")
+		return
+	}
+	a, hasAccount, ok := srv.hostAccountFor(w, r, t, srv.cfg.AnonymousGames)
+	if !ok {
+		return
+	}
+	if !srv.checkQuota(w, t, true) {
+		return
+	}
+
+	// a template's settings are the starting point, anything in the query
+	// overrides them
+	var tmpl store.GameTemplate
+	if name := r.URL.Query().Get("template"); name != "" {
+		if tmpl, ok = srv.loadTemplate(w, templateOwner(t, a, hasAccount), name); !ok {
+			return
+		}
+	}
+
+	req := gameRequestFromQuery(r.URL.Query(), tmpl.GameRequest)
+	opts := srv.newGameOptions(t.ID, req)
+	opts.Teams = tmpl.Teams
+
+	gameCode, err := srv.startGame(r, opts, req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if len(tmpl.Questions) > 0 {
+		if err := srv.manager.LoadQuestions(gameCode, tmpl.Questions); err != nil {
+			writeErr(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	hostToken, _ := srv.manager.HostToken(gameCode)
+	if hasAccount {
+		if err := srv.addAccountGame(a.ID, gameCode, hostToken); err != nil {
+			log.Printf("failed to add game %s to account %s: %v", gameCode, a.ID, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	resp := map[string]interface{}{"gameCode": gameCode, "hostToken": hostToken}
+	if opts.MaxDuration > 0 {
+		resp["endsAt"] = srv.manager.StartedAt(gameCode).Add(opts.MaxDuration).Format(time.RFC3339)
+	}
+	srv.addWebhookSecret(resp, gameCode)
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// gameRequestFromQuery reads a store.GameRequest from query parameters named like
+// its JSON fields, except for webhooks, which are repeated webhook
+// parameters, webhookEvents, which is comma separated, and scoring, which
+// is JSON. parameters that
+// aren't given keep their value from req, e.g. a template's.
+func gameRequestFromQuery(query url.Values, req store.GameRequest) store.GameRequest {
+	str := func(key string, field *string) {
+		if _, ok := query[key]; ok {
+			*field = query.Get(key)
+		}
+	}
+	flag := func(key string, field *bool) {
+		if _, ok := query[key]; ok {
+			*field, _ = strconv.ParseBool(query.Get(key))
+		}
+	}
+
+	str("locale", &req.Locale)
+	str("orphanPolicy", &req.OrphanPolicy)
+	str("maxDuration", &req.MaxDuration)
+	flag("teamLockout", &req.TeamLockout)
+	str("recognition", &req.Recognition)
+	str("turnTimeout", &req.TurnTimeout)
+	flag("firstBuzzWins", &req.FirstBuzzWins)
+	flag("armedBuzzing", &req.ArmedBuzzing)
+	str("buzzCooldown", &req.BuzzCooldown)
+	str("almostWindow", &req.AlmostWindow)
+	str("code", &req.Code)
+	str("recoveryPin", &req.RecoveryPIN)
+	flag("latencyCompensation", &req.LatencyCompensation)
+	if _, ok := query["maxPlayers"]; ok {
+		req.MaxPlayers, _ = strconv.Atoi(query.Get("maxPlayers"))
+	}
+	flag("waitingRoom", &req.WaitingRoom)
+	flag("public", &req.Public)
+	flag("captains", &req.Captains)
+	str("tiebreak", &req.Tiebreak)
+	str("name", &req.Name)
+	if webhooks, ok := query["webhook"]; ok {
+		req.Webhooks = webhooks
+	}
+	if events := query.Get("webhookEvents"); events != "" {
+		req.WebhookEvents = strings.Split(events, ",")
+	}
+	if scoring := query.Get("scoring"); scoring != "" {
+		var rules game.ScoringRules
+		if err := json.Unmarshal([]byte(scoring), &rules); err == nil {
+			req.Scoring = &rules
+		}
+	}
+	return req
+}
+
+// newGameOptions builds a game's options from what the host asked for,
+// ignoring anything unsupported.
+func (srv *Server) newGameOptions(tenantID string, req store.GameRequest) game.GameOptions {
+	opts := game.GameOptions{Tenant: tenantID, TeamLockout: req.TeamLockout, FirstBuzzWins: req.FirstBuzzWins, ArmedBuzzing: req.ArmedBuzzing, LatencyCompensation: req.LatencyCompensation, Public: req.Public, Captains: req.Captains}
+
+	// the host can pin the language used for everything the server says to
+	// players in this game
+	opts.Locale = supportedLocale(req.Locale)
+
+	if orphanPolicies[req.OrphanPolicy] {
+		opts.OrphanPolicy = req.OrphanPolicy
+	}
+
+	// the configured max game duration applies unless the host asks for
+	// their own limit
+	opts.MaxDuration = srv.cfg.MaxGameDuration
+	if d, err := time.ParseDuration(req.MaxDuration); err == nil && d > 0 {
+		opts.MaxDuration = d
+	}
+
+	if recognitionModes[req.Recognition] {
+		opts.Recognition = req.Recognition
+	}
+	if tiebreaks[req.Tiebreak] {
+		opts.Tiebreak = req.Tiebreak
+	}
+	if d, err := time.ParseDuration(req.TurnTimeout); err == nil && d > 0 {
+		opts.TurnTimeout = d
+	}
+
+	// and the configured player cap, with a waiting room if either the
+	// server or the host wants one
+	opts.MaxPlayers = srv.cfg.MaxPlayers
+	if req.MaxPlayers > 0 {
+		opts.MaxPlayers = req.MaxPlayers
+	}
+	opts.WaitingRoom = srv.cfg.WaitingRoom || req.WaitingRoom
+
+	// likewise the configured buzz cooldown, which a host can turn off
+	// with "0s"
+	opts.BuzzCooldown = srv.cfg.BuzzCooldown
+	if d, err := time.ParseDuration(req.BuzzCooldown); err == nil && d >= 0 {
+		opts.BuzzCooldown = d
+	}
+	// and the configured almost window
+	opts.AlmostWindow = srv.cfg.AlmostWindow
+	if d, err := time.ParseDuration(req.AlmostWindow); err == nil && d >= 0 {
+		opts.AlmostWindow = d
+	}
+	if req.Scoring != nil && validateScoring(*req.Scoring) == nil {
+		opts.Scoring = *req.Scoring
+	}
+	return opts
+}
+
+// startGame creates a game, with the custom code, recovery PIN, webhooks
+// and name if they were asked for, and counts it against its tenant. a code
+// or name that's malformed or rejected by moderation, a code that's taken,
+// a malformed PIN or webhook, or bad scoring rules, comes back as a
+// requestError.
+func (srv *Server) startGame(r *http.Request, opts game.GameOptions, req store.GameRequest) (string, error) {
+	if err := checkRecoveryPIN(req.RecoveryPIN); err != nil {
+		return "", err
+	}
+	name, err := gameName(req.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := srv.moderateText(opts.Tenant, srv.localeFor(r, ""), "a new game", "game name", name); err != nil {
+		return "", &requestError{status: http.StatusUnprocessableEntity, Code: codeTextRejected, Message: "game name rejected", Field: "name"}
+	}
+	opts.Name = name
+	if req.Scoring != nil {
+		if err := validateScoring(*req.Scoring); err != nil {
+			return "", err
+		}
+	}
+	webhooks, err := srv.gameWebhooks(req.Webhooks, req.WebhookEvents)
+	if err != nil {
+		return "", err
+	}
+
+	code := req.Code
+	if code != "" {
+		var err error
+		if code, err = customGameCode(code); err != nil {
+			return "", err
+		}
+		if err := srv.moderateText(opts.Tenant, srv.localeFor(r, ""), "a new game", "game code", code); err != nil {
+			return "", &requestError{status: http.StatusUnprocessableEntity, Code: codeGameCodeRejected, Message: "game code rejected", Field: "code"}
+		}
+	}
+
+	gameCode, err := srv.manager.CreateGame(opts, code)
+	if err == game.ErrCodeTaken {
+		return "", &requestError{status: http.StatusConflict, Code: codeGameCodeTaken, Message: fmt.Sprintf("game code [%s] is taken", code), Field: "code"}
+	}
+	if err == game.ErrAtGameLimit {
+		return "", srv.gameLimitError()
+	}
+	if err == game.ErrIDExhausted || err == game.ErrIDSpaceFull {
+		// the code space is crowded rather than anything being broken
+		log.Printf("failed to find a free game code: %v", err)
+		return "", &requestError{status: http.StatusServiceUnavailable, Code: errorCode(err), Message: err.Error(), RetryAfterMs: 1000}
+	}
+	if err != nil {
+		return "", err
+	}
+	if req.RecoveryPIN != "" {
+		srv.manager.SetRecoveryPIN(gameCode, req.RecoveryPIN)
+	}
+	if len(webhooks) > 0 {
+		srv.manager.SetWebhooks(gameCode, webhooks)
+	}
+	srv.scheduleGameEnd(gameCode)
+	srv.meter(opts.Tenant, store.Usage{GamesCreated: 1})
+	srv.publish(transport.Message{GameID: gameCode, Action: gameCreated}, transport.ToHost)
+	return gameCode, nil
+}
+
+// PlayHandler establishes a stream and sends SSE to the client with
+// game updates.
+func (srv *Server) PlayHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, http.StatusInternalServerError, "", "Streaming unsupported!")
+		return
+	}
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	version, err := srv.eventVersion(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	p, resumed, ok := srv.admitPlayer(w, r, id)
+	if !ok {
+		return
+	}
+
+	flusher, _ := srv.startEventStream(w)
+	w, flusher, unbatch := srv.batchEvents(w, flusher)
+	defer unbatch()
+	guard := newWriteGuard(r, func() {
+		log.Printf("player %s stopped reading their stream for game %s, cutting it off", p.PlayerID, id)
+		srv.timelineErrorf(id, "player %s stream stalled", p.PlayerID)
+		srv.publish(transport.Message{GameID: id, PlayerID: p.PlayerID, Action: game.PlayerStalled,
+			Data: stalledEvent{Timeouts: sseMaxTimeouts, TimeoutMs: sseWriteTimeout.Milliseconds()}}, transport.ToHost)
+	})
+	// the request's context is cancelled once the client goes away
+	srv.streamPlayer(r, p, resumed, &sseStream{srv: srv, w: w, flusher: flusher, guard: guard, version: version}, r.Context().Done())
+}
+
+// admitPlayer works out who is connecting to a game's player stream. a
+// player who joined through /join, or is coming back, passes their token or
+// session token to connect as themselves. without one a new player is made. resumed is true
+// for players who have had a stream before. ok is false if an error has
+// been written instead.
+func (srv *Server) admitPlayer(w http.ResponseWriter, r *http.Request, id string) (p game.Player, resumed, ok bool) {
+	queryParams := r.URL.Query()
+
+	// verify requested game exists
+	if !srv.manager.Exists(id) {
+		log.Println("failed to verify that game exists")
+		writeError(w, http.StatusBadRequest, codeGameNotFound, srv.tr(r, "", "game_not_found", id))
+		return game.Player{}, false, false
+	}
+	if !srv.checkGameQuota(w, id) {
+		return game.Player{}, false, false
+	}
+	log.Printf("listening to game: %s", id)
+
+	if session := queryParams.Get("session"); session != "" {
+		gameID, playerID, err := srv.parseSessionToken(session)
+		if err != nil {
+			writeErr(w, http.StatusUnauthorized, err)
+			return game.Player{}, false, false
+		}
+		p, ok := srv.manager.Player(playerID)
+		if gameID != id || !ok || p.GameID != id {
+			// kicked players' sessions die with their player record
+			writeError(w, http.StatusUnauthorized, codeSessionInvalid, "unknown player session")
+			return game.Player{}, false, false
+		}
+		return p, !p.ConnectedAt.IsZero(), true
+	}
+
+	if token := queryParams.Get("token"); token != "" {
+		p, ok := srv.manager.PlayerByToken(id, token)
+		if !ok {
+			// players in the waiting room are held by streamPlayer
+			if p, ok := srv.manager.WaitingByToken(id, token); ok {
+				return p, false, true
+			}
+		}
+		if !ok && srv.manager.Banned(id, token) {
+			writeError(w, http.StatusForbidden, codeBanned, srv.tr(r, id, "banned"))
+			return game.Player{}, false, false
+		}
+		if !ok {
+			writeError(w, http.StatusUnauthorized, codeBadPlayerToken, "unknown player token")
+			return game.Player{}, false, false
+		}
+		// only catch up players who have had a stream before
+		return p, !p.ConnectedAt.IsZero(), true
+	}
+
+	p, err := srv.joinGame(r, id, queryParams.Get("name"), queryParams.Get("team"), queryParams.Get("avatar"))
+	if err == errTextRejected {
+		writeError(w, http.StatusUnprocessableEntity, codeNameRejected, srv.tr(r, id, "name_rejected"))
+		return game.Player{}, false, false
+	}
+	if err == game.ErrBanned {
+		writeError(w, http.StatusForbidden, codeBanned, srv.tr(r, id, "banned"))
+		return game.Player{}, false, false
+	}
+	if err == game.ErrGameEnded {
+		writeError(w, http.StatusConflict, codeGameEnded, srv.tr(r, id, "game_ended"))
+		return game.Player{}, false, false
+	}
+	if err == game.ErrGameFull {
+		writeError(w, http.StatusConflict, codeGameFull, srv.tr(r, id, "game_full"))
+		return game.Player{}, false, false
+	}
+	if re, ok := err.(*requestError); ok {
+		writeRequestError(w, re)
+		return game.Player{}, false, false
+	}
+	if err == game.ErrWaiting {
+		srv.announceWaiting(id, p)
+		return p, false, true
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, err)
+		return game.Player{}, false, false
+	}
+	return p, false, true
+}
+
+// streamPlayer connects an admitted player and feeds their game's events to
+// stream until done fires, the stream fails or the player is removed. a
+// player in the waiting room is held until they get a slot.
+func (srv *Server) streamPlayer(r *http.Request, p game.Player, resumed bool, stream playerStream, done <-chan struct{}) {
+	id, playerID := p.GameID, p.PlayerID
+
+	if !srv.waitForSlot(p, stream, done) {
+		return
+	}
+
+	thisClient, arrival, err := srv.manager.Connect(id, playerID)
+	if err != nil {
+		log.Printf("failed to connect player %s: %v", playerID, err)
+		return
+	}
+	// like a host's, a player's stream can be trimmed down with ?events=,
+	// and changed while it's open with PlayerEventsHandler
+	thisClient.SetFilter(transport.ParseEventFilter(r.URL.Query()))
+
+	opts, _ := srv.manager.Options(id)
+	connectedAt := time.Now()
+	srv.recordTimeline(id, timelineEntry{Kind: timelineConnect, PlayerID: playerID, Detail: r.RemoteAddr})
+
+	var leaveOnce sync.Once
+	leave := func(why string) {
+		leaveOnce.Do(func() {
+			// we need to close this client's channel and remove it to avoid creating a leak.
+			gone := srv.manager.Disconnect(playerID, thisClient)
+			srv.meterConnection(opts.Tenant, connectedAt)
+			srv.recordTimeline(id, timelineEntry{Kind: timelineDisconnect, PlayerID: playerID, Detail: why + " after " + time.Since(connectedAt).String()})
+
+			// a kicked player has already been announced, and a player whose
+			// stream was replaced by a newer one hasn't left
+			if !gone || !srv.manager.PlayerInGame(id, playerID) {
+				return
+			}
+			srv.announceLeft(id, playerID)
+			log.Println("disconnect")
+		})
+	}
+	defer leave("stream ended")
+
+	go func() {
+		<-done
+		leave("closed")
+	}()
+
+	// a client whose stream stops taking writes is gone even if its
+	// connection never closed
+	hb := srv.startHeartbeat(thisClient, func() { leave("missed heartbeats") })
+	defer hb.halt()
+	srv.startQualityReports(id, playerID, thisClient, hb)
+	srv.startClock(id, thisClient, hb)
+	srv.startPings(id, playerID, thisClient, hb)
+
+	// send initial message
+	err = stream.writeHello(map[string]interface{}{
+		"v":          eventV1,
+		"type":       helloEvent,
+		"time":       time.Now().Local().String(),
+		"gameID":     id,
+		"playerID":   playerID,
+		"playerName": p.Name,
+		"token":      p.Token,
+		"session":    srv.newSessionToken(id, playerID),
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	hb.wrote()
+	// end initial message
+
+	if arrival != "" {
+		srv.publish(transport.Message{
+			GameID:   id,
+			PlayerID: playerID,
+			Action:   arrival,
+		}, transport.ToHost)
+	}
+
+	out := srv.outboxFor(playerID)
+	caughtUp := 0
+
+	// catch a returning player up from the last seq they saw. clients may
+	// tell us explicitly, an EventSource does it with Last-Event-ID,
+	// otherwise fall back to what we last delivered.
+	if resumed {
+		lastSeq := out.lastSeq()
+		if seq, ok := lastEventID(r); ok {
+			lastSeq = seq
+		}
+		if seq, err := strconv.Atoi(r.URL.Query().Get("lastSeq")); err == nil {
+			lastSeq = seq
+		}
+
+		missed, ok := out.since(lastSeq)
+		if !ok {
+			// too far behind to replay exactly, the client has to resync
+			missed = append([]transport.Message{resyncMessage(id)}, missed...)
+		}
+		for _, msg := range missed {
+			if !thisClient.Wants(msg.Action) {
+				continue
+			}
+			if err := stream.writeEvent(msg); err != nil {
+				log.Println(err.Error())
+				return
+			}
+			hb.wrote()
+			if msg.Seq > 0 {
+				caughtUp = msg.Seq
+				out.delivered(msg.Seq)
+			}
+		}
+	} else {
+		// a new player gets the last minute or so of the game, so joining
+		// mid question shows the question, lock and scores straight away
+		for _, msg := range srv.recentFor(id) {
+			if !thisClient.Wants(msg.Action) {
+				continue
+			}
+			if err := stream.writeEvent(msg); err != nil {
+				log.Println(err.Error())
+				return
+			}
+			hb.wrote()
+			caughtUp = msg.Seq
+			out.delivered(msg.Seq)
+		}
+	}
+
+	for {
+		msg, ok := thisClient.NextOr(done)
+		if !ok {
+			return
+		}
+
+		if msg.Action == heartbeatAction {
+			if err := stream.writeHeartbeat(); err != nil {
+				return
+			}
+			hb.wrote()
+			continue
+		}
+
+		// skip anything already sent during catch up. critical events are
+		// let through since they are redelivered with the same seq.
+		if msg.Seq > 0 && msg.Seq <= caughtUp && !criticalActions[msg.Action] {
+			continue
+		}
+		if msg.Action == chaosReconnect {
+			leave("hung up by chaos")
+			return
+		}
+		if !thisClient.Wants(msg.Action) {
+			// it's as good as delivered, a resumed stream needn't replay it
+			out.delivered(msg.Seq)
+			continue
+		}
+		if srv.disturb(id, msg) {
+			continue
+		}
+
+		traceEnd := traceWrite(msg, playerID)
+		err := stream.writeEvent(msg)
+		traceEnd(err)
+		if err != nil {
+			log.Println(err.Error())
+			srv.timelineErrorf(id, "failed to write seq %d to player %s: %v", msg.Seq, playerID, err)
+			return
+		}
+		hb.wrote()
+		out.delivered(msg.Seq)
+
+		if msg.Action == serverShutdown {
+			return
+		}
+		if msg.Action == playerKicked && msg.PlayerID == playerID {
+			leave("kicked")
+			return
+		}
+	}
+}
+
+// writePlayerEvent writes a single message to a player's event stream, in
+// the given event schema version.
+func (srv *Server) writePlayerEvent(w http.ResponseWriter, flusher http.Flusher, msg transport.Message, version int) error {
+	jsonBytes, err := json.Marshal(srv.versionedEvent(msg, version))
+	if err != nil {
+		return err
+	}
+
+	return writeSSE(w, flusher, msg.Seq, msg.Action, jsonBytes)
+}
+
+// HostListenHandler establishes a stream and sends SSE related to host features.
+// hosts can trim the stream down with ?include=, ?events= or ?exclude=, see
+// transport.EventFilter.
+func (srv *Server) HostListenHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// the request's context is cancelled once the client goes away
+	done := r.Context().Done()
+
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, http.StatusInternalServerError, "", "Streaming unsupported!")
+		return
+	}
+
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	version, err := srv.eventVersion(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if !srv.checkGameQuota(w, id) {
+		return
+	}
+
+	label := r.URL.Query().Get("label")
+	// cut on a character, not in the middle of one
+	if runes := []rune(label); len(runes) > maxHostLabelLength {
+		label = string(runes[:maxHostLabelLength])
+	}
+	clientID := r.URL.Query().Get("clientID")
+	if len(clientID) > maxClientIDLength {
+		writeRequestError(w, badRequest("clientID", "clientID can be at most %d characters", maxClientIDLength))
+		return
+	}
+	hostQueue, conn, catchUp, err := srv.manager.AddHost(id, clientID, label)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	// the filter can be changed while the stream is open, see
+	// HostEventsHandler
+	hostQueue.SetFilter(transport.ParseEventFilter(r.URL.Query()))
+	opts, _ := srv.manager.Options(id)
+	connectedAt := time.Now()
+
+	srv.hostBack(id)
+	log.Printf("HOST %s listening to game to game: %s", conn.ID, id)
+	srv.recordTimeline(id, timelineEntry{Kind: timelineConnect, Detail: "host " + conn.ID + " " + r.RemoteAddr})
+	srv.publish(transport.Message{GameID: id, Action: "host_joined", Data: conn}, transport.ToHost)
+
+	flusher, _ := srv.startEventStream(w)
+	w, flusher, unbatch := srv.batchEvents(w, flusher)
+	defer unbatch()
+	guard := newWriteGuard(r, func() {
+		log.Printf("host %s stopped reading its stream for game %s, cutting it off", conn.ID, id)
+		srv.timelineErrorf(id, "host %s stream stalled", conn.ID)
+	})
+
+	// written is the last seq written to the host, anything queued behind
+	// it when the stream ends is replayed to whoever hosts next
+	var written int64
+
+	var leaveOnce sync.Once
+	leave := func(why string) {
+		leaveOnce.Do(func() {
+			log.Printf("HOST %s left game: %s", conn.ID, id)
+			hostQueue.Close()
+			// once every host is gone, give them a chance to come back
+			// before the game's orphan policy kicks in
+			// a stream replaced by its client reconnecting hasn't left
+			switch removed, last := srv.manager.RemoveHost(id, hostQueue, int(atomic.LoadInt64(&written))); {
+			case last:
+				srv.hostGone(id)
+			case removed:
+				srv.publish(transport.Message{GameID: id, Action: "host_left", Data: conn}, transport.ToHost)
+			}
+			srv.meterConnection(opts.Tenant, connectedAt)
+			srv.recordTimeline(id, timelineEntry{Kind: timelineDisconnect, Detail: "host " + conn.ID + " " + why + " after " + time.Since(connectedAt).String()})
+		})
+	}
+	defer leave("stream ended")
+
+	hb := srv.startHeartbeat(hostQueue, func() { leave("missed heartbeats") })
+	defer hb.halt()
+	srv.startClock(id, hostQueue, hb)
+
+	// a host reconnecting after a blip is sent what it missed instead of
+	// silently losing buzzes. without a Last-Event-ID, the first host back
+	// on a game gets everything since the last one left, co-hosts joining
+	// someone already hosting start live.
+	var missed []transport.Message
+	caughtUp := 0
+	lastSeq, resumed := lastEventID(r)
+	if !resumed && catchUp >= 0 {
+		lastSeq, resumed = catchUp, true
+	}
+	if resumed {
+		atomic.StoreInt64(&written, int64(lastSeq))
+		var exact bool
+		missed, exact = srv.hostOutboxFor(id).since(lastSeq)
+		if !exact {
+			missed = append([]transport.Message{resyncMessage(id)}, missed...)
+		}
+	}
+
+	for {
+		var msg transport.Message
+		if len(missed) > 0 {
+			msg, missed = missed[0], missed[1:]
+			if msg.Seq > caughtUp {
+				caughtUp = msg.Seq
+			}
+		} else {
+			msg, ok = hostQueue.NextOr(done)
+			if !ok {
+				leave("closed")
+				return
+			}
+			// skip anything already sent during catch up
+			if msg.Seq > 0 && msg.Seq <= caughtUp {
+				continue
+			}
+		}
+		if msg.Action == heartbeatAction {
+			if err := guard.do(func() error { return writeHeartbeat(w, flusher) }); err != nil {
+				return
+			}
+			hb.wrote()
+			continue
+		}
+		if msg.Action == chaosReconnect {
+			leave("hung up by chaos")
+			return
+		}
+		if !hostQueue.Wants(msg.Action) {
+			continue
+		}
+		if srv.disturb(id, msg) {
+			continue
+		}
+
+		jsonBytes, err := json.Marshal(srv.versionedEvent(msg, version))
+		if err != nil {
+			srv.timelineErrorf(id, "failed to encode seq %d for the host: %v", msg.Seq, err)
+			writeError(w, http.StatusInternalServerError, "", "failed to encode response")
+			return
+		}
+
+		traceEnd := traceWrite(msg, "host")
+		err = guard.do(func() error { return writeSSE(w, flusher, msg.Seq, msg.Action, jsonBytes) })
+		traceEnd(err)
+		if err != nil {
+			return
+		}
+		hb.wrote()
+		if int64(msg.Seq) > atomic.LoadInt64(&written) {
+			atomic.StoreInt64(&written, int64(msg.Seq))
+		}
+
+		if msg.Action == serverShutdown {
+			return
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// appFiles is the built-in web client, a host console and a buzzer page
+// that only talk to the API, so a host can run a game from this binary
+// alone without deploying a frontend.
+//
+//go:embed app
+var appFiles embed.FS
+
+// appHandler serves the built-in web client, mounted at /app/.
+func appHandler() http.Handler {
+	sub, err := fs.Sub(appFiles, "app")
+	if err != nil {
+		// app is embedded above, so it's always there
+		panic(err)
+	}
+	return http.StripPrefix("/app/", http.FileServer(http.FS(sub)))
+}
@@ -0,0 +1,66 @@
+// shared helpers for the built-in host console and buzzer page. they only
+// use the public API, the same one any other frontend would.
+"use strict";
+
+var bzzz = {
+  // post sends body as JSON and resolves with the decoded response, or
+  // rejects with the server's error message.
+  post: function (path, body, headers) {
+    var h = { "Content-Type": "application/json" };
+    for (var k in headers || {}) h[k] = headers[k];
+    return fetch(path, { method: "POST", headers: h, body: JSON.stringify(body || {}) })
+      .then(function (res) {
+        return res.text().then(function (text) {
+          if (!res.ok) {
+            var msg = text, body = {};
+            try { body = JSON.parse(text); msg = body.message || text; } catch (e) {}
+            var err = new Error(msg.trim() || res.statusText);
+            err.code = body.code;
+            // e.g. a free name when the one asked for is taken
+            err.suggestion = (body.details || {}).suggestion;
+            throw err;
+          }
+          return text ? JSON.parse(text) : {};
+        });
+      });
+  },
+
+  get: function (path) {
+    return fetch(path).then(function (res) {
+      if (!res.ok) throw new Error(res.statusText);
+      return res.json();
+    });
+  },
+
+  // listen opens an event stream and calls handlers[action] with each
+  // event. the browser reconnects on its own, resuming from the last seq.
+  listen: function (url, handlers) {
+    var es = new EventSource(url);
+    Object.keys(handlers).forEach(function (action) {
+      es.addEventListener(action, function (e) { handlers[action](JSON.parse(e.data)); });
+    });
+    return es;
+  },
+
+  byID: function (id) { return document.getElementById(id); },
+
+  show: function (id, on) { bzzz.byID(id).classList.toggle("hidden", !on); },
+
+  status: function (text, isError) {
+    var el = bzzz.byID("status");
+    el.textContent = text || "";
+    el.classList.toggle("error", !!isError);
+  },
+
+  // list replaces the items of a list element with one per text.
+  list: function (id, items) {
+    var el = bzzz.byID(id);
+    el.innerHTML = "";
+    items.forEach(function (item) {
+      var li = document.createElement("li");
+      li.textContent = item.text;
+      if (item.className) li.className = item.className;
+      el.appendChild(li);
+    });
+  }
+};
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticator identifies the tenant behind a request to the tenant API,
+// creating games, usage, webhooks and the like. host and player tokens are
+// per game and aren't its concern.
+type Authenticator interface {
+	// Authenticate returns the caller's tenant. without tenants configured
+	// that's the zero tenant for anyone who authenticates.
+	Authenticate(r *http.Request) (*tenant, error)
+}
+
+// newAuthenticator picks how callers authenticate: "apikey" (the default,
+// a tenant's X-API-Key), "token" (static bearer tokens) or "jwt" (bearer
+// JWTs from an OIDC provider).
+func (srv *Server) newAuthenticator() (Authenticator, error) {
+	switch kind := srv.cfg.Auth; kind {
+	case "", "apikey":
+		return apiKeyAuthenticator{srv: srv}, nil
+	case "token":
+		return srv.newTokenAuthenticator(srv.cfg.AuthTokens)
+	case "jwt":
+		if srv.cfg.OIDCIssuer == "" {
+			return nil, errors.New("jwt auth needs an OIDC issuer")
+		}
+		// without an audience, any token the issuer signed for any of its
+		// other clients would get in
+		if srv.cfg.OIDCAudience == "" {
+			return nil, errors.New("jwt auth needs an OIDC audience")
+		}
+		return &jwtAuthenticator{srv: srv,
+			issuer:      srv.cfg.OIDCIssuer,
+			audience:    srv.cfg.OIDCAudience,
+			jwksURL:     srv.cfg.OIDCJWKSURL,
+			tenantClaim: srv.cfg.OIDCTenantClaim,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth %q", kind)
+	}
+}
+
+// bearerToken pulls the token off an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// tenantNamed looks up a tenant by id. without tenants configured every
+// caller shares the zero tenant.
+func (srv *Server) tenantNamed(id string) (*tenant, error) {
+	if len(srv.tenants) == 0 {
+		return &tenant{}, nil
+	}
+	t, ok := srv.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant [%s]", id)
+	}
+	return t, nil
+}
+
+// apiKeyAuthenticator matches the X-API-Key header against the keys in the
+// tenants config. without tenants it lets everyone in.
+type apiKeyAuthenticator struct {
+	srv *Server
+}
+
+func (a apiKeyAuthenticator) Authenticate(r *http.Request) (*tenant, error) {
+	if len(a.srv.tenants) == 0 {
+		return &tenant{}, nil
+	}
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return nil, errNoCredentials
+	}
+	t, ok := a.srv.tenantKeys[key]
+	if !ok {
+		return nil, errors.New("unknown API key")
+	}
+	return t, nil
+}
+
+// tokenAuthenticator accepts a fixed set of bearer tokens. each token is
+// written "tenant:token" when there are tenants, or just "token".
+type tokenAuthenticator struct {
+	srv *Server
+
+	// tokens maps token -> tenant id
+	tokens map[string]string
+}
+
+func (srv *Server) newTokenAuthenticator(entries []string) (*tokenAuthenticator, error) {
+	a := &tokenAuthenticator{srv: srv, tokens: map[string]string{}}
+	for _, entry := range entries {
+		tenantID, token := "", entry
+		if i := strings.Index(entry, ":"); i >= 0 {
+			tenantID, token = entry[:i], entry[i+1:]
+		}
+		if token == "" {
+			return nil, errors.New("empty auth token")
+		}
+		a.tokens[token] = tenantID
+	}
+	if len(a.tokens) == 0 {
+		return nil, errors.New("token auth needs at least one token")
+	}
+	return a, nil
+}
+
+func (a *tokenAuthenticator) Authenticate(r *http.Request) (*tenant, error) {
+	given := bearerToken(r)
+	if given == "" {
+		return nil, errNoCredentials
+	}
+	for token, tenantID := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(given)) == 1 {
+			return a.srv.tenantNamed(tenantID)
+		}
+	}
+	return nil, errors.New("unknown token")
+}
+
+// jwtAuthenticator accepts RS256 bearer JWTs issued by an OIDC provider.
+// the tenant is named by a claim. the signing keys come from the JWKS the
+// issuer's discovery document points at unless a JWKS url is given.
+type jwtAuthenticator struct {
+	srv *Server
+
+	issuer      string
+	audience    string
+	jwksURL     string
+	tenantClaim string
+
+	mu sync.Mutex
+	// triedAt is when discovery last failed, err how, so a broken issuer
+	// isn't asked again on every request
+	triedAt time.Time
+	err     error
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*tenant, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, errNoCredentials
+	}
+
+	jwksURL, err := a.keysURL()
+	if err != nil {
+		return nil, err
+	}
+	claims, err := parseJWT(token, func(kid string) (*rsa.PublicKey, error) {
+		return a.srv.jwksKey(jwksURL, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims["iss"] != a.issuer {
+		return nil, errors.New("token from the wrong issuer")
+	}
+	if !jwtAudience(claims, a.audience) {
+		return nil, errors.New("token for the wrong audience")
+	}
+
+	tenantID, _ := claims[a.tenantClaim].(string)
+	return a.srv.tenantNamed(tenantID)
+}
+
+// keysURL returns the JWKS url, discovering it from the issuer the first
+// time it's needed. a failed discovery is tried again after
+// jwksRefetchEvery.
+func (a *jwtAuthenticator) keysURL() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.jwksURL != "" {
+		return a.jwksURL, nil
+	}
+	if time.Since(a.triedAt) < jwksRefetchEvery {
+		return "", a.err
+	}
+	jwksURL, err := a.discover()
+	if err != nil {
+		a.triedAt, a.err = time.Now(), err
+		return "", err
+	}
+	a.jwksURL = jwksURL
+	return a.jwksURL, nil
+}
+
+// discover fetches the issuer's discovery document for its JWKS url.
+func (a *jwtAuthenticator) discover() (string, error) {
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(a.issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching OIDC discovery returned %s", resp.Status)
+	}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return "", err
+	}
+	if discovery.JWKSURI == "" {
+		return "", errors.New("OIDC discovery has no jwks_uri")
+	}
+	return discovery.JWKSURI, nil
+}
+
+// tenantFor identifies the tenant making a request with the configured
+// authenticator. ok is false if the request doesn't authenticate.
+func (srv *Server) tenantFor(r *http.Request) (t *tenant, ok bool) {
+	t, err := srv.authenticator.Authenticate(r)
+	if err != nil {
+		if err != errNoCredentials {
+			log.Printf("rejected tenant credentials: %v", err)
+		}
+		return nil, false
+	}
+	return t, true
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxAvatarLength is in runes, enough for an emoji made of several code
+// points, like a family or a flag
+const maxAvatarLength = 8
+
+// playerAvatar tidies up and checks the avatar a player picked, an emoji
+// or a few characters shown next to their name.
+func playerAvatar(raw string) (string, error) {
+	avatar := strings.TrimSpace(raw)
+	if len([]rune(avatar)) > maxAvatarLength {
+		return "", badRequest("avatar", "avatars can be at most %d characters", maxAvatarLength)
+	}
+	for _, c := range avatar {
+		switch {
+		case unicode.IsLetter(c), unicode.IsMark(c), unicode.IsNumber(c):
+		case unicode.Is(unicode.So, c), unicode.Is(unicode.Sk, c), c == zeroWidthJoiner:
+		default:
+			return "", badRequest("avatar", "avatars can't have %q in them", string(c))
+		}
+	}
+	return avatar, nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// eventBatcher coalesces the writes to an event stream. rather than
+// flushing each event as it's written, the first flush starts a
+// cfg.StreamBatchWindow timer and everything written before it fires goes
+// out together, so a burst of buzzes in a big game costs each stream one
+// flush instead of one per buzz.
+type eventBatcher struct {
+	http.ResponseWriter
+	flusher http.Flusher
+	window  time.Duration
+
+	// mu keeps the timer's flush off the response while the stream is
+	// writing to it
+	mu      sync.Mutex
+	pending bool
+	stopped bool
+}
+
+// batchEvents wraps an event stream's response so its flushes are batched,
+// when cfg.StreamBatchWindow is set. stop must be called before the
+// handler returns, it flushes anything still held back.
+func (srv *Server) batchEvents(w http.ResponseWriter, flusher http.Flusher) (http.ResponseWriter, http.Flusher, func()) {
+	if srv.cfg.StreamBatchWindow <= 0 {
+		return w, flusher, func() {}
+	}
+	b := &eventBatcher{ResponseWriter: w, flusher: flusher, window: srv.cfg.StreamBatchWindow}
+	return b, b, b.stop
+}
+
+func (b *eventBatcher) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ResponseWriter.Write(p)
+}
+
+// Flush schedules a flush at the end of the batch window, unless one is
+// already due.
+func (b *eventBatcher) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending || b.stopped {
+		return
+	}
+	b.pending = true
+	time.AfterFunc(b.window, b.flush)
+}
+
+func (b *eventBatcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return
+	}
+	b.pending = false
+	b.flusher.Flush()
+}
+
+func (b *eventBatcher) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending {
+		b.flusher.Flush()
+	}
+	b.stopped = true
+}
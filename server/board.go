@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// boardUpdate is sent to everyone with the whole board whenever it changes:
+// when the host sets it up, when a cell is picked and when someone new wins
+// the pick.
+const boardUpdate = "board"
+
+// boardStartRequest sets up a board, Picker getting the first pick.
+type boardStartRequest struct {
+	Picker string `json:"picker"`
+}
+
+// pickRequest picks a board question, either by its ID or by its category
+// and points.
+type pickRequest struct {
+	PlayerID   string `json:"playerID"`
+	QuestionID int    `json:"questionID"`
+	Category   string `json:"category"`
+	Points     int    `json:"points"`
+}
+
+func (req pickRequest) validate() error {
+	if req.QuestionID == 0 && req.Category == "" {
+		return badRequest("questionID", "questionID or category is required")
+	}
+	return nil
+}
+
+// pickError answers a pick that failed.
+func (srv *Server) pickError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch err {
+	case game.ErrNoBoard, game.ErrNotYourPick, game.ErrCellUsed:
+		writeError(w, http.StatusConflict, errorCode(err), srv.trErr(r, id, err))
+	case game.ErrNoSuchCell:
+		writeErr(w, http.StatusBadRequest, err)
+	default:
+		writeErr(w, http.StatusNotFound, err)
+	}
+}
+
+// showPick tells everyone about a picked question: the players' buzzers
+// are reset, then the board and the question go out together.
+func (srv *Server) showPick(gameID string, q game.Question, v game.BoardView) {
+	srv.publish(transport.Message{GameID: gameID, Action: "reset"}, transport.ToPlayers)
+	srv.advanceTurn(gameID)
+	srv.publishTxn([]transport.Message{
+		{GameID: gameID, Action: boardUpdate, Data: v},
+		srv.questionShownMessage(gameID, q),
+	}, transport.ToAll)
+}
+
+// HostBoardHandler sets up a board from the loaded questions that have a
+// category, starting over if there already was one. the body is optional,
+// {"picker": "..."} giving a player the first pick, which otherwise is the
+// host's.
+func (srv *Server) HostBoardHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req boardStartRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	v, err := srv.manager.StartBoard(id, req.Picker)
+	switch err {
+	case nil:
+	case game.ErrNoQuestions, game.ErrNoCategories:
+		writeErr(w, http.StatusConflict, err)
+		return
+	case game.ErrPlayerNotFound:
+		writeErr(w, http.StatusBadRequest, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, Action: boardUpdate, Data: v}, transport.ToAll)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("failed to encode board: %v", err)
+	}
+}
+
+// BoardHandler returns a game's board, which cells are left and who picks
+// next.
+func (srv *Server) BoardHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	v, err := srv.manager.Board(id)
+	switch err {
+	case nil:
+	case game.ErrNoBoard:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("failed to encode board: %v", err)
+	}
+}
+
+// PickHandler lets the player with the pick choose the next question off
+// the board, {"playerID": "...", "questionID": 7} or {"playerID": "...",
+// "category": "...", "points": 200}.
+func (srv *Server) PickHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	var req pickRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if req.PlayerID == "" {
+		writeRequestError(w, badRequest("playerID", "playerID is required"))
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if !srv.allowFlood(w, r, id, req.PlayerID, "pick") {
+		return
+	}
+
+	q, v, err := srv.manager.PickCell(id, req.PlayerID, req.QuestionID, req.Category, req.Points)
+	if err != nil {
+		srv.pickError(w, r, id, err)
+		return
+	}
+	srv.showPick(id, q, v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("failed to encode board: %v", err)
+	}
+}
+
+// HostPickHandler picks the next question off the board for whoever has
+// the pick, for the first pick or a picker who has gone quiet. it takes the
+// same body as PickHandler without the playerID, and answers with the
+// question, answer included.
+func (srv *Server) HostPickHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	var req pickRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	q, v, err := srv.manager.PickCell(id, "", req.QuestionID, req.Category, req.Points)
+	if err != nil {
+		srv.pickError(w, r, id, err)
+		return
+	}
+	srv.showPick(id, q, v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(q)
+	if err != nil {
+		log.Printf("failed to encode question: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"bzzz/internal/transport"
+)
+
+// publish queues a message for delivery to the given audience.
+func (srv *Server) publish(msg transport.Message, to transport.Audience) {
+	srv.enqueue(msg.GameID, hubWork{env: transport.Envelope{Msgs: []transport.Message{msg}, To: to}})
+}
+
+// publishTxn queues messages that must be sequenced back to back as a single
+// transaction. Every message in the transaction carries the seq of the first
+// one as its txn. they must all be for the same game.
+func (srv *Server) publishTxn(msgs []transport.Message, to transport.Audience) {
+	if len(msgs) == 0 {
+		return
+	}
+	srv.enqueue(msgs[0].GameID, hubWork{env: transport.Envelope{Msgs: msgs, To: to}})
+}
+
+// sequence numbers published messages and hands them to the broker.
+// sequence numbers are assigned here, by the game's hub, so host and player
+// streams see events for a game in the same total order.
+func (srv *Server) sequence(env transport.Envelope) {
+	span := srv.startSequenceSpan(env)
+	defer span.End()
+
+	// every event carries the state it leaves the game in
+	state, change := srv.stateChange(env.Msgs)
+	if change != nil {
+		defer srv.sequence(transport.Envelope{Msgs: []transport.Message{*change}, To: transport.ToAll})
+	}
+
+	txn := 0
+	sequenced := transport.Envelope{Msgs: make([]transport.Message, 0, len(env.Msgs)), To: env.To, SequencedAt: time.Now(), Trace: span.SpanContext()}
+	for _, msg := range env.Msgs {
+		msg.Seq = srv.manager.NextSeq(msg.GameID)
+		msg.State = state
+		if len(env.Msgs) > 1 {
+			if txn == 0 {
+				txn = msg.Seq
+			}
+			msg.Txn = txn
+		}
+		sequenced.Msgs = append(sequenced.Msgs, msg)
+	}
+	span.SetAttributes(attribute.Int("seq", sequenced.Msgs[0].Seq))
+
+	srv.queueWebhooks(sequenced)
+	srv.recordHistory(sequenced)
+	srv.recordReplay(sequenced)
+
+	if err := srv.broker.Publish(sequenced); err != nil {
+		// still reach the streams on this replica
+		log.Printf("failed to publish to broker, delivering locally: %v", err)
+		span.RecordError(err)
+		srv.relayEnvelope(sequenced)
+	}
+}
+
+// relay hands envelopes coming off the broker to the hubs of their games
+// until the server shuts down. it never waits on a game, however far
+// behind its hub is.
+func (srv *Server) relay(events <-chan transport.Envelope) {
+	srv.setRelaying(true)
+	defer srv.setRelaying(false)
+
+	for {
+		var env transport.Envelope
+		select {
+		case <-srv.done:
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			env = e
+		}
+		if len(env.Msgs) == 0 {
+			continue
+		}
+		srv.enqueue(env.Msgs[0].GameID, hubWork{env: env, relayed: true})
+	}
+}
+
+func (srv *Server) relayEnvelope(env transport.Envelope) {
+	for _, msg := range env.Msgs {
+		msg.Trace = env.Trace
+		srv.deliver(msg, env.To, env.SequencedAt)
+	}
+}
+
+// deliver hands a sequenced message to its audience on this replica.
+func (srv *Server) deliver(msg transport.Message, to transport.Audience, sequencedAt time.Time) {
+	log.Printf("msg received: %v", msg)
+	span := startMessageSpan(msg, "deliver", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+	// each stream's write is a child of this delivery
+	msg.Trace = span.SpanContext()
+	msg.QueuedAt = time.Now()
+	srv.recordRuling(msg)
+	srv.trackTimer(msg)
+	srv.project(msg)
+
+	playerIDs, clients, hosts := srv.manager.Recipients(msg.GameID)
+	delivered, dropped, queued := 0, 0, 0
+	if msg.Private {
+		playerIDs, clients = onlyPlayer(msg.PlayerID, playerIDs, clients)
+	}
+
+	if to&transport.ToPlayers != 0 {
+		// a private message is no business of players joining later
+		if !msg.Private {
+			srv.rememberRecent(msg)
+		}
+
+		// record the message in each player's outbox and track critical
+		// events before they go out so an ack can't arrive ahead of the
+		// pending entry
+		for _, playerID := range playerIDs {
+			srv.outboxFor(playerID).push(msg)
+			if client, ok := clients[playerID]; ok && criticalActions[msg.Action] {
+				srv.trackAck(playerID, client, msg)
+			}
+		}
+
+		for playerID, client := range clients {
+			ok, evict := client.Send(msg)
+			if evict {
+				// a client too far behind to take gameplay events is cut
+				// off rather than stalling everyone else. the message is
+				// already in their outbox for when they reconnect.
+				log.Printf("client queue full, evicting player %s at seq %d for game %s", playerID, msg.Seq, msg.GameID)
+				srv.timelineErrorf(msg.GameID, "evicted slow player %s at seq %d", playerID, msg.Seq)
+				// closing the queue ends their stream, which marks them
+				// gone and tells the host
+				client.Close()
+			}
+			if !ok {
+				log.Printf("client queue full, dropping seq %d for game %s", msg.Seq, msg.GameID)
+				dropped++
+				continue
+			}
+			delivered++
+		}
+
+		// previews are a debugging aid, never wait on them
+		for _, preview := range srv.manager.Previews(msg.GameID) {
+			if !msg.Private {
+				preview.Offer(msg)
+			}
+		}
+	}
+
+	// dashboards watch over the whole game, short of what's private to one
+	// player, and like previews are never waited on
+	if to&transport.ToHost != 0 || !msg.Private {
+		for _, dashboard := range srv.manager.Dashboards(msg.GameID) {
+			dashboard.Offer(msg)
+		}
+	}
+
+	if to&transport.ToHost != 0 {
+		// the backlog takes everything for the host first, so whatever a
+		// missing or slow host doesn't get now it's caught up on from
+		// there rather than anything here ever waiting on it
+		srv.hostOutboxFor(msg.GameID).push(msg)
+
+		if len(hosts) == 0 {
+			log.Printf("no host stream for game %s, keeping seq %d for when one connects", msg.GameID, msg.Seq)
+			queued++
+		}
+		for _, host := range hosts {
+			ok, evict := host.Send(msg)
+			if evict {
+				// a host too far behind for gameplay events is cut off,
+				// its next connection replays them from the backlog
+				log.Printf("host queue full, evicting host at seq %d for game %s", msg.Seq, msg.GameID)
+				srv.timelineErrorf(msg.GameID, "evicted slow host at seq %d", msg.Seq)
+				host.Close()
+				queued++
+				continue
+			}
+			if !ok {
+				log.Printf("host queue for game %s full, dropping seq %d", msg.GameID, msg.Seq)
+				dropped++
+				continue
+			}
+			delivered++
+		}
+	}
+	srv.notifySpectators(msg)
+	srv.meterGameEvents(msg.GameID, delivered)
+
+	e := timelineEntry{
+		Kind:      timelineEvent,
+		Action:    msg.Action,
+		PlayerID:  msg.PlayerID,
+		Seq:       msg.Seq,
+		Delivered: delivered,
+		Dropped:   dropped,
+		Queued:    queued,
+	}
+	if !sequencedAt.IsZero() {
+		e.LatencyMs = float64(time.Since(sequencedAt)) / float64(time.Millisecond)
+	}
+	srv.recordTimeline(msg.GameID, e)
+	span.SetAttributes(
+		attribute.Int("delivered", delivered),
+		attribute.Int("dropped", dropped),
+		attribute.Int("queued", queued),
+	)
+
+	if msg.Action == "disconnect" && msg.PlayerID == "" {
+		for _, playerID := range srv.manager.Remove(msg.GameID) {
+			srv.dropOutbox(playerID)
+			srv.dropFlood(playerID)
+		}
+		srv.clearChallenges(msg.GameID)
+		srv.dropRecent(msg.GameID)
+		srv.dropOutbox("host:" + msg.GameID)
+		srv.endTimeline(msg.GameID)
+		log.Println("game ended")
+	}
+}
+
+// onlyPlayer narrows a message's recipients down to a single player.
+func onlyPlayer(playerID string, playerIDs []string, clients map[string]*transport.Queue) ([]string, map[string]*transport.Queue) {
+	only := map[string]*transport.Queue{}
+	for _, id := range playerIDs {
+		if id != playerID {
+			continue
+		}
+		if client, ok := clients[id]; ok {
+			only[id] = client
+		}
+		return []string{id}, only
+	}
+	return nil, only
+}
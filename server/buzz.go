@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"bzzz/internal/game"
+)
+
+// questionReset is sent to everyone when the host resets the question: the
+// buzz order is cleared and the buzzers unlocked in one go.
+const questionReset = "question_reset"
+
+// questionResetEvent is the data of question_reset events.
+type questionResetEvent struct {
+	// QuestionID is the question that's now up, counting from 1
+	QuestionID int `json:"questionID"`
+	Round      int `json:"round,omitempty"`
+}
+
+// maxNonceLength bounds buzz nonces, which are kept with the game. a UUID
+// fits with room to spare.
+const maxNonceLength = 64
+
+// buzz results, telling a player straight away how their buzz went.
+const (
+	buzzAccepted      = "accepted"
+	buzzDuplicate     = "duplicate"
+	buzzPractice      = "practice"
+	buzzLocked        = "locked"
+	buzzLate          = "late"
+	buzzLockedOut     = "lockedOut"
+	buzzNotYourTurn   = "notYourTurn"
+	buzzAlreadyBuzzed = "alreadyBuzzed"
+	buzzStale         = "stale"
+	buzzTargetLocked  = "buzzerLocked"
+	buzzEarly         = "early"
+)
+
+// buzzResponse answers a buzz, so a player's UI can show where they landed
+// without waiting for the buzz event to come back on their stream.
+type buzzResponse struct {
+	Result string `json:"result"`
+	// Position is the player's place in the buzz order, counting from 1.
+	// in latency compensated games a buzz pressed earlier but arriving
+	// later can still push it back
+	Position int        `json:"position,omitempty"`
+	Buzz     *game.Buzz `json:"buzz,omitempty"`
+	// Duplicate is set when the buzz was already counted
+	Duplicate bool `json:"duplicate,omitempty"`
+	// DeltaMs is how far behind the winning buzz a late buzz landed
+	DeltaMs float64 `json:"deltaMs,omitempty"`
+	// Error explains a buzz that wasn't counted, it's answered with as
+	// the message of an error
+	Error string `json:"-"`
+}
+
+// buzzResultCodes are the error codes of buzzes that weren't counted.
+var buzzResultCodes = map[string]string{
+	buzzLocked:        codeBuzzersLocked,
+	buzzLate:          codeLateBuzz,
+	buzzLockedOut:     codeTeamLockedOut,
+	buzzNotYourTurn:   codeNotYourTurn,
+	buzzAlreadyBuzzed: codeAlreadyBuzzed,
+	buzzStale:         codeStaleBuzz,
+	buzzTargetLocked:  codeBuzzerLocked,
+	buzzEarly:         codeEarlyBuzz,
+}
+
+// requestError turns a buzz that wasn't counted into the error it's
+// answered with, the result and where the buzz landed going in the
+// details.
+func (resp buzzResponse) requestError(status int) *requestError {
+	details := map[string]interface{}{"result": resp.Result}
+	if resp.Position > 0 {
+		details["position"] = resp.Position
+	}
+	if resp.Buzz != nil {
+		details["buzz"] = resp.Buzz
+	}
+	if resp.DeltaMs > 0 {
+		details["deltaMs"] = resp.DeltaMs
+	}
+	return &requestError{status: status, Code: buzzResultCodes[resp.Result], Message: resp.Error, Details: details}
+}
+
+// writeBuzzResponse answers a buzz, as an error if it wasn't counted.
+func writeBuzzResponse(w http.ResponseWriter, status int, resp buzzResponse) {
+	if status >= 400 {
+		writeRequestError(w, resp.requestError(status))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("failed to encode buzz response: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+)
+
+// buzz log results beyond those a buzz is answered with, for buzzes turned
+// away before they got that far.
+const buzzCooldown = "cooldown"
+
+// logBuzz logs a buzz request, see game.Manager.LogBuzz.
+func (srv *Server) logBuzz(req buzzRequest, result string, b game.Buzz, received time.Time) {
+	srv.manager.LogBuzz(req.GameID, req.PlayerID, req.Nonce, result, b, received)
+}
+
+// verifyBuzzLog checks a buzz log's hash chain, returning the number of
+// the first entry that doesn't match, or 0 if they all do. a log kept
+// without chaining has nothing to check.
+func verifyBuzzLog(entries []game.BuzzLogEntry) int {
+	prev := ""
+	for i, e := range entries {
+		if e.Hash == "" {
+			continue
+		}
+		if e.N != i+1 || e.Prev != prev || e.Digest() != e.Hash {
+			return e.N
+		}
+		prev = e.Hash
+	}
+	return 0
+}
+
+// buzzLogResponse is a game's buzz log along with whether its hash chain
+// holds up.
+type buzzLogResponse struct {
+	Entries []game.BuzzLogEntry `json:"entries"`
+	// Intact is false when an entry doesn't match its hash, BrokenAt is the
+	// first such entry
+	Intact   bool `json:"intact"`
+	BrokenAt int  `json:"brokenAt,omitempty"`
+	// Head is the hash of the latest entry, which a league can note down
+	// to check the log against later
+	Head string `json:"head,omitempty"`
+}
+
+// HostBuzzLogHandler downloads a game's buzz log, every buzz it received
+// with the time the server got it, JSON by default or CSV with
+// ?format=csv. like the history it keeps working after the game has ended.
+func (srv *Server) HostBuzzLogHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("unknown format %q, json or csv", format))
+		return
+	}
+
+	if _, ok := srv.loadHistory(w, r); !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	entries, err := srv.store.LoadBuzzLog(id)
+	if err != nil {
+		log.Printf("failed to load buzz log for game %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "", "failed to load buzz log")
+		return
+	}
+	// entries are written in order, but sort anyway so a log pieced
+	// together from more than one store still reads right
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].N < entries[b].N })
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"bzzz-%s-buzzes.csv\"", id))
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"n", "at", "monotonicMs", "playerID", "playerName", "team", "questionID", "round", "result", "position", "deltaMs", "compensationMs", "nonce", "prev", "hash"})
+		for _, e := range entries {
+			cw.Write([]string{
+				strconv.Itoa(e.N),
+				e.At.UTC().Format(time.RFC3339Nano),
+				strconv.FormatFloat(e.MonotonicMs, 'f', 3, 64),
+				e.PlayerID,
+				e.PlayerName,
+				e.Team,
+				strconv.Itoa(e.QuestionID),
+				strconv.Itoa(e.Round),
+				e.Result,
+				strconv.Itoa(e.Position),
+				strconv.FormatFloat(e.DeltaMs, 'f', -1, 64),
+				strconv.FormatFloat(e.CompensationMs, 'f', -1, 64),
+				e.Nonce,
+				e.Prev,
+				e.Hash,
+			})
+		}
+		cw.Flush()
+		return
+	}
+
+	resp := buzzLogResponse{Entries: entries, Intact: true}
+	if broken := verifyBuzzLog(entries); broken != 0 {
+		log.Printf("buzz log for game %s is broken at entry %d", id, broken)
+		resp.Intact, resp.BrokenAt = false, broken
+	}
+	if len(entries) > 0 {
+		resp.Head = entries[len(entries)-1].Hash
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"bzzz-%s-buzzes.json\"", id))
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"bzzz/internal/game"
+)
+
+// chainedLog returns a buzz log of n entries chained the way
+// game.Manager.LogBuzz chains them.
+func chainedLog(n int) []game.BuzzLogEntry {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	var entries []game.BuzzLogEntry
+	prev := ""
+	for i := 1; i <= n; i++ {
+		e := game.BuzzLogEntry{
+			N:          i,
+			At:         start.Add(time.Duration(i) * time.Millisecond),
+			PlayerID:   string(rune('a' + i)),
+			QuestionID: 1,
+			Result:     "accepted",
+			Position:   i,
+			Prev:       prev,
+		}
+		e.Hash = e.Digest()
+		prev = e.Hash
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestVerifyBuzzLog(t *testing.T) {
+	if broken := verifyBuzzLog(chainedLog(5)); broken != 0 {
+		t.Fatalf("intact log broken at %d", broken)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func([]game.BuzzLogEntry) []game.BuzzLogEntry
+		want   int
+	}{
+		{"edited", func(entries []game.BuzzLogEntry) []game.BuzzLogEntry {
+			entries[2].PlayerID = "mallory"
+			return entries
+		}, 3},
+		{"edited and rehashed", func(entries []game.BuzzLogEntry) []game.BuzzLogEntry {
+			entries[2].Position = 1
+			entries[2].Hash = entries[2].Digest()
+			return entries
+		}, 4},
+		{"reordered", func(entries []game.BuzzLogEntry) []game.BuzzLogEntry {
+			entries[1], entries[2] = entries[2], entries[1]
+			return entries
+		}, 3},
+		{"dropped", func(entries []game.BuzzLogEntry) []game.BuzzLogEntry {
+			return append(entries[:1], entries[2:]...)
+		}, 3},
+	} {
+		if broken := verifyBuzzLog(tc.change(chainedLog(5))); broken != tc.want {
+			t.Errorf("%s log broken at %d, want %d", tc.name, broken, tc.want)
+		}
+	}
+}
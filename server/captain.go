@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// team captain events. captains only exist in games with the Captains
+// option, see game.GameOptions.
+const (
+	// captainChanged tells everyone the host made a player their team's
+	// captain
+	captainChanged = "captain_changed"
+	// teamJoinRequested tells a captain, and the host, that a player
+	// joining the game asked to be on their team
+	teamJoinRequested  = "team_join_requested"
+	teamMemberAccepted = "team_member_accepted"
+	teamMemberRemoved  = "team_member_removed"
+	// timeoutRequested tells the host a captain wants a timeout. pausing
+	// the game for it is up to the host
+	timeoutRequested = "timeout_requested"
+)
+
+// captainEvent is the data of a captain_changed event.
+type captainEvent struct {
+	Team       string `json:"team"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+}
+
+// teamMemberEvent is the data of team_join_requested, team_member_accepted
+// and team_member_removed events. PlayerID is the member, Captain who
+// accepted or removed them.
+type teamMemberEvent struct {
+	Team       string `json:"team"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Captain    string `json:"captain,omitempty"`
+}
+
+// timeoutEvent is the data of a timeout_requested event. Count is how many
+// timeouts the team has asked for this game, this one included.
+type timeoutEvent struct {
+	Team       string `json:"team"`
+	PlayerID   string `json:"playerID"`
+	PlayerName string `json:"playerName"`
+	Count      int    `json:"count"`
+}
+
+// announceTeamRequest tells a team's captain, and the host, that a player
+// asked to join it.
+func (srv *Server) announceTeamRequest(gameID string, p game.Player) {
+	team, captain := srv.manager.TeamRequest(gameID, p.PlayerID)
+	if team == "" {
+		return
+	}
+	srv.publish(transport.Message{
+		GameID:   gameID,
+		PlayerID: captain,
+		Action:   teamJoinRequested,
+		Data:     teamMemberEvent{Team: team, PlayerID: p.PlayerID, PlayerName: p.Name},
+		Private:  true,
+	}, transport.ToAll)
+}
+
+// writeCaptainError answers a captain's or host's team request that failed
+// with err.
+func (srv *Server) writeCaptainError(w http.ResponseWriter, r *http.Request, gameID string, err error) {
+	switch err {
+	case game.ErrGameNotFound, game.ErrPlayerNotFound:
+		writeErr(w, http.StatusNotFound, err)
+	case game.ErrNoCaptains, game.ErrNotCaptain, game.ErrNotOnTeam, game.ErrNoTeamRequest:
+		writeError(w, http.StatusConflict, errorCode(err), srv.trErr(r, gameID, err))
+	default:
+		writeError(w, http.StatusInternalServerError, "", err.Error())
+	}
+}
+
+// captainRequest is who the host makes a team's captain.
+type captainRequest struct {
+	Team     string `json:"team"`
+	PlayerID string `json:"playerID"`
+}
+
+func (req *captainRequest) validate() error {
+	req.Team = teamName(req.Team)
+	if req.Team == "" {
+		return badRequest("team", "team is required")
+	}
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	return nil
+}
+
+// teamMemberRequest is a captain, PlayerID, accepting or removing a
+// member of their team.
+type teamMemberRequest struct {
+	PlayerID string `json:"playerID"`
+	MemberID string `json:"memberID"`
+}
+
+func (req teamMemberRequest) validate() error {
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if req.MemberID == "" {
+		return badRequest("memberID", "memberID is required")
+	}
+	return nil
+}
+
+// timeoutRequest is a captain asking for a timeout.
+type timeoutRequest struct {
+	PlayerID string `json:"playerID"`
+}
+
+func (req timeoutRequest) validate() error {
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	return nil
+}
+
+// HostCaptainHandler makes a player their team's captain. the body is
+// {"team": "...", "playerID": "..."}, and the player has to be on the team.
+// without the host picking, a team's captain is whoever has been on it
+// longest.
+func (srv *Server) HostCaptainHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req captainRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	p, err := srv.manager.AppointCaptain(id, req.Team, req.PlayerID)
+	if err != nil {
+		srv.writeCaptainError(w, r, id, err)
+		return
+	}
+
+	ev := captainEvent{Team: req.Team, PlayerID: p.PlayerID, PlayerName: p.Name}
+	srv.publish(traced(r.Context(), transport.Message{GameID: id, PlayerID: p.PlayerID, Action: captainChanged, Data: ev}), transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(ev)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// TeamAcceptHandler lets a captain accept a player who asked to join their
+// team when they joined the game.
+func (srv *Server) TeamAcceptHandler(w http.ResponseWriter, r *http.Request) {
+	srv.teamMember(w, r, true)
+}
+
+// TeamRemoveHandler lets a captain take a player off their team. the
+// player stays in the game without one.
+func (srv *Server) TeamRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	srv.teamMember(w, r, false)
+}
+
+// teamMember accepts or removes a member of a captain's team and tells
+// everyone.
+func (srv *Server) teamMember(w http.ResponseWriter, r *http.Request, accept bool) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req teamMemberRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	action, change := teamMemberAccepted, srv.manager.AcceptMember
+	if !accept {
+		action, change = teamMemberRemoved, srv.manager.RemoveMember
+	}
+	p, err := change(id, req.PlayerID, req.MemberID)
+	if err != nil {
+		srv.writeCaptainError(w, r, id, err)
+		return
+	}
+
+	ev := teamMemberEvent{Team: p.Team, PlayerID: p.PlayerID, PlayerName: p.Name, Captain: req.PlayerID}
+	srv.publish(traced(r.Context(), transport.Message{GameID: id, PlayerID: p.PlayerID, Action: action, Data: ev}), transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(ev)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// TeamTimeoutHandler lets a captain ask the host for a timeout. only the
+// host hears about it, and decides whether to pause the game.
+func (srv *Server) TeamTimeoutHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req timeoutRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if !srv.allowFlood(w, r, id, req.PlayerID, "timeout") {
+		return
+	}
+
+	p, count, err := srv.manager.RequestTimeout(id, req.PlayerID)
+	if err != nil {
+		srv.writeCaptainError(w, r, id, err)
+		return
+	}
+
+	ev := timeoutEvent{Team: p.Team, PlayerID: p.PlayerID, PlayerName: p.Name, Count: count}
+	srv.publish(traced(r.Context(), transport.Message{GameID: id, PlayerID: p.PlayerID, Action: timeoutRequested, Data: ev}), transport.ToHost)
+	log.Printf("team %s asked for timeout %d in game %s", p.Team, count, id)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(ev)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// rulingActions are the host events players are allowed to challenge.
+var rulingActions = map[string]bool{
+	"lock":   true,
+	"unlock": true,
+	"reset":  true,
+	// locking or unlocking one player or team
+	targetLocked:   true,
+	targetUnlocked: true,
+	// a reset from /reset, which also unlocks the buzzers
+	questionReset: true,
+}
+
+type scoreCorrection struct {
+	PlayerID string `json:"playerID"`
+	Points   int    `json:"points"`
+}
+
+type challenge struct {
+	ID          int               `json:"id"`
+	PlayerID    string            `json:"playerID"`
+	Seq         int               `json:"seq"`
+	Reason      string            `json:"reason"`
+	Status      string            `json:"status"`
+	FiledAt     time.Time         `json:"filedAt"`
+	ResolvedAt  *time.Time        `json:"resolvedAt,omitempty"`
+	Note        string            `json:"note,omitempty"`
+	Corrections []scoreCorrection `json:"corrections,omitempty"`
+	// QuestionID and Round are where the challenged ruling was made
+	QuestionID int `json:"questionID,omitempty"`
+	Round      int `json:"round,omitempty"`
+}
+
+const (
+	challengeOpen     = "open"
+	challengeUpheld   = "upheld"
+	challengeRejected = "rejected"
+)
+
+// ruling is a challengeable event that went out.
+type ruling struct {
+	at         time.Time
+	questionID int
+	round      int
+}
+
+// recordRuling remembers when a challengeable event went out.
+func (srv *Server) recordRuling(msg transport.Message) {
+	if !rulingActions[msg.Action] {
+		return
+	}
+	questionID, round := srv.manager.QuestionTag(msg.GameID)
+
+	srv.challengesMu.Lock()
+	defer srv.challengesMu.Unlock()
+
+	if _, ok := srv.rulings[msg.GameID]; !ok {
+		srv.rulings[msg.GameID] = map[int]ruling{}
+	}
+	now := time.Now()
+	srv.rulings[msg.GameID][msg.Seq] = ruling{at: now, questionID: questionID, round: round}
+
+	// nothing older than the window can be challenged anymore
+	for seq, rl := range srv.rulings[msg.GameID] {
+		if now.Sub(rl.at) > srv.cfg.ChallengeWindow {
+			delete(srv.rulings[msg.GameID], seq)
+		}
+	}
+}
+
+// clearChallenges drops everything kept for a finished game.
+func (srv *Server) clearChallenges(gameID string) {
+	srv.challengesMu.Lock()
+	defer srv.challengesMu.Unlock()
+
+	delete(srv.rulings, gameID)
+	delete(srv.challenges, gameID)
+}
+
+// challengeRequest is a player disputing the ruling on the event at Seq.
+type challengeRequest struct {
+	PlayerID string `json:"playerID"`
+	Seq      int    `json:"seq"`
+	Reason   string `json:"reason"`
+}
+
+func (c challengeRequest) validate() error {
+	if c.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if c.Seq <= 0 {
+		return badRequest("seq", "seq must be positive")
+	}
+	return nil
+}
+
+// ChallengeHandler lets a player protest a ruling within challengeWindow.
+func (srv *Server) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req challengeRequest
+	if err := decodeRequest(r, &req); err != nil {
+		log.Println(err.Error())
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	srv.challengesMu.Lock()
+	rl, ok := srv.rulings[id][req.Seq]
+	if !ok || time.Since(rl.at) > srv.cfg.ChallengeWindow {
+		srv.challengesMu.Unlock()
+		writeError(w, http.StatusConflict, codeNotChallengeable, fmt.Sprintf("seq [%d] is not a ruling that can still be challenged", req.Seq))
+		return
+	}
+
+	c := &challenge{
+		ID:         srv.nextChallengeID,
+		PlayerID:   req.PlayerID,
+		Seq:        req.Seq,
+		Reason:     req.Reason,
+		Status:     challengeOpen,
+		FiledAt:    time.Now(),
+		QuestionID: rl.questionID,
+		Round:      rl.round,
+	}
+	srv.nextChallengeID++
+	if _, ok := srv.challenges[id]; !ok {
+		srv.challenges[id] = map[int]*challenge{}
+	}
+	srv.challenges[id][c.ID] = c
+	filed := *c
+	srv.challengesMu.Unlock()
+
+	srv.publish(transport.Message{GameID: id, PlayerID: req.PlayerID, Action: "challenge_filed", Data: filed}, transport.ToHost)
+
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(filed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostChallengesHandler lists every challenge filed in a game.
+func (srv *Server) HostChallengesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	srv.challengesMu.Lock()
+	list := []challenge{}
+	for _, c := range srv.challenges[id] {
+		list = append(list, *c)
+	}
+	srv.challengesMu.Unlock()
+	sort.Slice(list, func(a, b int) bool { return list[a].ID < list[b].ID })
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"challenges": list})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// resolveChallengeRequest is the host's ruling on a challenge.
+type resolveChallengeRequest struct {
+	Upheld      bool              `json:"upheld"`
+	Note        string            `json:"note"`
+	Corrections []scoreCorrection `json:"corrections"`
+}
+
+// HostResolveChallengeHandler lets the host or judges rule on a challenge.
+// an upheld challenge may carry score corrections which are recorded as
+// adjustments in the score ledger and broadcast as a score_correction event
+// referencing the challenged ruling.
+func (srv *Server) HostResolveChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	cid, err := strconv.Atoi(params["challengeID"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("failed to convert challenge id [%s] to int", params["challengeID"]))
+		return
+	}
+
+	var req resolveChallengeRequest
+	err = decodeRequest(r, &req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	for _, c := range req.Corrections {
+		if !srv.manager.PlayerInGame(id, c.PlayerID) {
+			writeError(w, http.StatusNotFound, codePlayerNotFound, fmt.Sprintf("player [%s] not found in game [%s]", c.PlayerID, id))
+			return
+		}
+	}
+
+	srv.challengesMu.Lock()
+	c, ok := srv.challenges[id][cid]
+	if !ok {
+		srv.challengesMu.Unlock()
+		writeError(w, http.StatusNotFound, codeChallengeNotFound, fmt.Sprintf("challenge [%d] not found", cid))
+		return
+	}
+	if c.Status != challengeOpen {
+		srv.challengesMu.Unlock()
+		writeError(w, http.StatusConflict, codeChallengeResolved, fmt.Sprintf("challenge [%d] was already %s", cid, c.Status))
+		return
+	}
+
+	now := time.Now()
+	c.ResolvedAt = &now
+	c.Note = req.Note
+	c.Status = challengeRejected
+	if req.Upheld {
+		c.Status = challengeUpheld
+		c.Corrections = req.Corrections
+	}
+	resolved := *c
+	srv.challengesMu.Unlock()
+
+	msgs := []transport.Message{{GameID: id, PlayerID: resolved.PlayerID, Action: "challenge_resolved", Data: resolved}}
+	if resolved.Status == challengeUpheld && len(resolved.Corrections) > 0 {
+		entries := []game.ScoreEntry{}
+		for _, c := range resolved.Corrections {
+			e, err := srv.manager.RecordScore(id, game.ScoreEntry{
+				Kind:     game.ScoreKindAdjustment,
+				PlayerID: c.PlayerID,
+				Points:   c.Points,
+				Reason:   fmt.Sprintf("challenge %d upheld", resolved.ID),
+			})
+			if err != nil {
+				log.Printf("failed to record correction for challenge %d: %v", resolved.ID, err)
+				continue
+			}
+			entries = append(entries, e)
+		}
+
+		msgs = append(msgs, transport.Message{GameID: id, Action: "score_correction", Data: scoreCorrectionEvent{
+			ChallengeID: resolved.ID,
+			Seq:         resolved.Seq,
+			Corrections: resolved.Corrections,
+			Entries:     entries,
+			Leaderboard: srv.manager.Leaderboard(id),
+		}}, srv.scoreMessage(id, entries))
+	}
+	srv.publishTxn(msgs, transport.ToAll)
+
+	err = json.NewEncoder(w).Encode(resolved)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
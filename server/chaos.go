@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// chaosReconnect is queued on a stream to make it hang up, as if the
+// connection had dropped. it's never written out.
+const chaosReconnect = "chaos_reconnect"
+
+// chaosSettings are the faults injected into streams when the server runs
+// with chaos on, for client developers to test their reconnect and resync
+// logic against. they're dev only, never turn chaos on in production.
+type chaosSettings struct {
+	// GameID limits the faults to one game's streams, every game's when
+	// it's empty
+	GameID string `json:"gameID,omitempty"`
+	// LatencyMs holds each event back before it's written, plus up to
+	// JitterMs more picked at random
+	LatencyMs int `json:"latencyMs"`
+	JitterMs  int `json:"jitterMs"`
+	// DropRate is the share of events, from 0 to 1, never written at all.
+	// their seqs are skipped, so clients see the gap
+	DropRate float64 `json:"dropRate"`
+}
+
+func (s *chaosSettings) validate() error {
+	if s.LatencyMs < 0 || s.LatencyMs > 60000 {
+		return badRequest("latencyMs", "latencyMs must be between 0 and 60000")
+	}
+	if s.JitterMs < 0 || s.JitterMs > 60000 {
+		return badRequest("jitterMs", "jitterMs must be between 0 and 60000")
+	}
+	if s.DropRate < 0 || s.DropRate > 1 {
+		return badRequest("dropRate", "dropRate must be between 0 and 1")
+	}
+	return nil
+}
+
+// disturb applies the chaos settings to an event about to be written to
+// one of gameID's streams, sleeping for the latency and reporting whether
+// the event should be dropped. with chaos off it does nothing.
+func (srv *Server) disturb(gameID string, msg transport.Message) (drop bool) {
+	if !srv.cfg.Chaos || msg.Action == serverShutdown {
+		return false
+	}
+
+	srv.chaosMu.Lock()
+	s := srv.chaos
+	if s.GameID != "" && s.GameID != gameID {
+		srv.chaosMu.Unlock()
+		return false
+	}
+	delay := time.Duration(s.LatencyMs) * time.Millisecond
+	if s.JitterMs > 0 {
+		delay += time.Duration(srv.chaosRand.Intn(s.JitterMs+1)) * time.Millisecond
+	}
+	drop = s.DropRate > 0 && srv.chaosRand.Float64() < s.DropRate
+	srv.chaosMu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return drop
+}
+
+// AdminChaosHandler returns the faults being injected into streams.
+func (srv *Server) AdminChaosHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	srv.chaosMu.Lock()
+	s := srv.chaos
+	srv.chaosMu.Unlock()
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// AdminSetChaosHandler replaces the faults being injected into streams. an
+// empty body turns them all off.
+func (srv *Server) AdminSetChaosHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	var req chaosSettings
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	srv.chaosMu.Lock()
+	srv.chaos = req
+	srv.chaosMu.Unlock()
+	log.Printf("chaos now %+v", req)
+
+	err := json.NewEncoder(w).Encode(req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// chaosReconnectRequest picks the streams to hang up.
+type chaosReconnectRequest struct {
+	GameID string `json:"gameID"`
+	// PlayerID picks one player's stream, every host and player stream in
+	// the game is hung up when it's empty
+	PlayerID string `json:"playerID,omitempty"`
+}
+
+func (req *chaosReconnectRequest) validate() error {
+	if req.GameID == "" {
+		return badRequest("gameID", "gameID is required")
+	}
+	return nil
+}
+
+// AdminChaosReconnectHandler hangs up a game's streams, or one player's,
+// so their clients have to reconnect.
+func (srv *Server) AdminChaosReconnectHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	var req chaosReconnectRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if !srv.manager.Exists(req.GameID) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, "", "game_not_found", req.GameID))
+		return
+	}
+
+	streams := 0
+	for _, q := range srv.manager.StreamQueues(req.GameID, req.PlayerID) {
+		if q.Offer(transport.Message{GameID: req.GameID, Action: chaosReconnect}) {
+			streams++
+		}
+	}
+	log.Printf("chaos hung up %d streams in game %s", streams, req.GameID)
+
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(map[string]int{"streams": streams})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// serverClock is sent on player and host streams every clock interval with
+// the server's time, so clients can keep their offset from it up to date as
+// their clocks drift. it's only as good as the stream is fast, clients
+// after a precise offset measure it with GET /api/time.
+const serverClock = "clock"
+
+// clockEvent is the data of a clock event.
+type clockEvent struct {
+	ServerTime time.Time `json:"serverTime"`
+	// ServerMs is ServerTime in Unix milliseconds
+	ServerMs int64 `json:"serverMs"`
+}
+
+// timeSync answers a client syncing its clock. with t0 the client's time
+// when it sent the request and t3 when the answer arrived, its clock is
+// ((ReceivedMs - t0) + (SentMs - t3)) / 2 behind the server's and the round
+// trip took (t3 - t0) - (SentMs - ReceivedMs), the same sums NTP does.
+type timeSync struct {
+	// ClientTime is the ?t= the client sent, passed back as it is so it
+	// needn't keep track of its requests
+	ClientTime string `json:"clientTime,omitempty"`
+	// ReceivedMs and SentMs are when the server got the request and when
+	// it answered, in Unix milliseconds
+	ReceivedMs int64     `json:"receivedMs"`
+	SentMs     int64     `json:"sentMs"`
+	ServerTime time.Time `json:"serverTime"`
+}
+
+// unixMs is t in Unix milliseconds, the resolution clients keep time in.
+func unixMs(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// startClock sends a stream the server's time every clock interval until
+// the stream's heartbeat is halted. clock events aren't sequenced, they
+// describe the server rather than the game.
+func (srv *Server) startClock(gameID string, q *transport.Queue, hb *heartbeat) {
+	if srv.cfg.ClockInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(srv.cfg.ClockInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hb.stop:
+				return
+			case <-ticker.C:
+			}
+
+			now := time.Now()
+			q.Offer(transport.Message{GameID: gameID, Action: serverClock, Data: clockEvent{ServerTime: now, ServerMs: unixMs(now)}})
+		}
+	}()
+}
+
+// TimeHandler answers a client syncing its clock with the server's, see
+// timeSync. it needs no game, so displays can sync before there is one. a
+// client should take the answer with the shortest round trip out of a few.
+func TimeHandler(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	log.Printf("Got connection: %s", r.Proto)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	sent := time.Now()
+	err := json.NewEncoder(w).Encode(timeSync{
+		ClientTime: r.URL.Query().Get("t"),
+		ReceivedMs: unixMs(received),
+		SentMs:     unixMs(sent),
+		ServerTime: sent,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
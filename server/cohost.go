@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// maxHostLabelLength caps the labels hosts give their streams, in characters.
+const maxHostLabelLength = 32
+
+// maxClientIDLength caps the IDs clients give their streams.
+const maxClientIDLength = 64
+
+// HostRosterHandler lists the host streams open on a game.
+func (srv *Server) HostRosterHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	hosts, ok := srv.manager.Hosts(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"hosts": hosts})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
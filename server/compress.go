@@ -0,0 +1,180 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+
+// acceptsGzip reports whether a client takes gzipped responses. it can turn
+// them down for its connection with ?compress=false, e.g. a display on a
+// slow CPU that would rather spend the bandwidth, or by leaving gzip out of
+// its Accept-Encoding.
+func acceptsGzip(r *http.Request) bool {
+	if raw := r.URL.Query().Get("compress"); raw != "" {
+		if on, err := strconv.ParseBool(raw); err == nil && !on {
+			return false
+		}
+	}
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(enc, ";")
+		if name := strings.ToLower(strings.TrimSpace(parts[0])); name != "gzip" && name != "*" {
+			continue
+		}
+		// gzip;q=0 means anything but gzip
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[len("q="):], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressed gzips a route's responses for clients that accept it. event
+// streams are gzipped from their first byte and every flush of the stream
+// flushes the gzip stream too, so events still arrive one at a time. other
+// responses are held back until they reach cfg.CompressMinSize, anything
+// smaller goes out as it is, since gzip would only make it bigger.
+func (srv *Server) compressed(stream bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !srv.cfg.Compression || websocket.IsWebSocketUpgrade(r) {
+			next(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, stream: stream, minSize: srv.cfg.CompressMinSize}
+		defer gw.close()
+		next(gw, r)
+	}
+}
+
+// gzipResponseWriter gzips what's written to it once it knows the
+// response is worth it.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	stream  bool
+	minSize int
+
+	status  int
+	decided bool
+	// buf holds the start of a response until it's known whether to gzip it
+	buf []byte
+	gz  *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if w.stream || strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.start(true)
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.minSize {
+			return len(p), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends what's been written so far, gzipped if it's being gzipped.
+// a response flushed before it's decided is taken for a stream.
+func (w *gzipResponseWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.start(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// start sends the headers, gzipping the response if compress is true and
+// it can be, along with anything held back so far.
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || w.status < http.StatusOK ||
+		w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		compress = false
+	}
+	if compress {
+		// net/http would sniff the gzipped bytes, so sniff the real ones
+		if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+			h.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close finishes the response once the handler's done with it.
+func (w *gzipResponseWriter) close() {
+	if w.status == 0 {
+		// the handler didn't write anything, leave the status to net/http
+		return
+	}
+	if !w.decided {
+		// everything fit in buf, too small to gzip
+		w.start(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(nil)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
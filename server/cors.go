@@ -0,0 +1,131 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// corsExposedHeaders are the response headers besides the safelisted ones
+// that browsers let pages read, for backing off and naming downloads.
+var corsExposedHeaders = []string{"Retry-After", "Content-Disposition"}
+
+// originAllowed reports whether cfg.CORSOrigins lets a page from origin call
+// the API.
+func (srv *Server) originAllowed(origin string) bool {
+	for _, allowed := range srv.cfg.CORSOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsWildcard reports whether any origin is allowed, in which case
+// responses say so with "*" instead of echoing the caller's origin.
+func (srv *Server) corsWildcard() bool {
+	for _, allowed := range srv.cfg.CORSOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMethodAllowed reports whether cfg.CORSMethods has method.
+func (srv *Server) corsMethodAllowed(method string) bool {
+	for _, allowed := range srv.cfg.CORSMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsHeadersAllowed reports whether cfg.CORSHeaders has every header in a
+// preflight's comma separated Access-Control-Request-Headers.
+func (srv *Server) corsHeadersAllowed(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		ok := false
+		for _, allowed := range srv.cfg.CORSHeaders {
+			if strings.EqualFold(allowed, header) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// corsHandler applies the CORS policy in cfg to every request. preflights
+// are answered here without reaching the routes, turned away with a 403 if
+// they ask for a method or header the policy doesn't allow. requests from
+// origins that aren't allowed go through without CORS headers, so browsers
+// keep the response from the page.
+func (srv *Server) corsHandler(next http.Handler) http.Handler {
+	methods := strings.Join(srv.cfg.CORSMethods, ", ")
+	headers := strings.Join(srv.cfg.CORSHeaders, ", ")
+	exposed := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(srv.cfg.CORSMaxAge.Seconds()))
+	wildcard := srv.corsWildcard()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		h := w.Header()
+		if !wildcard {
+			h.Add("Vary", "Origin")
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" || !srv.originAllowed(origin) {
+			if preflight {
+				log.Printf("CORS preflight from %q turned away, origin not allowed", origin)
+				writeError(w, http.StatusForbidden, codeCORSRejected, "origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if wildcard {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", exposed)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		method := r.Header.Get("Access-Control-Request-Method")
+		if !srv.corsMethodAllowed(method) {
+			log.Printf("CORS preflight from %s turned away, method %s not allowed", origin, method)
+			writeError(w, http.StatusForbidden, codeCORSRejected, "method not allowed")
+			return
+		}
+		if requested := r.Header.Get("Access-Control-Request-Headers"); !srv.corsHeadersAllowed(requested) {
+			log.Printf("CORS preflight from %s turned away, headers %q not allowed", origin, requested)
+			writeError(w, http.StatusForbidden, codeCORSRejected, "headers not allowed")
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", methods)
+		h.Set("Access-Control-Allow-Headers", headers)
+		if srv.cfg.CORSMaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
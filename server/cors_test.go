@@ -0,0 +1,93 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"bzzz/internal/testserver"
+)
+
+// preflight sends a CORS preflight for a POST to /api/host, returning the
+// response.
+func preflight(t *testing.T, ts *testserver.Server, origin, method, headers string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest("OPTIONS", ts.URL+"/api/host", nil)
+	if err != nil {
+		t.Fatalf("failed to send preflight: %v", err)
+	}
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	resp, err := ts.Server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to send preflight: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestCORSPreflight(t *testing.T) {
+	c := testserver.Config()
+	c.CORSOrigins = []string{"https://quiz.example"}
+	ts := testserver.New(t, c)
+
+	for _, tc := range []struct {
+		name, origin, method, headers string
+		want                          int
+	}{
+		{"allowed", "https://quiz.example", "POST", "Content-Type, Authorization", http.StatusNoContent},
+		{"origin in another case", "https://QUIZ.example", "POST", "", http.StatusNoContent},
+		{"player token header", "https://quiz.example", "POST", "x-player-token", http.StatusNoContent},
+		{"disallowed origin", "https://evil.example", "POST", "Content-Type", http.StatusForbidden},
+		{"disallowed method", "https://quiz.example", "PATCH", "", http.StatusForbidden},
+		{"disallowed header", "https://quiz.example", "POST", "Content-Type, X-Evil", http.StatusForbidden},
+	} {
+		resp := preflight(t, ts, tc.origin, tc.method, tc.headers)
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s preflight got %d, want %d", tc.name, resp.StatusCode, tc.want)
+			continue
+		}
+		allowOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+		switch {
+		case tc.origin == "https://evil.example" && allowOrigin != "":
+			t.Errorf("%s preflight allowed origin %q", tc.name, allowOrigin)
+		case tc.want == http.StatusNoContent && allowOrigin != tc.origin:
+			t.Errorf("%s preflight allowed origin %q, want %q", tc.name, allowOrigin, tc.origin)
+		case tc.want == http.StatusNoContent && resp.Header.Get("Access-Control-Allow-Methods") == "":
+			t.Errorf("%s preflight has no allowed methods", tc.name)
+		}
+	}
+}
+
+// requests from an origin that isn't allowed still go through, just
+// without the headers that would let the page read the response
+func TestCORSDisallowedOriginRequest(t *testing.T) {
+	c := testserver.Config()
+	c.CORSOrigins = []string{"https://quiz.example"}
+	ts := testserver.New(t, c)
+
+	for origin, want := range map[string]string{
+		"https://quiz.example": "https://quiz.example",
+		"https://evil.example": "",
+	} {
+		req, err := http.NewRequest("GET", ts.URL+"/healthz", nil)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		req.Header.Set("Origin", origin)
+		resp, err := ts.Server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request from %s got %d, want 200", origin, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != want {
+			t.Fatalf("request from %s allowed origin %q, want %q", origin, got, want)
+		}
+	}
+}
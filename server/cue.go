@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/transport"
+)
+
+// cueAction is the event a cue goes out as.
+const cueAction = "cue"
+
+// maxCuePayload caps the parameters sent along with a cue.
+const maxCuePayload = 4 << 10
+
+// cueNames are the names a cue can have, e.g. "correct" or "round-2".
+var cueNames = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,32}$`)
+
+// cueRequest is a cue from the host. the payload is passed on to players
+// as is.
+type cueRequest struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func (c cueRequest) validate() error {
+	if !cueNames.MatchString(c.Name) {
+		return badRequest("name", "cue names are 1 to 32 letters, digits, dots, dashes or underscores")
+	}
+	if len(c.Payload) > maxCuePayload {
+		return badRequest("payload", "cue payload is over %d bytes", maxCuePayload)
+	}
+	return nil
+}
+
+// cueEvent is the data of a cue. At lets a player catching up on missed
+// events skip cues that are long over.
+type cueEvent struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+// HostCueHandler sends a named cue, e.g. "correct", "wrong" or "intro", to
+// every player so their devices can play a sound or animation in sync. the
+// server doesn't know or care what cues mean.
+func (srv *Server) HostCueHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req cueRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	cue := cueEvent{Name: req.Name, Payload: req.Payload, At: time.Now()}
+	srv.publish(transport.Message{GameID: id, Action: cueAction, Data: cue}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(cue)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"bzzz/internal/transport"
+)
+
+// a dashboard stream starts with the games it's watching, and is told
+// about each game its account creates after that
+const (
+	dashboardGames = "dashboard_games"
+	dashboardGame  = "dashboard_game"
+)
+
+// dashboardGamesEvent is the data of a dashboard_games event.
+type dashboardGamesEvent struct {
+	Games []string `json:"games"`
+}
+
+// addDashboardGame has the account's open dashboards watch a game it just
+// created.
+func (srv *Server) addDashboardGame(accountID, gameID, hostToken string) {
+	srv.dashboardsMu.Lock()
+	defer srv.dashboardsMu.Unlock()
+
+	for q := range srv.dashboards[accountID] {
+		if srv.manager.WatchGame(gameID, hostToken, q) {
+			q.Offer(transport.Message{GameID: gameID, Action: dashboardGame})
+		}
+	}
+}
+
+// HostDashboardHandler streams the events of every running game the calling
+// host account created, each tagged with its gameID, so an organizer
+// running several rooms at once can keep an eye on all of them from one
+// screen. it opens with a dashboard_games event listing the games, games
+// the account creates later are announced with a dashboard_game event as
+// they start. ?include=, ?events= and ?exclude= trim it down like a host
+// stream.
+func (srv *Server) HostDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	done := r.Context().Done()
+
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, http.StatusInternalServerError, "", "Streaming unsupported!")
+		return
+	}
+
+	a, ok := srv.currentAccount(w, r)
+	if !ok {
+		return
+	}
+	version, err := srv.eventVersion(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	q := srv.queues.NewQueue()
+	q.SetFilter(transport.ParseEventFilter(r.URL.Query()))
+
+	srv.dashboardsMu.Lock()
+	if srv.dashboards[a.ID] == nil {
+		srv.dashboards[a.ID] = map[*transport.Queue]bool{}
+	}
+	srv.dashboards[a.ID][q] = true
+	games := []string{}
+	for _, g := range a.Games {
+		if srv.manager.WatchGame(g.ID, g.HostToken, q) {
+			games = append(games, g.ID)
+		}
+	}
+	srv.dashboardsMu.Unlock()
+
+	defer func() {
+		srv.dashboardsMu.Lock()
+		delete(srv.dashboards[a.ID], q)
+		if len(srv.dashboards[a.ID]) == 0 {
+			delete(srv.dashboards, a.ID)
+		}
+		srv.dashboardsMu.Unlock()
+		srv.manager.UnwatchGames(q)
+		q.Close()
+	}()
+
+	flusher, _ := srv.startEventStream(w)
+
+	hb := srv.startHeartbeat(q, func() { q.Close() })
+	defer hb.halt()
+
+	write := func(msg transport.Message) error {
+		jsonBytes, err := json.Marshal(srv.versionedEvent(msg, version))
+		if err != nil {
+			return err
+		}
+		// seqs are per game, so they can't be handed back to resume from
+		return writeSSE(w, flusher, 0, msg.Action, jsonBytes)
+	}
+
+	if err := write(transport.Message{Action: dashboardGames, Data: dashboardGamesEvent{Games: games}}); err != nil {
+		return
+	}
+	hb.wrote()
+
+	for {
+		msg, ok := q.NextOr(done)
+		if !ok {
+			return
+		}
+		if msg.Action == heartbeatAction {
+			if err := writeHeartbeat(w, flusher); err != nil {
+				return
+			}
+			hb.wrote()
+			continue
+		}
+		if !q.Wants(msg.Action) {
+			continue
+		}
+		if err := write(msg); err != nil {
+			log.Println(err.Error())
+			return
+		}
+		hb.wrote()
+		if msg.Action == serverShutdown {
+			return
+		}
+	}
+}
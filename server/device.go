@@ -0,0 +1,307 @@
+package server
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+)
+
+// deviceIDPattern is what a device ID can look like, a MAC address or
+// serial number is typical.
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+// deviceIdleTimeout is how long a device connection can go without a line
+// before it's closed. devices PING to stay connected between buzzes.
+const deviceIdleTimeout = 2 * time.Minute
+
+// maxDeviceLine caps the length of a line from a device.
+const maxDeviceLine = 256
+
+// deviceWriteTimeout is how long a reply can take to reach a device.
+const deviceWriteTimeout = 5 * time.Second
+
+func (srv *Server) deviceConnected(deviceID string) bool {
+	srv.deviceConnsMu.Lock()
+	defer srv.deviceConnsMu.Unlock()
+	return srv.deviceConns[deviceID] > 0
+}
+
+// pairDeviceRequest pairs a device with a player.
+type pairDeviceRequest struct {
+	DeviceID string `json:"deviceID"`
+	PlayerID string `json:"playerID"`
+}
+
+func (p pairDeviceRequest) validate() error {
+	if !deviceIDPattern.MatchString(p.DeviceID) {
+		return badRequest("deviceID", "deviceID must be 1 to 64 letters, digits, '_', '.', ':' or '-'")
+	}
+	if p.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	return nil
+}
+
+// HostDevicesHandler lists the hardware buzzers paired in a game and
+// whether each is connected.
+func (srv *Server) HostDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	list, err := srv.manager.Devices(id)
+	if err != nil {
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+	for i := range list {
+		list[i].Connected = srv.deviceConnected(list[i].DeviceID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"devices": list}); err != nil {
+		log.Printf("failed to encode devices: %v", err)
+	}
+}
+
+// HostPairDeviceHandler pairs a hardware buzzer with a player, so the
+// device's buzzes count as theirs. the body is
+//
+//	{"deviceID": "24:6F:28:AA:01:02", "playerID": "..."}
+//
+// pairing a device again moves it to the new player. the player keeps
+// buzzing from their phone too.
+func (srv *Server) HostPairDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req pairDeviceRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	d, err := srv.manager.PairDevice(id, req.DeviceID, req.PlayerID)
+	switch err {
+	case nil:
+	case game.ErrDeviceTaken:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+	d.Connected = srv.deviceConnected(d.DeviceID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		log.Printf("failed to encode device: %v", err)
+	}
+}
+
+// HostUnpairDeviceHandler forgets a hardware buzzer's pairing. its buzzes
+// are turned away until it's paired again.
+func (srv *Server) HostUnpairDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if err := srv.manager.UnpairDevice(id, params["device"]); err != nil {
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listenDevices starts accepting hardware buzzer connections on
+// cfg.DeviceAddr, returning the listener to close on shutdown.
+func (srv *Server) listenDevices() (net.Listener, error) {
+	ln, err := net.Listen("tcp", srv.cfg.DeviceAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for devices: %v", err)
+	}
+	log.Printf("listening for devices on %s", ln.Addr())
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
+				return
+			}
+			go srv.serveDevice(conn)
+		}
+	}()
+	return ln, nil
+}
+
+// deviceSession is a device connection's state.
+type deviceSession struct {
+	srv *Server
+
+	conn net.Conn
+	// deviceID is what HELLO named the connection, "" before it
+	deviceID string
+	// hello is set once HELLO has been sent
+	hello bool
+}
+
+// serveDevice reads commands from a device until it hangs up or goes
+// quiet.
+func (srv *Server) serveDevice(conn net.Conn) {
+	defer conn.Close()
+	s := &deviceSession{srv: srv, conn: conn}
+	defer s.setDevice("")
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, maxDeviceLine), maxDeviceLine)
+	for {
+		conn.SetReadDeadline(time.Now().Add(deviceIdleTimeout))
+		if !scanner.Scan() {
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		reply := s.command(strings.ToUpper(fields[0]), fields[1:])
+		conn.SetWriteDeadline(time.Now().Add(deviceWriteTimeout))
+		if _, err := fmt.Fprintf(conn, "%s\n", reply); err != nil {
+			return
+		}
+	}
+}
+
+// setDevice moves the connection's count over to another device.
+func (s *deviceSession) setDevice(deviceID string) {
+	s.srv.deviceConnsMu.Lock()
+	defer s.srv.deviceConnsMu.Unlock()
+
+	if s.deviceID != "" {
+		if s.srv.deviceConns[s.deviceID]--; s.srv.deviceConns[s.deviceID] <= 0 {
+			delete(s.srv.deviceConns, s.deviceID)
+		}
+	}
+	s.deviceID = deviceID
+	if deviceID != "" {
+		s.srv.deviceConns[deviceID]++
+	}
+}
+
+// command carries out one command, returning the reply line.
+func (s *deviceSession) command(cmd string, args []string) string {
+	switch {
+	case cmd == "PING":
+		return "PONG"
+	case cmd == "HELLO":
+		if len(args) == 0 || !deviceIDPattern.MatchString(args[0]) {
+			return deviceError(codeBadRequest, "HELLO needs a device ID")
+		}
+		if s.srv.cfg.DeviceKey != "" && (len(args) < 2 || subtle.ConstantTimeCompare([]byte(args[1]), []byte(s.srv.cfg.DeviceKey)) != 1) {
+			return deviceError(codeBadCredentials, "wrong device key")
+		}
+		s.hello = true
+		s.setDevice(args[0])
+		return "OK " + args[0]
+	case s.srv.cfg.DeviceKey != "" && !s.hello:
+		return deviceError(codeCredentialsRequired, "send HELLO with the device key first")
+	case cmd == "BUZZ":
+		deviceID, nonce := s.deviceID, ""
+		if len(args) > 0 {
+			deviceID = args[0]
+		}
+		if len(args) > 1 {
+			nonce = args[1]
+		}
+		if deviceID == "" {
+			return deviceError(codeBadRequest, "BUZZ needs a device ID, or HELLO first")
+		}
+		return s.srv.deviceBuzz(s.conn, deviceID, nonce)
+	default:
+		return deviceError(codeBadRequest, fmt.Sprintf("unknown command %s", cmd))
+	}
+}
+
+// deviceBuzz buzzes in as the player a device is paired with.
+func (srv *Server) deviceBuzz(conn net.Conn, deviceID, nonce string) string {
+	gameID, playerID, ok := srv.manager.DevicePlayer(deviceID)
+	if !ok {
+		return deviceError(codeDeviceNotFound, game.ErrDeviceNotFound.Error())
+	}
+
+	// buzzIn answers over HTTP, a request stands in for the connection
+	r, _ := http.NewRequest(http.MethodPost, "/api/play/"+gameID+"/buzz", nil)
+	r.RemoteAddr = conn.RemoteAddr().String()
+	w := &replyWriter{header: http.Header{}}
+	req := buzzRequest{GameID: gameID, PlayerID: playerID, Nonce: nonce}
+	if err := req.validate(); err != nil {
+		writeRequestError(w, err)
+	} else if err := srv.checkPlayerIn(r, gameID, playerID); err != nil {
+		writeRequestError(w, err)
+	} else {
+		srv.buzzIn(w, r, req)
+	}
+	return deviceReply(w)
+}
+
+// deviceReply turns buzzIn's answer into a reply line.
+func deviceReply(w *replyWriter) string {
+	var body struct {
+		Result   string `json:"result"`
+		Position int    `json:"position"`
+		Code     string `json:"code"`
+		Message  string `json:"message"`
+	}
+	json.Unmarshal(w.body.Bytes(), &body)
+
+	if w.status >= 300 || body.Code != "" {
+		if body.Code == "" {
+			body.Code = statusCode(w.status)
+		}
+		return deviceError(body.Code, body.Message)
+	}
+	if body.Position > 0 {
+		return fmt.Sprintf("OK %s %d", body.Result, body.Position)
+	}
+	return "OK " + body.Result
+}
+
+// deviceError is an error reply line. the message is kept to one line.
+func deviceError(code, message string) string {
+	return strings.TrimSpace("ERR " + code + " " + strings.Join(strings.Fields(message), " "))
+}
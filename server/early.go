@@ -0,0 +1,19 @@
+package server
+
+import (
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// penalizeEarlyBuzz counts an early buzz, telling the host and, if it cost
+// the player points, everyone.
+func (srv *Server) penalizeEarlyBuzz(gameID, playerID string) {
+	e, err := srv.manager.EarlyBuzz(gameID, playerID)
+	if err != nil {
+		return
+	}
+	srv.publish(transport.Message{GameID: gameID, PlayerID: playerID, Action: game.EarlyBuzz, Data: e}, transport.ToHost)
+	if e.Penalty != nil {
+		srv.publish(srv.scoreMessage(gameID, []game.ScoreEntry{*e.Penalty}), transport.ToAll)
+	}
+}
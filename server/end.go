@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// end of game events. results goes to everyone, farewell to players only.
+const (
+	gameResults  = "results"
+	gameFarewell = "farewell"
+)
+
+// why a game ended, as given in results and farewell events
+const (
+	endedByHost     = "host"
+	endedByTimeUp   = "time_up"
+	endedByHostLost = "host_lost"
+)
+
+// resultsEvent is the data of results events, the final standings of a
+// game that has ended.
+type resultsEvent struct {
+	Reason    string             `json:"reason"`
+	StartedAt time.Time          `json:"startedAt"`
+	EndedAt   time.Time          `json:"endedAt"`
+	Standings []game.Standing    `json:"standings"`
+	Stats     []game.PlayerStats `json:"stats"`
+	// Winners are the IDs of the players tied on the most points, empty if
+	// nobody scored
+	Winners []string `json:"winners"`
+	// CleanupAt is when the game is torn down and its streams closed
+	CleanupAt time.Time `json:"cleanupAt"`
+}
+
+// farewellEvent is the data of farewell events.
+type farewellEvent struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// finishGame ends a game: everyone is sent the final results, players are
+// sent a farewell, and the game is torn down once cfg.EndLinger has passed.
+func (srv *Server) finishGame(gameID, reason string) (resultsEvent, error) {
+	endedAt, err := srv.manager.End(gameID)
+	if err != nil {
+		return resultsEvent{}, err
+	}
+
+	srv.orphansMu.Lock()
+	delete(srv.orphans, gameID)
+	srv.orphansMu.Unlock()
+
+	srv.recordLeagueStats(gameID)
+
+	stats, err := srv.manager.Stats(gameID)
+	if err != nil {
+		return resultsEvent{}, err
+	}
+	standings := srv.manager.Leaderboard(gameID)
+
+	results := resultsEvent{
+		Reason:    reason,
+		StartedAt: srv.manager.StartedAt(gameID),
+		EndedAt:   endedAt,
+		Standings: standings,
+		Stats:     stats,
+		Winners:   winners(standings),
+		CleanupAt: endedAt.Add(srv.cfg.EndLinger),
+	}
+	srv.publish(transport.Message{GameID: gameID, Action: gameResults, Data: results}, transport.ToAll)
+	srv.publish(transport.Message{GameID: gameID, Action: gameFarewell, Data: farewellEvent{
+		Reason:  reason,
+		Message: srv.tr(nil, gameID, "game_over"),
+	}}, transport.ToPlayers)
+
+	log.Printf("game %s ended (%s), tearing it down at %s", gameID, reason, results.CleanupAt.Format(time.RFC3339))
+	srv.scheduleTeardown(gameID)
+	return results, nil
+}
+
+// winners returns the IDs of the players tied on the most points.
+func winners(standings []game.Standing) []string {
+	ids := []string{}
+	top := 0
+	for _, s := range standings {
+		switch {
+		case s.Points <= 0 || s.Points < top:
+		case s.Points > top:
+			top, ids = s.Points, []string{s.PlayerID}
+		default:
+			ids = append(ids, s.PlayerID)
+		}
+	}
+	return ids
+}
+
+// scheduleTeardown tears down an ended game once its results have been up
+// for cfg.EndLinger. games restored after a restart that are already past
+// it are torn down straight away.
+func (srv *Server) scheduleTeardown(gameID string) {
+	endedAt, ok := srv.manager.EndedAt(gameID)
+	if !ok {
+		return
+	}
+
+	time.AfterFunc(time.Until(endedAt.Add(srv.cfg.EndLinger)), func() {
+		// the code may have gone to another game since, a rematch say
+		if at, ok := srv.manager.EndedAt(gameID); !ok || !at.Equal(endedAt) {
+			return
+		}
+		srv.endGame(gameID)
+	})
+}
+
+// HostEndHandler ends a game, sending everyone the final results and
+// players a farewell before it's torn down.
+func (srv *Server) HostEndHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	results, err := srv.finishGame(id, endedByHost)
+	switch err {
+	case nil:
+	case game.ErrGameEnded:
+		writeError(w, http.StatusConflict, codeGameEnded, srv.tr(r, id, "game_ended"))
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(results)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
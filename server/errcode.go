@@ -0,0 +1,267 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"bzzz/internal/game"
+)
+
+// error codes name what went wrong with a request, for clients to branch on
+// instead of matching messages, which can be localized. every error
+// response has one, see requestError.
+const (
+	// generic codes, for errors nothing more specific fits
+	codeBadRequest       = "BAD_REQUEST"
+	codeUnauthorized     = "UNAUTHORIZED"
+	codeForbidden        = "FORBIDDEN"
+	codeNotFound         = "NOT_FOUND"
+	codeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	codeConflict         = "CONFLICT"
+	codeUnprocessable    = "UNPROCESSABLE"
+	codeRateLimited      = "RATE_LIMITED"
+	codeInternal         = "INTERNAL_ERROR"
+	codeUpstream         = "UPSTREAM_ERROR"
+	codeUnavailable      = "UNAVAILABLE"
+
+	// games and players
+	codeGameNotFound      = "GAME_NOT_FOUND"
+	codePlayerNotFound    = "PLAYER_NOT_FOUND"
+	codeGameFull          = "GAME_FULL"
+	codeGameStarted       = "GAME_STARTED"
+	codeGamePaused        = "GAME_PAUSED"
+	codeGameEnded         = "GAME_ENDED"
+	codeGameNotPaused     = "GAME_NOT_PAUSED"
+	codeBadTransition     = "BAD_TRANSITION"
+	codeGameAlreadyPaused = "GAME_ALREADY_PAUSED"
+	codeHostAway          = "HOST_AWAY"
+	codeGameCodeTaken     = "GAME_CODE_TAKEN"
+	codeGameCodeRejected  = "GAME_CODE_REJECTED"
+	codeNoFreeGameCodes   = "NO_FREE_GAME_CODES"
+	codeBanned            = "BANNED"
+	codeNameRejected      = "NAME_REJECTED"
+	codeNameTooLong       = "NAME_TOO_LONG"
+	codeNameInvalid       = "NAME_INVALID"
+	codeNameTaken         = "NAME_TAKEN"
+	codeTextRejected      = "TEXT_REJECTED"
+	codeSeatTaken         = "SEAT_TAKEN"
+	codeBadPlayerToken    = "BAD_PLAYER_TOKEN"
+	codeSessionInvalid    = "SESSION_INVALID"
+	codeSessionExpired    = "SESSION_EXPIRED"
+	codeNoPendingEvent    = "NO_PENDING_EVENT"
+	codeStalePing         = "STALE_PING"
+	codeFloodWarning      = "FLOOD_WARNING"
+	codeFloodMuted        = "FLOOD_MUTED"
+	codeFloodBanned       = "FLOOD_BANNED"
+	codeQuotaExceeded     = "QUOTA_EXCEEDED"
+	codeAtCapacity        = "AT_CAPACITY"
+	codeNoCaptains        = "NO_CAPTAINS"
+	codeNotCaptain        = "NOT_CAPTAIN"
+	codeNotOnTeam         = "NOT_ON_TEAM"
+	codeNoTeamRequest     = "NO_TEAM_REQUEST"
+
+	// buzzing
+	codeBuzzersLocked  = "BUZZERS_LOCKED"
+	codeAlreadyBuzzed  = "ALREADY_BUZZED"
+	codeTeamLockedOut  = "TEAM_LOCKED_OUT"
+	codeNotYourTurn    = "NOT_YOUR_TURN"
+	codeLateBuzz       = "LATE_BUZZ"
+	codeBuzzCooldown   = "BUZZ_COOLDOWN"
+	codeDuplicateBuzz  = "DUPLICATE_BUZZ"
+	codeStaleBuzz      = "STALE_BUZZ"
+	codeBuzzerLocked   = "BUZZER_LOCKED"
+	codeEarlyBuzz      = "EARLY_BUZZ"
+	codeNoBuzzes       = "NO_BUZZES"
+	codeNotRoundRobin  = "NOT_ROUND_ROBIN"
+	codeNoSoundcheck   = "NO_SOUNDCHECK"
+	codeNoPractice     = "NO_PRACTICE"
+	codeDeviceNotFound = "DEVICE_NOT_FOUND"
+	codeDeviceTaken    = "DEVICE_TAKEN"
+
+	// questions, answers and scores
+	codeNotOnFloor        = "NOT_ON_FLOOR"
+	codeAlreadyAnswered   = "ALREADY_ANSWERED"
+	codeNotAnswered       = "NOT_ANSWERED"
+	codeNoQuestions       = "NO_QUESTIONS"
+	codeOutOfQuestions    = "OUT_OF_QUESTIONS"
+	codeBadQuestionsCSV   = "BAD_QUESTIONS_CSV"
+	codeNoActiveRound     = "NO_ACTIVE_ROUND"
+	codeNoTimer           = "NO_TIMER"
+	codeScoreNotFound     = "SCORE_NOT_FOUND"
+	codeNotCorrectable    = "NOT_CORRECTABLE"
+	codeUnknownScoreKind  = "UNKNOWN_SCORE_KIND"
+	codeChallengeNotFound = "CHALLENGE_NOT_FOUND"
+	codeChallengeResolved = "CHALLENGE_RESOLVED"
+	codeNotChallengeable  = "NOT_CHALLENGEABLE"
+	codeNoWagerRound      = "NO_WAGER_ROUND"
+	codeWagersClosed      = "WAGERS_CLOSED"
+	codeWagersOpen        = "WAGERS_OPEN"
+	codeWagerTooHigh      = "WAGER_TOO_HIGH"
+	codeNoWager           = "NO_WAGER"
+	codeWagerRevealed     = "WAGER_REVEALED"
+	codeNoAllPlay         = "NO_ALLPLAY"
+	codeAllPlayClosed     = "ALLPLAY_CLOSED"
+	codeAllPlayOpen       = "ALLPLAY_OPEN"
+	codeAllPlayRevealed   = "ALLPLAY_REVEALED"
+	codeNoCategories      = "NO_CATEGORIES"
+	codeNoBoard           = "NO_BOARD"
+	codeNotYourPick       = "NOT_YOUR_PICK"
+	codeCellNotFound      = "CELL_NOT_FOUND"
+	codeCellUsed          = "CELL_USED"
+	codeNothingToUndo     = "NOTHING_TO_UNDO"
+	codeUndoStale         = "UNDO_STALE"
+
+	// credentials
+	codeCredentialsRequired = "CREDENTIALS_REQUIRED"
+	codeBadCredentials      = "BAD_CREDENTIALS"
+	codeHostTokenRequired   = "HOST_TOKEN_REQUIRED"
+	codeBadHostToken        = "BAD_HOST_TOKEN"
+	codePlayerTokenRequired = "PLAYER_TOKEN_REQUIRED"
+	codeBadRecoveryPIN      = "BAD_RECOVERY_PIN"
+	codeRecoveryLockedOut   = "RECOVERY_LOCKED_OUT"
+	codeNoRecoveryPIN       = "NO_RECOVERY_PIN"
+	codeTransferNotAllowed  = "TRANSFER_NOT_ALLOWED"
+	codeAdminTokenRequired  = "ADMIN_TOKEN_REQUIRED"
+	codeAccountKeyRequired  = "ACCOUNT_KEY_REQUIRED"
+	codeBadAccountKey       = "BAD_ACCOUNT_KEY"
+	codeWrongTenant         = "WRONG_TENANT"
+	codeAccountKeyNotFound  = "ACCOUNT_KEY_NOT_FOUND"
+	codeLastAccountKey      = "LAST_ACCOUNT_KEY"
+	codeTooManyAccountKeys  = "TOO_MANY_ACCOUNT_KEYS"
+	codeCORSRejected        = "CORS_REJECTED"
+
+	// everything else
+	codeTemplateNotFound   = "TEMPLATE_NOT_FOUND"
+	codeHistoryNotFound    = "HISTORY_NOT_FOUND"
+	codeUnsupportedVersion = "UNSUPPORTED_VERSION"
+	codeStreamNotFound     = "STREAM_NOT_FOUND"
+
+	// request bodies
+	codeBodyTooLarge = "BODY_TOO_LARGE"
+	codeUnknownField = "UNKNOWN_FIELD"
+)
+
+// errorCodes are the codes of the errors handlers pass on as they are.
+var errorCodes = map[error]string{
+	game.ErrGameNotFound:       codeGameNotFound,
+	game.ErrPlayerNotFound:     codePlayerNotFound,
+	game.ErrCodeTaken:          codeGameCodeTaken,
+	game.ErrIDExhausted:        codeNoFreeGameCodes,
+	game.ErrIDSpaceFull:        codeNoFreeGameCodes,
+	game.ErrAtGameLimit:        codeAtCapacity,
+	game.ErrGameFull:           codeGameFull,
+	game.ErrGameStarted:        codeGameStarted,
+	game.ErrGamePaused:         codeGamePaused,
+	game.ErrGameEnded:          codeGameEnded,
+	game.ErrNotPaused:          codeGameNotPaused,
+	game.ErrAlreadyPaused:      codeGameAlreadyPaused,
+	game.ErrBanned:             codeBanned,
+	game.ErrNoCaptains:         codeNoCaptains,
+	game.ErrNotCaptain:         codeNotCaptain,
+	game.ErrNotOnTeam:          codeNotOnTeam,
+	game.ErrNoTeamRequest:      codeNoTeamRequest,
+	game.ErrNameTaken:          codeNameTaken,
+	errTextRejected:            codeTextRejected,
+	game.ErrSeatTaken:          codeSeatTaken,
+	errSessionInvalid:          codeSessionInvalid,
+	errSessionExpired:          codeSessionExpired,
+	game.ErrTeamLockedOut:      codeTeamLockedOut,
+	game.ErrNotYourTurn:        codeNotYourTurn,
+	game.ErrLateBuzz:           codeLateBuzz,
+	game.ErrBuzzCooldown:       codeBuzzCooldown,
+	game.ErrDuplicateBuzz:      codeDuplicateBuzz,
+	game.ErrBuzzersLocked:      codeBuzzersLocked,
+	game.ErrStaleBuzz:          codeStaleBuzz,
+	game.ErrBuzzerLocked:       codeBuzzerLocked,
+	game.ErrEarlyBuzz:          codeEarlyBuzz,
+	game.ErrNoSoundcheck:       codeNoSoundcheck,
+	game.ErrNoPractice:         codeNoPractice,
+	game.ErrDeviceNotFound:     codeDeviceNotFound,
+	game.ErrDeviceTaken:        codeDeviceTaken,
+	game.ErrNotOnFloor:         codeNotOnFloor,
+	game.ErrAlreadyAnswered:    codeAlreadyAnswered,
+	game.ErrNotAnswered:        codeNotAnswered,
+	game.ErrNoQuestions:        codeNoQuestions,
+	game.ErrOutOfQuestions:     codeOutOfQuestions,
+	game.ErrBadQuestionsCSV:    codeBadQuestionsCSV,
+	game.ErrNoActiveRound:      codeNoActiveRound,
+	game.ErrScoreNotFound:      codeScoreNotFound,
+	game.ErrNotCorrectable:     codeNotCorrectable,
+	game.ErrUnknownScoreKind:   codeUnknownScoreKind,
+	game.ErrNoWagerRound:       codeNoWagerRound,
+	game.ErrWagersClosed:       codeWagersClosed,
+	game.ErrWagersOpen:         codeWagersOpen,
+	game.ErrWagerTooHigh:       codeWagerTooHigh,
+	game.ErrNoWager:            codeNoWager,
+	game.ErrWagerRevealed:      codeWagerRevealed,
+	game.ErrNoAllPlay:          codeNoAllPlay,
+	game.ErrAllPlayClosed:      codeAllPlayClosed,
+	game.ErrAllPlayOpen:        codeAllPlayOpen,
+	game.ErrAllPlayRevealed:    codeAllPlayRevealed,
+	game.ErrNoCategories:       codeNoCategories,
+	game.ErrNoBoard:            codeNoBoard,
+	game.ErrNotYourPick:        codeNotYourPick,
+	game.ErrNoSuchCell:         codeCellNotFound,
+	game.ErrCellUsed:           codeCellUsed,
+	game.ErrNothingToUndo:      codeNothingToUndo,
+	game.ErrUndoStale:          codeUndoStale,
+	errNoCredentials:           codeCredentialsRequired,
+	game.ErrBadHostToken:       codeBadHostToken,
+	game.ErrBadRecoveryPIN:     codeBadRecoveryPIN,
+	game.ErrRecoveryLockedOut:  codeRecoveryLockedOut,
+	game.ErrNoRecoveryPIN:      codeNoRecoveryPIN,
+	game.ErrTransferNotAllowed: codeTransferNotAllowed,
+	errUnknownAccountKey:       codeBadAccountKey,
+	errLastAccountKey:          codeLastAccountKey,
+}
+
+// statusCodes are the generic codes for errors without one of their own.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          codeBadRequest,
+	http.StatusUnauthorized:        codeUnauthorized,
+	http.StatusForbidden:           codeForbidden,
+	http.StatusNotFound:            codeNotFound,
+	http.StatusMethodNotAllowed:    codeMethodNotAllowed,
+	http.StatusConflict:            codeConflict,
+	http.StatusUnprocessableEntity: codeUnprocessable,
+	http.StatusTooManyRequests:     codeRateLimited,
+	http.StatusInternalServerError: codeInternal,
+	http.StatusBadGateway:          codeUpstream,
+	http.StatusServiceUnavailable:  codeUnavailable,
+}
+
+// errorCode returns the code for err, "" if it has none of its own.
+func errorCode(err error) string {
+	if _, ok := err.(*game.TransitionError); ok {
+		return codeBadTransition
+	}
+	return errorCodes[err]
+}
+
+// statusCode returns the generic code for an HTTP status.
+func statusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return codeInternal
+	}
+	return codeBadRequest
+}
+
+// writeError answers a request with a structured error. code can be "" for
+// the status's generic one.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeRequestError(w, &requestError{status: status, Code: code, Message: message})
+}
+
+// writeErr answers a request with err, coded if it's one of errorCodes.
+func writeErr(w http.ResponseWriter, status int, err error) {
+	writeError(w, status, errorCode(err), err.Error())
+}
+
+// apiNotFound answers requests under /api/ that no route takes, including
+// ones for a route with the wrong method.
+func apiNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("no endpoint for %s %s", r.Method, r.URL.Path))
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"time"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// eventSchemaVersion is the latest version of the event envelope and the
+// typed event data below. it goes up when a field changes meaning or goes
+// away, adding a field doesn't bump it. older versions are still sent to
+// clients that ask for them, see eventVersion.
+const eventSchemaVersion = eventV2
+
+// helloEvent names the greeting a player's stream opens with, which isn't
+// a message so has no action of its own.
+const helloEvent = "hello"
+
+// event is how a message looks on the host and player streams. Type is
+// also the stream's SSE event field, so clients can listen for each kind
+// of event on its own.
+type event struct {
+	V          int         `json:"v"`
+	Type       string      `json:"type"`
+	Time       string      `json:"time"`
+	GameID     string      `json:"gameID"`
+	PlayerID   string      `json:"playerID"`
+	PlayerName string      `json:"playerName"`
+	Action     string      `json:"action"`
+	Seq        int         `json:"seq"`
+	Txn        int         `json:"txn"`
+	Critical   bool        `json:"critical"`
+	State      string      `json:"state,omitempty"`
+	Seat       int         `json:"seat,omitempty"`
+	Team       string      `json:"team,omitempty"`
+	Avatar     string      `json:"avatar,omitempty"`
+	Color      string      `json:"color,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// newEvent wraps a message in the v1 event envelope.
+func (srv *Server) newEvent(msg transport.Message) event {
+	p, _ := srv.manager.Player(msg.PlayerID)
+	return event{
+		V:          eventV1,
+		Type:       msg.Action,
+		Time:       time.Now().Local().String(),
+		GameID:     msg.GameID,
+		PlayerID:   msg.PlayerID,
+		PlayerName: p.Name,
+		Action:     msg.Action,
+		Seq:        msg.Seq,
+		Txn:        msg.Txn,
+		Critical:   criticalActions[msg.Action],
+		State:      msg.State,
+		Seat:       p.Seat,
+		Team:       p.Team,
+		Avatar:     p.Avatar,
+		Color:      p.Color,
+		Data:       msg.Data,
+	}
+}
+
+// buzzEvent is the data of a buzz, the buzz itself and the question's
+// buzz order after it.
+type buzzEvent struct {
+	Buzz  game.Buzz   `json:"buzz"`
+	Order []game.Buzz `json:"order"`
+}
+
+// scoreEvent is the data of a score, the entries just recorded and the
+// leaderboard after them.
+type scoreEvent struct {
+	Entries     []game.ScoreEntry `json:"entries"`
+	Leaderboard []game.Standing   `json:"leaderboard"`
+}
+
+// scoreCorrectionEvent is the data of a score_correction, sent when an
+// upheld challenge changes the scores.
+type scoreCorrectionEvent struct {
+	ChallengeID int               `json:"challengeID"`
+	Seq         int               `json:"seq"`
+	Corrections []scoreCorrection `json:"corrections"`
+	Entries     []game.ScoreEntry `json:"entries"`
+	Leaderboard []game.Standing   `json:"leaderboard"`
+}
+
+// scoreReconciledEvent is the data of a score_reconciled, sent when the
+// host corrects an earlier entry.
+type scoreReconciledEvent struct {
+	Correction  game.ScoreEntry `json:"correction"`
+	Original    game.ScoreEntry `json:"original"`
+	Leaderboard []game.Standing `json:"leaderboard"`
+}
+
+// summaryEvent is the data of the summary sent when a game's time is up.
+type summaryEvent struct {
+	StartedAt string   `json:"startedAt"`
+	EndedAt   string   `json:"endedAt"`
+	Players   []string `json:"players"`
+}
+
+// timerEvent is the data of the timer events. starts carry DurationMs,
+// ticks RemainingMs, and both say when the timer EndsAt.
+type timerEvent struct {
+	TimerID     int        `json:"timerID"`
+	DurationMs  int64      `json:"durationMs,omitempty"`
+	RemainingMs int64      `json:"remainingMs,omitempty"`
+	EndsAt      *time.Time `json:"endsAt,omitempty"`
+	AutoLock    bool       `json:"autoLock,omitempty"`
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/transport"
+)
+
+// unfilteredActions reach a stream whatever its filter, a client that
+// never hears them is left hanging.
+var unfilteredActions = map[string]bool{
+	serverShutdown: true,
+	playerKicked:   true,
+	"resync":       true,
+}
+
+// unfiltered reports whether an action reaches a stream whatever its
+// filter, which critical actions do too.
+func unfiltered(action string) bool {
+	return unfilteredActions[action] || criticalActions[action]
+}
+
+// maxFilterActions caps how many actions a filter can list.
+const maxFilterActions = 100
+
+// validateFilterSpec checks a filter a client sent.
+func validateFilterSpec(s transport.EventFilterSpec) error {
+	if len(s.Include) > maxFilterActions {
+		return badRequest("include", "include can list at most %d events", maxFilterActions)
+	}
+	if len(s.Exclude) > maxFilterActions {
+		return badRequest("exclude", "exclude can list at most %d events", maxFilterActions)
+	}
+	return nil
+}
+
+// playerEventsRequest changes the events a player's open stream is sent.
+type playerEventsRequest struct {
+	PlayerID string `json:"playerID"`
+	transport.EventFilterSpec
+}
+
+func (req playerEventsRequest) validate() error {
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	return validateFilterSpec(req.EventFilterSpec)
+}
+
+// hostEventsRequest changes the events a host stream is sent. ClientID is
+// the clientID the stream was opened with, or the ID in its host_joined
+// event if it wasn't opened with one.
+type hostEventsRequest struct {
+	ClientID string `json:"clientID"`
+	transport.EventFilterSpec
+}
+
+func (req hostEventsRequest) validate() error {
+	if req.ClientID == "" {
+		return badRequest("clientID", "clientID is required")
+	}
+	return validateFilterSpec(req.EventFilterSpec)
+}
+
+// PlayerEventsHandler changes which events a player's open stream is sent,
+// replacing the filter it was opened with, e.g. a scoreboard display
+// narrowing down to buzzes and scores once the game gets going.
+func (srv *Server) PlayerEventsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req playerEventsRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	q, ok := srv.manager.PlayerQueue(id, req.PlayerID)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeStreamNotFound, fmt.Sprintf("player [%s] has no stream open", req.PlayerID))
+		return
+	}
+	writeFilter(w, q, req.Filter())
+}
+
+// HostEventsHandler changes which events one of a game's host streams is
+// sent, like PlayerEventsHandler.
+func (srv *Server) HostEventsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req hostEventsRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+	q, ok := srv.manager.HostQueue(id, req.ClientID)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeStreamNotFound, fmt.Sprintf("no host stream [%s] open for game [%s]", req.ClientID, id))
+		return
+	}
+	writeFilter(w, q, req.Filter())
+}
+
+// writeFilter sets a stream's filter and answers with it.
+func writeFilter(w http.ResponseWriter, q *transport.Queue, f transport.EventFilter) {
+	q.SetFilter(f)
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(f.Spec())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
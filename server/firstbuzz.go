@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// lateBuzz is sent to the host when a buzz loses to the winner in a first
+// buzz wins game.
+const lateBuzz = "buzz_late"
+
+// rejectLateBuzz turns a late buzz away, telling the host by how much it
+// lost the first time the player tries.
+func (srv *Server) rejectLateBuzz(w http.ResponseWriter, r *http.Request, gameID string, b game.Buzz, first bool) {
+	if first {
+		srv.publish(transport.Message{GameID: gameID, PlayerID: b.PlayerID, Action: lateBuzz, Data: b}, transport.ToHost)
+		if b.Tie != 0 {
+			order := srv.manager.BuzzOrder(gameID)
+			if !b.Late {
+				order = append(order, b)
+			}
+			srv.announceTie(gameID, b, order)
+		}
+	}
+
+	writeBuzzResponse(w, http.StatusConflict, buzzResponse{Result: buzzLate, DeltaMs: b.DeltaMs, Error: srv.trErr(r, gameID, game.ErrLateBuzz)})
+}
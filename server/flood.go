@@ -0,0 +1,130 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// flood control is a single per-player budget shared by every player
+// initiated feature, so switching from buzz spam to some other action doesn't
+// buy a fresh allowance.
+const (
+	floodBurst       = 10.0
+	floodRefillRate  = 2.0 // tokens per second
+	floodMuteFor     = 10 * time.Second
+	floodBanFor      = 2 * time.Minute
+	floodStrikeDecay = 5 * time.Minute
+	floodStrikesMute = 2
+	floodStrikesBan  = 4
+)
+
+// floodCosts is how much of the shared budget each feature consumes.
+// unknown features cost 1.
+var floodCosts = map[string]float64{
+	"buzz":     1,
+	"chat":     1,
+	"reaction": 0.5,
+	"answer":   1,
+	"wager":    1,
+	"allplay":  1,
+	"pick":     1,
+}
+
+type floodPenalty int
+
+const (
+	floodNone floodPenalty = iota
+	floodWarn
+	floodMute
+	floodBan
+)
+
+type floodState struct {
+	tokens     float64
+	refilledAt time.Time
+	strikes    int
+	struckAt   time.Time
+	blockedTil time.Time
+	penalty    floodPenalty
+}
+
+// dropFlood forgets a player's flood budget.
+func (srv *Server) dropFlood(playerID string) {
+	srv.floodMu.Lock()
+	defer srv.floodMu.Unlock()
+
+	delete(srv.floods, playerID)
+}
+
+// checkFlood charges a player for using a feature. It returns the penalty in
+// effect and how long until the player may act again.
+func (srv *Server) checkFlood(playerID string, feature string) (floodPenalty, time.Duration) {
+	srv.floodMu.Lock()
+	defer srv.floodMu.Unlock()
+
+	now := time.Now()
+	f, ok := srv.floods[playerID]
+	if !ok {
+		f = &floodState{tokens: floodBurst, refilledAt: now}
+		srv.floods[playerID] = f
+	}
+
+	if now.Before(f.blockedTil) {
+		return f.penalty, f.blockedTil.Sub(now)
+	}
+
+	// forgive old strikes once a player has behaved for a while
+	if f.strikes > 0 && now.Sub(f.struckAt) > floodStrikeDecay {
+		f.strikes = 0
+	}
+
+	f.tokens = math.Min(floodBurst, f.tokens+now.Sub(f.refilledAt).Seconds()*floodRefillRate)
+	f.refilledAt = now
+
+	cost, ok := floodCosts[feature]
+	if !ok {
+		cost = 1
+	}
+	if f.tokens >= cost {
+		f.tokens -= cost
+		return floodNone, 0
+	}
+
+	// out of budget, escalate warn -> mute -> temp ban
+	f.strikes++
+	f.struckAt = now
+	switch {
+	case f.strikes >= floodStrikesBan:
+		f.penalty = floodBan
+		f.blockedTil = now.Add(floodBanFor)
+	case f.strikes >= floodStrikesMute:
+		f.penalty = floodMute
+		f.blockedTil = now.Add(floodMuteFor)
+	default:
+		f.penalty = floodWarn
+		return floodWarn, time.Duration((cost - f.tokens) / floodRefillRate * float64(time.Second))
+	}
+	return f.penalty, f.blockedTil.Sub(now)
+}
+
+// allowFlood checks the flood budget for a player action and writes an error
+// response when the action is refused.
+func (srv *Server) allowFlood(w http.ResponseWriter, r *http.Request, gameID, playerID string, feature string) bool {
+	penalty, wait := srv.checkFlood(playerID, feature)
+	if penalty == floodNone {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	switch penalty {
+	case floodBan:
+		writeError(w, http.StatusForbidden, codeFloodBanned, srv.tr(r, gameID, "flood_ban", wait.Round(time.Second)))
+	case floodMute:
+		writeError(w, http.StatusTooManyRequests, codeFloodMuted, srv.tr(r, gameID, "flood_mute", wait.Round(time.Second)))
+	default:
+		writeError(w, http.StatusTooManyRequests, codeFloodWarning, srv.tr(r, gameID, "flood_warn"))
+	}
+	return false
+}
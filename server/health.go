@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// readyTimeout is how long the readiness checks get before a check that
+// hasn't answered counts as failed.
+const readyTimeout = 2 * time.Second
+
+// readyChecks are what has to be working for the server to take games,
+// the store games are saved to, the broker events go out through and the
+// loop relaying them back to streams.
+func (srv *Server) readyChecks() map[string]func() error {
+	return map[string]func() error{
+		"store":  func() error { return srv.store.Ping() },
+		"broker": func() error { return srv.broker.Ping() },
+		"relay": func() error {
+			srv.relayingMu.Lock()
+			defer srv.relayingMu.Unlock()
+
+			if !srv.relaying {
+				return errors.New("not relaying events")
+			}
+			return nil
+		},
+	}
+}
+
+func (srv *Server) setRelaying(running bool) {
+	srv.relayingMu.Lock()
+	defer srv.relayingMu.Unlock()
+
+	srv.relaying = running
+}
+
+// readyResponse is the outcome of the readiness checks.
+type readyResponse struct {
+	Ready bool `json:"ready"`
+	// Checks maps each check to "ok" or why it failed
+	Checks map[string]string `json:"checks"`
+	// Hubs is how many games have events running, for information only
+	Hubs int `json:"hubs"`
+}
+
+// HealthzHandler answers liveness probes. it only shows the process is
+// serving requests, so an orchestrator restarts it when it isn't. probes
+// come every few seconds, so they aren't logged.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// ReadyzHandler answers readiness probes, with 200 if every check in
+// readyChecks passes and 503 if any fails, so an orchestrator holds
+// traffic back from a replica that can't reach its store or broker.
+func (srv *Server) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	type result struct {
+		name string
+		err  error
+	}
+	checks := srv.readyChecks()
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check func() error) {
+			results <- result{name, check()}
+		}(name, check)
+	}
+
+	resp := readyResponse{Ready: true, Checks: map[string]string{}, Hubs: srv.hubCount()}
+	timeout := time.After(readyTimeout)
+	for len(resp.Checks) < len(checks) {
+		select {
+		case res := <-results:
+			resp.Checks[res.name] = "ok"
+			if res.err != nil {
+				resp.Checks[res.name] = res.err.Error()
+				resp.Ready = false
+			}
+		case <-timeout:
+			for name := range checks {
+				if _, ok := resp.Checks[name]; !ok {
+					resp.Checks[name] = "timed out"
+				}
+			}
+			resp.Ready = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
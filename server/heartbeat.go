@@ -0,0 +1,78 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// heartbeatAction marks a heartbeat queued on a stream. it's written as an
+// SSE comment, which EventSource clients ignore, rather than as an event.
+const heartbeatAction = "heartbeat"
+
+// missedHeartbeats is how many heartbeat intervals a stream can go without
+// writing anything before its client is considered dead, e.g. a half open
+// connection the write is stuck on.
+const missedHeartbeats = 3
+
+// heartbeat keeps an idle stream busy enough that proxies don't drop it, and
+// notices when writes to the client stop getting through.
+type heartbeat struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// startHeartbeat queues a heartbeat on q every heartbeat interval until
+// stopped. dead is called once, in its own goroutine, if the stream hasn't
+// written for missedHeartbeats intervals. with the interval set to zero
+// there are no heartbeats.
+func (srv *Server) startHeartbeat(q *transport.Queue, dead func()) *heartbeat {
+	hb := &heartbeat{lastWrite: time.Now(), stop: make(chan struct{})}
+	if srv.cfg.HeartbeatInterval <= 0 {
+		return hb
+	}
+
+	go func() {
+		ticker := time.NewTicker(srv.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hb.stop:
+				return
+			case <-ticker.C:
+			}
+
+			hb.mu.Lock()
+			idle := time.Since(hb.lastWrite)
+			hb.mu.Unlock()
+			if idle > missedHeartbeats*srv.cfg.HeartbeatInterval {
+				dead()
+				return
+			}
+			q.Offer(transport.Message{Action: heartbeatAction})
+		}
+	}()
+	return hb
+}
+
+// wrote records a successful write to the stream.
+func (hb *heartbeat) wrote() {
+	hb.mu.Lock()
+	hb.lastWrite = time.Now()
+	hb.mu.Unlock()
+}
+
+// sinceWrite returns how long ago the stream last wrote.
+func (hb *heartbeat) sinceWrite() time.Duration {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	return time.Since(hb.lastWrite)
+}
+
+func (hb *heartbeat) halt() {
+	hb.stopOnce.Do(func() { close(hb.stop) })
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+	"bzzz/internal/transport"
+)
+
+// historyActions are the actions kept in a game's history, the ones an
+// organizer needs to audit a ruling or tally a season afterwards.
+var historyActions = map[string]bool{
+	"joined":             true,
+	game.PlayerLeft:      true,
+	game.PlayerRejoined:  true,
+	game.PlayerStalled:   true,
+	playerKicked:         true,
+	"buzz":               true,
+	lateBuzz:             true,
+	gameCreated:          true,
+	buzzAlmost:           true,
+	game.EarlyBuzz:       true,
+	"lock":               true,
+	"unlock":             true,
+	targetLocked:         true,
+	targetUnlocked:       true,
+	"reset":              true,
+	questionReset:        true,
+	questionShown:        true,
+	answerSubmitted:      true,
+	answerVerdict:        true,
+	"score":              true,
+	"score_correction":   true,
+	"score_reconciled":   true,
+	hostUndo:             true,
+	stateChanged:         true,
+	captainChanged:       true,
+	teamMemberAccepted:   true,
+	teamMemberRemoved:    true,
+	timeoutRequested:     true,
+	"challenge_filed":    true,
+	"challenge_resolved": true,
+	"round_start":        true,
+	"round_end":          true,
+	"time_up":            true,
+	gamePaused:           true,
+	gameResumed:          true,
+	gameStats:            true,
+	"summary":            true,
+	"game_expired":       true,
+	"disconnect":         true,
+	hostTransferred:      true,
+	playerWaiting:        true,
+	playerAdmitted:       true,
+	wagerOpen:            true,
+	wagerSubmitted:       true,
+	wagerClosed:          true,
+	wagerReveal:          true,
+	allPlayOpen:          true,
+	allPlayAnswer:        true,
+	allPlayClosed:        true,
+	allPlayReveal:        true,
+	hostMarker:           true,
+	buzzTie:              true,
+}
+
+// recordHistory appends the messages of a freshly sequenced envelope that
+// belong in their game's history. it runs on the replica that sequenced
+// the envelope, so each entry is only stored once.
+func (srv *Server) recordHistory(env transport.Envelope) {
+	if srv.cfg.HistoryRetention <= 0 {
+		return
+	}
+
+	byGame := map[string][]store.HistoryEntry{}
+	for _, msg := range env.Msgs {
+		if !historyActions[msg.Action] || msg.GameID == "" {
+			continue
+		}
+
+		e := store.HistoryEntry{Seq: msg.Seq, Txn: msg.Txn, At: env.SequencedAt, Action: msg.Action, PlayerID: msg.PlayerID}
+		e.MonotonicMs = game.SinceStart(srv.manager.StartedAt(msg.GameID), env.SequencedAt)
+		if p, ok := srv.manager.Player(msg.PlayerID); ok {
+			e.PlayerName = p.Name
+			e.Team = p.Team
+		}
+		if msg.Data != nil {
+			data, err := json.Marshal(msg.Data)
+			if err != nil {
+				log.Printf("failed to encode %s for game %s history: %v", msg.Action, msg.GameID, err)
+			}
+			e.Data = data
+		}
+		byGame[msg.GameID] = append(byGame[msg.GameID], e)
+	}
+
+	for gameID, entries := range byGame {
+		hostToken, _ := srv.manager.HostToken(gameID)
+		if err := srv.store.AppendHistory(gameID, hostToken, entries); err != nil {
+			log.Printf("failed to record history for game %s: %v", gameID, err)
+		}
+	}
+}
+
+// loadHistory fetches a game's history when the request carries its host
+// token, writing the error to w when it can't. a game that's still running
+// is checked against its current hosts, one that has ended against the host
+// token kept with its history. the host account that created the game can
+// send its account key instead of the host token.
+func (srv *Server) loadHistory(w http.ResponseWriter, r *http.Request) (store.GameHistory, bool) {
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return store.GameHistory{}, false
+	}
+
+	token := hostTokenFrom(r)
+	if token == "" {
+		if a, err := srv.accountFor(r); err == nil {
+			token, _ = a.HostToken(id)
+		}
+	}
+	if token == "" {
+		writeError(w, http.StatusUnauthorized, codeHostTokenRequired, "host token required")
+		return store.GameHistory{}, false
+	}
+
+	h, found, err := srv.store.LoadHistory(id)
+	if err != nil {
+		log.Printf("failed to load history for game %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "", "failed to load history")
+		return store.GameHistory{}, false
+	}
+	running := srv.manager.Exists(id)
+	if !found && !running {
+		writeError(w, http.StatusNotFound, codeHistoryNotFound, fmt.Sprintf("no history for game id [%s]", id))
+		return store.GameHistory{}, false
+	}
+
+	if running && !srv.manager.IsHost(id, token) || !running && subtle.ConstantTimeCompare([]byte(h.HostToken), []byte(token)) != 1 {
+		log.Printf("rejected bad host token for game %s history", id)
+		writeError(w, http.StatusForbidden, codeBadHostToken, "host token doesn't match this game")
+		return store.GameHistory{}, false
+	}
+	if h.Entries == nil {
+		h.Entries = []store.HistoryEntry{}
+	}
+	return h, true
+}
+
+// HostHistoryHandler returns a game's history, joins, buzzes with the time
+// they were sequenced, questions, answers, rulings and scores, oldest
+// first. it keeps working after the game has ended, for as long as the
+// history is retained.
+func (srv *Server) HostHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	h, ok := srv.loadHistory(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h)
+}
+
+// HostHistoryExportHandler returns a game's history as a download, JSON by
+// default or CSV with ?format=csv. the CSV has one row per entry with the
+// entry's data left as JSON in the last column. entries carry the wall
+// clock time and the monotonic milliseconds since the game started, for
+// syncing video of the game against them.
+func (srv *Server) HostHistoryExportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("unknown format %q, json or csv", format))
+		return
+	}
+
+	h, ok := srv.loadHistory(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"bzzz-%s-history.csv\"", id))
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"seq", "txn", "at", "monotonicMs", "action", "playerID", "playerName", "team", "data"})
+		for _, e := range h.Entries {
+			cw.Write([]string{
+				strconv.Itoa(e.Seq),
+				strconv.Itoa(e.Txn),
+				e.At.UTC().Format(time.RFC3339Nano),
+				strconv.FormatFloat(e.MonotonicMs, 'f', 3, 64),
+				e.Action,
+				e.PlayerID,
+				e.PlayerName,
+				e.Team,
+				string(e.Data),
+			})
+		}
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"bzzz-%s-history.json\"", id))
+	json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// hostTokenFrom pulls the host token off a request, from an
+// "Authorization: Bearer" or X-Host-Token header, or the hostToken query
+// param for EventSource streams, which can't set headers.
+func hostTokenFrom(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token := r.Header.Get("X-Host-Token"); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("hostToken")
+}
+
+// requireHost only lets requests carrying the game's host token through to
+// h.
+func (srv *Server) requireHost(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if !srv.manager.Exists(id) {
+			writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+			return
+		}
+
+		token := hostTokenFrom(r)
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, codeHostTokenRequired, "host token required")
+			return
+		}
+		if !srv.manager.IsHost(id, token) {
+			log.Printf("rejected bad host token for game %s", id)
+			writeError(w, http.StatusForbidden, codeBadHostToken, "host token doesn't match this game")
+			return
+		}
+		h(w, r)
+	}
+}
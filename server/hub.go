@@ -0,0 +1,170 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// hubIdleTimeout is how long a hub with nothing to do waits before
+// stopping. a game that's still going gets a new one with its next event.
+const hubIdleTimeout = 5 * time.Minute
+
+// hubWork is an envelope for a hub, either published on this replica and
+// waiting to be sequenced or relayed off the broker and waiting to be
+// delivered.
+type hubWork struct {
+	env     transport.Envelope
+	relayed bool
+}
+
+// hub runs a single game's event pipeline on its own goroutine, sequencing
+// what's published to the game and delivering what comes back off the
+// broker to its streams. a game slow to deliver to, say with a big crowd
+// or a stalled store, only holds up its own events. ending the game stops
+// its hub.
+type hub struct {
+	srv *Server
+
+	gameID string
+	// wake is poked whenever work is queued
+	wake chan struct{}
+
+	mu   sync.Mutex
+	work []hubWork
+	// stopped is set once the hub has left hubs and takes no more work
+	stopped bool
+}
+
+// hubFor returns the hub for a game, starting it on first use.
+func (srv *Server) hubFor(gameID string) *hub {
+	srv.hubsMu.Lock()
+	defer srv.hubsMu.Unlock()
+
+	h, ok := srv.hubs[gameID]
+	if !ok {
+		h = &hub{srv: srv, gameID: gameID, wake: make(chan struct{}, 1)}
+		srv.hubs[gameID] = h
+		go h.run()
+	}
+	return h
+}
+
+// enqueue hands work to a game's hub, starting a new one if the last has
+// just stopped.
+func (srv *Server) enqueue(gameID string, w hubWork) {
+	for !srv.hubFor(gameID).push(w) {
+	}
+}
+
+// push queues work for the hub, returning false if it has stopped.
+func (h *hub) push(w hubWork) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		return false
+	}
+	h.work = append(h.work, w)
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// next takes the oldest queued work off the hub.
+func (h *hub) next() (hubWork, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.work) == 0 {
+		return hubWork{}, false
+	}
+	w := h.work[0]
+	h.work[0] = hubWork{}
+	h.work = h.work[1:]
+	return w, true
+}
+
+// stop takes the hub out of hubs, unless work has been queued since it
+// last looked. it reports whether the hub stopped.
+func (h *hub) stop() bool {
+	h.srv.hubsMu.Lock()
+	defer h.srv.hubsMu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.work) > 0 {
+		return false
+	}
+	h.stopped = true
+	if h.srv.hubs[h.gameID] == h {
+		delete(h.srv.hubs, h.gameID)
+	}
+	return true
+}
+
+// run works through the hub's queue until the game ends or the hub has sat
+// idle for hubIdleTimeout.
+func (h *hub) run() {
+	idle := time.NewTimer(hubIdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-h.wake:
+		case <-idle.C:
+			if h.stop() {
+				return
+			}
+		}
+
+		ended := false
+		for {
+			w, ok := h.next()
+			if !ok {
+				break
+			}
+			if !w.relayed {
+				h.srv.sequence(w.env)
+				continue
+			}
+			h.srv.relayEnvelope(w.env)
+			for _, msg := range w.env.Msgs {
+				ended = ended || msg.Action == "disconnect" && msg.PlayerID == ""
+			}
+		}
+		if ended && h.stop() {
+			log.Printf("game %s ended, stopping its hub", h.gameID)
+			return
+		}
+
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(hubIdleTimeout)
+	}
+}
+
+// hubCount returns how many games have a running hub.
+func (srv *Server) hubCount() int {
+	srv.hubsMu.Lock()
+	defer srv.hubsMu.Unlock()
+
+	return len(srv.hubs)
+}
+
+// hubRunning reports whether a game's hub is still running.
+func (srv *Server) hubRunning(gameID string) bool {
+	srv.hubsMu.Lock()
+	defer srv.hubsMu.Unlock()
+
+	_, ok := srv.hubs[gameID]
+	return ok
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bzzz/internal/game"
+)
+
+const defaultLocale = "en"
+
+// catalogs holds the player facing strings the server generates, keyed by
+// locale and then message key. values are fmt format strings.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"game_not_found":   "game id [%s] not found",
+		"player_not_found": "player [%s] not found in game [%s]",
+		"bad_ack":          "failed to decode ack",
+		"no_pending_event": "no pending event with seq [%d]",
+		"flood_warn":       "slow down! further flooding will get you muted",
+		"flood_mute":       "muted for flooding, try again in %s",
+		"flood_ban":        "temporarily banned for flooding, try again in %s",
+		"default_name":     "Player %d",
+		"name_rejected":    "that name isn't allowed, please pick another",
+		"name_too_long":    "names can be at most %d characters",
+		"name_invalid":     "names can't have %q in them",
+		"name_taken":       "someone is already called %s, please pick another name",
+		"banned":           "you have been banned from this game",
+		"game_full":        "this game is full",
+		"host_away":        "game is paused until the host returns",
+		"game_paused":      "game is paused",
+		"buzzers_locked":   "buzzers are locked",
+		"already_buzzed":   "already buzzed for this question",
+		"team_locked_out":  "your team already buzzed for this question",
+		"not_your_turn":    "it's not your turn",
+		"late_buzz":        "another player already won this question",
+		"stale_buzz":       "that question has been reset",
+		"buzzer_locked":    "the host has locked your buzzer",
+		"early_buzz":       "the buzzers aren't armed yet, wait for the host",
+		"buzz_cooldown":    "buzzing again too soon",
+		"not_on_floor":     "only the buzzed in player can answer",
+		"already_answered": "answer already submitted",
+		"no_wager_round":   "no wager round has been opened",
+		"wagers_closed":    "wagers are closed",
+		"wager_too_high":   "wager can be at most %d",
+		"no_allplay":       "no all-play round has been opened",
+		"allplay_closed":   "all-play answers are closed",
+		"not_your_pick":    "it's not your pick",
+		"cell_used":        "that question has already been picked",
+		"no_captains":      "this game doesn't have team captains",
+		"not_captain":      "only your team's captain can do that",
+		"not_on_team":      "that player isn't on the team",
+		"no_team_request":  "that player hasn't asked to join your team",
+		"kicked":           "you have been removed from this game",
+		"game_ended":       "this game has ended",
+		"game_over":        "thanks for playing!",
+	},
+	"es": {
+		"game_not_found":   "no se encontró el juego [%s]",
+		"player_not_found": "el jugador [%s] no está en el juego [%s]",
+		"bad_ack":          "no se pudo leer la confirmación",
+		"no_pending_event": "no hay ningún evento pendiente con seq [%d]",
+		"flood_warn":       "¡más despacio! si sigues así serás silenciado",
+		"flood_mute":       "silenciado por exceso de mensajes, inténtalo de nuevo en %s",
+		"flood_ban":        "bloqueado temporalmente por exceso de mensajes, inténtalo de nuevo en %s",
+		"default_name":     "Jugador %d",
+		"name_rejected":    "ese nombre no está permitido, elige otro",
+		"name_too_long":    "los nombres pueden tener como máximo %d caracteres",
+		"name_invalid":     "los nombres no pueden contener %q",
+		"name_taken":       "ya hay alguien llamado %s, elige otro nombre",
+		"banned":           "te han expulsado de este juego",
+		"game_full":        "este juego está lleno",
+		"host_away":        "el juego está en pausa hasta que vuelva el anfitrión",
+		"game_paused":      "el juego está en pausa",
+		"buzzers_locked":   "los pulsadores están bloqueados",
+		"already_buzzed":   "ya has pulsado en esta pregunta",
+		"team_locked_out":  "tu equipo ya ha pulsado en esta pregunta",
+		"not_your_turn":    "no es tu turno",
+		"late_buzz":        "otro jugador ya ganó esta pregunta",
+		"stale_buzz":       "esa pregunta ya se ha reiniciado",
+		"buzzer_locked":    "el presentador ha bloqueado tu pulsador",
+		"early_buzz":       "los pulsadores aún no están activados, espera al presentador",
+		"buzz_cooldown":    "espera un poco antes de volver a pulsar",
+		"not_on_floor":     "solo puede responder el jugador que pulsó",
+		"already_answered": "ya has enviado una respuesta",
+		"no_wager_round":   "no hay ninguna ronda de apuestas abierta",
+		"wagers_closed":    "las apuestas están cerradas",
+		"wager_too_high":   "puedes apostar como máximo %d",
+		"no_allplay":       "no hay ninguna ronda para todos abierta",
+		"allplay_closed":   "ya no se aceptan respuestas",
+		"not_your_pick":    "no te toca elegir",
+		"cell_used":        "esa pregunta ya se ha elegido",
+		"no_captains":      "este juego no tiene capitanes de equipo",
+		"not_captain":      "solo el capitán de tu equipo puede hacer eso",
+		"not_on_team":      "ese jugador no está en el equipo",
+		"no_team_request":  "ese jugador no ha pedido unirse a tu equipo",
+		"kicked":           "te han sacado de este juego",
+		"game_ended":       "este juego ha terminado",
+		"game_over":        "¡gracias por jugar!",
+	},
+	"de": {
+		"game_not_found":   "Spiel [%s] nicht gefunden",
+		"player_not_found": "Spieler [%s] ist nicht im Spiel [%s]",
+		"bad_ack":          "Bestätigung konnte nicht gelesen werden",
+		"no_pending_event": "kein ausstehendes Ereignis mit seq [%d]",
+		"flood_warn":       "Langsamer! Wer weiter spammt, wird stummgeschaltet",
+		"flood_mute":       "wegen Spam stummgeschaltet, erneut versuchen in %s",
+		"flood_ban":        "wegen Spam vorübergehend gesperrt, erneut versuchen in %s",
+		"default_name":     "Spieler %d",
+		"name_rejected":    "dieser Name ist nicht erlaubt, bitte wähle einen anderen",
+		"name_too_long":    "Namen dürfen höchstens %d Zeichen lang sein",
+		"name_invalid":     "Namen dürfen kein %q enthalten",
+		"name_taken":       "%s ist schon vergeben, bitte wähle einen anderen Namen",
+		"banned":           "du wurdest aus diesem Spiel ausgeschlossen",
+		"game_full":        "dieses Spiel ist voll",
+		"host_away":        "das Spiel ist pausiert, bis der Host zurück ist",
+		"game_paused":      "das Spiel ist pausiert",
+		"buzzers_locked":   "die Buzzer sind gesperrt",
+		"already_buzzed":   "du hast bei dieser Frage schon gebuzzert",
+		"team_locked_out":  "dein Team hat bei dieser Frage schon gebuzzert",
+		"not_your_turn":    "du bist nicht an der Reihe",
+		"late_buzz":        "ein anderer Spieler hat diese Frage schon gewonnen",
+		"stale_buzz":       "diese Frage wurde schon zurückgesetzt",
+		"buzzer_locked":    "der Moderator hat deinen Buzzer gesperrt",
+		"early_buzz":       "die Buzzer sind noch nicht scharf, warte auf den Moderator",
+		"buzz_cooldown":    "warte kurz, bevor du wieder buzzerst",
+		"not_on_floor":     "nur wer gebuzzert hat, darf antworten",
+		"already_answered": "Antwort schon abgegeben",
+		"no_wager_round":   "es läuft keine Einsatzrunde",
+		"wagers_closed":    "die Einsätze sind geschlossen",
+		"wager_too_high":   "du kannst höchstens %d setzen",
+		"no_allplay":       "es läuft keine Runde für alle",
+		"allplay_closed":   "es werden keine Antworten mehr angenommen",
+		"not_your_pick":    "du darfst gerade nicht wählen",
+		"cell_used":        "diese Frage wurde schon gewählt",
+		"no_captains":      "dieses Spiel hat keine Teamkapitäne",
+		"not_captain":      "das darf nur der Kapitän deines Teams",
+		"not_on_team":      "dieser Spieler ist nicht im Team",
+		"no_team_request":  "dieser Spieler hat nicht gefragt, ob er in dein Team darf",
+		"kicked":           "du wurdest aus diesem Spiel entfernt",
+		"game_ended":       "dieses Spiel ist vorbei",
+		"game_over":        "danke fürs Mitspielen!",
+	},
+}
+
+// errorKeys are the catalog keys of the errors players can run into, so
+// they're told in their own language.
+var errorKeys = map[error]string{
+	game.ErrGamePaused:      "game_paused",
+	game.ErrGameEnded:       "game_ended",
+	game.ErrTeamLockedOut:   "team_locked_out",
+	game.ErrNotYourTurn:     "not_your_turn",
+	game.ErrLateBuzz:        "late_buzz",
+	game.ErrStaleBuzz:       "stale_buzz",
+	game.ErrBuzzerLocked:    "buzzer_locked",
+	game.ErrEarlyBuzz:       "early_buzz",
+	game.ErrBuzzersLocked:   "buzzers_locked",
+	game.ErrBuzzCooldown:    "buzz_cooldown",
+	game.ErrNotOnFloor:      "not_on_floor",
+	game.ErrAlreadyAnswered: "already_answered",
+	game.ErrNoWagerRound:    "no_wager_round",
+	game.ErrWagersClosed:    "wagers_closed",
+	game.ErrNoAllPlay:       "no_allplay",
+	game.ErrAllPlayClosed:   "allplay_closed",
+	game.ErrNotYourPick:     "not_your_pick",
+	game.ErrCellUsed:        "cell_used",
+	game.ErrNoCaptains:      "no_captains",
+	game.ErrNotCaptain:      "not_captain",
+	game.ErrNotOnTeam:       "not_on_team",
+	game.ErrNoTeamRequest:   "no_team_request",
+}
+
+// supportedLocale returns the catalog locale for a language tag such as
+// "de-AT", or "" if there is none.
+func supportedLocale(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	return ""
+}
+
+// acceptLanguage picks the most preferred supported locale from an
+// Accept-Language header.
+func acceptLanguage(header string) string {
+	type pref struct {
+		locale string
+		q      float64
+	}
+
+	prefs := []pref{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		locale := supportedLocale(fields[0])
+		if locale == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{locale, q})
+		}
+	}
+
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	if len(prefs) == 0 {
+		return ""
+	}
+	return prefs[0].locale
+}
+
+// localeFor picks the locale for a response: the request's Accept-Language
+// when we support it, otherwise the game's locale, otherwise English.
+func (srv *Server) localeFor(r *http.Request, gameID string) string {
+	if r != nil {
+		if locale := acceptLanguage(r.Header.Get("Accept-Language")); locale != "" {
+			return locale
+		}
+	}
+	if opts, ok := srv.manager.Options(gameID); ok && opts.Locale != "" {
+		return opts.Locale
+	}
+	return defaultLocale
+}
+
+// localeOrDefault returns locale, or English when it's unset.
+func localeOrDefault(locale string) string {
+	if locale == "" {
+		return defaultLocale
+	}
+	return locale
+}
+
+// localize formats the message for key in the given locale, falling back to
+// English for anything missing.
+func localize(locale, key string, args ...interface{}) string {
+	format, ok := catalogs[locale][key]
+	if !ok {
+		format, ok = catalogs[defaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// tr localizes a message for the player making the request.
+func (srv *Server) tr(r *http.Request, gameID string, key string, args ...interface{}) string {
+	return localize(srv.localeFor(r, gameID), key, args...)
+}
+
+// trErr localizes an error for the player making the request, leaving
+// errors without a catalog key as they are.
+func (srv *Server) trErr(r *http.Request, gameID string, err error) string {
+	if key, ok := errorKeys[err]; ok {
+		return srv.tr(r, gameID, key)
+	}
+	return err.Error()
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+)
+
+// customCodePattern is what a host can ask for as their own game code, after
+// it has been upper cased.
+var customCodePattern = regexp.MustCompile(`^[A-Z0-9]{4,16}$`)
+
+// customGameCode checks a game code a host asked for and returns it upper
+// cased. the code still has to be reserved before it's theirs.
+func customGameCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !customCodePattern.MatchString(code) {
+		return "", badRequest("code", "game code must be 4 to 16 letters and digits")
+	}
+	return code, nil
+}
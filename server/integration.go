@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+)
+
+// joinURL is where players are sent to join a game. the join URL setting
+// is a template like "https://play.example.com/{locale}/join/{code}", where
+// {locale} is the request's language or else the game's, without it
+// players are pointed at the join endpoint of the server that took the
+// request.
+func (srv *Server) joinURL(r *http.Request, gameCode string) string {
+	if tmpl := srv.cfg.JoinURL; tmpl != "" {
+		return strings.NewReplacer("{code}", gameCode, "{locale}", srv.localeFor(r, gameCode)).Replace(tmpl)
+	}
+	return fmt.Sprintf("%s/api/play/%s/join", requestBaseURL(r), gameCode)
+}
+
+// requestBaseURL is the scheme and host the request came in on, as the
+// client saw them.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+// integrationGameRequest is a game created by an integration, optionally
+// with its questions.
+type integrationGameRequest struct {
+	store.GameRequest
+	Questions []game.Question `json:"questions"`
+}
+
+// IntegrationCreateHandler lets another server create a game, load its
+// questions and get back everything needed to run it in one call. unlike
+// POST /api/host it always needs a tenant API key. the host token returned
+// lasts as long as the game.
+func (srv *Server) IntegrationCreateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	t, ok := srv.tenantFor(r)
+	if !ok || t.ID == "" {
+		writeError(w, http.StatusUnauthorized, codeCredentialsRequired, "tenant credentials are required")
+		return
+	}
+
+	var req integrationGameRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := game.ValidateQuestions(req.Questions); err != nil {
+		writeErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !srv.checkQuota(w, t, true) {
+		return
+	}
+
+	opts := srv.newGameOptions(t.ID, req.GameRequest)
+	gameCode, err := srv.startGame(r, opts, req.GameRequest)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := srv.manager.LoadQuestions(gameCode, req.Questions); err != nil {
+		writeErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	hostToken, _ := srv.manager.HostToken(gameCode)
+	resp := map[string]interface{}{
+		"gameCode":  gameCode,
+		"joinURL":   srv.joinURL(r, gameCode),
+		"hostToken": hostToken,
+		"questions": len(req.Questions),
+	}
+	if opts.MaxDuration > 0 {
+		resp["endsAt"] = srv.manager.StartedAt(gameCode).Add(opts.MaxDuration).Format(time.RFC3339)
+	}
+	srv.addWebhookSecret(resp, gameCode)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+)
+
+// joinGame adds a new player to a game, naming them if they didn't pick a
+// name. names and avatars that don't pass moderation fail with
+// errTextRejected, and malformed ones or names already taken in the game
+// fail with a requestError, suggesting a free variant of a taken name.
+func (srv *Server) joinGame(r *http.Request, gameID, name, team, avatar string) (game.Player, error) {
+	name, err := srv.playerName(r, gameID, name)
+	if err != nil {
+		return game.Player{}, err
+	}
+	avatar, err = playerAvatar(avatar)
+	if err != nil {
+		return game.Player{}, err
+	}
+	if name == "" {
+		name = srv.manager.AvailableName(gameID, srv.tr(r, gameID, "default_name", srv.manager.PlayerCount(gameID)+1))
+	}
+	team = teamName(team)
+	if err := srv.moderate(r, gameID, moderateName, name); err != nil {
+		return game.Player{}, err
+	}
+	if err := srv.moderate(r, gameID, moderateTeam, team); err != nil {
+		return game.Player{}, err
+	}
+	if err := srv.moderate(r, gameID, moderateAvatar, avatar); err != nil {
+		return game.Player{}, err
+	}
+	p, err := srv.manager.Join(gameID, name, team, avatar)
+	if err == game.ErrNameTaken {
+		return game.Player{}, srv.nameTakenError(r, gameID, name)
+	}
+	return p, err
+}
+
+// joinRequest is who a player joining a game wants to be.
+type joinRequest struct {
+	Name   string `json:"name"`
+	Team   string `json:"team"`
+	Avatar string `json:"avatar"`
+}
+
+// JoinHandler creates a player in a game without opening their stream. the
+// returned token is passed to /api/play/{id} to connect, and again to
+// reconnect as the same player. the session token does the same but
+// expires, see newSessionToken.
+func (srv *Server) JoinHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req joinRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, "", "game_not_found", id))
+		return
+	}
+	if !srv.checkGameQuota(w, id) {
+		return
+	}
+
+	p, err := srv.joinGame(r, id, req.Name, req.Team, req.Avatar)
+	if err == errTextRejected {
+		writeError(w, http.StatusUnprocessableEntity, codeNameRejected, srv.tr(r, id, "name_rejected"))
+		return
+	}
+	if err == game.ErrBanned {
+		writeError(w, http.StatusForbidden, codeBanned, srv.tr(r, id, "banned"))
+		return
+	}
+	if err == game.ErrGameEnded {
+		writeError(w, http.StatusConflict, codeGameEnded, srv.tr(r, id, "game_ended"))
+		return
+	}
+	if err == game.ErrGameFull {
+		writeError(w, http.StatusConflict, codeGameFull, srv.tr(r, id, "game_full"))
+		return
+	}
+	if re, ok := err.(*requestError); ok {
+		writeRequestError(w, re)
+		return
+	}
+	if err == game.ErrWaiting {
+		// the player's token opens their stream, which is held until a
+		// slot opens up
+		srv.announceWaiting(id, p)
+		position, _, _ := srv.manager.Waiting(id, p.PlayerID)
+		w.WriteHeader(http.StatusAccepted)
+		err = json.NewEncoder(w).Encode(map[string]interface{}{
+			"gameID":     id,
+			"playerID":   p.PlayerID,
+			"playerName": p.Name,
+			"team":       p.Team,
+			"avatar":     p.Avatar,
+			"color":      p.Color,
+			"token":      p.Token,
+			"waiting":    true,
+			"position":   position,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		}
+		return
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"gameID":     id,
+		"playerID":   p.PlayerID,
+		"playerName": p.Name,
+		"team":       p.Team,
+		"avatar":     p.Avatar,
+		"color":      p.Color,
+		"token":      p.Token,
+		"session":    srv.newSessionToken(id, p.PlayerID),
+	}
+	// a team with a captain has to accept the player first
+	if team, _ := srv.manager.TeamRequest(id, p.PlayerID); team != "" {
+		resp["requestedTeam"] = team
+		srv.announceTeamRequest(id, p)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const jwksCacheFor = time.Hour
+
+// jwtClockSkew is how much leeway is given when checking exp, nbf and iat.
+const jwtClockSkew = time.Minute
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// parseJWT verifies an RS256 signed JWT and returns its claims. keyFor looks
+// up the verification key for the token's kid.
+func parseJWT(token string, keyFor func(kid string) (*rsa.PublicKey, error)) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("bad token header: %v", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm [%s]", header.Alg)
+	}
+
+	key, err := keyFor(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("bad token signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("invalid token signature")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("bad token claims: %v", err)
+	}
+
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(jwtClockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	if iat, ok := claims["iat"].(float64); ok && now.Add(jwtClockSkew).Before(time.Unix(int64(iat), 0)) {
+		return nil, errors.New("token issued in the future")
+	}
+
+	return claims, nil
+}
+
+// signJWT creates an RS256 signed JWT with the given claims.
+func signJWT(claims map[string]interface{}, key *rsa.PrivateKey, kid string) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "RS256", Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// jwtAudience reports whether the aud claim contains want. aud may be a
+// single string or a list.
+func jwtAudience(claims map[string]interface{}, want string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == want
+	case []interface{}:
+		for _, a := range aud {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jwksRefetchEvery is how often a JWKS can be fetched again for a kid it
+// didn't have. tokens naming kids nobody issued can't make the server fetch
+// any more often than that.
+const jwksRefetchEvery = time.Minute
+
+type jwksEntry struct {
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	// triedAt is when the keys were last fetched or tried to be, err what
+	// went wrong if it failed. fetching is closed when the fetch in flight
+	// finishes, nil when there isn't one
+	triedAt  time.Time
+	err      error
+	fetching chan struct{}
+}
+
+// jwksKey returns the RSA key with the given kid from a remote JWKS. Keys are
+// cached and refetched when stale or when an unknown kid shows up, which is
+// how providers roll their keys, but no more often than jwksRefetchEvery. a
+// kid missing from the last fetch is a miss until then, and a stale key is
+// used until a fetch succeeds. fetches happen outside jwksMu, one per url at
+// a time, with everyone else needing that url waiting on it.
+func (srv *Server) jwksKey(url, kid string) (*rsa.PublicKey, error) {
+	srv.jwksMu.Lock()
+	for {
+		entry, ok := srv.jwksCache[url]
+		if !ok {
+			entry = &jwksEntry{}
+			srv.jwksCache[url] = entry
+		}
+		key, known := entry.keys[kid]
+		switch {
+		case known && time.Since(entry.fetchedAt) < jwksCacheFor:
+			srv.jwksMu.Unlock()
+			return key, nil
+		case entry.fetching != nil:
+			wait := entry.fetching
+			srv.jwksMu.Unlock()
+			<-wait
+			srv.jwksMu.Lock()
+			continue
+		case time.Since(entry.triedAt) < jwksRefetchEvery:
+			err := entry.err
+			srv.jwksMu.Unlock()
+			if known {
+				return key, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("no key [%s] in JWKS", kid)
+		}
+
+		done := make(chan struct{})
+		entry.fetching, entry.triedAt = done, time.Now()
+		srv.jwksMu.Unlock()
+
+		keys, err := fetchJWKS(url)
+
+		srv.jwksMu.Lock()
+		entry.fetching, entry.err = nil, err
+		if err == nil {
+			entry.keys, entry.fetchedAt = keys, time.Now()
+		}
+		close(done)
+	}
+}
+
+func fetchJWKS(url string) (map[string]*rsa.PublicKey, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS returned %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+// validClaims are claims parseJWT accepts, for tests to spoil.
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": "https://issuer.example",
+		"aud": "bzzz",
+		"sub": "host-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// signHS256 signs claims with HMAC-SHA256 keyed by secret, the way an
+// attacker holding only the public key would try to.
+func signHS256(t *testing.T, claims map[string]interface{}, secret []byte, kid string) string {
+	t.Helper()
+
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT", Kid: kid})
+	if err != nil {
+		t.Fatalf("failed to encode header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to encode claims: %v", err)
+	}
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signing))
+	return signing + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseJWT(t *testing.T) {
+	key := newRSAKey(t)
+	keyFor := func(kid string) (*rsa.PublicKey, error) { return &key.PublicKey, nil }
+
+	sign := func(claims map[string]interface{}) string {
+		token, err := signJWT(claims, key, "k1")
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	if _, err := parseJWT(sign(validClaims()), keyFor); err != nil {
+		t.Fatalf("valid token got %v", err)
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to encode public key: %v", err)
+	}
+	noExp := validClaims()
+	delete(noExp, "exp")
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	notYet := validClaims()
+	notYet["nbf"] = time.Now().Add(time.Hour).Unix()
+	valid := sign(validClaims())
+	parts := strings.Split(valid, ".")
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	sig[0] ^= 0xff
+	otherKey, err := signJWT(validClaims(), newRSAKey(t), "k1")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+	}{
+		{"alg none", strings.Join([]string{base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)), parts[1], ""}, ".")},
+		{"HS256 keyed by the public key", signHS256(t, validClaims(), pub, "k1")},
+		{"no exp", sign(noExp)},
+		{"expired", sign(expired)},
+		{"not yet valid", sign(notYet)},
+		{"bad signature", parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(sig)},
+		{"claims swapped under a signature", parts[0] + "." + strings.Split(sign(expired), ".")[1] + "." + parts[2]},
+		{"signed by another key", otherKey},
+	} {
+		if _, err := parseJWT(tc.token, keyFor); err == nil {
+			t.Errorf("%s accepted", tc.name)
+		}
+	}
+}
+
+func TestJWTAudience(t *testing.T) {
+	for _, tc := range []struct {
+		aud  interface{}
+		want bool
+	}{
+		{"bzzz", true},
+		{"other", false},
+		{[]interface{}{"other", "bzzz"}, true},
+		{[]interface{}{"other"}, false},
+		{nil, false},
+	} {
+		claims := validClaims()
+		claims["aud"] = tc.aud
+		if got := jwtAudience(claims, "bzzz"); got != tc.want {
+			t.Errorf("aud %v got %v, want %v", tc.aud, got, tc.want)
+		}
+	}
+}
+
+// jwksServer serves the public halves of keys as a JWKS, counting fetches.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: map[string]*rsa.PrivateKey{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.fetches++
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, key := range s.keys {
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) add(kid string, key *rsa.PrivateKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = key
+}
+
+func (s *jwksServer) fetched() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+// a kid the cached JWKS doesn't have refetches it, but no more often than
+// jwksRefetchEvery
+func TestJWKSUnknownKid(t *testing.T) {
+	jwks := newJWKSServer(t)
+	jwks.add("k1", newRSAKey(t))
+	srv := &Server{jwksCache: map[string]*jwksEntry{}}
+
+	if _, err := srv.jwksKey(jwks.URL, "k1"); err != nil {
+		t.Fatalf("failed to get k1: %v", err)
+	}
+	if _, err := srv.jwksKey(jwks.URL, "k1"); err != nil {
+		t.Fatalf("failed to get k1 again: %v", err)
+	}
+	if n := jwks.fetched(); n != 1 {
+		t.Fatalf("fetched the JWKS %d times for a known kid, want 1", n)
+	}
+
+	// the provider rolls a new key in, but the last fetch was just now
+	jwks.add("k2", newRSAKey(t))
+	if _, err := srv.jwksKey(jwks.URL, "k2"); err == nil {
+		t.Fatal("got k2 without refetching")
+	}
+	for i := 0; i < 5; i++ {
+		srv.jwksKey(jwks.URL, "forged")
+	}
+	if n := jwks.fetched(); n != 1 {
+		t.Fatalf("fetched the JWKS %d times within jwksRefetchEvery, want 1", n)
+	}
+
+	srv.jwksMu.Lock()
+	srv.jwksCache[jwks.URL].triedAt = time.Now().Add(-jwksRefetchEvery)
+	srv.jwksMu.Unlock()
+	key, err := srv.jwksKey(jwks.URL, "k2")
+	if err != nil {
+		t.Fatalf("failed to get k2 once refetching was allowed: %v", err)
+	}
+	if key.N.Cmp(jwks.keys["k2"].N) != 0 {
+		t.Fatal("got the wrong key for k2")
+	}
+	if n := jwks.fetched(); n != 2 {
+		t.Fatalf("fetched the JWKS %d times, want 2", n)
+	}
+	if _, err := srv.jwksKey(jwks.URL, "k1"); err != nil {
+		t.Fatalf("failed to get k1 after the refetch: %v", err)
+	}
+}
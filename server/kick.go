@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// playerKicked tells everyone a player was removed by the host. the kicked
+// player's stream ends once it has been written.
+const playerKicked = "kicked"
+
+// kickRequest names the player to remove, and whether to keep them out.
+type kickRequest struct {
+	PlayerID string `json:"playerID"`
+	Ban      bool   `json:"ban"`
+}
+
+// HostKickHandler removes a player from a game. the body is
+// {"playerID": "...", "ban": true}, ban keeping them from rejoining under the
+// same name or token.
+func (srv *Server) HostKickHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req kickRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	p, q, err := srv.manager.Kick(id, req.PlayerID, req.Ban)
+	switch err {
+	case nil:
+	case game.ErrGameNotFound:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	default:
+		writeError(w, http.StatusNotFound, codePlayerNotFound, fmt.Sprintf("player [%s] not found in game [%s]", req.PlayerID, id))
+		return
+	}
+	srv.dropOutbox(p.PlayerID)
+	srv.dropFlood(p.PlayerID)
+
+	// the player is told why in the game's language, not the host's
+	notice := srv.tr(nil, id, "kicked")
+	if req.Ban {
+		notice = srv.tr(nil, id, "banned")
+	}
+	msg := transport.Message{
+		GameID:   id,
+		PlayerID: p.PlayerID,
+		Action:   playerKicked,
+		Data:     map[string]interface{}{"playerName": p.Name, "banned": req.Ban, "message": notice},
+	}
+	// the player is no longer a recipient, so tell their stream directly
+	if q != nil {
+		q.Offer(msg)
+	}
+	srv.publish(msg, transport.ToAll)
+	srv.admitWaiting(id)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"playerID":   p.PlayerID,
+		"playerName": p.Name,
+		"banned":     req.Ban,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/transport"
+)
+
+// pingAction is sent to players in latency compensated games every ping
+// interval. the player answers with a pong carrying its PingID, and the
+// time in between is their round trip time.
+const pingAction = "ping"
+
+// how many times the ping stream answers, unless ?count= asks for fewer,
+// and how far apart
+const (
+	defaultPingCount   = 5
+	maxPingCount       = 30
+	pingStreamInterval = time.Second
+)
+
+// pingStreamEvent names the answers on the ping stream
+const pingStreamEvent = "pong"
+
+// rttSmoothing is how much weight a new round trip sample gets against the
+// ones before it, so one slow pong doesn't swing a player's compensation.
+const rttSmoothing = 0.3
+
+// pingEvent is the data of a ping.
+type pingEvent struct {
+	PingID int64     `json:"pingID"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+// pongRequest answers a ping.
+type pongRequest struct {
+	PlayerID string `json:"playerID"`
+	PingID   int64  `json:"pingID"`
+}
+
+func (p pongRequest) validate() error {
+	if p.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if p.PingID <= 0 {
+		return badRequest("pingID", "pingID is required")
+	}
+	return nil
+}
+
+// pongResponse tells a player their smoothed round trip time.
+type pongResponse struct {
+	RttMs float64 `json:"rttMs"`
+}
+
+// serverPing answers a client's ping. the client works out its latency to
+// the server from how long the answer took, and its clock's offset from
+// ServerTime.
+type serverPing struct {
+	ServerTime time.Time `json:"serverTime"`
+	// ClientTime is the ?t= the client sent, passed back as it is so it
+	// can time the round trip without keeping track of its pings
+	ClientTime string `json:"clientTime,omitempty"`
+	// N counts the answers on the ping stream, from 1
+	N int `json:"n,omitempty"`
+}
+
+// playerLatency is what's known about a player's round trip time.
+type playerLatency struct {
+	// pingID and sentAt are the ping waiting on a pong, only the latest
+	// one counts
+	pingID int64
+	sentAt time.Time
+	rtt    time.Duration
+}
+
+// startPings pings a player every ping interval, while their game has
+// latency compensation on, until the stream's heartbeat is halted. pings
+// aren't sequenced, they describe the stream rather than the game.
+func (srv *Server) startPings(gameID, playerID string, q *transport.Queue, hb *heartbeat) {
+	if srv.cfg.PingInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(srv.cfg.PingInterval)
+		defer ticker.Stop()
+		defer srv.forgetLatency(playerID)
+
+		var pingID int64
+		for {
+			select {
+			case <-hb.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if opts, ok := srv.manager.Options(gameID); !ok || !opts.LatencyCompensation {
+				continue
+			}
+
+			pingID++
+			now := time.Now()
+			srv.latenciesMu.Lock()
+			l, ok := srv.latencies[playerID]
+			if !ok {
+				l = &playerLatency{}
+				srv.latencies[playerID] = l
+			}
+			l.pingID, l.sentAt = pingID, now
+			srv.latenciesMu.Unlock()
+
+			q.Offer(transport.Message{GameID: gameID, PlayerID: playerID, Action: pingAction, Data: pingEvent{PingID: pingID, SentAt: now}})
+		}
+	}()
+}
+
+// recordPong takes a player's answer to a ping as a round trip sample. a
+// pong for anything but the latest ping is ignored.
+func (srv *Server) recordPong(playerID string, pingID int64) (time.Duration, bool) {
+	now := time.Now()
+
+	srv.latenciesMu.Lock()
+	defer srv.latenciesMu.Unlock()
+
+	l, ok := srv.latencies[playerID]
+	if !ok || l.pingID != pingID || l.sentAt.IsZero() {
+		return 0, false
+	}
+	sample := now.Sub(l.sentAt)
+	l.sentAt = time.Time{}
+	if l.rtt == 0 {
+		l.rtt = sample
+	} else {
+		l.rtt = time.Duration(rttSmoothing*float64(sample) + (1-rttSmoothing)*float64(l.rtt))
+	}
+	return l.rtt, true
+}
+
+// measuredRTT returns a player's smoothed round trip time, false before
+// their first pong.
+func (srv *Server) measuredRTT(playerID string) (time.Duration, bool) {
+	srv.latenciesMu.Lock()
+	defer srv.latenciesMu.Unlock()
+
+	l, ok := srv.latencies[playerID]
+	if !ok || l.rtt == 0 {
+		return 0, false
+	}
+	return l.rtt, true
+}
+
+// latencyCompensation is how much earlier a player's buzz is taken to
+// have been pressed than it arrived, half their round trip time, capped so
+// a player can't buy an advantage by answering pings slowly.
+func (srv *Server) latencyCompensation(playerID string) time.Duration {
+	rtt, ok := srv.measuredRTT(playerID)
+	if !ok {
+		return 0
+	}
+	comp := rtt / 2
+	if comp > srv.cfg.MaxLatencyCompensation {
+		comp = srv.cfg.MaxLatencyCompensation
+	}
+	return comp
+}
+
+func (srv *Server) forgetLatency(playerID string) {
+	srv.latenciesMu.Lock()
+	delete(srv.latencies, playerID)
+	srv.latenciesMu.Unlock()
+}
+
+// PongHandler takes a player's answer to a ping. players on a WebSocket
+// can send a pong command up the socket instead.
+func (srv *Server) PongHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req pongRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	srv.pong(w, req)
+}
+
+// pong records a checked pong and answers it on w.
+func (srv *Server) pong(w http.ResponseWriter, req pongRequest) {
+	rtt, ok := srv.recordPong(req.PlayerID, req.PingID)
+	if !ok {
+		writeRequestError(w, &requestError{status: http.StatusConflict, Code: codeStalePing, Message: "not the latest ping", Field: "pingID"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(pongResponse{RttMs: float64(rtt) / float64(time.Millisecond)})
+	if err != nil {
+		log.Printf("failed to encode pong response: %v", err)
+	}
+}
+
+// PingHandler answers a client measuring its latency to the server, with
+// the server's time and the ?t= it sent. asked for text/event-stream, as an
+// EventSource does, it answers ?count= times a second apart on an event
+// stream instead, so the client can measure the path its events take,
+// proxies and all.
+func (srv *Server) PingHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+	clientTime := r.URL.Query().Get("t")
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		err := json.NewEncoder(w).Encode(serverPing{ServerTime: time.Now(), ClientTime: clientTime})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		}
+		return
+	}
+
+	count := defaultPingCount
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPingCount {
+			writeRequestError(w, badRequest("count", "count must be between 1 and %d", maxPingCount))
+			return
+		}
+		count = n
+	}
+
+	flusher, ok := srv.startEventStream(w)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "", "Streaming unsupported!")
+		return
+	}
+	ticker := time.NewTicker(pingStreamInterval)
+	defer ticker.Stop()
+	for n := 1; ; n++ {
+		jsonBytes, err := json.Marshal(serverPing{ServerTime: time.Now(), ClientTime: clientTime, N: n})
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+		if err := writeSSE(w, flusher, 0, pingStreamEvent, jsonBytes); err != nil || n == count {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
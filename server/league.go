@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"bzzz/internal/store"
+)
+
+// maxRoundTypeLength caps the round types hosts label rounds with.
+const maxRoundTypeLength = 32
+
+// defaultRoundType is what buzzes outside any typed round count towards.
+const defaultRoundType = "standard"
+
+// roundType tidies up a round type given by a host.
+func roundType(raw string) string {
+	t := strings.ToLower(strings.Join(strings.Fields(raw), " "))
+	if r := []rune(t); len(r) > maxRoundTypeLength {
+		t = string(r[:maxRoundTypeLength])
+	}
+	return t
+}
+
+// tallyGame works out what a finished game adds to its tenant's league
+// stats. the team with the most points wins, a tie at the top has no
+// winner.
+func (srv *Server) tallyGame(gameID string) store.LeagueStats {
+	stats := store.NewLeagueStats()
+
+	teamOf := map[string]string{}
+	for _, p := range srv.manager.Players(gameID) {
+		if p.Team != "" {
+			teamOf[p.PlayerID] = p.Team
+		}
+	}
+
+	points := map[string]int{}
+	for _, s := range srv.manager.Leaderboard(gameID) {
+		if t, ok := teamOf[s.PlayerID]; ok {
+			points[t] += s.Points
+		}
+	}
+	for _, t := range teamOf {
+		if _, ok := points[t]; !ok {
+			points[t] = 0
+		}
+	}
+
+	winner, best, tied := "", 0, false
+	for t, p := range points {
+		stats.Teams[t] = store.TeamTally{Games: 1}
+		switch {
+		case winner == "" || p > best:
+			winner, best, tied = t, p, false
+		case p == best:
+			tied = true
+		}
+	}
+	if winner != "" && !tied {
+		stats.Teams[winner] = store.TeamTally{Games: 1, Wins: 1}
+	}
+
+	rounds := srv.manager.Rounds(gameID)
+	for _, b := range srv.manager.Reactions(gameID) {
+		kind := defaultRoundType
+		if b.Round > 0 && b.Round <= len(rounds) && rounds[b.Round-1].Type != "" {
+			kind = rounds[b.Round-1].Type
+		}
+		r := stats.Reactions[kind]
+		r.Buzzes++
+		r.TotalMs += b.ReactionMs
+		stats.Reactions[kind] = r
+	}
+	return stats
+}
+
+// recordLeagueStats adds a game that's ending to its tenant's league stats.
+func (srv *Server) recordLeagueStats(gameID string) {
+	opts, ok := srv.manager.Options(gameID)
+	if !ok || len(srv.tenants) == 0 {
+		return
+	}
+	if err := srv.store.AddLeagueStats(opts.Tenant, srv.tallyGame(gameID)); err != nil {
+		log.Printf("failed to record league stats for game %s: %v", gameID, err)
+	}
+}
+
+// openMetricsLabel escapes a label value for the OpenMetrics text format.
+func openMetricsLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// writeOpenMetrics writes league stats in the OpenMetrics text format.
+func writeOpenMetrics(w http.ResponseWriter, teams []store.TeamReport, reactions []store.ReactionReport) {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+
+	var b strings.Builder
+	b.WriteString("# TYPE bzzz_team_games counter\n# HELP bzzz_team_games Finished games a team played in.\n")
+	for _, t := range teams {
+		fmt.Fprintf(&b, "bzzz_team_games_total{team=\"%s\"} %d\n", openMetricsLabel(t.Team), t.Games)
+	}
+	b.WriteString("# TYPE bzzz_team_wins counter\n# HELP bzzz_team_wins Finished games a team won outright.\n")
+	for _, t := range teams {
+		fmt.Fprintf(&b, "bzzz_team_wins_total{team=\"%s\"} %d\n", openMetricsLabel(t.Team), t.Wins)
+	}
+	b.WriteString("# TYPE bzzz_team_win_rate gauge\n# HELP bzzz_team_win_rate Share of its games a team won.\n")
+	for _, t := range teams {
+		fmt.Fprintf(&b, "bzzz_team_win_rate{team=\"%s\"} %g\n", openMetricsLabel(t.Team), t.WinRate)
+	}
+	b.WriteString("# TYPE bzzz_reaction_buzzes counter\n# HELP bzzz_reaction_buzzes Buzzes timed from the buzzers being armed.\n")
+	for _, r := range reactions {
+		fmt.Fprintf(&b, "bzzz_reaction_buzzes_total{round_type=\"%s\"} %d\n", openMetricsLabel(r.RoundType), r.Buzzes)
+	}
+	b.WriteString("# TYPE bzzz_reaction_time_milliseconds gauge\n# HELP bzzz_reaction_time_milliseconds Average reaction time.\n# UNIT bzzz_reaction_time_milliseconds milliseconds\n")
+	for _, r := range reactions {
+		fmt.Fprintf(&b, "bzzz_reaction_time_milliseconds{round_type=\"%s\"} %g\n", openMetricsLabel(r.RoundType), r.AverageMs)
+	}
+	b.WriteString("# EOF\n")
+
+	fmt.Fprint(w, b.String())
+}
+
+// LeagueStatsHandler exports the calling tenant's league stats, as JSON or
+// with ?format=openmetrics (or an Accept header asking for it) for
+// scraping.
+func (srv *Server) LeagueStatsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	t, ok := srv.tenantFor(r)
+	if !ok || t.ID == "" {
+		writeError(w, http.StatusUnauthorized, codeCredentialsRequired, "tenant credentials are required")
+		return
+	}
+
+	stats, err := srv.store.LoadLeagueStats(t.ID)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	teams, reactions := stats.Report()
+
+	if r.URL.Query().Get("format") == "openmetrics" || strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+		writeOpenMetrics(w, teams, reactions)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant":    t.ID,
+		"teams":     teams,
+		"reactions": reactions,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
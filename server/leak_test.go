@@ -0,0 +1,114 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"bzzz/client"
+	"bzzz/internal/testserver"
+)
+
+// goroutineSlack allows for goroutines the runtime and net/http start and
+// stop on their own schedule.
+const goroutineSlack = 2
+
+// settle waits for the server's stats to pass ok, failing the test with
+// the last stats if they don't within a few seconds.
+func settle(t *testing.T, ts *testserver.Server, ok func(testserver.Stats) bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		// idle keep-alive connections hold a goroutine on both ends
+		http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+		ts.Server.Client().CloseIdleConnections()
+
+		stats := ts.Stats()
+		if ok(stats) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stats never settled: %+v", stats)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// playGame runs a game with a host stream and a stream for each of its
+// players, each of whom buzzes in on a question of their own. it returns
+// the game and every stream, the host's first.
+func playGame(t *testing.T, ts *testserver.Server, players int) (*client.Game, []*testserver.Stream) {
+	t.Helper()
+
+	g := ts.CreateGame(client.GameOptions{})
+	host := ts.ListenHost(g)
+	streams := []*testserver.Stream{host}
+	var joined []*client.Player
+	for i := 0; i < players; i++ {
+		p := ts.Join(g, fmt.Sprintf("player%d", i))
+		joined = append(joined, p)
+		streams = append(streams, ts.Listen(p))
+	}
+	for _, p := range joined {
+		ts.MustBuzz(g, p)
+		host.WaitFor("buzz")
+		if err := ts.Client.Reset(context.Background(), g.Code, g.HostToken); err != nil {
+			t.Fatalf("failed to reset: %v", err)
+		}
+		host.WaitFor("question_reset")
+	}
+	return g, streams
+}
+
+func TestStreamsReleasedOnDisconnect(t *testing.T) {
+	ts := testserver.New(t, nil)
+	var base testserver.Stats
+	settle(t, ts, func(s testserver.Stats) bool {
+		base = s
+		return true
+	})
+
+	_, streams := playGame(t, ts, 10)
+	if n := ts.Stats().Queues; n != int64(len(streams)) {
+		t.Fatalf("%d queues open, want %d", n, len(streams))
+	}
+	for _, st := range streams {
+		st.Close()
+	}
+
+	// the game goes on, so its hub and the hub's goroutine may too, but
+	// nothing of the streams
+	settle(t, ts, func(s testserver.Stats) bool {
+		return s.Queues == base.Queues && s.Connected == 0 && s.Hosts == 0 &&
+			s.Goroutines <= base.Goroutines+goroutineSlack+1
+	})
+}
+
+func TestGameReleasedOnEnd(t *testing.T) {
+	c := testserver.Config()
+	c.EndLinger = 0
+	ts := testserver.New(t, c)
+	var base testserver.Stats
+	settle(t, ts, func(s testserver.Stats) bool {
+		base = s
+		return true
+	})
+
+	for i := 0; i < 3; i++ {
+		g, streams := playGame(t, ts, 5)
+		if _, err := ts.Client.EndGame(context.Background(), g.Code, g.HostToken); err != nil {
+			t.Fatalf("failed to end game: %v", err)
+		}
+		for _, st := range streams {
+			st.Close()
+		}
+	}
+
+	settle(t, ts, func(s testserver.Stats) bool {
+		return s.Games == base.Games && s.Hubs == base.Hubs && s.Queues == base.Queues &&
+			s.Goroutines <= base.Goroutines+goroutineSlack
+	})
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// stateChanged tells everyone the game moved from one lifecycle state to
+// another. it's sequenced straight after the events that moved it, even
+// when they only went to the host or the players.
+const stateChanged = "state_changed"
+
+// stateEvent is the data of a state_changed event.
+type stateEvent struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// transitionStatus is the status to answer err with, 409 for a
+// game.TransitionError and otherwise status.
+func transitionStatus(err error, status int) int {
+	if _, ok := err.(*game.TransitionError); ok {
+		return http.StatusConflict
+	}
+	return status
+}
+
+// stateChange moves a game on to the state a batch of messages about to be
+// sequenced left it in, returning the state and a state_changed for
+// everyone if it's a new one.
+func (srv *Server) stateChange(msgs []transport.Message) (string, *transport.Message) {
+	if len(msgs) == 0 || msgs[0].GameID == "" {
+		return "", nil
+	}
+	gameID := msgs[0].GameID
+	from, to, changed, err := srv.manager.TransitionState(gameID)
+	if err != nil {
+		log.Printf("game %s made an unexpected move: %v", gameID, err)
+		srv.timelineErrorf(gameID, "unexpected state change from %s to %s", from, to)
+	}
+	if !changed {
+		return to, nil
+	}
+	return to, &transport.Message{GameID: gameID, Action: stateChanged, Data: stateEvent{From: from, To: to}}
+}
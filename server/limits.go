@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"bzzz/internal/game"
+)
+
+// capacityRetryAfter is how long clients turned away at a limit are told to
+// wait before trying again.
+const capacityRetryAfter = 30 * time.Second
+
+// limitStats is how this replica stands against its limits, 0 for a limit
+// meaning there is none.
+type limitStats struct {
+	Streams        int64 `json:"streams"`
+	MaxStreams     int   `json:"maxStreams"`
+	StreamsRefused int64 `json:"streamsRefused"`
+	MaxGames       int   `json:"maxGames"`
+	GamesRefused   int64 `json:"gamesRefused"`
+}
+
+func (srv *Server) currentLimitStats() limitStats {
+	return limitStats{
+		Streams:        atomic.LoadInt64(&srv.openStreams),
+		MaxStreams:     srv.cfg.MaxStreams,
+		StreamsRefused: atomic.LoadInt64(&srv.streamsRefused),
+		MaxGames:       srv.cfg.MaxGames,
+		GamesRefused:   atomic.LoadInt64(&srv.gamesRefused),
+	}
+}
+
+// atCapacity is the error for a request turned away at a limit.
+func atCapacity(message string, limit int) *requestError {
+	return &requestError{
+		status:       http.StatusServiceUnavailable,
+		Code:         codeAtCapacity,
+		Message:      message,
+		RetryAfterMs: capacityRetryAfter.Milliseconds(),
+		Details:      map[string]interface{}{"limit": limit},
+	}
+}
+
+// gameLimitError answers a game that couldn't be created for
+// game.ErrAtGameLimit.
+func (srv *Server) gameLimitError() *requestError {
+	atomic.AddInt64(&srv.gamesRefused, 1)
+	log.Printf("turned a new game away, at the limit of %d games", srv.cfg.MaxGames)
+	return atCapacity(fmt.Sprintf("%s, try again shortly", game.ErrAtGameLimit), srv.cfg.MaxGames)
+}
+
+// limitStreams counts a route's requests as open streams for as long as
+// they run, turning them away once cfg.MaxStreams are open.
+func (srv *Server) limitStreams(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&srv.openStreams, 1)
+		defer atomic.AddInt64(&srv.openStreams, -1)
+
+		if srv.cfg.MaxStreams > 0 && n > int64(srv.cfg.MaxStreams) {
+			if atomic.AddInt64(&srv.streamsRefused, 1)%100 == 1 {
+				// a surge is turned away many times over, only log
+				// some of them
+				log.Printf("turned a stream away, at the limit of %d streams", srv.cfg.MaxStreams)
+			}
+			writeRequestError(w, atCapacity("this server has as many connections open as it can take, try again shortly", srv.cfg.MaxStreams))
+			return
+		}
+		next(w, r)
+	}
+}
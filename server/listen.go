@@ -0,0 +1,373 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// listenerConfig describes one socket the server accepts connections on.
+type listenerConfig struct {
+	// Network is "tcp" (dual stack), "tcp4", "tcp6", "unix" or "systemd".
+	// defaults to "tcp".
+	Network string `json:"network"`
+
+	// Addr is host:port for tcp. leave the host empty to bind every
+	// interface, or give an interface address like "192.168.1.5:8080" or
+	// "[::1]:8080". for unix it is the socket path and for systemd it is the
+	// FileDescriptorName of an activated socket.
+	Addr string `json:"addr"`
+
+	// Mode sets the permissions of a unix socket, e.g. "0660".
+	Mode string `json:"mode"`
+
+	// CertFile and KeyFile enable TLS for this listener. a listener without
+	// them serves plain HTTP.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+
+	// Autocert enables TLS with certificates from Let's Encrypt for the
+	// configured autocert domains instead of from files.
+	Autocert bool `json:"autocert"`
+
+	// Serve lists what the listener serves, "public" for the API,
+	// WebSockets and web client, "private" for /metrics and the admin API.
+	// both when empty, with /metrics then needing the admin token. health
+	// probes are answered on every listener.
+	Serve []string `json:"serve"`
+}
+
+// what a listener can serve, see listenerConfig.Serve
+const (
+	servePublic  = "public"
+	servePrivate = "private"
+)
+
+// serves reports whether a listener serves part.
+func (l listenerConfig) serves(part string) bool {
+	if len(l.Serve) == 0 {
+		return true
+	}
+	for _, p := range l.Serve {
+		if p == part {
+			return true
+		}
+	}
+	return false
+}
+
+// privatePath reports whether a request path is for metrics or the admin
+// API, which a deployment can keep to listeners on its internal network.
+func privatePath(path string) bool {
+	return path == "/metrics" || strings.HasPrefix(path, "/api/admin/")
+}
+
+// serving describes what a listener serves for its log line, nothing when
+// it serves everything.
+func (l listenerConfig) serving() string {
+	if len(l.Serve) == 0 {
+		return ""
+	}
+	return ", serving " + strings.Join(l.Serve, " and ")
+}
+
+// privateKey marks a request as having come in on a listener the public
+// can't reach.
+type privateKey struct{}
+
+// servedBy limits h to what a listener serves. everything else is not
+// found on it, as if it weren't there. requests on a listener the public
+// can't reach are marked so /metrics is served there without the admin
+// token, see requireAdminInPublic.
+func (srv *Server) servedBy(l listenerConfig, h http.Handler) http.Handler {
+	private := !l.serves(servePublic)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		part := servePublic
+		switch {
+		case r.URL.Path == "/healthz" || r.URL.Path == "/readyz":
+			h.ServeHTTP(w, r)
+			return
+		case privatePath(r.URL.Path):
+			part = servePrivate
+		}
+		if !l.serves(part) {
+			apiNotFound(w, r)
+			return
+		}
+		if private {
+			r = r.WithContext(context.WithValue(r.Context(), privateKey{}, true))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tls reports whether a listener serves HTTPS.
+func (l listenerConfig) tls() bool {
+	return l.CertFile != "" || l.Autocert
+}
+
+// defaultListeners is the listener on the configured address used when no
+// listener config is given, TLS unless it's turned off, and the plain HTTP
+// listener next to it if there is one. with a private address, metrics and
+// the admin API move off them onto a plain HTTP listener there.
+func (srv *Server) defaultListeners() []listenerConfig {
+	l := listenerConfig{Addr: srv.cfg.Addr}
+	switch srv.cfg.TLS {
+	case "off":
+	case "autocert":
+		l.Autocert = true
+	default:
+		l.CertFile, l.KeyFile = srv.cfg.CertFile, srv.cfg.KeyFile
+		if l.CertFile == "" {
+			l.CertFile, l.KeyFile = "fullchain.pem", "privkey.pem"
+			if srv.cfg.Mode == "dev" {
+				log.Printf("dev mode. using self-signed cert")
+				l.CertFile, l.KeyFile = "local.crt", "local.key"
+			}
+		}
+	}
+
+	listeners := []listenerConfig{l}
+	if srv.cfg.HTTPAddr != "" {
+		listeners = append(listeners, listenerConfig{Addr: srv.cfg.HTTPAddr})
+	}
+	if srv.cfg.PrivateAddr != "" {
+		for i := range listeners {
+			listeners[i].Serve = []string{servePublic}
+		}
+		listeners = append(listeners, listenerConfig{Addr: srv.cfg.PrivateAddr, Serve: []string{servePrivate}})
+	}
+	return listeners
+}
+
+// loadListeners reads listener configs from the configured listeners JSON
+// file. without one every systemd activated socket is served over plain
+// HTTP, and failing that defaultListeners are used.
+func (srv *Server) loadListeners(activated map[string]net.Listener) ([]listenerConfig, error) {
+	path := srv.cfg.ListenersConfig
+	if path == "" {
+		if len(activated) > 0 {
+			listeners := []listenerConfig{}
+			for name := range activated {
+				listeners = append(listeners, listenerConfig{Network: "systemd", Addr: name})
+			}
+			return listeners, nil
+		}
+		return srv.defaultListeners(), nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed struct {
+		Listeners []listenerConfig `json:"listeners"`
+	}
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Listeners) == 0 {
+		return nil, errors.New("no listeners configured")
+	}
+
+	for i, l := range parsed.Listeners {
+		if l.Network == "" {
+			parsed.Listeners[i].Network = "tcp"
+		}
+		if (l.CertFile == "") != (l.KeyFile == "") {
+			return nil, fmt.Errorf("listener %s needs both a certFile and a keyFile", l.Addr)
+		}
+		if l.Autocert && l.CertFile != "" {
+			return nil, fmt.Errorf("listener %s can't use autocert and a certFile", l.Addr)
+		}
+		if l.Autocert && len(srv.cfg.AutocertDomains) == 0 {
+			return nil, fmt.Errorf("listener %s uses autocert but there are no autocert_domains", l.Addr)
+		}
+		for _, part := range l.Serve {
+			if part != servePublic && part != servePrivate {
+				return nil, fmt.Errorf("listener %s can't serve %q, only public or private", l.Addr, part)
+			}
+		}
+	}
+	return parsed.Listeners, nil
+}
+
+// systemdListeners returns the sockets passed in by systemd socket
+// activation keyed by FileDescriptorName, or by fd number when unnamed.
+func systemdListeners() (map[string]net.Listener, error) {
+	listeners := map[string]net.Listener{}
+
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return listeners, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil {
+		return listeners, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// don't let child processes think the sockets are theirs
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	for i := 0; i < n; i++ {
+		fd := systemdFirstFD + i
+		name := strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" && names[i] != "unknown" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("activated socket %s: %v", name, err)
+		}
+		listeners[name] = ln
+	}
+	return listeners, nil
+}
+
+// systemdFirstFD is the first file descriptor systemd passes sockets on.
+const systemdFirstFD = 3
+
+// openListener creates the socket for a listener config.
+func openListener(l listenerConfig, activated map[string]net.Listener) (net.Listener, error) {
+	switch l.Network {
+	case "systemd":
+		ln, ok := activated[l.Addr]
+		if !ok {
+			return nil, fmt.Errorf("no activated socket named %s", l.Addr)
+		}
+		return ln, nil
+	case "unix":
+		// clear out a socket left behind by a previous run
+		if fi, err := os.Stat(l.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(l.Addr)
+		}
+		ln, err := net.Listen("unix", l.Addr)
+		if err != nil {
+			return nil, err
+		}
+		if l.Mode != "" {
+			mode, err := strconv.ParseUint(l.Mode, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("bad mode %s for %s: %v", l.Mode, l.Addr, err)
+			}
+			if err := os.Chmod(l.Addr, os.FileMode(mode)); err != nil {
+				return nil, err
+			}
+		}
+		return ln, nil
+	case "":
+		return net.Listen("tcp", l.Addr)
+	default:
+		return net.Listen(l.Network, l.Addr)
+	}
+}
+
+// serve starts every configured listener and returns their servers. a
+// listener failing once it's serving takes the whole process down, just
+// like a single listener would.
+func (srv *Server) serve(h http.Handler) ([]*http.Server, error) {
+	activated, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners, err := srv.loadListeners(activated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load listener config: %v", err)
+	}
+
+	var certs *autocert.Manager
+	for _, l := range listeners {
+		if l.Autocert {
+			certs = srv.newAutocertManager()
+			break
+		}
+	}
+	if srv.cfg.TLSClientCA != "" {
+		if err := srv.loadClientCAs(); err != nil {
+			return nil, err
+		}
+	}
+
+	var reloaders []*certReloader
+	servers := make([]*http.Server, 0, len(listeners))
+	for _, l := range listeners {
+		ln, err := openListener(l, activated)
+		if err != nil {
+			return nil, err
+		}
+
+		lh := srv.servedBy(l, h)
+		hs := &http.Server{Handler: lh, ConnContext: withConn}
+		switch {
+		case l.Autocert:
+			hs.TLSConfig = certs.TLSConfig()
+			srv.applyTLSSettings(hs.TLSConfig)
+		case l.tls():
+			r, err := newCertReloader(l.CertFile, l.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			reloaders = append(reloaders, r)
+			hs.TLSConfig = &tls.Config{GetCertificate: r.getCertificate}
+			srv.applyTLSSettings(hs.TLSConfig)
+		case certs != nil:
+			// Let's Encrypt checks domains over plain HTTP
+			hs.Handler = certs.HTTPHandler(lh)
+		}
+		servers = append(servers, hs)
+
+		go func(l listenerConfig, ln net.Listener) {
+			var err error
+			switch {
+			case l.Autocert:
+				log.Printf("listening on %s %s with TLS from Let's Encrypt%s", ln.Addr().Network(), ln.Addr(), l.serving())
+				err = hs.ServeTLS(ln, "", "")
+			case l.tls():
+				log.Printf("listening on %s %s with TLS%s", ln.Addr().Network(), ln.Addr(), l.serving())
+				// the certificate comes from its certReloader
+				err = hs.ServeTLS(ln, "", "")
+			default:
+				log.Printf("listening on %s %s%s", ln.Addr().Network(), ln.Addr(), l.serving())
+				err = hs.Serve(ln)
+			}
+			// a server being shut down isn't a failure
+			if err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}(l, ln)
+	}
+	if len(reloaders) > 0 || srv.cfg.TLSClientCA != "" {
+		srv.reloadTLSOnHangup(reloaders)
+	}
+	return servers, nil
+}
+
+// newAutocertManager gets and renews certificates from Let's Encrypt for
+// the configured domains, keeping them in the autocert cache dir so a
+// restart doesn't ask for new ones.
+func (srv *Server) newAutocertManager() *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(srv.cfg.AutocertDomains...),
+		Cache:      autocert.DirCache(srv.cfg.AutocertCacheDir),
+		Email:      srv.cfg.AutocertEmail,
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// a host locking or unlocking the buzzers of one player or team, rather
+// than everyone's. they're events of their own so clients that only know
+// lock and unlock don't take them for the whole game's.
+const (
+	targetLocked   = "buzzer_locked"
+	targetUnlocked = "buzzer_unlocked"
+)
+
+// lockRequest picks whose buzzers a lock or unlock is for, everyone's when
+// it's empty.
+type lockRequest struct {
+	PlayerID string `json:"playerID,omitempty"`
+	Team     string `json:"team,omitempty"`
+}
+
+func (req *lockRequest) validate() error {
+	if req.PlayerID != "" && req.Team != "" {
+		return badRequest("team", "lock a player or a team, not both")
+	}
+	return nil
+}
+
+// targeted reports whether the request is for one player or team.
+func (req *lockRequest) targeted() bool {
+	return req.PlayerID != "" || req.Team != ""
+}
+
+// lockTargetEvent is the data of buzzer_locked and buzzer_unlocked events.
+type lockTargetEvent struct {
+	PlayerID string `json:"playerID,omitempty"`
+	Team     string `json:"team,omitempty"`
+}
+
+// lockStatus is the body of GET /host/{id}/lock.
+type lockStatus struct {
+	Locked bool `json:"locked"`
+	// Armed is whether an ArmedBuzzing game is taking buzzes
+	Armed         bool     `json:"armed"`
+	LockedPlayers []string `json:"lockedPlayers,omitempty"`
+	LockedTeams   []string `json:"lockedTeams,omitempty"`
+}
+
+// lockTargetHandled handles a lock or unlock whose body picks one player or
+// team, reporting whether it did. requests without one are left for the
+// caller to lock or unlock everyone.
+func (srv *Server) lockTargetHandled(w http.ResponseWriter, r *http.Request, id string, lock bool) bool {
+	var req lockRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return true
+		}
+	}
+	if !req.targeted() {
+		return false
+	}
+
+	switch err := srv.manager.LockTarget(id, req.PlayerID, req.Team, lock); err {
+	case nil:
+	case game.ErrPlayerNotFound:
+		writeError(w, http.StatusNotFound, codePlayerNotFound, fmt.Sprintf("player [%s] not found in game [%s]", req.PlayerID, id))
+		return true
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return true
+	}
+
+	action := targetLocked
+	if !lock {
+		action = targetUnlocked
+	}
+	srv.publish(traced(r.Context(), transport.Message{
+		GameID:   id,
+		PlayerID: req.PlayerID,
+		Action:   action,
+		Data:     lockTargetEvent(req),
+	}), transport.ToPlayers)
+
+	w.WriteHeader(http.StatusCreated)
+	return true
+}
@@ -0,0 +1,547 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+)
+
+// LTI 1.3 lets a game be launched from an LMS such as Canvas or Moodle.
+// Teachers launching the tool get a fresh game, students launching the same
+// resource link are sent to that game, the course roster is pulled via the
+// names and roles service and final scores are posted back through the
+// assignment and grade service.
+
+const (
+	ltiStateTTL = 10 * time.Minute
+
+	ltiClaimMessageType = "https://purl.imsglobal.org/spec/lti/claim/message_type"
+	ltiClaimVersion     = "https://purl.imsglobal.org/spec/lti/claim/version"
+	ltiClaimDeployment  = "https://purl.imsglobal.org/spec/lti/claim/deployment_id"
+	ltiClaimRoles       = "https://purl.imsglobal.org/spec/lti/claim/roles"
+	ltiClaimResource    = "https://purl.imsglobal.org/spec/lti/claim/resource_link"
+	ltiClaimNRPS        = "https://purl.imsglobal.org/spec/lti-nrps/claim/namesroleservice"
+	ltiClaimAGS         = "https://purl.imsglobal.org/spec/lti-ags/claim/endpoint"
+
+	ltiRoleInstructor = "http://purl.imsglobal.org/vocab/lis/v2/membership#Instructor"
+
+	ltiScopeNRPS  = "https://purl.imsglobal.org/spec/lti-nrps/scope/contextmembership.readonly"
+	ltiScopeScore = "https://purl.imsglobal.org/spec/lti-ags/scope/score"
+)
+
+// ltiPlatform is an LMS registration.
+type ltiPlatform struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"clientID"`
+	AuthURL  string `json:"authURL"`
+	TokenURL string `json:"tokenURL"`
+	JWKSURL  string `json:"jwksURL"`
+}
+
+// ltiConfig is loaded from the JSON file named by the LTI_CONFIG env var.
+type ltiConfig struct {
+	Platforms []ltiPlatform `json:"platforms"`
+
+	// the tool's private key, used to sign service token requests
+	KeyFile string `json:"keyFile"`
+	KeyID   string `json:"keyID"`
+
+	// LaunchURL is the redirect_uri registered with the platforms
+	LaunchURL string `json:"launchURL"`
+
+	// HostURL and PlayURL are the frontend pages teachers and students are
+	// sent to. the game code is added as the "game" query param.
+	HostURL string `json:"hostURL"`
+	PlayURL string `json:"playURL"`
+}
+
+type ltiState struct {
+	nonce    string
+	platform *ltiPlatform
+	expires  time.Time
+}
+
+type ltiMember struct {
+	UserID string   `json:"userID"`
+	Name   string   `json:"name"`
+	Roles  []string `json:"roles"`
+}
+
+// ltiGame tracks the LMS context a game was launched from.
+type ltiGame struct {
+	platform     *ltiPlatform
+	resourceLink string
+	membersURL   string
+	lineItemURL  string
+	roster       []ltiMember
+}
+
+func (srv *Server) loadLTIConfig(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &srv.lti); err != nil {
+		return err
+	}
+	if len(srv.lti.Platforms) == 0 {
+		return errors.New("no platforms configured")
+	}
+
+	keyPEM, err := ioutil.ReadFile(srv.lti.KeyFile)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return errors.New("no PEM data in key file")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		srv.ltiKey = key
+		return nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("key file is not an RSA key")
+	}
+	srv.ltiKey = rsaKey
+	return nil
+}
+
+func (srv *Server) ltiPlatformFor(issuer, clientID string) *ltiPlatform {
+	for i, p := range srv.lti.Platforms {
+		if p.Issuer == issuer && (clientID == "" || p.ClientID == clientID) {
+			return &srv.lti.Platforms[i]
+		}
+	}
+	return nil
+}
+
+// LTILoginHandler handles third party initiated login, the first leg of an
+// LTI 1.3 launch, by redirecting back to the platform's auth endpoint.
+func (srv *Server) LTILoginHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "", "failed to parse login request")
+		return
+	}
+
+	platform := srv.ltiPlatformFor(r.Form.Get("iss"), r.Form.Get("client_id"))
+	if platform == nil {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("unknown platform [%s]", r.Form.Get("iss")))
+		return
+	}
+
+	state, err := game.RandomHex(16)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to generate state")
+		return
+	}
+	nonce, err := game.RandomHex(16)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to generate nonce")
+		return
+	}
+
+	srv.ltiMu.Lock()
+	now := time.Now()
+	for k, s := range srv.ltiStates {
+		if now.After(s.expires) {
+			delete(srv.ltiStates, k)
+		}
+	}
+	srv.ltiStates[state] = &ltiState{nonce: nonce, platform: platform, expires: now.Add(ltiStateTTL)}
+	srv.ltiMu.Unlock()
+
+	q := url.Values{}
+	q.Set("scope", "openid")
+	q.Set("response_type", "id_token")
+	q.Set("response_mode", "form_post")
+	q.Set("prompt", "none")
+	q.Set("client_id", platform.ClientID)
+	q.Set("redirect_uri", srv.lti.LaunchURL)
+	q.Set("login_hint", r.Form.Get("login_hint"))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	if hint := r.Form.Get("lti_message_hint"); hint != "" {
+		q.Set("lti_message_hint", hint)
+	}
+
+	http.Redirect(w, r, platform.AuthURL+"?"+q.Encode(), http.StatusFound)
+}
+
+// LTILaunchHandler validates the platform's id_token and sends the user to
+// the right game. Instructors get a new game per resource link, everyone else
+// joins the game already running for that link.
+func (srv *Server) LTILaunchHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "", "failed to parse launch request")
+		return
+	}
+
+	srv.ltiMu.Lock()
+	state, ok := srv.ltiStates[r.PostForm.Get("state")]
+	delete(srv.ltiStates, r.PostForm.Get("state"))
+	srv.ltiMu.Unlock()
+	if !ok || time.Now().After(state.expires) {
+		writeError(w, http.StatusBadRequest, "", "unknown or expired launch state")
+		return
+	}
+
+	platform := state.platform
+	claims, err := parseJWT(r.PostForm.Get("id_token"), func(kid string) (*rsa.PublicKey, error) {
+		return srv.jwksKey(platform.JWKSURL, kid)
+	})
+	if err != nil {
+		log.Println(err.Error())
+		writeError(w, http.StatusUnauthorized, "", "invalid id_token")
+		return
+	}
+
+	if claims["iss"] != platform.Issuer || !jwtAudience(claims, platform.ClientID) {
+		writeError(w, http.StatusUnauthorized, "", "id_token was not issued for this tool")
+		return
+	}
+	if claims["nonce"] != state.nonce {
+		writeError(w, http.StatusUnauthorized, "", "id_token nonce mismatch")
+		return
+	}
+	if claims[ltiClaimMessageType] != "LtiResourceLinkRequest" || claims[ltiClaimVersion] != "1.3.0" {
+		writeError(w, http.StatusBadRequest, "", "unsupported LTI message")
+		return
+	}
+	if _, ok := claims[ltiClaimDeployment].(string); !ok {
+		writeError(w, http.StatusBadRequest, "", "missing deployment id")
+		return
+	}
+
+	resource, _ := claims[ltiClaimResource].(map[string]interface{})
+	linkID, _ := resource["id"].(string)
+	if linkID == "" {
+		writeError(w, http.StatusBadRequest, "", "missing resource link")
+		return
+	}
+	linkKey := platform.Issuer + "|" + linkID
+
+	instructor := false
+	roles, _ := claims[ltiClaimRoles].([]interface{})
+	for _, role := range roles {
+		if role == ltiRoleInstructor {
+			instructor = true
+		}
+	}
+
+	srv.ltiMu.Lock()
+	gameID, running := srv.ltiLinks[linkKey]
+	srv.ltiMu.Unlock()
+	if running && !srv.manager.Exists(gameID) {
+		running = false
+	}
+
+	if instructor {
+		if !running {
+			gameID, err = srv.manager.CreateGame(game.GameOptions{}, "")
+			if err == game.ErrAtGameLimit {
+				writeRequestError(w, srv.gameLimitError())
+				return
+			}
+			if err != nil {
+				writeErr(w, http.StatusInternalServerError, err)
+				return
+			}
+
+			lg := &ltiGame{platform: platform, resourceLink: linkKey}
+			if nrps, ok := claims[ltiClaimNRPS].(map[string]interface{}); ok {
+				lg.membersURL, _ = nrps["context_memberships_url"].(string)
+			}
+			if ags, ok := claims[ltiClaimAGS].(map[string]interface{}); ok {
+				lg.lineItemURL, _ = ags["lineitem"].(string)
+			}
+
+			srv.ltiMu.Lock()
+			srv.ltiGames[gameID] = lg
+			srv.ltiLinks[linkKey] = gameID
+			srv.ltiMu.Unlock()
+
+			if lg.membersURL != "" {
+				go srv.ltiSyncRoster(gameID, lg)
+			}
+		}
+
+		hostToken, _ := srv.manager.HostToken(gameID)
+		http.Redirect(w, r, ltiFrontendURL(srv.lti.HostURL, gameID, url.Values{"hostToken": {hostToken}}), http.StatusFound)
+		return
+	}
+
+	if !running {
+		writeError(w, http.StatusNotFound, codeGameNotFound, "your teacher hasn't started this game yet")
+		return
+	}
+
+	extra := url.Values{}
+	if name, ok := claims["name"].(string); ok {
+		extra.Set("name", name)
+	}
+	if sub, ok := claims["sub"].(string); ok {
+		extra.Set("ltiUser", sub)
+	}
+	http.Redirect(w, r, ltiFrontendURL(srv.lti.PlayURL, gameID, extra), http.StatusFound)
+}
+
+func ltiFrontendURL(base string, gameID string, extra url.Values) string {
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("game", gameID)
+
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + q.Encode()
+}
+
+// ltiAccessToken requests a service access token from the platform using a
+// signed client assertion.
+func (srv *Server) ltiAccessToken(platform *ltiPlatform, scopes ...string) (string, error) {
+	jti, err := game.RandomHex(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	assertion, err := signJWT(map[string]interface{}{
+		"iss": platform.ClientID,
+		"sub": platform.ClientID,
+		"aud": platform.TokenURL,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"jti": jti,
+	}, srv.ltiKey, srv.lti.KeyID)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	form.Set("client_assertion", assertion)
+	form.Set("scope", strings.Join(scopes, " "))
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.PostForm(platform.TokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// ltiSyncRoster pulls the course roster for a game.
+func (srv *Server) ltiSyncRoster(gameID string, game *ltiGame) {
+	token, err := srv.ltiAccessToken(game.platform, ltiScopeNRPS)
+	if err != nil {
+		log.Printf("lti roster for game %s: %v", gameID, err)
+		return
+	}
+
+	req, err := http.NewRequest("GET", game.membersURL, nil)
+	if err != nil {
+		log.Printf("lti roster for game %s: %v", gameID, err)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.ims.lti-nrps.v2.membershipcontainer+json")
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("lti roster for game %s: %v", gameID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("lti roster for game %s: membership request returned %s", gameID, resp.Status)
+		return
+	}
+
+	var container struct {
+		Members []struct {
+			UserID string   `json:"user_id"`
+			Name   string   `json:"name"`
+			Roles  []string `json:"roles"`
+			Status string   `json:"status"`
+		} `json:"members"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&container); err != nil {
+		log.Printf("lti roster for game %s: %v", gameID, err)
+		return
+	}
+
+	roster := []ltiMember{}
+	for _, m := range container.Members {
+		if m.Status != "" && m.Status != "Active" {
+			continue
+		}
+		roster = append(roster, ltiMember{UserID: m.UserID, Name: m.Name, Roles: m.Roles})
+	}
+
+	srv.ltiMu.Lock()
+	game.roster = roster
+	srv.ltiMu.Unlock()
+	log.Printf("lti roster for game %s: %d members", gameID, len(roster))
+}
+
+func (srv *Server) ltiGameFromRequest(w http.ResponseWriter, r *http.Request) (string, *ltiGame, bool) {
+	id := mux.Vars(r)["id"]
+
+	srv.ltiMu.Lock()
+	game, ok := srv.ltiGames[id]
+	srv.ltiMu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] was not launched from an LMS", id))
+		return "", nil, false
+	}
+	return id, game, true
+}
+
+// LTIRosterHandler returns the course roster for an LMS launched game.
+func (srv *Server) LTIRosterHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	_, game, ok := srv.ltiGameFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	srv.ltiMu.Lock()
+	roster := game.roster
+	srv.ltiMu.Unlock()
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"members": roster})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// ltiScoresRequest overrides the scores posted back to the gradebook.
+type ltiScoresRequest struct {
+	Scores []struct {
+		UserID  string  `json:"userID"`
+		Score   float64 `json:"score"`
+		Maximum float64 `json:"maximum"`
+	} `json:"scores"`
+}
+
+// LTIScoresHandler posts final scores to the LMS gradebook.
+func (srv *Server) LTIScoresHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	i, game, ok := srv.ltiGameFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if game.lineItemURL == "" {
+		writeError(w, http.StatusConflict, "", "the LMS did not provide a gradebook column for this game")
+		return
+	}
+
+	var req ltiScoresRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	token, err := srv.ltiAccessToken(game.platform, ltiScopeScore)
+	if err != nil {
+		log.Println(err.Error())
+		writeError(w, http.StatusBadGateway, "", "failed to authenticate with the LMS")
+		return
+	}
+
+	// scores are posted to the line item's /scores sub resource
+	scoresURL := game.lineItemURL
+	query := ""
+	if q := strings.Index(scoresURL, "?"); q >= 0 {
+		scoresURL, query = scoresURL[:q], scoresURL[q:]
+	}
+	scoresURL = strings.TrimSuffix(scoresURL, "/") + "/scores" + query
+
+	client := http.Client{Timeout: 10 * time.Second}
+	failed := []string{}
+	for _, s := range req.Scores {
+		body, err := json.Marshal(map[string]interface{}{
+			"userId":           s.UserID,
+			"scoreGiven":       s.Score,
+			"scoreMaximum":     s.Maximum,
+			"activityProgress": "Completed",
+			"gradingProgress":  "FullyGraded",
+			"timestamp":        time.Now().Format(time.RFC3339),
+		})
+		if err != nil {
+			failed = append(failed, s.UserID)
+			continue
+		}
+
+		post, err := http.NewRequest("POST", scoresURL, bytes.NewReader(body))
+		if err != nil {
+			failed = append(failed, s.UserID)
+			continue
+		}
+		post.Header.Set("Authorization", "Bearer "+token)
+		post.Header.Set("Content-Type", "application/vnd.ims.lis.v1.score+json")
+
+		resp, err := client.Do(post)
+		if err != nil {
+			log.Printf("lti score for game %s user %s: %v", i, s.UserID, err)
+			failed = append(failed, s.UserID)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("lti score for game %s user %s: %s", i, s.UserID, resp.Status)
+			failed = append(failed, s.UserID)
+		}
+	}
+
+	if len(failed) > 0 {
+		writeError(w, http.StatusBadGateway, "", fmt.Sprintf("failed to post scores for %s", strings.Join(failed, ", ")))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// hostMarker is a named point in a game the host marked, e.g. "Q5 start",
+// for lining a video of the game up against its buzzes afterwards.
+const hostMarker = "marker"
+
+// maxMarkerName caps how long a marker's name can be, in characters.
+const maxMarkerName = 64
+
+// markerRequest is the body of POST /host/{id}/markers.
+type markerRequest struct {
+	Name string `json:"name"`
+}
+
+func (req *markerRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return badRequest("name", "name is required")
+	}
+	if utf8.RuneCountInString(req.Name) > maxMarkerName {
+		return badRequest("name", "name is too long")
+	}
+	return nil
+}
+
+// markerEvent is the data of a marker event.
+type markerEvent struct {
+	Name string `json:"name"`
+	// At is when the server got the marker, MonotonicMs how long after the
+	// game started that was, see game.SinceStart
+	At          time.Time `json:"at"`
+	MonotonicMs float64   `json:"monotonicMs"`
+}
+
+// HostMarkerHandler drops a named marker into the game's event log with the
+// server's time. only the host's stream hears about it, but it's kept in
+// the history, so exports show it between the buzzes it was marked among.
+func (srv *Server) HostMarkerHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req markerRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	startedAt := srv.manager.StartedAt(id)
+	if startedAt.IsZero() {
+		writeErr(w, http.StatusNotFound, game.ErrGameNotFound)
+		return
+	}
+	now := time.Now()
+	ev := markerEvent{Name: req.Name, At: now, MonotonicMs: game.SinceStart(startedAt, now)}
+	srv.publish(traced(r.Context(), transport.Message{GameID: id, Action: hostMarker, Data: ev}), transport.ToHost)
+
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(ev)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
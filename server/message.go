@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/transport"
+)
+
+// hostMessage is a message from the host, sent to every player or just one
+const hostMessage = "host_message"
+
+// how much a host can say in one message
+const (
+	maxHostMessageText = 500
+	maxHostMessageData = 4096
+)
+
+// hostMessageRequest is a message for the host to send. it goes to every
+// player, or only PlayerID when it's given.
+type hostMessageRequest struct {
+	PlayerID string `json:"playerID,omitempty"`
+	Text     string `json:"text,omitempty"`
+	// Data is anything the host's and players' apps agree on, passed
+	// through as it is
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (req *hostMessageRequest) validate() error {
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" && len(req.Data) == 0 {
+		return badRequest("text", "a message needs text or data")
+	}
+	if len([]rune(req.Text)) > maxHostMessageText {
+		return badRequest("text", "text can be at most %d characters", maxHostMessageText)
+	}
+	if len(req.Data) > maxHostMessageData {
+		return badRequest("data", "data can be at most %d bytes", maxHostMessageData)
+	}
+	return nil
+}
+
+// hostMessageEvent is the data of a host_message event.
+type hostMessageEvent struct {
+	Text string          `json:"text,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+	// Direct is true when the message was sent to just this player
+	Direct bool `json:"direct"`
+}
+
+// HostMessageHandler sends a message from the host to the game's players,
+// or to one of them when the body names a playerID, such as telling them
+// they're up next or clearing up a question privately.
+func (srv *Server) HostMessageHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req hostMessageRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+	if req.PlayerID != "" && !srv.manager.PlayerInGame(id, req.PlayerID) {
+		writeError(w, http.StatusNotFound, codePlayerNotFound, fmt.Sprintf("player [%s] not found in game [%s]", req.PlayerID, id))
+		return
+	}
+
+	// a direct message is private, only the player it's for gets it
+	srv.publish(transport.Message{
+		GameID:   id,
+		PlayerID: req.PlayerID,
+		Action:   hostMessage,
+		Data:     hostMessageEvent{Text: req.Text, Data: req.Data, Direct: req.PlayerID != ""},
+		Private:  req.PlayerID != "",
+	}, transport.ToPlayers)
+
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// metric is one line of /metrics.
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value float64
+}
+
+// MetricsHandler serves this replica's stats in the OpenMetrics text
+// format for Prometheus to scrape. they're the same numbers as the admin
+// stats. on a private listener they're served without a token, anywhere
+// else only with the admin token, see requireAdminInPublic.
+// scrapes come every few seconds, so they aren't logged.
+func (srv *Server) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := srv.currentServerStats()
+	metrics := []metric{
+		{"bzzz_uptime_seconds", "gauge", "How long the replica has been serving.", time.Since(stats.StartedAt).Seconds()},
+		{"bzzz_games", "gauge", "Games running on the replica.", float64(stats.Games)},
+		{"bzzz_games_orphaned", "gauge", "Games whose hosts have gone.", float64(stats.Orphaned)},
+		{"bzzz_players", "gauge", "Players in games on the replica.", float64(stats.Players)},
+		{"bzzz_players_connected", "gauge", "Players with a stream open.", float64(stats.Connected)},
+		{"bzzz_hosts", "gauge", "Host streams open.", float64(stats.Hosts)},
+		{"bzzz_spectators", "gauge", "Spectator streams open.", float64(stats.Spectators)},
+		{"bzzz_hubs", "gauge", "Games with events running.", float64(stats.Hubs)},
+		{"bzzz_streams", "gauge", "Streams and held connections open.", float64(stats.Limits.Streams)},
+		{"bzzz_queues", "gauge", "Stream queues open, which should follow the streams.", float64(stats.Queues)},
+		{"bzzz_streams_refused", "counter", "Streams turned away at max_streams.", float64(stats.Limits.StreamsRefused)},
+		{"bzzz_games_refused", "counter", "Games turned away at max_games.", float64(stats.Limits.GamesRefused)},
+		{"bzzz_goroutines", "gauge", "Goroutines running.", float64(stats.Goroutines)},
+		{"bzzz_heap_bytes", "gauge", "Heap in use.", float64(stats.HeapBytes)},
+	}
+
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# TYPE %s %s\n# HELP %s %s\n", m.name, m.kind, m.name, m.help)
+		name := m.name
+		if m.kind == "counter" {
+			name += "_total"
+		}
+		fmt.Fprintf(&b, "%s %g\n", name, m.value)
+	}
+	b.WriteString("# EOF\n")
+
+	fmt.Fprint(w, b.String())
+}
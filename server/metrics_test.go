@@ -0,0 +1,39 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"bzzz/internal/testserver"
+)
+
+// a server embedded through Handler has no private listener, so /metrics
+// needs the admin token there
+func TestMetricsNeedAdminToken(t *testing.T) {
+	ts := testserver.New(t, nil)
+
+	for _, tc := range []struct {
+		token string
+		want  int
+	}{
+		{"", http.StatusUnauthorized},
+		{"wrong", http.StatusUnauthorized},
+		{testserver.AdminToken, http.StatusOK},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+"/metrics", nil)
+		if err != nil {
+			t.Fatalf("failed to fetch metrics: %v", err)
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		resp, err := ts.Server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("failed to fetch metrics: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("metrics with token %q got %d, want %d", tc.token, resp.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var errTextRejected = errors.New("text rejected by moderation")
+
+// kinds of text that get moderated
+const (
+	moderateName   = "name"
+	moderateTeam   = "team"
+	moderateAvatar = "avatar"
+)
+
+// Moderator decides whether player written text is fit to show everyone
+// else. lang is the game's locale, e.g. "en".
+type Moderator interface {
+	Allowed(text, lang string) (bool, error)
+}
+
+// moderationConfig is how a tenant wants text moderated.
+type moderationConfig struct {
+	// Provider is "wordlist" (the default), "perspective" or "none"
+	Provider string `json:"provider"`
+	// Words are blocked on top of the built in list, in every language
+	Words []string `json:"words,omitempty"`
+	// APIKey and Threshold configure the perspective provider. text
+	// scoring at or above Threshold for toxicity is rejected, 0.8 if unset.
+	APIKey    string  `json:"apiKey,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+func newModerator(c moderationConfig) (Moderator, error) {
+	switch c.Provider {
+	case "", "wordlist":
+		return newWordlistModerator(c.Words), nil
+	case "perspective":
+		if c.APIKey == "" {
+			return nil, errors.New("the perspective moderator needs an apiKey")
+		}
+		threshold := c.Threshold
+		if threshold <= 0 {
+			threshold = 0.8
+		}
+		return &perspectiveModerator{apiKey: c.APIKey, threshold: threshold, words: newWordlistModerator(c.Words)}, nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown moderation provider %q", c.Provider)
+	}
+}
+
+// moderate checks text of the given kind written in a game, e.g. a player's
+// name, in the language of the request. a provider that can't be reached
+// lets the text through rather than locking players out.
+func (srv *Server) moderate(r *http.Request, gameID, kind, text string) error {
+	opts, _ := srv.manager.Options(gameID)
+	return srv.moderateText(opts.Tenant, srv.localeFor(r, gameID), "game "+gameID, kind, text)
+}
+
+// moderateText checks text with the moderator of a tenant. where says what
+// the text is for in the logs, e.g. the game it was written in.
+func (srv *Server) moderateText(tenantID, locale, where, kind, text string) error {
+	if text == "" {
+		return nil
+	}
+
+	m, ok := srv.moderators[tenantID]
+	if !ok {
+		m = srv.defaultModerator
+	}
+	if m == nil {
+		return nil
+	}
+
+	allowed, err := m.Allowed(text, locale)
+	if err != nil {
+		log.Printf("failed to moderate %s in %s, letting it through: %v", kind, where, err)
+		return nil
+	}
+	if !allowed {
+		log.Printf("moderation rejected a %s in %s", kind, where)
+		return errTextRejected
+	}
+	return nil
+}
+
+// blockedWords are rejected in names and text, keyed by language. the lists
+// are deliberately short and only catch the obvious.
+var blockedWords = map[string][]string{
+	"en": {"fuck", "fucker", "fucking", "shit", "bitch", "cunt", "asshole", "pussy", "bastard", "slut", "whore", "nigger", "faggot", "retard"},
+	"es": {"puta", "putas", "puto", "putos", "mierda", "cabron", "cabrón", "pendejo", "coño", "joder", "marica", "maricon", "maricón", "gilipollas", "zorra"},
+	"de": {"scheisse", "scheiße", "arschloch", "fotze", "hure", "wichser", "schwuchtel", "missgeburt", "fick", "ficken", "schlampe"},
+}
+
+// leet undoes the usual letter swaps used to sneak words past a filter
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+type wordlistModerator struct {
+	// extra words blocked in every language
+	extra map[string]bool
+}
+
+func newWordlistModerator(words []string) *wordlistModerator {
+	m := &wordlistModerator{extra: map[string]bool{}}
+	for _, w := range words {
+		m.extra[strings.ToLower(w)] = true
+	}
+	return m
+}
+
+// moderationWords splits text into lower case words with leetspeak undone.
+func moderationWords(text string) []string {
+	normalized := leet.Replace(strings.ToLower(text))
+	return strings.FieldsFunc(normalized, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// hasExtra reports whether any of words was blocked by the tenant.
+func (m *wordlistModerator) hasExtra(words []string) bool {
+	for _, w := range words {
+		if m.extra[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed rejects text containing a blocked word for its language, or
+// English, as a whole word or squashed together with others.
+func (m *wordlistModerator) Allowed(text, lang string) (bool, error) {
+	words := moderationWords(text)
+	if m.hasExtra(words) {
+		return false, nil
+	}
+	squashed := strings.Join(words, "")
+
+	lists := [][]string{blockedWords[lang]}
+	if lang != defaultLocale {
+		lists = append(lists, blockedWords[defaultLocale])
+	}
+	for _, list := range lists {
+		for _, bad := range list {
+			for _, w := range words {
+				if w == bad {
+					return false, nil
+				}
+			}
+			// only longer words are matched inside others, short ones
+			// would catch too many innocent names
+			if len(bad) >= 5 && strings.Contains(squashed, bad) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+const perspectiveURL = "https://commentanalyzer.googleapis.com/v1alpha1/comments:analyze"
+
+var perspectiveClient = &http.Client{Timeout: 3 * time.Second}
+
+// perspectiveModerator scores text for toxicity with Google's Perspective
+// API. the tenant's own words are still blocked locally first.
+type perspectiveModerator struct {
+	apiKey    string
+	threshold float64
+	words     *wordlistModerator
+}
+
+func (m *perspectiveModerator) Allowed(text, lang string) (bool, error) {
+	if m.words.hasExtra(moderationWords(text)) {
+		return false, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"comment":             map[string]string{"text": text},
+		"languages":           []string{lang},
+		"requestedAttributes": map[string]interface{}{"TOXICITY": map[string]interface{}{}},
+		"doNotStore":          true,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := perspectiveClient.Post(perspectiveURL+"?key="+m.apiKey, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("perspective responded %s", resp.Status)
+	}
+
+	var result struct {
+		AttributeScores struct {
+			Toxicity struct {
+				SummaryScore struct {
+					Value float64 `json:"value"`
+				} `json:"summaryScore"`
+			} `json:"TOXICITY"`
+		} `json:"attributeScores"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.AttributeScores.Toxicity.SummaryScore.Value < m.threshold, nil
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"bzzz/internal/game"
+)
+
+// nameSymbols is the punctuation allowed in player names besides letters,
+// numbers, spaces and emoji.
+const nameSymbols = "'-_.,!?&()#@+*:"
+
+// zeroWidthJoiner glues emoji such as families together, the only
+// invisible character names can have.
+const zeroWidthJoiner = '\u200d'
+
+// playerName tidies up a name given by a player, collapsing runs of spaces,
+// and checks it's short enough and made of characters that show up on
+// the host screen. it fails with a requestError for the name field.
+func (srv *Server) playerName(r *http.Request, gameID, raw string) (string, error) {
+	name := strings.Join(strings.Fields(raw), " ")
+	if len([]rune(name)) > game.MaxPlayerNameLength {
+		return "", &requestError{status: http.StatusUnprocessableEntity, Code: codeNameTooLong, Message: srv.tr(r, gameID, "name_too_long", game.MaxPlayerNameLength), Field: "name"}
+	}
+	for _, c := range name {
+		switch {
+		case unicode.IsLetter(c), unicode.IsMark(c), unicode.IsNumber(c), c == ' ':
+		case unicode.Is(unicode.So, c), c == zeroWidthJoiner:
+		case strings.ContainsRune(nameSymbols, c):
+		default:
+			return "", &requestError{status: http.StatusUnprocessableEntity, Code: codeNameInvalid, Message: srv.tr(r, gameID, "name_invalid", string(c)), Field: "name"}
+		}
+	}
+	return name, nil
+}
+
+// nameTakenError answers a join with a name that's taken, suggesting one
+// that isn't.
+func (srv *Server) nameTakenError(r *http.Request, gameID, name string) *requestError {
+	return &requestError{
+		status:     http.StatusConflict,
+		Code:       codeNameTaken,
+		Message:    srv.tr(r, gameID, "name_taken", name),
+		Field:      "name",
+		Suggestion: srv.manager.AvailableName(gameID, name),
+	}
+}
@@ -0,0 +1,319 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"bzzz/internal/game"
+)
+
+// eventDataTypes maps the actions whose data has a type of its own to that
+// type, so the spec can describe them.
+var eventDataTypes = map[string]interface{}{
+	"buzz":              buzzEvent{},
+	"score":             scoreEvent{},
+	"score_correction":  scoreCorrectionEvent{},
+	"dashboard_games":   dashboardGamesEvent{},
+	hostUndo:            game.UndoEvent{},
+	playerReacted:       reactEvent{},
+	serverClock:         clockEvent{},
+	stateChanged:        stateEvent{},
+	captainChanged:      captainEvent{},
+	teamJoinRequested:   teamMemberEvent{},
+	teamMemberAccepted:  teamMemberEvent{},
+	teamMemberRemoved:   teamMemberEvent{},
+	timeoutRequested:    timeoutEvent{},
+	hostMarker:          markerEvent{},
+	buzzTie:             tieEvent{},
+	"score_reconciled":  scoreReconciledEvent{},
+	"summary":           summaryEvent{},
+	timerStart:          timerEvent{},
+	timerTick:           timerEvent{},
+	timerStop:           timerEvent{},
+	timerEnd:            timerEvent{},
+	gamePaused:          pauseEvent{},
+	gameResumed:         pauseEvent{},
+	gameStats:           statsEvent{},
+	questionReset:       questionResetEvent{},
+	gameResults:         resultsEvent{},
+	gameFarewell:        farewellEvent{},
+	gameRematch:         rematchEvent{},
+	hostMessage:         hostMessageEvent{},
+	targetLocked:        lockTargetEvent{},
+	targetUnlocked:      lockTargetEvent{},
+	cueAction:           cueEvent{},
+	pingAction:          pingEvent{},
+	hostTransferred:     transferEvent{},
+	playerWaiting:       waitingEvent{},
+	playerAdmitted:      waitingEvent{},
+	"resync":            resyncEvent{},
+	wagerOpen:           wagerOpenEvent{},
+	wagerSubmitted:      game.WagerEntry{},
+	wagerReceived:       game.WagerEntry{},
+	wagerClosed:         wagerClosedEvent{},
+	wagerReveal:         wagerRevealEvent{},
+	allPlayOpen:         allPlayOpenEvent{},
+	allPlayAnswer:       game.AllPlayEntry{},
+	allPlayReceived:     game.AllPlayEntry{},
+	allPlayClosed:       allPlayClosedEvent{},
+	allPlayReveal:       allPlayRevealEvent{},
+	boardUpdate:         game.BoardView{},
+	lateBuzz:            game.Buzz{},
+	buzzAlmost:          almostEvent{},
+	game.EarlyBuzz:      game.EarlyBuzzEvent{},
+	"host_joined":       game.HostConn{},
+	"host_left":         game.HostConn{},
+	game.PlayerLeft:     game.Presence{},
+	game.PlayerRejoined: game.Presence{},
+	game.PlayerStalled:  stalledEvent{},
+	practiceStart:       game.PracticeStatus{},
+	practiceBuzz:        game.PracticeBuzzEvent{},
+	practiceEnd:         game.PracticeStatus{},
+	"challenge_filed":   challenge{},
+	"round_start":       game.Round{},
+	"round_end":         game.Round{},
+}
+
+var pathParam = regexp.MustCompile(`{([^}]+)}`)
+
+// OpenAPIHandler serves an OpenAPI 3 document describing the API, built
+// from apiRoutes.
+func (srv *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	srv.openAPIOnce.Do(func() {
+		var err error
+		srv.openAPIDoc, err = json.MarshalIndent(openAPISpec(srv.apiRoutes()), "", "  ")
+		if err != nil {
+			log.Printf("failed to encode OpenAPI spec: %v", err)
+		}
+	})
+	if srv.openAPIDoc == nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode OpenAPI spec")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(srv.openAPIDoc)
+}
+
+// openAPISpec describes routes as an OpenAPI 3 document.
+func openAPISpec(routes []route) map[string]interface{} {
+	schemas := schemaSet{}
+	paths := map[string]map[string]interface{}{}
+	// operation IDs have to be unique, handlers serving several routes
+	// get a number on the end
+	used := map[string]int{}
+
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"summary": rt.Summary,
+		}
+
+		var params []map[string]interface{}
+		for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]string{"type": "string"},
+			})
+		}
+		for _, name := range rt.Query {
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "query", "schema": map[string]string{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		switch rt.Auth {
+		case authTenant:
+			op["security"] = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+		case authHost, authPastHost, authRecovery:
+			op["security"] = []map[string][]string{{"hostToken": {}}}
+		case authAdmin, authMetrics:
+			op["security"] = []map[string][]string{{"adminToken": {}}}
+		case authAccount:
+			op["security"] = []map[string][]string{{"accountKey": {}}}
+		case authPlayer:
+			op["security"] = []map[string][]string{{"playerToken": {}}}
+		}
+
+		if rt.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(rt.Request))},
+				},
+			}
+		}
+
+		ok := map[string]interface{}{"description": "OK"}
+		switch {
+		case rt.Stream:
+			ok["description"] = "a server sent event stream, each event named by its type, in the event schema version asked for with ?v="
+			ok["content"] = map[string]interface{}{
+				"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{
+					"oneOf": []interface{}{schemas.of(reflect.TypeOf(event{})), schemas.of(reflect.TypeOf(eventV2Envelope{}))},
+				}},
+			}
+		case rt.Response != nil:
+			ok["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(rt.Response))},
+			}
+		}
+		status := "200"
+		if len(rt.Methods) > 0 && rt.Methods[0] == "POST" && !strings.HasPrefix(rt.Path, "/api/lti/") {
+			status = "201"
+		}
+		op["responses"] = map[string]interface{}{
+			status: ok,
+			"default": map[string]interface{}{
+				"description": "an error, with a code to tell what went wrong",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(errorBody{}))},
+				},
+			},
+		}
+
+		if paths[rt.Path] == nil {
+			paths[rt.Path] = map[string]interface{}{}
+		}
+		for _, m := range rt.Methods {
+			methodOp := map[string]interface{}{}
+			for k, v := range op {
+				methodOp[k] = v
+			}
+			id := operationID(rt.Handler)
+			if used[id]++; used[id] > 1 {
+				id += strconv.Itoa(used[id])
+			}
+			methodOp["operationId"] = id
+			paths[rt.Path][strings.ToLower(m)] = methodOp
+		}
+	}
+
+	// the data of each kind of event, keyed by action
+	events := map[string]interface{}{}
+	for action, data := range eventDataTypes {
+		events[action] = schemas.of(reflect.TypeOf(data))
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "bzzz",
+			"version": strconv.Itoa(eventSchemaVersion),
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey":      map[string]string{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer":      map[string]string{"type": "http", "scheme": "bearer"},
+				"hostToken":   map[string]string{"type": "http", "scheme": "bearer", "description": "the host token, also accepted as X-Host-Token or ?hostToken="},
+				"adminToken":  map[string]string{"type": "http", "scheme": "bearer"},
+				"accountKey":  map[string]string{"type": "apiKey", "in": "header", "name": "X-Account-Key", "description": "a host account's API key"},
+				"playerToken": map[string]string{"type": "http", "scheme": "bearer", "description": "the player's token or session token, also accepted as X-Player-Token or ?token= or ?session="},
+			},
+		},
+		"x-event-data": events,
+	}
+}
+
+// operationID names an operation after its handler, HostLockHandler
+// becomes hostLock.
+func operationID(h http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	// handlers on Server are method values, named like (*Server).HostLockHandler-fm
+	name = strings.TrimSuffix(name, "-fm")
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "Handler")
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// schemaSet collects the named schemas referenced from the spec.
+type schemaSet map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+var rawJSONType = reflect.TypeOf(json.RawMessage{})
+
+// of returns the JSON schema for t. named structs are added to the set and
+// referenced.
+func (s schemaSet) of(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawJSONType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.of(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t == reflect.TypeOf(time.Duration(0)) {
+			return map[string]interface{}{"type": "integer", "description": "nanoseconds"}
+		}
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := s[name]; !ok {
+			// claim the name before recursing in case the type refers
+			// to itself
+			s[name] = nil
+			s[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	// interface{} and anything else could be any JSON value
+	return map[string]interface{}{}
+}
+
+// object describes a struct's JSON encoding, including the fields of any
+// struct it embeds.
+func (s schemaSet) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	s.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (s schemaSet) fields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			s.fields(f.Type, props)
+			continue
+		}
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.of(f.Type)
+	}
+}
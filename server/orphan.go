@@ -0,0 +1,171 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// orphaned game policies, picked per game with ?orphanPolicy= at creation
+const (
+	// orphanPause pauses the game and tells players the host is gone. the
+	// game ends if the host isn't back within the orphan grace period.
+	orphanPause = "pause"
+	// orphanEnd ends the game as soon as the host is considered lost.
+	orphanEnd = "end"
+	// orphanPromote hands the game to the designated backup player, or to
+	// the longest connected player when there is no backup. the game pauses
+	// if nobody is left to promote.
+	orphanPromote = "promote"
+)
+
+var orphanPolicies = map[string]bool{
+	orphanPause:   true,
+	orphanEnd:     true,
+	orphanPromote: true,
+}
+
+type orphan struct {
+	goneAt time.Time
+	lost   bool
+	// players already promoted during this absence that never took over
+	passedOver map[string]bool
+}
+
+// hostGone starts the clock on a game whose host stream went away. ended
+// games are already on their way out, so they're left alone.
+func (srv *Server) hostGone(gameID string) {
+	if srv.manager.Ended(gameID) {
+		return
+	}
+
+	srv.orphansMu.Lock()
+	defer srv.orphansMu.Unlock()
+
+	if _, ok := srv.orphans[gameID]; !ok {
+		srv.orphans[gameID] = &orphan{goneAt: time.Now()}
+	}
+}
+
+// hostBack clears a game's host absence, telling players if the game had
+// already been flagged as orphaned.
+func (srv *Server) hostBack(gameID string) {
+	srv.orphansMu.Lock()
+	o, ok := srv.orphans[gameID]
+	delete(srv.orphans, gameID)
+	srv.orphansMu.Unlock()
+
+	if ok && o.lost {
+		log.Printf("host returned to game %s", gameID)
+		srv.publish(transport.Message{GameID: gameID, Action: "host_returned"}, transport.ToPlayers)
+	}
+}
+
+// isOrphaned reports whether a game is currently paused waiting on its host.
+func (srv *Server) isOrphaned(gameID string) bool {
+	srv.orphansMu.Lock()
+	defer srv.orphansMu.Unlock()
+
+	o, ok := srv.orphans[gameID]
+	return ok && o.lost
+}
+
+// endGame tells players the game is over, which also tears it down. games
+// ended with finishGame have already had their stats and results sent.
+func (srv *Server) endGame(gameID string) {
+	srv.orphansMu.Lock()
+	delete(srv.orphans, gameID)
+	srv.orphansMu.Unlock()
+
+	if !srv.manager.Ended(gameID) {
+		srv.recordLeagueStats(gameID)
+		srv.publishStats(gameID)
+	}
+	srv.publish(transport.Message{GameID: gameID, Action: "disconnect"}, transport.ToPlayers)
+}
+
+// watchHosts applies each game's orphan policy once its host has been gone
+// for longer than hostLostAfter.
+func (srv *Server) watchHosts() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		var lost, expired []string
+
+		srv.orphansMu.Lock()
+		now := time.Now()
+		for gameID, o := range srv.orphans {
+			gone := now.Sub(o.goneAt)
+			switch {
+			case !o.lost && gone > srv.cfg.HostLostAfter:
+				o.lost = true
+				lost = append(lost, gameID)
+			case o.lost && gone > srv.cfg.HostLostAfter+srv.cfg.OrphanGrace:
+				expired = append(expired, gameID)
+			}
+		}
+		srv.orphansMu.Unlock()
+
+		for _, gameID := range lost {
+			opts, _ := srv.manager.Options(gameID)
+			policy := opts.OrphanPolicy
+
+			log.Printf("host lost for game %s, applying %s policy", gameID, policy)
+			switch policy {
+			case orphanEnd:
+				srv.finishGame(gameID, endedByHostLost)
+			case orphanPromote:
+				if !srv.promoteHost(gameID) {
+					srv.publish(transport.Message{GameID: gameID, Action: "host_lost"}, transport.ToPlayers)
+				}
+			default:
+				srv.publish(transport.Message{GameID: gameID, Action: "host_lost"}, transport.ToPlayers)
+			}
+		}
+
+		for _, gameID := range expired {
+			log.Printf("host never returned to game %s, ending it", gameID)
+			srv.finishGame(gameID, endedByHostLost)
+		}
+	}
+}
+
+// promoteHost announces a connected player as the game's new host. the
+// promoted player's client is expected to open the host stream, if it doesn't
+// within hostLostAfter the next candidate is tried. it returns false when
+// there is nobody to promote.
+func (srv *Server) promoteHost(gameID string) bool {
+	srv.orphansMu.Lock()
+	o, ok := srv.orphans[gameID]
+	if !ok {
+		srv.orphansMu.Unlock()
+		return false
+	}
+	if o.passedOver == nil {
+		o.passedOver = map[string]bool{}
+	}
+
+	candidate := srv.manager.PromotionCandidate(gameID, o.passedOver)
+	if candidate == "" {
+		srv.orphansMu.Unlock()
+		return false
+	}
+
+	// restart the clock so the promoted player gets the same window to take
+	// over as the original host had to come back
+	o.passedOver[candidate] = true
+	o.goneAt = time.Now()
+	o.lost = false
+	srv.orphansMu.Unlock()
+
+	log.Printf("promoting player %s to host of game %s", candidate, gameID)
+	srv.manager.SetPromoted(gameID, candidate)
+	srv.publish(transport.Message{GameID: gameID, PlayerID: candidate, Action: "host_promoted"}, transport.ToPlayers)
+	return true
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"sync"
+
+	"bzzz/internal/transport"
+)
+
+const outboxSize = 256
+
+// outbox keeps the most recent messages sent to a single client in a ring
+// buffer keyed by seq, so a client that reconnects can be caught up from the
+// last event it actually received regardless of which transport it uses.
+type outbox struct {
+	mu            sync.Mutex
+	buf           []transport.Message
+	next          int
+	count         int
+	evicted       int
+	lastDelivered int
+	// pushed is closed and replaced on every push, waking long polls
+	pushed chan struct{}
+}
+
+func newOutbox(size int) *outbox {
+	return &outbox{buf: make([]transport.Message, size), pushed: make(chan struct{})}
+}
+
+// push records a message headed to the client.
+func (o *outbox) push(msg transport.Message) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.count == len(o.buf) {
+		o.evicted = o.buf[o.next].Seq
+	}
+	o.buf[o.next] = msg
+	o.next = (o.next + 1) % len(o.buf)
+	if o.count < len(o.buf) {
+		o.count++
+	}
+	close(o.pushed)
+	o.pushed = make(chan struct{})
+}
+
+// changed returns a channel closed the next time a message is pushed. take
+// it before calling since so nothing pushed in between is missed.
+func (o *outbox) changed() <-chan struct{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.pushed
+}
+
+// since returns every retained message with a seq greater than seq, oldest
+// first. ok is false when messages after seq have already been evicted and
+// the client can't be caught up exactly.
+func (o *outbox) since(seq int) (msgs []transport.Message, ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	start := (o.next - o.count + len(o.buf)) % len(o.buf)
+	for n := 0; n < o.count; n++ {
+		msg := o.buf[(start+n)%len(o.buf)]
+		if msg.Seq > seq {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	// anything evicted after seq would leave a gap in the replay
+	return msgs, seq >= o.evicted
+}
+
+// delivered marks seq as written to the client.
+func (o *outbox) delivered(seq int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if seq > o.lastDelivered {
+		o.lastDelivered = seq
+	}
+}
+
+// lastSeq returns the last seq written to the client.
+func (o *outbox) lastSeq() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.lastDelivered
+}
+
+// outboxFor returns the outbox for a player, creating it on first use.
+func (srv *Server) outboxFor(playerID string) *outbox {
+	return srv.outboxSized(playerID, outboxSize)
+}
+
+// hostOutboxFor returns the outbox for a game's host stream, the backlog
+// a host that was away or fell behind is caught up from. it keeps
+// cfg.HostBacklog events and shares the outboxes map, player IDs never
+// contain a colon.
+func (srv *Server) hostOutboxFor(gameID string) *outbox {
+	return srv.outboxSized("host:"+gameID, srv.cfg.HostBacklog)
+}
+
+// outboxSized returns the outbox under key, creating it with room for size
+// messages on first use.
+func (srv *Server) outboxSized(key string, size int) *outbox {
+	srv.outboxesMu.Lock()
+	defer srv.outboxesMu.Unlock()
+
+	o, ok := srv.outboxes[key]
+	if !ok {
+		o = newOutbox(size)
+		srv.outboxes[key] = o
+	}
+	return o
+}
+
+// dropOutbox forgets a player's outbox.
+func (srv *Server) dropOutbox(playerID string) {
+	srv.outboxesMu.Lock()
+	defer srv.outboxesMu.Unlock()
+
+	delete(srv.outboxes, playerID)
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// OverlayHandler serves a game's stream overlay, a page with a transparent
+// background for adding to OBS or similar as a browser source. it follows
+// the game's spectator stream, showing who won the buzz, the top scores and
+// the running timer. ?top= sets how many scores show, 0 hides them, and
+// ?timer=0 hides the timer.
+func (srv *Server) OverlayHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+
+	page, err := appFiles.ReadFile("app/overlay.html")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to load overlay")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(page)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// pause events, sent to everyone.
+const (
+	gamePaused  = "game_paused"
+	gameResumed = "game_resumed"
+)
+
+// pauseEvent is the data of game_paused and game_resumed events.
+type pauseEvent struct {
+	PausedAt time.Time `json:"pausedAt"`
+	// TimerMs is what was left of the countdown running when the game was
+	// paused, which carries on from there when it's resumed
+	TimerMs int64 `json:"timerMs,omitempty"`
+}
+
+// paused turns a request away with a 409 while its game is paused or has
+// ended, for buzzes and anything that changes the scores. it reports
+// whether it did.
+func (srv *Server) paused(w http.ResponseWriter, r *http.Request, gameID string) bool {
+	if srv.manager.Ended(gameID) {
+		writeError(w, http.StatusConflict, codeGameEnded, srv.tr(r, gameID, "game_ended"))
+		return true
+	}
+	if !srv.manager.Paused(gameID) {
+		return false
+	}
+	writeError(w, http.StatusConflict, codeGamePaused, srv.tr(r, gameID, "game_paused"))
+	return true
+}
+
+// HostPauseHandler pauses a game, stopping any running countdown and
+// telling everyone.
+func (srv *Server) HostPauseHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	pausedAt, timerID, left, err := srv.manager.Pause(id)
+	switch err {
+	case nil:
+	case game.ErrAlreadyPaused:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	e := pauseEvent{PausedAt: pausedAt, TimerMs: left.Milliseconds()}
+	msgs := []transport.Message{{GameID: id, Action: gamePaused, Data: e}}
+	if timerID != 0 {
+		msgs = append(msgs, transport.Message{GameID: id, Action: timerStop, Data: timerEvent{TimerID: timerID}})
+	}
+	srv.publishTxn(msgs, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// HostResumeHandler resumes a paused game, restarting the countdown the
+// pause stopped with the time it had left.
+func (srv *Server) HostResumeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	pausedAt, left, autoLock, err := srv.manager.Resume(id)
+	switch err {
+	case nil:
+	case game.ErrNotPaused:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	msgs := []transport.Message{{GameID: id, Action: gameResumed, Data: pauseEvent{PausedAt: pausedAt, TimerMs: left.Milliseconds()}}}
+	if left > 0 {
+		timerID, endsAt, err := srv.manager.StartTimer(id, left, autoLock)
+		if err != nil {
+			writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+			return
+		}
+		srv.scheduleTimerEnd(id, timerID, left, autoLock)
+		msgs = append(msgs, transport.Message{GameID: id, Action: timerStart, Data: timerEvent{
+			TimerID:    timerID,
+			DurationMs: left.Milliseconds(),
+			EndsAt:     &endsAt,
+			AutoLock:   autoLock,
+		}})
+	}
+	srv.publishTxn(msgs, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+}
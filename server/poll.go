@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// long polling is the fallback transport for networks that buffer or block
+// both SSE and WebSockets. a poll is answered as soon as there are events
+// after ?since=, or with none once ?wait= runs out.
+const (
+	defaultPollWait = 25 * time.Second
+	// maxPollWait keeps a poll inside the 60 second idle timeout most
+	// proxies have
+	maxPollWait = 55 * time.Second
+	// pollSweepInterval is how often players who stopped polling are
+	// looked for
+	pollSweepInterval = 5 * time.Second
+)
+
+// pollResponse answers a long poll. the events are the same as a stream's.
+type pollResponse struct {
+	Events []interface{} `json:"events"`
+	// Seq is the since to send with the next poll
+	Seq int `json:"seq"`
+}
+
+// watchPollers tells hosts about players who stopped long polling, the way
+// a stream closing does.
+func (srv *Server) watchPollers() {
+	ticker := time.NewTicker(pollSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		for _, p := range srv.manager.StalePollers() {
+			srv.announceLeft(p.GameID, p.PlayerID)
+		}
+	}
+}
+
+// pollParams reads how long a poll may wait and the seq it wants events
+// after, since being fallback if it isn't given.
+func pollParams(r *http.Request, fallback int) (wait time.Duration, since int, err error) {
+	q := r.URL.Query()
+
+	wait = defaultPollWait
+	if raw := q.Get("wait"); raw != "" {
+		if wait, err = time.ParseDuration(raw); err != nil || wait < 0 {
+			return 0, 0, badRequest("wait", "bad wait [%s]", raw)
+		}
+		if wait > maxPollWait {
+			wait = maxPollWait
+		}
+	}
+
+	since = fallback
+	if raw := q.Get("since"); raw != "" {
+		if since, err = strconv.Atoi(raw); err != nil {
+			return 0, 0, badRequest("since", "bad since [%s]", raw)
+		}
+	}
+	return wait, since, nil
+}
+
+// longPoll waits up to wait for messages in out after since that keep
+// allows. exact is false when some have already been evicted and the
+// client has to resync.
+func longPoll(r *http.Request, out *outbox, since int, wait time.Duration, keep func(transport.Message) bool) (msgs []transport.Message, exact bool) {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		changed := out.changed()
+
+		var all []transport.Message
+		all, exact = out.since(since)
+		msgs = msgs[:0]
+		for _, msg := range all {
+			if keep(msg) {
+				msgs = append(msgs, msg)
+			}
+		}
+		if len(msgs) > 0 || !exact {
+			return msgs, exact
+		}
+
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return msgs, exact
+		case <-r.Context().Done():
+			return msgs, exact
+		}
+	}
+}
+
+// writePoll answers a poll with msgs in the given event schema version, led
+// by a resync when they couldn't be caught up exactly.
+func (srv *Server) writePoll(w http.ResponseWriter, gameID string, since int, msgs []transport.Message, exact bool, version int) {
+	resp := pollResponse{Events: []interface{}{}, Seq: since}
+	if !exact {
+		resp.Events = append(resp.Events, srv.versionedEvent(resyncMessage(gameID), version))
+	}
+	for _, msg := range msgs {
+		resp.Events = append(resp.Events, srv.versionedEvent(msg, version))
+		if msg.Seq > resp.Seq {
+			resp.Seq = msg.Seq
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("failed to encode poll for game %s: %v", gameID, err)
+	}
+}
+
+// PlayPollHandler long polls a player's events, for when neither SSE nor
+// WebSockets get through. the player joins first and polls with their
+// ?token= or ?session=, passing the seq from each answer as ?since= on the
+// next. polling keeps them connected as a stream would.
+func (srv *Server) PlayPollHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	// without either admitPlayer would make a new player on every poll
+	if r.URL.Query().Get("token") == "" && r.URL.Query().Get("session") == "" {
+		writeRequestError(w, badRequest("token", "token or session is required"))
+		return
+	}
+	p, _, ok := srv.admitPlayer(w, r, id)
+	if !ok {
+		return
+	}
+
+	out := srv.outboxFor(p.PlayerID)
+	wait, since, err := pollParams(r, out.lastSeq())
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	version, err := srv.eventVersion(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	// players in the waiting room just poll until they get a slot
+	arrival, err := srv.manager.Polled(id, p.PlayerID, time.Now().Add(wait))
+	if err == game.ErrGameNotFound {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+	if arrival != "" {
+		srv.publish(transport.Message{GameID: id, PlayerID: p.PlayerID, Action: arrival}, transport.ToHost)
+	}
+
+	msgs, exact := longPoll(r, out, since, wait, func(transport.Message) bool { return true })
+	for _, msg := range msgs {
+		out.delivered(msg.Seq)
+	}
+	srv.manager.Polled(id, p.PlayerID, time.Now())
+
+	srv.writePoll(w, id, since, msgs, exact, version)
+}
+
+// HostPollHandler long polls a game's host events, taking ?include= and
+// ?exclude= like the host stream. a host that only polls keeps the game
+// from being orphaned as long as it keeps polling.
+func (srv *Server) HostPollHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+	wait, since, err := pollParams(r, 0)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	version, err := srv.eventVersion(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	srv.hostBack(id)
+	filter := transport.ParseEventFilter(r.URL.Query())
+	msgs, exact := longPoll(r, srv.hostOutboxFor(id), since, wait, func(msg transport.Message) bool {
+		return msg.Action != heartbeatAction && (unfiltered(msg.Action) || filter.Allows(msg.Action))
+	})
+	// between polls the game has no host, unless one is streaming. the
+	// next poll comes back well within the orphan policy's patience.
+	if hosts, ok := srv.manager.Hosts(id); ok && len(hosts) == 0 {
+		srv.hostGone(id)
+	}
+
+	srv.writePoll(w, id, since, msgs, exact, version)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// practice mode events. practiceBuzz goes to the player who buzzed and the
+// host, the host's copy carrying the checklist.
+const (
+	practiceStart = "practice"
+	practiceBuzz  = "practice_buzz"
+	practiceEnd   = "practice_end"
+)
+
+// practiceBuzzIn answers a buzz made in practice mode, echoing it to the
+// player and the host. it returns false if practice mode is off.
+func (srv *Server) practiceBuzzIn(w http.ResponseWriter, r *http.Request, clientMsg buzzRequest, received time.Time) bool {
+	e, status, ok := srv.manager.PracticeBuzz(clientMsg.GameID, clientMsg.PlayerID, received)
+	if !ok {
+		return false
+	}
+	srv.publish(transport.Message{GameID: clientMsg.GameID, PlayerID: clientMsg.PlayerID, Action: practiceBuzz, Data: e, Private: true}, transport.ToPlayers)
+	e.Checklist = &status
+	srv.publish(transport.Message{GameID: clientMsg.GameID, PlayerID: clientMsg.PlayerID, Action: practiceBuzz, Data: e}, transport.ToHost)
+	srv.logBuzz(clientMsg, buzzPractice, game.Buzz{}, received)
+	writeBuzzResponse(w, http.StatusCreated, buzzResponse{Result: buzzPractice})
+	return true
+}
+
+// endPractice turns off practice mode if it's on, telling everyone. it's
+// called when the first round starts.
+func (srv *Server) endPractice(gameID string) {
+	status, err := srv.manager.EndPractice(gameID)
+	if err != nil {
+		return
+	}
+	srv.publish(transport.Message{GameID: gameID, Action: practiceEnd, Data: status}, transport.ToAll)
+}
+
+// HostPracticeHandler turns on practice mode before a game starts. players
+// can buzz as often as they like to test their devices, each buzz is
+// echoed back to them and the host and none of them count. it stays on
+// until the host ends it or starts the first round.
+func (srv *Server) HostPracticeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	status, err := srv.manager.StartPractice(id)
+	switch err {
+	case nil:
+	case game.ErrGameStarted:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, Action: practiceStart, Data: status}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostPracticeStatusHandler returns the practice checklist, who has tested
+// their device and who hasn't.
+func (srv *Server) HostPracticeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	status, err := srv.manager.Practice(id)
+	switch err {
+	case nil:
+	case game.ErrNoPractice:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostEndPracticeHandler turns off practice mode, going back to real
+// buzzes.
+func (srv *Server) HostEndPracticeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	status, err := srv.manager.EndPractice(id)
+	switch err {
+	case nil:
+	case game.ErrNoPractice:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, Action: practiceEnd, Data: status}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// stalledEvent is the data of a player_stalled event.
+type stalledEvent struct {
+	// Timeouts is how many writes in a row timed out
+	Timeouts  int   `json:"timeouts"`
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+// announceLeft tells the host a player's stream has gone.
+func (srv *Server) announceLeft(gameID, playerID string) {
+	p, ok := srv.manager.Player(playerID)
+	if !ok {
+		return
+	}
+	srv.publish(transport.Message{GameID: gameID, PlayerID: playerID, Action: game.PlayerLeft, Data: game.PresenceOf(p)}, transport.ToHost)
+}
+
+// GamePlayersHandler returns a game's live roster, who has joined and
+// whether they're connected right now.
+func (srv *Server) GamePlayersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	players, ok := srv.manager.Presence(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"players": players,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// HostPreviewHandler streams exactly what the game's players receive,
+// written the same way as a player stream, so hosts can check nothing is
+// reaching players that shouldn't.
+func (srv *Server) HostPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	done := r.Context().Done()
+
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, http.StatusInternalServerError, "", "Streaming unsupported!")
+		return
+	}
+
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	version, err := srv.eventVersion(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	q, err := srv.manager.AddPreview(id)
+	if err != nil {
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+	defer srv.manager.RemovePreview(id, q)
+
+	flusher, _ := srv.startEventStream(w)
+
+	hb := srv.startHeartbeat(q, func() { srv.manager.RemovePreview(id, q) })
+	defer hb.halt()
+
+	for {
+		msg, ok := q.NextOr(done)
+		if !ok {
+			return
+		}
+		if msg.Action == heartbeatAction {
+			if err := writeHeartbeat(w, flusher); err != nil {
+				return
+			}
+			hb.wrote()
+			continue
+		}
+		if err := srv.writePlayerEvent(w, flusher, msg, version); err != nil {
+			log.Println(err.Error())
+			return
+		}
+		hb.wrote()
+		if msg.Action == serverShutdown {
+			return
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// gameState is the read model served by GET /api/game/{id}/state. it's kept
+// up to date as events are delivered so a snapshot never has to be built on
+// request. Seq is the last event folded in, so a client resyncing applies
+// only events after it. Timer is only there while a countdown is running.
+type gameState struct {
+	GameID     string             `json:"gameID"`
+	Seq        int                `json:"seq"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+	Roster     []game.RosterEntry `json:"roster"`
+	Scoreboard []game.Standing    `json:"scoreboard"`
+	Question   questionState      `json:"question"`
+	Timer      *timerState        `json:"timer,omitempty"`
+	// PausedAt is set while the host has the game paused
+	PausedAt *time.Time `json:"pausedAt,omitempty"`
+	// State is the game's lifecycle state, see lifecycle
+	State string `json:"state"`
+}
+
+// timerState is a game's running countdown.
+type timerState struct {
+	ID     int       `json:"id"`
+	EndsAt time.Time `json:"endsAt"`
+}
+
+type questionState struct {
+	ID int `json:"id"`
+	// Text is set once a loaded question has been shown to players
+	Text   string `json:"text,omitempty"`
+	Round  int    `json:"round,omitempty"`
+	Locked bool   `json:"locked"`
+	// LockedPlayers and LockedTeams are locked out on their own
+	LockedPlayers []string    `json:"lockedPlayers,omitempty"`
+	LockedTeams   []string    `json:"lockedTeams,omitempty"`
+	Buzzes        []game.Buzz `json:"buzzes"`
+}
+
+// projection is a game's read model along with its encoded snapshot.
+type projection struct {
+	srv *Server
+
+	state    gameState
+	snapshot []byte
+}
+
+// projection sections and the actions that change them
+const (
+	projectRoster = 1 << iota
+	projectScoreboard
+	projectQuestion
+	projectTimer
+	projectPause
+
+	projectAll = projectRoster | projectScoreboard | projectQuestion | projectTimer | projectPause
+)
+
+var projectedActions = map[string]int{
+	"joined":            projectRoster,
+	game.PlayerLeft:     projectRoster,
+	game.PlayerRejoined: projectRoster,
+	"seats":             projectRoster,
+	teamMemberAccepted:  projectRoster,
+	teamMemberRemoved:   projectRoster,
+	"score":             projectScoreboard,
+	"score_reconciled":  projectScoreboard,
+	"score_correction":  projectScoreboard,
+	hostUndo:            projectRoster | projectScoreboard | projectQuestion,
+	"buzz":              projectQuestion,
+	"lock":              projectQuestion,
+	"unlock":            projectQuestion,
+	targetLocked:        projectQuestion,
+	targetUnlocked:      projectQuestion,
+	"reset":             projectQuestion,
+	questionReset:       projectQuestion,
+	"round_start":       projectQuestion,
+	"round_end":         projectQuestion,
+	lateBuzz:            projectQuestion,
+	questionShown:       projectQuestion,
+	timerStart:          projectTimer,
+	timerStop:           projectTimer,
+	timerEnd:            projectTimer,
+	gamePaused:          projectPause,
+	gameResumed:         projectPause,
+}
+
+// project folds a delivered event into its game's read model, refreshing
+// only the sections the event touches.
+func (srv *Server) project(msg transport.Message) {
+	srv.projectionsMu.Lock()
+	defer srv.projectionsMu.Unlock()
+
+	if msg.Action == "disconnect" && msg.PlayerID == "" {
+		delete(srv.projections, msg.GameID)
+		return
+	}
+
+	p, ok := srv.projections[msg.GameID]
+	if !ok {
+		// first event for this game on this replica
+		p = srv.buildProjection(msg.GameID)
+	} else if sections := projectedActions[msg.Action]; sections != 0 {
+		p.refresh(sections)
+	}
+	if msg.Seq > p.state.Seq {
+		p.state.Seq = msg.Seq
+	}
+	if msg.State != "" {
+		p.state.State = msg.State
+	}
+	p.encode()
+	srv.projections[msg.GameID] = p
+}
+
+// rebuildProjection throws a game's read model away and builds it again from
+// the game's current state, e.g. after a restart or when the shape of
+// gameState changes.
+func (srv *Server) rebuildProjection(gameID string) []byte {
+	srv.projectionsMu.Lock()
+	defer srv.projectionsMu.Unlock()
+
+	seq := 0
+	if old, ok := srv.projections[gameID]; ok {
+		seq = old.state.Seq
+	}
+
+	p := srv.buildProjection(gameID)
+	p.state.Seq = seq
+	p.encode()
+	srv.projections[gameID] = p
+	return p.snapshot
+}
+
+// resyncEvent is the data of a resync, sent to a stream that is too far
+// behind to be caught up event by event. the client fetches the snapshot at
+// State and applies only the events after its seq.
+type resyncEvent struct {
+	State string `json:"state"`
+}
+
+func resyncMessage(gameID string) transport.Message {
+	return transport.Message{GameID: gameID, Action: "resync", Data: resyncEvent{State: "/api/game/" + gameID + "/state"}}
+}
+
+// gameSnapshot returns the encoded read model for a game, building it if no
+// event has been projected yet.
+func (srv *Server) gameSnapshot(gameID string) []byte {
+	srv.projectionsMu.Lock()
+	p, ok := srv.projections[gameID]
+	srv.projectionsMu.Unlock()
+
+	if ok {
+		return p.snapshot
+	}
+	return srv.rebuildProjection(gameID)
+}
+
+func (srv *Server) buildProjection(gameID string) *projection {
+	p := &projection{srv: srv, state: gameState{GameID: gameID, State: srv.manager.State(gameID)}}
+	p.refresh(projectAll)
+	return p
+}
+
+func (p *projection) refresh(sections int) {
+	gameID := p.state.GameID
+
+	if sections&projectRoster != 0 {
+		players := p.srv.manager.Players(gameID)
+		roster := make([]game.RosterEntry, 0, len(players))
+		for _, pl := range players {
+			roster = append(roster, game.RosterEntry{
+				PlayerID:  pl.PlayerID,
+				Name:      pl.Name,
+				Seat:      pl.Seat,
+				Team:      pl.Team,
+				Avatar:    pl.Avatar,
+				Color:     pl.Color,
+				Connected: pl.Connected,
+			})
+		}
+		sort.Slice(roster, func(a, b int) bool { return roster[a].Name < roster[b].Name })
+		p.state.Roster = roster
+	}
+	if sections&projectScoreboard != 0 {
+		p.state.Scoreboard = p.srv.manager.Leaderboard(gameID)
+	}
+	if sections&projectQuestion != 0 {
+		questionID, round := p.srv.manager.QuestionTag(gameID)
+		lockedPlayers, lockedTeams := p.srv.manager.LockedTargets(gameID)
+		p.state.Question = questionState{
+			ID:            questionID,
+			Text:          p.srv.manager.ShownQuestion(gameID).Text,
+			Round:         round,
+			Locked:        p.srv.manager.Locked(gameID),
+			LockedPlayers: lockedPlayers,
+			LockedTeams:   lockedTeams,
+			Buzzes:        p.srv.manager.BuzzOrder(gameID),
+		}
+	}
+	if sections&projectTimer != 0 {
+		p.state.Timer = nil
+		if timerID, endsAt, ok := p.srv.manager.Timer(gameID); ok {
+			p.state.Timer = &timerState{ID: timerID, EndsAt: endsAt}
+		}
+	}
+	if sections&projectPause != 0 {
+		p.state.PausedAt = nil
+		if pausedAt, ok := p.srv.manager.PausedAt(gameID); ok {
+			p.state.PausedAt = &pausedAt
+		}
+	}
+	p.state.UpdatedAt = time.Now()
+}
+
+func (p *projection) encode() {
+	b, err := json.Marshal(p.state)
+	if err != nil {
+		log.Printf("failed to encode state for game %s: %v", p.state.GameID, err)
+		return
+	}
+	p.snapshot = append(b, '\n')
+}
+
+// GameStateHandler returns a snapshot of a game's roster, scoreboard,
+// current question and running timer.
+func (srv *Server) GameStateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(srv.gameSnapshot(id))
+}
+
+// HostRebuildStateHandler rebuilds a game's read model from scratch.
+func (srv *Server) HostRebuildStateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(srv.rebuildProjection(id))
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bzzz/internal/game"
+)
+
+// maxGameNameLength bounds the name a public game is listed under, in runes
+const maxGameNameLength = 60
+
+// how many public games are listed, unless ?limit= asks for fewer
+const (
+	defaultPublicGames = 50
+	maxPublicGames     = 200
+)
+
+// gameName tidies up the name a host gave their game.
+func gameName(raw string) (string, error) {
+	name := strings.Join(strings.Fields(raw), " ")
+	if len([]rune(name)) > maxGameNameLength {
+		return "", badRequest("name", "game names can be at most %d characters", maxGameNameLength)
+	}
+	return name, nil
+}
+
+// PublicGamesHandler lists the public games players can join without being
+// given a code. ?limit= caps how many come back.
+func (srv *Server) PublicGamesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	limit := defaultPublicGames
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeRequestError(w, badRequest("limit", "limit must be a positive number"))
+			return
+		}
+		if n < maxPublicGames {
+			limit = n
+		} else {
+			limit = maxPublicGames
+		}
+	}
+
+	games := srv.manager.PublicGames()
+	for i := range games {
+		if srv.isOrphaned(games[i].GameID) {
+			games[i].Status = game.PublicPaused
+		}
+	}
+	if len(games) > limit {
+		games = games[:limit]
+	}
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"games": games})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
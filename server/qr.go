@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bzzz/internal/game"
+	"bzzz/internal/qr"
+
+	"github.com/gorilla/mux"
+)
+
+// how big a QR code's PNG is drawn, in pixels to a module. the default
+// fills about a third of a 1080p projector at the usual versions.
+const (
+	defaultQRScale = 8
+	maxQRScale     = 32
+)
+
+// joinLink is how a game's players get to its join page.
+type joinLink struct {
+	GameCode string `json:"gameCode"`
+	// JoinURL is the join page in the requester's locale, ShortURL a short
+	// link redirecting to it in each player's own, and URL the one the QR
+	// code holds
+	JoinURL  string `json:"joinURL"`
+	ShortURL string `json:"shortURL,omitempty"`
+	URL      string `json:"url"`
+}
+
+// shortJoinURL is a short link to a game's join page, for QR codes and
+// reading out. the short join URL setting is a template like
+// "https://bz.zz/{code}" for deployments with a short domain pointed at
+// /j/{code}, without it the link is /j/{code} on the server that took the
+// request. it's empty when there's no join page to go to.
+func (srv *Server) shortJoinURL(r *http.Request, gameCode string) string {
+	if tmpl := srv.cfg.ShortJoinURL; tmpl != "" {
+		return strings.Replace(tmpl, "{code}", gameCode, -1)
+	}
+	if srv.cfg.JoinURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/j/%s", requestBaseURL(r), gameCode)
+}
+
+// gameJoinLink returns the links to a game's join page. the QR code holds
+// the short link when there is one, it's quicker to scan from the back of
+// the room.
+func (srv *Server) gameJoinLink(r *http.Request, gameCode string) joinLink {
+	link := joinLink{GameCode: gameCode, JoinURL: srv.joinURL(r, gameCode), ShortURL: srv.shortJoinURL(r, gameCode)}
+	link.URL = link.JoinURL
+	if link.ShortURL != "" {
+		link.URL = link.ShortURL
+	}
+	return link
+}
+
+// GameQRHandler returns a QR code of a game's join link, for the host to
+// put up on a projector, so every frontend needn't bundle a QR library. it's
+// a PNG unless format asks for svg, or json for just the links. the links
+// also come in a Link header with the image.
+func (srv *Server) GameQRHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "png" && format != "svg" && format != "json" {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("unknown format %q, png, svg or json", format))
+		return
+	}
+	scale := defaultQRScale
+	if s := r.URL.Query().Get("scale"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxQRScale {
+			writeError(w, http.StatusBadRequest, "", fmt.Sprintf("scale must be 1 to %d pixels to a module", maxQRScale))
+			return
+		}
+		scale = n
+	}
+
+	if !srv.manager.Exists(id) {
+		writeErr(w, http.StatusNotFound, game.ErrGameNotFound)
+		return
+	}
+	link := srv.gameJoinLink(r, id)
+
+	if format == "json" {
+		err := json.NewEncoder(w).Encode(link)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+			return
+		}
+		return
+	}
+
+	code, err := qr.Encode(link.URL)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", fmt.Sprintf("failed to encode QR code: %v", err))
+		return
+	}
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"alternate\"", link.JoinURL))
+	if link.ShortURL != "" {
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"shortlink\"", link.ShortURL))
+	}
+	// the join link changes with the locale the request asked for
+	w.Header().Set("Vary", "Accept-Language")
+
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, code.SVG())
+		return
+	}
+	b, err := code.PNG(scale)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", fmt.Sprintf("failed to draw QR code: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(b)
+}
+
+// ShortJoinHandler sends a short join link on to the game's join page, in
+// the language of the player's browser.
+func (srv *Server) ShortJoinHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if srv.cfg.JoinURL == "" {
+		writeError(w, http.StatusNotFound, "", "this server has no join page")
+		return
+	}
+	if !srv.manager.Exists(id) {
+		writeErr(w, http.StatusNotFound, game.ErrGameNotFound)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept-Language")
+	http.Redirect(w, r, srv.joinURL(r, id), http.StatusFound)
+}
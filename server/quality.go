@@ -0,0 +1,96 @@
+package server
+
+import (
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// connectionQuality is sent to a player and their host every quality
+// interval so a lagging player finds out before it costs them a buzz.
+const connectionQuality = "connection_quality"
+
+// quality levels, worst last
+const (
+	qualityGood = "good"
+	qualityFair = "fair"
+	qualityPoor = "poor"
+)
+
+// delivery lag beyond these is fair or poor. lag only counts while
+// messages are waiting, an idle stream isn't a slow one.
+const (
+	fairLag = 500 * time.Millisecond
+	poorLag = 2 * time.Second
+)
+
+// qualityReport describes how far behind a player's stream is.
+type qualityReport struct {
+	Level      string  `json:"level"`
+	QueueDepth int     `json:"queueDepth"`
+	LagMs      float64 `json:"lagMs"`
+	// LastFlushMs is how long ago anything was last written to the stream
+	LastFlushMs float64 `json:"lastFlushMs"`
+	// RttMs is the player's smoothed round trip time, measured by pings in
+	// latency compensated games
+	RttMs float64 `json:"rttMs,omitempty"`
+}
+
+// measureQuality grades a stream from its queue depth and the time since it
+// last flushed.
+func measureQuality(depth int, sinceFlush time.Duration) qualityReport {
+	lag := time.Duration(0)
+	if depth > 0 {
+		lag = sinceFlush
+	}
+
+	level := qualityGood
+	switch {
+	case lag > poorLag || depth >= transport.LowQueueSize:
+		level = qualityPoor
+	case lag > fairLag || depth >= transport.LowQueueSize/4:
+		level = qualityFair
+	}
+
+	return qualityReport{
+		Level:       level,
+		QueueDepth:  depth,
+		LagMs:       float64(lag) / float64(time.Millisecond),
+		LastFlushMs: float64(sinceFlush) / float64(time.Millisecond),
+	}
+}
+
+// startQualityReports sends a player and the host a connection quality
+// report for the player's stream every quality interval until the stream's
+// heartbeat is halted. reports aren't sequenced, they describe the stream
+// rather than the game.
+func (srv *Server) startQualityReports(gameID, playerID string, q *transport.Queue, hb *heartbeat) {
+	if srv.cfg.QualityInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(srv.cfg.QualityInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hb.stop:
+				return
+			case <-ticker.C:
+			}
+
+			report := measureQuality(q.Depth(), hb.sinceWrite())
+			if rtt, ok := srv.measuredRTT(playerID); ok {
+				report.RttMs = float64(rtt) / float64(time.Millisecond)
+			}
+			msg := transport.Message{GameID: gameID, PlayerID: playerID, Action: connectionQuality, Data: report}
+
+			// never wait on a stream to tell it it's slow
+			q.Offer(msg)
+			for _, host := range srv.manager.HostQueues(gameID) {
+				host.Offer(msg)
+			}
+		}
+	}()
+}
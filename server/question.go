@@ -0,0 +1,194 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// maxQuestionsUpload caps the size of an uploaded question set.
+const maxQuestionsUpload = 1 << 20
+
+// questionShown is sent to everyone when the host moves on to a loaded
+// question. players get the text and points, never the answer.
+const questionShown = "question"
+
+// HostQuestionsHandler returns the questions loaded for a game, answers
+// included.
+func (srv *Server) HostQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"questions": srv.manager.Questions(id)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// parseQuestionsCSV reads questions from CSV with text, answer, points and
+// category columns. a header row naming them is skipped, all but the text
+// are optional.
+func parseQuestionsCSV(r io.Reader) ([]game.Question, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 && strings.EqualFold(strings.TrimSpace(rows[0][0]), "text") {
+		rows = rows[1:]
+	}
+
+	qs := make([]game.Question, 0, len(rows))
+	for i, row := range rows {
+		if len(row) > 4 {
+			return nil, game.ErrBadQuestionsCSV
+		}
+		q := game.Question{Text: strings.TrimSpace(row[0])}
+		if len(row) > 1 {
+			q.Answer = strings.TrimSpace(row[1])
+		}
+		if len(row) > 2 && strings.TrimSpace(row[2]) != "" {
+			points, err := strconv.Atoi(strings.TrimSpace(row[2]))
+			if err != nil {
+				return nil, fmt.Errorf("question %d has bad points [%s]", i+1, row[2])
+			}
+			q.Points = points
+		}
+		if len(row) > 3 {
+			q.Category = strings.TrimSpace(row[3])
+		}
+		qs = append(qs, q)
+	}
+	return qs, nil
+}
+
+// questionsRequest is a question set uploaded as JSON.
+type questionsRequest struct {
+	Questions []game.Question `json:"questions"`
+}
+
+// HostLoadQuestionsHandler replaces a game's questions with an uploaded
+// set, either JSON, {"questions": [{"text": "...", "answer": "...",
+// "points": 10, "category": "..."}]}, or CSV with the same four columns
+// when sent as text/csv.
+func (srv *Server) HostLoadQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var qs []game.Question
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		var err error
+		if qs, err = parseQuestionsCSV(r.Body); err != nil {
+			writeErr(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		var req questionsRequest
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		qs = req.Questions
+	}
+
+	err := srv.manager.LoadQuestions(id, qs)
+	if err == game.ErrGameNotFound {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"questions": len(qs)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostNextQuestionHandler moves on to the next loaded question, clearing
+// the buzzes like a reset, and pushes its text to everyone.
+func (srv *Server) HostNextQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	q, advanced, err := srv.manager.NextQuestion(id)
+	switch err {
+	case nil:
+	case game.ErrNoQuestions, game.ErrOutOfQuestions:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+
+	if advanced {
+		srv.publish(transport.Message{GameID: id, Action: "reset"}, transport.ToPlayers)
+		srv.advanceTurn(id)
+	}
+	srv.publish(srv.questionShownMessage(id, q), transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// questionShownMessage is the questionShown event for a question, without
+// its answer.
+func (srv *Server) questionShownMessage(gameID string, q game.Question) transport.Message {
+	_, round := srv.manager.QuestionTag(gameID)
+	shown := map[string]interface{}{
+		"id":     q.ID,
+		"text":   q.Text,
+		"points": q.Points,
+		"round":  round,
+	}
+	if q.Category != "" {
+		shown["category"] = q.Category
+	}
+	return transport.Message{GameID: gameID, Action: questionShown, Data: shown}
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"bzzz/internal/game"
+)
+
+// lowPriorityActions are informational and may be dropped for slow clients.
+// anything not listed here is treated as gameplay critical.
+var lowPriorityActions = map[string]bool{
+	"joined":            true,
+	game.PlayerLeft:     true,
+	game.PlayerRejoined: true,
+	// a heartbeat stuck behind a backlog is itself a sign of a slow client
+	heartbeatAction: true,
+	// the next tick is never more than a second away
+	timerTick: true,
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket per key, e.g. per IP or player, refilling
+// at rate tokens a second up to burst.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens     float64
+	refilledAt time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, buckets: map[string]*rateBucket{}}
+}
+
+// setUpRateLimits builds the limiters the config asks for.
+func (srv *Server) setUpRateLimits() {
+	srv.createLimiter, srv.buzzLimiter = nil, nil
+	if n := srv.cfg.CreateGameRate; n > 0 {
+		srv.createLimiter = newRateLimiter(float64(n)/60, float64(n))
+	}
+	if n := srv.cfg.BuzzRate; n > 0 {
+		srv.buzzLimiter = newRateLimiter(float64(n), float64(n))
+	}
+}
+
+// allow takes a token from key's bucket. when it's empty it returns false
+// and how long until the next token.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, refilledAt: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.refilledAt).Seconds()*l.rate)
+	b.refilledAt = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled, they're no different from a
+// fresh one.
+func (l *rateLimiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.refilledAt).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// sweepRateLimits keeps the limiters from holding on to every IP and player
+// they've ever seen.
+func (srv *Server) sweepRateLimits() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		for _, l := range []*rateLimiter{srv.createLimiter, srv.buzzLimiter} {
+			if l != nil {
+				l.sweep()
+			}
+		}
+	}
+}
+
+// allowRate checks key against l, answering 429 with a Retry-After if it's
+// over the limit. a nil limiter allows everything.
+func allowRate(w http.ResponseWriter, l *rateLimiter, key string) bool {
+	if l == nil {
+		return true
+	}
+	ok, wait := l.allow(key)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded, slow down")
+	}
+	return ok
+}
+
+// limitByIP wraps a handler so each client IP is held to l.
+func (srv *Server) limitByIP(l *rateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowRate(w, l, srv.clientIP(r)) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP is the address a request came from. behind a proxy that's the
+// proxy, unless it's trusted to say who it's forwarding for. the proxy
+// appends that to X-Forwarded-For, so only the last entry is taken, the
+// ones before it are whatever the client sent.
+func (srv *Server) clientIP(r *http.Request) string {
+	if srv.cfg.TrustForwardedFor {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,45 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"bzzz/internal/testserver"
+)
+
+// createGameFrom creates a game with X-Forwarded-For set to fwd, returning
+// the status it got.
+func createGameFrom(t *testing.T, ts *testserver.Server, fwd string) int {
+	t.Helper()
+
+	req, err := http.NewRequest("POST", ts.URL+"/api/host", nil)
+	if err != nil {
+		t.Fatalf("failed to create game: %v", err)
+	}
+	req.Header.Set("X-Forwarded-For", fwd)
+	resp, err := ts.Server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to create game: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// entries a client puts in X-Forwarded-For ahead of the proxy's don't get
+// it a fresh rate limit
+func TestSpoofedForwardedFor(t *testing.T) {
+	c := testserver.Config()
+	c.CreateGameRate = 1
+	c.TrustForwardedFor = true
+	ts := testserver.New(t, c)
+
+	if status := createGameFrom(t, ts, "1.1.1.1, 10.0.0.1"); status != http.StatusCreated {
+		t.Fatalf("first game got %d, want 201", status)
+	}
+	if status := createGameFrom(t, ts, "2.2.2.2, 10.0.0.1"); status != http.StatusTooManyRequests {
+		t.Fatalf("game with a spoofed entry got %d, want 429", status)
+	}
+	if status := createGameFrom(t, ts, "1.1.1.1, 10.0.0.2"); status != http.StatusCreated {
+		t.Fatalf("game from another client got %d, want 201", status)
+	}
+}
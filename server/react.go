@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/transport"
+)
+
+// playerReacted tells the host a player reacted, a signal of its own so
+// players can chime in between questions without touching the buzzer.
+const playerReacted = "reacted"
+
+// reactions are what a player can react with.
+var reactions = map[string]bool{
+	"hand_raise": true,
+	"thumbs_up":  true,
+	"laugh":      true,
+}
+
+// reactRequest is a player's reaction.
+type reactRequest struct {
+	PlayerID string `json:"playerID"`
+	Reaction string `json:"reaction"`
+}
+
+func (req *reactRequest) validate() error {
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	req.Reaction = strings.ToLower(strings.TrimSpace(req.Reaction))
+	if !reactions[req.Reaction] {
+		names := make([]string, 0, len(reactions))
+		for name := range reactions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return badRequest("reaction", "reaction must be one of %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// reactEvent is the data of a reacted event.
+type reactEvent struct {
+	PlayerName string `json:"playerName"`
+	Reaction   string `json:"reaction"`
+}
+
+// ReactHandler lets a player raise their hand or react to what's going on,
+// which only the host sees. reactions come out of the same flood budget as
+// buzzes, at half the cost.
+func (srv *Server) ReactHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req reactRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if !srv.allowFlood(w, r, id, req.PlayerID, "reaction") {
+		return
+	}
+
+	p, _ := srv.manager.Player(req.PlayerID)
+	ev := reactEvent{PlayerName: p.Name, Reaction: req.Reaction}
+	srv.publish(transport.Message{GameID: id, PlayerID: req.PlayerID, Action: playerReacted, Data: ev}, transport.ToHost)
+
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(ev)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// HostArmHandler arms a game's buzzers. everyone is told when, and buzzes
+// from then until the next question report how long the player took.
+func (srv *Server) HostArmHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	armedAt, err := srv.manager.Arm(id)
+	if err != nil {
+		writeErr(w, transitionStatus(err, http.StatusNotFound), err)
+		return
+	}
+
+	questionID, round := srv.manager.QuestionTag(id)
+	armed := map[string]interface{}{
+		"armedAt":    armedAt.Format(time.RFC3339Nano),
+		"questionID": questionID,
+		"round":      round,
+	}
+	srv.publish(transport.Message{GameID: id, Action: "armed", Data: armed}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(armed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostReactionsHandler returns every armed buzz in a game with each
+// player's reaction time stats.
+func (srv *Server) HostReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	buzzes := srv.manager.Reactions(id)
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"buzzes":  buzzes,
+		"players": game.SummarizeReactions(buzzes),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// reapInterval is how often games are checked for inactivity.
+const reapInterval = time.Minute
+
+// reapIdleGames expires idle games forever, e.g. those of a host who closed
+// the tab without their stream ever reporting the disconnect. anyone still
+// listening is told the game expired before it ends.
+func (srv *Server) reapIdleGames() {
+	if srv.cfg.GameIdleTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		for _, gameID := range srv.manager.IdleGames(srv.cfg.GameIdleTimeout) {
+			log.Printf("game %s idle for over %s, expiring it", gameID, srv.cfg.GameIdleTimeout)
+			srv.publish(transport.Message{GameID: gameID, Action: "game_expired"}, transport.ToAll)
+			srv.endGame(gameID)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// recentEventsMax caps how many events are kept per game however busy it
+// gets.
+const recentEventsMax = 128
+
+type recentEvent struct {
+	msg transport.Message
+	at  time.Time
+}
+
+// expireRecent drops events older than the TTL off the front of a tail.
+func (srv *Server) expireRecent(tail []recentEvent, now time.Time) []recentEvent {
+	i := 0
+	for i < len(tail) && now.Sub(tail[i].at) > srv.cfg.RecentEventsTTL {
+		i++
+	}
+	return tail[i:]
+}
+
+// rememberRecent adds an event sent to players to its game's tail.
+func (srv *Server) rememberRecent(msg transport.Message) {
+	if srv.cfg.RecentEventsTTL <= 0 || msg.Seq == 0 {
+		return
+	}
+
+	srv.recentMu.Lock()
+	defer srv.recentMu.Unlock()
+
+	now := time.Now()
+	tail := append(srv.expireRecent(srv.recentEvents[msg.GameID], now), recentEvent{msg: msg, at: now})
+	if len(tail) > recentEventsMax {
+		tail = tail[len(tail)-recentEventsMax:]
+	}
+	srv.recentEvents[msg.GameID] = tail
+}
+
+// recentFor returns a game's events from the last RecentEventsTTL, oldest
+// first, so a player joining mid question learns where things stand.
+func (srv *Server) recentFor(gameID string) []transport.Message {
+	srv.recentMu.Lock()
+	defer srv.recentMu.Unlock()
+
+	tail := srv.expireRecent(srv.recentEvents[gameID], time.Now())
+	if len(tail) == 0 {
+		delete(srv.recentEvents, gameID)
+		return nil
+	}
+	srv.recentEvents[gameID] = tail
+
+	msgs := make([]transport.Message, len(tail))
+	for i, e := range tail {
+		msgs[i] = e.msg
+	}
+	return msgs
+}
+
+// dropRecent forgets the tail of a game that has ended.
+func (srv *Server) dropRecent(gameID string) {
+	srv.recentMu.Lock()
+	defer srv.recentMu.Unlock()
+
+	delete(srv.recentEvents, gameID)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+	"bzzz/internal/transport"
+)
+
+// gameRematch tells the players of a game where its rematch is, each with
+// how to rejoin, and the host who was brought along.
+const gameRematch = "rematch"
+
+// endedByRematch is why a game ended when the host started a rematch of it.
+const endedByRematch = "rematch"
+
+// rematchTeardownTimeout caps how long a rematch under the same code waits
+// for the old game to be torn down before giving up.
+const rematchTeardownTimeout = 5 * time.Second
+
+// rematchRequest is the optional body of POST /host/{id}/rematch.
+type rematchRequest struct {
+	// SameCode keeps the game's code, tearing the old game down first.
+	// otherwise the rematch gets a new one
+	SameCode bool `json:"sameCode"`
+}
+
+// rematchPlayer is a player brought along to a rematch.
+type rematchPlayer struct {
+	PlayerID string `json:"playerID"`
+	Name     string `json:"name"`
+	Team     string `json:"team,omitempty"`
+}
+
+// rematchEvent is the data of a rematch event. players are each sent their
+// own PlayerID and Token in the new game, the host everyone's Players.
+type rematchEvent struct {
+	GameCode string          `json:"gameCode"`
+	SameCode bool            `json:"sameCode"`
+	PlayerID string          `json:"playerID,omitempty"`
+	Token    string          `json:"token,omitempty"`
+	Players  []rematchPlayer `json:"players,omitempty"`
+}
+
+// rematchResponse is what starting a rematch returns to the host.
+type rematchResponse struct {
+	GameCode      string          `json:"gameCode"`
+	HostToken     string          `json:"hostToken"`
+	SameCode      bool            `json:"sameCode"`
+	Players       []rematchPlayer `json:"players"`
+	WebhookSecret string          `json:"webhookSecret,omitempty"`
+}
+
+// waitTornDown waits for a game ended with endGame to be torn down, its
+// hub stopped and it deleted from the store, so its code can be used again.
+// it reports whether the game is gone.
+func (srv *Server) waitTornDown(gameID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !srv.manager.Exists(gameID) && !srv.hubRunning(gameID) && !srv.manager.Saving(gameID) {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return !srv.manager.Exists(gameID)
+}
+
+// rematchPlayers returns players as the host is shown them in a rematch.
+func rematchPlayers(players []game.Player) []rematchPlayer {
+	out := []rematchPlayer{}
+	for _, p := range players {
+		out = append(out, rematchPlayer{PlayerID: p.PlayerID, Name: p.Name, Team: p.Team})
+	}
+	return out
+}
+
+// announceRematch sends each player of a game a private rematch event with
+// how to rejoin in the rematch, and the host one with who is coming along.
+// players are matched up by name, which a game keeps unique.
+func (srv *Server) announceRematch(r *http.Request, gameID string, ev rematchEvent, from, to []game.Player) {
+	joined := map[string]game.Player{}
+	for _, p := range to {
+		joined[p.Name] = p
+	}
+
+	msgs := []transport.Message{}
+	for _, p := range from {
+		np, ok := joined[p.Name]
+		if !ok {
+			continue
+		}
+		pev := ev
+		pev.PlayerID, pev.Token = np.PlayerID, np.Token
+		msgs = append(msgs, transport.Message{GameID: gameID, PlayerID: p.PlayerID, Action: gameRematch, Data: pev, Private: true})
+	}
+	srv.publishTxn(msgs, transport.ToPlayers)
+
+	ev.Players = rematchPlayers(to)
+	srv.publish(traced(r.Context(), transport.Message{GameID: gameID, Action: gameRematch, Data: ev}), transport.ToHost)
+}
+
+// HostRematchHandler starts a rematch of a game: a fresh game with the same
+// settings, teams and players, all on zero points. players are sent a
+// rematch event with how to rejoin, so nobody has to enter a code again.
+// under a new code the old game ends as usual, its results up until it's
+// torn down. under the same code it's torn down first, players and host
+// keeping their tokens. questions aren't brought along, the host loads the
+// next set.
+func (srv *Server) HostRematchHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req rematchRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	src, err := srv.manager.RematchSource(id)
+	if err != nil {
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+
+	code := ""
+	if req.SameCode {
+		// the old game has to be gone before its code can be had again,
+		// so players hear about the rematch before it's torn down
+		code = id
+		srv.announceRematch(r, id, rematchEvent{GameCode: id, SameCode: true}, src.Players, src.Players)
+		srv.endGame(id)
+		if !srv.waitTornDown(id, rematchTeardownTimeout) {
+			writeError(w, http.StatusServiceUnavailable, "", fmt.Sprintf("game id [%s] is still being torn down", id))
+			return
+		}
+	}
+
+	gameCode, err := srv.manager.CreateGame(src.Options, code)
+	switch err {
+	case nil:
+	case game.ErrCodeTaken:
+		writeError(w, http.StatusConflict, codeGameCodeTaken, fmt.Sprintf("game code [%s] is taken", code))
+		return
+	case game.ErrAtGameLimit:
+		writeRequestError(w, srv.gameLimitError())
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	players, err := srv.manager.AddRematchPlayers(gameCode, src, req.SameCode)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if len(src.Options.Webhooks) > 0 {
+		srv.manager.SetWebhooks(gameCode, src.Options.Webhooks)
+	}
+	srv.scheduleGameEnd(gameCode)
+	srv.meter(src.Options.Tenant, store.Usage{GamesCreated: 1})
+	srv.publish(transport.Message{GameID: gameCode, Action: gameCreated}, transport.ToHost)
+
+	if !req.SameCode {
+		srv.announceRematch(r, id, rematchEvent{GameCode: gameCode}, src.Players, players)
+		if !srv.manager.Ended(id) {
+			if _, err := srv.finishGame(id, endedByRematch); err != nil {
+				log.Printf("failed to end game %s for its rematch: %v", id, err)
+			}
+		}
+	}
+
+	hostToken, _ := srv.manager.HostToken(gameCode)
+	resp := rematchResponse{GameCode: gameCode, HostToken: hostToken, SameCode: req.SameCode, Players: rematchPlayers(players)}
+	opts, _ := srv.manager.Options(gameCode)
+	if len(opts.Webhooks) > 0 {
+		resp.WebhookSecret = opts.Webhooks[0].Secret
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/store"
+	"bzzz/internal/transport"
+)
+
+// replayEnd closes a timed replay stream once every event has been sent.
+const replayEnd = "replay_end"
+
+const (
+	// maxReplayGap caps the wait between two events of a timed replay, so
+	// an intermission doesn't hold it up for minutes
+	maxReplayGap = 10 * time.Second
+	// maxReplaySpeed is the most a timed replay can be sped up
+	maxReplaySpeed = 16
+)
+
+// audienceName names an audience for a replay entry.
+func audienceName(to transport.Audience) string {
+	switch to {
+	case transport.ToAll:
+		return "all"
+	case transport.ToHost:
+		return "host"
+	default:
+		return "players"
+	}
+}
+
+// recordReplay appends the messages of a freshly sequenced envelope to
+// their game's replay. like recordHistory it runs on the replica that
+// sequenced the envelope.
+func (srv *Server) recordReplay(env transport.Envelope) {
+	if srv.cfg.HistoryRetention <= 0 || len(env.Msgs) == 0 || env.Msgs[0].GameID == "" {
+		return
+	}
+
+	gameID := env.Msgs[0].GameID
+	entries := make([]store.ReplayEntry, 0, len(env.Msgs))
+	for _, msg := range env.Msgs {
+		e := store.ReplayEntry{
+			HistoryEntry: store.HistoryEntry{Seq: msg.Seq, Txn: msg.Txn, At: env.SequencedAt, Action: msg.Action, PlayerID: msg.PlayerID},
+			To:           audienceName(env.To),
+			Private:      msg.Private,
+		}
+		if p, ok := srv.manager.Player(msg.PlayerID); ok {
+			e.PlayerName = p.Name
+			e.Team = p.Team
+		}
+		if msg.Data != nil {
+			data, err := json.Marshal(msg.Data)
+			if err != nil {
+				log.Printf("failed to encode %s for game %s replay: %v", msg.Action, gameID, err)
+			}
+			e.Data = data
+		}
+		entries = append(entries, e)
+	}
+
+	if err := srv.store.AppendReplay(gameID, entries); err != nil {
+		log.Printf("failed to record replay for game %s: %v", gameID, err)
+	}
+}
+
+// replayRange picks out the entries between the seqs from and to, either
+// being 0 for no limit.
+func replayRange(entries []store.ReplayEntry, from, to int) []store.ReplayEntry {
+	picked := make([]store.ReplayEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Seq >= from && (to == 0 || e.Seq <= to) {
+			picked = append(picked, e)
+		}
+	}
+	return picked
+}
+
+// replayParams reads the seq range and speed of a replay.
+func replayParams(r *http.Request) (from, to int, speed float64, err error) {
+	q := r.URL.Query()
+	seq := func(key string) (int, error) {
+		raw := q.Get(key)
+		if raw == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return 0, badRequest(key, "bad %s [%s]", key, raw)
+		}
+		return n, nil
+	}
+	if from, err = seq("from"); err != nil {
+		return 0, 0, 0, err
+	}
+	if to, err = seq("to"); err != nil {
+		return 0, 0, 0, err
+	}
+
+	speed = 1
+	if raw := q.Get("speed"); raw != "" {
+		speed, err = strconv.ParseFloat(raw, 64)
+		if err != nil || speed <= 0 || speed > maxReplaySpeed {
+			return 0, 0, 0, badRequest("speed", "speed must be above 0 and at most %d", maxReplaySpeed)
+		}
+	}
+	return from, to, speed, nil
+}
+
+// GameReplayHandler replays a game's events in order, for reviewing a round
+// or looking into a report of events arriving out of order. by default it
+// streams them as SSE with the time there was between them, sped up by
+// ?speed= and with long gaps cut short, each event carrying when it was
+// originally sent. ?mode=all returns them all at once as JSON instead.
+// ?from= and ?to= limit it to a range of seqs, such as a single round.
+// it takes the game's host token like the history, and keeps working
+// after the game has ended for as long as the history is retained.
+func (srv *Server) GameReplayHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// the history checks the host token
+	if _, ok := srv.loadHistory(w, r); !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	from, to, speed, err := replayParams(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	entries, err := srv.store.LoadReplay(id)
+	if err != nil {
+		log.Printf("failed to load replay for game %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "", "failed to load replay")
+		return
+	}
+	entries = replayRange(entries, from, to)
+
+	if r.URL.Query().Get("mode") == "all" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"events": entries}); err != nil {
+			log.Printf("failed to encode replay for game %s: %v", id, err)
+		}
+		return
+	}
+
+	flusher, ok := srv.startEventStream(w)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "", "streaming unsupported")
+		return
+	}
+	for i, e := range entries {
+		if i > 0 {
+			gap := e.At.Sub(entries[i-1].At)
+			if gap > maxReplayGap {
+				gap = maxReplayGap
+			}
+			select {
+			case <-time.After(time.Duration(float64(gap) / speed)):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("failed to encode %s for game %s replay: %v", e.Action, id, err)
+			continue
+		}
+		if err := writeSSE(w, flusher, e.Seq, e.Action, b); err != nil {
+			return
+		}
+	}
+	writeSSE(w, flusher, 0, replayEnd, []byte(fmt.Sprintf(`{"events":%d}`, len(entries))))
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// requestError is a request the server won't act on. it's written back as
+// JSON, {"code": "...", "message": "...", "details": {...}}, so clients can
+// tell what went wrong by its code and which part of the request was at
+// fault by the details.
+type requestError struct {
+	status int
+	// Code is one of the error codes, the status's generic one if it's ""
+	Code    string
+	Message string
+	// Field is the body field at fault, if it's down to one
+	Field string
+	// RetryAfterMs is how long until the request would be accepted, for
+	// requests turned away for being too soon
+	RetryAfterMs int64
+	// Suggestion is a value for Field that would be accepted, e.g. a free
+	// name when the one asked for is taken
+	Suggestion string
+	// Details are anything else about the error, written alongside the
+	// above
+	Details map[string]interface{}
+}
+
+// errorBody is a requestError as it's written back.
+type errorBody struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// body returns what's written back for e.
+func (e *requestError) body() errorBody {
+	b := errorBody{Code: e.Code, Message: e.Message}
+	if b.Code == "" {
+		b.Code = statusCode(e.status)
+	}
+	if e.Field != "" || e.RetryAfterMs > 0 || e.Suggestion != "" || len(e.Details) > 0 {
+		b.Details = map[string]interface{}{}
+		for k, v := range e.Details {
+			b.Details[k] = v
+		}
+		if e.Field != "" {
+			b.Details["field"] = e.Field
+		}
+		if e.RetryAfterMs > 0 {
+			b.Details["retryAfterMs"] = e.RetryAfterMs
+		}
+		if e.Suggestion != "" {
+			b.Details["suggestion"] = e.Suggestion
+		}
+	}
+	return b
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+// badRequest is a requestError for a malformed or invalid body.
+func badRequest(field, format string, args ...interface{}) *requestError {
+	return &requestError{status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...), Field: field}
+}
+
+// validator is a request body that can check itself once decoded.
+type validator interface {
+	validate() error
+}
+
+// decodeRequest reads a JSON request body into v, then validates it if v
+// knows how. the body has to be a single JSON value with no fields v
+// doesn't have, a typo'd field is an error rather than quietly left out.
+func decodeRequest(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	// only the end of the body can follow the value. reading up to it is
+	// also what finds a body running past its limit after a value that fit
+	if _, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return decodeError(err)
+		}
+		return badRequest("", "malformed JSON body: more than one value")
+	}
+	if val, ok := v.(validator); ok {
+		return val.validate()
+	}
+	return nil
+}
+
+// decodeError is the requestError for a body that couldn't be decoded.
+func decodeError(err error) *requestError {
+	// json and http only say which by their messages
+	msg := err.Error()
+	switch {
+	case msg == "http: request body too large":
+		return &requestError{status: http.StatusRequestEntityTooLarge, Code: codeBodyTooLarge, Message: "request body is too large"}
+	case strings.HasPrefix(msg, "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(msg, "json: unknown field "), `"`)
+		return &requestError{status: http.StatusBadRequest, Code: codeUnknownField, Message: fmt.Sprintf("unknown field [%s]", field), Field: field}
+	}
+	return badRequest("", "malformed JSON body: %v", err)
+}
+
+// limitBody caps the size of a route's request bodies at max bytes, or
+// cfg.MaxRequestBody when it's 0. reading past it fails, and the
+// connection is closed once the response is written.
+func (srv *Server) limitBody(max int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := max
+		if n == 0 {
+			n = int64(srv.cfg.MaxRequestBody)
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+		}
+		next(w, r)
+	}
+}
+
+// writeRequestError answers a request with err, which is a 500 unless it's
+// a requestError.
+func writeRequestError(w http.ResponseWriter, err error) {
+	re, ok := err.(*requestError)
+	if !ok {
+		re = &requestError{status: http.StatusInternalServerError, Code: errorCode(err), Message: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if re.RetryAfterMs > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(float64(re.RetryAfterMs)/1000))))
+	}
+	w.WriteHeader(re.status)
+	if err := json.NewEncoder(w).Encode(re.body()); err != nil {
+		log.Printf("failed to write request error: %v", err)
+	}
+}
+
+// checkPlayer makes sure a request's game exists, its player is in it and
+// it carries the player's token or session token before anything is done on
+// their behalf. player IDs are public, see GamePlayersHandler, so the ID
+// alone proves nothing.
+func (srv *Server) checkPlayer(r *http.Request, gameID, playerID string) error {
+	if err := srv.checkPlayerIn(r, gameID, playerID); err != nil {
+		return err
+	}
+	credential := playerCredentialFrom(r)
+	if credential == "" {
+		return &requestError{status: http.StatusUnauthorized, Code: codePlayerTokenRequired, Message: "player token or session required"}
+	}
+	if !srv.holdsPlayer(gameID, playerID, credential) {
+		log.Printf("rejected bad player credentials for %s in game %s", playerID, gameID)
+		return &requestError{status: http.StatusForbidden, Code: codeBadPlayerToken, Message: "token doesn't match this player", Field: "playerID"}
+	}
+	return nil
+}
+
+// checkPlayerIn makes sure a game exists and its player is in it, for
+// callers that already know who the player is, like an open stream or a
+// paired device.
+func (srv *Server) checkPlayerIn(r *http.Request, gameID, playerID string) error {
+	if !srv.manager.Exists(gameID) {
+		return &requestError{status: http.StatusNotFound, Code: codeGameNotFound, Message: srv.tr(r, "", "game_not_found", gameID), Field: "gameID"}
+	}
+	if !srv.manager.PlayerInGame(gameID, playerID) {
+		return &requestError{status: http.StatusNotFound, Code: codePlayerNotFound, Message: srv.tr(r, gameID, "player_not_found", playerID, gameID), Field: "playerID"}
+	}
+	return nil
+}
+
+// playerCredentialFrom pulls a player's token or session token off a
+// request, from an "Authorization: Bearer" or X-Player-Token header, or the
+// token or session query param.
+func playerCredentialFrom(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token := r.Header.Get("X-Player-Token"); token != "" {
+		return token
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("session")
+}
+
+// holdsPlayer reports whether credential, a player token or session token,
+// is playerID's in gameID.
+func (srv *Server) holdsPlayer(gameID, playerID, credential string) bool {
+	if p, ok := srv.manager.PlayerByToken(gameID, credential); ok {
+		return p.PlayerID == playerID
+	}
+	sessionGame, sessionPlayer, err := srv.parseSessionToken(credential)
+	return err == nil && sessionGame == gameID && sessionPlayer == playerID
+}
+
+// buzzRequest is a player buzzing in. Nonce is picked by the client so a
+// buzz sent more than once, e.g. over two transports, only counts once.
+type buzzRequest struct {
+	GameID   string `json:"gameID"`
+	PlayerID string `json:"playerID"`
+	Nonce    string `json:"nonce,omitempty"`
+	// QuestionID is the question the player is buzzing for, so a buzz
+	// still on its way when the host resets the question doesn't count
+	// for the next one. it's optional
+	QuestionID int `json:"questionID,omitempty"`
+}
+
+func (b buzzRequest) validate() error {
+	if b.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if len(b.Nonce) > maxNonceLength {
+		return badRequest("nonce", "nonce can be at most %d characters", maxNonceLength)
+	}
+	return nil
+}
+
+// backupRequest picks the player promoted if the host is lost.
+type backupRequest struct {
+	PlayerID string `json:"playerID"`
+}
+
+// ackRequest acknowledges the critical event at Seq.
+type ackRequest struct {
+	PlayerID string `json:"playerID"`
+	Seq      int    `json:"seq"`
+}
+
+func (a ackRequest) validate() error {
+	if a.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if a.Seq <= 0 {
+		return badRequest("seq", "seq must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"bzzz/client"
+	"bzzz/internal/testserver"
+)
+
+// buzzWithBody buzzes in as p with a body padded with spaces to size bytes,
+// returning the status it got.
+func buzzWithBody(t *testing.T, ts *testserver.Server, g *client.Game, p *client.Player, size int) int {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"playerID": %q}`, p.PlayerID)
+	body += strings.Repeat(" ", size-len(body))
+	req, err := http.NewRequest("POST", ts.URL+"/api/play/"+g.Code+"/buzz", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to buzz: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	resp, err := ts.Server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to buzz: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMaxRequestBody(t *testing.T) {
+	const limit = 256
+	c := testserver.Config()
+	c.MaxRequestBody = limit
+	ts := testserver.New(t, c)
+	g := ts.CreateGame(client.GameOptions{})
+	alice := ts.Join(g, "alice")
+
+	if status := buzzWithBody(t, ts, g, alice, limit+1); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("body over the limit got %d, want 413", status)
+	}
+	if status := buzzWithBody(t, ts, g, alice, limit); status != http.StatusCreated {
+		t.Fatalf("body at the limit got %d, want 201", status)
+	}
+}
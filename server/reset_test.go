@@ -0,0 +1,145 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bzzz/client"
+	"bzzz/internal/testserver"
+)
+
+// postActions applies host actions to a game through /actions.
+func postActions(t *testing.T, ts *testserver.Server, g *client.Game, body string) {
+	t.Helper()
+
+	req, err := http.NewRequest("POST", ts.URL+"/api/host/"+g.Code+"/actions", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to post actions: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+g.HostToken)
+	resp, err := ts.Server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to post actions: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("actions got %s, want 201", resp.Status)
+	}
+}
+
+// questionState is the question section of a game's state.
+type questionState struct {
+	ID     int               `json:"id"`
+	Locked bool              `json:"locked"`
+	Buzzes []json.RawMessage `json:"buzzes"`
+}
+
+// waitForQuestion waits for the game's state to be on question id.
+func waitForQuestion(t *testing.T, ts *testserver.Server, g *client.Game, id int) questionState {
+	t.Helper()
+
+	deadline := time.Now().Add(testserver.Timeout)
+	for {
+		st, err := ts.Client.State(context.Background(), g.Code)
+		if err != nil {
+			t.Fatalf("failed to get state: %v", err)
+		}
+		var q questionState
+		if err := json.Unmarshal(st.Question, &q); err != nil {
+			t.Fatalf("failed to decode question: %v", err)
+		}
+		if q.ID == id {
+			return q
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state on question %d, want %d", q.ID, id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestActionsResetSendsOneQuestionReset(t *testing.T) {
+	ts := testserver.New(t, nil)
+	g := ts.CreateGame(client.GameOptions{FirstBuzzWins: true})
+	host := ts.ListenHost(g)
+	alice := ts.Join(g, "alice")
+	aliceStream := ts.Listen(alice)
+
+	ts.MustBuzz(g, alice)
+	host.WaitFor("buzz")
+	postActions(t, ts, g, `{"actions": [{"action": "reset"}]}`)
+
+	var data struct {
+		QuestionID int `json:"questionID"`
+	}
+	host.WaitForData("question_reset", &data)
+	if data.QuestionID != 2 {
+		t.Fatalf("question_reset for question %d, want 2", data.QuestionID)
+	}
+	aliceStream.WaitFor("question_reset")
+	host.AssertNone("question_reset", 100*time.Millisecond)
+	for _, e := range host.Events() {
+		if e.Type == "reset" {
+			t.Fatal("host got a reset event alongside question_reset")
+		}
+	}
+
+	if q := waitForQuestion(t, ts, g, 2); q.Locked || len(q.Buzzes) != 0 {
+		t.Fatalf("question after reset is %+v, want unlocked with no buzzes", q)
+	}
+	ts.MustBuzz(g, alice)
+}
+
+// a buzz for a question racing its reset is either taken and then cleared
+// by the reset, or turned away as stale
+func TestResetRacingBuzz(t *testing.T) {
+	ts := testserver.New(t, nil)
+	for run := 0; run < 20; run++ {
+		g := ts.CreateGame(client.GameOptions{})
+		host := ts.ListenHost(g)
+		var players []*client.Player
+		for _, name := range []string{"alice", "bob", "carol"} {
+			players = append(players, ts.Join(g, name))
+		}
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for _, p := range players {
+			wg.Add(1)
+			go func(p *client.Player) {
+				defer wg.Done()
+				<-start
+				_, err := ts.Client.BuzzInFor(context.Background(), g.Code, p.PlayerID, p.Token, "", 1)
+				var cerr *client.Error
+				if err != nil && (!errors.As(err, &cerr) || cerr.Code != client.CodeStaleBuzz) {
+					t.Errorf("run %d: %s's buzz got %v", run, p.Name, err)
+				}
+			}(p)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := ts.Client.Reset(context.Background(), g.Code, g.HostToken); err != nil {
+				t.Errorf("run %d: failed to reset: %v", run, err)
+			}
+		}()
+		close(start)
+		wg.Wait()
+		if t.Failed() {
+			t.FailNow()
+		}
+
+		host.WaitFor("question_reset")
+		if q := waitForQuestion(t, ts, g, 2); q.Locked || len(q.Buzzes) != 0 {
+			t.Fatalf("run %d: question after reset is %+v, want unlocked with no buzzes", run, q)
+		}
+		host.Close()
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// scoreMessage is the score event sent to everyone whenever a game's scores
+// change, carrying the ledger entries behind the change and the new
+// leaderboard.
+func (srv *Server) scoreMessage(gameID string, entries []game.ScoreEntry) transport.Message {
+	playerID := ""
+	if len(entries) == 1 {
+		playerID = entries[0].PlayerID
+	}
+	return transport.Message{GameID: gameID, PlayerID: playerID, Action: "score", Data: scoreEvent{
+		Entries:     entries,
+		Leaderboard: srv.manager.Leaderboard(gameID),
+	}}
+}
+
+// GameScoresHandler returns a game's scoreboard.
+func (srv *Server) GameScoresHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"scores": srv.manager.Leaderboard(id)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostScoresHandler returns a game's score ledger and the leaderboard
+// computed from it.
+func (srv *Server) HostScoresHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"ledger":      srv.manager.ScoreLedger(id),
+		"leaderboard": srv.manager.Leaderboard(id),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// awardRequest is points the host gives or takes away.
+type awardRequest struct {
+	PlayerID string `json:"playerID"`
+	Points   int    `json:"points"`
+	Reason   string `json:"reason"`
+}
+
+// HostAwardHandler awards a player points, or deducts them when points is
+// negative, and broadcasts the new standings.
+func (srv *Server) HostAwardHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	var req awardRequest
+	err := decodeRequest(r, &req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	e, err := srv.manager.RecordScore(id, game.ScoreEntry{
+		Kind:     game.ScoreKindAward,
+		PlayerID: req.PlayerID,
+		Points:   req.Points,
+		Reason:   req.Reason,
+	})
+	if err != nil {
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+
+	srv.manager.PushUndo(id, game.UndoEntry{Action: game.UndoScore, PlayerID: e.PlayerID, Scores: []int{e.ID}})
+	srv.publish(srv.scoreMessage(id, []game.ScoreEntry{e}), transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(e)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// correctScoreRequest amends an earlier score entry.
+type correctScoreRequest struct {
+	PlayerID string `json:"playerID"`
+	Points   *int   `json:"points"`
+	Reason   string `json:"reason"`
+}
+
+// HostCorrectScoreHandler amends a past award or adjustment, e.g. when the
+// wrong player was credited or the points were mistyped. the original entry
+// is kept, a correction referencing it is appended to the ledger and a
+// score_reconciled event carries both along with the recomputed leaderboard.
+func (srv *Server) HostCorrectScoreHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	entryID, err := strconv.Atoi(params["scoreID"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("failed to convert score id [%s] to int", params["scoreID"]))
+		return
+	}
+
+	orig, ok := srv.manager.ScoreEntry(id, entryID)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeScoreNotFound, fmt.Sprintf("score entry [%d] not found in game [%s]", entryID, id))
+		return
+	}
+
+	// anything left out of the correction stays as it was
+	req := correctScoreRequest{PlayerID: orig.PlayerID}
+	err = decodeRequest(r, &req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	points := orig.Points
+	if req.Points != nil {
+		points = *req.Points
+	}
+
+	e, err := srv.manager.RecordScore(id, game.ScoreEntry{
+		Kind:     game.ScoreKindCorrection,
+		PlayerID: req.PlayerID,
+		Points:   points,
+		Reason:   req.Reason,
+		Corrects: orig.ID,
+	})
+	switch err {
+	case nil:
+	case game.ErrNotCorrectable:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+
+	srv.manager.PushUndo(id, game.UndoEntry{Action: game.UndoScore, PlayerID: e.PlayerID, Scores: []int{e.ID}})
+	srv.publishTxn([]transport.Message{
+		{GameID: id, Action: "score_reconciled", Data: scoreReconciledEvent{
+			Correction:  e,
+			Original:    orig,
+			Leaderboard: srv.manager.Leaderboard(id),
+		}},
+		srv.scoreMessage(id, []game.ScoreEntry{e}),
+	}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(e)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"math"
+
+	"bzzz/internal/game"
+)
+
+// maxRoundMultipliers caps how many rounds can have a multiplier of their
+// own.
+const maxRoundMultipliers = 20
+
+// validateScoring checks scoring rules a host sent.
+func validateScoring(s game.ScoringRules) error {
+	if s.Correct < 0 {
+		return badRequest("scoring", "correct can't be negative")
+	}
+	if s.Wrong < 0 {
+		return badRequest("scoring", "wrong is what a wrong answer costs and can't be negative")
+	}
+	if s.EarlyBuzz < 0 {
+		return badRequest("scoring", "earlyBuzz is what an early buzz costs and can't be negative")
+	}
+	if len(s.RoundMultipliers) > maxRoundMultipliers {
+		return badRequest("scoring", "at most %d round multipliers", maxRoundMultipliers)
+	}
+	for _, m := range s.RoundMultipliers {
+		if m <= 0 || math.IsInf(m, 0) {
+			return badRequest("scoring", "round multipliers must be above 0")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// seatRequest seats a player, or every player when Auto is set.
+type seatRequest struct {
+	PlayerID string `json:"playerID"`
+	Seat     int    `json:"seat"`
+	Auto     bool   `json:"auto"`
+}
+
+// HostSeatsHandler assigns seats/podiums to players so physical setups can
+// light the right podium. the body is either {"playerID": 1, "seat": 2} to
+// seat one player (seat 0 unseats them) or {"auto": true} to seat everyone
+// who doesn't have a seat yet.
+func (srv *Server) HostSeatsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	var req seatRequest
+	err := decodeRequest(r, &req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if req.Auto {
+		srv.manager.AutoSeat(id)
+	} else {
+		if req.Seat < 0 {
+			writeError(w, http.StatusBadRequest, "", "seat must be a positive number")
+			return
+		}
+		switch err := srv.manager.SetSeat(id, req.PlayerID, req.Seat); err {
+		case nil:
+		case game.ErrSeatTaken:
+			writeError(w, http.StatusConflict, codeSeatTaken, fmt.Sprintf("seat [%d] is taken", req.Seat))
+			return
+		default:
+			writeError(w, http.StatusNotFound, codePlayerNotFound, fmt.Sprintf("player [%s] not found in game [%s]", req.PlayerID, id))
+			return
+		}
+	}
+
+	seats := srv.manager.Seats(id)
+	srv.publish(transport.Message{GameID: id, Action: "seats", Data: seats}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"seats": seats})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
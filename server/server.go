@@ -0,0 +1,729 @@
+// Package server is a bzzz server: the HTTP API, its event streams and the
+// games behind them. cmd/bzzz serves it on the configured listeners, and it
+// can be embedded in another program or driven through Handler in tests
+// without binding sockets.
+package server
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"bzzz/config"
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+	"bzzz/internal/transport"
+)
+
+// Server is a bzzz server. everything it runs, its games, streams and
+// background jobs, hangs off it, so a process can run several.
+type Server struct {
+	// openStreams is how many streams this replica has open. gamesRefused
+	// and streamsRefused count what was turned away at cfg.MaxGames and
+	// cfg.MaxStreams. they're first so they stay 64 bit aligned for atomic
+	openStreams    int64
+	gamesRefused   int64
+	streamsRefused int64
+
+	cfg     *config.Config
+	handler http.Handler
+	servers []*http.Server
+	// devices is the hardware buzzer listener, if there is one
+	devices net.Listener
+	// done is closed on Shutdown, stopping the background jobs
+	done chan struct{}
+	// startedAt is when this replica started serving
+	startedAt time.Time
+	// openAPIDoc is the OpenAPI document, built on its first request
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+
+	// store is where games and everything kept about them are stored
+	store   store.Store
+	manager *game.Manager
+	broker  transport.Broker
+	// queues makes every stream's queue
+	queues *transport.Queues
+	// tracerProvider is set while spans are being exported
+	tracerProvider *sdktrace.TracerProvider
+
+	relayingMu sync.Mutex
+	// relaying is set while relay is taking envelopes off the broker
+	relaying bool
+
+	hubsMu sync.Mutex
+	// hubs maps gameID -> the hub running the game's events
+	hubs map[string]*hub
+
+	outboxesMu sync.Mutex
+	// outboxes maps playerID -> outbox
+	outboxes map[string]*outbox
+
+	acksMu sync.Mutex
+	// pendingAcks maps playerID -> event seq -> pending delivery
+	pendingAcks map[string]map[int]*pendingAck
+
+	recentMu sync.Mutex
+	// recentEvents maps gameID -> the events players got in the last
+	// RecentEventsTTL, oldest first
+	recentEvents map[string][]recentEvent
+
+	projectionsMu sync.Mutex
+	// projections maps gameID -> read model
+	projections map[string]*projection
+
+	timelinesMu sync.Mutex
+	// timelines maps gameID -> what happened in it, oldest first
+	timelines map[string]*timeline
+
+	tickersMu sync.Mutex
+	// tickers maps gameID -> stops the ticks of the game's running timer on
+	// this replica
+	tickers map[string]chan struct{}
+
+	orphansMu sync.Mutex
+	// orphans maps gameID -> host absence
+	orphans map[string]*orphan
+
+	dashboardsMu sync.Mutex
+	// dashboards maps accountID -> the dashboard queues open on this replica
+	dashboards map[string]map[*transport.Queue]bool
+
+	deviceConnsMu sync.Mutex
+	deviceConns   map[string]int
+
+	latenciesMu sync.Mutex
+	// latencies maps playerID -> their measured round trip time, while they
+	// have a stream open
+	latencies map[string]*playerLatency
+
+	floodMu sync.Mutex
+	// floods maps playerID -> flood budget
+	floods map[string]*floodState
+
+	challengesMu sync.Mutex
+	// rulings maps gameID -> seq -> ruling, challenges maps gameID -> challenge
+	// id -> challenge
+	rulings         map[string]map[int]ruling
+	challenges      map[string]map[int]*challenge
+	nextChallengeID int
+
+	chaosMu sync.Mutex
+	chaos   chaosSettings
+	// chaosRand picks jitter and drops, it isn't safe for concurrent use
+	// so it's only used with chaosMu held
+	chaosRand *rand.Rand
+
+	// limits on creating games per IP and buzzing per player, nil when off
+	createLimiter *rateLimiter
+	buzzLimiter   *rateLimiter
+
+	authenticator Authenticator
+	// sessionKey signs session tokens
+	sessionKey []byte
+	jwksMu     sync.Mutex
+	// jwksCache maps a JWKS url -> the keys last fetched from it
+	jwksCache map[string]*jwksEntry
+	// clientCAs are the CAs client certificates have to be signed by in
+	// mutual TLS, read from cfg.TLSClientCA and again whenever TLS is
+	// reloaded.
+	clientCAs struct {
+		mu   sync.RWMutex
+		pool *x509.CertPool
+	}
+
+	// tenants is loaded from the JSON file named by TENANTS_CONFIG, see
+	// loadTenants. without it the server runs single tenant with no quotas.
+	tenants map[string]*tenant
+	// tenantKeys maps API key -> tenant
+	tenantKeys map[string]*tenant
+	meterMu    sync.Mutex
+	// unflushed maps tenant -> period -> usage not yet written to the store
+	unflushed map[string]map[string]*store.Usage
+
+	// accountsMu serializes changes to accounts made on this replica, which
+	// are read, changed and written back whole.
+	accountsMu sync.Mutex
+
+	// moderators maps tenantID -> moderator, built as tenants are loaded. a
+	// nil moderator lets everything through.
+	moderators map[string]Moderator
+	// defaultModerator is used for games without a tenant, picked by the
+	// moderation setting
+	defaultModerator Moderator
+
+	deadLettersMu sync.Mutex
+	// deadLetters maps tenantID -> delivery ID -> delivery that ran out of
+	// attempts, see deadLetter
+	deadLetters map[string]map[string]*webhookDelivery
+
+	lti       ltiConfig
+	ltiKey    *rsa.PrivateKey
+	ltiMu     sync.Mutex
+	ltiStates map[string]*ltiState
+	// ltiGames maps gameID -> LMS context, ltiLinks maps resource link -> gameID
+	ltiGames map[string]*ltiGame
+	ltiLinks map[string]string
+}
+
+// New sets up a server from c, restoring any games that were in progress,
+// without opening any listeners.
+func New(c *config.Config) (*Server, error) {
+	srv := &Server{
+		cfg:             c,
+		done:            make(chan struct{}),
+		startedAt:       time.Now(),
+		hubs:            map[string]*hub{},
+		outboxes:        map[string]*outbox{},
+		pendingAcks:     map[string]map[int]*pendingAck{},
+		recentEvents:    map[string][]recentEvent{},
+		projections:     map[string]*projection{},
+		timelines:       map[string]*timeline{},
+		tickers:         map[string]chan struct{}{},
+		orphans:         map[string]*orphan{},
+		dashboards:      map[string]map[*transport.Queue]bool{},
+		deviceConns:     map[string]int{},
+		latencies:       map[string]*playerLatency{},
+		floods:          map[string]*floodState{},
+		rulings:         map[string]map[int]ruling{},
+		challenges:      map[string]map[int]*challenge{},
+		nextChallengeID: 1,
+		chaosRand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		jwksCache:       map[string]*jwksEntry{},
+		tenants:         map[string]*tenant{},
+		tenantKeys:      map[string]*tenant{},
+		unflushed:       map[string]map[string]*store.Usage{},
+		moderators:      map[string]Moderator{},
+		deadLetters:     map[string]map[string]*webhookDelivery{},
+		ltiStates:       map[string]*ltiState{},
+		ltiGames:        map[string]*ltiGame{},
+		ltiLinks:        map[string]string{},
+	}
+
+	var err error
+	srv.store, err = store.New(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up store: %v", err)
+	}
+	gameIDs, err := game.NewIDGenerator(srv.cfg, srv.cfg.GameIDStrategy, "game")
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up id generator: %v", err)
+	}
+	playerIDs, err := game.NewIDGenerator(srv.cfg, srv.cfg.PlayerIDStrategy, "player")
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up id generator: %v", err)
+	}
+	srv.queues = &transport.Queues{LowPriority: lowPriorityActions, Unfiltered: unfiltered}
+	srv.manager = game.NewManager(srv.cfg, srv.store, gameIDs, playerIDs, srv.queues)
+
+	srv.defaultModerator, err = newModerator(moderationConfig{Provider: srv.cfg.Moderation})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up moderation: %v", err)
+	}
+
+	if err := srv.setUpTracing(); err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %v", err)
+	}
+	srv.broker, err = transport.NewBroker(srv.cfg.Broker, srv.cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up broker: %v", err)
+	}
+	srv.authenticator, err = srv.newAuthenticator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up auth: %v", err)
+	}
+	if err := srv.setUpSessions(); err != nil {
+		return nil, fmt.Errorf("failed to set up sessions: %v", err)
+	}
+	srv.setUpRateLimits()
+
+	if path := srv.cfg.TenantsConfig; path != "" {
+		if err := srv.loadTenants(path); err != nil {
+			return nil, fmt.Errorf("failed to load tenants: %v", err)
+		}
+	}
+	if path := srv.cfg.LTIConfig; path != "" {
+		if err := srv.loadLTIConfig(path); err != nil {
+			return nil, fmt.Errorf("failed to load LTI config: %v", err)
+		}
+	}
+
+	// pick up games that were in progress before a restart. their hosts
+	// aren't connected yet, so they start out as gone
+	restored, err := srv.manager.Restore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore games: %v", err)
+	}
+	for _, gameID := range restored {
+		srv.hostGone(gameID)
+		srv.scheduleGameEnd(gameID)
+		srv.scheduleTeardown(gameID)
+		srv.rebuildProjection(gameID)
+	}
+
+	if srv.cfg.Chaos {
+		log.Printf("chaos is on, the admin API can disrupt streams. never run like this in production")
+	}
+
+	srv.handler = srv.corsHandler(srv.routes())
+	return srv, nil
+}
+
+// route is one API endpoint. everything besides its method, path and
+// handler only documents it, in the OpenAPI spec served at /openapi.json.
+type route struct {
+	Methods []string
+	Path    string
+	Handler http.HandlerFunc
+	Summary string
+	// Auth is who may call the route, see the auth constants
+	Auth string
+	// Query lists the query parameters the route reads
+	Query []string
+	// Request and Response are zero values of the JSON bodies, nil for
+	// none or none worth describing
+	Request  interface{}
+	Response interface{}
+	// Stream routes answer with an event stream of Events
+	Stream bool
+	// Held routes hold their connection open like Stream ones without
+	// being event streams, e.g. WebSockets. both count towards
+	// cfg.MaxStreams
+	Held bool
+	// Limit, if set, holds each client IP to a rate
+	Limit *rateLimiter
+	// MaxBody, if set, is the largest body the route takes in bytes, for
+	// the few that take more than cfg.MaxRequestBody
+	MaxBody int64
+}
+
+// who may call a route
+const (
+	authNone   = ""
+	authTenant = "tenant"
+	authHost   = "host"
+	authAdmin  = "admin"
+	// authAccount routes take a host account's key, see accountFor
+	authAccount = "account"
+	// authPastHost routes take the host token of a game that may have
+	// ended, so check it themselves
+	authPastHost = "past_host"
+	// authRecovery routes take the host token or the game's recovery PIN,
+	// so check them themselves
+	authRecovery = "recovery"
+	// authPlayer routes take the token or session token of the player
+	// they act for, see checkPlayer
+	authPlayer = "player"
+	// authMetrics routes take the admin token, except on listeners only
+	// the internal network reaches, see servedBy
+	authMetrics = "metrics"
+)
+
+// apiRoutes lists every API endpoint, in the order they're matched.
+func (srv *Server) apiRoutes() []route {
+	routes := []route{
+		{Methods: []string{"POST"}, Path: "/api/host", Handler: srv.HostCreateHandler, Auth: authTenant, Limit: srv.createLimiter,
+			Summary: "Create a game", Query: []string{"template", "locale", "orphanPolicy", "maxDuration", "teamLockout", "recognition", "turnTimeout", "firstBuzzWins", "armedBuzzing", "buzzCooldown", "latencyCompensation", "maxPlayers", "waitingRoom", "code", "recoveryPin", "public", "name"}},
+		{Methods: []string{"GET"}, Path: "/api/games/public", Handler: srv.PublicGamesHandler,
+			Summary: "List the public games that can be joined", Query: []string{"limit"}},
+		{Methods: []string{"GET"}, Path: "/api/host/dashboard", Handler: srv.HostDashboardHandler, Auth: authAccount, Stream: true,
+			Summary: "Open one stream of the events of every game the calling host account is running", Query: []string{"include", "events", "exclude", "v"}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}", Handler: srv.HostListenHandler, Auth: authHost, Stream: true,
+			Summary: "Open a host stream", Query: []string{"label", "clientID", "include", "events", "exclude", "v"}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/poll", Handler: srv.HostPollHandler, Auth: authHost, Held: true,
+			Summary: "Long poll the host events after a seq, for networks that block streams", Query: []string{"since", "wait", "include", "events", "exclude", "v"}, Response: pollResponse{}},
+		{Methods: []string{"PUT"}, Path: "/api/host/{id}/events", Handler: srv.HostEventsHandler, Auth: authHost,
+			Summary: "Change which events one of the game's host streams is sent", Request: hostEventsRequest{}, Response: transport.EventFilterSpec{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/hosts", Handler: srv.HostRosterHandler, Auth: authHost,
+			Summary: "List the host streams open on a game"},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/preview", Handler: srv.HostPreviewHandler, Auth: authHost, Stream: true,
+			Summary: "Preview what players are sent", Query: []string{"v"}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/reset", Handler: srv.HostResetHandler, Auth: authHost,
+			Summary: "Clear the buzzes and move to the next question"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/lock", Handler: srv.HostLockHandler, Auth: authHost,
+			Summary: "Lock the buzzers, everyone's or one player's or team's", Request: lockRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/unlock", Handler: srv.HostUnlockHandler, Auth: authHost,
+			Summary: "Unlock the buzzers, everyone's or one player's or team's", Request: lockRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/actions", Handler: srv.HostActionsHandler, Auth: authHost,
+			Summary: "Apply several host actions as one transaction", Request: hostActionsRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/transfer", Handler: srv.HostTransferHandler, Auth: authRecovery, Limit: srv.createLimiter,
+			Summary: "Issue a new host token to take over the game", Request: transferRequest{}, Response: transferResponse{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/backup", Handler: srv.HostBackupHandler, Auth: authHost,
+			Summary: "Pick the player promoted if the host is lost", Request: backupRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/seats", Handler: srv.HostSeatsHandler, Auth: authHost,
+			Summary: "Seat players", Request: seatRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/kick", Handler: srv.HostKickHandler, Auth: authHost,
+			Summary: "Remove a player", Request: kickRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/teams/captain", Handler: srv.HostCaptainHandler, Auth: authHost,
+			Summary: "Make a player their team's captain", Request: captainRequest{}, Response: captainEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/message", Handler: srv.HostMessageHandler, Auth: authHost,
+			Summary: "Send a message to every player, or just one", Request: hostMessageRequest{}, Response: hostMessageRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/turn/skip", Handler: srv.HostSkipTurnHandler, Auth: authHost,
+			Summary: "Skip the current turn"},
+		{Methods: []string{"POST", "PUT"}, Path: "/api/host/{id}/settings", Handler: srv.HostSettingsHandler, Auth: authHost,
+			Summary: "Change the game's settings", Request: settingsRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/arm", Handler: srv.HostArmHandler, Auth: authHost,
+			Summary: "Arm the buzzers for reaction timing"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/cue", Handler: srv.HostCueHandler, Auth: authHost,
+			Summary: "Send players a named sound or animation cue", Request: cueRequest{}, Response: cueEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/timer/start", Handler: srv.HostTimerStartHandler, Auth: authHost,
+			Summary: "Start a countdown", Request: timerStartRequest{}, Response: timerEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/timer/stop", Handler: srv.HostTimerStopHandler, Auth: authHost,
+			Summary: "Stop the countdown"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/pause", Handler: srv.HostPauseHandler, Auth: authHost,
+			Summary: "Pause the game, turning buzzes and score changes away"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/resume", Handler: srv.HostResumeHandler, Auth: authHost,
+			Summary: "Resume a paused game"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/end", Handler: srv.HostEndHandler, Auth: authHost,
+			Summary: "End the game, sending the final results before it's torn down", Response: resultsEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/rematch", Handler: srv.HostRematchHandler, Auth: authHost, Limit: srv.createLimiter,
+			Summary: "Start a rematch with the same players, teams and settings on zero points", Request: rematchRequest{}, Response: rematchResponse{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/reactions", Handler: srv.HostReactionsHandler, Auth: authHost,
+			Summary: "Get reaction time stats"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/soundcheck", Handler: srv.HostSoundcheckHandler, Auth: authHost,
+			Summary: "Start a soundcheck", Response: game.SoundcheckStatus{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/soundcheck/end", Handler: srv.HostEndSoundcheckHandler, Auth: authHost,
+			Summary: "End the soundcheck", Response: game.SoundcheckStatus{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/practice", Handler: srv.HostPracticeHandler, Auth: authHost,
+			Summary: "Turn on practice mode before the game starts", Response: game.PracticeStatus{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/practice", Handler: srv.HostPracticeStatusHandler, Auth: authHost,
+			Summary: "Get the practice checklist", Response: game.PracticeStatus{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/practice/end", Handler: srv.HostEndPracticeHandler, Auth: authHost,
+			Summary: "Turn off practice mode", Response: game.PracticeStatus{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/round/start", Handler: srv.HostRoundStartHandler, Auth: authHost,
+			Summary: "Start a round", Request: roundStartRequest{}, Response: game.Round{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/round/end", Handler: srv.HostRoundEndHandler, Auth: authHost,
+			Summary: "End the current round", Response: game.Round{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/rounds", Handler: srv.HostRoundsHandler, Auth: authHost,
+			Summary: "List the game's rounds"},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/questions", Handler: srv.HostQuestionsHandler, Auth: authHost,
+			Summary: "List the game's questions"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/questions", Handler: srv.HostLoadQuestionsHandler, Auth: authHost, MaxBody: maxQuestionsUpload,
+			Summary: "Upload a question set as JSON or CSV", Request: questionsRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/question/next", Handler: srv.HostNextQuestionHandler, Auth: authHost,
+			Summary: "Push the next question to players", Response: game.Question{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/challenges", Handler: srv.HostChallengesHandler, Auth: authHost,
+			Summary: "List challenges"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/challenges/{challengeID}", Handler: srv.HostResolveChallengeHandler, Auth: authHost,
+			Summary: "Rule on a challenge", Request: resolveChallengeRequest{}, Response: challenge{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/scores", Handler: srv.HostScoresHandler, Auth: authHost,
+			Summary: "Get the score ledger"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/scores", Handler: srv.HostAwardHandler, Auth: authHost,
+			Summary: "Award or deduct points", Request: awardRequest{}, Response: game.ScoreEntry{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/score", Handler: srv.HostAwardHandler, Auth: authHost,
+			Summary: "Award or deduct points", Request: awardRequest{}, Response: game.ScoreEntry{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/judge", Handler: srv.HostJudgeHandler, Auth: authHost,
+			Summary: "Judge the answer of the player with the floor", Request: judgeRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/scores/{scoreID}/correct", Handler: srv.HostCorrectScoreHandler, Auth: authHost,
+			Summary: "Correct an earlier score entry", Request: correctScoreRequest{}, Response: game.ScoreEntry{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/undo", Handler: srv.HostUndoHandler, Auth: authHost,
+			Summary: "Undo the host's most recent score change, judgment, kick or reset", Response: game.UndoEvent{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/undo", Handler: srv.HostUndoLogHandler, Auth: authHost,
+			Summary: "List the host actions that can still be undone"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/markers", Handler: srv.HostMarkerHandler, Auth: authHost,
+			Summary: "Mark a named point in the game's event log, for syncing video against it", Request: markerRequest{}, Response: markerEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/wager/open", Handler: srv.HostWagerOpenHandler, Auth: authHost,
+			Summary: "Open a wager round", Request: wagerOpenRequest{}, Response: game.WagerRound{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/wager/close", Handler: srv.HostWagerCloseHandler, Auth: authHost,
+			Summary: "Stop taking wagers", Response: game.WagerRound{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/wager/reveal", Handler: srv.HostWagerRevealHandler, Auth: authHost,
+			Summary: "Reveal and judge a player's wager", Request: wagerRevealRequest{}, Response: wagerRevealEvent{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/wager", Handler: srv.HostWagersHandler, Auth: authHost,
+			Summary: "Get the wager round with every wager", Response: game.WagerRound{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/allplay/open", Handler: srv.HostAllPlayOpenHandler, Auth: authHost,
+			Summary: "Open an all-play round everyone answers", Request: allPlayOpenRequest{}, Response: game.AllPlayRound{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/allplay/close", Handler: srv.HostAllPlayCloseHandler, Auth: authHost,
+			Summary: "Stop taking all-play answers", Response: game.AllPlayRound{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/allplay/reveal", Handler: srv.HostAllPlayRevealHandler, Auth: authHost,
+			Summary: "Reveal and judge every all-play answer at once", Request: allPlayRevealRequest{}, Response: allPlayRevealEvent{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/allplay", Handler: srv.HostAllPlayHandler, Auth: authHost,
+			Summary: "Get the all-play round with every answer", Response: game.AllPlayRound{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/board", Handler: srv.HostBoardHandler, Auth: authHost,
+			Summary: "Set up a board from the questions' categories", Request: boardStartRequest{}, Response: game.BoardView{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/pick", Handler: srv.HostPickHandler, Auth: authHost,
+			Summary: "Pick the next question off the board", Request: pickRequest{}, Response: game.Question{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/devices", Handler: srv.HostDevicesHandler, Auth: authHost,
+			Summary: "List the hardware buzzers paired with players"},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/devices", Handler: srv.HostPairDeviceHandler, Auth: authHost,
+			Summary: "Pair a hardware buzzer with a player", Request: pairDeviceRequest{}, Response: game.Device{}},
+		{Methods: []string{"DELETE"}, Path: "/api/host/{id}/devices/{device}", Handler: srv.HostUnpairDeviceHandler, Auth: authHost,
+			Summary: "Unpair a hardware buzzer"},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/history", Handler: srv.HostHistoryHandler, Auth: authPastHost,
+			Summary: "Get the game's history, also after it has ended", Response: store.GameHistory{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/history/export", Handler: srv.HostHistoryExportHandler, Auth: authPastHost,
+			Summary: "Download the game's history as JSON or CSV", Query: []string{"format"}, Response: store.GameHistory{}},
+		{Methods: []string{"GET"}, Path: "/api/host/{id}/buzzlog", Handler: srv.HostBuzzLogHandler, Auth: authPastHost,
+			Summary: "Download every buzz the game received, hash chained, as JSON or CSV", Query: []string{"format"}, Response: buzzLogResponse{}},
+		{Methods: []string{"POST"}, Path: "/api/host/{id}/state/rebuild", Handler: srv.HostRebuildStateHandler, Auth: authHost,
+			Summary: "Rebuild the game's state from its events", Response: gameState{}},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/replay", Handler: srv.GameReplayHandler, Auth: authPastHost, Held: true,
+			Summary: "Replay every event of a game, streamed with its original timing or all at once", Query: []string{"mode", "speed", "from", "to"}},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/lock", Handler: srv.LockStatusHandler,
+			Summary: "Check whether the buzzers are locked", Response: lockStatus{}},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/state", Handler: srv.GameStateHandler,
+			Summary: "Get the game's state", Response: gameState{}},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/board", Handler: srv.BoardHandler,
+			Summary: "Get the game's board", Response: game.BoardView{}},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/scores", Handler: srv.GameScoresHandler,
+			Summary: "Get the scoreboard"},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/players", Handler: srv.GamePlayersHandler,
+			Summary: "Get the live roster of who's connected"},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/teams", Handler: srv.GameTeamsHandler,
+			Summary: "Get the teams and their scores"},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/qr", Handler: srv.GameQRHandler,
+			Summary: "Get a QR code of the game's join link as a PNG or SVG, or the links themselves", Query: []string{"format", "scale"}, Response: joinLink{}},
+		{Methods: []string{"GET"}, Path: "/api/game/{id}/players/{playerID}/stats", Handler: srv.GamePlayerStatsHandler,
+			Summary: "Get a player's buzzes, wins, reaction time and answers", Response: game.PlayerStats{}},
+		{Methods: []string{"GET"}, Path: "/api/admin/games", Handler: srv.AdminGamesHandler, Auth: authAdmin,
+			Summary: "List every running game"},
+		{Methods: []string{"GET"}, Path: "/api/admin/games/{id}", Handler: srv.AdminGameHandler, Auth: authAdmin,
+			Summary: "Inspect a game"},
+		{Methods: []string{"POST"}, Path: "/api/admin/games/{id}/end", Handler: srv.AdminEndGameHandler, Auth: authAdmin,
+			Summary: "Force end a game"},
+		{Methods: []string{"GET"}, Path: "/api/admin/games/{id}/timeline", Handler: srv.AdminTimelineHandler, Auth: authAdmin,
+			Summary: "Get a game's timeline on this replica"},
+		{Methods: []string{"GET"}, Path: "/api/admin/stats", Handler: srv.AdminStatsHandler, Auth: authAdmin,
+			Summary: "Get server stats for this replica"},
+		{Methods: []string{"GET"}, Path: "/api/templates", Handler: srv.TemplatesHandler, Auth: authTenant,
+			Summary: "List the game templates saved for the tenant"},
+		{Methods: []string{"GET"}, Path: "/api/templates/{name}", Handler: srv.TemplateHandler, Auth: authTenant,
+			Summary: "Get a game template", Response: store.GameTemplate{}},
+		{Methods: []string{"PUT"}, Path: "/api/templates/{name}", Handler: srv.SaveTemplateHandler, Auth: authTenant, MaxBody: maxTemplateBody,
+			Summary: "Save a game template with settings, teams and questions to create games from", Request: store.GameTemplate{}, Response: store.GameTemplate{}},
+		{Methods: []string{"DELETE"}, Path: "/api/templates/{name}", Handler: srv.DeleteTemplateHandler, Auth: authTenant,
+			Summary: "Delete a game template"},
+		{Methods: []string{"POST"}, Path: "/api/accounts", Handler: srv.RegisterAccountHandler, Auth: authTenant, Limit: srv.createLimiter,
+			Summary: "Register a host account, getting its first API key", Request: registerRequest{}, Response: registerResponse{}},
+		{Methods: []string{"GET"}, Path: "/api/account", Handler: srv.AccountHandler, Auth: authAccount,
+			Summary: "Get the calling host account with its API keys and games", Response: store.HostAccount{}},
+		{Methods: []string{"POST"}, Path: "/api/account/keys", Handler: srv.AccountKeyCreateHandler, Auth: authAccount,
+			Summary: "Issue the calling host account another API key", Response: keyResponse{}},
+		{Methods: []string{"DELETE"}, Path: "/api/account/keys/{keyID}", Handler: srv.AccountKeyRevokeHandler, Auth: authAccount,
+			Summary: "Revoke one of the calling host account's API keys"},
+		{Methods: []string{"GET"}, Path: "/api/usage", Handler: srv.UsageHandler, Auth: authTenant,
+			Summary: "Get the tenant's usage this month"},
+		{Methods: []string{"GET"}, Path: "/api/stats/league", Handler: srv.LeagueStatsHandler, Auth: authTenant,
+			Summary: "Get league stats", Query: []string{"format"}},
+		{Methods: []string{"POST"}, Path: "/api/integrations/games", Handler: srv.IntegrationCreateHandler, Auth: authTenant, Limit: srv.createLimiter, MaxBody: maxQuestionsUpload,
+			Summary: "Create a game with its questions in one call", Request: integrationGameRequest{}},
+		{Methods: []string{"GET"}, Path: "/api/webhooks/dead-letters", Handler: srv.DeadLettersHandler, Auth: authTenant,
+			Summary: "List webhook deliveries that gave up"},
+		{Methods: []string{"POST"}, Path: "/api/webhooks/dead-letters/{endpointID}/{deliveryID}", Handler: srv.RedeliverHandler, Auth: authTenant,
+			Summary: "Retry a dead lettered webhook delivery"},
+		{Methods: []string{"GET"}, Path: "/api/watch/{id}", Handler: srv.WatchHandler, Stream: true,
+			Summary: "Watch a game's state as a spectator"},
+		{Methods: []string{"GET"}, Path: "/overlay/{id}", Handler: srv.OverlayHandler,
+			Summary: "Get a game's stream overlay, a transparent HTML page for OBS browser sources", Query: []string{"top", "timer"}},
+		{Methods: []string{"GET"}, Path: "/api/play/{id}", Handler: srv.PlayHandler, Stream: true,
+			Summary: "Open a player stream, joining unless a token or session is given", Query: []string{"token", "session", "name", "team", "avatar", "lastSeq", "include", "events", "exclude", "v"}},
+		{Methods: []string{"GET"}, Path: "/api/play/{id}/ws", Handler: srv.PlayWSHandler, Held: true,
+			Summary: "Open a player stream over WebSocket, which also takes buzzes", Query: []string{"token", "session", "name", "team", "avatar", "lastSeq", "include", "events", "exclude", "v"}},
+		{Methods: []string{"GET"}, Path: "/api/play/{id}/poll", Handler: srv.PlayPollHandler, Held: true,
+			Summary: "Long poll a player's events after a seq, for networks that block streams", Query: []string{"token", "session", "since", "wait", "v"}, Response: pollResponse{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/join", Handler: srv.JoinHandler,
+			Summary: "Join a game without opening a stream", Request: joinRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/buzz", Handler: srv.BuzzHandler, Auth: authPlayer,
+			Summary: "Buzz in", Request: buzzRequest{}, Response: buzzResponse{}},
+		{Methods: []string{"GET"}, Path: "/api/time", Handler: TimeHandler,
+			Summary: "Get the server's time, for a client working out its clock's offset from it", Query: []string{"t"}, Response: timeSync{}},
+		{Methods: []string{"GET"}, Path: "/api/play/{id}/ping", Handler: srv.PingHandler, Held: true,
+			Summary: "Measure latency to the server, answering once or as an event stream of pongs", Query: []string{"t", "count"}, Response: serverPing{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/pong", Handler: srv.PongHandler, Auth: authPlayer,
+			Summary: "Answer a latency ping", Request: pongRequest{}, Response: pongResponse{}},
+		{Methods: []string{"PUT"}, Path: "/api/play/{id}/events", Handler: srv.PlayerEventsHandler, Auth: authPlayer,
+			Summary: "Change which events a player's open stream is sent", Request: playerEventsRequest{}, Response: transport.EventFilterSpec{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/ack", Handler: srv.AckHandler, Auth: authPlayer,
+			Summary: "Acknowledge a critical event", Request: ackRequest{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/challenge", Handler: srv.ChallengeHandler, Auth: authPlayer,
+			Summary: "Challenge a ruling", Request: challengeRequest{}, Response: challenge{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/react", Handler: srv.ReactHandler, Auth: authPlayer,
+			Summary: "Raise a hand or react, which only the host sees", Request: reactRequest{}, Response: reactEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/answer", Handler: srv.AnswerHandler, Auth: authPlayer,
+			Summary: "Submit an answer after buzzing in", Request: answerRequest{}, Response: game.Answer{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/team/accept", Handler: srv.TeamAcceptHandler, Auth: authPlayer,
+			Summary: "Accept a player onto the captain's team", Request: teamMemberRequest{}, Response: teamMemberEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/team/remove", Handler: srv.TeamRemoveHandler, Auth: authPlayer,
+			Summary: "Take a player off the captain's team", Request: teamMemberRequest{}, Response: teamMemberEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/team/timeout", Handler: srv.TeamTimeoutHandler, Auth: authPlayer,
+			Summary: "Ask the host for a timeout as the team's captain", Request: timeoutRequest{}, Response: timeoutEvent{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/wager", Handler: srv.WagerHandler, Auth: authPlayer,
+			Summary: "Wager and answer in a wager round", Request: wagerRequest{}, Response: game.WagerEntry{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/allplay", Handler: srv.AllPlayHandler, Auth: authPlayer,
+			Summary: "Answer in an all-play round", Request: allPlayRequest{}, Response: game.AllPlayEntry{}},
+		{Methods: []string{"POST"}, Path: "/api/play/{id}/pick", Handler: srv.PickHandler, Auth: authPlayer,
+			Summary: "Pick the next question off the board, for the player with the pick", Request: pickRequest{}, Response: game.BoardView{}},
+		{Methods: []string{"GET"}, Path: "/j/{id}", Handler: srv.ShortJoinHandler,
+			Summary: "Redirect a short join link to the game's join page"},
+		{Methods: []string{"GET"}, Path: "/openapi.json", Handler: srv.OpenAPIHandler,
+			Summary: "Get this OpenAPI document"},
+		{Methods: []string{"GET"}, Path: "/healthz", Handler: HealthzHandler,
+			Summary: "Check the server is up, for liveness probes"},
+		{Methods: []string{"GET"}, Path: "/readyz", Handler: srv.ReadyzHandler,
+			Summary: "Check the server can take games, for readiness probes", Response: readyResponse{}},
+		{Methods: []string{"GET"}, Path: "/metrics", Handler: srv.MetricsHandler, Auth: authMetrics,
+			Summary: "Get this replica's stats in the OpenMetrics text format, for Prometheus. only private listeners serve it without the admin token"},
+	}
+
+	if srv.cfg.Chaos {
+		routes = append(routes,
+			route{Methods: []string{"GET"}, Path: "/api/admin/chaos", Handler: srv.AdminChaosHandler, Auth: authAdmin,
+				Summary: "Get the faults being injected into streams", Response: chaosSettings{}},
+			route{Methods: []string{"PUT"}, Path: "/api/admin/chaos", Handler: srv.AdminSetChaosHandler, Auth: authAdmin,
+				Summary: "Set the latency and dropped events injected into streams", Request: chaosSettings{}, Response: chaosSettings{}},
+			route{Methods: []string{"POST"}, Path: "/api/admin/chaos/reconnect", Handler: srv.AdminChaosReconnectHandler, Auth: authAdmin,
+				Summary: "Hang up a game's streams so their clients reconnect", Request: chaosReconnectRequest{}},
+		)
+	}
+	if srv.cfg.LTIConfig != "" {
+		routes = append(routes,
+			route{Methods: []string{"GET", "POST"}, Path: "/api/lti/login", Handler: srv.LTILoginHandler,
+				Summary: "Start an LTI launch"},
+			route{Methods: []string{"POST"}, Path: "/api/lti/launch", Handler: srv.LTILaunchHandler,
+				Summary: "Complete an LTI launch"},
+			route{Methods: []string{"GET"}, Path: "/api/host/{id}/lti/roster", Handler: srv.LTIRosterHandler, Auth: authHost,
+				Summary: "Get the course roster"},
+			route{Methods: []string{"POST"}, Path: "/api/host/{id}/lti/scores", Handler: srv.LTIScoresHandler, Auth: authHost,
+				Summary: "Post scores to the gradebook", Request: ltiScoresRequest{}},
+		)
+	}
+	return routes
+}
+
+// routes builds the API router.
+func (srv *Server) routes() *mux.Router {
+	r := mux.NewRouter()
+
+	for _, rt := range srv.apiRoutes() {
+		h := rt.Handler
+		// held connections such as WebSockets compress themselves, if at all
+		if !rt.Held {
+			h = srv.compressed(rt.Stream, h)
+		}
+		switch rt.Auth {
+		case authHost:
+			h = srv.requireHost(h)
+		case authAdmin:
+			h = srv.requireAdmin(h)
+		case authMetrics:
+			h = srv.requireAdminInPublic(h)
+		}
+		// the game has to be here before its host can be checked
+		if strings.Contains(rt.Path, "{id}") {
+			h = srv.loadGame(h)
+		}
+		if rt.Limit != nil {
+			h = srv.limitByIP(rt.Limit, h)
+		}
+		if !rt.Held {
+			h = srv.limitBody(rt.MaxBody, h)
+		}
+		if rt.Stream || rt.Held {
+			h = srv.limitStreams(h)
+		} else {
+			h = traceRoute(rt.Path, h)
+		}
+		r.HandleFunc(rt.Path, h).Methods(rt.Methods...)
+	}
+
+	if srv.cfg.WebApp {
+		r.Handle("/app", http.RedirectHandler("/app/", http.StatusMovedPermanently))
+		r.PathPrefix("/app/").Handler(appHandler())
+	}
+
+	// the rest of /api/ gets an error clients can read, not the file
+	// server's page
+	r.PathPrefix("/api/").HandlerFunc(apiNotFound)
+	r.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("./build"))))
+	return r
+}
+
+// Handler returns the server's HTTP handler, for serving it some other way
+// than through its configured listeners.
+func (srv *Server) Handler() http.Handler {
+	return srv.handler
+}
+
+// Start opens the configured listeners and starts the event pipeline and
+// background jobs. it returns once everything is running.
+func (srv *Server) Start() error {
+	if err := srv.StartBackground(); err != nil {
+		return err
+	}
+
+	var err error
+	if srv.servers, err = srv.serve(srv.handler); err != nil {
+		return err
+	}
+	if srv.cfg.DeviceAddr != "" {
+		srv.devices, err = srv.listenDevices()
+	}
+	return err
+}
+
+// StartBackground starts the event pipeline and background jobs without
+// opening any listeners, for serving Handler some other way, such as on an
+// httptest server.
+func (srv *Server) StartBackground() error {
+	events, err := srv.broker.Subscribe()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to broker: %v", err)
+	}
+
+	go srv.relay(events)
+	go srv.redeliverAcks()
+	go srv.watchHosts()
+	go srv.watchPollers()
+	go srv.flushUsage()
+	go srv.reapIdleGames()
+	go srv.expireTimelines()
+	go srv.expireDeadLetters()
+	go srv.sweepRateLimits()
+	return nil
+}
+
+// Shutdown tells every stream the server is going away, waits for them to
+// close until ctx is done and saves every game.
+func (srv *Server) Shutdown(ctx context.Context) {
+	srv.notifyShutdown()
+	close(srv.done)
+	if srv.devices != nil {
+		srv.devices.Close()
+	}
+
+	var wg sync.WaitGroup
+	for _, hs := range srv.servers {
+		wg.Add(1)
+		go func(hs *http.Server) {
+			defer wg.Done()
+			if err := hs.Shutdown(ctx); err != nil {
+				log.Printf("streams still open after %s, closing them: %v", srv.cfg.ShutdownTimeout, err)
+				hs.Close()
+			}
+		}(hs)
+	}
+	wg.Wait()
+
+	srv.flushUsageOnce()
+	srv.manager.SaveAll()
+	srv.manager.Close()
+	// a store with a file open, like sqlite, flushes and closes it
+	if c, ok := srv.store.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Printf("failed to close the store: %v", err)
+		}
+	}
+	srv.shutdownTracing(ctx)
+	log.Println("shut down")
+}
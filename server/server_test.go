@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bzzz/client"
+	"bzzz/internal/testserver"
+)
+
+func TestBuzzReachesHostAndPlayers(t *testing.T) {
+	ts := testserver.New(t, nil)
+	g := ts.CreateGame(client.GameOptions{})
+	host := ts.ListenHost(g)
+	alice := ts.Join(g, "alice")
+	bob := ts.Join(g, "bob")
+	bobStream := ts.Listen(bob)
+
+	ts.MustBuzz(g, alice)
+	if e := host.WaitFor("buzz"); e.PlayerID != alice.PlayerID {
+		t.Fatalf("host got a buzz from %s, want %s", e.PlayerID, alice.PlayerID)
+	}
+	if e := bobStream.WaitFor("buzz"); e.PlayerID != alice.PlayerID {
+		t.Fatalf("bob got a buzz from %s, want %s", e.PlayerID, alice.PlayerID)
+	}
+}
+
+func TestBuzzTwice(t *testing.T) {
+	ts := testserver.New(t, nil)
+	g := ts.CreateGame(client.GameOptions{})
+	alice := ts.Join(g, "alice")
+
+	ts.MustBuzz(g, alice)
+	err := ts.Buzz(g, alice)
+	var cerr *client.Error
+	if !errors.As(err, &cerr) || cerr.Code != client.CodeAlreadyBuzzed {
+		t.Fatalf("second buzz got %v, want %s", err, client.CodeAlreadyBuzzed)
+	}
+}
+
+func TestResetClearsBuzzes(t *testing.T) {
+	ts := testserver.New(t, nil)
+	g := ts.CreateGame(client.GameOptions{})
+	host := ts.ListenHost(g)
+	alice := ts.Join(g, "alice")
+
+	ts.MustBuzz(g, alice)
+	host.WaitFor("buzz")
+	if err := ts.Client.Reset(context.Background(), g.Code, g.HostToken); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	host.WaitFor("question_reset")
+
+	// the buzzers are open again for the next question
+	ts.MustBuzz(g, alice)
+	host.WaitFor("buzz")
+}
+
+// several servers run in one process without sharing games
+func TestServersSideBySide(t *testing.T) {
+	a := testserver.New(t, nil)
+	b := testserver.New(t, nil)
+
+	g := a.CreateGame(client.GameOptions{})
+	a.Join(g, "alice")
+
+	_, err := b.Client.State(context.Background(), g.Code)
+	var cerr *client.Error
+	if !errors.As(err, &cerr) || cerr.Code != client.CodeGameNotFound {
+		t.Fatalf("game %s on the other server got %v, want %s", g.Code, err, client.CodeGameNotFound)
+	}
+	if n := a.Stats().Games; n != 1 {
+		t.Fatalf("first server has %d games, want 1", n)
+	}
+	if n := b.Stats().Games; n != 0 {
+		t.Fatalf("second server has %d games, want 0", n)
+	}
+}
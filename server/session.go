@@ -0,0 +1,80 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// session tokens let a player whose stream dropped, say when their phone
+// locked, come back as the same player. they're signed rather than looked
+// up so any replica can check one, and expire SessionTTL after they were
+// issued. every new stream is handed a fresh one.
+
+var (
+	errSessionInvalid = errors.New("invalid session token")
+	errSessionExpired = errors.New("session token has expired")
+)
+
+// setUpSessions picks the key session tokens are signed with. without a
+// configured secret tokens only work on this replica until it restarts.
+func (srv *Server) setUpSessions() error {
+	if srv.cfg.SessionSecret != "" {
+		srv.sessionKey = []byte(srv.cfg.SessionSecret)
+		return nil
+	}
+	log.Println("no session secret configured, sessions won't survive a restart or work across replicas")
+	srv.sessionKey = make([]byte, 32)
+	_, err := rand.Read(srv.sessionKey)
+	return err
+}
+
+// newSessionToken issues a token resuming playerID in gameID.
+func (srv *Server) newSessionToken(gameID, playerID string) string {
+	expires := time.Now().Add(srv.cfg.SessionTTL).Unix()
+	payload := gameID + "\n" + playerID + "\n" + strconv.FormatInt(expires, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(srv.signSession(payload))
+}
+
+func (srv *Server) signSession(payload string) []byte {
+	mac := hmac.New(sha256.New, srv.sessionKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// parseSessionToken checks a session token, returning the game and player
+// it resumes.
+func (srv *Server) parseSessionToken(token string) (gameID, playerID string, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", "", errSessionInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", errSessionInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, srv.signSession(string(payload))) {
+		return "", "", errSessionInvalid
+	}
+
+	fields := strings.Split(string(payload), "\n")
+	if len(fields) != 3 {
+		return "", "", errSessionInvalid
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return "", "", errSessionInvalid
+	}
+	if time.Now().Unix() > expires {
+		return "", "", errSessionExpired
+	}
+	return fields[0], fields[1], nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"bzzz/config"
+	"bzzz/internal/game"
+)
+
+// newSessionServer returns a server signing session tokens that last ttl.
+func newSessionServer(t *testing.T, ttl time.Duration) *Server {
+	t.Helper()
+
+	c := config.Default()
+	c.Store = "memory"
+	c.Broker = "memory"
+	c.WebApp = false
+	c.SessionSecret = "session-secret"
+	c.SessionTTL = ttl
+	srv, err := New(c)
+	if err != nil {
+		t.Fatalf("failed to set up server: %v", err)
+	}
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	return srv
+}
+
+func TestSessionToken(t *testing.T) {
+	srv := newSessionServer(t, time.Hour)
+
+	token := srv.newSessionToken("ABCD", "alice")
+	gameID, playerID, err := srv.parseSessionToken(token)
+	if err != nil || gameID != "ABCD" || playerID != "alice" {
+		t.Fatalf("got %s, %s, %v, want ABCD, alice", gameID, playerID, err)
+	}
+
+	// claiming someone else under alice's MAC, or with one made up
+	sig := strings.Split(token, ".")[1]
+	other := base64.RawURLEncoding.EncodeToString([]byte("ABCD\nbob\n" + strings.Split(sessionPayload(t, token), "\n")[2]))
+	mac := hmac.New(sha256.New, []byte("guessed-secret"))
+	mac.Write([]byte(sessionPayload(t, token)))
+	for _, forged := range []string{
+		other + "." + sig,
+		strings.Split(token, ".")[0] + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)),
+		strings.Split(token, ".")[0],
+		token + "x",
+	} {
+		if _, _, err := srv.parseSessionToken(forged); err != errSessionInvalid {
+			t.Errorf("forged token %s got %v, want errSessionInvalid", forged, err)
+		}
+	}
+
+	// a token from another server's secret
+	elsewhere := newSessionServer(t, time.Hour)
+	elsewhere.sessionKey = []byte("another-secret")
+	if _, _, err := srv.parseSessionToken(elsewhere.newSessionToken("ABCD", "alice")); err != errSessionInvalid {
+		t.Errorf("token signed with another secret got %v, want errSessionInvalid", err)
+	}
+}
+
+// sessionPayload returns a session token's payload.
+func sessionPayload(t *testing.T, token string) string {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatalf("failed to decode token: %v", err)
+	}
+	return string(b)
+}
+
+func TestSessionTokenExpires(t *testing.T) {
+	srv := newSessionServer(t, -time.Second)
+
+	if _, _, err := srv.parseSessionToken(srv.newSessionToken("ABCD", "alice")); err != errSessionExpired {
+		t.Fatalf("expired token got %v, want errSessionExpired", err)
+	}
+}
+
+// a session token only resumes its own player in its own game
+func TestSessionTokenOnlyForItsGame(t *testing.T) {
+	srv := newSessionServer(t, time.Hour)
+
+	var games []string
+	var players []game.Player
+	for i := 0; i < 2; i++ {
+		gameID, err := srv.manager.CreateGame(game.GameOptions{}, "")
+		if err != nil {
+			t.Fatalf("failed to create game: %v", err)
+		}
+		p, err := srv.manager.Join(gameID, "alice", "", "")
+		if err != nil {
+			t.Fatalf("failed to join: %v", err)
+		}
+		games, players = append(games, gameID), append(players, p)
+	}
+
+	token := srv.newSessionToken(games[0], players[0].PlayerID)
+	if !srv.holdsPlayer(games[0], players[0].PlayerID, token) {
+		t.Fatal("session token doesn't hold its own player")
+	}
+	if srv.holdsPlayer(games[1], players[1].PlayerID, token) {
+		t.Fatal("session token holds alice in another game")
+	}
+	if srv.holdsPlayer(games[1], players[0].PlayerID, token) {
+		t.Fatal("session token holds its player in another game")
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// writeBuzzCooldown turns away a buzz made during the player's cooldown,
+// saying exactly how long is left so clients can count it down.
+func (srv *Server) writeBuzzCooldown(w http.ResponseWriter, r *http.Request, gameID, playerID string) {
+	wait := srv.manager.CooldownLeft(gameID, playerID)
+	writeRequestError(w, &requestError{
+		status:       http.StatusTooManyRequests,
+		Code:         codeBuzzCooldown,
+		Message:      srv.trErr(r, gameID, game.ErrBuzzCooldown),
+		RetryAfterMs: int64(math.Ceil(float64(wait) / float64(time.Millisecond))),
+	})
+}
+
+// gameSettings is how a game's adjustable options look to clients.
+func gameSettings(opts game.GameOptions) map[string]interface{} {
+	return map[string]interface{}{
+		"maxPlayers":       opts.MaxPlayers,
+		"firstBuzzWins":    opts.FirstBuzzWins,
+		"autoLock":         opts.FirstBuzzWins,
+		"armedBuzzing":     opts.ArmedBuzzing,
+		"buzzCooldown":     opts.BuzzCooldown.String(),
+		"almostWindow":     opts.AlmostWindow.String(),
+		"teamMode":         !opts.SoloMode,
+		"teamLockout":      opts.TeamLockout,
+		"recordLateBuzzes": opts.RecordLateBuzzes,
+
+		"latencyCompensation": opts.LatencyCompensation,
+		"waitingRoom":         opts.WaitingRoom,
+		"locale":              localeOrDefault(opts.Locale),
+		"scoring":             opts.Scoring,
+		"captains":            opts.Captains,
+		"tiebreak":            tiebreakOrDefault(opts.Tiebreak),
+	}
+}
+
+// settingsRequest is the settings a host changes, anything left out stays
+// as it was.
+type settingsRequest struct {
+	MaxPlayers       *int    `json:"maxPlayers"`
+	AutoLock         *bool   `json:"autoLock"`
+	FirstBuzzWins    *bool   `json:"firstBuzzWins"`
+	ArmedBuzzing     *bool   `json:"armedBuzzing"`
+	BuzzCooldown     *string `json:"buzzCooldown"`
+	AlmostWindow     *string `json:"almostWindow"`
+	TeamMode         *bool   `json:"teamMode"`
+	TeamLockout      *bool   `json:"teamLockout"`
+	RecordLateBuzzes *bool   `json:"recordLateBuzzes"`
+
+	LatencyCompensation *bool `json:"latencyCompensation"`
+	WaitingRoom         *bool `json:"waitingRoom"`
+	// Locale is what players get server messages in when their
+	// Accept-Language isn't one we have
+	Locale *string `json:"locale"`
+	// Scoring replaces the game's scoring rules, from the next verdict on
+	Scoring *game.ScoringRules `json:"scoring"`
+	// Captains turns team captains on or off
+	Captains *bool `json:"captains"`
+	// Tiebreak is how buzzes too close together to tell apart are ordered
+	Tiebreak *string `json:"tiebreak"`
+}
+
+// HostSettingsHandler changes a running game's settings. the body sets any
+// of
+//
+//	{"maxPlayers": 20, "autoLock": true, "armedBuzzing": true, "buzzCooldown": "2s", "almostWindow": "500ms",
+//	 "teamMode": false, "teamLockout": false, "recordLateBuzzes": true,
+//	 "latencyCompensation": true, "locale": "de", "captains": true, "tiebreak": "random",
+//	 "scoring": {"correct": 100, "wrongCostsValue": true, "roundMultipliers": [1, 2], "floor": 0}}
+//
+// leaving out what shouldn't change. autoLock locks the buzzers on the first
+// buzz, firstBuzzWins is another name for it. armedBuzzing only takes buzzes
+// between the host arming the buzzers and locking them or moving on, early
+// ones cost what scoring's game.EarlyBuzz says. a maxPlayers of 0 means no
+// limit and doesn't affect players already in the game. locale is the
+// language players get server messages in unless their Accept-Language asks
+// for one we have. scoring is the whole of the rules the judge applies,
+// see game.ScoringRules, here Jeopardy with double points in the second round
+// and no score going below 0. captains gives each team a captain, see
+// captain.go. tiebreak is arrival, random, alphabetical or lowest_score,
+// see tiebreak.go. every client is sent the new settings.
+func (srv *Server) HostSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req settingsRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if req.MaxPlayers != nil && *req.MaxPlayers < 0 {
+		writeError(w, http.StatusBadRequest, "", "maxPlayers can't be negative")
+		return
+	}
+	var cooldown time.Duration
+	if req.BuzzCooldown != nil {
+		d, err := time.ParseDuration(*req.BuzzCooldown)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, "", fmt.Sprintf("bad buzzCooldown [%s]", *req.BuzzCooldown))
+			return
+		}
+		cooldown = d
+	}
+	var almost time.Duration
+	if req.AlmostWindow != nil {
+		d, err := time.ParseDuration(*req.AlmostWindow)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, "", fmt.Sprintf("bad almostWindow [%s]", *req.AlmostWindow))
+			return
+		}
+		almost = d
+	}
+	if req.AutoLock == nil {
+		req.AutoLock = req.FirstBuzzWins
+	}
+	var locale string
+	if req.Locale != nil {
+		if locale = supportedLocale(*req.Locale); locale == "" {
+			writeError(w, http.StatusBadRequest, "", fmt.Sprintf("unsupported locale [%s]", *req.Locale))
+			return
+		}
+	}
+
+	if req.Tiebreak != nil && !tiebreaks[*req.Tiebreak] {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("unknown tiebreak [%s]", *req.Tiebreak))
+		return
+	}
+	if req.Scoring != nil {
+		if err := validateScoring(*req.Scoring); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	opts, err := srv.manager.UpdateOptions(id, func(opts *game.GameOptions) {
+		if req.MaxPlayers != nil {
+			opts.MaxPlayers = *req.MaxPlayers
+		}
+		if req.AutoLock != nil {
+			opts.FirstBuzzWins = *req.AutoLock
+		}
+		if req.ArmedBuzzing != nil {
+			opts.ArmedBuzzing = *req.ArmedBuzzing
+		}
+		if req.BuzzCooldown != nil {
+			opts.BuzzCooldown = cooldown
+		}
+		if req.AlmostWindow != nil {
+			opts.AlmostWindow = almost
+		}
+		if req.TeamMode != nil {
+			opts.SoloMode = !*req.TeamMode
+		}
+		if req.TeamLockout != nil {
+			opts.TeamLockout = *req.TeamLockout
+		}
+		if req.RecordLateBuzzes != nil {
+			opts.RecordLateBuzzes = *req.RecordLateBuzzes
+		}
+		if req.LatencyCompensation != nil {
+			opts.LatencyCompensation = *req.LatencyCompensation
+		}
+		if req.WaitingRoom != nil {
+			opts.WaitingRoom = *req.WaitingRoom
+		}
+		if req.Locale != nil {
+			opts.Locale = locale
+		}
+		if req.Scoring != nil {
+			opts.Scoring = *req.Scoring
+		}
+		if req.Captains != nil {
+			opts.Captains = *req.Captains
+		}
+		if req.Tiebreak != nil {
+			opts.Tiebreak = *req.Tiebreak
+		}
+	})
+	if err != nil {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	settings := gameSettings(opts)
+	srv.publish(transport.Message{GameID: id, Action: "settings", Data: settings}, transport.ToAll)
+	// a raised player cap makes room for whoever is waiting
+	srv.admitWaiting(id)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(settings)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
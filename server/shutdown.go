@@ -0,0 +1,34 @@
+package server
+
+import (
+	"bzzz/internal/transport"
+)
+
+// serverShutdown is sent to every stream on this replica before it exits.
+// streams end right after it, and clients should reconnect. whichever
+// replica the game's code is routed to next picks the game up from the
+// store.
+const serverShutdown = "server_shutdown"
+
+// notifyShutdown tells every stream held by this replica that it's going
+// away. it goes straight to the streams rather than through the broker since
+// the other replicas are staying up.
+func (srv *Server) notifyShutdown() {
+	for _, gameID := range srv.manager.GameIDs() {
+		msg := transport.Message{GameID: gameID, Action: serverShutdown}
+
+		_, clients, hosts := srv.manager.Recipients(gameID)
+		for _, client := range clients {
+			client.Offer(msg)
+		}
+		for _, host := range hosts {
+			host.Offer(msg)
+		}
+		for _, preview := range srv.manager.Previews(gameID) {
+			preview.Offer(msg)
+		}
+		for _, spectator := range srv.manager.Spectators(gameID) {
+			spectator.Offer(msg)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// soundcheck events
+const (
+	soundcheckStart  = "soundcheck"
+	soundcheckBuzz   = "soundcheck_buzz"
+	soundcheckFinish = "soundcheck_end"
+)
+
+// HostSoundcheckHandler starts a practice buzz round. players are asked to
+// buzz once, and until it ends their buzzes only tell the host their button
+// works.
+func (srv *Server) HostSoundcheckHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	status, err := srv.manager.StartSoundcheck(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, Action: soundcheckStart, Data: status}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostEndSoundcheckHandler ends a practice buzz round, going back to real
+// buzzes.
+func (srv *Server) HostEndSoundcheckHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	status, err := srv.manager.EndSoundcheck(id)
+	switch err {
+	case nil:
+	case game.ErrNoSoundcheck:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, Action: soundcheckFinish, Data: status}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+const (
+	// sseWriteTimeout is how long a write to an event stream can take
+	// before it counts as timed out, usually because the client's TCP
+	// buffers are full
+	sseWriteTimeout = 5 * time.Second
+	// sseMaxTimeouts is how many timeouts in a row make a stream stalled,
+	// when it's cut off
+	sseMaxTimeouts = 3
+)
+
+var errStreamStalled = errors.New("stream stalled, its client stopped reading")
+
+type connKey struct{}
+
+// withConn is the servers' ConnContext, so a stream can get at the
+// connection under it.
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// writeGuard puts deadlines on a stream's writes so a client that stopped
+// reading can't hold its handler forever. each write runs in the
+// background while the stream waits on it, a write that takes longer than
+// sseWriteTimeout counts as a timeout, and after sseMaxTimeouts of them in
+// a row the connection's write deadline is pulled in to end it.
+type writeGuard struct {
+	// conn is the stream's own connection. it's nil over HTTP/2, where the
+	// connection is shared with other requests and can't be cut off, so a
+	// stalled stream is only reported
+	conn     net.Conn
+	timeouts int
+	// stalled is called once when the stream is found stalled
+	stalled     func()
+	stalledOnce bool
+}
+
+// newWriteGuard guards the writes of a request's stream.
+func newWriteGuard(r *http.Request, stalled func()) *writeGuard {
+	g := &writeGuard{stalled: stalled}
+	if r.ProtoMajor == 1 {
+		g.conn, _ = r.Context().Value(connKey{}).(net.Conn)
+	}
+	return g
+}
+
+// do runs one write under the guard. it returns errStreamStalled once the
+// stream has been cut off.
+func (g *writeGuard) do(write func() error) error {
+	if g == nil {
+		return write()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- write() }()
+
+	timer := time.NewTimer(sseWriteTimeout)
+	defer timer.Stop()
+	timedOut := false
+	for {
+		select {
+		case err := <-done:
+			if !timedOut {
+				g.timeouts = 0
+			}
+			return err
+		case <-timer.C:
+		}
+
+		timedOut = true
+		g.timeouts++
+		if g.timeouts < sseMaxTimeouts {
+			timer.Reset(sseWriteTimeout)
+			continue
+		}
+		if !g.stalledOnce {
+			g.stalledOnce = true
+			if g.stalled != nil {
+				g.stalled()
+			}
+		}
+		if g.conn == nil {
+			// the write can't be abandoned while it may still touch the
+			// response, so wait it out
+			return <-done
+		}
+		g.conn.SetWriteDeadline(time.Now())
+		<-done
+		return errStreamStalled
+	}
+}
+
+// writeHeartbeat writes a keepalive comment to an event stream.
+func writeHeartbeat(w http.ResponseWriter, flusher http.Flusher) error {
+	if _, err := fmt.Fprintf(w, ": ping %d\n\n", time.Now().Unix()); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// writeSSE writes one event. events with a seq carry it as their id, which
+// the browser hands back in Last-Event-ID when it reconnects, and eventType
+// names the event so EventSource listeners can pick the ones they want.
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, seq int, eventType string, data []byte) error {
+	if seq > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", seq); err != nil {
+			return err
+		}
+	}
+	if eventType != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", eventType); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// lastEventID returns the seq a reconnecting EventSource last saw. ok is
+// false if it didn't say.
+func lastEventID(r *http.Request) (seq int, ok bool) {
+	seq, err := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	return seq, err == nil
+}
+
+// startEventStream sets up an SSE response and pushes the headers out
+// immediately so the client knows the stream is open.
+func (srv *Server) startEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Connection", "keep-alive")
+	// never let a content length sneak in, streams must be chunked
+	h.Del("Content-Length")
+
+	// tell intermediaries such as nginx not to buffer the stream
+	if srv.cfg.SSEProxyHints {
+		// no-transform stops proxies from compressing and buffering the stream
+		h.Set("Cache-Control", "no-cache, no-transform")
+		h.Set("X-Accel-Buffering", "no")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	// some proxies (e.g. Cloudflare) hold a response until they have seen a
+	// couple KB, so deployments behind one pad the start of the stream
+	if srv.cfg.SSEPadding > 0 {
+		fmt.Fprintf(w, ":%s\n\n", strings.Repeat(" ", srv.cfg.SSEPadding))
+	}
+	flusher.Flush()
+
+	return flusher, true
+}
+
+// playerStream is the connection a player's events are written to.
+type playerStream interface {
+	// writeHello greets a newly connected player
+	writeHello(hello map[string]interface{}) error
+	writeEvent(msg transport.Message) error
+	// writeHeartbeat keeps an idle connection from being dropped
+	writeHeartbeat() error
+}
+
+// sseStream writes player events as server sent events.
+type sseStream struct {
+	srv *Server
+
+	w       http.ResponseWriter
+	flusher http.Flusher
+	guard   *writeGuard
+	version int
+}
+
+func (s *sseStream) writeHello(hello map[string]interface{}) error {
+	jsonBytes, err := json.Marshal(s.srv.versionedHello(hello, s.version))
+	if err != nil {
+		return err
+	}
+	return s.guard.do(func() error { return writeSSE(s.w, s.flusher, 0, helloEvent, jsonBytes) })
+}
+
+func (s *sseStream) writeEvent(msg transport.Message) error {
+	return s.guard.do(func() error { return s.srv.writePlayerEvent(s.w, s.flusher, msg, s.version) })
+}
+
+func (s *sseStream) writeHeartbeat() error {
+	return s.guard.do(func() error { return writeHeartbeat(s.w, s.flusher) })
+}
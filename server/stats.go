@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// gameStats is sent to everyone as a game ends, with every player's stats
+// for a final results screen.
+const gameStats = "game_stats"
+
+// statsEvent is the data of a game_stats event.
+type statsEvent struct {
+	Players []game.PlayerStats `json:"players"`
+}
+
+// publishStats sends everyone the final stats of a game that's ending.
+func (srv *Server) publishStats(gameID string) {
+	stats, err := srv.manager.Stats(gameID)
+	if err != nil {
+		log.Printf("failed to work out stats for game %s: %v", gameID, err)
+		return
+	}
+	srv.publish(transport.Message{GameID: gameID, Action: gameStats, Data: statsEvent{Players: stats}}, transport.ToAll)
+}
+
+// GamePlayerStatsHandler returns a player's buzzes, wins, average reaction
+// time, judged answers and points so far in a game.
+func (srv *Server) GamePlayerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	playerID := params["playerID"]
+
+	stats, err := srv.manager.PlayerStats(id, playerID)
+	switch err {
+	case nil:
+	case game.ErrPlayerNotFound:
+		writeError(w, http.StatusNotFound, codePlayerNotFound, srv.tr(r, id, "player_not_found", playerID, id))
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
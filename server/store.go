@@ -0,0 +1,32 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// loadGame picks the game named in a route's path up from the store when
+// this replica doesn't hold it, say because the replica that was playing it
+// went away, then runs next. see game.Manager.Load.
+func (srv *Server) loadGame(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gameID := mux.Vars(r)["id"]
+		loaded, err := srv.manager.Load(gameID)
+		if err != nil {
+			log.Printf("failed to load game %s: %v", gameID, err)
+			writeError(w, http.StatusServiceUnavailable, "", "failed to load game")
+			return
+		}
+		if loaded {
+			// like a game restored at startup, its host isn't connected
+			// here yet
+			srv.hostGone(gameID)
+			srv.scheduleGameEnd(gameID)
+			srv.scheduleTeardown(gameID)
+			srv.rebuildProjection(gameID)
+		}
+		next(w, r)
+	}
+}
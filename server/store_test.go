@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bzzz/client"
+	"bzzz/internal/testserver"
+)
+
+// a replica that doesn't hold a game reads it in from the store the
+// replicas share the first time it's asked about it
+func TestGamePickedUpFromSharedStore(t *testing.T) {
+	c := testserver.Config()
+	c.Store = "sqlite"
+	c.SQLitePath = filepath.Join(t.TempDir(), "bzzz.db")
+	a := testserver.New(t, c)
+	b := testserver.New(t, c)
+
+	g := a.CreateGame(client.GameOptions{})
+	alice := a.Join(g, "alice")
+
+	// a writes the game out in the background
+	deadline := time.Now().Add(testserver.Timeout)
+	for {
+		_, err := b.Client.State(context.Background(), g.Code)
+		if err == nil {
+			break
+		}
+		var cerr *client.Error
+		if !errors.As(err, &cerr) || cerr.Code != client.CodeGameNotFound {
+			t.Fatalf("failed to get state from the other replica: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("game %s never reached the other replica", g.Code)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// players and the host carry on where a left off
+	b.MustBuzz(g, alice)
+	bob := b.Join(g, "bob")
+	if err := b.Client.Reset(context.Background(), g.Code, g.HostToken); err != nil {
+		t.Fatalf("host failed to reset on the other replica: %v", err)
+	}
+	st, err := b.Client.State(context.Background(), g.Code)
+	if err != nil {
+		t.Fatalf("failed to get state: %v", err)
+	}
+	var q questionState
+	if err := json.Unmarshal(st.Question, &q); err != nil {
+		t.Fatalf("failed to decode question: %v", err)
+	}
+	if q.ID != 2 {
+		t.Fatalf("state on question %d after reset, want 2", q.ID)
+	}
+	b.MustBuzz(g, bob)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxTeamNameLength caps team names picked with ?team= on join.
+const maxTeamNameLength = 32
+
+// teamName tidies up a team name given by a player.
+func teamName(raw string) string {
+	name := strings.Join(strings.Fields(raw), " ")
+	if r := []rune(name); len(r) > maxTeamNameLength {
+		name = string(r[:maxTeamNameLength])
+	}
+	return name
+}
+
+// GameTeamsHandler returns a game's team rosters.
+func (srv *Server) GameTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+
+	opts, _ := srv.manager.Options(id)
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"teams":       srv.manager.Teams(id),
+		"teamLockout": opts.TeamLockout,
+		"captains":    opts.Captains,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
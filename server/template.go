@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+)
+
+// maxTemplateNameLength caps the names templates are saved under.
+const maxTemplateNameLength = 64
+
+// maxTemplateTeams caps the teams a template sets up.
+const maxTemplateTeams = 32
+
+// maxTemplateBody caps a saved template, which has room for a full
+// question bank unlike other request bodies.
+const maxTemplateBody = 1 << 20
+
+// templateName tidies up a template name from the path.
+func templateName(raw string) (string, error) {
+	name := strings.Join(strings.Fields(raw), " ")
+	if name == "" {
+		return "", badRequest("name", "template name is required")
+	}
+	if len([]rune(name)) > maxTemplateNameLength {
+		return "", badRequest("name", "template names can be at most %d characters", maxTemplateNameLength)
+	}
+	return name, nil
+}
+
+// templateRequest is a template as a host saves it.
+type templateRequest struct {
+	store.GameTemplate
+}
+
+func (t *templateRequest) validate() error {
+	if err := game.ValidateQuestions(t.Questions); err != nil {
+		return badRequest("questions", "%v", err)
+	}
+	if t.Scoring != nil {
+		if err := validateScoring(*t.Scoring); err != nil {
+			return err
+		}
+	}
+	if len(t.Teams) > maxTemplateTeams {
+		return badRequest("teams", "a template can set up at most %d teams", maxTemplateTeams)
+	}
+	seen := map[string]bool{}
+	teams := make([]string, 0, len(t.Teams))
+	for _, raw := range t.Teams {
+		name := teamName(raw)
+		if name == "" {
+			return badRequest("teams", "team names can't be blank")
+		}
+		if !seen[name] {
+			seen[name] = true
+			teams = append(teams, name)
+		}
+	}
+	t.Teams = teams
+	// a recovery PIN is a secret for one game, not something to keep
+	t.RecoveryPIN = ""
+	return nil
+}
+
+// templateOwner is who a tenant's templates belong to, the host account
+// making the request if it has one or else the tenant as a whole.
+func templateOwner(t *tenant, a store.HostAccount, hasAccount bool) string {
+	if hasAccount {
+		return a.Owner()
+	}
+	return t.ID
+}
+
+// templateOwnerFor works out whose templates a request is for, writing an
+// error and returning false if its credentials are bad.
+func (srv *Server) templateOwnerFor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	t, ok := srv.tenantFor(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeBadCredentials, "unknown tenant credentials")
+		return "", false
+	}
+	a, hasAccount, ok := srv.hostAccountFor(w, r, t, true)
+	if !ok {
+		return "", false
+	}
+	return templateOwner(t, a, hasAccount), true
+}
+
+// loadTemplate looks up one of an owner's templates, writing an error and
+// returning false if it can't.
+func (srv *Server) loadTemplate(w http.ResponseWriter, owner, name string) (store.GameTemplate, bool) {
+	t, ok, err := srv.store.LoadTemplate(owner, name)
+	if err != nil {
+		log.Printf("failed to load template %s: %v", name, err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to load template")
+		return store.GameTemplate{}, false
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, codeTemplateNotFound, fmt.Sprintf("template [%s] not found", name))
+		return store.GameTemplate{}, false
+	}
+	return t, true
+}
+
+// TemplatesHandler lists the caller's templates by name, the host
+// account's if it sends an account key or else the tenant's.
+func (srv *Server) TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	owner, ok := srv.templateOwnerFor(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := srv.store.ListTemplates(owner)
+	if err != nil {
+		log.Printf("failed to list templates for %s: %v", owner, err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to list templates")
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"templates": list})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// TemplateHandler returns one of the caller's templates.
+func (srv *Server) TemplateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	owner, ok := srv.templateOwnerFor(w, r)
+	if !ok {
+		return
+	}
+	name, err := templateName(mux.Vars(r)["name"])
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	tmpl, ok := srv.loadTemplate(w, owner, name)
+	if !ok {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(tmpl); err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// SaveTemplateHandler saves a template under the name in the path, taking
+// the same settings as creating a game plus teams and questions, e.g.
+//
+//	{"maxPlayers": 40, "firstBuzzWins": true, "teams": ["Red", "Blue"],
+//	 "questions": [{"text": "...", "answer": "...", "points": 100}]}
+//
+// saving over an existing template replaces it.
+func (srv *Server) SaveTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	owner, ok := srv.templateOwnerFor(w, r)
+	if !ok {
+		return
+	}
+	name, err := templateName(mux.Vars(r)["name"])
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	var req templateRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	tmpl := req.GameTemplate
+	tmpl.Name, tmpl.UpdatedAt = name, time.Now()
+
+	if err := srv.store.SaveTemplate(owner, tmpl); err != nil {
+		log.Printf("failed to save template %s: %v", name, err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to save template")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(tmpl); err != nil {
+		log.Printf("failed to encode template: %v", err)
+	}
+}
+
+// DeleteTemplateHandler deletes one of the caller's templates.
+// games already made from it are unaffected.
+func (srv *Server) DeleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	owner, ok := srv.templateOwnerFor(w, r)
+	if !ok {
+		return
+	}
+	name, err := templateName(mux.Vars(r)["name"])
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	deleted, err := srv.store.DeleteTemplate(owner, name)
+	if err != nil {
+		log.Printf("failed to delete template %s: %v", name, err)
+		writeError(w, http.StatusServiceUnavailable, "", "failed to delete template")
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, codeTemplateNotFound, fmt.Sprintf("template [%s] not found", name))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"bzzz/internal/game"
+	"bzzz/internal/store"
+)
+
+// quota caps a tenant's usage per billing period, zero means unlimited.
+type quota struct {
+	GamesCreated      int64 `json:"gamesCreated"`
+	ConnectionMinutes int64 `json:"connectionMinutes"`
+	EventsDelivered   int64 `json:"eventsDelivered"`
+}
+
+// exceeded returns the first limit u has used up, or "" if there's quota
+// left. the games limit only counts when creating a game.
+func (q quota) exceeded(u store.Usage, creating bool) string {
+	switch {
+	case creating && q.GamesCreated > 0 && u.GamesCreated >= q.GamesCreated:
+		return "gamesCreated"
+	case q.ConnectionMinutes > 0 && u.ConnectionSeconds >= q.ConnectionMinutes*60:
+		return "connectionMinutes"
+	case q.EventsDelivered > 0 && u.EventsDelivered >= q.EventsDelivered:
+		return "eventsDelivered"
+	}
+	return ""
+}
+
+type tenant struct {
+	ID      string   `json:"id"`
+	APIKeys []string `json:"apiKeys"`
+	Quota   quota    `json:"quota"`
+	// Webhooks receive the events of the tenant's games
+	Webhooks []game.WebhookEndpoint `json:"webhooks,omitempty"`
+	// Moderation picks how names in the tenant's games are checked
+	Moderation moderationConfig `json:"moderation"`
+}
+
+func (srv *Server) loadTenants(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var cfg struct {
+		Tenants []*tenant `json:"tenants"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+
+	for _, t := range cfg.Tenants {
+		if t.ID == "" {
+			return fmt.Errorf("tenant without an id")
+		}
+		srv.tenants[t.ID] = t
+		for _, key := range t.APIKeys {
+			srv.tenantKeys[key] = t
+		}
+		for _, e := range t.Webhooks {
+			if e.ID == "" || e.URL == "" || e.Secret == "" {
+				return fmt.Errorf("tenant %s has a webhook without an id, url or secret", t.ID)
+			}
+		}
+		m, err := newModerator(t.Moderation)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %v", t.ID, err)
+		}
+		srv.moderators[t.ID] = m
+	}
+	return nil
+}
+
+// usagePeriod is the billing period usage at t counts towards.
+func usagePeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// usageFlushInterval is how often metered usage is written to the store.
+const usageFlushInterval = 10 * time.Second
+
+// meter records usage for a tenant in the current period.
+func (srv *Server) meter(tenantID string, u store.Usage) {
+	if len(srv.tenants) == 0 {
+		return
+	}
+
+	period := usagePeriod(time.Now())
+
+	srv.meterMu.Lock()
+	defer srv.meterMu.Unlock()
+
+	if _, ok := srv.unflushed[tenantID]; !ok {
+		srv.unflushed[tenantID] = map[string]*store.Usage{}
+	}
+	if _, ok := srv.unflushed[tenantID][period]; !ok {
+		srv.unflushed[tenantID][period] = &store.Usage{}
+	}
+	srv.unflushed[tenantID][period].Add(u)
+}
+
+// meterGameEvents records n events delivered for a game.
+func (srv *Server) meterGameEvents(gameID string, n int) {
+	if n == 0 || len(srv.tenants) == 0 {
+		return
+	}
+	if opts, ok := srv.manager.Options(gameID); ok {
+		srv.meter(opts.Tenant, store.Usage{EventsDelivered: int64(n)})
+	}
+}
+
+// meterConnection records the time a stream was open for.
+func (srv *Server) meterConnection(tenantID string, since time.Time) {
+	srv.meter(tenantID, store.Usage{ConnectionSeconds: int64(time.Since(since) / time.Second)})
+}
+
+// flushUsage writes metered usage to the store forever.
+func (srv *Server) flushUsage() {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		srv.flushUsageOnce()
+	}
+}
+
+// flushUsageOnce writes out everything metered since the last flush.
+func (srv *Server) flushUsageOnce() {
+	srv.meterMu.Lock()
+	pending := srv.unflushed
+	srv.unflushed = map[string]map[string]*store.Usage{}
+	srv.meterMu.Unlock()
+
+	for tenantID, periods := range pending {
+		for period, u := range periods {
+			if err := srv.store.AddUsage(tenantID, period, *u); err != nil {
+				log.Printf("failed to record usage for tenant %s: %v", tenantID, err)
+				// try again next time
+				srv.meter(tenantID, *u)
+			}
+		}
+	}
+}
+
+// currentUsage returns a tenant's usage so far this period, including what
+// hasn't been flushed yet.
+func (srv *Server) currentUsage(tenantID string) (store.Usage, error) {
+	period := usagePeriod(time.Now())
+
+	u, err := srv.store.LoadUsage(tenantID, period)
+	if err != nil {
+		return store.Usage{}, err
+	}
+
+	srv.meterMu.Lock()
+	if pending, ok := srv.unflushed[tenantID][period]; ok {
+		u.Add(*pending)
+	}
+	srv.meterMu.Unlock()
+	return u, nil
+}
+
+// checkQuota writes an error and returns false if the tenant has used up its
+// quota for this period, including its games quota when creating a game.
+// games already running are never cut off, quotas only stop new games and
+// connections.
+func (srv *Server) checkQuota(w http.ResponseWriter, t *tenant, creating bool) bool {
+	if t.ID == "" {
+		return true
+	}
+
+	u, err := srv.currentUsage(t.ID)
+	if err != nil {
+		log.Printf("failed to load usage for tenant %s: %v", t.ID, err)
+		return true
+	}
+	if limit := t.Quota.exceeded(u, creating); limit != "" {
+		writeError(w, http.StatusTooManyRequests, codeQuotaExceeded, fmt.Sprintf("%s quota exceeded for this month", limit))
+		return false
+	}
+	return true
+}
+
+// checkGameQuota is checkQuota for the tenant that owns a game.
+func (srv *Server) checkGameQuota(w http.ResponseWriter, gameID string) bool {
+	opts, _ := srv.manager.Options(gameID)
+	t, ok := srv.tenants[opts.Tenant]
+	if !ok {
+		return true
+	}
+	return srv.checkQuota(w, t, false)
+}
+
+// UsageHandler returns the calling tenant's usage and quota for the current
+// period.
+func (srv *Server) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	t, ok := srv.tenantFor(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeBadCredentials, "unknown tenant credentials")
+		return
+	}
+
+	u, err := srv.currentUsage(t.ID)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant": t.ID,
+		"period": usagePeriod(time.Now()),
+		"usage":  u,
+		"quota":  t.Quota,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// buzzTie tells the host that buzzes were pressed too close together to
+// tell apart, and how the tie was broken. the host can take the order as
+// it stands or re-run the buzz with a reset.
+const buzzTie = "buzz_tie"
+
+var tiebreaks = map[string]bool{
+	game.TiebreakArrival:      true,
+	game.TiebreakRandom:       true,
+	game.TiebreakAlphabetical: true,
+	game.TiebreakLowestScore:  true,
+}
+
+// tiebreakOrDefault returns how a game breaks ties, arrival order unless
+// the host picked something else.
+func tiebreakOrDefault(tiebreak string) string {
+	if tiebreak == "" {
+		return game.TiebreakArrival
+	}
+	return tiebreak
+}
+
+// tieEvent is the data of a buzz_tie event. Buzzes are the tied buzzes
+// in the order the tiebreak put them.
+type tieEvent struct {
+	QuestionID int         `json:"questionID"`
+	Tie        int         `json:"tie"`
+	Tiebreak   string      `json:"tiebreak"`
+	Buzzes     []game.Buzz `json:"buzzes"`
+}
+
+// tieOf returns the buzzes in a tie, in order.
+func tieOf(order []game.Buzz, tie int) []game.Buzz {
+	buzzes := []game.Buzz{}
+	for _, b := range order {
+		if b.Tie == tie {
+			buzzes = append(buzzes, b)
+		}
+	}
+	return buzzes
+}
+
+// announceTie tells the host about a tie b is part of, given the buzz
+// order it stands in.
+func (srv *Server) announceTie(gameID string, b game.Buzz, order []game.Buzz) {
+	if b.Tie == 0 {
+		return
+	}
+	opts, _ := srv.manager.Options(gameID)
+	tiebreak := tiebreakOrDefault(opts.Tiebreak)
+	if opts.FirstBuzzWins {
+		tiebreak = game.TiebreakArrival
+	}
+	srv.publish(transport.Message{
+		GameID:   gameID,
+		PlayerID: b.PlayerID,
+		Action:   buzzTie,
+		Data: tieEvent{
+			QuestionID: b.QuestionID,
+			Tie:        b.Tie,
+			Tiebreak:   tiebreak,
+			Buzzes:     tieOf(order, b.Tie),
+		},
+	}, transport.ToHost)
+}
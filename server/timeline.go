@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// timelineSize caps how many entries are kept per game, oldest go first
+	timelineSize = 2000
+	// timelineRetention is how long a finished game's timeline is kept for
+	// support to look at
+	timelineRetention = 24 * time.Hour
+)
+
+// timeline entry kinds
+const (
+	timelineEvent      = "event"
+	timelineConnect    = "connect"
+	timelineDisconnect = "disconnect"
+	timelineError      = "error"
+)
+
+// timelineEntry is one thing that happened to a game on this replica.
+type timelineEntry struct {
+	At       time.Time `json:"at"`
+	Kind     string    `json:"kind"`
+	Action   string    `json:"action,omitempty"`
+	PlayerID string    `json:"playerID,omitempty"`
+	Seq      int       `json:"seq,omitempty"`
+	// LatencyMs is how long an event took from being sequenced to being
+	// handed to this replica's streams
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+	Delivered int     `json:"delivered,omitempty"`
+	Dropped   int     `json:"dropped,omitempty"`
+	// Queued counts hosts an event was kept back for in the host backlog,
+	// because none was connected or one had fallen too far behind
+	Queued int    `json:"queued,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type timeline struct {
+	entries []timelineEntry
+	endedAt time.Time
+}
+
+// recordTimeline adds an entry to a game's timeline.
+func (srv *Server) recordTimeline(gameID string, e timelineEntry) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	srv.timelinesMu.Lock()
+	defer srv.timelinesMu.Unlock()
+
+	t, ok := srv.timelines[gameID]
+	if !ok {
+		t = &timeline{}
+		srv.timelines[gameID] = t
+	}
+	if len(t.entries) >= timelineSize {
+		t.entries = t.entries[1:]
+	}
+	t.entries = append(t.entries, e)
+}
+
+// timelineErrorf records something going wrong in a game.
+func (srv *Server) timelineErrorf(gameID, format string, args ...interface{}) {
+	srv.recordTimeline(gameID, timelineEntry{Kind: timelineError, Detail: fmt.Sprintf(format, args...)})
+}
+
+// endTimeline starts the retention clock on a finished game's timeline.
+func (srv *Server) endTimeline(gameID string) {
+	srv.timelinesMu.Lock()
+	defer srv.timelinesMu.Unlock()
+
+	if t, ok := srv.timelines[gameID]; ok {
+		t.endedAt = time.Now()
+	}
+}
+
+// expireTimelines drops the timelines of games that finished more than
+// timelineRetention ago, forever.
+func (srv *Server) expireTimelines() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		srv.timelinesMu.Lock()
+		for gameID, t := range srv.timelines {
+			if !t.endedAt.IsZero() && time.Since(t.endedAt) > timelineRetention {
+				delete(srv.timelines, gameID)
+			}
+		}
+		srv.timelinesMu.Unlock()
+	}
+}
+
+// AdminTimelineHandler returns everything recorded about a game on this
+// replica, events, connection churn, delivery latencies and errors, in the
+// order it happened.
+func (srv *Server) AdminTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	srv.timelinesMu.Lock()
+	t, ok := srv.timelines[id]
+	var entries []timelineEntry
+	var endedAt time.Time
+	if ok {
+		entries = append([]timelineEntry{}, t.entries...)
+		endedAt = t.endedAt
+	}
+	srv.timelinesMu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("no timeline for game id [%s]", id))
+		return
+	}
+
+	resp := map[string]interface{}{
+		"gameID":   id,
+		"running":  srv.manager.Exists(id),
+		"timeline": entries,
+	}
+	if !endedAt.IsZero() {
+		resp["endedAt"] = endedAt
+	}
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
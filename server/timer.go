@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/transport"
+)
+
+// timer events. ticks aren't sequenced, each replica sends its own streams
+// ticks counting down to the endsAt every replica got with timer_start, so
+// they agree without flooding the event log.
+const (
+	timerStart = "timer_start"
+	timerTick  = "timer_tick"
+	timerStop  = "timer_stop"
+	timerEnd   = "timer_end"
+)
+
+// timerTickInterval is how often a running timer ticks.
+const timerTickInterval = time.Second
+
+// trackTimer starts and stops ticking on this replica as timer events are
+// delivered.
+func (srv *Server) trackTimer(msg transport.Message) {
+	switch msg.Action {
+	case timerStart:
+		timerID, endsAt, ok := timerStarted(msg.Data)
+		if !ok {
+			log.Printf("malformed timer start for game %s", msg.GameID)
+			return
+		}
+		srv.startTicks(msg.GameID, timerID, endsAt)
+	case timerStop, timerEnd:
+		srv.stopTicks(msg.GameID)
+	case "disconnect":
+		if msg.PlayerID == "" {
+			srv.stopTicks(msg.GameID)
+		}
+	}
+}
+
+// timerStarted reads a timer_start event's data, a timerEvent unless it has
+// been through JSON on its way from another replica.
+func timerStarted(data interface{}) (timerID int, endsAt time.Time, ok bool) {
+	if e, ok := data.(timerEvent); ok && e.EndsAt != nil {
+		return e.TimerID, *e.EndsAt, true
+	}
+
+	fields, _ := data.(map[string]interface{})
+	switch id := fields["timerID"].(type) {
+	case int:
+		timerID = id
+	case float64:
+		timerID = int(id)
+	default:
+		return 0, time.Time{}, false
+	}
+	switch at := fields["endsAt"].(type) {
+	case time.Time:
+		endsAt = at
+	case string:
+		var err error
+		if endsAt, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return 0, time.Time{}, false
+		}
+	default:
+		return 0, time.Time{}, false
+	}
+	return timerID, endsAt, true
+}
+
+func (srv *Server) startTicks(gameID string, timerID int, endsAt time.Time) {
+	stop := make(chan struct{})
+
+	srv.tickersMu.Lock()
+	if old, ok := srv.tickers[gameID]; ok {
+		close(old)
+	}
+	srv.tickers[gameID] = stop
+	srv.tickersMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(timerTickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				remaining := endsAt.Sub(now)
+				if remaining <= 0 {
+					return
+				}
+				srv.sendTick(transport.Message{GameID: gameID, Action: timerTick, Data: timerEvent{
+					TimerID:     timerID,
+					RemainingMs: remaining.Milliseconds(),
+					EndsAt:      &endsAt,
+				}})
+			}
+		}
+	}()
+}
+
+func (srv *Server) stopTicks(gameID string) {
+	srv.tickersMu.Lock()
+	defer srv.tickersMu.Unlock()
+
+	if stop, ok := srv.tickers[gameID]; ok {
+		close(stop)
+		delete(srv.tickers, gameID)
+	}
+}
+
+// sendTick hands a tick straight to every stream of the game on this
+// replica. a tick nobody has room for is simply skipped.
+func (srv *Server) sendTick(msg transport.Message) {
+	_, clients, hosts := srv.manager.Recipients(msg.GameID)
+	for _, client := range clients {
+		client.Offer(msg)
+	}
+	for _, host := range hosts {
+		host.Offer(msg)
+	}
+	for _, preview := range srv.manager.Previews(msg.GameID) {
+		preview.Offer(msg)
+	}
+}
+
+// scheduleTimerEnd announces a countdown running out, locking the buzzers
+// first if asked to.
+func (srv *Server) scheduleTimerEnd(gameID string, timerID int, d time.Duration, autoLock bool) {
+	time.AfterFunc(d, func() {
+		if !srv.manager.ExpireTimer(gameID, timerID) {
+			return
+		}
+		log.Printf("timer %d in game %s ran out", timerID, gameID)
+
+		end := transport.Message{GameID: gameID, Action: timerEnd, Data: timerEvent{TimerID: timerID, AutoLock: autoLock}}
+		if !autoLock {
+			srv.publish(end, transport.ToAll)
+			return
+		}
+		if err := srv.manager.ApplyHostAction(gameID, "lock"); err != nil {
+			log.Printf("timer %d in game %s couldn't lock the buzzers: %v", timerID, gameID, err)
+			srv.publish(end, transport.ToAll)
+			return
+		}
+		srv.publishTxn([]transport.Message{end, {GameID: gameID, Action: "lock"}}, transport.ToAll)
+	})
+}
+
+// timerStartRequest starts a countdown of Duration.
+type timerStartRequest struct {
+	Duration string `json:"duration"`
+	AutoLock bool   `json:"autoLock"`
+}
+
+// HostTimerStartHandler starts a countdown every player sees ticking down
+// together. the body is {"duration": "30s", "autoLock": true}, autoLock
+// locking the buzzers when time runs out. a running timer is replaced.
+func (srv *Server) HostTimerStartHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req timerStartRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("bad duration [%s]", req.Duration))
+		return
+	}
+
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	timerID, endsAt, err := srv.manager.StartTimer(id, d, req.AutoLock)
+	if err != nil {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+	srv.scheduleTimerEnd(id, timerID, d, req.AutoLock)
+
+	started := timerEvent{
+		TimerID:    timerID,
+		DurationMs: d.Milliseconds(),
+		EndsAt:     &endsAt,
+		AutoLock:   req.AutoLock,
+	}
+	srv.publish(transport.Message{GameID: id, Action: timerStart, Data: started}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(started)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostTimerStopHandler cancels a game's running countdown.
+func (srv *Server) HostTimerStopHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	timerID, ok := srv.manager.StopTimer(id)
+	if !ok {
+		writeError(w, http.StatusConflict, codeNoTimer, "no timer running")
+		return
+	}
+	srv.publish(transport.Message{GameID: id, Action: timerStop, Data: timerEvent{TimerID: timerID}}, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// scheduleGameEnd ends a game once it has run for its max duration. games
+// restored after a restart that are already past it end straight away.
+func (srv *Server) scheduleGameEnd(gameID string) {
+	opts, ok := srv.manager.Options(gameID)
+	if !ok || opts.MaxDuration <= 0 {
+		return
+	}
+	started := srv.manager.StartedAt(gameID)
+
+	time.AfterFunc(time.Until(started.Add(opts.MaxDuration)), func() {
+		// the code may have gone to another game since, a rematch say
+		if !srv.manager.StartedAt(gameID).Equal(started) || srv.manager.Ended(gameID) {
+			return
+		}
+		log.Printf("game %s hit its max duration of %s", gameID, opts.MaxDuration)
+
+		names := []string{}
+		for _, p := range srv.manager.Players(gameID) {
+			names = append(names, p.Name)
+		}
+
+		srv.publishTxn([]transport.Message{
+			{GameID: gameID, Action: "time_up"},
+			{GameID: gameID, Action: "summary", Data: summaryEvent{
+				StartedAt: started.Format(time.RFC3339),
+				EndedAt:   time.Now().Format(time.RFC3339),
+				Players:   names,
+			}},
+		}, transport.ToAll)
+		srv.finishGame(gameID, endedByTimeUp)
+	})
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// tlsVersions maps the tls_min_version setting to crypto/tls versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// cipherSuiteIDs looks up cipher suites by name, nil for none so Go picks.
+// the config has already checked the names.
+func cipherSuiteIDs(names []string) []uint16 {
+	var ids []uint16
+	for _, name := range names {
+		for _, s := range tls.CipherSuites() {
+			if s.Name == name {
+				ids = append(ids, s.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// certReloader serves a listener's certificate from files it reads again
+// whenever TLS is reloaded, so a renewed certificate is picked up without
+// a restart. connections already open keep the one they started with.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// newCertReloader loads a certificate, failing if it can't be read.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the certificate files again. on failure the certificate
+// already loaded stays in use.
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %v", r.certFile, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// loadClientCAs reads cfg.TLSClientCA. on failure the CAs already loaded
+// stay in use.
+func (srv *Server) loadClientCAs() error {
+	b, err := ioutil.ReadFile(srv.cfg.TLSClientCA)
+	if err != nil {
+		return fmt.Errorf("failed to read client CAs: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return fmt.Errorf("no certificates found in client CAs %s", srv.cfg.TLSClientCA)
+	}
+
+	srv.clientCAs.mu.Lock()
+	defer srv.clientCAs.mu.Unlock()
+	srv.clientCAs.pool = pool
+	return nil
+}
+
+// verifyClient checks the certificate chain a client presented against
+// clientCAs. it's done here rather than by crypto/tls so the CAs can be
+// reloaded while listeners keep running.
+func (srv *Server) verifyClient(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("client certificate required")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("bad client certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	srv.clientCAs.mu.RLock()
+	pool := srv.clientCAs.pool
+	srv.clientCAs.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(opts); err != nil {
+		return fmt.Errorf("client certificate not trusted: %v", err)
+	}
+	return nil
+}
+
+// applyTLSSettings sets the minimum version, cipher suites and, with a
+// client CA, mutual TLS from cfg on a listener's TLS config.
+func (srv *Server) applyTLSSettings(c *tls.Config) {
+	c.MinVersion = tlsVersions[srv.cfg.TLSMinVersion]
+	c.CipherSuites = cipherSuiteIDs(srv.cfg.TLSCipherSuites)
+	if srv.cfg.TLSClientCA != "" {
+		// any certificate gets the handshake as far as verifyClient, which
+		// turns away the ones clientCAs didn't sign
+		c.ClientAuth = tls.RequireAnyClientCert
+		c.VerifyPeerCertificate = srv.verifyClient
+	}
+}
+
+// reloadTLSOnHangup reads every listener's certificate files and the
+// client CAs again each time the process gets SIGHUP.
+func (srv *Server) reloadTLSOnHangup(certs []*certReloader) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	go func() {
+		for range sig {
+			log.Printf("got SIGHUP, reloading TLS certificates")
+			for _, r := range certs {
+				if err := r.reload(); err != nil {
+					log.Printf("%v, keeping the old one", err)
+				}
+			}
+			if srv.cfg.TLSClientCA != "" {
+				if err := srv.loadClientCAs(); err != nil {
+					log.Printf("%v, keeping the old ones", err)
+				}
+			}
+		}
+	}()
+}
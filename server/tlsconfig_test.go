@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bzzz/config"
+)
+
+// newCert issues a certificate for name signed by parent, or self signed if
+// parent is nil, returning it with its key.
+func newCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca, caKey := newCert(t, "bzzz test CA", true, nil, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+
+	srv := &Server{cfg: &config.Config{TLSClientCA: caFile, TLSMinVersion: "1.2"}}
+	if err := srv.loadClientCAs(); err != nil {
+		t.Fatalf("failed to load client CAs: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{}
+	srv.applyTLSSettings(ts.TLS)
+	ts.StartTLS()
+	defer ts.Close()
+
+	signed, signedKey := newCert(t, "signed client", false, ca, caKey)
+	rogue, rogueKey := newCert(t, "rogue client", false, nil, nil)
+	for _, tc := range []struct {
+		name  string
+		certs []tls.Certificate
+		ok    bool
+	}{
+		{"no certificate", nil, false},
+		{"self signed certificate", []tls.Certificate{{Certificate: [][]byte{rogue.Raw}, PrivateKey: rogueKey}}, false},
+		{"CA signed certificate", []tls.Certificate{{Certificate: [][]byte{signed.Raw}, PrivateKey: signedKey}}, true},
+	} {
+		transport := ts.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = tc.certs
+		resp, err := (&http.Client{Transport: transport}).Get(ts.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if ok := err == nil && resp.StatusCode == http.StatusOK; ok != tc.ok {
+			t.Errorf("%s: got %v, want accepted %v", tc.name, err, tc.ok)
+		}
+	}
+}
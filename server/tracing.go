@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"bzzz/internal/transport"
+)
+
+// tracing follows a request through the event pipeline: the request's span,
+// then a span for sequencing each envelope it published, one for
+// delivering each message on every replica and one for each stream it's
+// written to. a buzz that reached someone late shows where the time went,
+// waiting on the game's hub, in the broker or in a slow client's queue.
+//
+// a message carries the span it was sent under along with it, across the
+// broker too, so everything it leads to ends up in the same trace. spans
+// all have the game's ID.
+
+// tracer makes every span. it does nothing until setUpTracing installs an
+// exporter.
+var tracer = otel.Tracer("bzzz")
+
+// traceContext reads and writes W3C traceparent headers.
+var traceContext = propagation.TraceContext{}
+
+// setUpTracing starts exporting spans to cfg.TraceEndpoint, if there is
+// one.
+func (srv *Server) setUpTracing() error {
+	if srv.cfg.TraceEndpoint == "" {
+		return nil
+	}
+
+	u, err := url.Parse(srv.cfg.TraceEndpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("bad trace endpoint %q, it needs to be a URL such as http://localhost:4318", srv.cfg.TraceEndpoint)
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceNameKey.String("bzzz")}
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, semconv.ServiceInstanceIDKey.String(host))
+	}
+	srv.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(srv.cfg.TraceSampleRatio))),
+	)
+	otel.SetTracerProvider(srv.tracerProvider)
+	otel.SetTextMapPropagator(traceContext)
+	log.Printf("exporting traces to %s", srv.cfg.TraceEndpoint)
+	return nil
+}
+
+// shutdownTracing exports the spans still waiting to go.
+func (srv *Server) shutdownTracing(ctx context.Context) {
+	if srv.tracerProvider == nil {
+		return
+	}
+	if err := srv.tracerProvider.Shutdown(ctx); err != nil {
+		log.Printf("failed to export the last spans: %v", err)
+	}
+}
+
+// statusWriter remembers the status a handler answered with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// traceRoute wraps a route's handler in a span, continuing any trace the
+// caller sent a traceparent for. streams aren't traced as a whole, they'd
+// last for hours, only the events written to them are.
+func traceRoute(path string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := traceContext.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		attrs := semconv.HTTPServerAttributesFromHTTPRequest("bzzz", path, r)
+		if id, ok := mux.Vars(r)["id"]; ok {
+			attrs = append(attrs, attribute.String("game.id", id))
+		}
+		ctx, span := tracer.Start(ctx, r.Method+" "+path, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(sw.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(sw.status, trace.SpanKindServer))
+	}
+}
+
+// traced stamps a message with the span in ctx, so everything it leads to
+// joins that span's trace.
+func traced(ctx context.Context, msg transport.Message) transport.Message {
+	msg.Trace = trace.SpanContextFromContext(ctx)
+	return msg
+}
+
+// startSequenceSpan starts the span of sequencing an envelope. it's a
+// child of the span the envelope was published under, or a trace of its
+// own for gameplay published from no request, like a timer running out.
+// timer ticks, heartbeats and the like are left out unless a request
+// published them.
+func (srv *Server) startSequenceSpan(env transport.Envelope) trace.Span {
+	parent := env.Msgs[0].Trace
+	if !parent.IsValid() {
+		noisy := true
+		for _, msg := range env.Msgs {
+			noisy = noisy && srv.queues.PriorityOf(msg) == transport.PriorityLow
+		}
+		if noisy {
+			return trace.SpanFromContext(context.Background())
+		}
+	}
+
+	_, span := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "sequence "+env.Msgs[0].Action,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("game.id", env.Msgs[0].GameID),
+			attribute.String("audience", audienceName(env.To)),
+			attribute.Int("messages", len(env.Msgs)),
+		))
+	return span
+}
+
+// startMessageSpan starts a span of a message's journey, a child of the
+// span it was sent under. it's a span that does nothing if the message
+// isn't being traced.
+func startMessageSpan(msg transport.Message, name string, opts ...trace.SpanStartOption) trace.Span {
+	if !msg.Trace.IsValid() {
+		return trace.SpanFromContext(context.Background())
+	}
+	opts = append(opts, trace.WithAttributes(
+		attribute.String("game.id", msg.GameID),
+		attribute.String("action", msg.Action),
+		attribute.Int("seq", msg.Seq),
+	))
+	_, span := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), msg.Trace), name+" "+msg.Action, opts...)
+	return span
+}
+
+// traceWrite spans writing a message to a stream, from when it was queued
+// for the stream until the write returned, so the time a slow client's
+// queue held it shows. to is "host" or the player's ID. call the returned
+// func with the write's result.
+func traceWrite(msg transport.Message, to string) func(error) {
+	if !msg.Trace.IsValid() {
+		return func(error) {}
+	}
+	var opts []trace.SpanStartOption
+	if !msg.QueuedAt.IsZero() {
+		opts = append(opts, trace.WithTimestamp(msg.QueuedAt))
+	}
+	span := startMessageSpan(msg, "write", opts...)
+	if to == "host" {
+		span.SetAttributes(attribute.String("stream", "host"))
+	} else {
+		span.SetAttributes(attribute.String("stream", "player"), attribute.String("player.id", to))
+	}
+	if !msg.QueuedAt.IsZero() {
+		span.SetAttributes(attribute.Float64("queued_ms", float64(time.Since(msg.QueuedAt))/float64(time.Millisecond)))
+	}
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// hostTransferred is sent to hosts when a game's host token is replaced.
+// streams opened with the old token are closed.
+const hostTransferred = "host_transferred"
+
+var recoveryPINPattern = regexp.MustCompile(`^[0-9]{4,8}$`)
+
+// transferRequest takes over a game. a caller without the host token, say
+// because the host's device has died, gives the recovery PIN chosen when
+// the game was created.
+type transferRequest struct {
+	RecoveryPIN string `json:"recoveryPin,omitempty"`
+}
+
+// transferResponse is the game's new host token. the old one stops working.
+type transferResponse struct {
+	GameCode  string `json:"gameCode"`
+	HostToken string `json:"hostToken"`
+}
+
+// transferEvent is the data of a host_transferred event.
+type transferEvent struct {
+	// By is how the transfer was allowed, "hostToken" or "recoveryPin"
+	By string `json:"by"`
+}
+
+// checkRecoveryPIN checks a recovery PIN a host asked for.
+func checkRecoveryPIN(pin string) error {
+	if pin != "" && !recoveryPINPattern.MatchString(pin) {
+		return badRequest("recoveryPin", "recovery PIN must be 4 to 8 digits")
+	}
+	return nil
+}
+
+// HostTransferHandler issues a game a new host token so another device can
+// take it over, players, scores and all. the caller proves they're the host
+// with the current host token or, if it's been lost with the host's device,
+// the game's recovery PIN. host streams still open with the old token are
+// told and closed.
+func (srv *Server) HostTransferHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req transferRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	// a host token, when there is one, is what's checked
+	token, pin, by := hostTokenFrom(r), "", "hostToken"
+	if token == "" {
+		pin, by = req.RecoveryPIN, "recoveryPin"
+	}
+
+	hostToken, old, err := srv.manager.TransferHost(id, token, pin)
+	switch err {
+	case nil:
+	case game.ErrGameNotFound:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	case game.ErrTransferNotAllowed:
+		writeErr(w, http.StatusUnauthorized, err)
+		return
+	case game.ErrBadHostToken:
+		log.Printf("rejected bad host token for game %s", id)
+		writeErr(w, http.StatusForbidden, err)
+		return
+	case game.ErrBadRecoveryPIN, game.ErrNoRecoveryPIN:
+		log.Printf("rejected recovery PIN for game %s", id)
+		writeRequestError(w, &requestError{status: http.StatusForbidden, Code: errorCode(err), Message: err.Error(), Field: "recoveryPin"})
+		return
+	case game.ErrRecoveryLockedOut:
+		log.Printf("recovery PIN for game %s is locked out", id)
+		writeRequestError(w, &requestError{status: http.StatusTooManyRequests, Code: errorCode(err), Message: err.Error(), Field: "recoveryPin"})
+		return
+	default:
+		writeErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	log.Printf("host of game %s transferred by %s", id, by)
+	srv.recordTimeline(id, timelineEntry{Kind: timelineConnect, Detail: "host transferred by " + by + " from " + r.RemoteAddr})
+
+	msg := transport.Message{GameID: id, Action: hostTransferred, Data: transferEvent{By: by}}
+	// the old streams are being let go, so tell them directly before
+	// closing them
+	for _, q := range old {
+		q.Offer(msg)
+		q.Close()
+	}
+	srv.publish(msg, transport.ToHost)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(transferResponse{GameCode: id, HostToken: hostToken})
+	if err != nil {
+		log.Printf("failed to encode transfer response: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+var recognitionModes = map[string]bool{
+	game.RecognitionRoundRobin: true,
+}
+
+// turn events
+const (
+	turnStart   = "turn"
+	turnTimeout = "turn_timeout"
+)
+
+// advanceTurn moves a round robin game on to the next player's turn and
+// tells everyone whose it is. games in other modes are left alone.
+func (srv *Server) advanceTurn(gameID string) {
+	opts, ok := srv.manager.Options(gameID)
+	if !ok || opts.Recognition != game.RecognitionRoundRobin {
+		return
+	}
+
+	p, turn, ok := srv.manager.NextTurn(gameID)
+	if !ok {
+		srv.publish(transport.Message{GameID: gameID, Action: turnStart, Data: map[string]interface{}{"turn": turn}}, transport.ToAll)
+		return
+	}
+
+	data := map[string]interface{}{"turn": turn, "playerName": p.Name}
+	if opts.TurnTimeout > 0 {
+		data["endsAt"] = time.Now().Add(opts.TurnTimeout).Format(time.RFC3339Nano)
+		srv.scheduleTurnTimeout(gameID, p.PlayerID, turn, opts.TurnTimeout)
+	}
+	srv.publish(transport.Message{GameID: gameID, PlayerID: p.PlayerID, Action: turnStart, Data: data}, transport.ToAll)
+}
+
+// scheduleTurnTimeout passes the turn on if its player hasn't buzzed by the
+// time it runs out.
+func (srv *Server) scheduleTurnTimeout(gameID, playerID string, turn int, timeout time.Duration) {
+	time.AfterFunc(timeout, func() {
+		if _, current := srv.manager.Turn(gameID); current != turn {
+			return
+		}
+		for _, b := range srv.manager.BuzzOrder(gameID) {
+			if b.PlayerID == playerID {
+				return
+			}
+		}
+
+		log.Printf("turn %d in game %s timed out", turn, gameID)
+		srv.publish(transport.Message{GameID: gameID, PlayerID: playerID, Action: turnTimeout, Data: map[string]interface{}{"turn": turn}}, transport.ToAll)
+		srv.advanceTurn(gameID)
+	})
+}
+
+// HostSkipTurnHandler passes the turn in a round robin game to the next
+// player. it also hands out the first turn.
+func (srv *Server) HostSkipTurnHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	opts, ok := srv.manager.Options(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+	if opts.Recognition != game.RecognitionRoundRobin {
+		writeError(w, http.StatusConflict, codeNotRoundRobin, "game isn't in round robin mode")
+		return
+	}
+
+	srv.advanceTurn(id)
+
+	w.WriteHeader(http.StatusCreated)
+}
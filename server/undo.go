@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// hostUndo tells everyone the host took back one of their actions.
+const hostUndo = "undo"
+
+// HostUndoHandler reverses the host's most recent score change, judgment,
+// kick or reset, and tells everyone with an undo event. scores come back
+// with it in a score event, so scoreboards needn't know about undoing.
+func (srv *Server) HostUndoHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	ev, err := srv.manager.Undo(id)
+	switch err {
+	case nil:
+	case game.ErrNothingToUndo, game.ErrUndoStale:
+		writeErr(w, http.StatusConflict, err)
+		return
+	case game.ErrGameNotFound:
+		writeErr(w, http.StatusNotFound, err)
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	msgs := []transport.Message{traced(r.Context(), transport.Message{GameID: id, PlayerID: ev.Undone.PlayerID, Action: hostUndo, Data: ev})}
+	if len(ev.Corrections) > 0 {
+		msgs = append(msgs, srv.scoreMessage(id, ev.Corrections))
+	}
+	srv.publishTxn(msgs, transport.ToAll)
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(ev)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// HostUndoLogHandler lists the host actions that can still be undone, the
+// next to go last.
+func (srv *Server) HostUndoLogHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeErr(w, http.StatusNotFound, game.ErrGameNotFound)
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"undo": srv.manager.UndoLog(id)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
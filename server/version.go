@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bzzz/internal/transport"
+)
+
+// event schema versions a client can ask for. v1 is the original envelope,
+// with the player an event is about flat on it, empty fields sent anyway
+// and a time in Go's default format. v2 nests the player under player,
+// leaves out what doesn't apply and gives times as RFC 3339. both are sent
+// until every deployed client has moved to v2, cfg.EventVersion picks what
+// clients that don't ask get in the meantime.
+const (
+	eventV1 = 1
+	eventV2 = 2
+)
+
+// eventVersions are the versions streams can be asked for
+var eventVersions = map[int]bool{eventV1: true, eventV2: true}
+
+// eventVersion works out the event schema version a client wants, from ?v=
+// or a version parameter on its Accept header, such as
+// "text/event-stream; version=2". ?v= wins if both are given. clients that
+// say neither get cfg.EventVersion.
+func (srv *Server) eventVersion(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("v")
+	if raw == "" {
+		for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+			if _, params, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && params["version"] != "" {
+				raw = params["version"]
+				break
+			}
+		}
+	}
+	if raw == "" {
+		return srv.cfg.EventVersion, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil || !eventVersions[v] {
+		return 0, &requestError{
+			status:  http.StatusBadRequest,
+			Code:    codeUnsupportedVersion,
+			Message: fmt.Sprintf("event version [%s] isn't supported, use %d or %d", raw, eventV1, eventV2),
+			Field:   "v",
+		}
+	}
+	return v, nil
+}
+
+// eventV2Envelope is how a message looks on a stream that asked for v2.
+type eventV2Envelope struct {
+	V        int          `json:"v"`
+	Type     string       `json:"type"`
+	Time     time.Time    `json:"time"`
+	GameID   string       `json:"gameID"`
+	Seq      int          `json:"seq,omitempty"`
+	Txn      int          `json:"txn,omitempty"`
+	Critical bool         `json:"critical,omitempty"`
+	State    string       `json:"state,omitempty"`
+	Player   *eventPlayer `json:"player,omitempty"`
+	Data     interface{}  `json:"data,omitempty"`
+}
+
+// eventPlayer is the player a v2 event is about.
+type eventPlayer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Seat   int    `json:"seat,omitempty"`
+	Team   string `json:"team,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+	Color  string `json:"color,omitempty"`
+}
+
+// versionedEvent wraps a message in the envelope of the given version.
+func (srv *Server) versionedEvent(msg transport.Message, version int) interface{} {
+	if version != eventV2 {
+		return srv.newEvent(msg)
+	}
+
+	e := eventV2Envelope{
+		V:        eventV2,
+		Type:     msg.Action,
+		Time:     time.Now().UTC(),
+		GameID:   msg.GameID,
+		Seq:      msg.Seq,
+		Txn:      msg.Txn,
+		Critical: criticalActions[msg.Action],
+		State:    msg.State,
+		Data:     msg.Data,
+	}
+	if msg.PlayerID != "" {
+		p, _ := srv.manager.Player(msg.PlayerID)
+		e.Player = &eventPlayer{
+			ID:     msg.PlayerID,
+			Name:   p.Name,
+			Seat:   p.Seat,
+			Team:   p.Team,
+			Avatar: p.Avatar,
+			Color:  p.Color,
+		}
+	}
+	return e
+}
+
+// versionedHello rewrites the v1 greeting a player's stream opens with for
+// the given version.
+func (srv *Server) versionedHello(hello map[string]interface{}, version int) map[string]interface{} {
+	if version != eventV2 {
+		return hello
+	}
+
+	playerID, _ := hello["playerID"].(string)
+	p, _ := srv.manager.Player(playerID)
+	return map[string]interface{}{
+		"v":      eventV2,
+		"type":   helloEvent,
+		"time":   time.Now().UTC(),
+		"gameID": hello["gameID"],
+		"player": eventPlayer{
+			ID:     playerID,
+			Name:   p.Name,
+			Seat:   p.Seat,
+			Team:   p.Team,
+			Avatar: p.Avatar,
+			Color:  p.Color,
+		},
+		"token":   hello["token"],
+		"session": hello["session"],
+	}
+}
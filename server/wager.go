@@ -0,0 +1,318 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// wager round events. wagerOpen, wagerClosed and wagerReveal go to
+// everyone. wagerSubmitted goes to the host with the wager and answer,
+// wagerReceived privately to the player who made it, so nobody else sees a
+// wager before it's revealed.
+const (
+	wagerOpen      = "wager_open"
+	wagerSubmitted = "wager_submitted"
+	wagerReceived  = "wager_received"
+	wagerClosed    = "wager_closed"
+	wagerReveal    = "wager_reveal"
+)
+
+// wagerOpenEvent is the data of a wager_open event.
+type wagerOpenEvent struct {
+	Category string `json:"category,omitempty"`
+	MaxWager int    `json:"maxWager,omitempty"`
+}
+
+// wagerClosedEvent is the data of a wager_closed event. it says who
+// wagered, never how much.
+type wagerClosedEvent struct {
+	Wagered []string `json:"wagered"`
+}
+
+// wagerOpenRequest opens a wager round.
+type wagerOpenRequest struct {
+	Category string `json:"category"`
+	MaxWager int    `json:"maxWager"`
+}
+
+func (req wagerOpenRequest) validate() error {
+	if req.MaxWager < 0 {
+		return badRequest("maxWager", "maxWager can't be negative")
+	}
+	return nil
+}
+
+// wagerRequest is a player's wager and answer, either of which can be left
+// out to send it later.
+type wagerRequest struct {
+	PlayerID string `json:"playerID"`
+	Wager    *int   `json:"wager"`
+	Answer   string `json:"answer"`
+}
+
+func (req wagerRequest) validate() error {
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	if req.Wager == nil && strings.TrimSpace(req.Answer) == "" {
+		return badRequest("", "a wager or an answer is required")
+	}
+	if req.Wager != nil && *req.Wager < 0 {
+		return badRequest("wager", "wager can't be negative")
+	}
+	return nil
+}
+
+// wagerRevealRequest is the host's verdict on a player's wagered answer.
+type wagerRevealRequest struct {
+	PlayerID string `json:"playerID"`
+	Correct  bool   `json:"correct"`
+}
+
+func (req wagerRevealRequest) validate() error {
+	if req.PlayerID == "" {
+		return badRequest("playerID", "playerID is required")
+	}
+	return nil
+}
+
+// wagerRevealEvent is the data of a wager_reveal event, the wager and
+// answer along with the score entry settling it.
+type wagerRevealEvent struct {
+	Wager game.WagerEntry  `json:"wager"`
+	Score *game.ScoreEntry `json:"score,omitempty"`
+}
+
+// HostWagerOpenHandler opens a wager round, telling players the category
+// and how much anyone can stake.
+func (srv *Server) HostWagerOpenHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req wagerOpenRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	opened, err := srv.manager.OpenWagers(id, strings.TrimSpace(req.Category), req.MaxWager)
+	if err != nil {
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, Action: wagerOpen, Data: wagerOpenEvent{Category: opened.Category, MaxWager: opened.MaxWager}}, transport.ToAll)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(opened)
+	if err != nil {
+		log.Printf("failed to encode wager round: %v", err)
+	}
+}
+
+// WagerHandler takes a player's wager and answer. the host sees them as
+// they come in, the player gets them back on their own stream and nobody
+// else hears anything until they're revealed.
+func (srv *Server) WagerHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	var req wagerRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := srv.checkPlayer(r, id, req.PlayerID); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	text := strings.TrimSpace(req.Answer)
+	if r := []rune(text); len(r) > maxAnswerLength {
+		text = string(r[:maxAnswerLength])
+	}
+
+	if !srv.allowFlood(w, r, id, req.PlayerID, "wager") {
+		return
+	}
+
+	e, maxStake, err := srv.manager.SubmitWager(id, req.PlayerID, req.Wager, text)
+	switch err {
+	case nil:
+	case game.ErrWagerTooHigh:
+		writeRequestError(w, &requestError{status: http.StatusBadRequest, Code: codeWagerTooHigh, Message: srv.tr(r, id, "wager_too_high", maxStake), Field: "wager"})
+		return
+	case game.ErrNoWagerRound, game.ErrWagersClosed:
+		writeError(w, http.StatusConflict, errorCode(err), srv.trErr(r, id, err))
+		return
+	default:
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+
+	srv.publish(transport.Message{GameID: id, PlayerID: req.PlayerID, Action: wagerSubmitted, Data: e}, transport.ToHost)
+	srv.publish(transport.Message{GameID: id, PlayerID: req.PlayerID, Action: wagerReceived, Data: e, Private: true}, transport.ToPlayers)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(e)
+	if err != nil {
+		log.Printf("failed to encode wager: %v", err)
+	}
+}
+
+// HostWagerCloseHandler stops the wagering. everyone hears who wagered but
+// not how much.
+func (srv *Server) HostWagerCloseHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	closed, err := srv.manager.CloseWagers(id)
+	switch err {
+	case nil:
+	case game.ErrNoWagerRound, game.ErrWagersClosed:
+		writeErr(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	wagered := wagerClosedEvent{Wagered: []string{}}
+	for _, e := range closed.Entries {
+		wagered.Wagered = append(wagered.Wagered, e.PlayerID)
+	}
+	srv.publish(transport.Message{GameID: id, Action: wagerClosed, Data: wagered}, transport.ToAll)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(closed)
+	if err != nil {
+		log.Printf("failed to encode wager round: %v", err)
+	}
+}
+
+// HostWagerRevealHandler reveals a player's wager and answer to everyone
+// with the host's verdict, settling the wager on the score ledger.
+func (srv *Server) HostWagerRevealHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+	if srv.paused(w, r, id) {
+		return
+	}
+
+	var req wagerRevealRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	e, points, err := srv.manager.RevealWager(id, req.PlayerID, req.Correct)
+	switch err {
+	case nil:
+	case game.ErrNoWagerRound, game.ErrWagersOpen, game.ErrWagerRevealed:
+		writeErr(w, http.StatusConflict, err)
+		return
+	case game.ErrNoWager:
+		writeRequestError(w, &requestError{status: http.StatusNotFound, Code: errorCode(err), Message: err.Error(), Field: "playerID"})
+		return
+	default:
+		writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+		return
+	}
+
+	reveal := wagerRevealEvent{Wager: e}
+	var msgs []transport.Message
+	if points != 0 {
+		s, err := srv.manager.RecordScore(id, game.ScoreEntry{
+			Kind:     game.ScoreKindAward,
+			PlayerID: e.PlayerID,
+			Points:   points,
+			Reason:   "wager",
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "", fmt.Sprintf("failed to record score: %v", err))
+			return
+		}
+		reveal.Score = &s
+		msgs = append(msgs, srv.scoreMessage(id, []game.ScoreEntry{s}))
+	}
+	msgs = append([]transport.Message{{GameID: id, PlayerID: e.PlayerID, Action: wagerReveal, Data: reveal}}, msgs...)
+	srv.publishTxn(msgs, transport.ToAll)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(reveal)
+	if err != nil {
+		log.Printf("failed to encode wager reveal: %v", err)
+	}
+}
+
+// HostWagersHandler returns the current or last wager round with every
+// wager and answer in it.
+func (srv *Server) HostWagersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	round, ok := srv.manager.Wagers(id)
+	if !ok {
+		if !srv.manager.Exists(id) {
+			writeError(w, http.StatusNotFound, codeGameNotFound, fmt.Sprintf("game id [%s] not found", id))
+			return
+		}
+		writeErr(w, http.StatusNotFound, game.ErrNoWagerRound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(round)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// waiting room actions. playerWaiting is sent to hosts when a player joins
+// a full game, and to the waiting player's own stream with their place in
+// line. playerAdmitted is sent to hosts when a slot opens and the player at
+// the front of the line takes it.
+const (
+	playerWaiting  = "player_waiting"
+	playerAdmitted = "player_admitted"
+)
+
+// waitingEvent is the data of player_waiting and player_admitted events.
+type waitingEvent struct {
+	PlayerID string `json:"playerID"`
+	Name     string `json:"name"`
+	Team     string `json:"team,omitempty"`
+	// Position is the player's place in line, counting from 1. it's left
+	// out once they're admitted
+	Position int `json:"position,omitempty"`
+}
+
+// admitWaiting fills any free slots in a game from its waiting room and
+// tells the host who got them. it's called whenever a slot might have
+// opened up.
+func (srv *Server) admitWaiting(gameID string) {
+	for _, p := range srv.manager.AdmitWaiting(gameID) {
+		log.Printf("player %s admitted to game %s from the waiting room", p.PlayerID, gameID)
+		srv.publish(transport.Message{GameID: gameID, PlayerID: p.PlayerID, Action: playerAdmitted, Data: waitingEvent{PlayerID: p.PlayerID, Name: p.Name, Team: p.Team}}, transport.ToHost)
+		srv.announceTeamRequest(gameID, p)
+	}
+}
+
+// announceWaiting tells the host a player has joined the waiting room.
+func (srv *Server) announceWaiting(gameID string, p game.Player) {
+	position, _, ok := srv.manager.Waiting(gameID, p.PlayerID)
+	if !ok {
+		return
+	}
+	srv.publish(transport.Message{GameID: gameID, PlayerID: p.PlayerID, Action: playerWaiting, Data: waitingEvent{PlayerID: p.PlayerID, Name: p.Name, Team: p.Team, Position: position}}, transport.ToHost)
+}
+
+// waitForSlot holds a waiting player's stream open until they're admitted,
+// telling them their place in line. it returns false if the stream should
+// end instead, because done fired, the stream failed or the game ended.
+// players who aren't waiting go straight through.
+func (srv *Server) waitForSlot(p game.Player, stream playerStream, done <-chan struct{}) bool {
+	position, admitted, ok := srv.manager.Waiting(p.GameID, p.PlayerID)
+	if !ok {
+		return true
+	}
+
+	log.Printf("player %s waiting for a slot in game %s", p.PlayerID, p.GameID)
+	err := stream.writeEvent(transport.Message{GameID: p.GameID, PlayerID: p.PlayerID, Action: playerWaiting, Data: waitingEvent{PlayerID: p.PlayerID, Name: p.Name, Team: p.Team, Position: position}})
+	if err != nil {
+		return false
+	}
+
+	var heartbeats <-chan time.Time
+	if srv.cfg.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(srv.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+
+	for {
+		select {
+		case <-admitted:
+			// the game ending lets everyone waiting go too
+			return srv.manager.PlayerInGame(p.GameID, p.PlayerID)
+		case <-done:
+			return false
+		case <-heartbeats:
+			if err := stream.writeHeartbeat(); err != nil {
+				return false
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"bzzz/internal/transport"
+)
+
+// spectatorState tells a spectator stream the game's state has changed. the
+// stream writes the latest snapshot rather than the event itself.
+const spectatorState = "state"
+
+// notifySpectators lets a game's spectators know about a delivered event
+// that changed what they show, or that the game is over.
+func (srv *Server) notifySpectators(msg transport.Message) {
+	gameOver := msg.Action == "disconnect" && msg.PlayerID == ""
+	if projectedActions[msg.Action] == 0 && !gameOver && msg.Action != serverShutdown {
+		return
+	}
+	if !gameOver && msg.Action != serverShutdown {
+		msg = transport.Message{GameID: msg.GameID, Action: spectatorState, Seq: msg.Seq}
+	}
+	// a big screen falling behind only ever needs the latest state
+	for _, q := range srv.manager.Spectators(msg.GameID) {
+		q.Offer(msg)
+	}
+}
+
+// WatchHandler streams a read-only view of a game, its roster, buzz order,
+// scores and lock state, for projecting on a big screen. each update is the
+// same snapshot GameStateHandler serves. it's SSE unless the request asks
+// for a WebSocket upgrade.
+func (srv *Server) WatchHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	if !srv.manager.Exists(id) {
+		writeError(w, http.StatusNotFound, codeGameNotFound, srv.tr(r, id, "game_not_found", id))
+		return
+	}
+	if !srv.checkGameQuota(w, id) {
+		return
+	}
+
+	var (
+		done          <-chan struct{}
+		writeSnapshot func(seq int, snapshot []byte) error
+		writePing     func() error
+	)
+	if websocket.IsWebSocketUpgrade(r) {
+		upgrader := websocket.Upgrader{
+			EnableCompression: srv.cfg.WSCompression,
+			CheckOrigin:       srv.allowedOrigin,
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("websocket upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		s := &wsStream{srv: srv, conn: conn}
+		done = readUntilClosed(conn)
+		writeSnapshot = func(seq int, snapshot []byte) error {
+			return s.write(websocket.TextMessage, snapshot)
+		}
+		writePing = s.writeHeartbeat
+	} else {
+		flusher, ok := srv.startEventStream(w)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, "", "Streaming unsupported!")
+			return
+		}
+		done = r.Context().Done()
+		writeSnapshot = func(seq int, snapshot []byte) error {
+			return writeSSE(w, flusher, seq, spectatorState, bytes.TrimSuffix(snapshot, []byte("\n")))
+		}
+		writePing = func() error { return writeHeartbeat(w, flusher) }
+	}
+
+	q, err := srv.manager.AddSpectator(id)
+	if err != nil {
+		return
+	}
+	defer srv.manager.RemoveSpectator(id, q)
+
+	opts, _ := srv.manager.Options(id)
+	defer srv.meterConnection(opts.Tenant, time.Now())
+
+	hb := srv.startHeartbeat(q, func() { srv.manager.RemoveSpectator(id, q) })
+	defer hb.halt()
+
+	last := srv.gameSnapshot(id)
+	if err := writeSnapshot(0, last); err != nil {
+		return
+	}
+	hb.wrote()
+
+	for {
+		msg, ok := q.NextOr(done)
+		if !ok {
+			return
+		}
+
+		switch msg.Action {
+		case heartbeatAction:
+			if err := writePing(); err != nil {
+				return
+			}
+			hb.wrote()
+		case spectatorState:
+			snapshot := srv.gameSnapshot(id)
+			if bytes.Equal(snapshot, last) {
+				continue
+			}
+			if err := writeSnapshot(msg.Seq, snapshot); err != nil {
+				return
+			}
+			hb.wrote()
+			last = snapshot
+		default:
+			// the game is over or the server is going away
+			return
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+const (
+	// webhookAttempts is how many times a delivery is tried before it's
+	// dead lettered
+	webhookAttempts = 6
+	// webhookBackoff is the wait before the first retry, doubling after
+	// every failed attempt
+	webhookBackoff = 2 * time.Second
+	webhookTimeout = 10 * time.Second
+
+	// maxDeadLetters is how many dead letters are kept for each tenant, the
+	// oldest going first to make room
+	maxDeadLetters = 500
+	// deadLetterRetention is how long a dead letter is kept for redelivery
+	deadLetterRetention = 7 * 24 * time.Hour
+)
+
+// maxGameWebhooks caps the webhook URLs a host can give a game.
+const maxGameWebhooks = 5
+
+// gameCreated is published once a game has been created, for its webhooks
+// and the tenant's.
+const gameCreated = "game_created"
+
+// gameWebhookEvents are the actions a game's own webhooks are sent unless
+// the host picks others, the game being created, players joining, buzzes,
+// score changes and the game ending.
+var gameWebhookEvents = []string{gameCreated, "joined", "buzz", "score", "disconnect"}
+
+// gameWebhooks turns the webhook URLs a host gave a new game into
+// endpoints, all signed with one new secret. events limits what they're
+// sent, gameWebhookEvents if empty. URLs have to be absolute http or https
+// URLs, anything else fails with a requestError.
+func (srv *Server) gameWebhooks(urls, events []string) ([]game.WebhookEndpoint, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	if !srv.cfg.GameWebhooks {
+		return nil, badRequest("webhooks", "this server doesn't take webhooks for games")
+	}
+	if len(urls) > maxGameWebhooks {
+		return nil, badRequest("webhooks", "a game can have at most %d webhooks", maxGameWebhooks)
+	}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, badRequest("webhooks", "webhook [%s] isn't an http or https URL", raw)
+		}
+	}
+	if len(events) == 0 {
+		events = gameWebhookEvents
+	}
+
+	secret, err := game.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]game.WebhookEndpoint, len(urls))
+	for i, u := range urls {
+		endpoints[i] = game.WebhookEndpoint{URL: u, Secret: secret, Events: events}
+	}
+	return endpoints, nil
+}
+
+// addWebhookSecret adds the secret a new game's webhooks are signed with to
+// the response creating it, the only time it's handed out.
+func (srv *Server) addWebhookSecret(resp map[string]interface{}, gameID string) {
+	opts, _ := srv.manager.Options(gameID)
+	if len(opts.Webhooks) > 0 {
+		resp["webhookSecret"] = opts.Webhooks[0].Secret
+	}
+}
+
+// webhookPayload is the JSON body of every webhook request.
+type webhookPayload struct {
+	ID       string      `json:"id"`
+	Event    string      `json:"event"`
+	GameID   string      `json:"gameID"`
+	PlayerID string      `json:"playerID,omitempty"`
+	Seq      int         `json:"seq"`
+	Data     interface{} `json:"data,omitempty"`
+	At       time.Time   `json:"at"`
+}
+
+// webhookDelivery is one payload on its way to one endpoint.
+type webhookDelivery struct {
+	ID         string          `json:"id"`
+	TenantID   string          `json:"-"`
+	EndpointID string          `json:"endpointID"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"lastError,omitempty"`
+	FailedAt   time.Time       `json:"failedAt,omitempty"`
+
+	endpoint game.WebhookEndpoint
+}
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// queueWebhooks sends a sequenced envelope to the webhook endpoints of the
+// tenant owning each game, and to the game's own. it's called once per
+// event, by the replica that sequenced it, and never blocks.
+func (srv *Server) queueWebhooks(env transport.Envelope) {
+	for _, msg := range env.Msgs {
+		opts, ok := srv.manager.Options(msg.GameID)
+		if !ok {
+			continue
+		}
+		var endpoints []game.WebhookEndpoint
+		if t, ok := srv.tenants[opts.Tenant]; ok {
+			endpoints = append(endpoints, t.Webhooks...)
+		}
+		endpoints = append(endpoints, opts.Webhooks...)
+		if len(endpoints) == 0 {
+			continue
+		}
+
+		id, err := game.ULIDs{}.NewID()
+		if err != nil {
+			log.Printf("failed to make webhook id: %v", err)
+			continue
+		}
+		body, err := json.Marshal(webhookPayload{
+			ID:       id,
+			Event:    msg.Action,
+			GameID:   msg.GameID,
+			PlayerID: msg.PlayerID,
+			Seq:      msg.Seq,
+			Data:     msg.Data,
+			At:       time.Now(),
+		})
+		if err != nil {
+			log.Printf("failed to encode webhook for game %s: %v", msg.GameID, err)
+			continue
+		}
+
+		for _, e := range endpoints {
+			if !e.Wants(msg.Action) {
+				continue
+			}
+			go srv.attemptWebhook(&webhookDelivery{
+				ID:         id,
+				TenantID:   opts.Tenant,
+				EndpointID: e.ID,
+				Event:      msg.Action,
+				Body:       body,
+				endpoint:   e,
+			})
+		}
+	}
+}
+
+// attemptWebhook tries a delivery, scheduling a retry with exponential
+// backoff on failure and dead lettering it once it runs out of attempts.
+func (srv *Server) attemptWebhook(d *webhookDelivery) {
+	d.Attempts++
+	err := sendWebhook(d)
+	if err == nil {
+		return
+	}
+
+	d.LastError = err.Error()
+	if d.Attempts >= webhookAttempts {
+		log.Printf("webhook %s to endpoint %s failed %d times, dead lettering: %v", d.ID, d.EndpointID, d.Attempts, err)
+		d.FailedAt = time.Now()
+
+		srv.deadLetter(d)
+		return
+	}
+
+	wait := webhookBackoff << uint(d.Attempts-1)
+	time.AfterFunc(wait, func() { srv.attemptWebhook(d) })
+}
+
+// deadLetter keeps a delivery that ran out of attempts for its tenant to
+// look at and redeliver, dropping the tenant's oldest if there's no room.
+func (srv *Server) deadLetter(d *webhookDelivery) {
+	srv.deadLettersMu.Lock()
+	defer srv.deadLettersMu.Unlock()
+
+	letters, ok := srv.deadLetters[d.TenantID]
+	if !ok {
+		letters = map[string]*webhookDelivery{}
+		srv.deadLetters[d.TenantID] = letters
+	}
+	for len(letters) >= maxDeadLetters {
+		var oldest string
+		for key, l := range letters {
+			if oldest == "" || l.FailedAt.Before(letters[oldest].FailedAt) {
+				oldest = key
+			}
+		}
+		log.Printf("too many dead letters for tenant %s, dropping %s", d.TenantID, oldest)
+		delete(letters, oldest)
+	}
+	letters[d.EndpointID+"/"+d.ID] = d
+}
+
+// expireDeadLetters drops dead letters nobody redelivered within
+// deadLetterRetention.
+func (srv *Server) expireDeadLetters() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		srv.deadLettersMu.Lock()
+		for tenantID, letters := range srv.deadLetters {
+			for key, d := range letters {
+				if time.Since(d.FailedAt) > deadLetterRetention {
+					delete(letters, key)
+				}
+			}
+			if len(letters) == 0 {
+				delete(srv.deadLetters, tenantID)
+			}
+		}
+		srv.deadLettersMu.Unlock()
+	}
+}
+
+// signWebhook returns the X-Bzzz-Signature header for body sent at ts, an
+// HMAC-SHA256 over "<ts>.<body>" keyed by the endpoint's secret. receivers
+// should recompute it and reject stale timestamps.
+func signWebhook(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func sendWebhook(d *webhookDelivery) error {
+	req, err := http.NewRequest("POST", d.endpoint.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Bzzz-Event", d.Event)
+	req.Header.Set("X-Bzzz-Delivery", d.ID)
+	req.Header.Set("X-Bzzz-Attempt", strconv.Itoa(d.Attempts))
+	req.Header.Set("X-Bzzz-Signature", signWebhook(d.endpoint.Secret, time.Now().Unix(), d.Body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return nil
+}
+
+// DeadLettersHandler lists the calling tenant's webhook deliveries that ran
+// out of attempts, oldest first.
+func (srv *Server) DeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	t, ok := srv.tenantFor(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeBadCredentials, "unknown tenant credentials")
+		return
+	}
+
+	srv.deadLettersMu.Lock()
+	list := make([]webhookDelivery, 0, len(srv.deadLetters[t.ID]))
+	for _, d := range srv.deadLetters[t.ID] {
+		list = append(list, *d)
+	}
+	srv.deadLettersMu.Unlock()
+	sort.Slice(list, func(a, b int) bool { return list[a].FailedAt.Before(list[b].FailedAt) })
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"deadLetters": list})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to encode JSON response")
+		return
+	}
+}
+
+// RedeliverHandler takes a dead lettered delivery off the list and tries it
+// again with a fresh set of attempts.
+func (srv *Server) RedeliverHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	t, ok := srv.tenantFor(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeBadCredentials, "unknown tenant credentials")
+		return
+	}
+
+	params := mux.Vars(r)
+	key := params["endpointID"] + "/" + params["deliveryID"]
+
+	srv.deadLettersMu.Lock()
+	d, ok := srv.deadLetters[t.ID][key]
+	if ok {
+		delete(srv.deadLetters[t.ID], key)
+	}
+	srv.deadLettersMu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("dead letter [%s] not found", key))
+		return
+	}
+
+	// pick up secret or URL changes made since it failed
+	for _, e := range t.Webhooks {
+		if e.ID == d.EndpointID {
+			d.endpoint = e
+		}
+	}
+	d.Attempts, d.LastError, d.FailedAt = 0, "", time.Time{}
+	go srv.attemptWebhook(d)
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"id":"01J0000000000000000000000","event":"buzz","gameID":"ABCD","seq":3}`)
+	got := signWebhook("whsec_test", 1700000000, body)
+	want := "t=1700000000,v1=ae16e132d6196a129f0e977fbb366597eb953144dd808399972fd094e111134b"
+	if got != want {
+		t.Fatalf("got signature %s, want %s", got, want)
+	}
+	if other := signWebhook("whsec_other", 1700000000, body); other == want {
+		t.Fatal("signature doesn't depend on the secret")
+	}
+	if later := signWebhook("whsec_test", 1700000001, body); later == want {
+		t.Fatal("signature doesn't depend on the timestamp")
+	}
+}
+
+// a tenant whose endpoint stays down keeps only its latest dead letters
+func TestDeadLettersCapped(t *testing.T) {
+	srv := &Server{deadLetters: map[string]map[string]*webhookDelivery{}}
+	start := time.Now()
+	for i := 0; i <= maxDeadLetters; i++ {
+		srv.deadLetter(&webhookDelivery{
+			ID:         fmt.Sprint(i),
+			TenantID:   "acme",
+			EndpointID: "hook",
+			FailedAt:   start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	letters := srv.deadLetters["acme"]
+	if len(letters) != maxDeadLetters {
+		t.Fatalf("kept %d dead letters, want %d", len(letters), maxDeadLetters)
+	}
+	if _, ok := letters["hook/0"]; ok {
+		t.Fatal("oldest dead letter kept")
+	}
+	if _, ok := letters[fmt.Sprintf("hook/%d", maxDeadLetters)]; !ok {
+		t.Fatal("newest dead letter dropped")
+	}
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"bzzz/internal/game"
+	"bzzz/internal/transport"
+)
+
+// WebSocket subprotocols a player can ask for at upgrade. JSON text frames
+// are the default, bzzz.v1.proto switches to binary frames each holding
+// one protobuf encoded Event, see proto/bzzz.proto.
+const (
+	wsProtocolJSON  = "bzzz.v1.json"
+	wsProtocolProto = "bzzz.v1.proto"
+)
+
+// wsWriteTimeout is how long a single frame can take to write before the
+// connection is given up on.
+const wsWriteTimeout = 10 * time.Second
+
+// Event field numbers, matching proto/bzzz.proto.
+const (
+	eventGameID     protowire.Number = 1
+	eventPlayerID   protowire.Number = 2
+	eventPlayerName protowire.Number = 3
+	eventAction     protowire.Number = 4
+	eventSeq        protowire.Number = 5
+	eventTxn        protowire.Number = 6
+	eventCritical   protowire.Number = 7
+	eventSeat       protowire.Number = 8
+	eventTeam       protowire.Number = 9
+	eventData       protowire.Number = 10
+	eventTime       protowire.Number = 11
+	eventToken      protowire.Number = 12
+	eventSession    protowire.Number = 13
+)
+
+// allowedOrigin applies the CORS origins to WebSocket upgrades, which
+// browsers don't preflight.
+func (srv *Server) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || srv.originAllowed(origin)
+}
+
+// wsStream writes player events as WebSocket frames, JSON text or protobuf
+// binary depending on the negotiated subprotocol.
+type wsStream struct {
+	srv *Server
+
+	conn   *websocket.Conn
+	binary bool
+	// version is the event schema version of JSON frames, protobuf frames
+	// have their own schema
+	version int
+	// mu serializes frames, replies to commands are written alongside the
+	// event stream
+	mu sync.Mutex
+}
+
+func (s *wsStream) write(frameType int, b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return s.conn.WriteMessage(frameType, b)
+}
+
+func (s *wsStream) writeJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write(websocket.TextMessage, b)
+}
+
+func (s *wsStream) writeHello(hello map[string]interface{}) error {
+	if !s.binary {
+		return s.writeJSON(s.srv.versionedHello(hello, s.version))
+	}
+
+	var b []byte
+	for _, f := range []struct {
+		num protowire.Number
+		key string
+	}{
+		{eventGameID, "gameID"},
+		{eventPlayerID, "playerID"},
+		{eventPlayerName, "playerName"},
+		{eventTime, "time"},
+		{eventToken, "token"},
+		{eventSession, "session"},
+	} {
+		v, _ := hello[f.key].(string)
+		b = appendProtoString(b, f.num, v)
+	}
+	return s.write(websocket.BinaryMessage, b)
+}
+
+func (s *wsStream) writeEvent(msg transport.Message) error {
+	if !s.binary {
+		return s.writeJSON(s.srv.versionedEvent(msg, s.version))
+	}
+
+	b, err := s.srv.protoEvent(msg)
+	if err != nil {
+		return err
+	}
+	return s.write(websocket.BinaryMessage, b)
+}
+
+func (s *wsStream) writeHeartbeat() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+}
+
+// protoEvent encodes a message as an Event. data varies by action so it's
+// carried as JSON.
+func (srv *Server) protoEvent(msg transport.Message) ([]byte, error) {
+	p, _ := srv.manager.Player(msg.PlayerID)
+
+	var b []byte
+	b = appendProtoString(b, eventGameID, msg.GameID)
+	b = appendProtoString(b, eventPlayerID, msg.PlayerID)
+	b = appendProtoString(b, eventPlayerName, p.Name)
+	b = appendProtoString(b, eventAction, msg.Action)
+	b = appendProtoVarint(b, eventSeq, uint64(msg.Seq))
+	b = appendProtoVarint(b, eventTxn, uint64(msg.Txn))
+	if criticalActions[msg.Action] {
+		b = appendProtoVarint(b, eventCritical, 1)
+	}
+	b = appendProtoVarint(b, eventSeat, uint64(p.Seat))
+	b = appendProtoString(b, eventTeam, p.Team)
+	if msg.Data != nil {
+		data, err := json.Marshal(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, eventData, protowire.BytesType)
+		b = protowire.AppendBytes(b, data)
+	}
+	b = appendProtoString(b, eventTime, time.Now().Local().String())
+	return b, nil
+}
+
+// appendProtoString appends a string field, leaving it out when empty like
+// proto3 does.
+func appendProtoString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendProtoVarint appends an integer field, leaving it out when zero.
+func appendProtoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// PlayWSHandler is PlayHandler over a WebSocket. permessage-deflate is
+// offered when compression is enabled, and the bzzz.v1.proto subprotocol
+// gets binary protobuf frames instead of JSON.
+func (srv *Server) PlayWSHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Got connection: %s", r.Proto)
+
+	// grab the game id from the path
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "", "no 'id' found in URL")
+		return
+	}
+
+	version, err := srv.eventVersion(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	p, resumed, ok := srv.admitPlayer(w, r, id)
+	if !ok {
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		Subprotocols:      []string{wsProtocolJSON, wsProtocolProto},
+		EnableCompression: srv.cfg.WSCompression,
+		CheckOrigin:       srv.allowedOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered
+		log.Printf("websocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.EnableWriteCompression(srv.cfg.WSCompression)
+	// commands are held to the same size as request bodies
+	conn.SetReadLimit(int64(srv.cfg.MaxRequestBody))
+
+	stream := &wsStream{srv: srv, conn: conn, binary: conn.Subprotocol() == wsProtocolProto, version: version}
+	done := srv.readCommands(r, p, stream)
+	srv.streamPlayer(r, p, resumed, stream, done)
+}
+
+// commands a player can send up their socket. buzzing this way skips the
+// separate HTTP round trip, and the jitter that comes with it.
+const (
+	wsBuzzCommand = "buzz"
+	wsPongCommand = "pong"
+)
+
+// wsCommand is a JSON text frame sent by a player over their socket, on
+// either subprotocol. Ref is echoed back on the reply so the client can
+// match them up.
+type wsCommand struct {
+	Type  string `json:"type"`
+	Ref   string `json:"ref,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+	// PingID is the ping a pong answers
+	PingID int64 `json:"pingID,omitempty"`
+}
+
+// wsReply answers a wsCommand with the status and body the same request
+// would have got over HTTP, errors included. Error carries a body that
+// isn't JSON, which nothing sends any more.
+type wsReply struct {
+	V      int             `json:"v"`
+	Type   string          `json:"type"`
+	Ref    string          `json:"ref,omitempty"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// wsReplyEvent names reply frames.
+const wsReplyEvent = "reply"
+
+// replyWriter collects what a handler writes so it can be sent back as a
+// wsReply.
+type replyWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *replyWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *replyWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *replyWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// reply turns what was written into a wsReply for ref, in the stream's
+// event schema version.
+func (w *replyWriter) reply(ref string, version int) wsReply {
+	reply := wsReply{V: version, Type: wsReplyEvent, Ref: ref, Status: w.status}
+	if reply.Status == 0 {
+		reply.Status = http.StatusOK
+	}
+	body := bytes.TrimSpace(w.body.Bytes())
+	if len(body) > 0 && json.Valid(body) {
+		reply.Body = body
+	} else if len(body) > 0 {
+		reply.Error = string(body)
+	}
+	return reply
+}
+
+// readCommands is readUntilClosed for a player's socket, which also
+// carries out the commands among the frames read.
+func (srv *Server) readCommands(r *http.Request, p game.Player, stream *wsStream) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			frameType, b, err := stream.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if frameType != websocket.TextMessage {
+				continue
+			}
+
+			var cmd wsCommand
+			w := &replyWriter{header: http.Header{}}
+			switch err := json.Unmarshal(b, &cmd); {
+			case err != nil:
+				writeRequestError(w, badRequest("", "malformed command: %v", err))
+			case cmd.Type == wsBuzzCommand:
+				req := buzzRequest{GameID: p.GameID, PlayerID: p.PlayerID, Nonce: cmd.Nonce}
+				if err := req.validate(); err != nil {
+					writeRequestError(w, err)
+				} else if err := srv.checkPlayerIn(r, p.GameID, p.PlayerID); err != nil {
+					writeRequestError(w, err)
+				} else {
+					srv.buzzIn(w, r, req)
+				}
+			case cmd.Type == wsPongCommand:
+				srv.pong(w, pongRequest{PlayerID: p.PlayerID, PingID: cmd.PingID})
+			default:
+				writeRequestError(w, badRequest("type", "unknown command %q", cmd.Type))
+			}
+
+			if err := stream.writeJSON(w.reply(cmd.Ref, stream.version)); err != nil {
+				log.Printf("failed to reply to player %s: %v", p.PlayerID, err)
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// readUntilClosed reads and discards frames so control frames are handled,
+// returning a channel closed once the socket is. a hijacked connection
+// doesn't cancel the request's context, reading is how a closed socket gets
+// noticed.
+func readUntilClosed(conn *websocket.Conn) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	return done
+}